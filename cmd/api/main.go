@@ -41,7 +41,7 @@ func main() {
 	logger.Println("Succesfully connected to database")
 
 	repository := repository.NewRepository(db)
-	service := service.NewService(*repository, cfg.JWT)
+	service := service.NewService(*repository, *cfg)
 	router := handler.SetupRouter(*repository, *service, db, logger)
 
 	server := &http.Server{
@@ -52,6 +52,11 @@ func main() {
 		IdleTimeout:  cfg.Server.IdleTimeout,
 	}
 
+	// Request streams, bin streams and load tests stream to their clients for
+	// minutes or more, they are canceled so Shutdown does not wait for them.
+	server.RegisterOnShutdown(service.StopStreams)
+	service.Start()
+
 	go func() {
 		logger.Printf("Server starting on port %s", cfg.Server.Port)
 		err := server.ListenAndServe()
@@ -69,9 +74,23 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	err = server.Shutdown(ctx)
-	if err != nil {
-		logger.Fatalf("Server shutdown failed: %v", err)
+	// The background work is stopped even when connections did not close in
+	// time, with its own deadline.
+	shutdownErr := server.Shutdown(ctx)
+	if shutdownErr != nil {
+		logger.Printf("Server shutdown failed: %v", shutdownErr)
+	}
+
+	// Collection runs and monitor checks in progress are canceled, run
+	// reports are saved and monitors released for another replica.
+	backgroundCtx, cancelBackground := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelBackground()
+	if err := service.Shutdown(backgroundCtx); err != nil {
+		shutdownErr = err
+		logger.Printf("Background work did not stop in time: %v", err)
+	}
+	if shutdownErr != nil {
+		os.Exit(1)
 	}
 	logger.Println("Server successfully shut down")
 }
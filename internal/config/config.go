@@ -3,14 +3,18 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 )
 
 type Config struct {
-	Server ServerConfig
-	DB     DBConfig
-	JWT    JWTConfig
+	Server   ServerConfig
+	DB       DBConfig
+	JWT      JWTConfig
+	Response ResponseConfig
+	LoadTest LoadTestConfig
 }
 
 type ServerConfig struct {
@@ -35,6 +39,76 @@ type JWTConfig struct {
 	AccessTokenExpiresIn time.Duration
 }
 
+// ResponseConfig controls how large response bodies are spooled to disk.
+// Spool limits are set per user tier, a limit of 0 disables spooling for
+// that tier, which is the default for anonymous callers. SpoolQuota bounds
+// the bodies on disk together.
+type ResponseConfig struct {
+	SpoolDir            string
+	SpoolTTL            time.Duration
+	SpoolQuota          int64
+	PreviewSize         int64
+	AnonymousSpoolLimit int64
+	UserSpoolLimit      int64
+}
+
+// SpoolLimit returns the largest body that may be spooled for a user tier.
+func (c ResponseConfig) SpoolLimit(authenticated bool) int64 {
+	if authenticated {
+		return c.UserSpoolLimit
+	}
+	return c.AnonymousSpoolLimit
+}
+
+// LoadTestConfig bounds load tests. Only hosts in AllowedHosts may be load
+// tested, so the server cannot be used to flood third parties; an entry
+// starting with "*." also allows every subdomain. Load testing is off while
+// the list is empty.
+type LoadTestConfig struct {
+	AllowedHosts   []string
+	MaxRate        int
+	MaxConcurrency int
+	MaxDuration    time.Duration
+	MaxRequests    int
+}
+
+// AllowsHost reports whether hostname may be load tested.
+func (c LoadTestConfig) AllowsHost(hostname string) bool {
+	hostname = strings.ToLower(strings.TrimSuffix(hostname, "."))
+	for _, allowed := range c.AllowedHosts {
+		if suffix, ok := strings.CutPrefix(allowed, "*."); ok {
+			if strings.HasSuffix(hostname, "."+suffix) {
+				return true
+			}
+		} else if hostname == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// getEnvList reads a comma separated environment variable, dropping empty
+// entries.
+func getEnvList(key string) []string {
+	var list []string
+	for _, entry := range strings.Split(os.Getenv(key), ",") {
+		if entry = strings.ToLower(strings.TrimSpace(entry)); entry != "" {
+			list = append(list, entry)
+		}
+	}
+	return list
+}
+
+// getEnvInt reads an integer environment variable, falling back to def when
+// it is unset or invalid.
+func getEnvInt(key string, def int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return value
+}
+
 func LoadConfig() (*Config, error) {
 	dbPort, err := strconv.Atoi(os.Getenv("DB_PORT"))
 	if err != nil {
@@ -75,10 +149,34 @@ func LoadConfig() (*Config, error) {
 		AccessTokenExpiresIn: time.Duration(accessTokenExpMin) * time.Minute,
 	}
 
+	spoolDir := os.Getenv("RESPONSE_SPOOL_DIR")
+	if spoolDir == "" {
+		spoolDir = filepath.Join(os.TempDir(), "suar-responses")
+	}
+
+	responseConf := ResponseConfig{
+		SpoolDir:            spoolDir,
+		SpoolTTL:            time.Duration(getEnvInt("RESPONSE_SPOOL_TTL_MINUTES", 30)) * time.Minute,
+		PreviewSize:         int64(getEnvInt("RESPONSE_PREVIEW_KB", 64)) * 1024,
+		SpoolQuota:          int64(getEnvInt("RESPONSE_SPOOL_QUOTA_MB", 10240)) * 1024 * 1024,
+		AnonymousSpoolLimit: int64(getEnvInt("ANONYMOUS_SPOOL_LIMIT_MB", 0)) * 1024 * 1024,
+		UserSpoolLimit:      int64(getEnvInt("USER_SPOOL_LIMIT_MB", 1024)) * 1024 * 1024,
+	}
+
+	loadTestConf := LoadTestConfig{
+		AllowedHosts:   getEnvList("LOAD_TEST_ALLOWED_HOSTS"),
+		MaxRate:        getEnvInt("LOAD_TEST_MAX_RATE", 50),
+		MaxConcurrency: getEnvInt("LOAD_TEST_MAX_CONCURRENCY", 20),
+		MaxDuration:    time.Duration(getEnvInt("LOAD_TEST_MAX_DURATION_SECONDS", 60)) * time.Second,
+		MaxRequests:    getEnvInt("LOAD_TEST_MAX_REQUESTS", 10000),
+	}
+
 	return &Config{
-		Server: serverConfig,
-		DB:     dBConfig,
-		JWT:    jwtConf,
+		Server:   serverConfig,
+		DB:       dBConfig,
+		JWT:      jwtConf,
+		Response: responseConf,
+		LoadTest: loadTestConf,
 	}, nil
 
 }
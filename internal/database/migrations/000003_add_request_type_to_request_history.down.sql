@@ -0,0 +1,3 @@
+-- +migrate Down
+ALTER TABLE request_history DROP COLUMN IF EXISTS session_transcript;
+ALTER TABLE request_history DROP COLUMN IF EXISTS request_type;
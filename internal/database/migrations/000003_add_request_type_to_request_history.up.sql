@@ -0,0 +1,7 @@
+-- +migrate Up
+
+-- Jenis entri riwayat, 'http' untuk request biasa dan 'websocket' untuk sesi WebSocket.
+ALTER TABLE request_history ADD COLUMN request_type VARCHAR(16) NOT NULL DEFAULT 'http';
+
+-- Transkrip sesi (pesan, arah, timestamp, close code) untuk entri non-HTTP.
+ALTER TABLE request_history ADD COLUMN session_transcript JSONB;
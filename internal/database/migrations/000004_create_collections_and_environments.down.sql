@@ -0,0 +1,4 @@
+-- +migrate Down
+DROP TABLE IF EXISTS environments;
+DROP TABLE IF EXISTS collection_items;
+DROP TABLE IF EXISTS collections;
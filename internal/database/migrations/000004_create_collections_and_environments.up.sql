@@ -0,0 +1,54 @@
+-- +migrate Up
+
+-- Koleksi request milik user, berisi variabel, auth dan script di level koleksi.
+CREATE TABLE collections (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    variables JSONB NOT NULL DEFAULT '[]',
+    auth JSONB,
+    scripts JSONB,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TRIGGER update_collections_updated_at
+BEFORE UPDATE ON collections
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+CREATE INDEX idx_collections_user_id ON collections(user_id);
+
+-- Folder dan request tersimpan dalam koleksi. parent_id NULL berarti item berada di root koleksi.
+CREATE TABLE collection_items (
+    id SERIAL PRIMARY KEY,
+    collection_id INTEGER NOT NULL REFERENCES collections(id) ON DELETE CASCADE,
+    parent_id INTEGER REFERENCES collection_items(id) ON DELETE CASCADE,
+    item_type VARCHAR(16) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    position INTEGER NOT NULL DEFAULT 0,
+    request JSONB,
+    auth JSONB,
+    scripts JSONB
+);
+
+CREATE INDEX idx_collection_items_collection_id ON collection_items(collection_id);
+
+-- Environment berisi variabel yang dapat dipakai oleh request.
+CREATE TABLE environments (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    variables JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TRIGGER update_environments_updated_at
+BEFORE UPDATE ON environments
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+CREATE INDEX idx_environments_user_id ON environments(user_id);
@@ -0,0 +1,3 @@
+-- +migrate Down
+ALTER TABLE collection_items DROP COLUMN IF EXISTS contract;
+DROP TABLE IF EXISTS api_specs;
@@ -0,0 +1,16 @@
+-- +migrate Up
+
+-- Dokumen OpenAPI milik user, disimpan sebagai JSON walaupun diunggah dalam format YAML.
+CREATE TABLE api_specs (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    version VARCHAR(64),
+    document JSONB NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_api_specs_user_id ON api_specs(user_id);
+
+-- Kontrak request tersimpan: operasi OpenAPI (spec_id, operation) atau JSON Schema untuk body respons.
+ALTER TABLE collection_items ADD COLUMN contract JSONB;
@@ -0,0 +1,4 @@
+-- +migrate Up
+
+-- Hasil assertion (lulus/gagal, nilai aktual) yang dievaluasi setelah request HTTP dijalankan.
+ALTER TABLE request_history ADD COLUMN assertion_results JSONB;
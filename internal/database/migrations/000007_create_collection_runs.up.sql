@@ -0,0 +1,23 @@
+-- +migrate Up
+
+-- Eksekusi seluruh request tersimpan dalam koleksi atau folder. results berisi hasil per request
+-- tanpa body respons, totals berisi ringkasannya. Run tetap disimpan walaupun koleksinya dihapus.
+CREATE TABLE collection_runs (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    collection_id INTEGER REFERENCES collections(id) ON DELETE SET NULL,
+    folder_id INTEGER,
+    environment_id INTEGER REFERENCES environments(id) ON DELETE SET NULL,
+    name VARCHAR(255) NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    concurrency INTEGER NOT NULL DEFAULT 1,
+    totals JSONB NOT NULL DEFAULT '{}',
+    results JSONB NOT NULL DEFAULT '[]',
+    error TEXT,
+    -- Diisi saat user membatalkan run, dibaca oleh replica yang menjalankannya.
+    cancel_requested BOOLEAN NOT NULL DEFAULT FALSE,
+    started_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_collection_runs_user_id ON collection_runs(user_id, started_at DESC);
@@ -0,0 +1,2 @@
+-- +migrate Down
+ALTER TABLE collection_runs DROP COLUMN IF EXISTS iterations;
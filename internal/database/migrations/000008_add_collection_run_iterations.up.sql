@@ -0,0 +1,4 @@
+-- +migrate Up
+
+-- Ringkasan per iterasi untuk run yang memakai data CSV/JSON, beserta baris data yang dipakai.
+ALTER TABLE collection_runs ADD COLUMN iterations JSONB NOT NULL DEFAULT '[]';
@@ -0,0 +1,3 @@
+-- +migrate Down
+DROP TABLE IF EXISTS monitor_results;
+DROP TABLE IF EXISTS monitors;
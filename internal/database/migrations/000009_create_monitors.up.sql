@@ -0,0 +1,46 @@
+-- +migrate Up
+
+-- Monitor menjalankan koleksi, folder, atau satu request tersimpan sesuai jadwal cron.
+-- next_run_at dihitung ulang setiap selesai dijalankan. lease_until menandai monitor
+-- yang sedang dijalankan oleh salah satu replica; lease yang kedaluwarsa (replica mati)
+-- boleh diambil alih replica lain.
+CREATE TABLE monitors (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    collection_id INTEGER NOT NULL REFERENCES collections(id) ON DELETE CASCADE,
+    item_id INTEGER,
+    environment_id INTEGER REFERENCES environments(id) ON DELETE SET NULL,
+    name VARCHAR(255) NOT NULL,
+    schedule VARCHAR(255) NOT NULL,
+    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    timeout INTEGER NOT NULL DEFAULT 0,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    next_run_at TIMESTAMPTZ NOT NULL,
+    lease_until TIMESTAMPTZ,
+    last_run_at TIMESTAMPTZ,
+    last_status VARCHAR(16),
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_monitors_user_id ON monitors(user_id);
+CREATE INDEX idx_monitors_due ON monitors(next_run_at) WHERE enabled;
+
+-- Hasil setiap kali monitor dijalankan. latency_ms adalah total waktu respons semua
+-- request dalam satu kali jalan, dipakai untuk menghitung persentil.
+CREATE TABLE monitor_results (
+    id BIGSERIAL PRIMARY KEY,
+    monitor_id INTEGER NOT NULL REFERENCES monitors(id) ON DELETE CASCADE,
+    status VARCHAR(16) NOT NULL,
+    requests INTEGER NOT NULL DEFAULT 0,
+    failed INTEGER NOT NULL DEFAULT 0,
+    assertions INTEGER NOT NULL DEFAULT 0,
+    assertions_failed INTEGER NOT NULL DEFAULT 0,
+    latency_ms BIGINT NOT NULL DEFAULT 0,
+    results JSONB NOT NULL DEFAULT '[]',
+    error TEXT,
+    started_at TIMESTAMPTZ NOT NULL,
+    finished_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_monitor_results_monitor_id ON monitor_results(monitor_id, started_at DESC);
@@ -0,0 +1,10 @@
+-- +migrate Down
+DROP TABLE IF EXISTS alert_deliveries;
+DROP TABLE IF EXISTS monitor_alerts;
+ALTER TABLE monitor_results DROP COLUMN IF EXISTS failures;
+ALTER TABLE monitors
+    DROP COLUMN IF EXISTS latency_threshold_ms,
+    DROP COLUMN IF EXISTS alert_after,
+    DROP COLUMN IF EXISTS alert_state,
+    DROP COLUMN IF EXISTS consecutive_failures,
+    DROP COLUMN IF EXISTS failing_since;
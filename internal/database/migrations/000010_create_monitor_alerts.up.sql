@@ -0,0 +1,51 @@
+-- +migrate Up
+
+-- Status alert per monitor. consecutive_failures dan failing_since dihitung setiap
+-- selesai dicek; alert_state berpindah ke 'failing' setelah alert_after kali gagal
+-- berturut-turut dan kembali ke 'ok' saat pulih, hanya perpindahan ini yang dikirim.
+ALTER TABLE monitors
+    ADD COLUMN latency_threshold_ms INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN alert_after INTEGER NOT NULL DEFAULT 1,
+    ADD COLUMN alert_state VARCHAR(16) NOT NULL DEFAULT 'ok',
+    ADD COLUMN consecutive_failures INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN failing_since TIMESTAMPTZ;
+
+ALTER TABLE monitor_results ADD COLUMN failures JSONB NOT NULL DEFAULT '[]';
+
+-- Webhook tujuan notifikasi sebuah monitor: generic (JSON, opsional ditandatangani
+-- HMAC dengan secret), slack, atau teams.
+CREATE TABLE monitor_alerts (
+    id SERIAL PRIMARY KEY,
+    monitor_id INTEGER NOT NULL REFERENCES monitors(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    kind VARCHAR(16) NOT NULL,
+    url TEXT NOT NULL,
+    secret VARCHAR(255),
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_monitor_alerts_monitor_id ON monitor_alerts(monitor_id);
+
+-- Antrian pengiriman notifikasi (outbox). Baris dibuat dalam transaksi yang sama dengan
+-- hasil cek, lalu dikirim dan dicoba ulang oleh replica mana pun. dedup_key mencegah
+-- notifikasi ganda untuk insiden yang sama.
+CREATE TABLE alert_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    alert_id INTEGER NOT NULL REFERENCES monitor_alerts(id) ON DELETE CASCADE,
+    monitor_id INTEGER NOT NULL REFERENCES monitors(id) ON DELETE CASCADE,
+    event VARCHAR(32) NOT NULL,
+    dedup_key VARCHAR(255) NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    sent_at TIMESTAMPTZ,
+    UNIQUE (alert_id, dedup_key)
+);
+
+CREATE INDEX idx_alert_deliveries_pending ON alert_deliveries(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX idx_alert_deliveries_monitor_id ON alert_deliveries(monitor_id, created_at DESC);
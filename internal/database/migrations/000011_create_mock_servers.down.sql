@@ -0,0 +1,4 @@
+-- +migrate Down
+DROP TABLE IF EXISTS mock_hits;
+DROP TABLE IF EXISTS mock_servers;
+ALTER TABLE collection_items DROP COLUMN IF EXISTS examples;
@@ -0,0 +1,37 @@
+-- +migrate Up
+
+-- Contoh respons tersimpan dari sebuah request, dipakai oleh mock server.
+ALTER TABLE collection_items ADD COLUMN examples JSONB;
+
+-- Mock server menyajikan contoh respons sebuah koleksi di /mock/{id}/... tanpa login.
+-- id berupa string acak karena URL mock bersifat publik dan tidak boleh bisa ditebak.
+CREATE TABLE mock_servers (
+    id VARCHAR(32) PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    collection_id INTEGER NOT NULL REFERENCES collections(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    delay_ms INTEGER NOT NULL DEFAULT 0,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_mock_servers_user_id ON mock_servers(user_id);
+
+-- Log setiap request yang masuk ke mock server, termasuk yang tidak cocok dengan contoh mana pun.
+CREATE TABLE mock_hits (
+    id BIGSERIAL PRIMARY KEY,
+    mock_id VARCHAR(32) NOT NULL REFERENCES mock_servers(id) ON DELETE CASCADE,
+    method VARCHAR(16) NOT NULL,
+    path TEXT NOT NULL,
+    query TEXT,
+    headers JSONB NOT NULL DEFAULT '[]',
+    body TEXT,
+    item_id INTEGER,
+    example VARCHAR(255),
+    status INTEGER NOT NULL,
+    duration_ms BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_mock_hits_mock_id ON mock_hits(mock_id, id DESC);
@@ -0,0 +1,3 @@
+-- +migrate Down
+DROP TABLE IF EXISTS bin_requests;
+DROP TABLE IF EXISTS request_bins;
@@ -0,0 +1,32 @@
+-- +migrate Up
+
+-- Request bin merekam setiap request yang masuk ke /bin/{id}/... tanpa login, lalu
+-- membalas dengan respons yang diatur user. id berupa string acak karena URL-nya publik.
+CREATE TABLE request_bins (
+    id VARCHAR(32) PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    response JSONB NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_request_bins_user_id ON request_bins(user_id);
+
+-- Request yang direkam sebuah bin. Body yang bukan UTF-8 disimpan sebagai base64.
+CREATE TABLE bin_requests (
+    id BIGSERIAL PRIMARY KEY,
+    bin_id VARCHAR(32) NOT NULL REFERENCES request_bins(id) ON DELETE CASCADE,
+    method VARCHAR(16) NOT NULL,
+    path TEXT NOT NULL,
+    query TEXT,
+    headers JSONB NOT NULL DEFAULT '[]',
+    body TEXT,
+    body_encoding VARCHAR(16),
+    size BIGINT NOT NULL DEFAULT 0,
+    truncated BOOLEAN NOT NULL DEFAULT FALSE,
+    source_ip VARCHAR(64),
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_bin_requests_bin_id ON bin_requests(bin_id, id DESC);
@@ -0,0 +1,11 @@
+-- +migrate Up
+
+-- Tiket sekali pakai untuk membuka WebSocket dan stream dari browser, yang tidak bisa
+-- mengirim header Authorization. Hanya hash SHA-256 tiket yang disimpan.
+CREATE TABLE auth_tickets (
+    ticket_hash CHAR(64) PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_auth_tickets_expires_at ON auth_tickets(expires_at);
@@ -0,0 +1,2 @@
+-- +migrate Down
+ALTER TABLE request_history DROP COLUMN IF EXISTS timings;
@@ -0,0 +1,5 @@
+-- +migrate Up
+
+-- Rincian waktu request per fase (blocked, dns, connect, tls, send, wait, receive)
+-- dalam milidetik, dipakai untuk export HAR.
+ALTER TABLE request_history ADD COLUMN timings JSONB;
@@ -0,0 +1,3 @@
+-- +migrate Down
+DROP INDEX IF EXISTS idx_collection_runs_running;
+ALTER TABLE collection_runs DROP COLUMN IF EXISTS heartbeat_at;
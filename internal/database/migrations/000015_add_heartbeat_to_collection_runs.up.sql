@@ -0,0 +1,8 @@
+-- +migrate Up
+
+-- Diperbarui secara berkala oleh replica yang menjalankan run. Run berstatus running
+-- yang heartbeat-nya sudah lama tidak diperbarui (replica mati) ditandai gagal dan
+-- tidak lagi dihitung dalam batas run aktif per user.
+ALTER TABLE collection_runs ADD COLUMN heartbeat_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+CREATE INDEX idx_collection_runs_running ON collection_runs(user_id, heartbeat_at) WHERE status = 'running';
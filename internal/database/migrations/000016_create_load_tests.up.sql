@@ -0,0 +1,15 @@
+-- +migrate Up
+
+-- Load test yang sedang berjalan, satu baris per test. Dipakai untuk membatasi load
+-- test per user dan per host di semua replica. Baris yang heartbeat-nya sudah lama
+-- tidak diperbarui (replica mati) tidak dihitung dan dihapus saat test baru dimulai.
+CREATE TABLE load_tests (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    host VARCHAR(255) NOT NULL,
+    started_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    heartbeat_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_load_tests_user_id ON load_tests(user_id);
+CREATE INDEX idx_load_tests_host ON load_tests(host);
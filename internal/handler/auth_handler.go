@@ -74,3 +74,16 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 
 	respondWithJson(w, http.StatusOK, resp)
 }
+
+// IssueTicket returns a single-use ticket for opening a WebSocket or event
+// stream, which browsers cannot send an Authorization header with.
+func (h *AuthHandler) IssueTicket(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserIDFromContext(r.Context())
+	resp, err := h.authService.IssueTicket(r.Context(), *userID)
+	if err != nil {
+		h.logger.Printf("Error issuing ticket: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to issue ticket")
+		return
+	}
+	respondWithJson(w, http.StatusCreated, resp)
+}
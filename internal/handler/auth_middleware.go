@@ -5,6 +5,7 @@ import (
 	"errors"
 	"log"
 	"net/http"
+	"net/url"
 	"strings"
 
 	"github.com/suar-net/suar-be/internal/model"
@@ -30,6 +31,12 @@ func NewAuthMiddleware(s service.IAuthService, l *log.Logger) *AuthMiddleware {
 // middleware untuk memeriksa token JWT
 func (m *AuthMiddleware) Authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Sudah diautentikasi dengan tiket oleh AllowTicket.
+		if _, ok := GetUserFromContext(r.Context()); ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
 			respondWithError(w, http.StatusUnauthorized, "Authorization header is required")
@@ -62,8 +69,99 @@ func (m *AuthMiddleware) Authenticate(next http.Handler) http.Handler {
 	})
 }
 
+// middleware untuk endpoint yang bisa dipakai dengan atau tanpa login.
+// Request tanpa Authorization header diteruskan sebagai anonim, tetapi token
+// yang dikirim tetap harus valid.
+func (m *AuthMiddleware) OptionalAuthenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		m.Authenticate(next).ServeHTTP(w, r)
+	})
+}
+
+// middleware untuk endpoint yang dibuka browser tanpa header Authorization
+// (WebSocket, EventSource). Browser meminta tiket sekali pakai lewat
+// POST /auth/tickets lalu mengirimnya di query parameter ticket, sehingga JWT
+// tidak pernah muncul di URL. Request tanpa tiket diteruskan apa adanya.
+func (m *AuthMiddleware) AllowTicket(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ticket := r.URL.Query().Get("ticket")
+		if ticket == "" || r.Header.Get("Authorization") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		claims, err := m.authService.RedeemTicket(r.Context(), ticket)
+		if err != nil {
+			if errors.Is(err, service.ErrTokenInvalid) {
+				respondWithError(w, http.StatusUnauthorized, "Invalid or expired ticket")
+			} else {
+				m.logger.Printf("ERROR: %v", err)
+				respondWithError(w, http.StatusInternalServerError, "Failed to redeem ticket")
+			}
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), userContextKey, claims)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
 // helper untuk mendapatkan claims pengguna dari context.
 func GetUserFromContext(ctx context.Context) (*model.Claims, bool) {
 	claims, ok := ctx.Value(userContextKey).(*model.Claims)
 	return claims, ok
 }
+
+// helper untuk mendapatkan ID pengguna dari context, nil untuk pengguna anonim.
+func GetUserIDFromContext(ctx context.Context) *int {
+	claims, ok := GetUserFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	return &claims.ID
+}
+
+// redactedQueryParams adalah query parameter yang berisi kredensial dan tidak
+// boleh tercatat di log request.
+var redactedQueryParams = []string{"ticket", "access_token"}
+
+// middleware yang menyamarkan kredensial di query string sebelum request
+// dicatat oleh middleware.Logger. Handler tetap membaca URL aslinya.
+func RedactQueryCredentials(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if uri, ok := redactRequestURI(r.RequestURI); ok {
+			r = r.WithContext(r.Context())
+			r.RequestURI = uri
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func redactRequestURI(uri string) (string, bool) {
+	path, rawQuery, found := strings.Cut(uri, "?")
+	if !found {
+		return uri, false
+	}
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		// Query yang tidak bisa di-parse tidak dicatat sama sekali.
+		return path + "?REDACTED", true
+	}
+	redacted := false
+	for _, name := range redactedQueryParams {
+		if values, ok := query[name]; ok {
+			for i := range values {
+				values[i] = "REDACTED"
+			}
+			redacted = true
+		}
+	}
+	if !redacted {
+		return uri, false
+	}
+	return path + "?" + query.Encode(), true
+}
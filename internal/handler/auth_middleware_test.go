@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/suar-net/suar-be/internal/model"
+	"github.com/suar-net/suar-be/internal/service"
+)
+
+func TestRedactRequestURI(t *testing.T) {
+	tests := []struct {
+		uri  string
+		want string
+		ok   bool
+	}{
+		{"/api/v1/ws", "/api/v1/ws", false},
+		{"/api/v1/ws?url=x", "/api/v1/ws?url=x", false},
+		{"/api/v1/ws?ticket=abc&url=x", "/api/v1/ws?ticket=REDACTED&url=x", true},
+		{"/api/v1/bins/1/stream?access_token=eyJ", "/api/v1/bins/1/stream?access_token=REDACTED", true},
+		{"/a?ticket=1&ticket=2", "/a?ticket=REDACTED&ticket=REDACTED", true},
+		{"/a?%zz", "/a?REDACTED", true},
+	}
+	for _, tt := range tests {
+		got, ok := redactRequestURI(tt.uri)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("redactRequestURI(%q) = %q, %v, want %q, %v", tt.uri, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+type fakeAuthService struct {
+	service.IAuthService
+	tickets map[string]int
+}
+
+func (f *fakeAuthService) ValidateToken(ctx context.Context, token string) (*model.Claims, error) {
+	if token != "valid" {
+		return nil, service.ErrTokenInvalid
+	}
+	return &model.Claims{ID: 1}, nil
+}
+
+func (f *fakeAuthService) RedeemTicket(ctx context.Context, ticket string) (*model.Claims, error) {
+	userID, ok := f.tickets[ticket]
+	if !ok {
+		return nil, service.ErrTokenInvalid
+	}
+	delete(f.tickets, ticket)
+	return &model.Claims{ID: userID}, nil
+}
+
+func TestAllowTicket(t *testing.T) {
+	m := NewAuthMiddleware(&fakeAuthService{tickets: map[string]int{"t1": 7}}, log.New(io.Discard, "", 0))
+	var gotUser *int
+	handler := m.AllowTicket(m.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUser = GetUserIDFromContext(r.Context())
+	})))
+
+	tests := []struct {
+		name   string
+		target string
+		header string
+		status int
+		user   int
+	}{
+		{"ticket", "/stream?ticket=t1", "", http.StatusOK, 7},
+		{"ticket is single use", "/stream?ticket=t1", "", http.StatusUnauthorized, 0},
+		{"unknown ticket", "/stream?ticket=nope", "", http.StatusUnauthorized, 0},
+		{"header still works", "/stream", "Bearer valid", http.StatusOK, 1},
+		{"no credentials", "/stream", "", http.StatusUnauthorized, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotUser = nil
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if tt.user != 0 && (gotUser == nil || *gotUser != tt.user) {
+				t.Errorf("user = %v, want %d", gotUser, tt.user)
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/suar-net/suar-be/internal/model"
+	"github.com/suar-net/suar-be/internal/service"
+)
+
+// maxBinBodySize bounds how much of the body of a captured request is kept;
+// the rest is read and counted but dropped, up to maxBinReadSize. Past it
+// the connection is closed and the size is taken from Content-Length.
+const (
+	maxBinBodySize = 1 << 20
+	maxBinReadSize = 32 << 20
+)
+
+type BinHandler struct {
+	binService service.IRequestBinService
+	logger     *log.Logger
+}
+
+func NewBinHandler(s service.IRequestBinService, l *log.Logger) *BinHandler {
+	return &BinHandler{
+		binService: s,
+		logger:     l,
+	}
+}
+
+// respondWithBinError maps service errors of the request bin endpoints to status codes.
+func (h *BinHandler) respondWithBinError(w http.ResponseWriter, err error) {
+	h.logger.Printf("ERROR: %v", err)
+	if errors.Is(err, service.ErrInvalidInput) {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	} else if errors.Is(err, service.ErrBinNotFound) {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	} else if errors.Is(err, service.ErrBinLimitReached) {
+		respondWithError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	respondWithError(w, http.StatusInternalServerError, "An internal error occurred")
+}
+
+func decodeRequestBin(w http.ResponseWriter, r *http.Request) (*model.DTORequestBinRequest, bool) {
+	var dto model.DTORequestBinRequest
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid JSON format")
+		return nil, false
+	}
+	if err := validate.Struct(&dto); err != nil {
+		respondWithError(w, http.StatusBadRequest, ValidationError(err))
+		return nil, false
+	}
+	return &dto, true
+}
+
+func (h *BinHandler) Create(w http.ResponseWriter, r *http.Request) {
+	dto, ok := decodeRequestBin(w, r)
+	if !ok {
+		return
+	}
+
+	bin, err := h.binService.CreateBin(r.Context(), *GetUserIDFromContext(r.Context()), dto)
+	if err != nil {
+		h.respondWithBinError(w, err)
+		return
+	}
+	respondWithJson(w, http.StatusCreated, bin)
+}
+
+func (h *BinHandler) List(w http.ResponseWriter, r *http.Request) {
+	bins, err := h.binService.ListBins(r.Context(), *GetUserIDFromContext(r.Context()))
+	if err != nil {
+		h.respondWithBinError(w, err)
+		return
+	}
+	respondWithJson(w, http.StatusOK, bins)
+}
+
+func (h *BinHandler) Get(w http.ResponseWriter, r *http.Request) {
+	bin, err := h.binService.GetBin(r.Context(), chi.URLParam(r, "binID"), *GetUserIDFromContext(r.Context()))
+	if err != nil {
+		h.respondWithBinError(w, err)
+		return
+	}
+	respondWithJson(w, http.StatusOK, bin)
+}
+
+func (h *BinHandler) Update(w http.ResponseWriter, r *http.Request) {
+	dto, ok := decodeRequestBin(w, r)
+	if !ok {
+		return
+	}
+
+	bin, err := h.binService.UpdateBin(r.Context(), chi.URLParam(r, "binID"), *GetUserIDFromContext(r.Context()), dto)
+	if err != nil {
+		h.respondWithBinError(w, err)
+		return
+	}
+	respondWithJson(w, http.StatusOK, bin)
+}
+
+func (h *BinHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if err := h.binService.DeleteBin(r.Context(), chi.URLParam(r, "binID"), *GetUserIDFromContext(r.Context())); err != nil {
+		h.respondWithBinError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Requests lists the latest requests a bin recorded, newest first; the limit
+// query parameter caps how many.
+func (h *BinHandler) Requests(w http.ResponseWriter, r *http.Request) {
+	limit, ok := queryLimit(w, r)
+	if !ok {
+		return
+	}
+
+	captures, err := h.binService.ListRequests(r.Context(), chi.URLParam(r, "binID"), *GetUserIDFromContext(r.Context()), limit)
+	if err != nil {
+		h.respondWithBinError(w, err)
+		return
+	}
+	respondWithJson(w, http.StatusOK, captures)
+}
+
+func (h *BinHandler) Clear(w http.ResponseWriter, r *http.Request) {
+	if err := h.binService.ClearRequests(r.Context(), chi.URLParam(r, "binID"), *GetUserIDFromContext(r.Context())); err != nil {
+		h.respondWithBinError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Stream pushes the requests a bin records as Server-Sent Events. The after
+// query parameter resumes from the ID of the last request the client saw.
+func (h *BinHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	var afterID int64
+	if after := r.URL.Query().Get("after"); after != "" {
+		id, err := strconv.ParseInt(after, 10, 64)
+		if err != nil || id < 0 {
+			respondWithError(w, http.StatusBadRequest, "Invalid after parameter")
+			return
+		}
+		afterID = id
+	}
+
+	sse := newSSEWriter(w)
+	err := h.binService.WatchBin(r.Context(), chi.URLParam(r, "binID"), *GetUserIDFromContext(r.Context()), afterID, func(event model.DTOBinEvent) error {
+		return sse.Send(event.Type, event)
+	})
+	if err != nil {
+		if sse.Started() {
+			h.logger.Printf("ERROR: %v", err)
+			return
+		}
+		h.respondWithBinError(w, err)
+	}
+}
+
+// Capture records a request sent to a bin and answers it with the bin's
+// response. It is public: anyone with the URL of a bin can send to it, so the
+// service only hands back headers and statuses that are safe to send.
+func (h *BinHandler) Capture(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBinReadSize)
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBinBodySize))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Failed to read request body")
+		return
+	}
+	size := int64(len(body))
+	if size == maxBinBodySize {
+		rest, err := io.Copy(io.Discard, r.Body)
+		size += rest
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			size = max(size, r.ContentLength)
+		} else if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Failed to read request body")
+			return
+		}
+	}
+
+	sourceIP, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		sourceIP = r.RemoteAddr
+	}
+	req := &model.DTOBinCapture{
+		Method:    r.Method,
+		Path:      "/" + chi.URLParam(r, "*"),
+		RawQuery:  r.URL.RawQuery,
+		Headers:   r.Header,
+		Body:      body,
+		Size:      size,
+		Truncated: size > int64(len(body)),
+		SourceIP:  sourceIP,
+	}
+
+	response, err := h.binService.Capture(r.Context(), chi.URLParam(r, "binID"), req)
+	if err != nil {
+		h.respondWithBinError(w, err)
+		return
+	}
+	for _, header := range response.Headers {
+		w.Header().Add(header.Key, header.Value)
+	}
+	w.WriteHeader(response.Status)
+	io.WriteString(w, response.Body)
+}
@@ -0,0 +1,256 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/suar-net/suar-be/internal/model"
+	"github.com/suar-net/suar-be/internal/service"
+)
+
+type CollectionHandler struct {
+	collectionService service.ICollectionService
+	logger            *log.Logger
+}
+
+func NewCollectionHandler(s service.ICollectionService, l *log.Logger) *CollectionHandler {
+	return &CollectionHandler{
+		collectionService: s,
+		logger:            l,
+	}
+}
+
+// urlParamID parses a numeric route parameter. It responds with an error
+// itself and returns false when the parameter is not a valid ID.
+func urlParamID(w http.ResponseWriter, r *http.Request, name string) (int, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, name))
+	if err != nil || id <= 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid "+name)
+		return 0, false
+	}
+	return id, true
+}
+
+// respondWithCollectionError maps service errors of the collection endpoints to status codes.
+func (h *CollectionHandler) respondWithCollectionError(w http.ResponseWriter, err error) {
+	h.logger.Printf("ERROR: %v", err)
+	if errors.Is(err, service.ErrInvalidInput) {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	} else if errors.Is(err, service.ErrCollectionNotFound) || errors.Is(err, service.ErrItemNotFound) ||
+		errors.Is(err, service.ErrEnvironmentNotFound) {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	respondWithError(w, http.StatusInternalServerError, "An internal error occurred")
+}
+
+func (h *CollectionHandler) List(w http.ResponseWriter, r *http.Request) {
+	collections, err := h.collectionService.ListCollections(r.Context(), *GetUserIDFromContext(r.Context()))
+	if err != nil {
+		h.respondWithCollectionError(w, err)
+		return
+	}
+	respondWithJson(w, http.StatusOK, collections)
+}
+
+// Get returns a collection with its folders and saved requests.
+func (h *CollectionHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, ok := urlParamID(w, r, "collectionID")
+	if !ok {
+		return
+	}
+
+	collection, err := h.collectionService.GetCollection(r.Context(), id, *GetUserIDFromContext(r.Context()))
+	if err != nil {
+		h.respondWithCollectionError(w, err)
+		return
+	}
+	respondWithJson(w, http.StatusOK, collection)
+}
+
+func (h *CollectionHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, ok := urlParamID(w, r, "collectionID")
+	if !ok {
+		return
+	}
+
+	if err := h.collectionService.DeleteCollection(r.Context(), id, *GetUserIDFromContext(r.Context())); err != nil {
+		h.respondWithCollectionError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+var unsafeFilenameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// ExportPostman downloads a collection as a Postman v2.1 collection file.
+func (h *CollectionHandler) ExportPostman(w http.ResponseWriter, r *http.Request) {
+	id, ok := urlParamID(w, r, "collectionID")
+	if !ok {
+		return
+	}
+
+	postman, err := h.collectionService.ExportPostman(r.Context(), id, *GetUserIDFromContext(r.Context()))
+	if err != nil {
+		h.respondWithCollectionError(w, err)
+		return
+	}
+
+	filename := unsafeFilenameChars.ReplaceAllString(postman.Info.Name, "_") + ".postman_collection.json"
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	respondWithJson(w, http.StatusOK, postman)
+}
+
+// SetContract links a saved request to an OpenAPI operation or a JSON Schema
+// its responses are validated against.
+func (h *CollectionHandler) SetContract(w http.ResponseWriter, r *http.Request) {
+	collectionID, ok := urlParamID(w, r, "collectionID")
+	if !ok {
+		return
+	}
+	itemID, ok := urlParamID(w, r, "itemID")
+	if !ok {
+		return
+	}
+
+	var contract model.Contract
+	if err := json.NewDecoder(r.Body).Decode(&contract); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid JSON format")
+		return
+	}
+
+	if err := h.collectionService.SetItemContract(r.Context(), collectionID, itemID, *GetUserIDFromContext(r.Context()), &contract); err != nil {
+		h.respondWithCollectionError(w, err)
+		return
+	}
+	respondWithJson(w, http.StatusOK, contract)
+}
+
+// DeleteContract removes the contract of a saved request.
+func (h *CollectionHandler) DeleteContract(w http.ResponseWriter, r *http.Request) {
+	collectionID, ok := urlParamID(w, r, "collectionID")
+	if !ok {
+		return
+	}
+	itemID, ok := urlParamID(w, r, "itemID")
+	if !ok {
+		return
+	}
+
+	if err := h.collectionService.SetItemContract(r.Context(), collectionID, itemID, *GetUserIDFromContext(r.Context()), nil); err != nil {
+		h.respondWithCollectionError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SetExtractions replaces the rules that copy values of a saved request's
+// responses into the active environment, an empty list removes them.
+func (h *CollectionHandler) SetExtractions(w http.ResponseWriter, r *http.Request) {
+	collectionID, ok := urlParamID(w, r, "collectionID")
+	if !ok {
+		return
+	}
+	itemID, ok := urlParamID(w, r, "itemID")
+	if !ok {
+		return
+	}
+
+	var dto model.DTOExtractionsRequest
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid JSON format")
+		return
+	}
+	if err := validate.Struct(&dto); err != nil {
+		respondWithError(w, http.StatusBadRequest, ValidationError(err))
+		return
+	}
+
+	if err := h.collectionService.SetItemExtractions(r.Context(), collectionID, itemID, *GetUserIDFromContext(r.Context()), dto.Extractions); err != nil {
+		h.respondWithCollectionError(w, err)
+		return
+	}
+	if dto.Extractions == nil {
+		dto.Extractions = []model.Extraction{}
+	}
+	respondWithJson(w, http.StatusOK, dto)
+}
+
+// SetExamples replaces the saved responses of a request that mock servers
+// answer with, an empty list removes them.
+func (h *CollectionHandler) SetExamples(w http.ResponseWriter, r *http.Request) {
+	collectionID, ok := urlParamID(w, r, "collectionID")
+	if !ok {
+		return
+	}
+	itemID, ok := urlParamID(w, r, "itemID")
+	if !ok {
+		return
+	}
+
+	var dto model.DTOExamplesRequest
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid JSON format")
+		return
+	}
+	if err := validate.Struct(&dto); err != nil {
+		respondWithError(w, http.StatusBadRequest, ValidationError(err))
+		return
+	}
+
+	if err := h.collectionService.SetItemExamples(r.Context(), collectionID, itemID, *GetUserIDFromContext(r.Context()), dto.Examples); err != nil {
+		h.respondWithCollectionError(w, err)
+		return
+	}
+	if dto.Examples == nil {
+		dto.Examples = []model.ResponseExample{}
+	}
+	respondWithJson(w, http.StatusOK, dto)
+}
+
+// Run sends a saved request with its pre-request and test scripts, using the
+// variables of the given environment. A request that fails is reported in
+// the result, not as an error status.
+func (h *CollectionHandler) Run(w http.ResponseWriter, r *http.Request) {
+	collectionID, ok := urlParamID(w, r, "collectionID")
+	if !ok {
+		return
+	}
+	itemID, ok := urlParamID(w, r, "itemID")
+	if !ok {
+		return
+	}
+
+	var dto model.DTOItemRunRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid JSON format")
+			return
+		}
+	}
+	if err := validate.Struct(&dto); err != nil {
+		respondWithError(w, http.StatusBadRequest, ValidationError(err))
+		return
+	}
+
+	// Scripts and the request together may take longer than the server's
+	// WriteTimeout.
+	http.NewResponseController(w).SetWriteDeadline(time.Now().Add(proxyWriteTimeout))
+
+	result, err := h.collectionService.RunItem(r.Context(), collectionID, itemID, *GetUserIDFromContext(r.Context()), &dto)
+	if err != nil && result == nil {
+		h.respondWithCollectionError(w, err)
+		return
+	} else if err != nil {
+		// The request itself ran, only saving the variables failed.
+		h.logger.Printf("ERROR: %v", err)
+	}
+	respondWithJson(w, http.StatusOK, result)
+}
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/suar-net/suar-be/internal/service"
+)
+
+type EnvironmentHandler struct {
+	environmentService service.IEnvironmentService
+	logger             *log.Logger
+}
+
+func NewEnvironmentHandler(s service.IEnvironmentService, l *log.Logger) *EnvironmentHandler {
+	return &EnvironmentHandler{
+		environmentService: s,
+		logger:             l,
+	}
+}
+
+// respondWithEnvironmentError maps service errors of the environment endpoints to status codes.
+func (h *EnvironmentHandler) respondWithEnvironmentError(w http.ResponseWriter, err error) {
+	h.logger.Printf("ERROR: %v", err)
+	if errors.Is(err, service.ErrInvalidInput) {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	} else if errors.Is(err, service.ErrEnvironmentNotFound) {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	respondWithError(w, http.StatusInternalServerError, "An internal error occurred")
+}
+
+func (h *EnvironmentHandler) List(w http.ResponseWriter, r *http.Request) {
+	environments, err := h.environmentService.ListEnvironments(r.Context(), *GetUserIDFromContext(r.Context()))
+	if err != nil {
+		h.respondWithEnvironmentError(w, err)
+		return
+	}
+	respondWithJson(w, http.StatusOK, environments)
+}
+
+func (h *EnvironmentHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, ok := urlParamID(w, r, "environmentID")
+	if !ok {
+		return
+	}
+
+	environment, err := h.environmentService.GetEnvironment(r.Context(), id, *GetUserIDFromContext(r.Context()))
+	if err != nil {
+		h.respondWithEnvironmentError(w, err)
+		return
+	}
+	respondWithJson(w, http.StatusOK, environment)
+}
+
+func (h *EnvironmentHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, ok := urlParamID(w, r, "environmentID")
+	if !ok {
+		return
+	}
+
+	if err := h.environmentService.DeleteEnvironment(r.Context(), id, *GetUserIDFromContext(r.Context())); err != nil {
+		h.respondWithEnvironmentError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
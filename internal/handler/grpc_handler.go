@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/suar-net/suar-be/internal/model"
+	"github.com/suar-net/suar-be/internal/service"
+)
+
+type GRPCHandler struct {
+	grpcService service.IGRPCService
+	logger      *log.Logger
+}
+
+func NewGRPCHandler(s service.IGRPCService, l *log.Logger) *GRPCHandler {
+	return &GRPCHandler{
+		grpcService: s,
+		logger:      l,
+	}
+}
+
+// respondWithGRPCError maps service errors of the gRPC endpoints to status codes.
+func (h *GRPCHandler) respondWithGRPCError(w http.ResponseWriter, err error) {
+	h.logger.Printf("ERROR: %v", err)
+	if errors.Is(err, service.ErrInvalidInput) {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	} else if errors.Is(err, service.ErrUpstreamFailed) {
+		respondWithError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	respondWithError(w, http.StatusInternalServerError, "An internal error occurred")
+}
+
+func (h *GRPCHandler) ListServices(w http.ResponseWriter, r *http.Request) {
+	var dto model.DTOGRPCListRequest
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid JSON format")
+		return
+	}
+
+	if err := validate.Struct(&dto); err != nil {
+		respondWithError(w, http.StatusBadRequest, ValidationError(err))
+		return
+	}
+
+	services, err := h.grpcService.ListServices(r.Context(), &dto)
+	if err != nil {
+		h.respondWithGRPCError(w, err)
+		return
+	}
+
+	respondWithJson(w, http.StatusOK, services)
+}
+
+// Invoke calls a gRPC method. A non-OK gRPC status is part of a successful
+// response, just like a non-2xx status of a proxied HTTP request.
+func (h *GRPCHandler) Invoke(w http.ResponseWriter, r *http.Request) {
+	var dto model.DTOGRPCRequest
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid JSON format")
+		return
+	}
+
+	if err := validate.Struct(&dto); err != nil {
+		respondWithError(w, http.StatusBadRequest, ValidationError(err))
+		return
+	}
+
+	http.NewResponseController(w).SetWriteDeadline(time.Now().Add(proxyWriteTimeout))
+
+	resp, err := h.grpcService.Invoke(r.Context(), GetUserIDFromContext(r.Context()), &dto)
+	if err != nil {
+		if resp == nil {
+			h.respondWithGRPCError(w, err)
+			return
+		}
+		// The call itself went through, only recording it failed.
+		h.logger.Printf("ERROR: %v", err)
+	}
+
+	respondWithJson(w, http.StatusOK, resp)
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/suar-net/suar-be/internal/model"
+	"github.com/suar-net/suar-be/internal/service"
+)
+
+// maxHARUploadSize bounds the size of an imported HAR file. Browser exports
+// embed response bodies and grow quickly.
+const maxHARUploadSize = 50 * 1024 * 1024
+
+type HistoryHandler struct {
+	historyService service.IHistoryService
+	logger         *log.Logger
+}
+
+func NewHistoryHandler(s service.IHistoryService, l *log.Logger) *HistoryHandler {
+	return &HistoryHandler{
+		historyService: s,
+		logger:         l,
+	}
+}
+
+// respondWithHistoryError maps service errors of the history endpoints to status codes.
+func (h *HistoryHandler) respondWithHistoryError(w http.ResponseWriter, err error) {
+	h.logger.Printf("ERROR: %v", err)
+	if errors.Is(err, service.ErrInvalidInput) {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	} else if errors.Is(err, service.ErrHistoryNotFound) || errors.Is(err, service.ErrRunNotFound) {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	respondWithError(w, http.StatusInternalServerError, "An internal error occurred")
+}
+
+// ExportHAR downloads the selected history entries as a HAR file.
+func (h *HistoryHandler) ExportHAR(w http.ResponseWriter, r *http.Request) {
+	var dto model.DTOHARExportRequest
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid JSON format")
+		return
+	}
+
+	if err := validate.Struct(&dto); err != nil {
+		respondWithError(w, http.StatusBadRequest, ValidationError(err))
+		return
+	}
+
+	userID := GetUserIDFromContext(r.Context())
+	har, err := h.historyService.ExportHAR(r.Context(), *userID, &dto)
+	if err != nil {
+		h.respondWithHistoryError(w, err)
+		return
+	}
+
+	filename := "suar-history-" + time.Now().UTC().Format("20060102-150405") + ".har"
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	respondWithJson(w, http.StatusOK, har)
+}
+
+// ExportRunHAR downloads the requests sent by a collection run as a HAR file.
+func (h *HistoryHandler) ExportRunHAR(w http.ResponseWriter, r *http.Request) {
+	runID, ok := urlParamID(w, r, "runID")
+	if !ok {
+		return
+	}
+
+	userID := GetUserIDFromContext(r.Context())
+	har, err := h.historyService.ExportRunHAR(r.Context(), *userID, runID)
+	if err != nil {
+		h.respondWithHistoryError(w, err)
+		return
+	}
+
+	filename := fmt.Sprintf("suar-run-%d.har", runID)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	respondWithJson(w, http.StatusOK, har)
+}
+
+// ImportHAR stores the entries of an uploaded HAR file, sent as the request
+// body, in the history of the current user.
+func (h *HistoryHandler) ImportHAR(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxHARUploadSize)
+
+	var har model.HAR
+	if err := json.NewDecoder(r.Body).Decode(&har); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			respondWithError(w, http.StatusRequestEntityTooLarge, "HAR file is too large")
+			return
+		}
+		respondWithError(w, http.StatusBadRequest, "Invalid HAR file")
+		return
+	}
+
+	userID := GetUserIDFromContext(r.Context())
+	result, err := h.historyService.ImportHAR(r.Context(), *userID, &har)
+	if err != nil {
+		h.respondWithHistoryError(w, err)
+		return
+	}
+
+	respondWithJson(w, http.StatusCreated, result)
+}
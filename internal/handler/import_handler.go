@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/suar-net/suar-be/internal/model"
+	"github.com/suar-net/suar-be/internal/service"
+)
+
+// maxCollectionUploadSize bounds the size of an uploaded Postman, Insomnia or
+// OpenAPI document.
+const maxCollectionUploadSize = 20 * 1024 * 1024
+
+type ImportHandler struct {
+	importService service.IImportService
+	logger        *log.Logger
+}
+
+func NewImportHandler(s service.IImportService, l *log.Logger) *ImportHandler {
+	return &ImportHandler{
+		importService: s,
+		logger:        l,
+	}
+}
+
+// respondWithImportError maps service errors of the import endpoints to status codes.
+func (h *ImportHandler) respondWithImportError(w http.ResponseWriter, err error) {
+	h.logger.Printf("ERROR: %v", err)
+	if errors.Is(err, service.ErrInvalidInput) {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	} else if errors.Is(err, service.ErrCollectionNotFound) {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	respondWithError(w, http.StatusInternalServerError, "An internal error occurred")
+}
+
+// Curl converts a cURL command line into a request that can be sent to
+// /api/v1/request as is. Signed-in users can also save it to a collection.
+func (h *ImportHandler) Curl(w http.ResponseWriter, r *http.Request) {
+	var dto model.DTOCurlImportRequest
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid JSON format")
+		return
+	}
+
+	if err := validate.Struct(&dto); err != nil {
+		respondWithError(w, http.StatusBadRequest, ValidationError(err))
+		return
+	}
+
+	userID := GetUserIDFromContext(r.Context())
+	if dto.CollectionID != nil && userID == nil {
+		respondWithError(w, http.StatusUnauthorized, "Authorization header is required to save the request")
+		return
+	}
+
+	imported, err := h.importService.ImportCurl(r.Context(), userID, &dto)
+	if err != nil {
+		h.respondWithImportError(w, err)
+		return
+	}
+
+	if imported.Saved != nil {
+		respondWithJson(w, http.StatusCreated, imported)
+		return
+	}
+	respondWithJson(w, http.StatusOK, imported)
+}
+
+// readUpload reads an uploaded export sent as the request body, up to limit
+// bytes. It responds with an error itself and returns false when the body
+// cannot be read.
+func (h *ImportHandler) readUpload(w http.ResponseWriter, r *http.Request, limit int64) ([]byte, bool) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			respondWithError(w, http.StatusRequestEntityTooLarge, "Uploaded file is too large")
+			return nil, false
+		}
+		respondWithError(w, http.StatusBadRequest, "Failed to read request body")
+		return nil, false
+	}
+	return data, true
+}
+
+// Postman imports a Postman v2.1 collection or a Postman environment, sent as
+// the request body, for the current user.
+func (h *ImportHandler) Postman(w http.ResponseWriter, r *http.Request) {
+	data, ok := h.readUpload(w, r, maxCollectionUploadSize)
+	if !ok {
+		return
+	}
+
+	result, err := h.importService.ImportPostman(r.Context(), *GetUserIDFromContext(r.Context()), data)
+	if err != nil {
+		h.respondWithImportError(w, err)
+		return
+	}
+
+	respondWithJson(w, http.StatusCreated, result)
+}
+
+// Insomnia imports an Insomnia v4 export, sent as the request body, for the
+// current user.
+func (h *ImportHandler) Insomnia(w http.ResponseWriter, r *http.Request) {
+	data, ok := h.readUpload(w, r, maxCollectionUploadSize)
+	if !ok {
+		return
+	}
+
+	result, err := h.importService.ImportInsomnia(r.Context(), *GetUserIDFromContext(r.Context()), data)
+	if err != nil {
+		h.respondWithImportError(w, err)
+		return
+	}
+
+	respondWithJson(w, http.StatusCreated, result)
+}
+
+// OpenAPI generates a collection from an OpenAPI 3 document, JSON or YAML,
+// sent as the request body.
+func (h *ImportHandler) OpenAPI(w http.ResponseWriter, r *http.Request) {
+	data, ok := h.readUpload(w, r, maxCollectionUploadSize)
+	if !ok {
+		return
+	}
+
+	result, err := h.importService.ImportOpenAPI(r.Context(), *GetUserIDFromContext(r.Context()), data)
+	if err != nil {
+		h.respondWithImportError(w, err)
+		return
+	}
+
+	respondWithJson(w, http.StatusCreated, result)
+}
+
+// HAR saves the requests of a HAR file, sent as the request body, as a new
+// collection of the current user.
+func (h *ImportHandler) HAR(w http.ResponseWriter, r *http.Request) {
+	data, ok := h.readUpload(w, r, maxHARUploadSize)
+	if !ok {
+		return
+	}
+
+	result, err := h.importService.ImportHAR(r.Context(), *GetUserIDFromContext(r.Context()), data)
+	if err != nil {
+		h.respondWithImportError(w, err)
+		return
+	}
+
+	respondWithJson(w, http.StatusCreated, result)
+}
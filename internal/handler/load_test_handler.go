@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/suar-net/suar-be/internal/model"
+	"github.com/suar-net/suar-be/internal/service"
+)
+
+type LoadTestHandler struct {
+	loadTestService service.ILoadTestService
+	logger          *log.Logger
+}
+
+func NewLoadTestHandler(s service.ILoadTestService, l *log.Logger) *LoadTestHandler {
+	return &LoadTestHandler{
+		loadTestService: s,
+		logger:          l,
+	}
+}
+
+// respondWithLoadTestError maps service errors of the load test endpoint to status codes.
+func (h *LoadTestHandler) respondWithLoadTestError(w http.ResponseWriter, err error) {
+	h.logger.Printf("ERROR: %v", err)
+	if errors.Is(err, service.ErrInvalidInput) {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	} else if errors.Is(err, service.ErrLoadTestNotAllowed) {
+		respondWithError(w, http.StatusForbidden, err.Error())
+		return
+	} else if errors.Is(err, service.ErrLoadTestLimitReached) {
+		respondWithError(w, http.StatusTooManyRequests, err.Error())
+		return
+	}
+	respondWithError(w, http.StatusInternalServerError, "An internal error occurred")
+}
+
+// Run runs a load test and streams its progress as Server-Sent Events.
+// Closing the connection stops the test.
+func (h *LoadTestHandler) Run(w http.ResponseWriter, r *http.Request) {
+	var dto model.DTOLoadTestRequest
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid JSON format")
+		return
+	}
+
+	if err := validate.Struct(&dto); err != nil {
+		respondWithError(w, http.StatusBadRequest, ValidationError(err))
+		return
+	}
+
+	sse := newSSEWriter(w)
+	err := h.loadTestService.RunLoadTest(r.Context(), *GetUserIDFromContext(r.Context()), &dto, func(event model.DTOLoadTestEvent) error {
+		return sse.Send(event.Type, event)
+	})
+	if err != nil {
+		if sse.Started() {
+			h.logger.Printf("ERROR: %v", err)
+			return
+		}
+		h.respondWithLoadTestError(w, err)
+	}
+}
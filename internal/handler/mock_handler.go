@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/suar-net/suar-be/internal/model"
+	"github.com/suar-net/suar-be/internal/service"
+)
+
+// maxMockRequestSize bounds the body of a request to a mock server.
+const maxMockRequestSize = 1 << 20
+
+type MockHandler struct {
+	mockService service.IMockService
+	logger      *log.Logger
+}
+
+func NewMockHandler(s service.IMockService, l *log.Logger) *MockHandler {
+	return &MockHandler{
+		mockService: s,
+		logger:      l,
+	}
+}
+
+// respondWithMockError maps service errors of the mock server endpoints to status codes.
+func (h *MockHandler) respondWithMockError(w http.ResponseWriter, err error) {
+	h.logger.Printf("ERROR: %v", err)
+	if errors.Is(err, service.ErrInvalidInput) {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	} else if errors.Is(err, service.ErrMockNotFound) || errors.Is(err, service.ErrCollectionNotFound) {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	} else if errors.Is(err, service.ErrMockLimitReached) {
+		respondWithError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	respondWithError(w, http.StatusInternalServerError, "An internal error occurred")
+}
+
+func decodeMockServer(w http.ResponseWriter, r *http.Request) (*model.DTOMockServerRequest, bool) {
+	var dto model.DTOMockServerRequest
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid JSON format")
+		return nil, false
+	}
+	if err := validate.Struct(&dto); err != nil {
+		respondWithError(w, http.StatusBadRequest, ValidationError(err))
+		return nil, false
+	}
+	return &dto, true
+}
+
+func (h *MockHandler) Create(w http.ResponseWriter, r *http.Request) {
+	dto, ok := decodeMockServer(w, r)
+	if !ok {
+		return
+	}
+
+	mock, err := h.mockService.CreateMock(r.Context(), *GetUserIDFromContext(r.Context()), dto)
+	if err != nil {
+		h.respondWithMockError(w, err)
+		return
+	}
+	respondWithJson(w, http.StatusCreated, mock)
+}
+
+func (h *MockHandler) List(w http.ResponseWriter, r *http.Request) {
+	mocks, err := h.mockService.ListMocks(r.Context(), *GetUserIDFromContext(r.Context()))
+	if err != nil {
+		h.respondWithMockError(w, err)
+		return
+	}
+	respondWithJson(w, http.StatusOK, mocks)
+}
+
+func (h *MockHandler) Get(w http.ResponseWriter, r *http.Request) {
+	mock, err := h.mockService.GetMock(r.Context(), chi.URLParam(r, "mockID"), *GetUserIDFromContext(r.Context()))
+	if err != nil {
+		h.respondWithMockError(w, err)
+		return
+	}
+	respondWithJson(w, http.StatusOK, mock)
+}
+
+func (h *MockHandler) Update(w http.ResponseWriter, r *http.Request) {
+	dto, ok := decodeMockServer(w, r)
+	if !ok {
+		return
+	}
+
+	mock, err := h.mockService.UpdateMock(r.Context(), chi.URLParam(r, "mockID"), *GetUserIDFromContext(r.Context()), dto)
+	if err != nil {
+		h.respondWithMockError(w, err)
+		return
+	}
+	respondWithJson(w, http.StatusOK, mock)
+}
+
+func (h *MockHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if err := h.mockService.DeleteMock(r.Context(), chi.URLParam(r, "mockID"), *GetUserIDFromContext(r.Context())); err != nil {
+		h.respondWithMockError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Hits lists the latest requests a mock server received, the limit query
+// parameter caps how many.
+func (h *MockHandler) Hits(w http.ResponseWriter, r *http.Request) {
+	limit, ok := queryLimit(w, r)
+	if !ok {
+		return
+	}
+
+	hits, err := h.mockService.ListHits(r.Context(), chi.URLParam(r, "mockID"), *GetUserIDFromContext(r.Context()), limit)
+	if err != nil {
+		h.respondWithMockError(w, err)
+		return
+	}
+	respondWithJson(w, http.StatusOK, hits)
+}
+
+// Serve answers a request to a mock server with the matching saved example.
+// It is public: anyone with the URL of a mock server can call it, so the
+// service only hands back headers and statuses that are safe to send.
+func (h *MockHandler) Serve(w http.ResponseWriter, r *http.Request) {
+	// Examples may be delayed for longer than the server's write timeout.
+	http.NewResponseController(w).SetWriteDeadline(time.Now().Add(proxyWriteTimeout))
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxMockRequestSize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			respondWithError(w, http.StatusRequestEntityTooLarge, "Request body is too large")
+			return
+		}
+		respondWithError(w, http.StatusBadRequest, "Failed to read request body")
+		return
+	}
+	req := &model.DTOMockRequest{
+		Method:   r.Method,
+		Path:     "/" + chi.URLParam(r, "*"),
+		Query:    r.URL.Query(),
+		RawQuery: r.URL.RawQuery,
+		Headers:  r.Header,
+		Body:     body,
+	}
+
+	response, err := h.mockService.ServeMock(r.Context(), chi.URLParam(r, "mockID"), req)
+	if err != nil {
+		h.respondWithMockError(w, err)
+		return
+	}
+	for _, header := range response.Headers {
+		w.Header().Add(header.Key, header.Value)
+	}
+	w.WriteHeader(response.Status)
+	io.WriteString(w, response.Body)
+}
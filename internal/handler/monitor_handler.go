@@ -0,0 +1,314 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/suar-net/suar-be/internal/model"
+	"github.com/suar-net/suar-be/internal/service"
+)
+
+type MonitorHandler struct {
+	monitorService service.IMonitorService
+	logger         *log.Logger
+}
+
+func NewMonitorHandler(s service.IMonitorService, l *log.Logger) *MonitorHandler {
+	return &MonitorHandler{
+		monitorService: s,
+		logger:         l,
+	}
+}
+
+// respondWithMonitorError maps service errors of the monitor endpoints to status codes.
+func (h *MonitorHandler) respondWithMonitorError(w http.ResponseWriter, err error) {
+	h.logger.Printf("ERROR: %v", err)
+	if errors.Is(err, service.ErrInvalidInput) {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	} else if errors.Is(err, service.ErrMonitorNotFound) || errors.Is(err, service.ErrCollectionNotFound) ||
+		errors.Is(err, service.ErrItemNotFound) || errors.Is(err, service.ErrEnvironmentNotFound) ||
+		errors.Is(err, service.ErrAlertNotFound) {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	} else if errors.Is(err, service.ErrMonitorLimitReached) || errors.Is(err, service.ErrAlertLimitReached) {
+		respondWithError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	respondWithError(w, http.StatusInternalServerError, "An internal error occurred")
+}
+
+// decodeMonitor reads and validates the settings of a monitor. It responds
+// with an error itself and returns false when they are invalid.
+func decodeMonitor(w http.ResponseWriter, r *http.Request) (*model.DTOMonitorRequest, bool) {
+	var dto model.DTOMonitorRequest
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid JSON format")
+		return nil, false
+	}
+	if err := validate.Struct(&dto); err != nil {
+		respondWithError(w, http.StatusBadRequest, ValidationError(err))
+		return nil, false
+	}
+	return &dto, true
+}
+
+// queryLimit reads the optional limit query parameter, 0 when it is absent.
+// It responds with an error itself and returns false when it is invalid.
+func queryLimit(w http.ResponseWriter, r *http.Request) (int, bool) {
+	value := r.URL.Query().Get("limit")
+	if value == "" {
+		return 0, true
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit <= 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid limit")
+		return 0, false
+	}
+	return limit, true
+}
+
+func (h *MonitorHandler) Create(w http.ResponseWriter, r *http.Request) {
+	dto, ok := decodeMonitor(w, r)
+	if !ok {
+		return
+	}
+
+	monitor, err := h.monitorService.CreateMonitor(r.Context(), *GetUserIDFromContext(r.Context()), dto)
+	if err != nil {
+		h.respondWithMonitorError(w, err)
+		return
+	}
+	respondWithJson(w, http.StatusCreated, monitor)
+}
+
+func (h *MonitorHandler) List(w http.ResponseWriter, r *http.Request) {
+	monitors, err := h.monitorService.ListMonitors(r.Context(), *GetUserIDFromContext(r.Context()))
+	if err != nil {
+		h.respondWithMonitorError(w, err)
+		return
+	}
+	respondWithJson(w, http.StatusOK, monitors)
+}
+
+func (h *MonitorHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, ok := urlParamID(w, r, "monitorID")
+	if !ok {
+		return
+	}
+
+	monitor, err := h.monitorService.GetMonitor(r.Context(), id, *GetUserIDFromContext(r.Context()))
+	if err != nil {
+		h.respondWithMonitorError(w, err)
+		return
+	}
+	respondWithJson(w, http.StatusOK, monitor)
+}
+
+func (h *MonitorHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, ok := urlParamID(w, r, "monitorID")
+	if !ok {
+		return
+	}
+	dto, ok := decodeMonitor(w, r)
+	if !ok {
+		return
+	}
+
+	monitor, err := h.monitorService.UpdateMonitor(r.Context(), id, *GetUserIDFromContext(r.Context()), dto)
+	if err != nil {
+		h.respondWithMonitorError(w, err)
+		return
+	}
+	respondWithJson(w, http.StatusOK, monitor)
+}
+
+func (h *MonitorHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, ok := urlParamID(w, r, "monitorID")
+	if !ok {
+		return
+	}
+
+	if err := h.monitorService.DeleteMonitor(r.Context(), id, *GetUserIDFromContext(r.Context())); err != nil {
+		h.respondWithMonitorError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Trigger schedules a check of a monitor right away.
+func (h *MonitorHandler) Trigger(w http.ResponseWriter, r *http.Request) {
+	id, ok := urlParamID(w, r, "monitorID")
+	if !ok {
+		return
+	}
+
+	if err := h.monitorService.TriggerMonitor(r.Context(), id, *GetUserIDFromContext(r.Context())); err != nil {
+		h.respondWithMonitorError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// Results lists the latest checks of a monitor, the limit query parameter
+// caps how many.
+func (h *MonitorHandler) Results(w http.ResponseWriter, r *http.Request) {
+	id, ok := urlParamID(w, r, "monitorID")
+	if !ok {
+		return
+	}
+	limit, ok := queryLimit(w, r)
+	if !ok {
+		return
+	}
+
+	results, err := h.monitorService.ListResults(r.Context(), id, *GetUserIDFromContext(r.Context()), limit)
+	if err != nil {
+		h.respondWithMonitorError(w, err)
+		return
+	}
+	respondWithJson(w, http.StatusOK, results)
+}
+
+// Stats reports uptime, latency percentiles and assertion failures over the
+// window query parameter: 1h, 24h (the default), 7d or 30d.
+func (h *MonitorHandler) Stats(w http.ResponseWriter, r *http.Request) {
+	id, ok := urlParamID(w, r, "monitorID")
+	if !ok {
+		return
+	}
+
+	stats, err := h.monitorService.GetStats(r.Context(), id, *GetUserIDFromContext(r.Context()), r.URL.Query().Get("window"))
+	if err != nil {
+		h.respondWithMonitorError(w, err)
+		return
+	}
+	respondWithJson(w, http.StatusOK, stats)
+}
+
+func decodeMonitorAlert(w http.ResponseWriter, r *http.Request) (*model.DTOMonitorAlertRequest, bool) {
+	var dto model.DTOMonitorAlertRequest
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid JSON format")
+		return nil, false
+	}
+	if err := validate.Struct(&dto); err != nil {
+		respondWithError(w, http.StatusBadRequest, ValidationError(err))
+		return nil, false
+	}
+	return &dto, true
+}
+
+func (h *MonitorHandler) ListAlerts(w http.ResponseWriter, r *http.Request) {
+	monitorID, ok := urlParamID(w, r, "monitorID")
+	if !ok {
+		return
+	}
+
+	alerts, err := h.monitorService.ListAlerts(r.Context(), monitorID, *GetUserIDFromContext(r.Context()))
+	if err != nil {
+		h.respondWithMonitorError(w, err)
+		return
+	}
+	respondWithJson(w, http.StatusOK, alerts)
+}
+
+func (h *MonitorHandler) CreateAlert(w http.ResponseWriter, r *http.Request) {
+	monitorID, ok := urlParamID(w, r, "monitorID")
+	if !ok {
+		return
+	}
+	dto, ok := decodeMonitorAlert(w, r)
+	if !ok {
+		return
+	}
+
+	alert, err := h.monitorService.CreateAlert(r.Context(), monitorID, *GetUserIDFromContext(r.Context()), dto)
+	if err != nil {
+		h.respondWithMonitorError(w, err)
+		return
+	}
+	respondWithJson(w, http.StatusCreated, alert)
+}
+
+func (h *MonitorHandler) UpdateAlert(w http.ResponseWriter, r *http.Request) {
+	monitorID, ok := urlParamID(w, r, "monitorID")
+	if !ok {
+		return
+	}
+	id, ok := urlParamID(w, r, "alertID")
+	if !ok {
+		return
+	}
+	dto, ok := decodeMonitorAlert(w, r)
+	if !ok {
+		return
+	}
+
+	alert, err := h.monitorService.UpdateAlert(r.Context(), id, monitorID, *GetUserIDFromContext(r.Context()), dto)
+	if err != nil {
+		h.respondWithMonitorError(w, err)
+		return
+	}
+	respondWithJson(w, http.StatusOK, alert)
+}
+
+func (h *MonitorHandler) DeleteAlert(w http.ResponseWriter, r *http.Request) {
+	monitorID, ok := urlParamID(w, r, "monitorID")
+	if !ok {
+		return
+	}
+	id, ok := urlParamID(w, r, "alertID")
+	if !ok {
+		return
+	}
+
+	if err := h.monitorService.DeleteAlert(r.Context(), id, monitorID, *GetUserIDFromContext(r.Context())); err != nil {
+		h.respondWithMonitorError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// TestAlert sends a test notification to an alert and reports whether the
+// webhook accepted it.
+func (h *MonitorHandler) TestAlert(w http.ResponseWriter, r *http.Request) {
+	monitorID, ok := urlParamID(w, r, "monitorID")
+	if !ok {
+		return
+	}
+	id, ok := urlParamID(w, r, "alertID")
+	if !ok {
+		return
+	}
+
+	result, err := h.monitorService.TestAlert(r.Context(), id, monitorID, *GetUserIDFromContext(r.Context()))
+	if err != nil {
+		h.respondWithMonitorError(w, err)
+		return
+	}
+	respondWithJson(w, http.StatusOK, result)
+}
+
+// Deliveries lists the latest notifications queued for the alerts of a
+// monitor with their outcome, the limit query parameter caps how many.
+func (h *MonitorHandler) Deliveries(w http.ResponseWriter, r *http.Request) {
+	monitorID, ok := urlParamID(w, r, "monitorID")
+	if !ok {
+		return
+	}
+	limit, ok := queryLimit(w, r)
+	if !ok {
+		return
+	}
+
+	deliveries, err := h.monitorService.ListDeliveries(r.Context(), monitorID, *GetUserIDFromContext(r.Context()), limit)
+	if err != nil {
+		h.respondWithMonitorError(w, err)
+		return
+	}
+	respondWithJson(w, http.StatusOK, deliveries)
+}
@@ -5,11 +5,17 @@ import (
 	"errors"
 	"log"
 	"net/http"
+	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/suar-net/suar-be/internal/model"
 	"github.com/suar-net/suar-be/internal/service"
 )
 
+// proxyWriteTimeout covers the longest allowed outbound request plus the time
+// needed to read and spool its body.
+const proxyWriteTimeout = 2 * time.Minute
+
 type RequestHandler struct {
 	requestService service.IRequestService
 	logger         *log.Logger
@@ -41,9 +47,16 @@ func (h *RequestHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The outbound request may legitimately take longer than the server's
+	// WriteTimeout, so extend the deadline for this response only.
+	http.NewResponseController(w).SetWriteDeadline(time.Now().Add(proxyWriteTimeout))
+
 	// r.Context() carries deadlines, cancellation signals, and other request-scoped values.
-	dtoResponse, err := h.requestService.ProcessRequest(r.Context(), &dto)
-	if err != nil {
+	dtoResponse, err := h.requestService.ProcessRequest(r.Context(), GetUserIDFromContext(r.Context()), &dto)
+	if err != nil && dtoResponse != nil {
+		// The request itself went through, only recording it failed.
+		h.logger.Printf("ERROR: %v", err)
+	} else if err != nil {
 		h.logger.Printf("ERROR: %v", err)
 
 		// Check for specific error types to return appropriate status codes
@@ -62,3 +75,82 @@ func (h *RequestHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	respondWithJson(w, http.StatusOK, dtoResponse)
 }
+
+// Stream executes a request and relays the upstream body to the client as
+// Server-Sent Events while it is still being received.
+func (h *RequestHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	var dto model.DTORequest
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid JSON format")
+		return
+	}
+
+	if err := validate.Struct(&dto); err != nil {
+		respondWithError(w, http.StatusBadRequest, ValidationError(err))
+		return
+	}
+
+	sse := newSSEWriter(w)
+	err := h.requestService.StreamRequest(r.Context(), GetUserIDFromContext(r.Context()), &dto, func(event model.DTOStreamEvent) error {
+		return sse.Send(event.Type, event)
+	})
+	if err != nil {
+		h.logger.Printf("ERROR: %v", err)
+		if sse.Started() {
+			return
+		}
+		if errors.Is(err, service.ErrInvalidInput) {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		} else if errors.Is(err, service.ErrStreamLimitReached) {
+			respondWithError(w, http.StatusTooManyRequests, err.Error())
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "An internal error occurred")
+	}
+}
+
+// CancelStream stops a running stream by the ID sent in its first event. A
+// signed in user can only cancel their own streams.
+func (h *RequestHandler) CancelStream(w http.ResponseWriter, r *http.Request) {
+	if err := h.requestService.CancelStream(GetUserIDFromContext(r.Context()), chi.URLParam(r, "streamID")); err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// IntrospectGraphQL returns the schema of a GraphQL endpoint for autocomplete.
+// Once fetched, the schema is also used to validate graphql requests the same
+// user sends to the endpoint with the same environment and credentials.
+func (h *RequestHandler) IntrospectGraphQL(w http.ResponseWriter, r *http.Request) {
+	var dto model.DTOGraphQLIntrospectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid JSON format")
+		return
+	}
+
+	if err := validate.Struct(&dto); err != nil {
+		respondWithError(w, http.StatusBadRequest, ValidationError(err))
+		return
+	}
+
+	schema, err := h.requestService.IntrospectGraphQL(r.Context(), *GetUserIDFromContext(r.Context()), &dto)
+	if err != nil {
+		h.logger.Printf("ERROR: %v", err)
+		if errors.Is(err, service.ErrInvalidInput) {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		} else if errors.Is(err, service.ErrRequestTimeout) {
+			respondWithError(w, http.StatusGatewayTimeout, err.Error())
+			return
+		} else if errors.Is(err, service.ErrUpstreamFailed) {
+			respondWithError(w, http.StatusBadGateway, err.Error())
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "An internal error occurred")
+		return
+	}
+
+	respondWithJson(w, http.StatusOK, schema)
+}
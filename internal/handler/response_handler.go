@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/suar-net/suar-be/internal/service"
+)
+
+type ResponseHandler struct {
+	requestService service.IRequestService
+	logger         *log.Logger
+}
+
+func NewResponseHandler(s service.IRequestService, l *log.Logger) *ResponseHandler {
+	return &ResponseHandler{
+		requestService: s,
+		logger:         l,
+	}
+}
+
+// Download streams a spooled response body. Range requests are handled by
+// http.ServeContent so large downloads can be resumed.
+func (h *ResponseHandler) Download(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+
+	spooled, file, err := h.requestService.OpenSpooledResponse(token)
+	if err != nil {
+		if errors.Is(err, service.ErrResponseNotFound) {
+			respondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		h.logger.Printf("Error opening spooled response: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to open response")
+		return
+	}
+	defer file.Close()
+
+	// Large bodies take longer than the server's WriteTimeout to transfer.
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	contentType := spooled.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": "response-" + spooled.Token[:8],
+	}))
+
+	http.ServeContent(w, r, "", spooled.CreatedAt, file)
+}
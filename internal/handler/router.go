@@ -11,6 +11,14 @@ import (
 	"github.com/suar-net/suar-be/internal/service"
 )
 
+// allowedOrigins lists the frontends allowed to call the API from a browser.
+// It is shared by CORS and the WebSocket origin check.
+var allowedOrigins = []string{
+	"https://suar.vercel.app",
+	"http://localhost:3000",
+	"http://localhost:5173",
+}
+
 // SetupRouter creates the main Chi router for the application.
 // add necessary service by inject it into the handlers.
 func SetupRouter(
@@ -23,23 +31,35 @@ func SetupRouter(
 	r := chi.NewRouter()
 
 	// global middleware
+	r.Use(RedactQueryCredentials)
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 	r.Use(cors.Handler(
 		cors.Options{
-			AllowedOrigins: []string{
-				"https://suar.vercel.app",
-				"http://localhost:3000",
-				"http://localhost:5173",
-			},
+			AllowedOrigins:   allowedOrigins,
 			AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-			AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type"},
+			AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "Range"},
+			ExposedHeaders:   []string{"Accept-Ranges", "Content-Disposition", "Content-Range"},
 			AllowCredentials: true,
 		},
 	))
 
 	// --- Inisialisasi Semua Handler ---
 	requestHandler := NewRequestHandelr(service.RequestService(), logger)
+	responseHandler := NewResponseHandler(service.RequestService(), logger)
+	webSocketHandler := NewWebSocketHandler(service.WebSocketService(), logger)
+	grpcHandler := NewGRPCHandler(service.GRPCService(), logger)
+	importHandler := NewImportHandler(service.ImportService(), logger)
+	snippetHandler := NewSnippetHandler(service.SnippetService(), logger)
+	historyHandler := NewHistoryHandler(service.HistoryService(), logger)
+	collectionHandler := NewCollectionHandler(service.CollectionService(), logger)
+	runHandler := NewRunHandler(service.RunService(), logger)
+	monitorHandler := NewMonitorHandler(service.MonitorService(), logger)
+	mockHandler := NewMockHandler(service.MockService(), logger)
+	binHandler := NewBinHandler(service.RequestBinService(), logger)
+	loadTestHandler := NewLoadTestHandler(service.LoadTestService(), logger)
+	environmentHandler := NewEnvironmentHandler(service.EnvironmentService(), logger)
+	specHandler := NewSpecHandler(service.SpecService(), logger)
 	authHandler := NewAuthHandler(service.AuthService(), logger)
 	healthHandler := NewHealthHandler(db, logger)
 
@@ -51,14 +71,106 @@ func SetupRouter(
 		r.Route("/auth", func(r chi.Router) {
 			r.Post("/register", authHandler.Register)
 			r.Post("/login", authHandler.Login)
+			r.With(authMiddleware.Authenticate).Post("/tickets", authHandler.IssueTicket)
+		})
+		r.With(authMiddleware.OptionalAuthenticate).Post("/request", requestHandler.ServeHTTP)
+		r.With(authMiddleware.AllowTicket, authMiddleware.OptionalAuthenticate).Post("/request/stream", requestHandler.Stream)
+		r.With(authMiddleware.Authenticate).Post("/graphql/introspect", requestHandler.IntrospectGraphQL)
+		r.With(authMiddleware.AllowTicket, authMiddleware.OptionalAuthenticate).Delete("/request/stream/{streamID}", requestHandler.CancelStream)
+		r.With(authMiddleware.AllowTicket, authMiddleware.OptionalAuthenticate).Get("/ws", webSocketHandler.Connect)
+		r.With(authMiddleware.AllowTicket, authMiddleware.Authenticate).Get("/bins/{binID}/stream", binHandler.Stream)
+		r.Route("/grpc", func(r chi.Router) {
+			r.Use(authMiddleware.OptionalAuthenticate)
+			r.Post("/services", grpcHandler.ListServices)
+			r.Post("/invoke", grpcHandler.Invoke)
+		})
+		r.Get("/responses/{token}", responseHandler.Download)
+		r.With(authMiddleware.OptionalAuthenticate).Post("/snippets", snippetHandler.Generate)
+		r.Route("/import", func(r chi.Router) {
+			r.With(authMiddleware.OptionalAuthenticate).Post("/curl", importHandler.Curl)
+			r.With(authMiddleware.Authenticate).Post("/postman", importHandler.Postman)
+			r.With(authMiddleware.Authenticate).Post("/insomnia", importHandler.Insomnia)
+			r.With(authMiddleware.Authenticate).Post("/openapi", importHandler.OpenAPI)
+			r.With(authMiddleware.Authenticate).Post("/har", importHandler.HAR)
 		})
-		r.Post("/request", requestHandler.ServeHTTP)
 
 		r.Group(func(r chi.Router) {
 			r.Use(authMiddleware.Authenticate)
-
+			r.Post("/history/har/export", historyHandler.ExportHAR)
+			r.Post("/history/har/import", historyHandler.ImportHAR)
+			r.Post("/load-tests", loadTestHandler.Run)
+			r.Route("/collections", func(r chi.Router) {
+				r.Get("/", collectionHandler.List)
+				r.Get("/{collectionID}", collectionHandler.Get)
+				r.Delete("/{collectionID}", collectionHandler.Delete)
+				r.Get("/{collectionID}/export/postman", collectionHandler.ExportPostman)
+				r.Put("/{collectionID}/items/{itemID}/contract", collectionHandler.SetContract)
+				r.Delete("/{collectionID}/items/{itemID}/contract", collectionHandler.DeleteContract)
+				r.Put("/{collectionID}/items/{itemID}/extractions", collectionHandler.SetExtractions)
+				r.Put("/{collectionID}/items/{itemID}/examples", collectionHandler.SetExamples)
+				r.Post("/{collectionID}/items/{itemID}/run", collectionHandler.Run)
+				r.Post("/{collectionID}/run", runHandler.Start)
+				r.Get("/{collectionID}/runs", runHandler.List)
+			})
+			r.Route("/runs", func(r chi.Router) {
+				r.Get("/", runHandler.List)
+				r.Get("/{runID}", runHandler.Get)
+				r.Post("/{runID}/cancel", runHandler.Cancel)
+				r.Get("/{runID}/har", historyHandler.ExportRunHAR)
+			})
+			r.Route("/monitors", func(r chi.Router) {
+				r.Get("/", monitorHandler.List)
+				r.Post("/", monitorHandler.Create)
+				r.Get("/{monitorID}", monitorHandler.Get)
+				r.Put("/{monitorID}", monitorHandler.Update)
+				r.Delete("/{monitorID}", monitorHandler.Delete)
+				r.Post("/{monitorID}/run", monitorHandler.Trigger)
+				r.Get("/{monitorID}/results", monitorHandler.Results)
+				r.Get("/{monitorID}/stats", monitorHandler.Stats)
+				r.Get("/{monitorID}/alerts", monitorHandler.ListAlerts)
+				r.Post("/{monitorID}/alerts", monitorHandler.CreateAlert)
+				r.Put("/{monitorID}/alerts/{alertID}", monitorHandler.UpdateAlert)
+				r.Delete("/{monitorID}/alerts/{alertID}", monitorHandler.DeleteAlert)
+				r.Post("/{monitorID}/alerts/{alertID}/test", monitorHandler.TestAlert)
+				r.Get("/{monitorID}/deliveries", monitorHandler.Deliveries)
+			})
+			r.Route("/mocks", func(r chi.Router) {
+				r.Get("/", mockHandler.List)
+				r.Post("/", mockHandler.Create)
+				r.Get("/{mockID}", mockHandler.Get)
+				r.Put("/{mockID}", mockHandler.Update)
+				r.Delete("/{mockID}", mockHandler.Delete)
+				r.Get("/{mockID}/hits", mockHandler.Hits)
+			})
+			r.Route("/bins", func(r chi.Router) {
+				r.Get("/", binHandler.List)
+				r.Post("/", binHandler.Create)
+				r.Get("/{binID}", binHandler.Get)
+				r.Put("/{binID}", binHandler.Update)
+				r.Delete("/{binID}", binHandler.Delete)
+				r.Get("/{binID}/requests", binHandler.Requests)
+				r.Delete("/{binID}/requests", binHandler.Clear)
+			})
+			r.Route("/environments", func(r chi.Router) {
+				r.Get("/", environmentHandler.List)
+				r.Get("/{environmentID}", environmentHandler.Get)
+				r.Delete("/{environmentID}", environmentHandler.Delete)
+			})
+			r.Route("/specs", func(r chi.Router) {
+				r.Get("/", specHandler.List)
+				r.Get("/{specID}", specHandler.Get)
+				r.Delete("/{specID}", specHandler.Delete)
+			})
 		})
 	})
 
+	// Mock servers answer any method on any path below their ID.
+	r.HandleFunc("/mock/{mockID}", mockHandler.Serve)
+	r.HandleFunc("/mock/{mockID}/*", mockHandler.Serve)
+
+	// Request bins record any method on any path below their ID.
+	r.HandleFunc("/bin/{binID}", binHandler.Capture)
+	r.HandleFunc("/bin/{binID}/*", binHandler.Capture)
+
 	return r
 }
@@ -0,0 +1,191 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/suar-net/suar-be/internal/model"
+	"github.com/suar-net/suar-be/internal/service"
+)
+
+// maxRunUploadSize bounds the body that starts a run, data included.
+const maxRunUploadSize = 6 << 20
+
+type RunHandler struct {
+	runService service.IRunService
+	logger     *log.Logger
+}
+
+func NewRunHandler(s service.IRunService, l *log.Logger) *RunHandler {
+	return &RunHandler{
+		runService: s,
+		logger:     l,
+	}
+}
+
+// respondWithRunError maps service errors of the run endpoints to status codes.
+func (h *RunHandler) respondWithRunError(w http.ResponseWriter, err error) {
+	h.logger.Printf("ERROR: %v", err)
+	if errors.Is(err, service.ErrInvalidInput) {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	} else if errors.Is(err, service.ErrCollectionNotFound) || errors.Is(err, service.ErrFolderNotFound) ||
+		errors.Is(err, service.ErrEnvironmentNotFound) || errors.Is(err, service.ErrRunNotFound) {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	} else if errors.Is(err, service.ErrRunLimitReached) {
+		respondWithError(w, http.StatusTooManyRequests, err.Error())
+		return
+	}
+	respondWithError(w, http.StatusInternalServerError, "An internal error occurred")
+}
+
+// Start runs the saved requests of a collection, or of one of its folders,
+// in the background. The response is the new run; its report fills in as
+// the requests complete. The options are sent as JSON, or as the "options"
+// field of a multipart form whose "data" file holds the CSV or JSON data.
+func (h *RunHandler) Start(w http.ResponseWriter, r *http.Request) {
+	collectionID, ok := urlParamID(w, r, "collectionID")
+	if !ok {
+		return
+	}
+
+	var dto model.DTOCollectionRunRequest
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		if !h.readDataUpload(w, r, &dto) {
+			return
+		}
+	} else if r.ContentLength != 0 {
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRunUploadSize)).Decode(&dto); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid JSON format")
+			return
+		}
+	}
+	if err := validate.Struct(&dto); err != nil {
+		respondWithError(w, http.StatusBadRequest, ValidationError(err))
+		return
+	}
+
+	run, err := h.runService.StartRun(r.Context(), collectionID, *GetUserIDFromContext(r.Context()), &dto)
+	if err != nil {
+		h.respondWithRunError(w, err)
+		return
+	}
+	respondWithJson(w, http.StatusAccepted, run)
+}
+
+// List returns the latest runs without their per request results. Under
+// /collections/{collectionID}/runs only the runs of that collection are
+// listed.
+func (h *RunHandler) List(w http.ResponseWriter, r *http.Request) {
+	var collectionID *int
+	if chi.URLParam(r, "collectionID") != "" {
+		id, ok := urlParamID(w, r, "collectionID")
+		if !ok {
+			return
+		}
+		collectionID = &id
+	}
+
+	runs, err := h.runService.ListRuns(r.Context(), *GetUserIDFromContext(r.Context()), collectionID)
+	if err != nil {
+		h.respondWithRunError(w, err)
+		return
+	}
+	respondWithJson(w, http.StatusOK, runs)
+}
+
+// Get returns a run with the result of each request.
+func (h *RunHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, ok := urlParamID(w, r, "runID")
+	if !ok {
+		return
+	}
+
+	run, err := h.runService.GetRun(r.Context(), id, *GetUserIDFromContext(r.Context()))
+	if err != nil {
+		h.respondWithRunError(w, err)
+		return
+	}
+	respondWithJson(w, http.StatusOK, run)
+}
+
+// Cancel stops a run in progress. The requests that completed stay in its
+// report.
+func (h *RunHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	id, ok := urlParamID(w, r, "runID")
+	if !ok {
+		return
+	}
+
+	if err := h.runService.CancelRun(r.Context(), id, *GetUserIDFromContext(r.Context())); err != nil {
+		h.respondWithRunError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// readDataUpload reads the options and the data file of a multipart run
+// request into dto. It responds with an error itself and returns false when
+// the form cannot be read.
+func (h *RunHandler) readDataUpload(w http.ResponseWriter, r *http.Request, dto *model.DTOCollectionRunRequest) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRunUploadSize)
+	if err := r.ParseMultipartForm(maxRunUploadSize); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			respondWithError(w, http.StatusRequestEntityTooLarge, "Uploaded file is too large")
+			return false
+		}
+		respondWithError(w, http.StatusBadRequest, "Invalid multipart form")
+		return false
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	if options := r.FormValue("options"); options != "" {
+		if err := json.Unmarshal([]byte(options), dto); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid JSON format in options")
+			return false
+		}
+	}
+
+	file, header, err := r.FormFile("data")
+	if errors.Is(err, http.ErrMissingFile) {
+		return true
+	} else if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Failed to read the data file")
+		return false
+	}
+	defer file.Close()
+	content, err := io.ReadAll(file)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Failed to read the data file")
+		return false
+	}
+
+	if !utf8.Valid(content) {
+		respondWithError(w, http.StatusBadRequest, "Data file must be UTF-8 text")
+		return false
+	}
+	// The file is passed on as a string, like data sent in a JSON body.
+	if dto.Data, err = json.Marshal(string(content)); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Failed to read the data file")
+		return false
+	}
+	if dto.DataFormat == "" {
+		switch strings.ToLower(path.Ext(header.Filename)) {
+		case ".csv":
+			dto.DataFormat = "csv"
+		case ".json":
+			dto.DataFormat = "json"
+		}
+	}
+	return true
+}
@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/suar-net/suar-be/internal/model"
+	"github.com/suar-net/suar-be/internal/service"
+)
+
+type SnippetHandler struct {
+	snippetService service.ISnippetService
+	logger         *log.Logger
+}
+
+func NewSnippetHandler(s service.ISnippetService, l *log.Logger) *SnippetHandler {
+	return &SnippetHandler{
+		snippetService: s,
+		logger:         l,
+	}
+}
+
+// Generate returns client code for a request definition or a history entry.
+func (h *SnippetHandler) Generate(w http.ResponseWriter, r *http.Request) {
+	var dto model.DTOSnippetRequest
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid JSON format")
+		return
+	}
+
+	if err := validate.Struct(&dto); err != nil {
+		respondWithError(w, http.StatusBadRequest, ValidationError(err))
+		return
+	}
+
+	userID := GetUserIDFromContext(r.Context())
+	if (dto.HistoryID != nil || dto.ItemID != nil) && userID == nil {
+		respondWithError(w, http.StatusUnauthorized, "Authentication is required to use history_id or item_id")
+		return
+	}
+
+	snippets, err := h.snippetService.Generate(r.Context(), userID, &dto)
+	if err != nil {
+		h.logger.Printf("ERROR: %v", err)
+		if errors.Is(err, service.ErrInvalidInput) {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		} else if errors.Is(err, service.ErrHistoryNotFound) || errors.Is(err, service.ErrCollectionNotFound) ||
+			errors.Is(err, service.ErrItemNotFound) || errors.Is(err, service.ErrEnvironmentNotFound) {
+			respondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "An internal error occurred")
+		return
+	}
+
+	respondWithJson(w, http.StatusOK, snippets)
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/suar-net/suar-be/internal/service"
+)
+
+type SpecHandler struct {
+	specService service.ISpecService
+	logger      *log.Logger
+}
+
+func NewSpecHandler(s service.ISpecService, l *log.Logger) *SpecHandler {
+	return &SpecHandler{
+		specService: s,
+		logger:      l,
+	}
+}
+
+// respondWithSpecError maps service errors of the spec endpoints to status codes.
+func (h *SpecHandler) respondWithSpecError(w http.ResponseWriter, err error) {
+	h.logger.Printf("ERROR: %v", err)
+	if errors.Is(err, service.ErrSpecNotFound) {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	respondWithError(w, http.StatusInternalServerError, "An internal error occurred")
+}
+
+func (h *SpecHandler) List(w http.ResponseWriter, r *http.Request) {
+	specs, err := h.specService.ListSpecs(r.Context(), *GetUserIDFromContext(r.Context()))
+	if err != nil {
+		h.respondWithSpecError(w, err)
+		return
+	}
+	respondWithJson(w, http.StatusOK, specs)
+}
+
+// Get returns a spec with its OpenAPI document.
+func (h *SpecHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, ok := urlParamID(w, r, "specID")
+	if !ok {
+		return
+	}
+
+	spec, err := h.specService.GetSpec(r.Context(), id, *GetUserIDFromContext(r.Context()))
+	if err != nil {
+		h.respondWithSpecError(w, err)
+		return
+	}
+	respondWithJson(w, http.StatusOK, spec)
+}
+
+func (h *SpecHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, ok := urlParamID(w, r, "specID")
+	if !ok {
+		return
+	}
+
+	if err := h.specService.DeleteSpec(r.Context(), id, *GetUserIDFromContext(r.Context())); err != nil {
+		h.respondWithSpecError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
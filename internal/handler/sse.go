@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// sseWriter writes Server-Sent Events to the client. Headers are only sent
+// with the first event, so a handler can still answer with a normal JSON
+// error if it fails before streaming starts.
+type sseWriter struct {
+	w          http.ResponseWriter
+	controller *http.ResponseController
+	started    bool
+}
+
+func newSSEWriter(w http.ResponseWriter) *sseWriter {
+	return &sseWriter{
+		w:          w,
+		controller: http.NewResponseController(w),
+	}
+}
+
+// Send writes one event with its payload encoded as JSON and flushes it.
+func (s *sseWriter) Send(event string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	if !s.started {
+		// Streams outlive the server's WriteTimeout.
+		s.controller.SetWriteDeadline(time.Time{})
+		s.w.Header().Set("Content-Type", "text/event-stream")
+		s.w.Header().Set("Cache-Control", "no-cache")
+		s.w.Header().Set("Connection", "keep-alive")
+		s.w.Header().Set("X-Accel-Buffering", "no")
+		s.w.WriteHeader(http.StatusOK)
+		s.started = true
+	}
+
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+		return err
+	}
+	return s.controller.Flush()
+}
+
+// Started reports whether any event has been written.
+func (s *sseWriter) Started() bool {
+	return s.started
+}
@@ -27,10 +27,14 @@ func ValidationError(err error) string {
 			errorMsgs = append(errorMsgs, fmt.Sprintf("Field '%s' must be a valid URL", e.Field()))
 		case "httpmethod":
 			errorMsgs = append(errorMsgs, fmt.Sprintf("Field '%s' must be a valid HTTP method", e.Field()))
+		case "oneof":
+			errorMsgs = append(errorMsgs, fmt.Sprintf("Field '%s' must be one of: %s", e.Field(), e.Param()))
 		case "gte":
 			errorMsgs = append(errorMsgs, fmt.Sprintf("Field '%s' must be greater than or equal to %s", e.Field(), e.Param()))
 		case "lte":
 			errorMsgs = append(errorMsgs, fmt.Sprintf("Field '%s' must be less than or equal to %s", e.Field(), e.Param()))
+		case "max":
+			errorMsgs = append(errorMsgs, fmt.Sprintf("Field '%s' must be at most %s long", e.Field(), e.Param()))
 		default:
 			errorMsgs = append(errorMsgs, fmt.Sprintf("Field '%s' failed on the '%s' tag", e.Field(), e.Tag()))
 		}
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gorilla/websocket"
+	"github.com/suar-net/suar-be/internal/model"
+	"github.com/suar-net/suar-be/internal/service"
+)
+
+type WebSocketHandler struct {
+	webSocketService service.IWebSocketService
+	upgrader         websocket.Upgrader
+	logger           *log.Logger
+}
+
+func NewWebSocketHandler(s service.IWebSocketService, l *log.Logger) *WebSocketHandler {
+	return &WebSocketHandler{
+		webSocketService: s,
+		upgrader: websocket.Upgrader{
+			CheckOrigin: func(r *http.Request) bool {
+				origin := r.Header.Get("Origin")
+				return origin == "" || slices.Contains(allowedOrigins, origin)
+			},
+		},
+		logger: l,
+	}
+}
+
+// Connect opens a WebSocket session to the target given in the query string
+// and relays messages between it and the client. The target is dialed before
+// the client connection is upgraded, so connection errors are still returned
+// as JSON.
+//
+// Query parameters: url (required), protocol (repeatable) and header in the
+// form "Name: value" (repeatable).
+func (h *WebSocketHandler) Connect(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	dto := model.DTOWebSocketRequest{
+		URL:          query.Get("url"),
+		Subprotocols: query["protocol"],
+		Headers:      make(map[string][]string),
+	}
+	for _, header := range query["header"] {
+		name, value, ok := strings.Cut(header, ":")
+		if !ok {
+			respondWithError(w, http.StatusBadRequest, "Header must be in the form 'Name: value'")
+			return
+		}
+		name = strings.TrimSpace(name)
+		dto.Headers[name] = append(dto.Headers[name], strings.TrimSpace(value))
+	}
+
+	if err := validate.Struct(&dto); err != nil {
+		respondWithError(w, http.StatusBadRequest, ValidationError(err))
+		return
+	}
+
+	session, err := h.webSocketService.Dial(r.Context(), &dto)
+	if err != nil {
+		h.logger.Printf("ERROR: %v", err)
+		if errors.Is(err, service.ErrInvalidInput) {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		} else if errors.Is(err, service.ErrUpstreamFailed) {
+			respondWithError(w, http.StatusBadGateway, err.Error())
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "An internal error occurred")
+		return
+	}
+
+	responseHeader := http.Header{}
+	if session.Subprotocol != "" {
+		responseHeader.Set("Sec-WebSocket-Protocol", session.Subprotocol)
+	}
+
+	client, err := h.upgrader.Upgrade(w, r, responseHeader)
+	if err != nil {
+		// Upgrade has already written an error response to the client.
+		session.Target.Close()
+		return
+	}
+
+	if err := h.webSocketService.Relay(r.Context(), GetUserIDFromContext(r.Context()), session, client); err != nil {
+		h.logger.Printf("Error saving websocket session: %v", err)
+	}
+}
@@ -14,9 +14,17 @@ type User struct {
 	UpdatedAt    time.Time `json:"updated_at"`
 }
 
+// Request types stored in request_history.
+const (
+	RequestTypeHTTP      = "http"
+	RequestTypeWebSocket = "websocket"
+	RequestTypeGRPC      = "grpc"
+)
+
 type Request struct {
 	ID                 int             `json:"id"`
 	UserID             *int            `json:"user_id"`
+	RequestType        string          `json:"request_type"`
 	ExecutedAt         time.Time       `json:"executed_at"`
 	RequestMethod      string          `json:"request_method"`
 	RequestURL         string          `json:"request_url"`
@@ -27,4 +35,592 @@ type Request struct {
 	ResponseBody       *string         `json:"response_body"`
 	ResponseSize       *int64          `json:"response_size"`
 	DurationMs         *int            `json:"duration_ms"`
+	SessionTranscript  json.RawMessage `json:"session_transcript,omitempty"`
+	AssertionResults   json.RawMessage `json:"assertion_results,omitempty"`
+	Timings            json.RawMessage `json:"timings,omitempty"`
+}
+
+// RequestTimings splits the time of an HTTP request into the phases used by
+// HAR, in milliseconds. Connect includes TLS. Phases that did not happen, such
+// as DNS and connect on a reused connection, are -1.
+type RequestTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	TLS     float64 `json:"tls"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// WebSocketMessage is one message relayed during a WebSocket session.
+// Direction is "sent" for client to target and "received" for target to client.
+type WebSocketMessage struct {
+	Direction  string    `json:"direction"`
+	Type       string    `json:"type"`
+	Data       string    `json:"data,omitempty"`
+	DataBase64 string    `json:"data_base64,omitempty"`
+	Size       int       `json:"size"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// WebSocketTranscript is stored as the session_transcript of a websocket
+// history entry.
+type WebSocketTranscript struct {
+	Subprotocol     string             `json:"subprotocol,omitempty"`
+	Messages        []WebSocketMessage `json:"messages"`
+	MessagesDropped int                `json:"messages_dropped,omitempty"`
+	CloseCode       int                `json:"close_code,omitempty"`
+	CloseReason     string             `json:"close_reason,omitempty"`
+	ClosedBy        string             `json:"closed_by,omitempty"`
+	OpenedAt        time.Time          `json:"opened_at"`
+	ClosedAt        time.Time          `json:"closed_at"`
+}
+
+// GRPCTranscript is stored as the session_transcript of a grpc history entry,
+// the parts of a call that do not fit the HTTP shaped columns.
+type GRPCTranscript struct {
+	Status        string              `json:"status"`
+	StatusMessage string              `json:"status_message,omitempty"`
+	StatusDetails []json.RawMessage   `json:"status_details,omitempty"`
+	Trailers      map[string][]string `json:"trailers,omitempty"`
+}
+
+// Collection item types.
+const (
+	CollectionItemFolder  = "folder"
+	CollectionItemRequest = "request"
+)
+
+// Auth types of collections, folders and saved requests. An item without
+// auth inherits it from its parent, AuthNone explicitly sends none.
+const (
+	AuthNone   = "none"
+	AuthBasic  = "basic"
+	AuthBearer = "bearer"
+	AuthAPIKey = "apikey"
+)
+
+// Collection is a named tree of folders and saved requests owned by a user.
+// Items is only filled in when the whole tree is loaded.
+type Collection struct {
+	ID          int               `json:"id"`
+	UserID      int               `json:"user_id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Variables   []Variable        `json:"variables"`
+	Auth        *RequestAuth      `json:"auth,omitempty"`
+	Scripts     *Scripts          `json:"scripts,omitempty"`
+	Items       []*CollectionItem `json:"items,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// CollectionItem is a folder or a saved request. Items holds the children of
+// a folder, ordered by Position.
+type CollectionItem struct {
+	ID           int                `json:"id"`
+	CollectionID int                `json:"collection_id"`
+	ParentID     *int               `json:"parent_id,omitempty"`
+	Type         string             `json:"type"`
+	Name         string             `json:"name"`
+	Description  string             `json:"description,omitempty"`
+	Position     int                `json:"position"`
+	Request      *CollectionRequest `json:"request,omitempty"`
+	Auth         *RequestAuth       `json:"auth,omitempty"`
+	Scripts      *Scripts           `json:"scripts,omitempty"`
+	Contract     *Contract          `json:"contract,omitempty"`
+	Examples     []ResponseExample  `json:"examples,omitempty"`
+	Items        []*CollectionItem  `json:"items,omitempty"`
+}
+
+// CollectionRequest is the definition of a saved request. Values may contain
+// {{variable}} placeholders, they are kept as written.
+type CollectionRequest struct {
+	Method      string       `json:"method"`
+	URL         string       `json:"url"`
+	Headers     []KeyValue   `json:"headers,omitempty"`
+	Body        *RequestBody `json:"body,omitempty"`
+	Assertions  []Assertion  `json:"assertions,omitempty"`
+	Extractions []Extraction `json:"extractions,omitempty"`
+}
+
+// Body modes of a saved request.
+const (
+	BodyModeRaw        = "raw"
+	BodyModeURLEncoded = "urlencoded"
+	BodyModeFormData   = "formdata"
+	BodyModeGraphQL    = "graphql"
+)
+
+type RequestBody struct {
+	Mode        string          `json:"mode"`
+	Raw         string          `json:"raw,omitempty"`
+	ContentType string          `json:"content_type,omitempty"`
+	URLEncoded  []KeyValue      `json:"urlencoded,omitempty"`
+	FormData    []FormField     `json:"formdata,omitempty"`
+	GraphQL     *DTOGraphQLBody `json:"graphql,omitempty"`
+}
+
+// KeyValue is an ordered header, query or form entry. Disabled entries are
+// kept so they can be re-enabled but are never sent.
+type KeyValue struct {
+	Key      string `json:"key"`
+	Value    string `json:"value"`
+	Disabled bool   `json:"disabled,omitempty"`
+}
+
+// FormField is a multipart form entry. File fields only keep the file name,
+// file contents are not stored.
+type FormField struct {
+	Key         string `json:"key"`
+	Value       string `json:"value,omitempty"`
+	Type        string `json:"type"` // text or file
+	ContentType string `json:"content_type,omitempty"`
+	Disabled    bool   `json:"disabled,omitempty"`
+}
+
+// RequestAuth holds the credentials of one auth type, the fields that apply
+// depend on Type. For AuthAPIKey, In is "header" or "query".
+type RequestAuth struct {
+	Type     string `json:"type"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Token    string `json:"token,omitempty"`
+	Key      string `json:"key,omitempty"`
+	Value    string `json:"value,omitempty"`
+	In       string `json:"in,omitempty"`
+}
+
+// Scripts are JavaScript sources run before a request is sent and after its
+// response arrives.
+type Scripts struct {
+	PreRequest string `json:"pre_request,omitempty"`
+	Test       string `json:"test,omitempty"`
+}
+
+// Variable is a collection or environment variable. Secret marks values such
+// as tokens that clients should mask.
+type Variable struct {
+	Key      string `json:"key"`
+	Value    string `json:"value"`
+	Secret   bool   `json:"secret,omitempty"`
+	Disabled bool   `json:"disabled,omitempty"`
+}
+
+// Environment is a named set of variables owned by a user.
+type Environment struct {
+	ID        int        `json:"id"`
+	UserID    int        `json:"user_id"`
+	Name      string     `json:"name"`
+	Variables []Variable `json:"variables"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// Contract links a request to what its responses are validated against:
+// an operation of a stored OpenAPI document, or a JSON Schema for the body.
+// Operation is an operationId or "METHOD /path", when it is empty the
+// operation is matched by the method and URL of the request.
+type Contract struct {
+	SpecID    int             `json:"spec_id,omitempty"`
+	Operation string          `json:"operation,omitempty"`
+	Schema    json.RawMessage `json:"schema,omitempty"`
+}
+
+// APISpec is a stored OpenAPI document. Document is kept as JSON, also when
+// it was uploaded as YAML.
+type APISpec struct {
+	ID        int             `json:"id"`
+	UserID    int             `json:"user_id"`
+	Name      string          `json:"name"`
+	Version   string          `json:"version,omitempty"`
+	Document  json.RawMessage `json:"document,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Sources an assertion reads from a response.
+const (
+	AssertionSourceStatus       = "status"
+	AssertionSourceHeader       = "header"
+	AssertionSourceJSONPath     = "jsonpath"
+	AssertionSourceXPath        = "xpath"
+	AssertionSourceResponseTime = "response_time" // milliseconds
+	AssertionSourceBodySize     = "body_size"     // bytes
+)
+
+// Operators comparing the value read by an assertion.
+const (
+	AssertionEquals      = "equals"
+	AssertionNotEquals   = "not_equals"
+	AssertionInRange     = "in_range"
+	AssertionLessThan    = "less_than"
+	AssertionGreaterThan = "greater_than"
+	AssertionExists      = "exists"
+	AssertionNotExists   = "not_exists"
+	AssertionContains    = "contains"
+	AssertionMatches     = "matches"
+	AssertionType        = "type"
+)
+
+// Assertion is a declarative check on a response. Property is the header
+// name, JSONPath or XPath expression for those sources. Value is any JSON
+// value to compare with, in_range uses Min and Max instead, both inclusive.
+type Assertion struct {
+	Name     string          `json:"name,omitempty" validate:"max=255"`
+	Source   string          `json:"source" validate:"required,oneof=status header jsonpath xpath response_time body_size"`
+	Property string          `json:"property,omitempty"`
+	Operator string          `json:"operator" validate:"required,oneof=equals not_equals in_range less_than greater_than exists not_exists contains matches type"`
+	Value    json.RawMessage `json:"value,omitempty"`
+	Min      *float64        `json:"min,omitempty"`
+	Max      *float64        `json:"max,omitempty"`
+}
+
+// AssertionResult is the outcome of an assertion. Actual is the value that
+// was read from the response, if any.
+type AssertionResult struct {
+	Assertion
+	Passed  bool   `json:"passed"`
+	Actual  any    `json:"actual,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// Sources an extraction reads from a response.
+const (
+	ExtractionSourceJSONPath = "jsonpath"
+	ExtractionSourceRegex    = "regex"
+	ExtractionSourceHeader   = "header"
+	ExtractionSourceCookie   = "cookie"
+)
+
+// Extraction copies a value of a response into a variable of the active
+// environment, so later requests can use it as {{variable}}. Expression is
+// the JSONPath, the regular expression, the header name or the cookie name.
+// A regular expression yields its first group, or the whole match when it
+// has none.
+type Extraction struct {
+	Variable   string `json:"variable" validate:"required,max=255"`
+	Source     string `json:"source" validate:"required,oneof=jsonpath regex header cookie"`
+	Expression string `json:"expression" validate:"required,max=2048"`
+}
+
+// ResponseExample is a saved response of a request, served by mock servers.
+// An example with Match rules is only picked when the incoming request has
+// every query parameter and header they list; DelayMs, when set, replaces
+// the delay of the mock server.
+type ResponseExample struct {
+	Name    string        `json:"name" validate:"required,max=255"`
+	Status  int           `json:"status" validate:"gte=100,lte=599"`
+	Headers []KeyValue    `json:"headers,omitempty" validate:"max=100"`
+	Body    string        `json:"body,omitempty" validate:"max=1048576"`
+	DelayMs *int          `json:"delay_ms,omitempty" validate:"omitempty,gte=0,lte=30000"`
+	Match   *ExampleMatch `json:"match,omitempty"`
+}
+
+// ExampleMatch lists the query parameters and headers a request must have
+// for an example to be picked. An empty value only requires the key.
+type ExampleMatch struct {
+	Query   []KeyValue `json:"query,omitempty" validate:"max=50"`
+	Headers []KeyValue `json:"headers,omitempty" validate:"max=50"`
+}
+
+// MockServer serves the examples of a collection at /mock/{id}/..., without
+// authentication. The ID is random as the URL is public. DelayMs delays
+// every response whose example sets no delay of its own.
+type MockServer struct {
+	ID           string    `json:"id"`
+	UserID       int       `json:"user_id"`
+	CollectionID int       `json:"collection_id"`
+	Name         string    `json:"name"`
+	DelayMs      int       `json:"delay_ms"`
+	Enabled      bool      `json:"enabled"`
+	Path         string    `json:"path"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// MockHit is a request a mock server received. ItemID and Example tell which
+// example answered it, they are empty when none matched.
+type MockHit struct {
+	ID         int64      `json:"id"`
+	MockID     string     `json:"mock_id"`
+	Method     string     `json:"method"`
+	Path       string     `json:"path"`
+	Query      string     `json:"query,omitempty"`
+	Headers    []KeyValue `json:"headers"`
+	Body       string     `json:"body,omitempty"`
+	ItemID     *int       `json:"item_id,omitempty"`
+	Example    string     `json:"example,omitempty"`
+	Status     int        `json:"status"`
+	DurationMs int64      `json:"duration_ms"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// LoadTest is a load test in progress. It is stored so the limits on load
+// tests apply across replicas; HeartbeatAt tells the replica running it is
+// still alive.
+type LoadTest struct {
+	ID          int64     `json:"id"`
+	UserID      int       `json:"user_id"`
+	Host        string    `json:"host"`
+	StartedAt   time.Time `json:"started_at"`
+	HeartbeatAt time.Time `json:"heartbeat_at"`
+}
+
+// RequestBin records every request sent to /bin/{id}/..., without
+// authentication, and answers them with Response. The ID is random as the
+// URL is public.
+type RequestBin struct {
+	ID        string      `json:"id"`
+	UserID    int         `json:"user_id"`
+	Name      string      `json:"name"`
+	Response  BinResponse `json:"response"`
+	Path      string      `json:"path"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+// BinResponse is the canned response a request bin answers with.
+type BinResponse struct {
+	Status  int        `json:"status" validate:"gte=200,lte=599"`
+	Headers []KeyValue `json:"headers,omitempty" validate:"max=50"`
+	Body    string     `json:"body,omitempty" validate:"max=65536"`
+}
+
+// Encodings of a captured request body.
+const (
+	BodyEncodingText   = "text"
+	BodyEncodingBase64 = "base64"
+)
+
+// CapturedRequest is a request a request bin recorded. Body is base64 when
+// BodyEncoding says so; Size is the full size of the body, which was cut
+// when Truncated is set.
+type CapturedRequest struct {
+	ID           int64      `json:"id"`
+	BinID        string     `json:"bin_id"`
+	Method       string     `json:"method"`
+	Path         string     `json:"path"`
+	Query        string     `json:"query,omitempty"`
+	Headers      []KeyValue `json:"headers"`
+	Body         string     `json:"body,omitempty"`
+	BodyEncoding string     `json:"body_encoding,omitempty"`
+	Size         int64      `json:"size"`
+	Truncated    bool       `json:"truncated,omitempty"`
+	SourceIP     string     `json:"source_ip"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// Statuses of a collection run. A finished run passed when every request was
+// sent and all of its assertions, tests and contract checks passed.
+const (
+	RunStatusRunning  = "running"
+	RunStatusPassed   = "passed"
+	RunStatusFailed   = "failed"
+	RunStatusCanceled = "canceled"
+)
+
+// CollectionRun is a run of the saved requests of a collection or of one of
+// its folders, once per row of its data. Results and Iterations are only
+// filled in when a single run is loaded.
+type CollectionRun struct {
+	ID            int             `json:"id"`
+	UserID        int             `json:"user_id"`
+	CollectionID  *int            `json:"collection_id"`
+	FolderID      *int            `json:"folder_id,omitempty"`
+	EnvironmentID *int            `json:"environment_id,omitempty"`
+	Name          string          `json:"name"`
+	Status        string          `json:"status"`
+	Concurrency   int             `json:"concurrency"`
+	Totals        RunTotals       `json:"totals"`
+	Results       []RunItemResult `json:"results,omitempty"`
+	Iterations    []RunIteration  `json:"iterations,omitempty"`
+	Error         string          `json:"error,omitempty"`
+	StartedAt     time.Time       `json:"started_at"`
+	FinishedAt    *time.Time      `json:"finished_at,omitempty"`
+}
+
+// RunTotals summarizes a run. Requests is the number of requests the run
+// plans to send over all iterations, Completed how many of them have
+// finished.
+type RunTotals struct {
+	Iterations       int   `json:"iterations"`
+	Requests         int   `json:"requests"`
+	Completed        int   `json:"completed"`
+	Passed           int   `json:"passed"`
+	Failed           int   `json:"failed"`
+	Assertions       int   `json:"assertions"`
+	AssertionsFailed int   `json:"assertions_failed"`
+	Tests            int   `json:"tests"`
+	TestsFailed      int   `json:"tests_failed"`
+	DurationMs       int64 `json:"duration_ms"` // sum of the response times
+}
+
+// RunItemResult is the outcome of one request of a run. Response bodies are
+// not kept, the history entry of the request has them.
+type RunItemResult struct {
+	Iteration  int                `json:"iteration"`
+	ItemID     int                `json:"item_id"`
+	Name       string             `json:"name"`
+	Path       string             `json:"path"`
+	Method     string             `json:"method,omitempty"`
+	URL        string             `json:"url,omitempty"`
+	StatusCode int                `json:"status_code,omitempty"`
+	DurationMs int64              `json:"duration_ms"`
+	Size       int64              `json:"size"`
+	Passed     bool               `json:"passed"`
+	Assertions []AssertionResult  `json:"assertions,omitempty"`
+	Tests      []DTOScriptTest    `json:"tests,omitempty"`
+	Contract   *DTOContractResult `json:"contract,omitempty"`
+	Warnings   []string           `json:"warnings,omitempty"`
+	Error      string             `json:"error,omitempty"`
+	HistoryID  *int               `json:"history_id,omitempty"`
+	StartedAt  time.Time          `json:"started_at"`
+}
+
+// RunIteration summarizes one pass over the requests of a run, with the row
+// of data its variables were bound to.
+type RunIteration struct {
+	Iteration int               `json:"iteration"`
+	Data      map[string]string `json:"data,omitempty"`
+	Completed int               `json:"completed"`
+	Passed    int               `json:"passed"`
+	Failed    int               `json:"failed"`
+}
+
+// Statuses of a monitor check. A check is down when a request failed or one
+// of its checks did not pass, error when it could not be run at all.
+const (
+	MonitorStatusUp    = "up"
+	MonitorStatusDown  = "down"
+	MonitorStatusError = "error"
+)
+
+// Monitor runs a collection, one of its folders or a single saved request
+// on a cron schedule. ItemID selects the folder or request, nil runs the
+// whole collection. A check fails when a request fails, gets a status
+// outside 2xx it has no status assertion for, or the check takes longer than
+// LatencyThresholdMs; after AlertAfter failed checks in a row the monitor's
+// alerts are notified, and again once it recovers.
+type Monitor struct {
+	ID            int        `json:"id"`
+	UserID        int        `json:"user_id"`
+	CollectionID  int        `json:"collection_id"`
+	ItemID        *int       `json:"item_id,omitempty"`
+	EnvironmentID *int       `json:"environment_id,omitempty"`
+	Name          string     `json:"name"`
+	Schedule      string     `json:"schedule"`
+	Timezone      string     `json:"timezone"`
+	Timeout       int        `json:"timeout"`
+	Enabled       bool       `json:"enabled"`
+	NextRunAt     time.Time  `json:"next_run_at"`
+	LastRunAt     *time.Time `json:"last_run_at,omitempty"`
+	LastStatus    string     `json:"last_status,omitempty"`
+
+	LatencyThresholdMs  int        `json:"latency_threshold_ms"`
+	AlertAfter          int        `json:"alert_after"`
+	AlertState          string     `json:"alert_state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	FailingSince        *time.Time `json:"failing_since,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// MonitorResult is the outcome of one check of a monitor. LatencyMs is the
+// sum of the response times of its requests.
+type MonitorResult struct {
+	ID               int64           `json:"id"`
+	MonitorID        int             `json:"monitor_id"`
+	Status           string          `json:"status"`
+	Requests         int             `json:"requests"`
+	Failed           int             `json:"failed"`
+	Assertions       int             `json:"assertions"`
+	AssertionsFailed int             `json:"assertions_failed"`
+	LatencyMs        int64           `json:"latency_ms"`
+	Results          []RunItemResult `json:"results"`
+	Failures         []string        `json:"failures,omitempty"`
+	Error            string          `json:"error,omitempty"`
+	StartedAt        time.Time       `json:"started_at"`
+	FinishedAt       time.Time       `json:"finished_at"`
+}
+
+// Alert states of a monitor.
+const (
+	AlertStateOK      = "ok"
+	AlertStateFailing = "failing"
+)
+
+// Kinds of alert webhooks, named after the payload they are sent.
+const (
+	AlertKindGeneric = "generic"
+	AlertKindSlack   = "slack"
+	AlertKindTeams   = "teams"
+)
+
+// Events an alert is notified of.
+const (
+	AlertEventFailing   = "monitor.failing"
+	AlertEventRecovered = "monitor.recovered"
+	AlertEventTest      = "monitor.test"
+)
+
+// Statuses of an alert delivery.
+const (
+	DeliveryStatusPending = "pending"
+	DeliveryStatusSent    = "sent"
+	DeliveryStatusFailed  = "failed"
+)
+
+// MonitorAlert is a webhook notified when a monitor starts failing and when
+// it recovers. Generic webhooks are signed with Secret when one is set.
+type MonitorAlert struct {
+	ID        int       `json:"id"`
+	MonitorID int       `json:"monitor_id"`
+	Name      string    `json:"name"`
+	Kind      string    `json:"kind"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"-"`
+	HasSecret bool      `json:"has_secret"`
+	Enabled   bool      `json:"enabled"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// AlertEvent is what a notification reports. It is sent as is to generic
+// webhooks, Slack and Teams get a message written from it.
+type AlertEvent struct {
+	Event        string            `json:"event"`
+	Monitor      AlertEventMonitor `json:"monitor"`
+	Status       string            `json:"status,omitempty"`
+	Failures     []string          `json:"failures,omitempty"`
+	FailingSince *time.Time        `json:"failing_since,omitempty"`
+	ResultID     int64             `json:"result_id,omitempty"`
+	CheckedAt    time.Time         `json:"checked_at"`
+	Requests     int               `json:"requests"`
+	Failed       int               `json:"failed"`
+	LatencyMs    int64             `json:"latency_ms"`
+}
+
+type AlertEventMonitor struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Schedule string `json:"schedule"`
+}
+
+// AlertDelivery is a notification queued for an alert. Alert is only filled
+// in for deliveries claimed to be sent.
+type AlertDelivery struct {
+	ID            int64         `json:"id"`
+	AlertID       int           `json:"alert_id"`
+	MonitorID     int           `json:"monitor_id"`
+	Event         string        `json:"event"`
+	Payload       AlertEvent    `json:"payload"`
+	Status        string        `json:"status"`
+	Attempts      int           `json:"attempts"`
+	LastError     string        `json:"last_error,omitempty"`
+	NextAttemptAt time.Time     `json:"next_attempt_at"`
+	CreatedAt     time.Time     `json:"created_at"`
+	SentAt        *time.Time    `json:"sent_at,omitempty"`
+	Alert         *MonitorAlert `json:"-"`
 }
@@ -7,26 +7,289 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
-// Change incoming request body from JSON to http request format
+// Response modes decide what happens to bodies larger than the inline limit.
+// Inline truncates them, spool stores them on disk for download.
+const (
+	ResponseModeInline = "inline"
+	ResponseModeSpool  = "spool"
+)
+
+// HTTP versions a request can be forced to use. Auto negotiates HTTP/2 over
+// TLS with ALPN and falls back to HTTP/1.1, h2c is HTTP/2 over plain TCP
+// with prior knowledge.
+const (
+	HTTPVersionAuto = "auto"
+	HTTPVersion1    = "http1.1"
+	HTTPVersion2    = "http2"
+	HTTPVersionH2C  = "h2c"
+)
+
+// Change incoming request body from JSON to http request format.
+// At most one of Body, BodyText and BodyBase64 may be set.
 type DTORequest struct {
-	Method  string              `json:"method" validate:"required"`
-	URL     string              `json:"url" validate:"required,url"`
-	Headers map[string][]string `json:"headers"`
-	Body    json.RawMessage     `json:"body,omitempty"`
-	Timeout int                 `json:"timeout" validate:"gte=0,lte=90000"` // 0 means default, max 90s
+	Method       string              `json:"method" validate:"required"`
+	URL          string              `json:"url" validate:"required,url"`
+	Headers      map[string][]string `json:"headers"`
+	Body         json.RawMessage     `json:"body,omitempty"`
+	BodyText     string              `json:"body_text,omitempty"`                // sent as is, for bodies that are not JSON
+	BodyBase64   string              `json:"body_base64,omitempty"`              // for binary bodies
+	Timeout      int                 `json:"timeout" validate:"gte=0,lte=90000"` // 0 means default, max 90s
+	ResponseMode string              `json:"response_mode,omitempty" validate:"omitempty,oneof=inline spool"`
+	GraphQL      *DTOGraphQLBody     `json:"graphql,omitempty"`
+	HTTPVersion  string              `json:"http_version,omitempty" validate:"omitempty,oneof=auto http1.1 http2 h2c"`
+	CaptureRaw   bool                `json:"capture_raw,omitempty"` // forces HTTP/1.1, ignored when streaming
+	Contract     *Contract           `json:"contract,omitempty"`    // validates the response, ignored when streaming
+	Assertions   []Assertion         `json:"assertions,omitempty" validate:"omitempty,max=100,dive"`
+	SkipHistory  bool                `json:"-"` // set by scheduled checks, which would flood the history
+}
+
+// DTOGraphQLBody is the graphql body mode of a request. The JSON envelope is
+// built by the service, Environment selects which cached schema is used to
+// validate the query before it is sent.
+type DTOGraphQLBody struct {
+	Query         string          `json:"query"`
+	Variables     json.RawMessage `json:"variables,omitempty"`
+	OperationName string          `json:"operationName,omitempty"`
+	Environment   string          `json:"environment,omitempty"`
+}
+
+type DTOGraphQLIntrospectionRequest struct {
+	URL         string              `json:"url" validate:"required,url"`
+	Headers     map[string][]string `json:"headers"`
+	Environment string              `json:"environment"`
+	Refresh     bool                `json:"refresh"`
+}
+
+// DTOGraphQLSchema is an introspected schema flattened for autocomplete.
+// Type references are written in GraphQL notation, e.g. "[User!]!".
+type DTOGraphQLSchema struct {
+	URL              string                `json:"url"`
+	Environment      string                `json:"environment,omitempty"`
+	QueryType        string                `json:"query_type,omitempty"`
+	MutationType     string                `json:"mutation_type,omitempty"`
+	SubscriptionType string                `json:"subscription_type,omitempty"`
+	Types            []DTOGraphQLType      `json:"types"`
+	Directives       []DTOGraphQLDirective `json:"directives"`
+	FetchedAt        time.Time             `json:"fetched_at"`
+	ExpiresAt        time.Time             `json:"expires_at"`
+}
+
+type DTOGraphQLType struct {
+	Kind          string                 `json:"kind"`
+	Name          string                 `json:"name"`
+	Description   string                 `json:"description,omitempty"`
+	Fields        []DTOGraphQLField      `json:"fields,omitempty"`
+	InputFields   []DTOGraphQLInputValue `json:"input_fields,omitempty"`
+	Interfaces    []string               `json:"interfaces,omitempty"`
+	PossibleTypes []string               `json:"possible_types,omitempty"`
+	EnumValues    []string               `json:"enum_values,omitempty"`
+}
+
+type DTOGraphQLField struct {
+	Name              string                 `json:"name"`
+	Description       string                 `json:"description,omitempty"`
+	Type              string                 `json:"type"`
+	Args              []DTOGraphQLInputValue `json:"args,omitempty"`
+	IsDeprecated      bool                   `json:"is_deprecated,omitempty"`
+	DeprecationReason string                 `json:"deprecation_reason,omitempty"`
+}
+
+type DTOGraphQLInputValue struct {
+	Name         string  `json:"name"`
+	Description  string  `json:"description,omitempty"`
+	Type         string  `json:"type"`
+	DefaultValue *string `json:"default_value,omitempty"`
+}
+
+type DTOGraphQLDirective struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Locations   []string               `json:"locations"`
+	Args        []DTOGraphQLInputValue `json:"args,omitempty"`
 }
 
-// Change incoming http response from complex object to simplified version
+// ContentKind tells clients how a response body should be rendered.
+type ContentKind string
+
+const (
+	ContentKindJSON   ContentKind = "json"
+	ContentKindXML    ContentKind = "xml"
+	ContentKindHTML   ContentKind = "html"
+	ContentKindText   ContentKind = "text"
+	ContentKindImage  ContentKind = "image"
+	ContentKindPDF    ContentKind = "pdf"
+	ContentKindBinary ContentKind = "binary"
+)
+
+// Change incoming http response from complex object to simplified version.
+// Size is the body size after content decoding, WireSize is the number of
+// bytes actually received from the target server. Clients read BodyText for
+// textual content and BodyBase64 for binary. Body, base64 encoded like
+// BodyBase64 whatever the content, is deprecated and only kept until
+// clients have moved to the new fields.
+// A spooled response only carries a preview of the body, the full body is
+// available at DownloadURL until DownloadExpiresAt.
 type DTOResponse struct {
-	StatusCode int                 `json:"status_code"`
-	Duration   time.Duration       `json:"duration"`
+	StatusCode      int                 `json:"status_code"`
+	Proto           string              `json:"protocol,omitempty"`
+	ALPN            string              `json:"alpn_protocol,omitempty"`
+	Duration        time.Duration       `json:"duration"`
+	Timings         *RequestTimings     `json:"timings,omitempty"`
+	Timestamp       time.Time           `json:"timestamp"`
+	Size            int64               `json:"size"`
+	WireSize        int64               `json:"wire_size"`
+	ContentEncoding string              `json:"content_encoding,omitempty"`
+	Charset         string              `json:"charset,omitempty"`
+	Headers         map[string][]string `json:"headers"`
+	Body            []byte              `json:"body,omitempty"` // Deprecated: use BodyText or BodyBase64.
+	MimeType        string              `json:"mime_type,omitempty"`
+	ContentKind     ContentKind         `json:"content_kind,omitempty"`
+	BodyText        string              `json:"body_text,omitempty"`
+	BodyPretty      string              `json:"body_pretty,omitempty"`
+	BodyBase64      string              `json:"body_base64,omitempty"`
+	Truncated       bool                `json:"truncated,omitempty"`
+	DownloadToken   string              `json:"download_token,omitempty"`
+	DownloadURL     string              `json:"download_url,omitempty"`
+	DownloadExpires *time.Time          `json:"download_expires_at,omitempty"`
+	Raw             *DTORawExchange     `json:"raw,omitempty"`
+	Contract        *DTOContractResult  `json:"contract,omitempty"`
+	Assertions      []AssertionResult   `json:"assertions,omitempty"`
+	HistoryID       *int                `json:"history_id,omitempty"`
+	Error           string              `json:"error,omitempty"`
+}
+
+// DTOContractResult lists where a response breaks its contract. Pointers are
+// JSON pointers into the response seen as {"status", "headers", "body"},
+// e.g. "/status", "/headers/X-Rate-Limit" or "/body/items/0/id".
+type DTOContractResult struct {
+	Operation  string                 `json:"operation,omitempty"`
+	Valid      bool                   `json:"valid"`
+	Violations []DTOContractViolation `json:"violations"`
+	Warnings   []string               `json:"warnings,omitempty"`
+}
+
+type DTOContractViolation struct {
+	Pointer string `json:"pointer"`
+	Message string `json:"message"`
+}
+
+// DTORawExchange holds the exact bytes sent and received on the connection,
+// including the request and status lines and headers in their original order
+// and casing. Each side is returned as text, or as base64 when it is not
+// valid UTF-8.
+type DTORawExchange struct {
+	Request           string `json:"request,omitempty"`
+	RequestBase64     string `json:"request_base64,omitempty"`
+	RequestSize       int64  `json:"request_size"`
+	RequestTruncated  bool   `json:"request_truncated,omitempty"`
+	Response          string `json:"response,omitempty"`
+	ResponseBase64    string `json:"response_base64,omitempty"`
+	ResponseSize      int64  `json:"response_size"`
+	ResponseTruncated bool   `json:"response_truncated,omitempty"`
+}
+
+// Stream event types sent by the streaming request endpoint.
+const (
+	StreamEventStream   = "stream"
+	StreamEventResponse = "response"
+	StreamEventMessage  = "message"
+	StreamEventChunk    = "chunk"
+	StreamEventDone     = "done"
+)
+
+// DTOStreamEvent is one event relayed to the client while an upstream
+// response is streamed. Elapsed is measured from the start of the request.
+type DTOStreamEvent struct {
+	Type       string              `json:"type"`
 	Timestamp  time.Time           `json:"timestamp"`
-	Size       int64               `json:"size"`
-	Headers    map[string][]string `json:"headers"`
-	Body       []byte              `json:"body,omitempty"`
+	Elapsed    time.Duration       `json:"elapsed"`
+	StreamID   string              `json:"stream_id,omitempty"`
+	StatusCode int                 `json:"status_code,omitempty"`
+	Proto      string              `json:"protocol,omitempty"`
+	Headers    map[string][]string `json:"headers,omitempty"`
+	Event      string              `json:"event,omitempty"`
+	ID         string              `json:"id,omitempty"`
+	Data       string              `json:"data,omitempty"`
+	DataBase64 string              `json:"data_base64,omitempty"`
+	Size       int64               `json:"size,omitempty"`
+	Reason     string              `json:"reason,omitempty"`
 	Error      string              `json:"error,omitempty"`
 }
 
+// DTOWebSocketRequest describes the target of a WebSocket session. It is read
+// from query parameters because browsers cannot send a body or custom headers
+// when opening a WebSocket.
+type DTOWebSocketRequest struct {
+	URL          string `validate:"required,url"`
+	Subprotocols []string
+	Headers      map[string][]string
+}
+
+// DTOGRPCDescriptorSource tells the service where message definitions come
+// from. DescriptorSet is a base64 encoded FileDescriptorSet, ProtoFiles maps
+// file names to .proto sources. When both are empty server reflection is used.
+type DTOGRPCDescriptorSource struct {
+	DescriptorSet string            `json:"descriptor_set,omitempty"`
+	ProtoFiles    map[string]string `json:"proto_files,omitempty"`
+}
+
+type DTOGRPCListRequest struct {
+	Target    string              `json:"target" validate:"required"`
+	Plaintext bool                `json:"plaintext"`
+	Metadata  map[string][]string `json:"metadata"`
+	Timeout   int                 `json:"timeout" validate:"gte=0,lte=90000"`
+	DTOGRPCDescriptorSource
+}
+
+type DTOGRPCServiceList struct {
+	Services []DTOGRPCService `json:"services"`
+}
+
+type DTOGRPCService struct {
+	Name    string          `json:"name"`
+	Methods []DTOGRPCMethod `json:"methods"`
+}
+
+// InputTemplate is the input message with every field set to its default,
+// ready to be filled in by the client.
+type DTOGRPCMethod struct {
+	Name            string          `json:"name"`
+	FullName        string          `json:"full_name"`
+	InputType       string          `json:"input_type"`
+	OutputType      string          `json:"output_type"`
+	ClientStreaming bool            `json:"client_streaming"`
+	ServerStreaming bool            `json:"server_streaming"`
+	InputTemplate   json.RawMessage `json:"input_template,omitempty"`
+}
+
+// DTOGRPCRequest invokes a gRPC method with messages written as protobuf
+// JSON. Unary and server streaming methods take exactly one message.
+type DTOGRPCRequest struct {
+	Target    string              `json:"target" validate:"required"`
+	Plaintext bool                `json:"plaintext"`
+	Service   string              `json:"service" validate:"required"`
+	Method    string              `json:"method" validate:"required"`
+	Metadata  map[string][]string `json:"metadata"`
+	Messages  []json.RawMessage   `json:"messages"`
+	Timeout   int                 `json:"timeout" validate:"gte=0,lte=90000"`
+	DTOGRPCDescriptorSource
+}
+
+// StatusCode is the numeric gRPC status code, Status its canonical name.
+type DTOGRPCResponse struct {
+	StatusCode      int                 `json:"status_code"`
+	Status          string              `json:"status"`
+	StatusMessage   string              `json:"status_message,omitempty"`
+	StatusDetails   []json.RawMessage   `json:"status_details,omitempty"`
+	Headers         map[string][]string `json:"headers"`
+	Trailers        map[string][]string `json:"trailers"`
+	Messages        []json.RawMessage   `json:"messages"`
+	MessagesDropped int                 `json:"messages_dropped,omitempty"`
+	Duration        time.Duration       `json:"duration"`
+	Timestamp       time.Time           `json:"timestamp"`
+	Error           string              `json:"error,omitempty"`
+}
+
 type DTOUserRegisterRequest struct {
 	Username string `json:"username" validate:"required"`
 	Email    string `json:"email" validate:"required,email"`
@@ -43,9 +306,415 @@ type DTOLoginResponse struct {
 	TokenType   string `json:"token_type"`
 }
 
+// DTOTicketResponse is a single-use ticket for endpoints a browser opens
+// without an Authorization header, passed as the ticket query parameter.
+type DTOTicketResponse struct {
+	Ticket    string    `json:"ticket"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
 type Claims struct {
 	ID       int    `json:"id"`
 	Username string `json:"username"`
 	Email    string `json:"email"`
 	jwt.RegisteredClaims
 }
+
+// DTOCurlImportRequest carries a cURL command line to convert into a request.
+// CollectionID, when set, also saves the request to that collection of the
+// signed-in user under Name, which defaults to the method and URL.
+type DTOCurlImportRequest struct {
+	Command      string `json:"command" validate:"required,max=1048576"`
+	CollectionID *int   `json:"collection_id,omitempty"`
+	Name         string `json:"name,omitempty" validate:"max=255"`
+}
+
+// DTOCurlImportResponse is the request described by an imported cURL command.
+// Warnings list the options that could not be represented and were dropped.
+// Saved is the collection item created when a collection_id was given.
+type DTOCurlImportResponse struct {
+	Request  DTORequest           `json:"request"`
+	Saved    *DTOImportedResource `json:"saved,omitempty"`
+	Warnings []string             `json:"warnings,omitempty"`
+}
+
+// Snippet languages supported by the code generator.
+const (
+	SnippetCurl       = "curl"
+	SnippetGo         = "go"
+	SnippetPython     = "python"
+	SnippetFetch      = "javascript"
+	SnippetAxios      = "node"
+	SnippetHTTPie     = "httpie"
+	SnippetPowerShell = "powershell"
+)
+
+// DTOSnippetRequest asks for client code equivalent to a request, given
+// inline, as a history entry or as a saved request of a collection of the
+// current user. A saved request has its variables resolved from the
+// environment and the collection, except those marked secret, which are
+// left as {{name}}; its scripts are not run. With RedactSecrets, values of
+// credential headers and query parameters of an inline request or a history
+// entry are replaced by {{placeholder}} names instead of being copied into
+// the code.
+type DTOSnippetRequest struct {
+	Request       *DTORequest `json:"request,omitempty"`
+	HistoryID     *int        `json:"history_id,omitempty"`
+	CollectionID  *int        `json:"collection_id,omitempty" validate:"required_with=ItemID"`
+	ItemID        *int        `json:"item_id,omitempty" validate:"required_with=CollectionID"`
+	EnvironmentID *int        `json:"environment_id,omitempty"`
+	Languages     []string    `json:"languages,omitempty" validate:"dive,oneof=curl go python javascript node httpie powershell"`
+	RedactSecrets bool        `json:"redact_secrets,omitempty"`
+}
+
+type DTOSnippet struct {
+	Language string `json:"language"`
+	Code     string `json:"code"`
+}
+
+type DTOSnippetResponse struct {
+	Snippets []DTOSnippet `json:"snippets"`
+}
+
+// DTOHARExportRequest selects the history entries to export as a HAR file.
+type DTOHARExportRequest struct {
+	IDs []int `json:"ids" validate:"required,min=1,max=1000"`
+}
+
+// DTOHARImportResponse reports the outcome of a HAR import. Skipped entries
+// use a scheme or format that cannot be stored, Warnings explain why.
+type DTOHARImportResponse struct {
+	Imported int      `json:"imported"`
+	Skipped  int      `json:"skipped"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// DTOImportedResource identifies a collection or environment created by an import.
+type DTOImportedResource struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// DTOCollectionImportResponse lists what an import of a Postman or Insomnia
+// export created. Warnings list the parts that could not be mapped.
+type DTOCollectionImportResponse struct {
+	Collections  []DTOImportedResource `json:"collections"`
+	Environments []DTOImportedResource `json:"environments"`
+	Specs        []DTOImportedResource `json:"specs,omitempty"`
+	Warnings     []string              `json:"warnings,omitempty"`
+}
+
+// DTOItemRunRequest runs a saved request with the variables of an
+// environment. Variables its scripts set are saved back to the environment
+// and the collection.
+type DTOItemRunRequest struct {
+	EnvironmentID *int `json:"environment_id,omitempty"`
+	Timeout       int  `json:"timeout" validate:"gte=0,lte=90000"` // per request, 0 means default
+}
+
+// DTOItemRunResult is the outcome of running a saved request. Request is what
+// was sent after scripts and variables were applied. Error is set when the
+// request could not be sent, Response is nil then.
+type DTOItemRunResult struct {
+	ItemID     int              `json:"item_id"`
+	Name       string           `json:"name"`
+	Request    *DTORequest      `json:"request,omitempty"`
+	Response   *DTOResponse     `json:"response,omitempty"`
+	PreRequest *DTOScriptResult `json:"pre_request,omitempty"`
+	Test       *DTOScriptResult `json:"test,omitempty"`
+	Extracted  []DTOExtraction  `json:"extracted,omitempty"`
+	Warnings   []string         `json:"warnings,omitempty"`
+	Error      string           `json:"error,omitempty"`
+}
+
+// DTOScriptResult reports a script phase: the pm.test results, console output
+// and the error that stopped the scripts, if any.
+type DTOScriptResult struct {
+	Tests          []DTOScriptTest   `json:"tests"`
+	Console        []DTOConsoleEntry `json:"console,omitempty"`
+	ConsoleDropped int               `json:"console_dropped,omitempty"`
+	Error          string            `json:"error,omitempty"`
+}
+
+type DTOScriptTest struct {
+	Name    string `json:"name"`
+	Passed  bool   `json:"passed"`
+	Skipped bool   `json:"skipped,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+type DTOConsoleEntry struct {
+	Level   string `json:"level"`
+	Message string `json:"message"`
+}
+
+// DTOExtraction reports one extraction rule of a run. Value is what was
+// written to the variable, Error why nothing was.
+type DTOExtraction struct {
+	Variable string `json:"variable"`
+	Found    bool   `json:"found"`
+	Value    string `json:"value,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// DTOExtractionsRequest replaces the extraction rules of a saved request.
+type DTOExtractionsRequest struct {
+	Extractions []Extraction `json:"extractions" validate:"max=100,dive"`
+}
+
+// DTOExamplesRequest replaces the response examples of a saved request.
+type DTOExamplesRequest struct {
+	Examples []ResponseExample `json:"examples" validate:"max=50,dive"`
+}
+
+// DTOCollectionRunRequest starts a run of a collection, or of one of its
+// folders. Requests are sent in order, with a Concurrency above 1 that many
+// are in flight at once, so a request may no longer see values extracted by
+// the one before it.
+//
+// With Data the requests run once per row, one iteration after the other,
+// with the values of the row bound as data variables. Data is a JSON array
+// of objects, or a string holding the text of a CSV file with a header row
+// or of a JSON file; DataFormat says which when it cannot be told apart.
+type DTOCollectionRunRequest struct {
+	EnvironmentID *int            `json:"environment_id,omitempty"`
+	FolderID      *int            `json:"folder_id,omitempty"`
+	Concurrency   int             `json:"concurrency" validate:"gte=0,lte=10"` // 0 means 1
+	Timeout       int             `json:"timeout" validate:"gte=0,lte=90000"`  // per request, 0 means default
+	StopOnFailure bool            `json:"stop_on_failure,omitempty"`
+	Data          json.RawMessage `json:"data,omitempty"`
+	DataFormat    string          `json:"data_format,omitempty" validate:"omitempty,oneof=csv json"`
+}
+
+// DTOMonitorRequest creates or replaces a monitor. Schedule is a five field
+// cron expression, or a descriptor such as @hourly or @every 5m, evaluated
+// in Timezone (UTC when empty). Checks run at most once a minute.
+type DTOMonitorRequest struct {
+	Name          string `json:"name" validate:"required,max=255"`
+	CollectionID  int    `json:"collection_id" validate:"required,gt=0"`
+	ItemID        *int   `json:"item_id,omitempty"`
+	EnvironmentID *int   `json:"environment_id,omitempty"`
+	Schedule      string `json:"schedule" validate:"required,max=255"`
+	Timezone      string `json:"timezone,omitempty" validate:"omitempty,max=64"`
+	Timeout       int    `json:"timeout" validate:"gte=0,lte=90000"` // per request, 0 means default
+	Enabled       *bool  `json:"enabled,omitempty"`                  // defaults to true
+
+	LatencyThresholdMs int `json:"latency_threshold_ms" validate:"gte=0,lte=600000"` // 0 means none
+	AlertAfter         int `json:"alert_after" validate:"gte=0,lte=100"`             // failed checks in a row, 0 means 1
+}
+
+// DTOMonitorAlertRequest creates or replaces an alert webhook. Secret only
+// applies to generic webhooks; on update nil keeps the current one and an
+// empty string removes it.
+type DTOMonitorAlertRequest struct {
+	Name    string  `json:"name" validate:"required,max=255"`
+	Kind    string  `json:"kind" validate:"required,oneof=generic slack teams"`
+	URL     string  `json:"url" validate:"required,url,max=2048"`
+	Secret  *string `json:"secret,omitempty" validate:"omitempty,max=255"`
+	Enabled *bool   `json:"enabled,omitempty"` // defaults to true
+}
+
+// DTOAlertTestResult reports whether a test notification was accepted.
+type DTOAlertTestResult struct {
+	Delivered  bool   `json:"delivered"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// DTOMonitorStats summarizes the checks of a monitor over a window of time.
+// Uptime is the share of checks that were up, in percent; latencies only
+// count checks that could be run. Series splits the window into buckets.
+type DTOMonitorStats struct {
+	Window           string               `json:"window"`
+	From             time.Time            `json:"from"`
+	Checks           int                  `json:"checks"`
+	Up               int                  `json:"up"`
+	Down             int                  `json:"down"`
+	Errors           int                  `json:"errors"`
+	Uptime           *float64             `json:"uptime"`
+	Latency          DTOLatencyStats      `json:"latency"`
+	Assertions       int                  `json:"assertions"`
+	AssertionsFailed int                  `json:"assertions_failed"`
+	Bucket           string               `json:"bucket"`
+	Series           []DTOMonitorStatsBin `json:"series"`
+}
+
+// DTOLatencyStats holds latency percentiles in milliseconds, they are nil
+// when there were no checks to measure.
+type DTOLatencyStats struct {
+	Avg *float64 `json:"avg"`
+	P50 *float64 `json:"p50"`
+	P90 *float64 `json:"p90"`
+	P95 *float64 `json:"p95"`
+	P99 *float64 `json:"p99"`
+}
+
+// DTOMonitorStatsBin summarizes the checks that started in one bucket.
+type DTOMonitorStatsBin struct {
+	Start            time.Time `json:"start"`
+	Checks           int       `json:"checks"`
+	Up               int       `json:"up"`
+	Uptime           float64   `json:"uptime"`
+	LatencyP95       *float64  `json:"latency_p95"`
+	AssertionsFailed int       `json:"assertions_failed"`
+}
+
+// DTOMockServerRequest creates or replaces a mock server.
+type DTOMockServerRequest struct {
+	CollectionID int    `json:"collection_id" validate:"required,gt=0"`
+	Name         string `json:"name" validate:"required,max=255"`
+	DelayMs      int    `json:"delay_ms" validate:"gte=0,lte=30000"`
+	Enabled      *bool  `json:"enabled,omitempty"` // defaults to true
+}
+
+// DTOMockRequest is a request received by a mock server. Path is relative to
+// the mock server and Headers keep the names as received.
+type DTOMockRequest struct {
+	Method   string
+	Path     string
+	Query    map[string][]string
+	Headers  map[string][]string
+	RawQuery string
+	Body     []byte
+}
+
+// DTOMockResponse is what a mock server answers with.
+type DTOMockResponse struct {
+	Status  int
+	Headers []KeyValue
+	Body    string
+}
+
+// DTORequestBinRequest creates or replaces a request bin. Without a response
+// the bin answers 200 with an empty body.
+type DTORequestBinRequest struct {
+	Name     string       `json:"name" validate:"required,max=255"`
+	Response *BinResponse `json:"response,omitempty"`
+}
+
+// DTOBinCapture is a request received by a request bin. Body holds at most
+// the part of the body that is kept, Size is the size of the whole body.
+type DTOBinCapture struct {
+	Method    string
+	Path      string
+	RawQuery  string
+	Headers   map[string][]string
+	Body      []byte
+	Size      int64
+	Truncated bool
+	SourceIP  string
+}
+
+// Types of the events streamed to a client watching a request bin.
+const (
+	BinEventReady   = "ready"
+	BinEventRequest = "request"
+	BinEventPing    = "ping"
+)
+
+// DTOBinEvent is an event streamed to a client watching a request bin: ready
+// once with the bin, then request for each request it records, and ping
+// while it is idle.
+type DTOBinEvent struct {
+	Type    string           `json:"type"`
+	Bin     *RequestBin      `json:"bin,omitempty"`
+	Request *CapturedRequest `json:"request,omitempty"`
+}
+
+// Modes of a load test: rate sends requests at a steady pace whatever the
+// target's latency, concurrency keeps a number of requests in flight.
+const (
+	LoadTestModeRate        = "rate"
+	LoadTestModeConcurrency = "concurrency"
+)
+
+// DTOLoadTestRequest fires one request repeatedly. The test stops after
+// DurationMs or once Requests have been sent, whichever comes first.
+type DTOLoadTestRequest struct {
+	Request     DTORequest `json:"request"`
+	Mode        string     `json:"mode" validate:"omitempty,oneof=rate concurrency"`
+	Rate        int        `json:"rate" validate:"gte=0"`        // requests per second, rate mode
+	Concurrency int        `json:"concurrency" validate:"gte=0"` // requests in flight, concurrency mode
+	DurationMs  int        `json:"duration_ms" validate:"gte=0"`
+	Requests    int        `json:"requests" validate:"gte=0"`
+}
+
+// Types of the events streamed while a load test runs.
+const (
+	LoadTestEventStarted  = "started"
+	LoadTestEventProgress = "progress"
+	LoadTestEventDone     = "done"
+)
+
+// Reasons a load test ends.
+const (
+	LoadTestReasonCompleted = "completed"
+	LoadTestReasonCanceled  = "canceled"
+	LoadTestReasonShutdown  = "shutdown"
+)
+
+// DTOLoadTestEvent is streamed while a load test runs: started with the
+// limits the test runs with, progress about once a second and done with the
+// final stats.
+type DTOLoadTestEvent struct {
+	Type   string         `json:"type"`
+	Plan   *LoadTestPlan  `json:"plan,omitempty"`
+	Stats  *LoadTestStats `json:"stats,omitempty"`
+	Reason string         `json:"reason,omitempty"`
+}
+
+// LoadTestPlan is a load test request once defaults and limits are applied.
+type LoadTestPlan struct {
+	Method      string `json:"method"`
+	URL         string `json:"url"`
+	Mode        string `json:"mode"`
+	Rate        int    `json:"rate,omitempty"`
+	Concurrency int    `json:"concurrency"`
+	DurationMs  int64  `json:"duration_ms"`
+	Requests    int    `json:"requests"`
+	TimeoutMs   int64  `json:"timeout_ms"`
+}
+
+// LoadTestStats sums up the requests of a load test so far. Dropped counts
+// the sends skipped in rate mode because every slot was still waiting for a
+// response, a sign the target cannot keep up with the rate.
+type LoadTestStats struct {
+	ElapsedMs     int64             `json:"elapsed_ms"`
+	Sent          int               `json:"sent"`
+	Completed     int               `json:"completed"`
+	Failed        int               `json:"failed"`
+	Dropped       int               `json:"dropped"`
+	InFlight      int               `json:"in_flight"`
+	Throughput    float64           `json:"throughput"` // completed requests per second
+	BytesReceived int64             `json:"bytes_received"`
+	Latency       LatencyStats      `json:"latency"`
+	Histogram     []LatencyBucket   `json:"histogram"`
+	Statuses      map[int]int       `json:"statuses"`
+	Errors        []LoadTestFailure `json:"errors"`
+}
+
+// LatencyStats are the response times of the completed requests, in
+// milliseconds.
+type LatencyStats struct {
+	Min  float64 `json:"min_ms"`
+	Mean float64 `json:"mean_ms"`
+	P50  float64 `json:"p50_ms"`
+	P90  float64 `json:"p90_ms"`
+	P99  float64 `json:"p99_ms"`
+	Max  float64 `json:"max_ms"`
+}
+
+// LatencyBucket counts the completed requests that took at most UpToMs and
+// more than the bucket before; the last bucket has no UpToMs.
+type LatencyBucket struct {
+	UpToMs *int `json:"up_to_ms"`
+	Count  int  `json:"count"`
+}
+
+// LoadTestFailure counts the requests that failed without a response for
+// one kind of error, with the message of the first one.
+type LoadTestFailure struct {
+	Kind    string `json:"kind"`
+	Count   int    `json:"count"`
+	Message string `json:"message"`
+}
@@ -0,0 +1,198 @@
+package model
+
+import (
+	"encoding/json"
+)
+
+// OpenAPIDocument is an OpenAPI 3.0 or 3.1 document. Only the fields used to
+// generate collections are declared.
+type OpenAPIDocument struct {
+	OpenAPI    string                       `json:"openapi"`
+	Swagger    string                       `json:"swagger"`
+	Info       OpenAPIInfo                  `json:"info"`
+	Servers    []OpenAPIServer              `json:"servers"`
+	Paths      map[string]*OpenAPIPathItem  `json:"paths"`
+	Components OpenAPIComponents            `json:"components"`
+	Security   []OpenAPISecurityRequirement `json:"security"`
+	Tags       []OpenAPITag                 `json:"tags"`
+}
+
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Version     string `json:"version"`
+}
+
+type OpenAPIServer struct {
+	URL         string                           `json:"url"`
+	Description string                           `json:"description"`
+	Variables   map[string]OpenAPIServerVariable `json:"variables"`
+}
+
+type OpenAPIServerVariable struct {
+	Default     string   `json:"default"`
+	Enum        []string `json:"enum"`
+	Description string   `json:"description"`
+}
+
+type OpenAPITag struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type OpenAPIComponents struct {
+	Schemas         map[string]*OpenAPISchema         `json:"schemas"`
+	Parameters      map[string]*OpenAPIParameter      `json:"parameters"`
+	RequestBodies   map[string]*OpenAPIRequestBody    `json:"requestBodies"`
+	Responses       map[string]*OpenAPIResponse       `json:"responses"`
+	Examples        map[string]*OpenAPIExample        `json:"examples"`
+	SecuritySchemes map[string]*OpenAPISecurityScheme `json:"securitySchemes"`
+}
+
+// OpenAPISecurityRequirement maps security scheme names to required scopes.
+type OpenAPISecurityRequirement map[string][]string
+
+type OpenAPIPathItem struct {
+	Ref        string              `json:"$ref"`
+	Parameters []*OpenAPIParameter `json:"parameters"`
+	Get        *OpenAPIOperation   `json:"get"`
+	Put        *OpenAPIOperation   `json:"put"`
+	Post       *OpenAPIOperation   `json:"post"`
+	Delete     *OpenAPIOperation   `json:"delete"`
+	Options    *OpenAPIOperation   `json:"options"`
+	Head       *OpenAPIOperation   `json:"head"`
+	Patch      *OpenAPIOperation   `json:"patch"`
+	Trace      *OpenAPIOperation   `json:"trace"`
+}
+
+// Operations returns the operations of the path item keyed by HTTP method,
+// in a fixed method order.
+func (p *OpenAPIPathItem) Operations() ([]string, []*OpenAPIOperation) {
+	var methods []string
+	var operations []*OpenAPIOperation
+	for _, candidate := range []struct {
+		method    string
+		operation *OpenAPIOperation
+	}{
+		{"GET", p.Get}, {"POST", p.Post}, {"PUT", p.Put}, {"PATCH", p.Patch},
+		{"DELETE", p.Delete}, {"HEAD", p.Head}, {"OPTIONS", p.Options}, {"TRACE", p.Trace},
+	} {
+		if candidate.operation != nil {
+			methods = append(methods, candidate.method)
+			operations = append(operations, candidate.operation)
+		}
+	}
+	return methods, operations
+}
+
+// OpenAPIOperation is a single API operation. A nil Security inherits the
+// document's security, an empty one disables it.
+type OpenAPIOperation struct {
+	Tags        []string                      `json:"tags"`
+	Summary     string                        `json:"summary"`
+	Description string                        `json:"description"`
+	OperationID string                        `json:"operationId"`
+	Parameters  []*OpenAPIParameter           `json:"parameters"`
+	RequestBody *OpenAPIRequestBody           `json:"requestBody"`
+	Responses   map[string]*OpenAPIResponse   `json:"responses"`
+	Security    *[]OpenAPISecurityRequirement `json:"security"`
+	Deprecated  bool                          `json:"deprecated"`
+}
+
+type OpenAPIParameter struct {
+	Ref         string                     `json:"$ref"`
+	Name        string                     `json:"name"`
+	In          string                     `json:"in"`
+	Description string                     `json:"description"`
+	Required    bool                       `json:"required"`
+	Schema      *OpenAPISchema             `json:"schema"`
+	Example     any                        `json:"example"`
+	Examples    map[string]*OpenAPIExample `json:"examples"`
+}
+
+type OpenAPIRequestBody struct {
+	Ref         string                       `json:"$ref"`
+	Description string                       `json:"description"`
+	Required    bool                         `json:"required"`
+	Content     map[string]*OpenAPIMediaType `json:"content"`
+}
+
+type OpenAPIResponse struct {
+	Ref         string                       `json:"$ref"`
+	Description string                       `json:"description"`
+	Content     map[string]*OpenAPIMediaType `json:"content"`
+}
+
+type OpenAPIMediaType struct {
+	Schema   *OpenAPISchema             `json:"schema"`
+	Example  any                        `json:"example"`
+	Examples map[string]*OpenAPIExample `json:"examples"`
+}
+
+type OpenAPIExample struct {
+	Ref     string `json:"$ref"`
+	Summary string `json:"summary"`
+	Value   any    `json:"value"`
+}
+
+// OpenAPISecurityScheme describes how an API is authenticated. Type is one of
+// http, apiKey, oauth2, openIdConnect or mutualTLS.
+type OpenAPISecurityScheme struct {
+	Ref          string `json:"$ref"`
+	Type         string `json:"type"`
+	Description  string `json:"description"`
+	Name         string `json:"name"`
+	In           string `json:"in"`
+	Scheme       string `json:"scheme"`
+	BearerFormat string `json:"bearerFormat"`
+}
+
+// OpenAPISchema is the subset of the OpenAPI schema object needed to build
+// example values.
+type OpenAPISchema struct {
+	Ref        string                    `json:"$ref"`
+	Type       OpenAPISchemaType         `json:"type"`
+	Format     string                    `json:"format"`
+	Properties map[string]*OpenAPISchema `json:"properties"`
+	Items      *OpenAPISchema            `json:"items"`
+	Required   []string                  `json:"required"`
+	Enum       []any                     `json:"enum"`
+	Const      any                       `json:"const"`
+	Default    any                       `json:"default"`
+	Example    any                       `json:"example"`
+	Examples   []any                     `json:"examples"`
+	AllOf      []*OpenAPISchema          `json:"allOf"`
+	OneOf      []*OpenAPISchema          `json:"oneOf"`
+	AnyOf      []*OpenAPISchema          `json:"anyOf"`
+	ReadOnly   bool                      `json:"readOnly"`
+	WriteOnly  bool                      `json:"writeOnly"`
+	Nullable   bool                      `json:"nullable"`
+}
+
+// OpenAPISchemaType accepts the single type of OpenAPI 3.0 as well as the
+// list of types allowed by OpenAPI 3.1.
+type OpenAPISchemaType []string
+
+func (t *OpenAPISchemaType) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = OpenAPISchemaType{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*t = list
+	return nil
+}
+
+// Primary returns the first type that is not "null".
+func (t OpenAPISchemaType) Primary() string {
+	for _, name := range t {
+		if name != "null" {
+			return name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,293 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// PostmanSchemaV21 identifies Postman Collection Format v2.1 documents.
+const PostmanSchemaV21 = "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"
+
+// PostmanCollection is a Postman Collection v2.1 document. Only the fields
+// mapped to the native collection model are declared.
+type PostmanCollection struct {
+	Info     PostmanInfo       `json:"info"`
+	Item     []PostmanItem     `json:"item"`
+	Auth     *PostmanAuth      `json:"auth,omitempty"`
+	Event    []PostmanEvent    `json:"event,omitempty"`
+	Variable []PostmanVariable `json:"variable,omitempty"`
+}
+
+type PostmanInfo struct {
+	PostmanID   string             `json:"_postman_id,omitempty"`
+	Name        string             `json:"name"`
+	Description PostmanDescription `json:"description,omitempty"`
+	Schema      string             `json:"schema"`
+}
+
+// PostmanItem is a folder when Item is set and a request otherwise.
+type PostmanItem struct {
+	Name        string             `json:"name"`
+	Description PostmanDescription `json:"description,omitempty"`
+	Item        []PostmanItem      `json:"item,omitempty"`
+	Request     *PostmanRequest    `json:"request,omitempty"`
+	Auth        *PostmanAuth       `json:"auth,omitempty"`
+	Event       []PostmanEvent     `json:"event,omitempty"`
+	Response    []PostmanResponse  `json:"response,omitempty"`
+}
+
+// PostmanResponse is a saved example response of a request.
+type PostmanResponse struct {
+	Name            string            `json:"name"`
+	OriginalRequest *PostmanRequest   `json:"originalRequest,omitempty"`
+	Status          string            `json:"status,omitempty"`
+	Code            int               `json:"code,omitempty"`
+	Header          []PostmanKeyValue `json:"header,omitempty"`
+	Body            string            `json:"body,omitempty"`
+}
+
+type PostmanRequest struct {
+	Method      string             `json:"method"`
+	Header      []PostmanKeyValue  `json:"header"`
+	URL         PostmanURL         `json:"url"`
+	Body        *PostmanBody       `json:"body,omitempty"`
+	Auth        *PostmanAuth       `json:"auth,omitempty"`
+	Description PostmanDescription `json:"description,omitempty"`
+}
+
+// UnmarshalJSON also accepts a request given as a plain URL string, which
+// Postman treats as a GET request.
+func (r *PostmanRequest) UnmarshalJSON(data []byte) error {
+	var raw string
+	if err := json.Unmarshal(data, &raw); err == nil {
+		*r = PostmanRequest{Method: "GET", URL: PostmanURL{Raw: raw}}
+		return nil
+	}
+	type plain PostmanRequest
+	return json.Unmarshal(data, (*plain)(r))
+}
+
+// PostmanKeyValue is used for headers, query parameters and form fields.
+// Type and Src only apply to form fields, where Type is "text" or "file".
+type PostmanKeyValue struct {
+	Key         string `json:"key"`
+	Value       string `json:"value"`
+	Disabled    bool   `json:"disabled,omitempty"`
+	Type        string `json:"type,omitempty"`
+	Src         any    `json:"src,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+}
+
+// PostmanURL accepts both the string and the object form of a request URL.
+type PostmanURL struct {
+	Raw      string            `json:"raw"`
+	Protocol string            `json:"protocol,omitempty"`
+	Host     []string          `json:"host,omitempty"`
+	Port     string            `json:"port,omitempty"`
+	Path     []string          `json:"path,omitempty"`
+	Query    []PostmanKeyValue `json:"query,omitempty"`
+}
+
+func (u *PostmanURL) UnmarshalJSON(data []byte) error {
+	var raw string
+	if err := json.Unmarshal(data, &raw); err == nil {
+		*u = PostmanURL{Raw: raw}
+		return nil
+	}
+
+	// Host and path may also be given as a single string.
+	var object struct {
+		Raw      string            `json:"raw"`
+		Protocol string            `json:"protocol"`
+		Host     json.RawMessage   `json:"host"`
+		Port     string            `json:"port"`
+		Path     json.RawMessage   `json:"path"`
+		Query    []PostmanKeyValue `json:"query"`
+	}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return err
+	}
+	*u = PostmanURL{Raw: object.Raw, Protocol: object.Protocol, Port: object.Port, Query: object.Query}
+	u.Host = stringOrList(object.Host, ".")
+	u.Path = stringOrList(object.Path, "/")
+	return nil
+}
+
+// stringOrList decodes a JSON string split on sep, or a JSON list of strings.
+// Path segments can also be objects, those are skipped.
+func stringOrList(data json.RawMessage, sep string) []string {
+	if len(data) == 0 {
+		return nil
+	}
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		return strings.Split(strings.Trim(single, sep), sep)
+	}
+	var list []json.RawMessage
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil
+	}
+	var parts []string
+	for _, element := range list {
+		var part string
+		if err := json.Unmarshal(element, &part); err == nil {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+type PostmanBody struct {
+	Mode       string              `json:"mode"`
+	Raw        string              `json:"raw,omitempty"`
+	URLEncoded []PostmanKeyValue   `json:"urlencoded,omitempty"`
+	FormData   []PostmanKeyValue   `json:"formdata,omitempty"`
+	GraphQL    *PostmanGraphQL     `json:"graphql,omitempty"`
+	Options    *PostmanBodyOptions `json:"options,omitempty"`
+	Disabled   bool                `json:"disabled,omitempty"`
+}
+
+type PostmanBodyOptions struct {
+	Raw struct {
+		Language string `json:"language,omitempty"`
+	} `json:"raw"`
+}
+
+type PostmanGraphQL struct {
+	Query     string `json:"query"`
+	Variables string `json:"variables,omitempty"`
+}
+
+// PostmanAuth keeps the parameters of every auth type as a key/value list
+// under the type name, as in "bearer": [{"key": "token", "value": "..."}].
+type PostmanAuth struct {
+	Type   string
+	Params map[string][]PostmanAuthParam
+}
+
+type PostmanAuthParam struct {
+	Key   string `json:"key"`
+	Value any    `json:"value"`
+	Type  string `json:"type,omitempty"`
+}
+
+func (a *PostmanAuth) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	a.Params = make(map[string][]PostmanAuthParam)
+	for name, value := range fields {
+		if name == "type" {
+			if err := json.Unmarshal(value, &a.Type); err != nil {
+				return err
+			}
+			continue
+		}
+		var params []PostmanAuthParam
+		if err := json.Unmarshal(value, &params); err == nil {
+			a.Params[name] = params
+		}
+	}
+	return nil
+}
+
+func (a PostmanAuth) MarshalJSON() ([]byte, error) {
+	fields := map[string]any{"type": a.Type}
+	for name, params := range a.Params {
+		fields[name] = params
+	}
+	return json.Marshal(fields)
+}
+
+// Param returns the value of an auth parameter of the auth's own type.
+func (a *PostmanAuth) Param(key string) string {
+	for _, param := range a.Params[a.Type] {
+		if param.Key == key {
+			if s, ok := param.Value.(string); ok {
+				return s
+			}
+			if param.Value != nil {
+				return fmt.Sprint(param.Value)
+			}
+		}
+	}
+	return ""
+}
+
+type PostmanEvent struct {
+	Listen string        `json:"listen"`
+	Script PostmanScript `json:"script"`
+}
+
+// PostmanScript accepts exec as a single string or a list of lines.
+type PostmanScript struct {
+	Type string   `json:"type,omitempty"`
+	Exec []string `json:"exec"`
+}
+
+func (s *PostmanScript) UnmarshalJSON(data []byte) error {
+	var object struct {
+		Type string          `json:"type"`
+		Exec json.RawMessage `json:"exec"`
+	}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return err
+	}
+	s.Type = object.Type
+	var single string
+	if err := json.Unmarshal(object.Exec, &single); err == nil {
+		s.Exec = strings.Split(single, "\n")
+		return nil
+	}
+	var lines []string
+	if len(object.Exec) > 0 {
+		if err := json.Unmarshal(object.Exec, &lines); err != nil {
+			return err
+		}
+	}
+	s.Exec = lines
+	return nil
+}
+
+// PostmanVariable is a collection variable, Value may be any JSON scalar.
+type PostmanVariable struct {
+	Key      string `json:"key"`
+	Value    any    `json:"value"`
+	Type     string `json:"type,omitempty"`
+	Disabled bool   `json:"disabled,omitempty"`
+}
+
+// PostmanDescription accepts a plain string or a {"content": "..."} object.
+type PostmanDescription string
+
+func (d *PostmanDescription) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*d = PostmanDescription(text)
+		return nil
+	}
+	var object struct {
+		Content string `json:"content"`
+	}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return err
+	}
+	*d = PostmanDescription(object.Content)
+	return nil
+}
+
+// PostmanEnvironment is an environment exported from Postman.
+type PostmanEnvironment struct {
+	Name   string                    `json:"name"`
+	Values []PostmanEnvironmentValue `json:"values"`
+	Scope  string                    `json:"_postman_variable_scope,omitempty"`
+}
+
+type PostmanEnvironmentValue struct {
+	Key     string `json:"key"`
+	Value   any    `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Enabled *bool  `json:"enabled,omitempty"`
+}
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/suar-net/suar-be/internal/model"
+)
+
+type apiSpecRepository struct {
+	db *sql.DB
+}
+
+func NewAPISpecRepository(db *sql.DB) IAPISpecRepository {
+	return &apiSpecRepository{db: db}
+}
+
+func (r *apiSpecRepository) Create(ctx context.Context, spec *model.APISpec) (int, error) {
+	query := `
+		INSERT INTO api_specs (user_id, name, version, document)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`
+
+	err := r.db.QueryRowContext(ctx, query, spec.UserID, spec.Name, spec.Version, []byte(spec.Document)).
+		Scan(&spec.ID, &spec.CreatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return spec.ID, nil
+}
+
+// GetByUserID lists the specs of a user without their documents.
+func (r *apiSpecRepository) GetByUserID(ctx context.Context, userID int) ([]*model.APISpec, error) {
+	query := `
+		SELECT id, user_id, name, version, created_at
+		FROM api_specs
+		WHERE user_id = $1
+		ORDER BY name ASC, id ASC`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var specs []*model.APISpec
+	for rows.Next() {
+		var spec model.APISpec
+		var version sql.NullString
+		if err := rows.Scan(&spec.ID, &spec.UserID, &spec.Name, &version, &spec.CreatedAt); err != nil {
+			return nil, err
+		}
+		spec.Version = version.String
+		specs = append(specs, &spec)
+	}
+	return specs, rows.Err()
+}
+
+// GetByID returns a spec with its document, or nil when the user has no such
+// spec.
+func (r *apiSpecRepository) GetByID(ctx context.Context, id int, userID int) (*model.APISpec, error) {
+	query := `
+		SELECT id, user_id, name, version, document, created_at
+		FROM api_specs
+		WHERE id = $1 AND user_id = $2`
+
+	var spec model.APISpec
+	var version sql.NullString
+	var document []byte
+	err := r.db.QueryRowContext(ctx, query, id, userID).
+		Scan(&spec.ID, &spec.UserID, &spec.Name, &version, &document, &spec.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	spec.Version = version.String
+	spec.Document = document
+	return &spec, nil
+}
+
+// Delete removes a spec. It reports whether the user owned such a spec.
+func (r *apiSpecRepository) Delete(ctx context.Context, id int, userID int) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM api_specs WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
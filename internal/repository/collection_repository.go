@@ -0,0 +1,492 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/suar-net/suar-be/internal/model"
+)
+
+type collectionRepository struct {
+	db *sql.DB
+}
+
+func NewCollectionRepository(db *sql.DB) ICollectionRepository {
+	return &collectionRepository{db: db}
+}
+
+// jsonValue encodes v for a JSONB column, nil pointers become NULL.
+func jsonValue(v any) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if string(data) == "null" {
+		return nil, nil
+	}
+	return data, nil
+}
+
+// scanJSON decodes a nullable JSONB column into dest.
+func scanJSON(data []byte, dest any) error {
+	if len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, dest)
+}
+
+// Create stores a collection together with its whole item tree in one
+// transaction and fills in the generated IDs.
+func (r *collectionRepository) Create(ctx context.Context, collection *model.Collection) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	variables := collection.Variables
+	if variables == nil {
+		variables = []model.Variable{}
+	}
+	variablesJSON, err := json.Marshal(variables)
+	if err != nil {
+		return 0, err
+	}
+	authJSON, err := jsonValue(collection.Auth)
+	if err != nil {
+		return 0, err
+	}
+	scriptsJSON, err := jsonValue(collection.Scripts)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `
+		INSERT INTO collections (user_id, name, description, variables, auth, scripts)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, updated_at`
+
+	err = tx.QueryRowContext(ctx, query,
+		collection.UserID,
+		collection.Name,
+		collection.Description,
+		variablesJSON,
+		authJSON,
+		scriptsJSON,
+	).Scan(&collection.ID, &collection.CreatedAt, &collection.UpdatedAt)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := insertItems(ctx, tx, collection.ID, nil, collection.Items, 0); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return collection.ID, nil
+}
+
+// insertItems stores items, and their children, from position firstPosition on.
+func insertItems(ctx context.Context, tx *sql.Tx, collectionID int, parentID *int, items []*model.CollectionItem, firstPosition int) error {
+	query := `
+		INSERT INTO collection_items (collection_id, parent_id, item_type, name, description, position, request, auth, scripts, contract, examples)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id`
+
+	for i, item := range items {
+		position := firstPosition + i
+		requestJSON, err := jsonValue(item.Request)
+		if err != nil {
+			return err
+		}
+		authJSON, err := jsonValue(item.Auth)
+		if err != nil {
+			return err
+		}
+		scriptsJSON, err := jsonValue(item.Scripts)
+		if err != nil {
+			return err
+		}
+		contractJSON, err := jsonValue(item.Contract)
+		if err != nil {
+			return err
+		}
+		examplesJSON, err := jsonValue(item.Examples)
+		if err != nil {
+			return err
+		}
+
+		item.CollectionID = collectionID
+		item.ParentID = parentID
+		item.Position = position
+		err = tx.QueryRowContext(ctx, query,
+			collectionID,
+			parentID,
+			item.Type,
+			item.Name,
+			item.Description,
+			position,
+			requestJSON,
+			authJSON,
+			scriptsJSON,
+			contractJSON,
+			examplesJSON,
+		).Scan(&item.ID)
+		if err != nil {
+			return err
+		}
+
+		if len(item.Items) > 0 {
+			id := item.ID
+			if err := insertItems(ctx, tx, collectionID, &id, item.Items, 0); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// AddItem appends item to the top level of a collection of the user. Returns
+// false when the collection does not exist.
+func (r *collectionRepository) AddItem(ctx context.Context, collectionID, userID int, item *model.CollectionItem) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// The row lock serialises concurrent appends to the same collection.
+	var id int
+	err = tx.QueryRowContext(ctx,
+		`SELECT id FROM collections WHERE id = $1 AND user_id = $2 FOR UPDATE`,
+		collectionID, userID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	var position int
+	err = tx.QueryRowContext(ctx,
+		`SELECT COALESCE(MAX(position) + 1, 0) FROM collection_items WHERE collection_id = $1 AND parent_id IS NULL`,
+		collectionID).Scan(&position)
+	if err != nil {
+		return false, err
+	}
+
+	if err := insertItems(ctx, tx, collectionID, nil, []*model.CollectionItem{item}, position); err != nil {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE collections SET updated_at = NOW() WHERE id = $1`, collectionID); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+func scanCollection(row rowScanner) (*model.Collection, error) {
+	var collection model.Collection
+	var description sql.NullString
+	var variables, auth, scripts []byte
+	err := row.Scan(
+		&collection.ID,
+		&collection.UserID,
+		&collection.Name,
+		&description,
+		&variables,
+		&auth,
+		&scripts,
+		&collection.CreatedAt,
+		&collection.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	collection.Description = description.String
+
+	if err := scanJSON(variables, &collection.Variables); err != nil {
+		return nil, fmt.Errorf("invalid variables in collection %d: %w", collection.ID, err)
+	}
+	if err := scanJSON(auth, &collection.Auth); err != nil {
+		return nil, fmt.Errorf("invalid auth in collection %d: %w", collection.ID, err)
+	}
+	if err := scanJSON(scripts, &collection.Scripts); err != nil {
+		return nil, fmt.Errorf("invalid scripts in collection %d: %w", collection.ID, err)
+	}
+	return &collection, nil
+}
+
+// GetByUserID lists the collections of a user without their items.
+func (r *collectionRepository) GetByUserID(ctx context.Context, userID int) ([]*model.Collection, error) {
+	query := `
+		SELECT id, user_id, name, description, variables, auth, scripts, created_at, updated_at
+		FROM collections
+		WHERE user_id = $1
+		ORDER BY name ASC, id ASC`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var collections []*model.Collection
+	for rows.Next() {
+		collection, err := scanCollection(rows)
+		if err != nil {
+			return nil, err
+		}
+		collections = append(collections, collection)
+	}
+	return collections, rows.Err()
+}
+
+// GetByID returns a collection with its item tree, or nil when the user has
+// no such collection.
+func (r *collectionRepository) GetByID(ctx context.Context, id int, userID int) (*model.Collection, error) {
+	query := `
+		SELECT id, user_id, name, description, variables, auth, scripts, created_at, updated_at
+		FROM collections
+		WHERE id = $1 AND user_id = $2`
+
+	collection, err := scanCollection(r.db.QueryRowContext(ctx, query, id, userID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	items, err := r.getItems(ctx, collection.ID)
+	if err != nil {
+		return nil, err
+	}
+	collection.Items = items
+	return collection, nil
+}
+
+// getItems loads every item of a collection and assembles the tree.
+func (r *collectionRepository) getItems(ctx context.Context, collectionID int) ([]*model.CollectionItem, error) {
+	query := `
+		SELECT id, collection_id, parent_id, item_type, name, description, position, request, auth, scripts, contract, examples
+		FROM collection_items
+		WHERE collection_id = $1
+		ORDER BY position ASC, id ASC`
+
+	rows, err := r.db.QueryContext(ctx, query, collectionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var all []*model.CollectionItem
+	for rows.Next() {
+		var item model.CollectionItem
+		var description sql.NullString
+		var request, auth, scripts, contract, examples []byte
+		if err := rows.Scan(
+			&item.ID,
+			&item.CollectionID,
+			&item.ParentID,
+			&item.Type,
+			&item.Name,
+			&description,
+			&item.Position,
+			&request,
+			&auth,
+			&scripts,
+			&contract,
+			&examples,
+		); err != nil {
+			return nil, err
+		}
+		item.Description = description.String
+		if err := scanJSON(request, &item.Request); err != nil {
+			return nil, fmt.Errorf("invalid request in collection item %d: %w", item.ID, err)
+		}
+		if err := scanJSON(auth, &item.Auth); err != nil {
+			return nil, fmt.Errorf("invalid auth in collection item %d: %w", item.ID, err)
+		}
+		if err := scanJSON(scripts, &item.Scripts); err != nil {
+			return nil, fmt.Errorf("invalid scripts in collection item %d: %w", item.ID, err)
+		}
+		if err := scanJSON(contract, &item.Contract); err != nil {
+			return nil, fmt.Errorf("invalid contract in collection item %d: %w", item.ID, err)
+		}
+		if err := scanJSON(examples, &item.Examples); err != nil {
+			return nil, fmt.Errorf("invalid examples in collection item %d: %w", item.ID, err)
+		}
+		all = append(all, &item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Rows are ordered by position, so appending keeps every level ordered.
+	byID := make(map[int]*model.CollectionItem, len(all))
+	for _, item := range all {
+		byID[item.ID] = item
+	}
+	var roots []*model.CollectionItem
+	for _, item := range all {
+		if item.ParentID == nil {
+			roots = append(roots, item)
+			continue
+		}
+		if parent, ok := byID[*item.ParentID]; ok {
+			parent.Items = append(parent.Items, item)
+		}
+	}
+	return roots, nil
+}
+
+// Delete removes a collection and its items. It reports whether the user
+// owned such a collection.
+func (r *collectionRepository) Delete(ctx context.Context, id int, userID int) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM collections WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// SetItemContract links a saved request to a contract, a nil contract removes
+// the link. It reports whether the user owns such a request.
+func (r *collectionRepository) SetItemContract(ctx context.Context, collectionID, itemID, userID int, contract *model.Contract) (bool, error) {
+	contractJSON, err := jsonValue(contract)
+	if err != nil {
+		return false, err
+	}
+
+	query := `
+		UPDATE collection_items ci
+		SET contract = $1
+		FROM collections c
+		WHERE ci.id = $2 AND ci.collection_id = $3 AND ci.item_type = $4
+			AND c.id = ci.collection_id AND c.user_id = $5`
+
+	result, err := r.db.ExecContext(ctx, query, contractJSON, itemID, collectionID, model.CollectionItemRequest, userID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// SetItemExtractions replaces the extraction rules of a saved request, no
+// rules removes them. It reports whether the user owns such a request.
+func (r *collectionRepository) SetItemExtractions(ctx context.Context, collectionID, itemID, userID int, extractions []model.Extraction) (bool, error) {
+	var extractionsJSON interface{}
+	if len(extractions) > 0 {
+		data, err := json.Marshal(extractions)
+		if err != nil {
+			return false, err
+		}
+		extractionsJSON = data
+	}
+
+	query := `
+		UPDATE collection_items ci
+		SET request = CASE
+			WHEN $1::jsonb IS NULL THEN COALESCE(ci.request, '{}'::jsonb) - 'extractions'
+			ELSE jsonb_set(COALESCE(ci.request, '{}'::jsonb), '{extractions}', $1::jsonb)
+		END
+		FROM collections c
+		WHERE ci.id = $2 AND ci.collection_id = $3 AND ci.item_type = $4
+			AND c.id = ci.collection_id AND c.user_id = $5`
+
+	result, err := r.db.ExecContext(ctx, query, extractionsJSON, itemID, collectionID, model.CollectionItemRequest, userID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// SetItemExamples replaces the response examples of a saved request, no
+// examples removes them. It reports whether the user owns such a request.
+func (r *collectionRepository) SetItemExamples(ctx context.Context, collectionID, itemID, userID int, examples []model.ResponseExample) (bool, error) {
+	var examplesJSON interface{}
+	if len(examples) > 0 {
+		data, err := json.Marshal(examples)
+		if err != nil {
+			return false, err
+		}
+		examplesJSON = data
+	}
+
+	query := `
+		UPDATE collection_items ci
+		SET examples = $1
+		FROM collections c
+		WHERE ci.id = $2 AND ci.collection_id = $3 AND ci.item_type = $4
+			AND c.id = ci.collection_id AND c.user_id = $5`
+
+	result, err := r.db.ExecContext(ctx, query, examplesJSON, itemID, collectionID, model.CollectionItemRequest, userID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// MergeVariables replaces the variables of a collection with what merge
+// makes of the stored ones, and reports whether the user owns it. The row is
+// locked meanwhile, so concurrent merges do not lose each other's changes.
+func (r *collectionRepository) MergeVariables(ctx context.Context, id int, userID int, merge func([]model.Variable) []model.Variable) (bool, error) {
+	return mergeVariables(ctx, r.db, "collections", id, userID, merge)
+}
+
+// mergeVariables applies merge to the variables column of a row of table in
+// a transaction holding the row lock.
+func mergeVariables(ctx context.Context, db *sql.DB, table string, id int, userID int, merge func([]model.Variable) []model.Variable) (bool, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var stored []byte
+	err = tx.QueryRowContext(ctx,
+		`SELECT variables FROM `+table+` WHERE id = $1 AND user_id = $2 FOR UPDATE`,
+		id, userID).Scan(&stored)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	var variables []model.Variable
+	if err := scanJSON(stored, &variables); err != nil {
+		return false, fmt.Errorf("invalid variables in %s %d: %w", table, id, err)
+	}
+
+	variables = merge(variables)
+	if variables == nil {
+		variables = []model.Variable{}
+	}
+	variablesJSON, err := json.Marshal(variables)
+	if err != nil {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE `+table+` SET variables = $1 WHERE id = $2`, variablesJSON, id); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
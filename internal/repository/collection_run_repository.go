@@ -0,0 +1,224 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/suar-net/suar-be/internal/model"
+)
+
+type collectionRunRepository struct {
+	db *sql.DB
+}
+
+func NewCollectionRunRepository(db *sql.DB) ICollectionRunRepository {
+	return &collectionRunRepository{db: db}
+}
+
+// runLockClass is the first key of the advisory locks that serialize
+// starting runs of the same user.
+const runLockClass = 1
+
+// Create stores a new run unless the user already has limit runs in
+// progress. Runs whose heartbeat is older than staleBefore are not counted,
+// their replica is gone. It reports whether the run was stored; an advisory
+// lock per user keeps replicas from starting runs past the limit together.
+func (r *collectionRunRepository) Create(ctx context.Context, run *model.CollectionRun, limit int, staleBefore time.Time) (bool, error) {
+	totalsJSON, err := json.Marshal(run.Totals)
+	if err != nil {
+		return false, err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1, $2)`, runLockClass, run.UserID); err != nil {
+		return false, err
+	}
+	var running int
+	err = tx.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM collection_runs
+		WHERE user_id = $1 AND status = $2 AND heartbeat_at >= $3`,
+		run.UserID, model.RunStatusRunning, staleBefore).
+		Scan(&running)
+	if err != nil {
+		return false, err
+	}
+	if running >= limit {
+		return false, nil
+	}
+
+	query := `
+		INSERT INTO collection_runs (user_id, collection_id, folder_id, environment_id, name, status, concurrency, totals)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, started_at`
+
+	err = tx.QueryRowContext(ctx, query, run.UserID, run.CollectionID, run.FolderID, run.EnvironmentID,
+		run.Name, run.Status, run.Concurrency, totalsJSON).
+		Scan(&run.ID, &run.StartedAt)
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// Heartbeat records that the replica running a run is alive and reports
+// whether the user asked to cancel the run.
+func (r *collectionRunRepository) Heartbeat(ctx context.Context, id int) (bool, error) {
+	var cancelRequested bool
+	err := r.db.QueryRowContext(ctx, `UPDATE collection_runs SET heartbeat_at = NOW() WHERE id = $1 RETURNING cancel_requested`, id).
+		Scan(&cancelRequested)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+	return cancelRequested, nil
+}
+
+// FailStale marks the runs in progress whose heartbeat is older than
+// staleBefore as failed and returns how many there were.
+func (r *collectionRunRepository) FailStale(ctx context.Context, staleBefore time.Time, message string) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE collection_runs
+		SET status = $1, error = $2, finished_at = NOW()
+		WHERE status = $3 AND heartbeat_at < $4`,
+		model.RunStatusFailed, message, model.RunStatusRunning, staleBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// Update saves the progress of a run and reports whether the user asked to
+// cancel it in the meantime.
+func (r *collectionRunRepository) Update(ctx context.Context, run *model.CollectionRun) (bool, error) {
+	results := run.Results
+	if results == nil {
+		results = []model.RunItemResult{}
+	}
+	resultsJSON, err := json.Marshal(results)
+	if err != nil {
+		return false, err
+	}
+	iterations := run.Iterations
+	if iterations == nil {
+		iterations = []model.RunIteration{}
+	}
+	iterationsJSON, err := json.Marshal(iterations)
+	if err != nil {
+		return false, err
+	}
+	totalsJSON, err := json.Marshal(run.Totals)
+	if err != nil {
+		return false, err
+	}
+	var runError sql.NullString
+	if run.Error != "" {
+		runError = sql.NullString{String: run.Error, Valid: true}
+	}
+
+	query := `
+		UPDATE collection_runs
+		SET status = $1, totals = $2, results = $3, iterations = $4, error = $5, finished_at = $6, heartbeat_at = NOW()
+		WHERE id = $7
+		RETURNING cancel_requested`
+
+	var cancelRequested bool
+	err = r.db.QueryRowContext(ctx, query, run.Status, totalsJSON, resultsJSON, iterationsJSON, runError, run.FinishedAt, run.ID).
+		Scan(&cancelRequested)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+	return cancelRequested, nil
+}
+
+// GetByUserID lists the latest runs of a user without their results,
+// optionally only those of one collection.
+func (r *collectionRunRepository) GetByUserID(ctx context.Context, userID int, collectionID *int) ([]*model.CollectionRun, error) {
+	query := `
+		SELECT id, user_id, collection_id, folder_id, environment_id, name, status, concurrency, totals, error, started_at, finished_at
+		FROM collection_runs
+		WHERE user_id = $1 AND ($2::INTEGER IS NULL OR collection_id = $2)
+		ORDER BY started_at DESC, id DESC
+		LIMIT 100`
+
+	rows, err := r.db.QueryContext(ctx, query, userID, collectionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []*model.CollectionRun
+	for rows.Next() {
+		var run model.CollectionRun
+		var totals []byte
+		var runError sql.NullString
+		if err := rows.Scan(&run.ID, &run.UserID, &run.CollectionID, &run.FolderID, &run.EnvironmentID, &run.Name,
+			&run.Status, &run.Concurrency, &totals, &runError, &run.StartedAt, &run.FinishedAt); err != nil {
+			return nil, err
+		}
+		if err := scanJSON(totals, &run.Totals); err != nil {
+			return nil, err
+		}
+		run.Error = runError.String
+		runs = append(runs, &run)
+	}
+	return runs, rows.Err()
+}
+
+// GetByID returns a run with its results and iterations, or nil when the user has no such
+// run.
+func (r *collectionRunRepository) GetByID(ctx context.Context, id int, userID int) (*model.CollectionRun, error) {
+	query := `
+		SELECT id, user_id, collection_id, folder_id, environment_id, name, status, concurrency, totals, results, iterations, error, started_at, finished_at
+		FROM collection_runs
+		WHERE id = $1 AND user_id = $2`
+
+	var run model.CollectionRun
+	var totals, results, iterations []byte
+	var runError sql.NullString
+	err := r.db.QueryRowContext(ctx, query, id, userID).
+		Scan(&run.ID, &run.UserID, &run.CollectionID, &run.FolderID, &run.EnvironmentID, &run.Name,
+			&run.Status, &run.Concurrency, &totals, &results, &iterations, &runError, &run.StartedAt, &run.FinishedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if err := scanJSON(totals, &run.Totals); err != nil {
+		return nil, err
+	}
+	if err := scanJSON(results, &run.Results); err != nil {
+		return nil, err
+	}
+	if err := scanJSON(iterations, &run.Iterations); err != nil {
+		return nil, err
+	}
+	run.Error = runError.String
+	return &run, nil
+}
+
+// RequestCancel flags a run for cancellation. It reports whether the user
+// owns such a run.
+func (r *collectionRunRepository) RequestCancel(ctx context.Context, id int, userID int) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `UPDATE collection_runs SET cancel_requested = TRUE WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/suar-net/suar-be/internal/model"
+)
+
+type environmentRepository struct {
+	db *sql.DB
+}
+
+func NewEnvironmentRepository(db *sql.DB) IEnvironmentRepository {
+	return &environmentRepository{db: db}
+}
+
+func (r *environmentRepository) Create(ctx context.Context, environment *model.Environment) (int, error) {
+	variables := environment.Variables
+	if variables == nil {
+		variables = []model.Variable{}
+	}
+	variablesJSON, err := json.Marshal(variables)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `
+		INSERT INTO environments (user_id, name, variables)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at, updated_at`
+
+	err = r.db.QueryRowContext(ctx, query, environment.UserID, environment.Name, variablesJSON).
+		Scan(&environment.ID, &environment.CreatedAt, &environment.UpdatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return environment.ID, nil
+}
+
+func scanEnvironment(row rowScanner) (*model.Environment, error) {
+	var environment model.Environment
+	var variables []byte
+	err := row.Scan(
+		&environment.ID,
+		&environment.UserID,
+		&environment.Name,
+		&variables,
+		&environment.CreatedAt,
+		&environment.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := scanJSON(variables, &environment.Variables); err != nil {
+		return nil, fmt.Errorf("invalid variables in environment %d: %w", environment.ID, err)
+	}
+	return &environment, nil
+}
+
+func (r *environmentRepository) GetByUserID(ctx context.Context, userID int) ([]*model.Environment, error) {
+	query := `
+		SELECT id, user_id, name, variables, created_at, updated_at
+		FROM environments
+		WHERE user_id = $1
+		ORDER BY name ASC, id ASC`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var environments []*model.Environment
+	for rows.Next() {
+		environment, err := scanEnvironment(rows)
+		if err != nil {
+			return nil, err
+		}
+		environments = append(environments, environment)
+	}
+	return environments, rows.Err()
+}
+
+// GetByID returns an environment of a user, or nil when there is no such
+// environment.
+func (r *environmentRepository) GetByID(ctx context.Context, id int, userID int) (*model.Environment, error) {
+	query := `
+		SELECT id, user_id, name, variables, created_at, updated_at
+		FROM environments
+		WHERE id = $1 AND user_id = $2`
+
+	environment, err := scanEnvironment(r.db.QueryRowContext(ctx, query, id, userID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return environment, nil
+}
+
+// Delete removes an environment and reports whether the user owned it.
+func (r *environmentRepository) Delete(ctx context.Context, id int, userID int) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM environments WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// MergeVariables replaces the variables of an environment with what merge
+// makes of the stored ones, and reports whether the user owns it. The row is
+// locked meanwhile, so concurrent merges do not lose each other's changes.
+func (r *environmentRepository) MergeVariables(ctx context.Context, id int, userID int, merge func([]model.Variable) []model.Variable) (bool, error) {
+	return mergeVariables(ctx, r.db, "environments", id, userID, merge)
+}
@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/suar-net/suar-be/internal/model"
+)
+
+type loadTestRepository struct {
+	db *sql.DB
+}
+
+func NewLoadTestRepository(db *sql.DB) ILoadTestRepository {
+	return &loadTestRepository{db: db}
+}
+
+// First keys of the advisory locks that serialize starting load tests of
+// the same user and against the same host, next to runLockClass.
+const (
+	loadTestUserLockClass = 2
+	loadTestHostLockClass = 3
+)
+
+// Create stores a load test unless its user already has userLimit load
+// tests in progress or its host is being load tested. Tests whose heartbeat
+// is older than staleBefore are not counted, their replica is gone, and are
+// deleted on the way. It reports whether the test was stored and, when it
+// was not, whether its host was the reason. Advisory locks per user and per
+// host, always taken in that order, keep replicas from starting tests past
+// the limits together.
+func (r *loadTestRepository) Create(ctx context.Context, test *model.LoadTest, userLimit int, staleBefore time.Time) (bool, bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, false, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1, $2)`, loadTestUserLockClass, test.UserID); err != nil {
+		return false, false, err
+	}
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1, hashtext($2))`, loadTestHostLockClass, test.Host); err != nil {
+		return false, false, err
+	}
+	_, err = tx.ExecContext(ctx, `DELETE FROM load_tests WHERE (user_id = $1 OR host = $2) AND heartbeat_at < $3`,
+		test.UserID, test.Host, staleBefore)
+	if err != nil {
+		return false, false, err
+	}
+
+	var hostBusy bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM load_tests WHERE host = $1)`, test.Host).Scan(&hostBusy); err != nil {
+		return false, false, err
+	}
+	if hostBusy {
+		return false, true, nil
+	}
+	var running int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM load_tests WHERE user_id = $1`, test.UserID).Scan(&running); err != nil {
+		return false, false, err
+	}
+	if running >= userLimit {
+		return false, false, nil
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO load_tests (user_id, host)
+		VALUES ($1, $2)
+		RETURNING id, started_at, heartbeat_at`,
+		test.UserID, test.Host).
+		Scan(&test.ID, &test.StartedAt, &test.HeartbeatAt)
+	if err != nil {
+		return false, false, err
+	}
+	return true, false, tx.Commit()
+}
+
+// Heartbeat records that the replica running a load test is alive.
+func (r *loadTestRepository) Heartbeat(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE load_tests SET heartbeat_at = NOW() WHERE id = $1`, id)
+	return err
+}
+
+func (r *loadTestRepository) Delete(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM load_tests WHERE id = $1`, id)
+	return err
+}
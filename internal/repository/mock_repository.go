@@ -0,0 +1,195 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/suar-net/suar-be/internal/model"
+)
+
+// maxMockHits is how many hits are kept per mock server, older ones are
+// removed as new ones come in.
+const maxMockHits = 1000
+
+type mockRepository struct {
+	db *sql.DB
+}
+
+func NewMockRepository(db *sql.DB) IMockRepository {
+	return &mockRepository{db: db}
+}
+
+// mockColumns is the column list read by every mock server query, in the
+// order scanMock expects.
+const mockColumns = `id, user_id, collection_id, name, delay_ms, enabled, created_at, updated_at`
+
+func scanMock(row rowScanner) (*model.MockServer, error) {
+	var mock model.MockServer
+	err := row.Scan(&mock.ID, &mock.UserID, &mock.CollectionID, &mock.Name, &mock.DelayMs, &mock.Enabled,
+		&mock.CreatedAt, &mock.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &mock, nil
+}
+
+func (r *mockRepository) Create(ctx context.Context, mock *model.MockServer) error {
+	query := `
+		INSERT INTO mock_servers (id, user_id, collection_id, name, delay_ms, enabled)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING created_at, updated_at`
+
+	return r.db.QueryRowContext(ctx, query, mock.ID, mock.UserID, mock.CollectionID, mock.Name, mock.DelayMs, mock.Enabled).
+		Scan(&mock.CreatedAt, &mock.UpdatedAt)
+}
+
+func (r *mockRepository) CountByUserID(ctx context.Context, userID int) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM mock_servers WHERE user_id = $1`, userID).Scan(&count)
+	return count, err
+}
+
+func (r *mockRepository) GetByUserID(ctx context.Context, userID int) ([]*model.MockServer, error) {
+	query := `SELECT ` + mockColumns + ` FROM mock_servers WHERE user_id = $1 ORDER BY name ASC, created_at ASC`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var mocks []*model.MockServer
+	for rows.Next() {
+		mock, err := scanMock(rows)
+		if err != nil {
+			return nil, err
+		}
+		mocks = append(mocks, mock)
+	}
+	return mocks, rows.Err()
+}
+
+// GetByID returns a mock server, or nil when the user has no such mock
+// server.
+func (r *mockRepository) GetByID(ctx context.Context, id string, userID int) (*model.MockServer, error) {
+	query := `SELECT ` + mockColumns + ` FROM mock_servers WHERE id = $1 AND user_id = $2`
+	return r.getMock(ctx, query, id, userID)
+}
+
+// GetPublic returns a mock server whoever owns it, or nil when there is no
+// such mock server. It serves the public mock endpoints.
+func (r *mockRepository) GetPublic(ctx context.Context, id string) (*model.MockServer, error) {
+	query := `SELECT ` + mockColumns + ` FROM mock_servers WHERE id = $1`
+	return r.getMock(ctx, query, id)
+}
+
+func (r *mockRepository) getMock(ctx context.Context, query string, args ...any) (*model.MockServer, error) {
+	mock, err := scanMock(r.db.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return mock, nil
+}
+
+// Update replaces the settings of a mock server and reports whether the
+// user owns it.
+func (r *mockRepository) Update(ctx context.Context, mock *model.MockServer) (bool, error) {
+	query := `
+		UPDATE mock_servers
+		SET collection_id = $1, name = $2, delay_ms = $3, enabled = $4, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $5 AND user_id = $6
+		RETURNING updated_at`
+
+	err := r.db.QueryRowContext(ctx, query, mock.CollectionID, mock.Name, mock.DelayMs, mock.Enabled, mock.ID, mock.UserID).
+		Scan(&mock.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Delete removes a mock server with its hits. It reports whether the user
+// owned such a mock server.
+func (r *mockRepository) Delete(ctx context.Context, id string, userID int) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM mock_servers WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// CreateHit logs a request a mock server received and drops the hits past
+// the latest maxMockHits.
+func (r *mockRepository) CreateHit(ctx context.Context, hit *model.MockHit) error {
+	headers := hit.Headers
+	if headers == nil {
+		headers = []model.KeyValue{}
+	}
+	headersJSON, err := json.Marshal(headers)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO mock_hits (mock_id, method, path, query, headers, body, item_id, example, status, duration_ms)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, created_at`
+
+	err = r.db.QueryRowContext(ctx, query, hit.MockID, hit.Method, hit.Path, nullString(hit.Query), headersJSON,
+		nullString(hit.Body), hit.ItemID, nullString(hit.Example), hit.Status, hit.DurationMs).
+		Scan(&hit.ID, &hit.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+		DELETE FROM mock_hits
+		WHERE mock_id = $1 AND id <= (
+			SELECT id FROM mock_hits WHERE mock_id = $1 ORDER BY id DESC OFFSET $2 LIMIT 1
+		)`, hit.MockID, maxMockHits)
+	return err
+}
+
+// GetHits lists the latest hits of a mock server, newest first.
+func (r *mockRepository) GetHits(ctx context.Context, mockID string, limit int) ([]*model.MockHit, error) {
+	query := `
+		SELECT id, mock_id, method, path, query, headers, body, item_id, example, status, duration_ms, created_at
+		FROM mock_hits
+		WHERE mock_id = $1
+		ORDER BY id DESC
+		LIMIT $2`
+
+	rows, err := r.db.QueryContext(ctx, query, mockID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hits []*model.MockHit
+	for rows.Next() {
+		var hit model.MockHit
+		var query, body, example sql.NullString
+		var headers []byte
+		if err := rows.Scan(&hit.ID, &hit.MockID, &hit.Method, &hit.Path, &query, &headers, &body, &hit.ItemID,
+			&example, &hit.Status, &hit.DurationMs, &hit.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := scanJSON(headers, &hit.Headers); err != nil {
+			return nil, err
+		}
+		hit.Query, hit.Body, hit.Example = query.String, body.String, example.String
+		hits = append(hits, &hit)
+	}
+	return hits, rows.Err()
+}
@@ -0,0 +1,241 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/suar-net/suar-be/internal/model"
+)
+
+// monitorAlertRetention is how long alert deliveries are kept once they are
+// done with.
+const monitorAlertRetention = 30 * 24 * time.Hour
+
+type monitorAlertRepository struct {
+	db *sql.DB
+}
+
+func NewMonitorAlertRepository(db *sql.DB) IMonitorAlertRepository {
+	return &monitorAlertRepository{db: db}
+}
+
+// alertColumns is the column list read by every alert query, in the order
+// scanAlert expects.
+const alertColumns = `id, monitor_id, name, kind, url, secret, enabled, created_at, updated_at`
+
+func scanAlert(row rowScanner) (*model.MonitorAlert, error) {
+	var alert model.MonitorAlert
+	var secret sql.NullString
+	err := row.Scan(&alert.ID, &alert.MonitorID, &alert.Name, &alert.Kind, &alert.URL, &secret, &alert.Enabled,
+		&alert.CreatedAt, &alert.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	alert.Secret = secret.String
+	alert.HasSecret = secret.Valid && secret.String != ""
+	return &alert, nil
+}
+
+func nullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
+
+func (r *monitorAlertRepository) Create(ctx context.Context, alert *model.MonitorAlert) (int, error) {
+	query := `
+		INSERT INTO monitor_alerts (monitor_id, name, kind, url, secret, enabled)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, updated_at`
+
+	err := r.db.QueryRowContext(ctx, query, alert.MonitorID, alert.Name, alert.Kind, alert.URL, nullString(alert.Secret), alert.Enabled).
+		Scan(&alert.ID, &alert.CreatedAt, &alert.UpdatedAt)
+	if err != nil {
+		return 0, err
+	}
+	alert.HasSecret = alert.Secret != ""
+	return alert.ID, nil
+}
+
+func (r *monitorAlertRepository) GetByMonitorID(ctx context.Context, monitorID int) ([]*model.MonitorAlert, error) {
+	query := `SELECT ` + alertColumns + ` FROM monitor_alerts WHERE monitor_id = $1 ORDER BY id ASC`
+
+	rows, err := r.db.QueryContext(ctx, query, monitorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var alerts []*model.MonitorAlert
+	for rows.Next() {
+		alert, err := scanAlert(rows)
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts, rows.Err()
+}
+
+// GetByID returns an alert of a monitor, or nil when the monitor has no such
+// alert.
+func (r *monitorAlertRepository) GetByID(ctx context.Context, id int, monitorID int) (*model.MonitorAlert, error) {
+	query := `SELECT ` + alertColumns + ` FROM monitor_alerts WHERE id = $1 AND monitor_id = $2`
+
+	alert, err := scanAlert(r.db.QueryRowContext(ctx, query, id, monitorID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return alert, nil
+}
+
+// Update replaces the settings of an alert and reports whether the monitor
+// has it.
+func (r *monitorAlertRepository) Update(ctx context.Context, alert *model.MonitorAlert) (bool, error) {
+	query := `
+		UPDATE monitor_alerts
+		SET name = $1, kind = $2, url = $3, secret = $4, enabled = $5, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $6 AND monitor_id = $7
+		RETURNING updated_at`
+
+	err := r.db.QueryRowContext(ctx, query, alert.Name, alert.Kind, alert.URL, nullString(alert.Secret), alert.Enabled,
+		alert.ID, alert.MonitorID).
+		Scan(&alert.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+	alert.HasSecret = alert.Secret != ""
+	return true, nil
+}
+
+// Delete removes an alert with its deliveries. It reports whether the
+// monitor had such an alert.
+func (r *monitorAlertRepository) Delete(ctx context.Context, id int, monitorID int) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM monitor_alerts WHERE id = $1 AND monitor_id = $2`, id, monitorID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// ClaimDeliveries takes up to limit pending deliveries that are due, with
+// their alerts, and counts an attempt for each. They are not due again until
+// the lease runs out, so a replica that dies mid send leaves them to be
+// retried, and rows another replica is claiming are skipped.
+func (r *monitorAlertRepository) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*model.AlertDelivery, error) {
+	query := `
+		WITH claimed AS (
+			UPDATE alert_deliveries
+			SET attempts = attempts + 1, next_attempt_at = CURRENT_TIMESTAMP + make_interval(secs => $1)
+			WHERE id IN (
+				SELECT id FROM alert_deliveries
+				WHERE status = $2 AND next_attempt_at <= CURRENT_TIMESTAMP
+				ORDER BY next_attempt_at ASC
+				LIMIT $3
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING id, alert_id, monitor_id, event, payload, status, attempts, created_at
+		)
+		SELECT c.id, c.alert_id, c.monitor_id, c.event, c.payload, c.status, c.attempts, c.created_at,
+			a.id, a.monitor_id, a.name, a.kind, a.url, a.secret, a.enabled, a.created_at, a.updated_at
+		FROM claimed c
+		JOIN monitor_alerts a ON a.id = c.alert_id
+		ORDER BY c.id`
+
+	rows, err := r.db.QueryContext(ctx, query, lease.Seconds(), model.DeliveryStatusPending, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []*model.AlertDelivery
+	for rows.Next() {
+		var delivery model.AlertDelivery
+		var alert model.MonitorAlert
+		var payload []byte
+		var secret sql.NullString
+		if err := rows.Scan(&delivery.ID, &delivery.AlertID, &delivery.MonitorID, &delivery.Event, &payload,
+			&delivery.Status, &delivery.Attempts, &delivery.CreatedAt,
+			&alert.ID, &alert.MonitorID, &alert.Name, &alert.Kind, &alert.URL, &secret, &alert.Enabled,
+			&alert.CreatedAt, &alert.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if err := scanJSON(payload, &delivery.Payload); err != nil {
+			return nil, err
+		}
+		alert.Secret = secret.String
+		alert.HasSecret = secret.Valid && secret.String != ""
+		delivery.Alert = &alert
+		deliveries = append(deliveries, &delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// MarkSent records that a delivery was accepted. Deliveries that are done
+// with past the retention period are removed on the way.
+func (r *monitorAlertRepository) MarkSent(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE alert_deliveries SET status = $1, sent_at = CURRENT_TIMESTAMP, last_error = NULL WHERE id = $2`,
+		model.DeliveryStatusSent, id)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx, `DELETE FROM alert_deliveries WHERE status <> $1 AND created_at < $2`,
+		model.DeliveryStatusPending, time.Now().Add(-monitorAlertRetention))
+	return err
+}
+
+// MarkFailed records a failed attempt. The delivery is tried again at
+// retryAt, or given up when retryAt is nil.
+func (r *monitorAlertRepository) MarkFailed(ctx context.Context, id int64, message string, retryAt *time.Time) error {
+	if retryAt == nil {
+		_, err := r.db.ExecContext(ctx, `UPDATE alert_deliveries SET status = $1, last_error = $2 WHERE id = $3`,
+			model.DeliveryStatusFailed, message, id)
+		return err
+	}
+	_, err := r.db.ExecContext(ctx, `UPDATE alert_deliveries SET last_error = $1, next_attempt_at = $2 WHERE id = $3`,
+		message, *retryAt, id)
+	return err
+}
+
+// GetDeliveries lists the latest deliveries of a monitor, newest first.
+func (r *monitorAlertRepository) GetDeliveries(ctx context.Context, monitorID int, limit int) ([]*model.AlertDelivery, error) {
+	query := `
+		SELECT id, alert_id, monitor_id, event, payload, status, attempts, last_error, next_attempt_at, created_at, sent_at
+		FROM alert_deliveries
+		WHERE monitor_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2`
+
+	rows, err := r.db.QueryContext(ctx, query, monitorID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []*model.AlertDelivery
+	for rows.Next() {
+		var delivery model.AlertDelivery
+		var payload []byte
+		var lastError sql.NullString
+		if err := rows.Scan(&delivery.ID, &delivery.AlertID, &delivery.MonitorID, &delivery.Event, &payload, &delivery.Status,
+			&delivery.Attempts, &lastError, &delivery.NextAttemptAt, &delivery.CreatedAt, &delivery.SentAt); err != nil {
+			return nil, err
+		}
+		if err := scanJSON(payload, &delivery.Payload); err != nil {
+			return nil, err
+		}
+		delivery.LastError = lastError.String
+		deliveries = append(deliveries, &delivery)
+	}
+	return deliveries, rows.Err()
+}
@@ -0,0 +1,374 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/suar-net/suar-be/internal/model"
+)
+
+// monitorResultRetention is how long the results of monitor checks are kept.
+const monitorResultRetention = 90 * 24 * time.Hour
+
+type monitorRepository struct {
+	db *sql.DB
+}
+
+func NewMonitorRepository(db *sql.DB) IMonitorRepository {
+	return &monitorRepository{db: db}
+}
+
+// monitorColumns is the column list read by every monitor query, in the
+// order scanMonitor expects.
+const monitorColumns = `id, user_id, collection_id, item_id, environment_id, name, schedule, timezone, timeout,
+	enabled, next_run_at, last_run_at, last_status, latency_threshold_ms, alert_after, alert_state,
+	consecutive_failures, failing_since, created_at, updated_at`
+
+func scanMonitor(row rowScanner) (*model.Monitor, error) {
+	var monitor model.Monitor
+	var lastStatus sql.NullString
+	err := row.Scan(&monitor.ID, &monitor.UserID, &monitor.CollectionID, &monitor.ItemID, &monitor.EnvironmentID,
+		&monitor.Name, &monitor.Schedule, &monitor.Timezone, &monitor.Timeout, &monitor.Enabled,
+		&monitor.NextRunAt, &monitor.LastRunAt, &lastStatus, &monitor.LatencyThresholdMs, &monitor.AlertAfter,
+		&monitor.AlertState, &monitor.ConsecutiveFailures, &monitor.FailingSince, &monitor.CreatedAt, &monitor.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	monitor.LastStatus = lastStatus.String
+	return &monitor, nil
+}
+
+func (r *monitorRepository) Create(ctx context.Context, monitor *model.Monitor) (int, error) {
+	query := `
+		INSERT INTO monitors (user_id, collection_id, item_id, environment_id, name, schedule, timezone, timeout, enabled,
+			next_run_at, latency_threshold_ms, alert_after)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING id, alert_state, created_at, updated_at`
+
+	err := r.db.QueryRowContext(ctx, query, monitor.UserID, monitor.CollectionID, monitor.ItemID, monitor.EnvironmentID,
+		monitor.Name, monitor.Schedule, monitor.Timezone, monitor.Timeout, monitor.Enabled, monitor.NextRunAt,
+		monitor.LatencyThresholdMs, monitor.AlertAfter).
+		Scan(&monitor.ID, &monitor.AlertState, &monitor.CreatedAt, &monitor.UpdatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return monitor.ID, nil
+}
+
+func (r *monitorRepository) CountByUserID(ctx context.Context, userID int) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM monitors WHERE user_id = $1`, userID).Scan(&count)
+	return count, err
+}
+
+func (r *monitorRepository) GetByUserID(ctx context.Context, userID int) ([]*model.Monitor, error) {
+	query := `SELECT ` + monitorColumns + ` FROM monitors WHERE user_id = $1 ORDER BY name ASC, id ASC`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var monitors []*model.Monitor
+	for rows.Next() {
+		monitor, err := scanMonitor(rows)
+		if err != nil {
+			return nil, err
+		}
+		monitors = append(monitors, monitor)
+	}
+	return monitors, rows.Err()
+}
+
+// GetByID returns a monitor, or nil when the user has no such monitor.
+func (r *monitorRepository) GetByID(ctx context.Context, id int, userID int) (*model.Monitor, error) {
+	query := `SELECT ` + monitorColumns + ` FROM monitors WHERE id = $1 AND user_id = $2`
+
+	monitor, err := scanMonitor(r.db.QueryRowContext(ctx, query, id, userID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return monitor, nil
+}
+
+// Update replaces the settings of a monitor and reports whether the user
+// owns it. A check in progress keeps its lease.
+func (r *monitorRepository) Update(ctx context.Context, monitor *model.Monitor) (bool, error) {
+	query := `
+		UPDATE monitors
+		SET collection_id = $1, item_id = $2, environment_id = $3, name = $4, schedule = $5, timezone = $6,
+			timeout = $7, enabled = $8, next_run_at = $9, latency_threshold_ms = $10, alert_after = $11,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $12 AND user_id = $13
+		RETURNING updated_at`
+
+	err := r.db.QueryRowContext(ctx, query, monitor.CollectionID, monitor.ItemID, monitor.EnvironmentID, monitor.Name,
+		monitor.Schedule, monitor.Timezone, monitor.Timeout, monitor.Enabled, monitor.NextRunAt,
+		monitor.LatencyThresholdMs, monitor.AlertAfter, monitor.ID, monitor.UserID).
+		Scan(&monitor.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Delete removes a monitor with its results. It reports whether the user
+// owned such a monitor.
+func (r *monitorRepository) Delete(ctx context.Context, id int, userID int) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM monitors WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// Trigger makes a monitor due now. It reports whether the user owns it.
+func (r *monitorRepository) Trigger(ctx context.Context, id int, userID int) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `UPDATE monitors SET next_run_at = CURRENT_TIMESTAMP WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// ClaimDue leases up to limit enabled monitors that are due. Rows another
+// replica is claiming at the same time are skipped rather than waited for,
+// and a lease that ran out, because the replica holding it died, can be
+// claimed again.
+func (r *monitorRepository) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]*model.Monitor, error) {
+	query := `
+		UPDATE monitors
+		SET lease_until = CURRENT_TIMESTAMP + make_interval(secs => $1)
+		WHERE id IN (
+			SELECT id FROM monitors
+			WHERE enabled AND next_run_at <= CURRENT_TIMESTAMP
+				AND (lease_until IS NULL OR lease_until < CURRENT_TIMESTAMP)
+			ORDER BY next_run_at ASC
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + monitorColumns
+
+	rows, err := r.db.QueryContext(ctx, query, lease.Seconds(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var monitors []*model.Monitor
+	for rows.Next() {
+		monitor, err := scanMonitor(rows)
+		if err != nil {
+			return nil, err
+		}
+		monitors = append(monitors, monitor)
+	}
+	return monitors, rows.Err()
+}
+
+// Complete stores the result of a check, the alert state and next run of
+// its monitor, and gives up the lease. When the check changed the alert
+// state, event is queued for every enabled alert of the monitor; the dedup
+// key keeps an event from being queued twice for the same incident. Results
+// past the retention period are removed on the way.
+func (r *monitorRepository) Complete(ctx context.Context, monitor *model.Monitor, result *model.MonitorResult, event *model.AlertEvent, dedupKey string) error {
+	results := result.Results
+	if results == nil {
+		results = []model.RunItemResult{}
+	}
+	resultsJSON, err := json.Marshal(results)
+	if err != nil {
+		return err
+	}
+	failures := result.Failures
+	if failures == nil {
+		failures = []string{}
+	}
+	failuresJSON, err := json.Marshal(failures)
+	if err != nil {
+		return err
+	}
+	var resultError sql.NullString
+	if result.Error != "" {
+		resultError = sql.NullString{String: result.Error, Valid: true}
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO monitor_results (monitor_id, status, requests, failed, assertions, assertions_failed, latency_ms, results, failures, error, started_at, finished_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING id`,
+		result.MonitorID, result.Status, result.Requests, result.Failed, result.Assertions, result.AssertionsFailed,
+		result.LatencyMs, resultsJSON, failuresJSON, resultError, result.StartedAt, result.FinishedAt).
+		Scan(&result.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE monitors
+		SET next_run_at = $1, lease_until = NULL, last_run_at = $2, last_status = $3,
+			alert_state = $4, consecutive_failures = $5, failing_since = $6
+		WHERE id = $7`,
+		monitor.NextRunAt, result.StartedAt, result.Status, monitor.AlertState, monitor.ConsecutiveFailures,
+		monitor.FailingSince, monitor.ID)
+	if err != nil {
+		return err
+	}
+
+	if event != nil {
+		event.ResultID = result.ID
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO alert_deliveries (alert_id, monitor_id, event, dedup_key, payload)
+			SELECT id, monitor_id, $2, $3, $4 FROM monitor_alerts WHERE monitor_id = $1 AND enabled
+			ON CONFLICT (alert_id, dedup_key) DO NOTHING`,
+			monitor.ID, event.Event, dedupKey, payload)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM monitor_results WHERE monitor_id = $1 AND started_at < $2`,
+		result.MonitorID, time.Now().Add(-monitorResultRetention))
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Release gives up the lease of a monitor without recording a check, so it
+// is picked up again as soon as possible.
+func (r *monitorRepository) Release(ctx context.Context, id int) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE monitors SET lease_until = NULL WHERE id = $1`, id)
+	return err
+}
+
+// GetResults lists the latest results of a monitor, newest first.
+func (r *monitorRepository) GetResults(ctx context.Context, monitorID int, limit int) ([]*model.MonitorResult, error) {
+	query := `
+		SELECT id, monitor_id, status, requests, failed, assertions, assertions_failed, latency_ms, results, failures, error, started_at, finished_at
+		FROM monitor_results
+		WHERE monitor_id = $1
+		ORDER BY started_at DESC, id DESC
+		LIMIT $2`
+
+	rows, err := r.db.QueryContext(ctx, query, monitorID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []*model.MonitorResult
+	for rows.Next() {
+		var result model.MonitorResult
+		var items, failures []byte
+		var resultError sql.NullString
+		if err := rows.Scan(&result.ID, &result.MonitorID, &result.Status, &result.Requests, &result.Failed, &result.Assertions,
+			&result.AssertionsFailed, &result.LatencyMs, &items, &failures, &resultError, &result.StartedAt, &result.FinishedAt); err != nil {
+			return nil, err
+		}
+		if err := scanJSON(items, &result.Results); err != nil {
+			return nil, err
+		}
+		if err := scanJSON(failures, &result.Failures); err != nil {
+			return nil, err
+		}
+		result.Error = resultError.String
+		results = append(results, &result)
+	}
+	return results, rows.Err()
+}
+
+// GetStats aggregates the results of a monitor since a point in time, with
+// a series bucketed by the given date_trunc unit.
+func (r *monitorRepository) GetStats(ctx context.Context, monitorID int, since time.Time, bucket string) (*model.DTOMonitorStats, error) {
+	stats := model.DTOMonitorStats{From: since, Bucket: bucket, Series: []model.DTOMonitorStatsBin{}}
+	var avg, p50, p90, p95, p99 sql.NullFloat64
+	err := r.db.QueryRowContext(ctx, `
+		SELECT COUNT(*),
+			COUNT(*) FILTER (WHERE status = $3),
+			COUNT(*) FILTER (WHERE status = $4),
+			COUNT(*) FILTER (WHERE status = $5),
+			COALESCE(SUM(assertions), 0),
+			COALESCE(SUM(assertions_failed), 0),
+			AVG(latency_ms)::FLOAT8 FILTER (WHERE status <> $5),
+			percentile_cont(0.5) WITHIN GROUP (ORDER BY latency_ms) FILTER (WHERE status <> $5),
+			percentile_cont(0.9) WITHIN GROUP (ORDER BY latency_ms) FILTER (WHERE status <> $5),
+			percentile_cont(0.95) WITHIN GROUP (ORDER BY latency_ms) FILTER (WHERE status <> $5),
+			percentile_cont(0.99) WITHIN GROUP (ORDER BY latency_ms) FILTER (WHERE status <> $5)
+		FROM monitor_results
+		WHERE monitor_id = $1 AND started_at >= $2`,
+		monitorID, since, model.MonitorStatusUp, model.MonitorStatusDown, model.MonitorStatusError).
+		Scan(&stats.Checks, &stats.Up, &stats.Down, &stats.Errors, &stats.Assertions, &stats.AssertionsFailed,
+			&avg, &p50, &p90, &p95, &p99)
+	if err != nil {
+		return nil, err
+	}
+	stats.Latency = model.DTOLatencyStats{
+		Avg: nullFloat(avg),
+		P50: nullFloat(p50),
+		P90: nullFloat(p90),
+		P95: nullFloat(p95),
+		P99: nullFloat(p99),
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT date_trunc($3, started_at AT TIME ZONE 'UTC'),
+			COUNT(*),
+			COUNT(*) FILTER (WHERE status = $4),
+			COALESCE(SUM(assertions_failed), 0),
+			percentile_cont(0.95) WITHIN GROUP (ORDER BY latency_ms) FILTER (WHERE status <> $5)
+		FROM monitor_results
+		WHERE monitor_id = $1 AND started_at >= $2
+		GROUP BY 1
+		ORDER BY 1`,
+		monitorID, since, bucket, model.MonitorStatusUp, model.MonitorStatusError)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var bin model.DTOMonitorStatsBin
+		var p95 sql.NullFloat64
+		if err := rows.Scan(&bin.Start, &bin.Checks, &bin.Up, &bin.AssertionsFailed, &p95); err != nil {
+			return nil, err
+		}
+		bin.LatencyP95 = nullFloat(p95)
+		stats.Series = append(stats.Series, bin)
+	}
+	return &stats, rows.Err()
+}
+
+func nullFloat(value sql.NullFloat64) *float64 {
+	if !value.Valid {
+		return nil
+	}
+	return &value.Float64
+}
@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/suar-net/suar-be/internal/model"
 )
@@ -10,22 +11,142 @@ import (
 type IUserRepository interface {
 	Create(ctx context.Context, user *model.User) (int, error)
 	GetByEmail(ctx context.Context, email string) (*model.User, error)
+	CreateTicket(ctx context.Context, ticketHash string, userID int, expiresAt time.Time) error
+	ConsumeTicket(ctx context.Context, ticketHash string) (*model.User, error)
 }
 
 type IRequestRepository interface {
 	Create(ctx context.Context, request *model.Request) error
+	CreateMany(ctx context.Context, requests []*model.Request) error
 	GetByUserID(ctx context.Context, userID int) ([]*model.Request, error)
+	GetByID(ctx context.Context, id int, userID int) (*model.Request, error)
+	GetByIDs(ctx context.Context, ids []int, userID int) ([]*model.Request, error)
+}
+
+type ICollectionRepository interface {
+	Create(ctx context.Context, collection *model.Collection) (int, error)
+	GetByUserID(ctx context.Context, userID int) ([]*model.Collection, error)
+	GetByID(ctx context.Context, id int, userID int) (*model.Collection, error)
+	Delete(ctx context.Context, id int, userID int) (bool, error)
+	AddItem(ctx context.Context, collectionID, userID int, item *model.CollectionItem) (bool, error)
+	SetItemContract(ctx context.Context, collectionID, itemID, userID int, contract *model.Contract) (bool, error)
+	SetItemExtractions(ctx context.Context, collectionID, itemID, userID int, extractions []model.Extraction) (bool, error)
+	SetItemExamples(ctx context.Context, collectionID, itemID, userID int, examples []model.ResponseExample) (bool, error)
+	MergeVariables(ctx context.Context, id int, userID int, merge func([]model.Variable) []model.Variable) (bool, error)
+}
+
+type IEnvironmentRepository interface {
+	Create(ctx context.Context, environment *model.Environment) (int, error)
+	GetByUserID(ctx context.Context, userID int) ([]*model.Environment, error)
+	GetByID(ctx context.Context, id int, userID int) (*model.Environment, error)
+	Delete(ctx context.Context, id int, userID int) (bool, error)
+	MergeVariables(ctx context.Context, id int, userID int, merge func([]model.Variable) []model.Variable) (bool, error)
+}
+
+type IAPISpecRepository interface {
+	Create(ctx context.Context, spec *model.APISpec) (int, error)
+	GetByUserID(ctx context.Context, userID int) ([]*model.APISpec, error)
+	GetByID(ctx context.Context, id int, userID int) (*model.APISpec, error)
+	Delete(ctx context.Context, id int, userID int) (bool, error)
+}
+
+type ICollectionRunRepository interface {
+	Create(ctx context.Context, run *model.CollectionRun, limit int, staleBefore time.Time) (bool, error)
+	Update(ctx context.Context, run *model.CollectionRun) (bool, error)
+	Heartbeat(ctx context.Context, id int) (bool, error)
+	FailStale(ctx context.Context, staleBefore time.Time, message string) (int64, error)
+	GetByUserID(ctx context.Context, userID int, collectionID *int) ([]*model.CollectionRun, error)
+	GetByID(ctx context.Context, id int, userID int) (*model.CollectionRun, error)
+	RequestCancel(ctx context.Context, id int, userID int) (bool, error)
+}
+
+type IMonitorRepository interface {
+	Create(ctx context.Context, monitor *model.Monitor) (int, error)
+	CountByUserID(ctx context.Context, userID int) (int, error)
+	GetByUserID(ctx context.Context, userID int) ([]*model.Monitor, error)
+	GetByID(ctx context.Context, id int, userID int) (*model.Monitor, error)
+	Update(ctx context.Context, monitor *model.Monitor) (bool, error)
+	Delete(ctx context.Context, id int, userID int) (bool, error)
+	Trigger(ctx context.Context, id int, userID int) (bool, error)
+	ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]*model.Monitor, error)
+	Complete(ctx context.Context, monitor *model.Monitor, result *model.MonitorResult, event *model.AlertEvent, dedupKey string) error
+	Release(ctx context.Context, id int) error
+	GetResults(ctx context.Context, monitorID int, limit int) ([]*model.MonitorResult, error)
+	GetStats(ctx context.Context, monitorID int, since time.Time, bucket string) (*model.DTOMonitorStats, error)
+}
+
+type IMonitorAlertRepository interface {
+	Create(ctx context.Context, alert *model.MonitorAlert) (int, error)
+	GetByMonitorID(ctx context.Context, monitorID int) ([]*model.MonitorAlert, error)
+	GetByID(ctx context.Context, id int, monitorID int) (*model.MonitorAlert, error)
+	Update(ctx context.Context, alert *model.MonitorAlert) (bool, error)
+	Delete(ctx context.Context, id int, monitorID int) (bool, error)
+	ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*model.AlertDelivery, error)
+	MarkSent(ctx context.Context, id int64) error
+	MarkFailed(ctx context.Context, id int64, message string, retryAt *time.Time) error
+	GetDeliveries(ctx context.Context, monitorID int, limit int) ([]*model.AlertDelivery, error)
+}
+
+type IMockRepository interface {
+	Create(ctx context.Context, mock *model.MockServer) error
+	CountByUserID(ctx context.Context, userID int) (int, error)
+	GetByUserID(ctx context.Context, userID int) ([]*model.MockServer, error)
+	GetByID(ctx context.Context, id string, userID int) (*model.MockServer, error)
+	GetPublic(ctx context.Context, id string) (*model.MockServer, error)
+	Update(ctx context.Context, mock *model.MockServer) (bool, error)
+	Delete(ctx context.Context, id string, userID int) (bool, error)
+	CreateHit(ctx context.Context, hit *model.MockHit) error
+	GetHits(ctx context.Context, mockID string, limit int) ([]*model.MockHit, error)
+}
+
+type IRequestBinRepository interface {
+	Create(ctx context.Context, bin *model.RequestBin) error
+	CountByUserID(ctx context.Context, userID int) (int, error)
+	GetByUserID(ctx context.Context, userID int) ([]*model.RequestBin, error)
+	GetByID(ctx context.Context, id string, userID int) (*model.RequestBin, error)
+	GetPublic(ctx context.Context, id string) (*model.RequestBin, error)
+	Update(ctx context.Context, bin *model.RequestBin) (bool, error)
+	Delete(ctx context.Context, id string, userID int) (bool, error)
+	CreateCapture(ctx context.Context, capture *model.CapturedRequest) error
+	GetCaptures(ctx context.Context, binID string, limit int) ([]*model.CapturedRequest, error)
+	GetCapturesAfter(ctx context.Context, binID string, afterID int64, limit int) ([]*model.CapturedRequest, error)
+	LatestCaptureID(ctx context.Context, binID string) (int64, error)
+	ClearCaptures(ctx context.Context, binID string) error
+}
+
+type ILoadTestRepository interface {
+	Create(ctx context.Context, test *model.LoadTest, userLimit int, staleBefore time.Time) (bool, bool, error)
+	Heartbeat(ctx context.Context, id int64) error
+	Delete(ctx context.Context, id int64) error
 }
 
 type Repository struct {
-	userRepo    IUserRepository
-	requestRepo IRequestRepository
+	userRepo        IUserRepository
+	requestRepo     IRequestRepository
+	collectionRepo  ICollectionRepository
+	environmentRepo IEnvironmentRepository
+	apiSpecRepo     IAPISpecRepository
+	runRepo         ICollectionRunRepository
+	monitorRepo     IMonitorRepository
+	alertRepo       IMonitorAlertRepository
+	mockRepo        IMockRepository
+	binRepo         IRequestBinRepository
+	loadTestRepo    ILoadTestRepository
 }
 
 func NewRepository(db *sql.DB) *Repository {
 	return &Repository{
-		userRepo:    NewUserRepository(db),
-		requestRepo: NewRequestRepository(db),
+		userRepo:        NewUserRepository(db),
+		requestRepo:     NewRequestRepository(db),
+		collectionRepo:  NewCollectionRepository(db),
+		environmentRepo: NewEnvironmentRepository(db),
+		apiSpecRepo:     NewAPISpecRepository(db),
+		runRepo:         NewCollectionRunRepository(db),
+		monitorRepo:     NewMonitorRepository(db),
+		alertRepo:       NewMonitorAlertRepository(db),
+		mockRepo:        NewMockRepository(db),
+		binRepo:         NewRequestBinRepository(db),
+		loadTestRepo:    NewLoadTestRepository(db),
 	}
 }
 
@@ -36,3 +157,39 @@ func (r *Repository) UserRepo() IUserRepository {
 func (r *Repository) RequestRepo() IRequestRepository {
 	return r.requestRepo
 }
+
+func (r *Repository) CollectionRepo() ICollectionRepository {
+	return r.collectionRepo
+}
+
+func (r *Repository) EnvironmentRepo() IEnvironmentRepository {
+	return r.environmentRepo
+}
+
+func (r *Repository) APISpecRepo() IAPISpecRepository {
+	return r.apiSpecRepo
+}
+
+func (r *Repository) RunRepo() ICollectionRunRepository {
+	return r.runRepo
+}
+
+func (r *Repository) MonitorRepo() IMonitorRepository {
+	return r.monitorRepo
+}
+
+func (r *Repository) AlertRepo() IMonitorAlertRepository {
+	return r.alertRepo
+}
+
+func (r *Repository) MockRepo() IMockRepository {
+	return r.mockRepo
+}
+
+func (r *Repository) BinRepo() IRequestBinRepository {
+	return r.binRepo
+}
+
+func (r *Repository) LoadTestRepo() ILoadTestRepository {
+	return r.loadTestRepo
+}
@@ -0,0 +1,227 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/suar-net/suar-be/internal/model"
+)
+
+// maxBinRequests is how many captured requests are kept per bin, older ones
+// are removed as new ones come in.
+const maxBinRequests = 500
+
+type requestBinRepository struct {
+	db *sql.DB
+}
+
+func NewRequestBinRepository(db *sql.DB) IRequestBinRepository {
+	return &requestBinRepository{db: db}
+}
+
+// binColumns is the column list read by every bin query, in the order
+// scanBin expects.
+const binColumns = `id, user_id, name, response, created_at, updated_at`
+
+func scanBin(row rowScanner) (*model.RequestBin, error) {
+	var bin model.RequestBin
+	var response []byte
+	if err := row.Scan(&bin.ID, &bin.UserID, &bin.Name, &response, &bin.CreatedAt, &bin.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := scanJSON(response, &bin.Response); err != nil {
+		return nil, err
+	}
+	return &bin, nil
+}
+
+func (r *requestBinRepository) Create(ctx context.Context, bin *model.RequestBin) error {
+	response, err := json.Marshal(bin.Response)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO request_bins (id, user_id, name, response)
+		VALUES ($1, $2, $3, $4)
+		RETURNING created_at, updated_at`
+
+	return r.db.QueryRowContext(ctx, query, bin.ID, bin.UserID, bin.Name, response).Scan(&bin.CreatedAt, &bin.UpdatedAt)
+}
+
+func (r *requestBinRepository) CountByUserID(ctx context.Context, userID int) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM request_bins WHERE user_id = $1`, userID).Scan(&count)
+	return count, err
+}
+
+func (r *requestBinRepository) GetByUserID(ctx context.Context, userID int) ([]*model.RequestBin, error) {
+	query := `SELECT ` + binColumns + ` FROM request_bins WHERE user_id = $1 ORDER BY name ASC, created_at ASC`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var bins []*model.RequestBin
+	for rows.Next() {
+		bin, err := scanBin(rows)
+		if err != nil {
+			return nil, err
+		}
+		bins = append(bins, bin)
+	}
+	return bins, rows.Err()
+}
+
+// GetByID returns a bin, or nil when the user has no such bin.
+func (r *requestBinRepository) GetByID(ctx context.Context, id string, userID int) (*model.RequestBin, error) {
+	query := `SELECT ` + binColumns + ` FROM request_bins WHERE id = $1 AND user_id = $2`
+	return r.getBin(ctx, query, id, userID)
+}
+
+// GetPublic returns a bin whoever owns it, or nil when there is no such
+// bin. It serves the public capture endpoints.
+func (r *requestBinRepository) GetPublic(ctx context.Context, id string) (*model.RequestBin, error) {
+	query := `SELECT ` + binColumns + ` FROM request_bins WHERE id = $1`
+	return r.getBin(ctx, query, id)
+}
+
+func (r *requestBinRepository) getBin(ctx context.Context, query string, args ...any) (*model.RequestBin, error) {
+	bin, err := scanBin(r.db.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return bin, nil
+}
+
+// Update replaces the settings of a bin and reports whether the user owns
+// it.
+func (r *requestBinRepository) Update(ctx context.Context, bin *model.RequestBin) (bool, error) {
+	response, err := json.Marshal(bin.Response)
+	if err != nil {
+		return false, err
+	}
+
+	query := `
+		UPDATE request_bins
+		SET name = $1, response = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $3 AND user_id = $4
+		RETURNING updated_at`
+
+	err = r.db.QueryRowContext(ctx, query, bin.Name, response, bin.ID, bin.UserID).Scan(&bin.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Delete removes a bin with its captured requests. It reports whether the
+// user owned such a bin.
+func (r *requestBinRepository) Delete(ctx context.Context, id string, userID int) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM request_bins WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// CreateCapture records a request sent to a bin and drops the requests past
+// the latest maxBinRequests.
+func (r *requestBinRepository) CreateCapture(ctx context.Context, capture *model.CapturedRequest) error {
+	headers := capture.Headers
+	if headers == nil {
+		headers = []model.KeyValue{}
+	}
+	headersJSON, err := json.Marshal(headers)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO bin_requests (bin_id, method, path, query, headers, body, body_encoding, size, truncated, source_ip)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, created_at`
+
+	err = r.db.QueryRowContext(ctx, query, capture.BinID, capture.Method, capture.Path, nullString(capture.Query),
+		headersJSON, nullString(capture.Body), nullString(capture.BodyEncoding), capture.Size, capture.Truncated,
+		nullString(capture.SourceIP)).
+		Scan(&capture.ID, &capture.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+		DELETE FROM bin_requests
+		WHERE bin_id = $1 AND id <= (
+			SELECT id FROM bin_requests WHERE bin_id = $1 ORDER BY id DESC OFFSET $2 LIMIT 1
+		)`, capture.BinID, maxBinRequests)
+	return err
+}
+
+// GetCaptures lists the latest requests of a bin, newest first.
+func (r *requestBinRepository) GetCaptures(ctx context.Context, binID string, limit int) ([]*model.CapturedRequest, error) {
+	query := `SELECT ` + captureColumns + ` FROM bin_requests WHERE bin_id = $1 ORDER BY id DESC LIMIT $2`
+	return r.getCaptures(ctx, query, binID, limit)
+}
+
+// GetCapturesAfter lists the requests of a bin recorded after the one with
+// the given ID, oldest first.
+func (r *requestBinRepository) GetCapturesAfter(ctx context.Context, binID string, afterID int64, limit int) ([]*model.CapturedRequest, error) {
+	query := `SELECT ` + captureColumns + ` FROM bin_requests WHERE bin_id = $1 AND id > $2 ORDER BY id ASC LIMIT $3`
+	return r.getCaptures(ctx, query, binID, afterID, limit)
+}
+
+// LatestCaptureID returns the ID of the latest request of a bin, 0 when it
+// has none.
+func (r *requestBinRepository) LatestCaptureID(ctx context.Context, binID string) (int64, error) {
+	var id int64
+	err := r.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(id), 0) FROM bin_requests WHERE bin_id = $1`, binID).Scan(&id)
+	return id, err
+}
+
+// ClearCaptures removes every request a bin recorded.
+func (r *requestBinRepository) ClearCaptures(ctx context.Context, binID string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM bin_requests WHERE bin_id = $1`, binID)
+	return err
+}
+
+const captureColumns = `id, bin_id, method, path, query, headers, body, body_encoding, size, truncated, source_ip, created_at`
+
+func (r *requestBinRepository) getCaptures(ctx context.Context, query string, args ...any) ([]*model.CapturedRequest, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var captures []*model.CapturedRequest
+	for rows.Next() {
+		var capture model.CapturedRequest
+		var query, body, encoding, sourceIP sql.NullString
+		var headers []byte
+		if err := rows.Scan(&capture.ID, &capture.BinID, &capture.Method, &capture.Path, &query, &headers, &body,
+			&encoding, &capture.Size, &capture.Truncated, &sourceIP, &capture.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := scanJSON(headers, &capture.Headers); err != nil {
+			return nil, err
+		}
+		capture.Query, capture.Body = query.String, body.String
+		capture.BodyEncoding, capture.SourceIP = encoding.String, sourceIP.String
+		captures = append(captures, &capture)
+	}
+	return captures, rows.Err()
+}
@@ -3,6 +3,8 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"time"
 
 	"github.com/suar-net/suar-be/internal/model"
 )
@@ -15,34 +17,131 @@ func NewRequestRepository(db *sql.DB) IRequestRepository {
 	return &requestRepository{db: db}
 }
 
-func (r *requestRepository) Create(ctx context.Context, request *model.Request) error {
+// requestColumns is the column list read by every history query, in the
+// order scanRequest expects.
+const requestColumns = `id, user_id, request_type, executed_at, request_method, request_url, request_headers, request_body, response_status_code, response_headers, response_body, response_size, duration_ms, session_transcript, assertion_results, timings`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanRequest(row rowScanner) (*model.Request, error) {
+	var req model.Request
+	err := row.Scan(
+		&req.ID,
+		&req.UserID,
+		&req.RequestType,
+		&req.ExecutedAt,
+		&req.RequestMethod,
+		&req.RequestURL,
+		&req.RequestHeaders,
+		&req.RequestBody,
+		&req.ResponseStatusCode,
+		&req.ResponseHeaders,
+		&req.ResponseBody,
+		&req.ResponseSize,
+		&req.DurationMs,
+		// Scan through *[]byte so a NULL transcript becomes nil instead of an error.
+		(*[]byte)(&req.SessionTranscript),
+		(*[]byte)(&req.AssertionResults),
+		(*[]byte)(&req.Timings),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// insertRequest stores request and sets request.ID to the new row's id.
+func insertRequest(ctx context.Context, db execer, request *model.Request) error {
 	query := `
-		INSERT INTO request_history (user_id, request_method, request_url, request_headers, request_body, response_status_code, response_headers, response_body, response_size, duration_ms)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+		INSERT INTO request_history (user_id, request_type, request_method, request_url, request_headers, request_body, response_status_code, response_headers, response_body, response_size, duration_ms, session_transcript, assertion_results, timings, executed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, COALESCE($15, CURRENT_TIMESTAMP))
+		RETURNING id`
 
-	_, err := r.db.ExecContext(ctx, query,
+	requestType := request.RequestType
+	if requestType == "" {
+		requestType = model.RequestTypeHTTP
+	}
+	// A zero ExecutedAt means now, imported entries keep their original time.
+	var executedAt *time.Time
+	if !request.ExecutedAt.IsZero() {
+		executedAt = &request.ExecutedAt
+	}
+
+	return db.QueryRowContext(ctx, query,
 		request.UserID,
+		requestType,
 		request.RequestMethod,
 		request.RequestURL,
-		request.RequestHeaders,
+		nullableJSON(request.RequestHeaders),
 		request.RequestBody,
 		request.ResponseStatusCode,
-		request.ResponseHeaders,
+		nullableJSON(request.ResponseHeaders),
 		request.ResponseBody,
 		request.ResponseSize,
 		request.DurationMs,
-	)
-	return err
+		nullableJSON(request.SessionTranscript),
+		nullableJSON(request.AssertionResults),
+		nullableJSON(request.Timings),
+		executedAt,
+	).Scan(&request.ID)
+}
+
+func (r *requestRepository) Create(ctx context.Context, request *model.Request) error {
+	return insertRequest(ctx, r.db, request)
+}
+
+// CreateMany inserts all requests in one transaction, either every entry is
+// stored or none is.
+func (r *requestRepository) CreateMany(ctx context.Context, requests []*model.Request) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, request := range requests {
+		if err := insertRequest(ctx, tx, request); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
 }
 
 func (r *requestRepository) GetByUserID(ctx context.Context, userID int) ([]*model.Request, error) {
 	query := `
-		SELECT id, user_id, executed_at, request_method, request_url, request_headers, request_body, response_status_code, response_headers, response_body, response_size, duration_ms
+		SELECT ` + requestColumns + `
 		FROM request_history
 		WHERE user_id = $1
 		ORDER BY executed_at DESC`
 
-	rows, err := r.db.QueryContext(ctx, query, userID)
+	return r.queryRequests(ctx, query, userID)
+}
+
+// GetByIDs returns the history entries among ids that are owned by userID,
+// oldest first. Unknown ids are left out.
+func (r *requestRepository) GetByIDs(ctx context.Context, ids []int, userID int) ([]*model.Request, error) {
+	query := `
+		SELECT ` + requestColumns + `
+		FROM request_history
+		WHERE id = ANY($1) AND user_id = $2
+		ORDER BY executed_at ASC, id ASC`
+
+	int64IDs := make([]int64, len(ids))
+	for i, id := range ids {
+		int64IDs[i] = int64(id)
+	}
+	return r.queryRequests(ctx, query, int64IDs, userID)
+}
+
+func (r *requestRepository) queryRequests(ctx context.Context, query string, args ...any) ([]*model.Request, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -50,25 +149,40 @@ func (r *requestRepository) GetByUserID(ctx context.Context, userID int) ([]*mod
 
 	var requests []*model.Request
 	for rows.Next() {
-		var req model.Request
-		if err := rows.Scan(
-			&req.ID,
-			&req.UserID,
-			&req.ExecutedAt,
-			&req.RequestMethod,
-			&req.RequestURL,
-			&req.RequestHeaders,
-			&req.RequestBody,
-			&req.ResponseStatusCode,
-			&req.ResponseHeaders,
-			&req.ResponseBody,
-			&req.ResponseSize,
-			&req.DurationMs,
-		); err != nil {
+		req, err := scanRequest(rows)
+		if err != nil {
 			return nil, err
 		}
-		requests = append(requests, &req)
+		requests = append(requests, req)
 	}
 
-	return requests, nil
+	return requests, rows.Err()
+}
+
+// GetByID returns a history entry owned by userID, or nil when there is no
+// such entry.
+func (r *requestRepository) GetByID(ctx context.Context, id int, userID int) (*model.Request, error) {
+	query := `
+		SELECT ` + requestColumns + `
+		FROM request_history
+		WHERE id = $1 AND user_id = $2`
+
+	req, err := scanRequest(r.db.QueryRowContext(ctx, query, id, userID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// nullableJSON stores an empty json.RawMessage as NULL instead of an invalid
+// empty JSONB value.
+func nullableJSON(data json.RawMessage) interface{} {
+	if len(data) == 0 {
+		return nil
+	}
+	return []byte(data)
 }
@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/suar-net/suar-be/internal/model"
 )
@@ -56,3 +57,35 @@ func (r *userRepository) GetByEmail(ctx context.Context, email string) (*model.U
 	return &user, nil
 
 }
+
+// CreateTicket menyimpan tiket baru dan sekaligus membersihkan tiket yang sudah kedaluwarsa.
+func (r *userRepository) CreateTicket(ctx context.Context, ticketHash string, userID int, expiresAt time.Time) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM auth_tickets WHERE expires_at < NOW()`); err != nil {
+		return err
+	}
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO auth_tickets (ticket_hash, user_id, expires_at) VALUES ($1, $2, $3)`,
+		ticketHash, userID, expiresAt)
+	return err
+}
+
+// ConsumeTicket menghapus tiket dan mengembalikan pemiliknya. Tiket hanya bisa dipakai
+// sekali, juga jika dipakai bersamaan di beberapa replika. nil jika tiket tidak ada
+// atau sudah kedaluwarsa.
+func (r *userRepository) ConsumeTicket(ctx context.Context, ticketHash string) (*model.User, error) {
+	query := `
+		DELETE FROM auth_tickets t
+		USING users u
+		WHERE t.ticket_hash = $1 AND t.user_id = u.id AND t.expires_at > NOW()
+		RETURNING u.id, u.username, u.email`
+
+	var user model.User
+	err := r.db.QueryRowContext(ctx, query, ticketHash).Scan(&user.ID, &user.Username, &user.Email)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &user, nil
+}
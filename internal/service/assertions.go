@@ -0,0 +1,470 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/antchfx/xmlquery"
+	"github.com/antchfx/xpath"
+	"github.com/ohler55/ojg/jp"
+	"github.com/suar-net/suar-be/internal/model"
+)
+
+// assertionOperators lists the operators each source supports.
+var assertionOperators = map[string][]string{
+	model.AssertionSourceStatus: {
+		model.AssertionEquals, model.AssertionNotEquals, model.AssertionInRange,
+		model.AssertionLessThan, model.AssertionGreaterThan,
+	},
+	model.AssertionSourceHeader: {
+		model.AssertionExists, model.AssertionNotExists, model.AssertionEquals,
+		model.AssertionNotEquals, model.AssertionContains, model.AssertionMatches,
+	},
+	model.AssertionSourceJSONPath: {
+		model.AssertionExists, model.AssertionNotExists, model.AssertionEquals,
+		model.AssertionNotEquals, model.AssertionContains, model.AssertionMatches,
+		model.AssertionType, model.AssertionInRange, model.AssertionLessThan,
+		model.AssertionGreaterThan,
+	},
+	model.AssertionSourceXPath: {
+		model.AssertionExists, model.AssertionNotExists, model.AssertionEquals,
+		model.AssertionNotEquals, model.AssertionContains, model.AssertionMatches,
+		model.AssertionInRange, model.AssertionLessThan, model.AssertionGreaterThan,
+	},
+	model.AssertionSourceResponseTime: {
+		model.AssertionInRange, model.AssertionLessThan, model.AssertionGreaterThan,
+	},
+	model.AssertionSourceBodySize: {
+		model.AssertionInRange, model.AssertionLessThan, model.AssertionGreaterThan,
+	},
+}
+
+// jsonTypes are the names accepted by the type operator.
+var jsonTypes = []string{"string", "number", "integer", "boolean", "array", "object", "null"}
+
+// compiledAssertion is an assertion with its expression, pattern and
+// expected value parsed once before the request is sent.
+type compiledAssertion struct {
+	model.Assertion
+	expected any
+	pattern  *regexp.Regexp
+	jsonPath jp.Expr
+	xpath    *xpath.Expr
+}
+
+// compileAssertions checks that every assertion is complete and parses its
+// expressions, so mistakes are reported before the request is sent.
+func compileAssertions(assertions []model.Assertion) ([]*compiledAssertion, error) {
+	compiled := make([]*compiledAssertion, 0, len(assertions))
+	for i, assertion := range assertions {
+		c, err := compileAssertion(assertion)
+		if err != nil {
+			return nil, fmt.Errorf("%w: assertion %d: %v", ErrInvalidInput, i+1, err)
+		}
+		compiled = append(compiled, c)
+	}
+	return compiled, nil
+}
+
+func compileAssertion(assertion model.Assertion) (*compiledAssertion, error) {
+	operators, ok := assertionOperators[assertion.Source]
+	if !ok {
+		return nil, fmt.Errorf("unknown source %q", assertion.Source)
+	}
+	if !containsString(operators, assertion.Operator) {
+		return nil, fmt.Errorf("%s does not support %s, use one of %s", assertion.Source, assertion.Operator, strings.Join(operators, ", "))
+	}
+
+	c := &compiledAssertion{Assertion: assertion}
+	switch assertion.Source {
+	case model.AssertionSourceHeader:
+		if strings.TrimSpace(assertion.Property) == "" {
+			return nil, fmt.Errorf("property must name the header")
+		}
+	case model.AssertionSourceJSONPath:
+		expr, err := jp.ParseString(assertion.Property)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JSONPath %q: %v", assertion.Property, err)
+		}
+		c.jsonPath = expr
+	case model.AssertionSourceXPath:
+		expr, err := xpath.Compile(assertion.Property)
+		if err != nil {
+			return nil, fmt.Errorf("invalid XPath %q: %v", assertion.Property, err)
+		}
+		c.xpath = expr
+	}
+
+	switch assertion.Operator {
+	case model.AssertionExists, model.AssertionNotExists:
+		return c, nil
+	case model.AssertionInRange:
+		if assertion.Min == nil && assertion.Max == nil {
+			return nil, fmt.Errorf("in_range needs min, max or both")
+		}
+		return c, nil
+	}
+
+	if len(assertion.Value) == 0 {
+		return nil, fmt.Errorf("%s needs a value", assertion.Operator)
+	}
+	if err := json.Unmarshal(assertion.Value, &c.expected); err != nil {
+		return nil, fmt.Errorf("invalid value: %v", err)
+	}
+	switch assertion.Operator {
+	case model.AssertionLessThan, model.AssertionGreaterThan:
+		if _, ok := c.expected.(float64); !ok {
+			return nil, fmt.Errorf("%s needs a number", assertion.Operator)
+		}
+	case model.AssertionMatches:
+		pattern, ok := c.expected.(string)
+		if !ok {
+			return nil, fmt.Errorf("matches needs a regular expression string")
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression: %v", err)
+		}
+		c.pattern = re
+	case model.AssertionType:
+		name, _ := c.expected.(string)
+		if !containsString(jsonTypes, name) {
+			return nil, fmt.Errorf("type must be one of %s", strings.Join(jsonTypes, ", "))
+		}
+	}
+	return c, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, element := range list {
+		if element == s {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluateAssertions runs every assertion against a response. The body is
+// parsed at most once per format.
+func evaluateAssertions(assertions []*compiledAssertion, response *model.DTOResponse) []model.AssertionResult {
+	body := &assertionBody{response: response}
+	results := make([]model.AssertionResult, 0, len(assertions))
+	for _, assertion := range assertions {
+		results = append(results, assertion.evaluate(response, body))
+	}
+	return results
+}
+
+// assertionBody parses the response body lazily for JSONPath and XPath.
+type assertionBody struct {
+	response *model.DTOResponse
+
+	jsonParsed bool
+	json       any
+	jsonErr    error
+
+	xmlParsed bool
+	xml       *xmlquery.Node
+	xmlErr    error
+}
+
+func (b *assertionBody) check() error {
+	if b.response.Truncated {
+		return fmt.Errorf("the body was too large to be kept in full")
+	}
+	return nil
+}
+
+func (b *assertionBody) parseJSON() (any, error) {
+	if !b.jsonParsed {
+		b.jsonParsed = true
+		if b.jsonErr = b.check(); b.jsonErr == nil {
+			if err := json.Unmarshal(b.response.Body, &b.json); err != nil {
+				b.jsonErr = fmt.Errorf("the body is not valid JSON: %v", err)
+			}
+		}
+	}
+	return b.json, b.jsonErr
+}
+
+func (b *assertionBody) parseXML() (*xmlquery.Node, error) {
+	if !b.xmlParsed {
+		b.xmlParsed = true
+		if b.xmlErr = b.check(); b.xmlErr == nil {
+			doc, err := xmlquery.Parse(bytes.NewReader(b.response.Body))
+			if err != nil {
+				b.xmlErr = fmt.Errorf("the body is not valid XML: %v", err)
+			}
+			b.xml = doc
+		}
+	}
+	return b.xml, b.xmlErr
+}
+
+func (a *compiledAssertion) evaluate(response *model.DTOResponse, body *assertionBody) model.AssertionResult {
+	result := model.AssertionResult{Assertion: a.Assertion}
+	fail := func(format string, args ...any) model.AssertionResult {
+		result.Message = fmt.Sprintf(format, args...)
+		return result
+	}
+
+	var actual any
+	var found bool
+	switch a.Source {
+	case model.AssertionSourceStatus:
+		actual, found = float64(response.StatusCode), true
+	case model.AssertionSourceResponseTime:
+		actual, found = float64(response.Duration.Milliseconds()), true
+	case model.AssertionSourceBodySize:
+		actual, found = float64(response.Size), true
+	case model.AssertionSourceHeader:
+		values := http.Header(response.Headers).Values(a.Property)
+		actual, found = strings.Join(values, ", "), len(values) > 0
+	case model.AssertionSourceJSONPath:
+		data, err := body.parseJSON()
+		if err != nil {
+			return fail("%v", err)
+		}
+		matches := a.jsonPath.Get(data)
+		found = len(matches) > 0
+		// A path matching one value compares that value, wildcards and
+		// filters compare the list of matches.
+		if len(matches) == 1 {
+			actual = matches[0]
+		} else if found {
+			actual = matches
+		}
+	case model.AssertionSourceXPath:
+		doc, err := body.parseXML()
+		if err != nil {
+			return fail("%v", err)
+		}
+		actual, found = evaluateXPath(a.xpath, doc)
+	}
+	if found {
+		result.Actual = actual
+	}
+
+	target := a.Source
+	if a.Property != "" {
+		target += " " + a.Property
+	}
+	switch a.Operator {
+	case model.AssertionExists:
+		if !found {
+			return fail("expected %s to exist", target)
+		}
+	case model.AssertionNotExists:
+		if found {
+			return fail("expected %s not to exist", target)
+		}
+	default:
+		if !found {
+			return fail("%s was not found in the response", target)
+		}
+		if message := a.compare(actual); message != "" {
+			return fail("expected %s %s", target, message)
+		}
+	}
+	result.Passed = true
+	return result
+}
+
+// compare applies the operator and describes the expectation when it fails.
+func (a *compiledAssertion) compare(actual any) string {
+	expected := string(a.Value)
+	switch a.Operator {
+	case model.AssertionEquals:
+		if !assertionEqual(actual, a.expected) {
+			return fmt.Sprintf("to equal %s, got %s", expected, formatActual(actual))
+		}
+	case model.AssertionNotEquals:
+		if assertionEqual(actual, a.expected) {
+			return fmt.Sprintf("not to equal %s", expected)
+		}
+	case model.AssertionContains:
+		if !assertionContains(actual, a.expected) {
+			return fmt.Sprintf("to contain %s, got %s", expected, formatActual(actual))
+		}
+	case model.AssertionMatches:
+		text, ok := actual.(string)
+		if !ok {
+			text = formatActual(actual)
+		}
+		if !a.pattern.MatchString(text) {
+			return fmt.Sprintf("to match %s, got %s", expected, formatActual(actual))
+		}
+	case model.AssertionType:
+		if name := jsonTypeName(actual); name != a.expected && !(a.expected == "number" && name == "integer") {
+			return fmt.Sprintf("to be of type %s, got %s", a.expected, name)
+		}
+	case model.AssertionLessThan, model.AssertionGreaterThan, model.AssertionInRange:
+		number, ok := assertionNumber(actual)
+		if !ok {
+			return fmt.Sprintf("to be a number, got %s", formatActual(actual))
+		}
+		return a.compareNumber(number)
+	}
+	return ""
+}
+
+func (a *compiledAssertion) compareNumber(number float64) string {
+	actual := strconv.FormatFloat(number, 'f', -1, 64)
+	switch a.Operator {
+	case model.AssertionLessThan:
+		if limit := a.expected.(float64); number >= limit {
+			return fmt.Sprintf("to be less than %v, got %s", limit, actual)
+		}
+	case model.AssertionGreaterThan:
+		if limit := a.expected.(float64); number <= limit {
+			return fmt.Sprintf("to be greater than %v, got %s", limit, actual)
+		}
+	case model.AssertionInRange:
+		low, high := math.Inf(-1), math.Inf(1)
+		if a.Min != nil {
+			low = *a.Min
+		}
+		if a.Max != nil {
+			high = *a.Max
+		}
+		if number < low || number > high {
+			return fmt.Sprintf("to be between %v and %v, got %s", low, high, actual)
+		}
+	}
+	return ""
+}
+
+// evaluateXPath returns the text of the matched nodes, or the value of an
+// expression such as count(//item). A node set matching one node compares
+// that node's text.
+func evaluateXPath(expr *xpath.Expr, doc *xmlquery.Node) (any, bool) {
+	switch value := expr.Evaluate(xmlquery.CreateXPathNavigator(doc)).(type) {
+	case *xpath.NodeIterator:
+		var texts []any
+		for value.MoveNext() {
+			texts = append(texts, value.Current().Value())
+		}
+		switch len(texts) {
+		case 0:
+			return nil, false
+		case 1:
+			return texts[0], true
+		default:
+			return texts, true
+		}
+	case bool:
+		return value, value
+	default:
+		return value, true
+	}
+}
+
+// assertionEqual compares JSON values. Numbers compare by value and strings
+// read from headers or XML compare with the expected value's JSON text, so
+// "200" equals 200.
+func assertionEqual(actual, expected any) bool {
+	if actualNumber, ok := assertionNumber(actual); ok {
+		if expectedNumber, ok := assertionNumber(expected); ok {
+			return actualNumber == expectedNumber
+		}
+	}
+	if text, ok := actual.(string); ok {
+		if _, isString := expected.(string); !isString {
+			return text == formatActual(expected)
+		}
+	}
+	return reflect.DeepEqual(normalizeJSON(actual), normalizeJSON(expected))
+}
+
+// assertionContains checks for a substring, an array element or an object key.
+func assertionContains(actual, expected any) bool {
+	switch value := actual.(type) {
+	case string:
+		needle, ok := expected.(string)
+		if !ok {
+			needle = formatActual(expected)
+		}
+		return strings.Contains(value, needle)
+	case []any:
+		for _, element := range value {
+			if assertionEqual(element, expected) {
+				return true
+			}
+		}
+	case map[string]any:
+		key, ok := expected.(string)
+		if ok {
+			_, exists := value[key]
+			return exists
+		}
+	}
+	return false
+}
+
+// assertionNumber reads a number, also from numeric text.
+func assertionNumber(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case string:
+		number, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return number, err == nil
+	}
+	return 0, false
+}
+
+func jsonTypeName(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case int64, int:
+		return "integer"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// normalizeJSON round trips a value through JSON so values from different
+// decoders compare equal.
+func normalizeJSON(value any) any {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var normalized any
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return value
+	}
+	return normalized
+}
+
+func formatActual(value any) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
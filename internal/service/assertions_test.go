@@ -0,0 +1,179 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/suar-net/suar-be/internal/model"
+)
+
+func floatPtr(v float64) *float64 { return &v }
+
+func TestCompileAssertions(t *testing.T) {
+	tests := []struct {
+		name      string
+		assertion model.Assertion
+		wantErr   string
+	}{
+		{"status equals", model.Assertion{Source: "status", Operator: "equals", Value: json.RawMessage("200")}, ""},
+		{"unknown source", model.Assertion{Source: "cookie", Operator: "exists"}, "unknown source"},
+		{"unsupported operator", model.Assertion{Source: "status", Operator: "matches", Value: json.RawMessage(`"2.."`)}, "does not support"},
+		{"header without name", model.Assertion{Source: "header", Operator: "exists", Property: " "}, "property must name the header"},
+		{"bad JSONPath", model.Assertion{Source: "jsonpath", Operator: "exists", Property: "$.["}, "invalid JSONPath"},
+		{"bad XPath", model.Assertion{Source: "xpath", Operator: "exists", Property: "//["}, "invalid XPath"},
+		{"range without bounds", model.Assertion{Source: "body_size", Operator: "in_range"}, "needs min, max or both"},
+		{"range with one bound", model.Assertion{Source: "body_size", Operator: "in_range", Max: floatPtr(10)}, ""},
+		{"missing value", model.Assertion{Source: "status", Operator: "equals"}, "needs a value"},
+		{"invalid value", model.Assertion{Source: "status", Operator: "equals", Value: json.RawMessage("{")}, "invalid value"},
+		{"less than text", model.Assertion{Source: "response_time", Operator: "less_than", Value: json.RawMessage(`"fast"`)}, "needs a number"},
+		{"matches number", model.Assertion{Source: "header", Property: "A", Operator: "matches", Value: json.RawMessage("1")}, "regular expression string"},
+		{"bad pattern", model.Assertion{Source: "header", Property: "A", Operator: "matches", Value: json.RawMessage(`"("`)}, "invalid regular expression"},
+		{"unknown type", model.Assertion{Source: "jsonpath", Property: "$.a", Operator: "type", Value: json.RawMessage(`"date"`)}, "type must be one of"},
+		{"known type", model.Assertion{Source: "jsonpath", Property: "$.a", Operator: "type", Value: json.RawMessage(`"array"`)}, ""},
+	}
+	for _, tt := range tests {
+		_, err := compileAssertions([]model.Assertion{tt.assertion})
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%s: err = %v", tt.name, err)
+		case tt.wantErr != "" && (!errors.Is(err, ErrInvalidInput) || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestEvaluateAssertions(t *testing.T) {
+	jsonResponse := &model.DTOResponse{
+		StatusCode: 201,
+		Headers:    http.Header{"Content-Type": {"application/json"}, "X-Count": {"12"}},
+		Body:       []byte(`{"id": 7, "name": "Rex", "tags": ["a", "b"], "price": 9.5, "owner": null}`),
+		Size:       74,
+		Duration:   120 * time.Millisecond,
+	}
+	xmlResponse := &model.DTOResponse{
+		StatusCode: 200,
+		Body:       []byte(`<pets><pet id="1">Rex</pet><pet id="2">Tom</pet></pets>`),
+	}
+	tests := []struct {
+		name      string
+		response  *model.DTOResponse
+		assertion model.Assertion
+		passed    bool
+		message   string
+	}{
+		{"status equals", jsonResponse, model.Assertion{Source: "status", Operator: "equals", Value: json.RawMessage("201")}, true, ""},
+		{"status not equals", jsonResponse, model.Assertion{Source: "status", Operator: "equals", Value: json.RawMessage("200")}, false, "expected status to equal 200, got 201"},
+		{"status in range", jsonResponse, model.Assertion{Source: "status", Operator: "in_range", Min: floatPtr(200), Max: floatPtr(299)}, true, ""},
+		{"status out of range", jsonResponse, model.Assertion{Source: "status", Operator: "in_range", Max: floatPtr(200)}, false, "to be between -Inf and 200, got 201"},
+		{"response time", jsonResponse, model.Assertion{Source: "response_time", Operator: "less_than", Value: json.RawMessage("100")}, false, "to be less than 100, got 120"},
+		{"body size", jsonResponse, model.Assertion{Source: "body_size", Operator: "greater_than", Value: json.RawMessage("10")}, true, ""},
+		{"header exists ignoring case", jsonResponse, model.Assertion{Source: "header", Property: "x-count", Operator: "exists"}, true, ""},
+		{"header missing", jsonResponse, model.Assertion{Source: "header", Property: "X-Missing", Operator: "exists"}, false, "expected header X-Missing to exist"},
+		{"header not exists", jsonResponse, model.Assertion{Source: "header", Property: "X-Missing", Operator: "not_exists"}, true, ""},
+		{"header text equals number", jsonResponse, model.Assertion{Source: "header", Property: "X-Count", Operator: "equals", Value: json.RawMessage("12")}, true, ""},
+		{"header matches", jsonResponse, model.Assertion{Source: "header", Property: "Content-Type", Operator: "matches", Value: json.RawMessage(`"^application/"`)}, true, ""},
+		{"compare missing header", jsonResponse, model.Assertion{Source: "header", Property: "X-Missing", Operator: "equals", Value: json.RawMessage(`"a"`)}, false, "header X-Missing was not found in the response"},
+		{"jsonpath equals", jsonResponse, model.Assertion{Source: "jsonpath", Property: "$.name", Operator: "equals", Value: json.RawMessage(`"Rex"`)}, true, ""},
+		{"jsonpath number", jsonResponse, model.Assertion{Source: "jsonpath", Property: "$.id", Operator: "equals", Value: json.RawMessage("7.0")}, true, ""},
+		{"jsonpath contains element", jsonResponse, model.Assertion{Source: "jsonpath", Property: "$.tags", Operator: "contains", Value: json.RawMessage(`"b"`)}, true, ""},
+		{"jsonpath contains key", jsonResponse, model.Assertion{Source: "jsonpath", Property: "$", Operator: "contains", Value: json.RawMessage(`"price"`)}, true, ""},
+		{"jsonpath wildcard", jsonResponse, model.Assertion{Source: "jsonpath", Property: "$.tags[*]", Operator: "equals", Value: json.RawMessage(`["a","b"]`)}, true, ""},
+		{"jsonpath null exists", jsonResponse, model.Assertion{Source: "jsonpath", Property: "$.owner", Operator: "type", Value: json.RawMessage(`"null"`)}, true, ""},
+		{"integer is a number", jsonResponse, model.Assertion{Source: "jsonpath", Property: "$.id", Operator: "type", Value: json.RawMessage(`"number"`)}, true, ""},
+		{"number is not an integer", jsonResponse, model.Assertion{Source: "jsonpath", Property: "$.price", Operator: "type", Value: json.RawMessage(`"integer"`)}, false, "to be of type integer, got number"},
+		{"jsonpath not a number", jsonResponse, model.Assertion{Source: "jsonpath", Property: "$.name", Operator: "less_than", Value: json.RawMessage("5")}, false, `to be a number, got "Rex"`},
+		{"jsonpath not equals", jsonResponse, model.Assertion{Source: "jsonpath", Property: "$.name", Operator: "not_equals", Value: json.RawMessage(`"Rex"`)}, false, `not to equal "Rex"`},
+		{"jsonpath on xml", xmlResponse, model.Assertion{Source: "jsonpath", Property: "$.a", Operator: "exists"}, false, "the body is not valid JSON"},
+		{"xpath text", xmlResponse, model.Assertion{Source: "xpath", Property: "//pet[@id='2']", Operator: "equals", Value: json.RawMessage(`"Tom"`)}, true, ""},
+		{"xpath count", xmlResponse, model.Assertion{Source: "xpath", Property: "count(//pet)", Operator: "equals", Value: json.RawMessage("2")}, true, ""},
+		{"xpath several nodes", xmlResponse, model.Assertion{Source: "xpath", Property: "//pet", Operator: "contains", Value: json.RawMessage(`"Rex"`)}, true, ""},
+		{"xpath false boolean", xmlResponse, model.Assertion{Source: "xpath", Property: "count(//pet) > 5", Operator: "exists"}, false, ""},
+		{"xpath on json", jsonResponse, model.Assertion{Source: "xpath", Property: "//a", Operator: "exists"}, false, "the body is not valid XML"},
+		{"truncated body", &model.DTOResponse{Body: []byte("{}"), Truncated: true},
+			model.Assertion{Source: "jsonpath", Property: "$", Operator: "exists"}, false, "too large"},
+	}
+	for _, tt := range tests {
+		compiled, err := compileAssertions([]model.Assertion{tt.assertion})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		result := evaluateAssertions(compiled, tt.response)[0]
+		if result.Passed != tt.passed || !strings.Contains(result.Message, tt.message) {
+			t.Errorf("%s: result = %+v, want passed %v and message %q", tt.name, result, tt.passed, tt.message)
+		}
+	}
+}
+
+func TestAssertionEqual(t *testing.T) {
+	tests := []struct {
+		actual, expected any
+		want             bool
+	}{
+		{float64(200), float64(200), true},
+		{"200", float64(200), true},
+		{int64(3), float64(3), true},
+		{"true", true, true},
+		{"abc", "abc", true},
+		{"abc", "ABC", false},
+		{[]any{"a"}, []any{"a"}, true},
+		{map[string]any{"a": float64(1)}, map[string]any{"a": float64(1)}, true},
+		{nil, nil, true},
+		{nil, "null", false},
+	}
+	for _, tt := range tests {
+		if got := assertionEqual(tt.actual, tt.expected); got != tt.want {
+			t.Errorf("assertionEqual(%#v, %#v) = %v, want %v", tt.actual, tt.expected, got, tt.want)
+		}
+	}
+}
+
+func TestAssertionNumberAndType(t *testing.T) {
+	numbers := []struct {
+		value any
+		want  float64
+		ok    bool
+	}{
+		{float64(1.5), 1.5, true},
+		{int64(2), 2, true},
+		{" 3 ", 3, true},
+		{"three", 0, false},
+		{true, 0, false},
+	}
+	for _, tt := range numbers {
+		if got, ok := assertionNumber(tt.value); got != tt.want || ok != tt.ok {
+			t.Errorf("assertionNumber(%#v) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	types := map[string]any{
+		"null": nil, "string": "", "boolean": false, "integer": float64(2), "number": 2.5,
+		"array": []any{}, "object": map[string]any{},
+	}
+	for want, value := range types {
+		if got := jsonTypeName(value); got != want {
+			t.Errorf("jsonTypeName(%#v) = %q, want %q", value, got, want)
+		}
+	}
+}
+
+func TestAssertionResultsJSON(t *testing.T) {
+	compiled, _ := compileAssertions([]model.Assertion{{Name: "ok", Source: "status", Operator: "equals", Value: json.RawMessage("200")}})
+	results := evaluateAssertions(compiled, &model.DTOResponse{StatusCode: 200})
+	data, err := json.Marshal(results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded []model.AssertionResult
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	want := []model.AssertionResult{{Assertion: model.Assertion{Name: "ok", Source: "status", Operator: "equals", Value: json.RawMessage("200")}, Passed: true, Actual: float64(200)}}
+	if !reflect.DeepEqual(decoded, want) {
+		t.Errorf("results = %s", data)
+	}
+}
@@ -2,6 +2,9 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"time"
@@ -114,3 +117,43 @@ func (s *authService) ValidateToken(ctx context.Context, tokenString string) (*m
 
 	return claims, nil
 }
+
+// ticketTTL is how long a ticket can be redeemed. A client asks for one right
+// before opening the connection, so this only needs to cover a round trip.
+const ticketTTL = 30 * time.Second
+
+// IssueTicket creates a single-use ticket for userID. Tickets stand in for the
+// JWT on URLs, which end up in access logs and browser history, so only their
+// hash is stored and they expire quickly.
+func (s *authService) IssueTicket(ctx context.Context, userID int) (*model.DTOTicketResponse, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("failed to generate ticket: %w", err)
+	}
+	ticket := hex.EncodeToString(raw)
+	expiresAt := time.Now().Add(ticketTTL)
+	if err := s.userRepo.CreateTicket(ctx, hashTicket(ticket), userID, expiresAt); err != nil {
+		return nil, fmt.Errorf("failed to store ticket: %w", err)
+	}
+	return &model.DTOTicketResponse{Ticket: ticket, ExpiresAt: expiresAt}, nil
+}
+
+// RedeemTicket consumes ticket and returns the claims of its owner.
+func (s *authService) RedeemTicket(ctx context.Context, ticket string) (*model.Claims, error) {
+	if len(ticket) != 64 {
+		return nil, ErrTokenInvalid
+	}
+	user, err := s.userRepo.ConsumeTicket(ctx, hashTicket(ticket))
+	if err != nil {
+		return nil, fmt.Errorf("failed to redeem ticket: %w", err)
+	}
+	if user == nil {
+		return nil, ErrTokenInvalid
+	}
+	return &model.Claims{ID: user.ID, Username: user.Username, Email: user.Email}, nil
+}
+
+func hashTicket(ticket string) string {
+	sum := sha256.Sum256([]byte(ticket))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/suar-net/suar-be/internal/config"
+	"github.com/suar-net/suar-be/internal/model"
+	"github.com/suar-net/suar-be/internal/repository"
+)
+
+type fakeTicketRepo struct {
+	repository.IUserRepository
+	tickets map[string]time.Time
+}
+
+func (f *fakeTicketRepo) CreateTicket(ctx context.Context, ticketHash string, userID int, expiresAt time.Time) error {
+	f.tickets[ticketHash] = expiresAt
+	return nil
+}
+
+func (f *fakeTicketRepo) ConsumeTicket(ctx context.Context, ticketHash string) (*model.User, error) {
+	expiresAt, ok := f.tickets[ticketHash]
+	delete(f.tickets, ticketHash)
+	if !ok || time.Now().After(expiresAt) {
+		return nil, nil
+	}
+	return &model.User{ID: 3, Username: "ana"}, nil
+}
+
+func TestTickets(t *testing.T) {
+	repo := &fakeTicketRepo{tickets: map[string]time.Time{}}
+	s := NewAuthService(repo, config.JWTConfig{})
+	ctx := context.Background()
+
+	issued, err := s.IssueTicket(ctx, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, stored := repo.tickets[issued.Ticket]; stored {
+		t.Error("the ticket is stored in plain text")
+	}
+	if time.Until(issued.ExpiresAt) > ticketTTL {
+		t.Errorf("ticket expires at %v, later than the TTL", issued.ExpiresAt)
+	}
+
+	claims, err := s.RedeemTicket(ctx, issued.Ticket)
+	if err != nil || claims.ID != 3 || claims.Username != "ana" {
+		t.Fatalf("RedeemTicket = %+v, %v", claims, err)
+	}
+	for _, ticket := range []string{issued.Ticket, "", "short", issued.Ticket[:63] + "x"} {
+		if _, err := s.RedeemTicket(ctx, ticket); !errors.Is(err, ErrTokenInvalid) {
+			t.Errorf("RedeemTicket(%q) = %v, want ErrTokenInvalid", ticket, err)
+		}
+	}
+}
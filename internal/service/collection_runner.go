@@ -0,0 +1,695 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/suar-net/suar-be/internal/model"
+	"github.com/suar-net/suar-be/internal/repository"
+)
+
+// Variable scopes as scripts name them: pm.variables, pm.iterationData,
+// pm.environment, pm.collectionVariables and pm.globals.
+const (
+	scopeLocal       = "local"
+	scopeData        = "data"
+	scopeEnvironment = "environment"
+	scopeCollection  = "collection"
+	scopeGlobals     = "globals"
+)
+
+// maxVariableDepth bounds how often variables inside variable values are
+// resolved, which also stops variables that refer to each other.
+const maxVariableDepth = 10
+
+var variablePattern = regexp.MustCompile(`\{\{([^{}]+)\}\}`)
+
+// runVariables holds the variables of a run. A {{name}} is looked up in
+// local variables, iteration data, the environment, the collection and the
+// globals, in that order, like Postman does. Environment and collection
+// variables are saved once the run is done, the others last for the run.
+type runVariables struct {
+	mu          sync.Mutex
+	local       map[string]string
+	data        map[string]string
+	environment []model.Variable
+	collection  []model.Variable
+	globals     map[string]string
+
+	environmentChanges variableChanges
+	collectionChanges  variableChanges
+
+	// placeholdSecrets leaves {{name}} in place of the variables marked
+	// secret, for requests shown rather than sent.
+	placeholdSecrets bool
+}
+
+// variableChanges records which environment or collection variables a run
+// set or unset, so only those are written back over the stored ones, which
+// may have been edited while the run went on.
+type variableChanges struct {
+	cleared bool
+	keys    map[string]bool
+}
+
+func (c *variableChanges) mark(key string) {
+	if c.keys == nil {
+		c.keys = map[string]bool{}
+	}
+	c.keys[key] = true
+}
+
+func (c *variableChanges) clear() {
+	c.cleared, c.keys = true, nil
+}
+
+func (c *variableChanges) changed() bool {
+	return c.cleared || len(c.keys) > 0
+}
+
+// apply merges the variables of the run, current, into stored: the changed
+// ones take the value they have at the end of the run, or are removed when
+// the run unset them, the others keep the stored value.
+func (c *variableChanges) apply(stored, current []model.Variable) []model.Variable {
+	var merged []model.Variable
+	if !c.cleared {
+		for _, variable := range stored {
+			if c.keys[variable.Key] {
+				i := findVariable(current, variable.Key)
+				if i < 0 {
+					continue
+				}
+				variable.Value, variable.Disabled = current[i].Value, current[i].Disabled
+			}
+			merged = append(merged, variable)
+		}
+	}
+	for _, variable := range current {
+		if (c.cleared || c.keys[variable.Key]) && findVariable(merged, variable.Key) < 0 {
+			merged = append(merged, variable)
+		}
+	}
+	return merged
+}
+
+func newRunVariables(collection *model.Collection, environment *model.Environment) *runVariables {
+	v := &runVariables{
+		local:      map[string]string{},
+		data:       map[string]string{},
+		globals:    map[string]string{},
+		collection: append([]model.Variable(nil), collection.Variables...),
+	}
+	if environment != nil {
+		v.environment = append([]model.Variable(nil), environment.Variables...)
+	}
+	return v
+}
+
+// startIteration binds the row of data of the next iteration. Local
+// variables only last for an iteration.
+func (v *runVariables) startIteration(row map[string]string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.local = map[string]string{}
+	v.data = map[string]string{}
+	for key, value := range row {
+		v.data[key] = value
+	}
+}
+
+func findVariable(variables []model.Variable, key string) int {
+	for i, variable := range variables {
+		if variable.Key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+func (v *runVariables) get(scope, key string) (string, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.getLocked(scope, key)
+}
+
+func (v *runVariables) getLocked(scope, key string) (string, bool) {
+	switch scope {
+	case scopeLocal:
+		value, ok := v.local[key]
+		return value, ok
+	case scopeData:
+		value, ok := v.data[key]
+		return value, ok
+	case scopeGlobals:
+		value, ok := v.globals[key]
+		return value, ok
+	case scopeEnvironment, scopeCollection:
+		variables := v.environment
+		if scope == scopeCollection {
+			variables = v.collection
+		}
+		if i := findVariable(variables, key); i >= 0 && !variables[i].Disabled {
+			return variables[i].Value, true
+		}
+	}
+	return "", false
+}
+
+// secretLocked reports whether key resolves to an environment or collection
+// variable marked secret.
+func (v *runVariables) secretLocked(key string) bool {
+	for _, scope := range []string{scopeLocal, scopeData, scopeEnvironment, scopeCollection, scopeGlobals} {
+		if _, ok := v.getLocked(scope, key); !ok {
+			continue
+		}
+		variables := v.environment
+		if scope == scopeCollection {
+			variables = v.collection
+		} else if scope != scopeEnvironment {
+			return false
+		}
+		return variables[findVariable(variables, key)].Secret
+	}
+	return false
+}
+
+// lookup finds the value a {{name}} resolves to.
+func (v *runVariables) lookup(key string) (string, bool) {
+	if strings.HasPrefix(key, "$") {
+		return dynamicVariable(key)
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for _, scope := range []string{scopeLocal, scopeData, scopeEnvironment, scopeCollection, scopeGlobals} {
+		if value, ok := v.getLocked(scope, key); ok {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// placeheld reports whether {{key}} is left in place as a secret.
+func (v *runVariables) placeheld(key string) bool {
+	if !v.placeholdSecrets {
+		return false
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.secretLocked(key)
+}
+
+func (v *runVariables) set(scope, key, value string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	switch scope {
+	case scopeLocal:
+		v.local[key] = value
+	case scopeGlobals:
+		v.globals[key] = value
+	case scopeEnvironment:
+		v.environment = setVariable(v.environment, key, value)
+		v.environmentChanges.mark(key)
+	case scopeCollection:
+		v.collection = setVariable(v.collection, key, value)
+		v.collectionChanges.mark(key)
+	}
+}
+
+// setVariable updates a variable in place, so its secret flag is kept, or
+// appends it.
+func setVariable(variables []model.Variable, key, value string) []model.Variable {
+	if i := findVariable(variables, key); i >= 0 {
+		variables[i].Value = value
+		variables[i].Disabled = false
+		return variables
+	}
+	return append(variables, model.Variable{Key: key, Value: value})
+}
+
+func (v *runVariables) unset(scope, key string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	switch scope {
+	case scopeLocal:
+		delete(v.local, key)
+	case scopeGlobals:
+		delete(v.globals, key)
+	case scopeEnvironment:
+		if i := findVariable(v.environment, key); i >= 0 {
+			v.environment = append(v.environment[:i], v.environment[i+1:]...)
+		}
+		v.environmentChanges.mark(key)
+	case scopeCollection:
+		if i := findVariable(v.collection, key); i >= 0 {
+			v.collection = append(v.collection[:i], v.collection[i+1:]...)
+		}
+		v.collectionChanges.mark(key)
+	}
+}
+
+func (v *runVariables) clear(scope string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	switch scope {
+	case scopeLocal:
+		v.local = map[string]string{}
+	case scopeGlobals:
+		v.globals = map[string]string{}
+	case scopeEnvironment:
+		v.environment = nil
+		v.environmentChanges.clear()
+	case scopeCollection:
+		v.collection = nil
+		v.collectionChanges.clear()
+	}
+}
+
+// all returns the enabled variables of a scope.
+func (v *runVariables) all(scope string) map[string]string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	values := map[string]string{}
+	switch scope {
+	case scopeLocal, scopeData, scopeGlobals:
+		source := v.local
+		if scope == scopeData {
+			source = v.data
+		} else if scope == scopeGlobals {
+			source = v.globals
+		}
+		for key, value := range source {
+			values[key] = value
+		}
+	case scopeEnvironment, scopeCollection:
+		variables := v.environment
+		if scope == scopeCollection {
+			variables = v.collection
+		}
+		for _, variable := range variables {
+			if !variable.Disabled {
+				values[variable.Key] = variable.Value
+			}
+		}
+	}
+	return values
+}
+
+// resolve replaces the {{name}} placeholders of a template and returns the
+// names that have no value.
+func (v *runVariables) resolve(template string) (string, []string) {
+	for depth := 0; depth < maxVariableDepth && strings.Contains(template, "{{"); depth++ {
+		changed := false
+		template = variablePattern.ReplaceAllStringFunc(template, func(match string) string {
+			name := strings.TrimSpace(match[2 : len(match)-2])
+			if v.placeheld(name) {
+				return match
+			}
+			if value, ok := v.lookup(name); ok {
+				changed = true
+				return value
+			}
+			return match
+		})
+		if !changed {
+			break
+		}
+	}
+
+	var unresolved []string
+	for _, match := range variablePattern.FindAllStringSubmatch(template, -1) {
+		if name := strings.TrimSpace(match[1]); !v.placeheld(name) {
+			unresolved = append(unresolved, name)
+		}
+	}
+	return template, unresolved
+}
+
+// dynamicVariable generates the values of Postman's most used dynamic
+// variables, a fresh one on every use.
+func dynamicVariable(name string) (string, bool) {
+	switch name {
+	case "$guid", "$randomUUID":
+		id := make([]byte, 16)
+		if _, err := rand.Read(id); err != nil {
+			return "", false
+		}
+		id[6] = id[6]&0x0f | 0x40
+		id[8] = id[8]&0x3f | 0x80
+		encoded := hex.EncodeToString(id)
+		return encoded[0:8] + "-" + encoded[8:12] + "-" + encoded[12:16] + "-" + encoded[16:20] + "-" + encoded[20:], true
+	case "$timestamp":
+		return strconv.FormatInt(time.Now().Unix(), 10), true
+	case "$isoTimestamp":
+		return time.Now().UTC().Format("2006-01-02T15:04:05.000Z"), true
+	case "$randomInt":
+		n, err := rand.Int(rand.Reader, big.NewInt(1001))
+		if err != nil {
+			return "", false
+		}
+		return n.String(), true
+	}
+	return "", false
+}
+
+// collectionRunner sends saved requests of a collection the way a client
+// would: with scripts run, variables resolved and auth inherited from the
+// folders and the collection.
+type collectionRunner struct {
+	requests    IRequestService
+	userID      int
+	collection  *model.Collection
+	environment *model.Environment
+	variables   *runVariables
+	timeout     int
+	skipHistory bool
+}
+
+// findItemPath returns the folders leading to an item followed by the item.
+func findItemPath(items []*model.CollectionItem, id int) []*model.CollectionItem {
+	for _, item := range items {
+		if item.ID == id {
+			return []*model.CollectionItem{item}
+		}
+		if path := findItemPath(item.Items, id); path != nil {
+			return append([]*model.CollectionItem{item}, path...)
+		}
+	}
+	return nil
+}
+
+type runScript struct {
+	name   string
+	source string
+}
+
+// scripts lists the scripts of a phase in the order they run: the
+// collection's, each folder's and the request's own.
+func (r *collectionRunner) scripts(path []*model.CollectionItem, phase string) []runScript {
+	pick := func(scripts *model.Scripts) string {
+		if scripts == nil {
+			return ""
+		}
+		if phase == scriptPhasePreRequest {
+			return scripts.PreRequest
+		}
+		return scripts.Test
+	}
+
+	var scripts []runScript
+	if source := pick(r.collection.Scripts); strings.TrimSpace(source) != "" {
+		scripts = append(scripts, runScript{name: r.collection.Name + " (" + phase + ")", source: source})
+	}
+	for _, item := range path {
+		if source := pick(item.Scripts); strings.TrimSpace(source) != "" {
+			scripts = append(scripts, runScript{name: item.Name + " (" + phase + ")", source: source})
+		}
+	}
+	return scripts
+}
+
+// auth returns the auth of the closest item that sets one.
+func (r *collectionRunner) auth(path []*model.CollectionItem) *model.RequestAuth {
+	for i := len(path) - 1; i >= 0; i-- {
+		if path[i].Auth != nil {
+			return path[i].Auth
+		}
+	}
+	return r.collection.Auth
+}
+
+// runPhase runs the scripts of a phase, it returns nil when there are none.
+func (r *collectionRunner) runPhase(ctx context.Context, path []*model.CollectionItem, info scriptInfo, request *model.CollectionRequest, response *scriptResponse) (*model.CollectionRequest, *model.DTOScriptResult) {
+	scripts := r.scripts(path, info.EventName)
+	if len(scripts) == 0 {
+		return request, nil
+	}
+
+	environmentName := ""
+	if r.environment != nil {
+		environmentName = r.environment.Name
+	}
+	sandbox, err := newScriptSandbox(r.variables, info, environmentName, request, response)
+	if err != nil {
+		return nil, &model.DTOScriptResult{Tests: []model.DTOScriptTest{}, Error: err.Error()}
+	}
+	for _, script := range scripts {
+		if !sandbox.run(ctx, script.name, script.source) {
+			break
+		}
+	}
+	return sandbox.close(ctx)
+}
+
+// runItem runs a saved request. Failures of scripts and of the request are
+// reported in the result, the run of a collection goes on with the next one.
+func (r *collectionRunner) runItem(ctx context.Context, path []*model.CollectionItem, info scriptInfo) *model.DTOItemRunResult {
+	item := path[len(path)-1]
+	result := &model.DTOItemRunResult{ItemID: item.ID, Name: item.Name}
+	info.RequestName = item.Name
+	info.RequestID = strconv.Itoa(item.ID)
+
+	// Scripts may change the request, so they get a copy.
+	var request model.CollectionRequest
+	if item.Request != nil {
+		data, err := json.Marshal(item.Request)
+		if err == nil {
+			err = json.Unmarshal(data, &request)
+		}
+		if err != nil {
+			result.Error = fmt.Sprintf("invalid saved request: %v", err)
+			return result
+		}
+	}
+
+	info.EventName = scriptPhasePreRequest
+	scripted, scriptResult := r.runPhase(ctx, path, info, &request, nil)
+	result.PreRequest = scriptResult
+	if scriptResult != nil && scriptResult.Error != "" {
+		result.Error = "pre-request script failed: " + scriptResult.Error
+		return result
+	}
+
+	dto, warnings := buildRunRequest(scripted, r.auth(path), r.variables)
+	dto.Timeout = r.timeout
+	dto.SkipHistory = r.skipHistory
+	dto.Contract = item.Contract
+	dto.Assertions = scripted.Assertions
+	result.Request = dto
+	result.Warnings = warnings
+
+	response, err := r.requests.ProcessRequest(ctx, &r.userID, dto)
+	if response == nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Response = response
+	r.extract(result, scripted.Extractions, response)
+
+	info.EventName = scriptPhaseTest
+	_, result.Test = r.runPhase(ctx, path, info, scripted, newScriptResponse(response))
+	return result
+}
+
+// extract runs the extraction rules of a request before its test scripts,
+// so they already see the new values. Without an active environment the
+// values only last for the run.
+func (r *collectionRunner) extract(result *model.DTOItemRunResult, extractions []model.Extraction, response *model.DTOResponse) {
+	if len(extractions) == 0 {
+		return
+	}
+	compiled, err := compileExtractions(extractions)
+	if err != nil {
+		result.Warnings = append(result.Warnings, err.Error())
+		return
+	}
+
+	scope := scopeEnvironment
+	if r.environment == nil {
+		scope = scopeLocal
+		result.Warnings = append(result.Warnings, "no environment is active, extracted values are only kept for this run")
+	}
+	result.Extracted = extractValues(compiled, response)
+	for _, extracted := range result.Extracted {
+		if extracted.Found {
+			r.variables.set(scope, extracted.Variable, extracted.Value)
+		}
+	}
+}
+
+// saveVariables writes back the environment and collection variables that
+// the run set or unset, merged into the stored ones so edits made during the
+// run are kept. It uses its own context so a client going away does not
+// lose them.
+func (r *collectionRunner) saveVariables(collections repository.ICollectionRepository, environments repository.IEnvironmentRepository) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	variables := r.variables
+	variables.mu.Lock()
+	defer variables.mu.Unlock()
+	if changes, current := variables.environmentChanges, variables.environment; changes.changed() && r.environment != nil {
+		merge := func(stored []model.Variable) []model.Variable { return changes.apply(stored, current) }
+		if _, err := environments.MergeVariables(ctx, r.environment.ID, r.userID, merge); err != nil {
+			return fmt.Errorf("failed to save environment variables: %w", err)
+		}
+	}
+	if changes, current := variables.collectionChanges, variables.collection; changes.changed() {
+		merge := func(stored []model.Variable) []model.Variable { return changes.apply(stored, current) }
+		if _, err := collections.MergeVariables(ctx, r.collection.ID, r.userID, merge); err != nil {
+			return fmt.Errorf("failed to save collection variables: %w", err)
+		}
+	}
+	return nil
+}
+
+// queryEscapeAroundVariables escapes value for a query string, leaving the
+// {{name}} it holds as they are.
+func queryEscapeAroundVariables(value string) string {
+	var escaped strings.Builder
+	last := 0
+	for _, match := range variablePattern.FindAllStringIndex(value, -1) {
+		escaped.WriteString(url.QueryEscape(value[last:match[0]]))
+		escaped.WriteString(value[match[0]:match[1]])
+		last = match[1]
+	}
+	escaped.WriteString(url.QueryEscape(value[last:]))
+	return escaped.String()
+}
+
+// buildRunRequest resolves the variables of a saved request, applies its
+// auth and encodes its body. Warnings list what could not be sent as saved.
+func buildRunRequest(request *model.CollectionRequest, auth *model.RequestAuth, variables *runVariables) (*model.DTORequest, []string) {
+	unresolved := map[string]bool{}
+	resolve := func(template string) string {
+		value, missing := variables.resolve(template)
+		for _, name := range missing {
+			unresolved[name] = true
+		}
+		return value
+	}
+	queryEscape := url.QueryEscape
+	if variables.placeholdSecrets {
+		queryEscape = queryEscapeAroundVariables
+	}
+	var warnings []string
+
+	method := strings.ToUpper(resolve(request.Method))
+	if method == "" {
+		method = http.MethodGet
+	}
+	requestURL := strings.TrimSpace(resolve(request.URL))
+	// Saved URLs often leave out the scheme, clients default to http.
+	if requestURL != "" && !strings.Contains(requestURL, "://") {
+		requestURL = "http://" + requestURL
+	}
+
+	headers := http.Header{}
+	for _, header := range request.Headers {
+		if !header.Disabled && header.Key != "" {
+			headers.Add(resolve(header.Key), resolve(header.Value))
+		}
+	}
+
+	if auth != nil {
+		switch auth.Type {
+		case model.AuthBasic:
+			if headers.Get("Authorization") == "" {
+				credentials := resolve(auth.Username) + ":" + resolve(auth.Password)
+				// Credentials holding a secret placeholder are left to be
+				// encoded once it is filled in.
+				if !variables.placeholdSecrets || !variablePattern.MatchString(credentials) {
+					credentials = base64.StdEncoding.EncodeToString([]byte(credentials))
+				}
+				headers.Set("Authorization", "Basic "+credentials)
+			}
+		case model.AuthBearer:
+			if headers.Get("Authorization") == "" {
+				headers.Set("Authorization", "Bearer "+resolve(auth.Token))
+			}
+		case model.AuthAPIKey:
+			key, value := resolve(auth.Key), resolve(auth.Value)
+			if auth.In == "query" {
+				separator := "?"
+				if strings.Contains(requestURL, "?") {
+					separator = "&"
+				}
+				requestURL += separator + queryEscape(key) + "=" + queryEscape(value)
+			} else if key != "" && headers.Get(key) == "" {
+				headers.Set(key, value)
+			}
+		}
+	}
+
+	dto := &model.DTORequest{Method: method, URL: requestURL}
+	if body := request.Body; body != nil {
+		switch body.Mode {
+		case model.BodyModeRaw:
+			dto.BodyText = resolve(body.Raw)
+			if body.ContentType != "" && headers.Get("Content-Type") == "" {
+				headers.Set("Content-Type", body.ContentType)
+			}
+		case model.BodyModeURLEncoded:
+			var pairs []string
+			for _, pair := range body.URLEncoded {
+				if !pair.Disabled {
+					pairs = append(pairs, queryEscape(resolve(pair.Key))+"="+queryEscape(resolve(pair.Value)))
+				}
+			}
+			dto.BodyText = strings.Join(pairs, "&")
+			if headers.Get("Content-Type") == "" {
+				headers.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+		case model.BodyModeFormData:
+			var buf bytes.Buffer
+			writer := multipart.NewWriter(&buf)
+			for _, field := range body.FormData {
+				if field.Disabled {
+					continue
+				}
+				if field.Type == "file" {
+					warnings = append(warnings, fmt.Sprintf("form field %q was not sent, file contents are not stored", field.Key))
+					continue
+				}
+				writer.WriteField(resolve(field.Key), resolve(field.Value))
+			}
+			writer.Close()
+			dto.BodyBase64 = base64.StdEncoding.EncodeToString(buf.Bytes())
+			headers.Set("Content-Type", writer.FormDataContentType())
+		case model.BodyModeGraphQL:
+			if body.GraphQL != nil {
+				graphQL := *body.GraphQL
+				graphQL.Query = resolve(graphQL.Query)
+				if len(graphQL.Variables) > 0 {
+					graphQL.Variables = json.RawMessage(resolve(string(graphQL.Variables)))
+				}
+				dto.GraphQL = &graphQL
+			}
+		}
+	}
+	dto.Headers = headers
+
+	if len(unresolved) > 0 {
+		names := make([]string, 0, len(unresolved))
+		for name := range unresolved {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		warnings = append(warnings, "unresolved variables: "+strings.Join(names, ", "))
+	}
+	return dto, warnings
+}
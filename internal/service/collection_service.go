@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/suar-net/suar-be/internal/model"
+	"github.com/suar-net/suar-be/internal/repository"
+)
+
+type collectionService struct {
+	repository   repository.ICollectionRepository
+	environments repository.IEnvironmentRepository
+	specs        repository.IAPISpecRepository
+	requests     IRequestService
+}
+
+func NewCollectionService(r repository.ICollectionRepository, e repository.IEnvironmentRepository, s repository.IAPISpecRepository, requests IRequestService) ICollectionService {
+	return &collectionService{repository: r, environments: e, specs: s, requests: requests}
+}
+
+// ListCollections returns the collections of a user without their items.
+func (s *collectionService) ListCollections(ctx context.Context, userID int) ([]*model.Collection, error) {
+	collections, err := s.repository.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list collections: %w", err)
+	}
+	if collections == nil {
+		collections = []*model.Collection{}
+	}
+	return collections, nil
+}
+
+// GetCollection returns a collection with its whole item tree.
+func (s *collectionService) GetCollection(ctx context.Context, id int, userID int) (*model.Collection, error) {
+	collection, err := s.repository.GetByID(ctx, id, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load collection: %w", err)
+	}
+	if collection == nil {
+		return nil, ErrCollectionNotFound
+	}
+	return collection, nil
+}
+
+func (s *collectionService) DeleteCollection(ctx context.Context, id int, userID int) error {
+	deleted, err := s.repository.Delete(ctx, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete collection: %w", err)
+	}
+	if !deleted {
+		return ErrCollectionNotFound
+	}
+	return nil
+}
+
+// ExportPostman returns a collection as a Postman v2.1 collection.
+func (s *collectionService) ExportPostman(ctx context.Context, id int, userID int) (*model.PostmanCollection, error) {
+	collection, err := s.GetCollection(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	return collectionToPostman(collection), nil
+}
+
+// SetItemContract links a saved request to an OpenAPI operation or a JSON
+// Schema, a nil contract removes the link. The contract is checked the same
+// way it is when the request is sent.
+func (s *collectionService) SetItemContract(ctx context.Context, collectionID, itemID, userID int, contract *model.Contract) error {
+	if contract != nil {
+		if _, err := newContractValidator(ctx, s.specs, &userID, contract); err != nil {
+			return err
+		}
+	}
+
+	updated, err := s.repository.SetItemContract(ctx, collectionID, itemID, userID, contract)
+	if err != nil {
+		return fmt.Errorf("failed to update contract: %w", err)
+	}
+	if !updated {
+		return ErrItemNotFound
+	}
+	return nil
+}
+
+// SetItemExtractions replaces the rules that copy values of a saved request's
+// responses into the active environment when it runs.
+func (s *collectionService) SetItemExtractions(ctx context.Context, collectionID, itemID, userID int, extractions []model.Extraction) error {
+	if _, err := compileExtractions(extractions); err != nil {
+		return err
+	}
+
+	updated, err := s.repository.SetItemExtractions(ctx, collectionID, itemID, userID, extractions)
+	if err != nil {
+		return fmt.Errorf("failed to update extractions: %w", err)
+	}
+	if !updated {
+		return ErrItemNotFound
+	}
+	return nil
+}
+
+// SetItemExamples replaces the saved responses of a request that mock
+// servers answer with.
+func (s *collectionService) SetItemExamples(ctx context.Context, collectionID, itemID, userID int, examples []model.ResponseExample) error {
+	updated, err := s.repository.SetItemExamples(ctx, collectionID, itemID, userID, examples)
+	if err != nil {
+		return fmt.Errorf("failed to update examples: %w", err)
+	}
+	if !updated {
+		return ErrItemNotFound
+	}
+	return nil
+}
+
+// RunItem sends a saved request with its scripts run and its variables
+// resolved from the environment and the collection. Variables changed by
+// the scripts are saved, an error doing so is returned with the result.
+func (s *collectionService) RunItem(ctx context.Context, collectionID, itemID, userID int, dto *model.DTOItemRunRequest) (*model.DTOItemRunResult, error) {
+	collection, err := s.GetCollection(ctx, collectionID, userID)
+	if err != nil {
+		return nil, err
+	}
+	path := findItemPath(collection.Items, itemID)
+	if path == nil || path[len(path)-1].Type != model.CollectionItemRequest {
+		return nil, ErrItemNotFound
+	}
+
+	var environment *model.Environment
+	if dto.EnvironmentID != nil {
+		environment, err = s.environments.GetByID(ctx, *dto.EnvironmentID, userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load environment: %w", err)
+		}
+		if environment == nil {
+			return nil, ErrEnvironmentNotFound
+		}
+	}
+
+	runner := &collectionRunner{
+		requests:    s.requests,
+		userID:      userID,
+		collection:  collection,
+		environment: environment,
+		variables:   newRunVariables(collection, environment),
+		timeout:     dto.Timeout,
+	}
+	result := runner.runItem(ctx, path, scriptInfo{Iteration: 0, IterationCount: 1})
+	return result, runner.saveVariables(s.repository, s.environments)
+}
@@ -0,0 +1,557 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/suar-net/suar-be/internal/model"
+	"github.com/suar-net/suar-be/internal/repository"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+const (
+	// contractDocumentURL is the location documents are registered under
+	// for schema compilation, nothing is fetched from it.
+	contractDocumentURL = "mem://contract/document.json"
+
+	// maxContractViolations bounds the violations reported for one response.
+	maxContractViolations = 100
+
+	// maxContractRefDepth bounds how many $ref hops are followed.
+	maxContractRefDepth = 16
+)
+
+var (
+	contractPrinter = message.NewPrinter(language.English)
+
+	openAPIMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+)
+
+// errExternalSchemaRef is returned for a $ref outside of the compiled
+// documents.
+var errExternalSchemaRef = errors.New("references outside of the document are not allowed")
+
+// localSchemaLoader refuses to load any URL. The default loader of the
+// compiler reads file:// URLs, which would let a user supplied schema read
+// files of the server.
+type localSchemaLoader struct{}
+
+func (localSchemaLoader) Load(url string) (any, error) {
+	return nil, errExternalSchemaRef
+}
+
+// newSchemaCompiler returns a compiler that only resolves references within
+// the documents added to it.
+func newSchemaCompiler() *jsonschema.Compiler {
+	compiler := jsonschema.NewCompiler()
+	compiler.UseLoader(localSchemaLoader{})
+	return compiler
+}
+
+// contractValidator checks a response against its contract. For OpenAPI
+// contracts document is the stored document and pointer locates the
+// operation in it, for JSON Schema contracts bodySchema is set.
+type contractValidator struct {
+	document   map[string]any
+	compiler   *jsonschema.Compiler
+	pointer    string
+	operation  string
+	method     string
+	bodySchema *jsonschema.Schema
+}
+
+// newContractValidator prepares the validation of a contract. A contract
+// linked to a stored spec needs a signed in user, a JSON Schema does not.
+func newContractValidator(ctx context.Context, specs repository.IAPISpecRepository, userID *int, contract *model.Contract) (*contractValidator, error) {
+	hasSchema := len(contract.Schema) > 0 && string(contract.Schema) != "null"
+	if (contract.SpecID > 0) == hasSchema {
+		return nil, fmt.Errorf("%w: a contract needs either a spec_id or a schema", ErrInvalidInput)
+	}
+
+	if hasSchema {
+		if contract.Operation != "" {
+			return nil, fmt.Errorf("%w: operation only applies to contracts with a spec_id", ErrInvalidInput)
+		}
+		document, err := jsonschema.UnmarshalJSON(bytes.NewReader(contract.Schema))
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid JSON Schema: %v", ErrInvalidInput, err)
+		}
+		compiler := newSchemaCompiler()
+		compiler.DefaultDraft(jsonschema.Draft2020)
+		if err := compiler.AddResource(contractDocumentURL, document); err != nil {
+			return nil, fmt.Errorf("%w: invalid JSON Schema: %v", ErrInvalidInput, err)
+		}
+		schema, err := compiler.Compile(contractDocumentURL)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid JSON Schema: %v", ErrInvalidInput, err)
+		}
+		return &contractValidator{bodySchema: schema}, nil
+	}
+
+	if userID == nil {
+		return nil, fmt.Errorf("%w: validating against a stored OpenAPI spec requires signing in", ErrInvalidInput)
+	}
+	spec, err := specs.GetByID(ctx, contract.SpecID, *userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load spec %d: %w", contract.SpecID, err)
+	}
+	if spec == nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidInput, ErrSpecNotFound)
+	}
+
+	validator, err := newOpenAPIContractValidator(spec.Document)
+	if err != nil {
+		return nil, err
+	}
+	if contract.Operation != "" {
+		if err := validator.selectOperation(contract.Operation); err != nil {
+			return nil, err
+		}
+	}
+	return validator, nil
+}
+
+// newOpenAPIContractValidator prepares a stored OpenAPI document for
+// validation. OpenAPI 3.0 schemas are validated as draft 4 with nullable
+// rewritten to a null type, 3.1 schemas as draft 2020-12.
+func newOpenAPIContractValidator(data []byte) (*contractValidator, error) {
+	decoded, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid stored OpenAPI document: %w", err)
+	}
+	document, ok := decoded.(map[string]any)
+	if !ok {
+		return nil, errors.New("invalid stored OpenAPI document")
+	}
+
+	compiler := newSchemaCompiler()
+	version, _ := document["openapi"].(string)
+	if strings.HasPrefix(version, "3.0") {
+		compiler.DefaultDraft(jsonschema.Draft4)
+		rewriteNullable(document)
+	} else {
+		compiler.DefaultDraft(jsonschema.Draft2020)
+	}
+	if err := compiler.AddResource(contractDocumentURL, document); err != nil {
+		return nil, fmt.Errorf("invalid stored OpenAPI document: %w", err)
+	}
+	return &contractValidator{document: document, compiler: compiler}, nil
+}
+
+// rewriteNullable turns the OpenAPI 3.0 nullable keyword into a type list
+// JSON Schema understands.
+func rewriteNullable(value any) {
+	switch v := value.(type) {
+	case map[string]any:
+		if nullable, _ := v["nullable"].(bool); nullable {
+			if schemaType, ok := v["type"].(string); ok {
+				v["type"] = []any{schemaType, "null"}
+			}
+			if enum, ok := v["enum"].([]any); ok {
+				v["enum"] = append(enum, nil)
+			}
+		}
+		for _, element := range v {
+			rewriteNullable(element)
+		}
+	case []any:
+		for _, element := range v {
+			rewriteNullable(element)
+		}
+	}
+}
+
+// selectOperation finds an operation by operationId or "METHOD /path".
+func (v *contractValidator) selectOperation(operation string) error {
+	wantMethod, wantPath, isPath := strings.Cut(strings.TrimSpace(operation), " ")
+	wantPath = strings.TrimSpace(wantPath)
+
+	paths, _ := v.document["paths"].(map[string]any)
+	for _, path := range sortedKeys(paths) {
+		pathItem, _ := paths[path].(map[string]any)
+		for _, method := range openAPIMethods {
+			op, ok := pathItem[method].(map[string]any)
+			if !ok {
+				continue
+			}
+			operationID, _ := op["operationId"].(string)
+			if operationID == operation || (isPath && strings.EqualFold(wantMethod, method) && wantPath == path) {
+				v.useOperation(path, method)
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%w: operation %q is not defined in the spec", ErrInvalidInput, operation)
+}
+
+func (v *contractValidator) useOperation(path, method string) {
+	v.pointer = "/paths/" + escapePointerToken(path) + "/" + method
+	v.operation = strings.ToUpper(method) + " " + path
+}
+
+// matchRequest records the method of the request and, when no operation was
+// named, finds the operation whose path template matches the request URL
+// below one of the server URLs. Templates with more literal segments win.
+func (v *contractValidator) matchRequest(method string, requestURL *url.URL) error {
+	v.method = method
+	if v.document == nil || v.pointer != "" {
+		return nil
+	}
+
+	paths, _ := v.document["paths"].(map[string]any)
+	bestPath, bestLiterals := "", -1
+	for _, base := range v.serverBasePaths() {
+		rest, ok := strings.CutPrefix(requestURL.Path, base)
+		if !ok || (rest != "" && !strings.HasPrefix(rest, "/")) {
+			continue
+		}
+		if rest == "" {
+			rest = "/"
+		}
+		for _, path := range sortedKeys(paths) {
+			pathItem, _ := paths[path].(map[string]any)
+			if _, ok := pathItem[strings.ToLower(method)].(map[string]any); !ok {
+				continue
+			}
+			if literals, ok := matchPathTemplate(path, rest); ok && literals > bestLiterals {
+				bestPath, bestLiterals = path, literals
+			}
+		}
+	}
+	if bestLiterals < 0 {
+		return fmt.Errorf("%w: no operation in the spec matches %s %s", ErrInvalidInput, method, requestURL.Path)
+	}
+	v.useOperation(bestPath, strings.ToLower(method))
+	return nil
+}
+
+// serverBasePaths returns the path of every server URL, with server
+// variables set to their defaults. A document without servers is served
+// from the root.
+func (v *contractValidator) serverBasePaths() []string {
+	servers, _ := v.document["servers"].([]any)
+	var bases []string
+	for _, element := range servers {
+		server, _ := element.(map[string]any)
+		serverURL, _ := server["url"].(string)
+		variables, _ := server["variables"].(map[string]any)
+		serverURL = openAPIPathParam.ReplaceAllStringFunc(serverURL, func(match string) string {
+			variable, _ := variables[match[1:len(match)-1]].(map[string]any)
+			value, _ := variable["default"].(string)
+			return value
+		})
+		if parsed, err := url.Parse(serverURL); err == nil {
+			bases = append(bases, strings.TrimSuffix(parsed.Path, "/"))
+		}
+	}
+	if len(bases) == 0 {
+		bases = append(bases, "")
+	}
+	return bases
+}
+
+// matchPathTemplate matches a path against an OpenAPI path template and
+// returns the number of literal segments.
+func matchPathTemplate(template, path string) (int, bool) {
+	templateSegments := strings.Split(strings.Trim(template, "/"), "/")
+	pathSegments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(templateSegments) != len(pathSegments) {
+		return 0, false
+	}
+
+	literals := 0
+	for i, segment := range templateSegments {
+		if !strings.Contains(segment, "{") {
+			if segment != pathSegments[i] {
+				return 0, false
+			}
+			literals++
+			continue
+		}
+		// Parameters may be part of a segment, as in {name}.{format}.
+		pattern, last := "^", 0
+		for _, loc := range openAPIPathParam.FindAllStringIndex(segment, -1) {
+			pattern += regexp.QuoteMeta(segment[last:loc[0]]) + "[^/]+"
+			last = loc[1]
+		}
+		pattern += regexp.QuoteMeta(segment[last:]) + "$"
+		if matched, err := regexp.MatchString(pattern, pathSegments[i]); err != nil || !matched {
+			return 0, false
+		}
+	}
+	return literals, true
+}
+
+// validate checks a response and lists every violation found.
+func (v *contractValidator) validate(response *model.DTOResponse) *model.DTOContractResult {
+	result := &model.DTOContractResult{Operation: v.operation, Violations: []model.DTOContractViolation{}}
+	if v.bodySchema != nil {
+		v.validateJSONBody(result, response, v.bodySchema)
+	} else {
+		v.validateOperation(result, response)
+	}
+
+	if len(result.Violations) > maxContractViolations {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("only the first %d of %d violations are listed", maxContractViolations, len(result.Violations)))
+		result.Violations = result.Violations[:maxContractViolations]
+	}
+	result.Valid = len(result.Violations) == 0
+	return result
+}
+
+func addViolation(result *model.DTOContractResult, pointer, format string, args ...any) {
+	result.Violations = append(result.Violations, model.DTOContractViolation{Pointer: pointer, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *contractValidator) validateOperation(result *model.DTOContractResult, response *model.DTOResponse) {
+	operation, _ := v.lookup(v.pointer).(map[string]any)
+	responses, _ := operation["responses"].(map[string]any)
+
+	code := strconv.Itoa(response.StatusCode)
+	responsePointer := ""
+	for _, key := range []string{code, code[:1] + "XX", code[:1] + "xx", "default"} {
+		if _, ok := responses[key]; ok {
+			responsePointer = v.pointer + "/responses/" + key
+			break
+		}
+	}
+	if responsePointer == "" {
+		addViolation(result, "/status", "status %d is not documented for %s", response.StatusCode, v.operation)
+		return
+	}
+	documented, responsePointer := v.resolve(responsePointer)
+	if documented == nil {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("the response at %s could not be resolved", responsePointer))
+		return
+	}
+
+	headers := http.Header(response.Headers)
+	documentedHeaders, _ := documented["headers"].(map[string]any)
+	for _, name := range sortedKeys(documentedHeaders) {
+		// Content-Type is described by the content map instead.
+		if strings.EqualFold(name, "Content-Type") {
+			continue
+		}
+		header, headerPointer := v.resolve(responsePointer + "/headers/" + escapePointerToken(name))
+		if header == nil {
+			continue
+		}
+		pointer := "/headers/" + escapePointerToken(name)
+		values := headers.Values(name)
+		if len(values) == 0 {
+			if required, _ := header["required"].(bool); required {
+				addViolation(result, pointer, "required header %s is missing", name)
+			}
+			continue
+		}
+		if _, ok := header["schema"]; !ok {
+			continue
+		}
+		schema, err := v.compiler.Compile(contractDocumentURL + "#" + encodePointer(headerPointer+"/schema"))
+		if err != nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("the schema of header %s could not be compiled: %v", name, err))
+			continue
+		}
+		schemaNode, _ := v.resolve(headerPointer + "/schema")
+		addSchemaViolations(result, pointer, schema.Validate(coerceHeaderValue(strings.Join(values, ","), schemaNode)))
+	}
+
+	content, _ := documented["content"].(map[string]any)
+	if len(content) == 0 {
+		return
+	}
+	if response.Truncated {
+		result.Warnings = append(result.Warnings, "the body was too large to be kept in full and was not validated")
+		return
+	}
+	if len(response.Body) == 0 {
+		if v.method != http.MethodHead && response.StatusCode != http.StatusNoContent && response.StatusCode != http.StatusNotModified {
+			addViolation(result, "/body", "the body is empty, expected %s", strings.Join(sortedKeys(content), " or "))
+		}
+		return
+	}
+
+	contentType := headers.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(contentType))
+	}
+	key := documentedMediaType(content, mediaType)
+	if key == "" {
+		addViolation(result, "/headers/Content-Type", "content type %q is not documented, expected %s", contentType, strings.Join(sortedKeys(content), " or "))
+		return
+	}
+	media, _ := content[key].(map[string]any)
+	if _, ok := media["schema"]; !ok {
+		return
+	}
+	if !openAPIIsJSON(mediaType) {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("bodies of type %s are not validated against their schema", mediaType))
+		return
+	}
+	schema, err := v.compiler.Compile(contractDocumentURL + "#" + encodePointer(responsePointer+"/content/"+escapePointerToken(key)+"/schema"))
+	if err != nil {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("the body schema could not be compiled: %v", err))
+		return
+	}
+	v.validateJSONBody(result, response, schema)
+}
+
+func (v *contractValidator) validateJSONBody(result *model.DTOContractResult, response *model.DTOResponse, schema *jsonschema.Schema) {
+	if response.Truncated {
+		result.Warnings = append(result.Warnings, "the body was too large to be kept in full and was not validated")
+		return
+	}
+	body, err := jsonschema.UnmarshalJSON(bytes.NewReader(response.Body))
+	if err != nil {
+		addViolation(result, "/body", "the body is not valid JSON: %v", err)
+		return
+	}
+	addSchemaViolations(result, "/body", schema.Validate(body))
+}
+
+// addSchemaViolations reports the innermost errors of a schema validation,
+// which point at the offending values.
+func addSchemaViolations(result *model.DTOContractResult, base string, err error) {
+	var validationErr *jsonschema.ValidationError
+	if err == nil || !errors.As(err, &validationErr) {
+		return
+	}
+	var walk func(e *jsonschema.ValidationError)
+	walk = func(e *jsonschema.ValidationError) {
+		if len(e.Causes) == 0 {
+			pointer := base
+			for _, token := range e.InstanceLocation {
+				pointer += "/" + escapePointerToken(token)
+			}
+			addViolation(result, pointer, "%s", e.ErrorKind.LocalizedString(contractPrinter))
+			return
+		}
+		for _, cause := range e.Causes {
+			walk(cause)
+		}
+	}
+	walk(validationErr)
+}
+
+// documentedMediaType finds the content entry for a media type, trying an
+// exact match, then type/* and then */*.
+func documentedMediaType(content map[string]any, mediaType string) string {
+	mainType, _, _ := strings.Cut(mediaType, "/")
+	for _, candidate := range []string{mediaType, mainType + "/*", "*/*"} {
+		for _, key := range sortedKeys(content) {
+			keyType, _, err := mime.ParseMediaType(key)
+			if err != nil {
+				keyType = strings.ToLower(key)
+			}
+			if keyType == candidate {
+				return key
+			}
+		}
+	}
+	return ""
+}
+
+// coerceHeaderValue converts a header value to the JSON type its schema
+// expects, values that do not parse are kept as strings.
+func coerceHeaderValue(value string, schema map[string]any) any {
+	schemaType, _ := schema["type"].(string)
+	if types, ok := schema["type"].([]any); ok {
+		for _, element := range types {
+			if name, _ := element.(string); name != "null" {
+				schemaType = name
+				break
+			}
+		}
+	}
+
+	switch schemaType {
+	case "integer", "number":
+		if _, err := strconv.ParseFloat(value, 64); err == nil {
+			return json.Number(value)
+		}
+	case "boolean":
+		if parsed, err := strconv.ParseBool(value); err == nil {
+			return parsed
+		}
+	case "array":
+		var items []any
+		for _, item := range strings.Split(value, ",") {
+			items = append(items, strings.TrimSpace(item))
+		}
+		return items
+	}
+	return value
+}
+
+// resolve returns the object at a pointer, following $ref, together with
+// the pointer it was finally found at.
+func (v *contractValidator) resolve(pointer string) (map[string]any, string) {
+	for range maxContractRefDepth {
+		object, ok := v.lookup(pointer).(map[string]any)
+		if !ok {
+			return nil, pointer
+		}
+		ref, ok := object["$ref"].(string)
+		if !ok {
+			return object, pointer
+		}
+		if !strings.HasPrefix(ref, "#/") {
+			return nil, pointer
+		}
+		unescaped, err := url.PathUnescape(ref[1:])
+		if err != nil {
+			return nil, pointer
+		}
+		pointer = unescaped
+	}
+	return nil, pointer
+}
+
+// lookup evaluates a JSON pointer against the document.
+func (v *contractValidator) lookup(pointer string) any {
+	var node any = v.document
+	if pointer == "" {
+		return node
+	}
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+		switch current := node.(type) {
+		case map[string]any:
+			node = current[token]
+		case []any:
+			index, err := strconv.Atoi(token)
+			if err != nil || index < 0 || index >= len(current) {
+				return nil
+			}
+			node = current[index]
+		default:
+			return nil
+		}
+	}
+	return node
+}
+
+func escapePointerToken(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
+
+// encodePointer percent-encodes a JSON pointer for use as a URL fragment.
+func encodePointer(pointer string) string {
+	tokens := strings.Split(pointer, "/")
+	for i, token := range tokens {
+		tokens[i] = url.PathEscape(token)
+	}
+	return strings.Join(tokens, "/")
+}
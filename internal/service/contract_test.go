@@ -0,0 +1,260 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/suar-net/suar-be/internal/model"
+	"github.com/suar-net/suar-be/internal/repository"
+)
+
+type fakeSpecRepo struct {
+	repository.IAPISpecRepository
+	specs map[int]*model.APISpec
+}
+
+func (f *fakeSpecRepo) GetByID(ctx context.Context, id int, userID int) (*model.APISpec, error) {
+	if spec, ok := f.specs[id]; ok && spec.UserID == userID {
+		return spec, nil
+	}
+	return nil, nil
+}
+
+// secretSchemaFile writes a schema to a temporary file and returns its
+// file:// URL. The schema only accepts "secret", so a validator that loaded
+// it would reject any other value.
+func secretSchemaFile(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "secret.json")
+	if err := os.WriteFile(path, []byte(`{"const": "secret"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
+
+func TestSchemaCompilerRejectsExternalRefs(t *testing.T) {
+	fileURL := secretSchemaFile(t)
+
+	// The compiler's default loader reads the file, which is what the
+	// rejecting loader prevents.
+	document, _ := jsonschema.UnmarshalJSON(strings.NewReader(`{"$ref": "` + fileURL + `"}`))
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource(contractDocumentURL, document); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := compiler.Compile(contractDocumentURL); err != nil {
+		t.Fatalf("the default loader did not load %s: %v", fileURL, err)
+	}
+
+	for _, ref := range []string{fileURL, "https://schemas.test/a.json", "other.json", "/etc/passwd"} {
+		schema := json.RawMessage(`{"type": "object", "properties": {"a": {"$ref": "` + ref + `"}}}`)
+		_, err := newContractValidator(context.Background(), nil, nil, &model.Contract{Schema: schema})
+		if !errors.Is(err, ErrInvalidInput) || !strings.Contains(err.Error(), errExternalSchemaRef.Error()) {
+			t.Errorf("$ref %s: err = %v, want the reference to be refused", ref, err)
+		}
+	}
+
+	// A local reference still works.
+	schema := json.RawMessage(`{"$defs": {"id": {"type": "integer"}}, "properties": {"id": {"$ref": "#/$defs/id"}}}`)
+	validator, err := newContractValidator(context.Background(), nil, nil, &model.Contract{Schema: schema})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := validator.validate(&model.DTOResponse{Body: []byte(`{"id": "x"}`)})
+	if result.Valid || len(result.Violations) != 1 || result.Violations[0].Pointer != "/body/id" {
+		t.Errorf("result = %+v", result)
+	}
+}
+
+func TestOpenAPIContractRejectsExternalRefs(t *testing.T) {
+	fileURL := secretSchemaFile(t)
+	validator, err := newOpenAPIContractValidator([]byte(`{"openapi": "3.1.0", "paths": {"/a": {"get": {"responses": {"200": {
+		"content": {"application/json": {"schema": {"$ref": "` + fileURL + `"}}}}}}}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validator.selectOperation("GET /a"); err != nil {
+		t.Fatal(err)
+	}
+	result := validator.validate(&model.DTOResponse{StatusCode: 200, Headers: map[string][]string{"Content-Type": {"application/json"}}, Body: []byte(`"public"`)})
+	if !result.Valid || len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], errExternalSchemaRef.Error()) {
+		t.Errorf("result = %+v, want a warning that the schema was not compiled", result)
+	}
+}
+
+func TestMatchPathTemplate(t *testing.T) {
+	tests := []struct {
+		template, path string
+		literals       int
+		ok             bool
+	}{
+		{"/pets", "/pets", 1, true},
+		{"/pets/{id}", "/pets/5", 1, true},
+		{"/pets/{id}", "/pets", 0, false},
+		{"/pets/{id}", "/pets/5/toys", 0, false},
+		{"/files/{name}.{ext}", "/files/a.json", 1, true},
+		{"/files/{name}.{ext}", "/files/a", 0, false},
+		{"/a/b", "/a/c", 0, false},
+		{"/", "/", 1, true},
+	}
+	for _, tt := range tests {
+		literals, ok := matchPathTemplate(tt.template, tt.path)
+		if literals != tt.literals || ok != tt.ok {
+			t.Errorf("matchPathTemplate(%q, %q) = %d, %v, want %d, %v", tt.template, tt.path, literals, ok, tt.literals, tt.ok)
+		}
+	}
+}
+
+func TestCoerceHeaderValue(t *testing.T) {
+	tests := []struct {
+		value  string
+		schema map[string]any
+		want   any
+	}{
+		{"5", map[string]any{"type": "integer"}, json.Number("5")},
+		{"x", map[string]any{"type": "integer"}, "x"},
+		{"1.5", map[string]any{"type": []any{"null", "number"}}, json.Number("1.5")},
+		{"true", map[string]any{"type": "boolean"}, true},
+		{"a, b", map[string]any{"type": "array"}, []any{"a", "b"}},
+		{"text", map[string]any{}, "text"},
+	}
+	for _, tt := range tests {
+		if got := coerceHeaderValue(tt.value, tt.schema); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("coerceHeaderValue(%q, %v) = %#v, want %#v", tt.value, tt.schema, got, tt.want)
+		}
+	}
+}
+
+func TestDocumentedMediaType(t *testing.T) {
+	content := map[string]any{"application/json; charset=utf-8": nil, "text/*": nil, "*/*": nil}
+	tests := []struct {
+		mediaType, want string
+	}{
+		{"application/json", "application/json; charset=utf-8"},
+		{"text/plain", "text/*"},
+		{"image/png", "*/*"},
+	}
+	for _, tt := range tests {
+		if got := documentedMediaType(content, tt.mediaType); got != tt.want {
+			t.Errorf("documentedMediaType(%q) = %q, want %q", tt.mediaType, got, tt.want)
+		}
+	}
+	if got := documentedMediaType(map[string]any{"application/xml": nil}, "application/json"); got != "" {
+		t.Errorf("undocumented media type matched %q", got)
+	}
+}
+
+func TestRewriteNullable(t *testing.T) {
+	var document any
+	json.Unmarshal([]byte(`{"a": {"type": "string", "nullable": true, "enum": ["x"]}, "b": [{"type": "integer", "nullable": false}]}`), &document)
+	rewriteNullable(document)
+	got, _ := json.Marshal(document)
+	want := `{"a":{"enum":["x",null],"nullable":true,"type":["string","null"]},"b":[{"nullable":false,"type":"integer"}]}`
+	if string(got) != want {
+		t.Errorf("rewriteNullable = %s, want %s", got, want)
+	}
+}
+
+func TestEncodePointer(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"/paths/" + escapePointerToken("/pets/{id}") + "/get", "/paths/~1pets~1%7Bid%7D/get"},
+		{"/a b", "/a%20b"},
+	}
+	for _, tt := range tests {
+		if got := encodePointer(tt.in); got != tt.want {
+			t.Errorf("encodePointer(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+const petsSpec = `{
+	"openapi": "3.0.3",
+	"servers": [{"url": "https://{env}.pets.test/v1", "variables": {"env": {"default": "api"}}}],
+	"paths": {
+		"/pets/{id}": {"get": {"operationId": "getPet", "responses": {
+			"200": {"$ref": "#/components/responses/Pet"},
+			"4XX": {"description": "error"}
+		}}},
+		"/pets/mine": {"get": {"responses": {"204": {"description": "none"}}}}
+	},
+	"components": {"responses": {"Pet": {
+		"headers": {"X-Rate": {"required": true, "schema": {"type": "integer"}}},
+		"content": {"application/json": {"schema": {"type": "object", "required": ["name"],
+			"properties": {"name": {"type": "string"}, "tag": {"type": "string", "nullable": true}}}}}
+	}}}
+}`
+
+func TestContractValidatorOpenAPI(t *testing.T) {
+	specs := &fakeSpecRepo{specs: map[int]*model.APISpec{1: {ID: 1, UserID: 7, Document: json.RawMessage(petsSpec)}}}
+	user, other := 7, 8
+
+	if _, err := newContractValidator(context.Background(), specs, nil, &model.Contract{SpecID: 1}); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("anonymous spec contract: err = %v", err)
+	}
+	if _, err := newContractValidator(context.Background(), specs, &other, &model.Contract{SpecID: 1}); !errors.Is(err, ErrSpecNotFound) {
+		t.Errorf("spec of another user: err = %v", err)
+	}
+	if _, err := newContractValidator(context.Background(), specs, &user, &model.Contract{SpecID: 1, Operation: "deletePet"}); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("unknown operation: err = %v", err)
+	}
+
+	jsonHeaders := http.Header{"Content-Type": {"application/json"}, "X-Rate": {"10"}}
+	tests := []struct {
+		name       string
+		url        string
+		operation  string
+		response   model.DTOResponse
+		operations string
+		violations []string
+		matchErr   bool
+	}{
+		{name: "valid", url: "https://api.pets.test/v1/pets/5", operations: "GET /pets/{id}",
+			response: model.DTOResponse{StatusCode: 200, Headers: jsonHeaders, Body: []byte(`{"name": "Rex", "tag": null}`)}},
+		{name: "literal path wins", url: "https://api.pets.test/v1/pets/mine", operations: "GET /pets/mine",
+			response: model.DTOResponse{StatusCode: 204}},
+		{name: "by operation id", url: "https://elsewhere.test/x", operation: "getPet", operations: "GET /pets/{id}",
+			response: model.DTOResponse{StatusCode: 404}},
+		{name: "body violations", url: "https://api.pets.test/v1/pets/5", operations: "GET /pets/{id}",
+			response:   model.DTOResponse{StatusCode: 200, Headers: jsonHeaders, Body: []byte(`{"tag": 1}`)},
+			violations: []string{"/body", "/body/tag"}},
+		{name: "missing header and wrong type", url: "https://api.pets.test/v1/pets/5", operations: "GET /pets/{id}",
+			response:   model.DTOResponse{StatusCode: 200, Headers: http.Header{"Content-Type": {"text/html"}}, Body: []byte("<p>")},
+			violations: []string{"/headers/X-Rate", "/headers/Content-Type"}},
+		{name: "undocumented status", url: "https://api.pets.test/v1/pets/5", operations: "GET /pets/{id}",
+			response: model.DTOResponse{StatusCode: 500}, violations: []string{"/status"}},
+		{name: "outside the server", url: "https://api.pets.test/pets/5", matchErr: true},
+	}
+	for _, tt := range tests {
+		validator, err := newContractValidator(context.Background(), specs, &user, &model.Contract{SpecID: 1, Operation: tt.operation})
+		if err != nil {
+			t.Fatal(err)
+		}
+		requestURL, _ := url.Parse(tt.url)
+		if err := validator.matchRequest(http.MethodGet, requestURL); (err != nil) != tt.matchErr {
+			t.Errorf("%s: matchRequest err = %v, wantErr %v", tt.name, err, tt.matchErr)
+			continue
+		}
+		if tt.matchErr {
+			continue
+		}
+		result := validator.validate(&tt.response)
+		var pointers []string
+		for _, violation := range result.Violations {
+			pointers = append(pointers, violation.Pointer)
+		}
+		if result.Operation != tt.operations || !reflect.DeepEqual(pointers, tt.violations) || result.Valid != (len(tt.violations) == 0) {
+			t.Errorf("%s: result = %+v, want operation %q and violations %q", tt.name, result, tt.operations, tt.violations)
+		}
+	}
+}
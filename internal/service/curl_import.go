@@ -0,0 +1,574 @@
+package service
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/suar-net/suar-be/internal/model"
+)
+
+// curlMultipartBoundary is fixed so that importing the same command twice
+// produces the same request.
+const curlMultipartBoundary = "----SuarFormBoundary7MA4YWxkTrZu0gW"
+
+// splitShellWords splits a command line the way a POSIX shell would for the
+// quoting styles that appear in copied cURL commands: single quotes, double
+// quotes, $'...' ANSI-C quotes, backslash escapes and line continuations.
+func splitShellWords(command string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+
+	runes := []rune(command)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\\':
+			if i+1 < len(runes) {
+				i++
+				// Backslash-newline is a line continuation.
+				if runes[i] == '\n' {
+					continue
+				}
+				if runes[i] == '\r' && i+1 < len(runes) && runes[i+1] == '\n' {
+					i++
+					continue
+				}
+				word.WriteRune(runes[i])
+				inWord = true
+			}
+		case r == '\'':
+			end := indexRune(runes, i+1, '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("%w: unterminated single quote", ErrInvalidInput)
+			}
+			word.WriteString(string(runes[i+1 : end]))
+			i = end
+			inWord = true
+		case r == '$' && i+1 < len(runes) && runes[i+1] == '\'':
+			end, err := readANSICQuote(runes, i+2, &word)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+			inWord = true
+		case r == '"':
+			i++
+			for ; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) && strings.ContainsRune("\"\\$`\n", runes[i+1]) {
+					i++
+					if runes[i] == '\n' {
+						continue
+					}
+				}
+				word.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("%w: unterminated double quote", ErrInvalidInput)
+			}
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+func indexRune(runes []rune, from int, target rune) int {
+	for i := from; i < len(runes); i++ {
+		if runes[i] == target {
+			return i
+		}
+	}
+	return -1
+}
+
+// readANSICQuote decodes a $'...' string starting after the opening quote and
+// returns the index of the closing quote.
+func readANSICQuote(runes []rune, start int, word *strings.Builder) (int, error) {
+	escapes := map[rune]string{'n': "\n", 't': "\t", 'r': "\r", '\\': "\\", '\'': "'", '"': "\"", 'a': "\a", 'b': "\b", 'e': "\x1b", 'f': "\f", 'v': "\v"}
+
+	for i := start; i < len(runes); i++ {
+		r := runes[i]
+		if r == '\'' {
+			return i, nil
+		}
+		if r != '\\' || i+1 >= len(runes) {
+			word.WriteRune(r)
+			continue
+		}
+
+		i++
+		if escaped, ok := escapes[runes[i]]; ok {
+			word.WriteString(escaped)
+			continue
+		}
+		digits := 0
+		switch runes[i] {
+		case 'x':
+			digits = 2
+		case 'u':
+			digits = 4
+		case 'U':
+			digits = 8
+		}
+		if digits > 0 {
+			end := i + 1
+			for end < len(runes) && end-i-1 < digits && strings.ContainsRune("0123456789abcdefABCDEF", runes[end]) {
+				end++
+			}
+			if value, err := strconv.ParseUint(string(runes[i+1:end]), 16, 32); err == nil {
+				if runes[i] == 'x' {
+					word.WriteByte(byte(value))
+				} else {
+					word.WriteRune(rune(value))
+				}
+				i = end - 1
+				continue
+			}
+		}
+		word.WriteRune('\\')
+		word.WriteRune(runes[i])
+	}
+	return 0, fmt.Errorf("%w: unterminated $'...' quote", ErrInvalidInput)
+}
+
+// curlOptionsWithValue lists every supported or skipped cURL option that
+// consumes the following word, keyed by long name with short aliases mapped
+// in curlShortOptions.
+var curlOptionsWithValue = map[string]bool{
+	"--request": true, "--header": true, "--data": true, "--data-raw": true,
+	"--data-ascii": true, "--data-binary": true, "--data-urlencode": true,
+	"--json": true, "--form": true, "--form-string": true, "--user": true,
+	"--user-agent": true, "--referer": true, "--cookie": true, "--url": true,
+	"--max-time": true, "--output": true, "--write-out": true,
+	"--connect-timeout": true, "--retry": true, "--proxy": true,
+	"--cacert": true, "--cert": true, "--key": true, "--cookie-jar": true,
+	"--max-redirs": true, "--resolve": true, "--interface": true,
+}
+
+var curlShortOptions = map[byte]string{
+	'X': "--request", 'H': "--header", 'd': "--data", 'F': "--form",
+	'u': "--user", 'A': "--user-agent", 'e': "--referer", 'b': "--cookie",
+	'm': "--max-time", 'o': "--output", 'w': "--write-out", 'x': "--proxy",
+	'E': "--cert", 'c': "--cookie-jar", 'k': "--insecure", 'L': "--location",
+	'I': "--head", 'G': "--get", 's': "--silent", 'S': "--show-error",
+	'v': "--verbose", 'i': "--include", 'f': "--fail", 'g': "--globoff",
+	'N': "--no-buffer", 'O': "--remote-name", 'Z': "--parallel",
+}
+
+// curlIgnoredOptions only change how curl prints or stores the result and
+// have no equivalent here.
+var curlIgnoredOptions = map[string]bool{
+	"--silent": true, "--show-error": true, "--verbose": true, "--include": true,
+	"--fail": true, "--globoff": true, "--no-buffer": true, "--progress-bar": true,
+	"--output": true, "--write-out": true, "--remote-name": true, "--location": true,
+	"--max-redirs": true, "--retry": true, "--cookie-jar": true, "--connect-timeout": true,
+	"--parallel": true, "--no-progress-meter": true,
+}
+
+// curlCommand collects what the options of a cURL command line ask for.
+type curlCommand struct {
+	method     string
+	url        string
+	headers    http.Header
+	data       []string
+	formParts  []string
+	get        bool
+	head       bool
+	compressed bool
+	jsonBody   bool
+	timeout    int
+	version    string
+	warnings   []string
+}
+
+func (c *curlCommand) warn(format string, args ...any) {
+	c.warnings = append(c.warnings, fmt.Sprintf(format, args...))
+}
+
+// expandCurlOptions normalizes short options to their long names, splitting
+// bundles like -sSL and attached values like -XPOST or --header=value.
+func expandCurlOptions(words []string) []string {
+	var expanded []string
+	for _, word := range words {
+		switch {
+		case strings.HasPrefix(word, "--"):
+			if name, value, ok := strings.Cut(word, "="); ok && curlOptionsWithValue[name] {
+				expanded = append(expanded, name, value)
+				continue
+			}
+			expanded = append(expanded, word)
+		case len(word) > 1 && word[0] == '-':
+			for i := 1; i < len(word); i++ {
+				long, ok := curlShortOptions[word[i]]
+				if !ok {
+					expanded = append(expanded, "-"+string(word[i]))
+					continue
+				}
+				expanded = append(expanded, long)
+				if curlOptionsWithValue[long] {
+					if i+1 < len(word) {
+						expanded = append(expanded, word[i+1:])
+					}
+					break
+				}
+			}
+		default:
+			expanded = append(expanded, word)
+		}
+	}
+	return expanded
+}
+
+// parseCurlCommand converts a cURL command line into a request definition.
+// Options that cannot be represented are reported as warnings instead of
+// failing the import.
+func parseCurlCommand(command string) (*model.DTOCurlImportResponse, error) {
+	words, err := splitShellWords(strings.TrimSpace(command))
+	if err != nil {
+		return nil, err
+	}
+	if len(words) == 0 || words[0] != "curl" {
+		return nil, fmt.Errorf("%w: command must start with curl", ErrInvalidInput)
+	}
+
+	cmd := &curlCommand{headers: make(http.Header)}
+	args := expandCurlOptions(words[1:])
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			if cmd.url != "" {
+				cmd.warn("ignored extra URL %q, only the first URL is imported", arg)
+				continue
+			}
+			cmd.url = arg
+			continue
+		}
+
+		value := ""
+		if curlOptionsWithValue[arg] {
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("%w: option %s requires a value", ErrInvalidInput, arg)
+			}
+			i++
+			value = args[i]
+		}
+		if err := cmd.apply(arg, value); err != nil {
+			return nil, err
+		}
+	}
+
+	return cmd.toDTO()
+}
+
+func (c *curlCommand) apply(option, value string) error {
+	switch option {
+	case "--request":
+		c.method = strings.ToUpper(value)
+	case "--header":
+		name, headerValue, ok := strings.Cut(value, ":")
+		if !ok {
+			// "Name;" sends an empty header, "Name" alone removes one.
+			if strings.HasSuffix(name, ";") {
+				c.headers.Add(strings.TrimSuffix(name, ";"), "")
+			}
+			return nil
+		}
+		c.headers.Add(strings.TrimSpace(name), strings.TrimSpace(headerValue))
+	case "--data", "--data-raw", "--data-ascii", "--data-binary":
+		if strings.HasPrefix(value, "@") && option != "--data-raw" {
+			c.warn("%s %s reads a local file, which cannot be imported", option, value)
+			return nil
+		}
+		c.data = append(c.data, value)
+	case "--json":
+		if strings.HasPrefix(value, "@") {
+			c.warn("--json %s reads a local file, which cannot be imported", value)
+			return nil
+		}
+		c.data = append(c.data, value)
+		c.jsonBody = true
+	case "--data-urlencode":
+		c.data = append(c.data, c.urlEncodeData(value))
+	case "--form", "--form-string":
+		c.formParts = append(c.formParts, value)
+	case "--user":
+		user, password, _ := strings.Cut(value, ":")
+		credentials := base64.StdEncoding.EncodeToString([]byte(user + ":" + password))
+		c.headers.Set("Authorization", "Basic "+credentials)
+	case "--user-agent":
+		c.headers.Set("User-Agent", value)
+	case "--referer":
+		c.headers.Set("Referer", value)
+	case "--cookie":
+		if !strings.Contains(value, "=") {
+			c.warn("--cookie %s reads a cookie file, which cannot be imported", value)
+			return nil
+		}
+		c.headers.Add("Cookie", value)
+	case "--url":
+		if c.url == "" {
+			c.url = value
+		}
+	case "--max-time":
+		seconds, err := strconv.ParseFloat(value, 64)
+		if err != nil || seconds < 0 {
+			return fmt.Errorf("%w: invalid --max-time value %q", ErrInvalidInput, value)
+		}
+		c.timeout = int(seconds * 1000)
+		if c.timeout > int(maxRequestTimeout.Milliseconds()) {
+			c.warn("--max-time %s exceeds the maximum of %v and was lowered", value, maxRequestTimeout)
+			c.timeout = int(maxRequestTimeout.Milliseconds())
+		}
+	case "--get":
+		c.get = true
+	case "--head":
+		c.head = true
+	case "--compressed":
+		c.compressed = true
+	case "--http1.1":
+		c.version = model.HTTPVersion1
+	case "--http2":
+		c.version = model.HTTPVersionAuto
+	case "--http2-prior-knowledge":
+		c.version = model.HTTPVersionH2C
+	case "--insecure":
+		c.warn("--insecure is not supported, TLS certificates are always verified")
+	case "--proxy":
+		c.warn("--proxy is not supported, the request is sent directly")
+	case "--cacert", "--cert", "--key":
+		c.warn("%s is not supported, client certificates and custom CAs cannot be imported", option)
+	case "--resolve", "--interface":
+		c.warn("%s is not supported and was ignored", option)
+	default:
+		if !curlIgnoredOptions[option] {
+			c.warn("unknown option %s was ignored", option)
+		}
+	}
+	return nil
+}
+
+// urlEncodeData implements the content forms of --data-urlencode:
+// "content", "=content", "name=content", "@file" and "name@file".
+func (c *curlCommand) urlEncodeData(value string) string {
+	if eq := strings.IndexByte(value, '='); eq >= 0 {
+		name := value[:eq]
+		if name == "" {
+			return url.QueryEscape(value[eq+1:])
+		}
+		return name + "=" + url.QueryEscape(value[eq+1:])
+	}
+	if at := strings.IndexByte(value, '@'); at >= 0 {
+		c.warn("--data-urlencode %s reads a local file, which cannot be imported", value)
+		return ""
+	}
+	return url.QueryEscape(value)
+}
+
+// multipartBody builds the body for -F parts. Parts that upload local files
+// are sent with an empty file and reported, since the files are not
+// available to the server.
+func (c *curlCommand) multipartBody() (string, string, error) {
+	var body strings.Builder
+	writer := multipart.NewWriter(&body)
+	if err := writer.SetBoundary(curlMultipartBoundary); err != nil {
+		return "", "", err
+	}
+
+	for _, part := range c.formParts {
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return "", "", fmt.Errorf("%w: invalid form part %q, expected name=value", ErrInvalidInput, part)
+		}
+
+		// Attributes such as ;type= and ;filename= follow the value.
+		value, attributes, _ := strings.Cut(value, ";")
+		contentType, filename := "", ""
+		for _, attribute := range strings.Split(attributes, ";") {
+			key, attrValue, _ := strings.Cut(strings.TrimSpace(attribute), "=")
+			switch key {
+			case "type":
+				contentType = attrValue
+			case "filename":
+				filename = strings.Trim(attrValue, `"`)
+			}
+		}
+
+		if strings.HasPrefix(value, "@") || strings.HasPrefix(value, "<") {
+			c.warn("form part %q reads local file %s, which cannot be imported, an empty file was used", name, value[1:])
+			if filename == "" && strings.HasPrefix(value, "@") {
+				filename = value[1:]
+			}
+			value = ""
+		}
+
+		header := make(map[string][]string)
+		disposition := fmt.Sprintf(`form-data; name="%s"`, escapeQuotes(name))
+		if filename != "" {
+			disposition += fmt.Sprintf(`; filename="%s"`, escapeQuotes(filename))
+			if contentType == "" {
+				contentType = "application/octet-stream"
+			}
+		}
+		header["Content-Disposition"] = []string{disposition}
+		if contentType != "" {
+			header["Content-Type"] = []string{contentType}
+		}
+		partWriter, err := writer.CreatePart(header)
+		if err != nil {
+			return "", "", err
+		}
+		if _, err := partWriter.Write([]byte(value)); err != nil {
+			return "", "", err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return "", "", err
+	}
+	return body.String(), writer.FormDataContentType(), nil
+}
+
+func escapeQuotes(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
+
+func (c *curlCommand) toDTO() (*model.DTOCurlImportResponse, error) {
+	if c.url == "" {
+		return nil, fmt.Errorf("%w: the command does not contain a URL", ErrInvalidInput)
+	}
+	rawURL := c.url
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to parse URL: %v", ErrInvalidInput, err)
+	}
+
+	if len(c.data) > 0 && len(c.formParts) > 0 {
+		return nil, fmt.Errorf("%w: -d and -F cannot be used together", ErrInvalidInput)
+	}
+
+	dto := model.DTORequest{Headers: c.headers, Timeout: c.timeout, HTTPVersion: c.version}
+	method := http.MethodGet
+	data := strings.Join(c.data, "&")
+
+	switch {
+	case c.get:
+		// -G moves the data into the query string.
+		if data != "" {
+			if parsedURL.RawQuery != "" {
+				parsedURL.RawQuery += "&"
+			}
+			parsedURL.RawQuery += data
+		}
+	case len(c.formParts) > 0:
+		method = http.MethodPost
+		body, contentType, err := c.multipartBody()
+		if err != nil {
+			return nil, err
+		}
+		dto.BodyText = body
+		if c.headers.Get("Content-Type") == "" {
+			c.headers.Set("Content-Type", contentType)
+		}
+	case len(c.data) > 0:
+		method = http.MethodPost
+		if c.jsonBody {
+			if c.headers.Get("Content-Type") == "" {
+				c.headers.Set("Content-Type", "application/json")
+			}
+			if c.headers.Get("Accept") == "" {
+				c.headers.Set("Accept", "application/json")
+			}
+		} else if c.headers.Get("Content-Type") == "" {
+			c.headers.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		setCurlBody(&dto, data)
+	}
+	if c.head {
+		method = http.MethodHead
+	}
+	if c.method != "" {
+		method = c.method
+	}
+	dto.Method = method
+	dto.URL = parsedURL.String()
+
+	if c.compressed && c.headers.Get("Accept-Encoding") == "" {
+		c.headers.Set("Accept-Encoding", "gzip, deflate, br, zstd")
+	}
+	if c.headers.Get("Cookie") != "" {
+		c.warn("the Cookie header was imported but is not forwarded to target servers")
+	}
+	if c.version == model.HTTPVersionH2C && parsedURL.Scheme == "https" {
+		dto.HTTPVersion = model.HTTPVersion2
+	}
+	if len(c.headers) == 0 {
+		dto.Headers = nil
+	}
+
+	return &model.DTOCurlImportResponse{
+		Request:  dto,
+		Warnings: c.warnings,
+	}, nil
+}
+
+// setCurlBody stores data in the body field that sends it unchanged: text,
+// JSON included, in body_text and anything else in body_base64. JSON is not
+// put in body, which would compact and re-escape it.
+func setCurlBody(dto *model.DTORequest, data string) {
+	if utf8.ValidString(data) {
+		dto.BodyText = data
+		return
+	}
+	dto.BodyBase64 = base64.StdEncoding.EncodeToString([]byte(data))
+}
+
+// curlToSavedRequest converts an imported request into a collection item.
+// Saved requests keep bodies as text, so a binary body is dropped with a
+// warning.
+func curlToSavedRequest(dto *model.DTORequest, name string, warnings *[]string) *model.CollectionItem {
+	request := &model.CollectionRequest{Method: dto.Method, URL: dto.URL}
+
+	names := make([]string, 0, len(dto.Headers))
+	for key := range dto.Headers {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+	for _, key := range names {
+		for _, value := range dto.Headers[key] {
+			request.Headers = append(request.Headers, model.KeyValue{Key: key, Value: value})
+		}
+	}
+
+	switch {
+	case dto.BodyText != "":
+		request.Body = &model.RequestBody{Mode: model.BodyModeRaw, Raw: dto.BodyText}
+	case dto.BodyBase64 != "":
+		*warnings = append(*warnings, "the binary body cannot be stored in a saved request and was dropped")
+	}
+
+	if strings.TrimSpace(name) == "" {
+		name = truncateName(dto.Method + " " + dto.URL)
+	}
+	return &model.CollectionItem{Type: model.CollectionItemRequest, Name: name, Request: request}
+}
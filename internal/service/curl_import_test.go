@@ -0,0 +1,218 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/suar-net/suar-be/internal/model"
+	"github.com/suar-net/suar-be/internal/repository"
+)
+
+func TestSplitShellWords(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []string
+		wantErr bool
+	}{
+		{`curl https://a.test`, []string{"curl", "https://a.test"}, false},
+		{`curl -H 'X-A: b c' "x\"y"`, []string{"curl", "-H", "X-A: b c", `x"y`}, false},
+		{"curl \\\n  -d a", []string{"curl", "-d", "a"}, false},
+		{"curl \\\r\n  -d a", []string{"curl", "-d", "a"}, false},
+		{`curl $'a\nb\x41\u00e9'`, []string{"curl", "a\nbAé"}, false},
+		{`curl a\ b`, []string{"curl", "a b"}, false},
+		{`curl ''`, []string{"curl", ""}, false},
+		{`curl 'open`, nil, true},
+		{`curl "open`, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := splitShellWords(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("splitShellWords(%q) err = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitShellWords(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestExpandCurlOptions(t *testing.T) {
+	got := expandCurlOptions([]string{"-sSL", "-XPOST", "--header=A: b", "-H", "C: d", "url"})
+	want := []string{"--silent", "--show-error", "--location", "--request", "POST", "--header", "A: b", "--header", "C: d", "url"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expandCurlOptions = %q, want %q", got, want)
+	}
+}
+
+func TestParseCurlCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		command  string
+		method   string
+		url      string
+		headers  http.Header
+		bodyText string
+		base64   string
+		warnings int
+	}{
+		{"plain get", `curl https://a.test/x`, "GET", "https://a.test/x", nil, "", "", 0},
+		{"scheme added", `curl a.test`, "GET", "http://a.test", nil, "", "", 0},
+		{"method and headers", `curl -X put https://a.test -H 'X-A: 1' -H 'X-A: 2'`, "PUT", "https://a.test",
+			http.Header{"X-A": {"1", "2"}}, "", "", 0},
+		{"json body kept as text", `curl https://a.test -H 'Content-Type: application/json' -d '{"b": "<&>",  "a": 1}'`, "POST", "https://a.test",
+			http.Header{"Content-Type": {"application/json"}}, `{"b": "<&>",  "a": 1}`, "", 0},
+		{"form data", `curl https://a.test -d a=1 -d b=2`, "POST", "https://a.test",
+			http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}, "a=1&b=2", "", 0},
+		{"data-urlencode", `curl https://a.test --data-urlencode 'q=a b&c' --data-urlencode '=x y'`, "POST", "https://a.test",
+			http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}, "q=a+b%26c&x+y", "", 0},
+		{"json option", `curl https://a.test --json '{"a":1}'`, "POST", "https://a.test",
+			http.Header{"Content-Type": {"application/json"}, "Accept": {"application/json"}}, `{"a":1}`, "", 0},
+		{"binary body", `curl https://a.test --data-binary $'\xff\xfe'`, "POST", "https://a.test",
+			http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}, "", "//4=", 0},
+		{"get moves data to the query", `curl -G https://a.test?x=1 -d y=2`, "GET", "https://a.test?x=1&y=2", nil, "", "", 0},
+		{"head", `curl -I https://a.test`, "HEAD", "https://a.test", nil, "", "", 0},
+		{"basic auth", `curl -u ana:secret https://a.test`, "GET", "https://a.test",
+			http.Header{"Authorization": {"Basic YW5hOnNlY3JldA=="}}, "", "", 0},
+		{"compressed", `curl --compressed https://a.test`, "GET", "https://a.test",
+			http.Header{"Accept-Encoding": {"gzip, deflate, br, zstd"}}, "", "", 0},
+		{"insecure and location", `curl -kL https://a.test`, "GET", "https://a.test", nil, "", "", 1},
+		{"cookies", `curl -b 'a=1; b=2' https://a.test`, "GET", "https://a.test",
+			http.Header{"Cookie": {"a=1; b=2"}}, "", "", 1},
+		{"cookie file", `curl -b jar.txt https://a.test`, "GET", "https://a.test", nil, "", "", 1},
+		{"local data file", `curl -d @body.json https://a.test`, "GET", "https://a.test", nil, "", "", 1},
+		{"unknown option", `curl --frobnicate https://a.test`, "GET", "https://a.test", nil, "", "", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCurlCommand(tt.command)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := got.Request
+			if req.Method != tt.method || req.URL != tt.url {
+				t.Errorf("request = %s %s, want %s %s", req.Method, req.URL, tt.method, tt.url)
+			}
+			if len(req.Headers) > 0 || tt.headers != nil {
+				if !reflect.DeepEqual(http.Header(req.Headers), tt.headers) {
+					t.Errorf("headers = %v, want %v", req.Headers, tt.headers)
+				}
+			}
+			if req.Body != nil || req.BodyText != tt.bodyText || req.BodyBase64 != tt.base64 {
+				t.Errorf("body %q, text %q, base64 %q", req.Body, req.BodyText, req.BodyBase64)
+			}
+			if len(got.Warnings) != tt.warnings {
+				t.Errorf("warnings = %q, want %d", got.Warnings, tt.warnings)
+			}
+		})
+	}
+}
+
+func TestParseCurlCommandMultipart(t *testing.T) {
+	got, err := parseCurlCommand(`curl https://a.test -F name=ana -F 'file=@photo.png;type=image/png'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := got.Request
+	if req.Method != "POST" || req.Headers["Content-Type"][0] != "multipart/form-data; boundary="+curlMultipartBoundary {
+		t.Errorf("request = %s, headers %v", req.Method, req.Headers)
+	}
+	for _, want := range []string{`name="name"`, "ana", `name="file"; filename="photo.png"`, "Content-Type: image/png"} {
+		if !strings.Contains(req.BodyText, want) {
+			t.Errorf("body does not contain %q:\n%s", want, req.BodyText)
+		}
+	}
+	if len(got.Warnings) != 1 {
+		t.Errorf("warnings = %q, want the local file to be reported", got.Warnings)
+	}
+}
+
+func TestParseCurlCommandErrors(t *testing.T) {
+	for _, command := range []string{
+		"",
+		"wget https://a.test",
+		"curl -X",
+		"curl -H 'X: 1'",
+		"curl https://a.test -d a -F b=c",
+		"curl https://a.test -F nameonly",
+		"curl https://a.test -m soon",
+	} {
+		if _, err := parseCurlCommand(command); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("parseCurlCommand(%q) = %v, want ErrInvalidInput", command, err)
+		}
+	}
+}
+
+func TestCurlToSavedRequest(t *testing.T) {
+	var warnings []string
+	dto := &model.DTORequest{
+		Method:   "POST",
+		URL:      "https://a.test/{{id}}",
+		Headers:  map[string][]string{"X-B": {"2"}, "Content-Type": {"application/json"}},
+		BodyText: `{"a": 1}`,
+	}
+	item := curlToSavedRequest(dto, "", &warnings)
+	want := &model.CollectionRequest{
+		Method:  "POST",
+		URL:     "https://a.test/{{id}}",
+		Headers: []model.KeyValue{{Key: "Content-Type", Value: "application/json"}, {Key: "X-B", Value: "2"}},
+		Body:    &model.RequestBody{Mode: model.BodyModeRaw, Raw: `{"a": 1}`},
+	}
+	if item.Name != "POST https://a.test/{{id}}" || item.Type != model.CollectionItemRequest || !reflect.DeepEqual(item.Request, want) {
+		t.Errorf("item = %+v, request %+v", item, item.Request)
+	}
+
+	binary := curlToSavedRequest(&model.DTORequest{Method: "PUT", URL: "https://a.test", BodyBase64: "AAE="}, "Upload", &warnings)
+	if binary.Name != "Upload" || binary.Request.Body != nil || len(warnings) != 1 {
+		t.Errorf("binary item = %+v, warnings %q", binary, warnings)
+	}
+}
+
+type fakeCollectionRepo struct {
+	repository.ICollectionRepository
+	added   map[int][]*model.CollectionItem
+	created []*model.Collection
+}
+
+func (f *fakeCollectionRepo) Create(ctx context.Context, collection *model.Collection) (int, error) {
+	f.created = append(f.created, collection)
+	return len(f.created), nil
+}
+
+func (f *fakeCollectionRepo) AddItem(ctx context.Context, collectionID, userID int, item *model.CollectionItem) (bool, error) {
+	if userID != 1 || collectionID != 10 {
+		return false, nil
+	}
+	item.ID = len(f.added[collectionID]) + 100
+	f.added[collectionID] = append(f.added[collectionID], item)
+	return true, nil
+}
+
+func TestImportCurlSavesRequest(t *testing.T) {
+	repo := &fakeCollectionRepo{added: map[int][]*model.CollectionItem{}}
+	s := NewImportService(repo, nil, nil)
+	ctx := context.Background()
+	user, other := 1, 2
+	collection := 10
+
+	got, err := s.ImportCurl(ctx, &user, &model.DTOCurlImportRequest{Command: "curl https://a.test", CollectionID: &collection, Name: "Home"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Saved == nil || got.Saved.ID != 100 || got.Saved.Name != "Home" || len(repo.added[10]) != 1 {
+		t.Errorf("saved = %+v, added %v", got.Saved, repo.added)
+	}
+
+	if got, err := s.ImportCurl(ctx, nil, &model.DTOCurlImportRequest{Command: "curl https://a.test"}); err != nil || got.Saved != nil {
+		t.Errorf("import without collection = %+v, %v", got, err)
+	}
+	for _, userID := range []*int{&other, nil} {
+		_, err := s.ImportCurl(ctx, userID, &model.DTOCurlImportRequest{Command: "curl https://a.test", CollectionID: &collection})
+		if !errors.Is(err, ErrCollectionNotFound) {
+			t.Errorf("saving for user %v = %v, want ErrCollectionNotFound", userID, err)
+		}
+	}
+}
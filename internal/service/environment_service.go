@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/suar-net/suar-be/internal/model"
+	"github.com/suar-net/suar-be/internal/repository"
+)
+
+type environmentService struct {
+	repository repository.IEnvironmentRepository
+}
+
+func NewEnvironmentService(r repository.IEnvironmentRepository) IEnvironmentService {
+	return &environmentService{repository: r}
+}
+
+func (s *environmentService) ListEnvironments(ctx context.Context, userID int) ([]*model.Environment, error) {
+	environments, err := s.repository.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list environments: %w", err)
+	}
+	if environments == nil {
+		environments = []*model.Environment{}
+	}
+	return environments, nil
+}
+
+func (s *environmentService) GetEnvironment(ctx context.Context, id int, userID int) (*model.Environment, error) {
+	environment, err := s.repository.GetByID(ctx, id, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load environment: %w", err)
+	}
+	if environment == nil {
+		return nil, ErrEnvironmentNotFound
+	}
+	return environment, nil
+}
+
+func (s *environmentService) DeleteEnvironment(ctx context.Context, id int, userID int) error {
+	deleted, err := s.repository.Delete(ctx, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete environment: %w", err)
+	}
+	if !deleted {
+		return ErrEnvironmentNotFound
+	}
+	return nil
+}
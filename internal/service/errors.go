@@ -5,6 +5,31 @@ import "errors"
 var (
 	ErrInvalidInput   = errors.New("invalid input")
 	ErrRequestTimeout = errors.New("request timeout")
+	ErrUpstreamFailed = errors.New("target server connection failed")
+
+	ErrResponseNotFound   = errors.New("response not found or expired")
+	ErrStreamNotFound     = errors.New("stream not found or already finished")
+	ErrStreamLimitReached = errors.New("too many streams open")
+	ErrHistoryNotFound    = errors.New("history entry not found")
+
+	ErrCollectionNotFound  = errors.New("collection not found")
+	ErrEnvironmentNotFound = errors.New("environment not found")
+	ErrItemNotFound        = errors.New("saved request not found")
+	ErrSpecNotFound        = errors.New("spec not found")
+	ErrFolderNotFound      = errors.New("folder not found")
+	ErrRunNotFound         = errors.New("run not found")
+	ErrRunLimitReached     = errors.New("too many runs in progress")
+	ErrMonitorNotFound     = errors.New("monitor not found")
+	ErrMonitorLimitReached = errors.New("monitor limit reached")
+	ErrAlertNotFound       = errors.New("alert not found")
+	ErrAlertLimitReached   = errors.New("alert limit reached")
+	ErrMockNotFound        = errors.New("mock server not found")
+	ErrMockLimitReached    = errors.New("mock server limit reached")
+	ErrBinNotFound         = errors.New("request bin not found")
+	ErrBinLimitReached     = errors.New("request bin limit reached")
+
+	ErrLoadTestNotAllowed   = errors.New("load testing is not allowed")
+	ErrLoadTestLimitReached = errors.New("too many load tests in progress")
 
 	// Auth-related errors
 	ErrInvalidCredentials = errors.New("invalid credentials")
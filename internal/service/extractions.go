@@ -0,0 +1,125 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/ohler55/ojg/jp"
+	"github.com/suar-net/suar-be/internal/model"
+)
+
+// compiledExtraction is an extraction rule with its expression parsed.
+type compiledExtraction struct {
+	model.Extraction
+	jsonPath jp.Expr
+	pattern  *regexp.Regexp
+}
+
+// compileExtractions checks extraction rules, so mistakes are reported when
+// they are saved rather than on every run.
+func compileExtractions(extractions []model.Extraction) ([]*compiledExtraction, error) {
+	compiled := make([]*compiledExtraction, 0, len(extractions))
+	for i, extraction := range extractions {
+		c := &compiledExtraction{Extraction: extraction}
+		if strings.TrimSpace(extraction.Variable) == "" || strings.ContainsAny(extraction.Variable, "{}") {
+			return nil, fmt.Errorf("%w: extraction %d: invalid variable name %q", ErrInvalidInput, i+1, extraction.Variable)
+		}
+		switch extraction.Source {
+		case model.ExtractionSourceJSONPath:
+			expr, err := jp.ParseString(extraction.Expression)
+			if err != nil {
+				return nil, fmt.Errorf("%w: extraction %d: invalid JSONPath %q: %v", ErrInvalidInput, i+1, extraction.Expression, err)
+			}
+			c.jsonPath = expr
+		case model.ExtractionSourceRegex:
+			pattern, err := regexp.Compile(extraction.Expression)
+			if err != nil {
+				return nil, fmt.Errorf("%w: extraction %d: invalid regular expression: %v", ErrInvalidInput, i+1, err)
+			}
+			c.pattern = pattern
+		case model.ExtractionSourceHeader, model.ExtractionSourceCookie:
+			if strings.TrimSpace(extraction.Expression) == "" {
+				return nil, fmt.Errorf("%w: extraction %d: expression must name the %s", ErrInvalidInput, i+1, extraction.Source)
+			}
+		default:
+			return nil, fmt.Errorf("%w: extraction %d: unknown source %q", ErrInvalidInput, i+1, extraction.Source)
+		}
+		compiled = append(compiled, c)
+	}
+	return compiled, nil
+}
+
+// extractValues applies extraction rules to a response. Values that are not
+// strings are stored as JSON, so a number 42 becomes "42".
+func extractValues(extractions []*compiledExtraction, response *model.DTOResponse) []model.DTOExtraction {
+	body := &assertionBody{response: response}
+	results := make([]model.DTOExtraction, 0, len(extractions))
+	for _, extraction := range extractions {
+		result := model.DTOExtraction{Variable: extraction.Variable}
+		value, found, err := extraction.extract(response, body)
+		switch {
+		case err != nil:
+			result.Error = err.Error()
+		case !found:
+			result.Error = fmt.Sprintf("%s %q did not match the response", extraction.Source, extraction.Expression)
+		default:
+			result.Found = true
+			result.Value = value
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+func (e *compiledExtraction) extract(response *model.DTOResponse, body *assertionBody) (string, bool, error) {
+	switch e.Source {
+	case model.ExtractionSourceJSONPath:
+		data, err := body.parseJSON()
+		if err != nil {
+			return "", false, err
+		}
+		matches := e.jsonPath.Get(data)
+		if len(matches) == 0 {
+			return "", false, nil
+		}
+		if text, ok := matches[0].(string); ok {
+			return text, true, nil
+		}
+		encoded, err := json.Marshal(matches[0])
+		if err != nil {
+			return "", false, err
+		}
+		return string(encoded), true, nil
+	case model.ExtractionSourceRegex:
+		if err := body.check(); err != nil {
+			return "", false, err
+		}
+		match := e.pattern.FindSubmatch(response.Body)
+		if match == nil {
+			return "", false, nil
+		}
+		if len(match) > 1 {
+			return string(match[1]), true, nil
+		}
+		return string(match[0]), true, nil
+	case model.ExtractionSourceHeader:
+		values := http.Header(response.Headers).Values(e.Expression)
+		if len(values) == 0 {
+			return "", false, nil
+		}
+		return values[0], true, nil
+	case model.ExtractionSourceCookie:
+		// A later Set-Cookie for the same name replaces an earlier one.
+		value, found := "", false
+		for _, cookie := range (&http.Response{Header: http.Header(response.Headers)}).Cookies() {
+			if cookie.Name == e.Expression {
+				value, found = cookie.Value, true
+			}
+		}
+		return value, found, nil
+	}
+	return "", false, nil
+}
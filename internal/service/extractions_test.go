@@ -0,0 +1,133 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/suar-net/suar-be/internal/model"
+)
+
+func TestCompileExtractions(t *testing.T) {
+	tests := []struct {
+		name       string
+		extraction model.Extraction
+		wantErr    string
+	}{
+		{"jsonpath", model.Extraction{Variable: "token", Source: "jsonpath", Expression: "$.token"}, ""},
+		{"regex", model.Extraction{Variable: "id", Source: "regex", Expression: `id=(\d+)`}, ""},
+		{"header", model.Extraction{Variable: "etag", Source: "header", Expression: "ETag"}, ""},
+		{"empty variable", model.Extraction{Variable: " ", Source: "header", Expression: "ETag"}, "invalid variable name"},
+		{"braces in variable", model.Extraction{Variable: "{{a}}", Source: "header", Expression: "ETag"}, "invalid variable name"},
+		{"bad JSONPath", model.Extraction{Variable: "a", Source: "jsonpath", Expression: "$.["}, "invalid JSONPath"},
+		{"bad pattern", model.Extraction{Variable: "a", Source: "regex", Expression: "("}, "invalid regular expression"},
+		{"cookie without name", model.Extraction{Variable: "a", Source: "cookie", Expression: " "}, "must name the cookie"},
+		{"unknown source", model.Extraction{Variable: "a", Source: "xpath", Expression: "//a"}, "unknown source"},
+	}
+	for _, tt := range tests {
+		_, err := compileExtractions([]model.Extraction{tt.extraction})
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%s: err = %v", tt.name, err)
+		case tt.wantErr != "" && (!errors.Is(err, ErrInvalidInput) || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestExtractValues(t *testing.T) {
+	response := &model.DTOResponse{
+		Headers: http.Header{
+			"Etag":       {`"v1"`, `"v2"`},
+			"Set-Cookie": {"session=old; Path=/", "theme=dark", "session=new; HttpOnly"},
+		},
+		Body: []byte(`{"token": "abc", "user": {"id": 42, "roles": ["admin"]}, "next": null}`),
+	}
+	tests := []struct {
+		name       string
+		response   *model.DTOResponse
+		extraction model.Extraction
+		want       model.DTOExtraction
+	}{
+		{"json string", response, model.Extraction{Source: "jsonpath", Expression: "$.token"},
+			model.DTOExtraction{Found: true, Value: "abc"}},
+		{"json number", response, model.Extraction{Source: "jsonpath", Expression: "$.user.id"},
+			model.DTOExtraction{Found: true, Value: "42"}},
+		{"json array", response, model.Extraction{Source: "jsonpath", Expression: "$.user.roles"},
+			model.DTOExtraction{Found: true, Value: `["admin"]`}},
+		{"json null", response, model.Extraction{Source: "jsonpath", Expression: "$.next"},
+			model.DTOExtraction{Found: true, Value: "null"}},
+		{"json missing", response, model.Extraction{Source: "jsonpath", Expression: "$.missing"},
+			model.DTOExtraction{Error: `jsonpath "$.missing" did not match the response`}},
+		{"regex group", response, model.Extraction{Source: "regex", Expression: `"id": (\d+)`},
+			model.DTOExtraction{Found: true, Value: "42"}},
+		{"regex whole match", response, model.Extraction{Source: "regex", Expression: `a.c`},
+			model.DTOExtraction{Found: true, Value: "abc"}},
+		{"first header value", response, model.Extraction{Source: "header", Expression: "etag"},
+			model.DTOExtraction{Found: true, Value: `"v1"`}},
+		{"missing header", response, model.Extraction{Source: "header", Expression: "X-Missing"},
+			model.DTOExtraction{Error: `header "X-Missing" did not match the response`}},
+		{"last cookie wins", response, model.Extraction{Source: "cookie", Expression: "session"},
+			model.DTOExtraction{Found: true, Value: "new"}},
+		{"missing cookie", response, model.Extraction{Source: "cookie", Expression: "lang"},
+			model.DTOExtraction{Error: `cookie "lang" did not match the response`}},
+		{"invalid json", &model.DTOResponse{Body: []byte("<html>")}, model.Extraction{Source: "jsonpath", Expression: "$.a"},
+			model.DTOExtraction{Error: "the body is not valid JSON: invalid character '<' looking for beginning of value"}},
+		{"truncated body", &model.DTOResponse{Body: []byte("id=1"), Truncated: true}, model.Extraction{Source: "regex", Expression: `id=(\d)`},
+			model.DTOExtraction{Error: "the body was too large to be kept in full"}},
+	}
+	for _, tt := range tests {
+		tt.extraction.Variable = "v"
+		tt.want.Variable = "v"
+		compiled, err := compileExtractions([]model.Extraction{tt.extraction})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := extractValues(compiled, tt.response)[0]; !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: extracted %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCollectionRunnerExtract(t *testing.T) {
+	response := &model.DTOResponse{Body: []byte(`{"token": "abc"}`)}
+	extractions := []model.Extraction{
+		{Variable: "token", Source: "jsonpath", Expression: "$.token"},
+		{Variable: "missing", Source: "jsonpath", Expression: "$.missing"},
+	}
+	collection := &model.Collection{}
+
+	environment := &model.Environment{Name: "dev", Variables: []model.Variable{{Key: "token", Value: "old"}}}
+	runner := &collectionRunner{collection: collection, environment: environment, variables: newRunVariables(collection, environment)}
+	var result model.DTOItemRunResult
+	runner.extract(&result, extractions, response)
+	if value, _ := runner.variables.get(scopeEnvironment, "token"); value != "abc" || !runner.variables.environmentChanges.changed() {
+		t.Errorf("environment token = %q, changed = %v", value, runner.variables.environmentChanges.changed())
+	}
+	if _, ok := runner.variables.get(scopeEnvironment, "missing"); ok {
+		t.Error("a value that was not found was stored")
+	}
+	if len(result.Extracted) != 2 || len(result.Warnings) != 0 {
+		t.Errorf("result = %+v", result)
+	}
+
+	// Without an environment the value only lasts for the run.
+	runner = &collectionRunner{collection: collection, variables: newRunVariables(collection, nil)}
+	result = model.DTOItemRunResult{}
+	runner.extract(&result, extractions, response)
+	if value, _ := runner.variables.get(scopeLocal, "token"); value != "abc" || runner.variables.environmentChanges.changed() {
+		t.Errorf("local token = %q", value)
+	}
+	if len(result.Warnings) != 1 {
+		t.Errorf("warnings = %q", result.Warnings)
+	}
+
+	// Rules that no longer compile are reported, not applied.
+	result = model.DTOItemRunResult{}
+	runner.extract(&result, []model.Extraction{{Variable: "a", Source: "regex", Expression: "("}}, response)
+	if len(result.Warnings) != 1 || result.Extracted != nil {
+		t.Errorf("result = %+v", result)
+	}
+}
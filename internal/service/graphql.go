@@ -0,0 +1,566 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/suar-net/suar-be/internal/model"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+const graphQLSchemaTTL = 15 * time.Minute
+
+// introspectionQuery is the standard introspection query without the fields
+// added after the June 2018 spec, which older servers reject.
+const introspectionQuery = `query IntrospectionQuery {
+  __schema {
+    queryType { name }
+    mutationType { name }
+    subscriptionType { name }
+    types { ...FullType }
+    directives {
+      name
+      description
+      locations
+      args { ...InputValue }
+    }
+  }
+}
+
+fragment FullType on __Type {
+  kind
+  name
+  description
+  fields(includeDeprecated: true) {
+    name
+    description
+    args { ...InputValue }
+    type { ...TypeRef }
+    isDeprecated
+    deprecationReason
+  }
+  inputFields { ...InputValue }
+  interfaces { ...TypeRef }
+  enumValues(includeDeprecated: true) {
+    name
+    description
+    isDeprecated
+    deprecationReason
+  }
+  possibleTypes { ...TypeRef }
+}
+
+fragment InputValue on __InputValue {
+  name
+  description
+  type { ...TypeRef }
+  defaultValue
+}
+
+fragment TypeRef on __Type {
+  kind
+  name
+  ofType {
+    kind
+    name
+    ofType {
+      kind
+      name
+      ofType {
+        kind
+        name
+        ofType {
+          kind
+          name
+          ofType {
+            kind
+            name
+            ofType {
+              kind
+              name
+              ofType {
+                kind
+                name
+              }
+            }
+          }
+        }
+      }
+    }
+  }
+}`
+
+// The introspection* types mirror the JSON returned by introspectionQuery.
+type introspectionTypeRef struct {
+	Kind   string                `json:"kind"`
+	Name   *string               `json:"name"`
+	OfType *introspectionTypeRef `json:"ofType"`
+}
+
+type introspectionInputValue struct {
+	Name         string               `json:"name"`
+	Description  *string              `json:"description"`
+	Type         introspectionTypeRef `json:"type"`
+	DefaultValue *string              `json:"defaultValue"`
+}
+
+type introspectionField struct {
+	Name              string                    `json:"name"`
+	Description       *string                   `json:"description"`
+	Args              []introspectionInputValue `json:"args"`
+	Type              introspectionTypeRef      `json:"type"`
+	IsDeprecated      bool                      `json:"isDeprecated"`
+	DeprecationReason *string                   `json:"deprecationReason"`
+}
+
+type introspectionEnumValue struct {
+	Name              string  `json:"name"`
+	Description       *string `json:"description"`
+	IsDeprecated      bool    `json:"isDeprecated"`
+	DeprecationReason *string `json:"deprecationReason"`
+}
+
+type introspectionType struct {
+	Kind          string                    `json:"kind"`
+	Name          string                    `json:"name"`
+	Description   *string                   `json:"description"`
+	Fields        []introspectionField      `json:"fields"`
+	InputFields   []introspectionInputValue `json:"inputFields"`
+	Interfaces    []introspectionTypeRef    `json:"interfaces"`
+	EnumValues    []introspectionEnumValue  `json:"enumValues"`
+	PossibleTypes []introspectionTypeRef    `json:"possibleTypes"`
+}
+
+type introspectionDirective struct {
+	Name        string                    `json:"name"`
+	Description *string                   `json:"description"`
+	Locations   []string                  `json:"locations"`
+	Args        []introspectionInputValue `json:"args"`
+}
+
+type introspectionSchema struct {
+	QueryType        *struct{ Name string }   `json:"queryType"`
+	MutationType     *struct{ Name string }   `json:"mutationType"`
+	SubscriptionType *struct{ Name string }   `json:"subscriptionType"`
+	Types            []introspectionType      `json:"types"`
+	Directives       []introspectionDirective `json:"directives"`
+}
+
+type introspectionResponse struct {
+	Data *struct {
+		Schema *introspectionSchema `json:"__schema"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// builtinGraphQLNames are defined by the gqlparser prelude and must not be
+// declared again when rebuilding a schema from introspection.
+var builtinGraphQLNames = map[string]bool{
+	"Int": true, "Float": true, "String": true, "Boolean": true, "ID": true,
+	"defer": true, "include": true, "skip": true, "deprecated": true, "specifiedBy": true, "oneOf": true,
+}
+
+// maxGraphQLSchemas bounds the number of cached schemas across all users.
+const maxGraphQLSchemas = 256
+
+// graphQLCacheKey identifies a cached schema. Schemas are kept for
+// graphQLSchemaTTL, at most maxGraphQLSchemas of them. A schema can depend
+// on who is asking, so entries are scoped by the user and by the credentials
+// sent to the endpoint.
+type graphQLCacheKey struct {
+	userID      int
+	environment string
+	endpoint    string
+	credentials string
+}
+
+type cachedGraphQLSchema struct {
+	summary *model.DTOGraphQLSchema
+	schema  *ast.Schema
+}
+
+func newGraphQLCacheKey(userID int, environment, rawURL string, headers map[string][]string) graphQLCacheKey {
+	return graphQLCacheKey{
+		userID:      userID,
+		environment: environment,
+		endpoint:    graphQLEndpoint(rawURL),
+		credentials: credentialFingerprint(headers),
+	}
+}
+
+// credentialFingerprint hashes the headers that may identify the caller to
+// the endpoint, so a schema fetched with one token is not reused for another.
+// Other headers, such as Content-Type, do not split the cache.
+func credentialFingerprint(headers map[string][]string) string {
+	var lines []string
+	for name, values := range headers {
+		if !isCredentialHeader(name) {
+			continue
+		}
+		lines = append(lines, http.CanonicalHeaderKey(name)+": "+strings.Join(values, ", "))
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	sort.Strings(lines)
+	sum := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+func isCredentialHeader(name string) bool {
+	name = strings.ToLower(name)
+	if name == "cookie" {
+		return true
+	}
+	for _, part := range []string{"auth", "token", "key", "secret", "session"} {
+		if strings.Contains(name, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyGraphQLBody turns the graphql body mode into a regular request. POST
+// requests carry the standard JSON envelope, GET requests carry the same
+// fields as query parameters as described by GraphQL over HTTP.
+func applyGraphQLBody(dto *model.DTORequest) error {
+	if len(dto.Body) > 0 || dto.BodyText != "" || dto.BodyBase64 != "" {
+		return fmt.Errorf("%w: body and graphql cannot be used together", ErrInvalidInput)
+	}
+	gql := dto.GraphQL
+	if strings.TrimSpace(gql.Query) == "" {
+		return fmt.Errorf("%w: graphql query cannot be empty", ErrInvalidInput)
+	}
+
+	switch strings.ToUpper(dto.Method) {
+	case http.MethodGet:
+		parsedURL, err := url.Parse(dto.URL)
+		if err != nil {
+			return fmt.Errorf("%w: failed to parse URL: %v", ErrInvalidInput, err)
+		}
+		query := parsedURL.Query()
+		query.Set("query", gql.Query)
+		if len(gql.Variables) > 0 && string(gql.Variables) != "null" {
+			query.Set("variables", string(gql.Variables))
+		}
+		if gql.OperationName != "" {
+			query.Set("operationName", gql.OperationName)
+		}
+		parsedURL.RawQuery = query.Encode()
+		dto.URL = parsedURL.String()
+	case http.MethodPost:
+		envelope := struct {
+			Query         string          `json:"query"`
+			Variables     json.RawMessage `json:"variables,omitempty"`
+			OperationName string          `json:"operationName,omitempty"`
+		}{gql.Query, gql.Variables, gql.OperationName}
+		body, err := json.Marshal(envelope)
+		if err != nil {
+			return fmt.Errorf("%w: invalid graphql variables: %v", ErrInvalidInput, err)
+		}
+		dto.Body = body
+		if !hasHeader(dto.Headers, "Content-Type") {
+			if dto.Headers == nil {
+				dto.Headers = make(map[string][]string)
+			}
+			dto.Headers["Content-Type"] = []string{"application/json"}
+		}
+	default:
+		return fmt.Errorf("%w: graphql requests must use GET or POST", ErrInvalidInput)
+	}
+	return nil
+}
+
+func hasHeader(headers map[string][]string, name string) bool {
+	for key := range headers {
+		if http.CanonicalHeaderKey(key) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// validateGraphQLQuery checks a query against the schema the user cached for
+// its endpoint. Queries are not validated when no schema has been
+// introspected, so introspection stays opt-in.
+func (rs RequestService) validateGraphQLQuery(userID int, dto *model.DTORequest) error {
+	cached, ok := rs.graphQLSchemas.get(newGraphQLCacheKey(userID, dto.GraphQL.Environment, dto.URL, dto.Headers))
+	if !ok {
+		return nil
+	}
+
+	_, errs := gqlparser.LoadQuery(cached.schema, dto.GraphQL.Query)
+	if len(errs) == 0 {
+		return nil
+	}
+	messages := make([]string, 0, len(errs))
+	for _, err := range errs {
+		messages = append(messages, err.Message)
+	}
+	return fmt.Errorf("%w: graphql query does not match the schema: %s", ErrInvalidInput, strings.Join(messages, "; "))
+}
+
+// graphQLEndpoint normalises a URL to the part that identifies the endpoint,
+// so GET requests with different query strings share one cached schema.
+func graphQLEndpoint(rawURL string) string {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	parsedURL.RawQuery = ""
+	parsedURL.Fragment = ""
+	return parsedURL.String()
+}
+
+// IntrospectGraphQL returns the schema of a GraphQL endpoint, running the
+// introspection query only when there is no cached copy or a refresh is asked.
+func (rs RequestService) IntrospectGraphQL(ctx context.Context, userID int, dto *model.DTOGraphQLIntrospectionRequest) (*model.DTOGraphQLSchema, error) {
+	key := newGraphQLCacheKey(userID, dto.Environment, dto.URL, dto.Headers)
+	endpoint := key.endpoint
+	if !dto.Refresh {
+		if cached, ok := rs.graphQLSchemas.get(key); ok {
+			return cached.summary, nil
+		}
+	}
+
+	outboundRequest, err := rs.CreateOutboundRequest(&model.DTORequest{
+		Method:  http.MethodPost,
+		URL:     dto.URL,
+		Headers: dto.Headers,
+		GraphQL: &model.DTOGraphQLBody{Query: introspectionQuery, OperationName: "IntrospectionQuery"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	dtoResponse, err := rs.ExecuteRequest(ctx, outboundRequest)
+	if err != nil {
+		return nil, err
+	}
+	if dtoResponse.Error != "" {
+		return nil, fmt.Errorf("%w: %s", ErrUpstreamFailed, dtoResponse.Error)
+	}
+
+	var result introspectionResponse
+	if err := json.Unmarshal(dtoResponse.Body, &result); err != nil {
+		return nil, fmt.Errorf("%w: introspection returned status %d without a GraphQL response", ErrUpstreamFailed, dtoResponse.StatusCode)
+	}
+	if len(result.Errors) > 0 {
+		return nil, fmt.Errorf("%w: introspection failed: %s", ErrUpstreamFailed, result.Errors[0].Message)
+	}
+	if result.Data == nil || result.Data.Schema == nil {
+		return nil, fmt.Errorf("%w: introspection response has no schema", ErrUpstreamFailed)
+	}
+
+	schema, err := gqlparser.LoadSchema(&ast.Source{Name: endpoint, Input: introspectionToSDL(result.Data.Schema)})
+	if err != nil {
+		return nil, fmt.Errorf("%w: introspected schema is invalid: %v", ErrUpstreamFailed, err)
+	}
+
+	summary := summarizeSchema(result.Data.Schema)
+	summary.URL = endpoint
+	summary.Environment = dto.Environment
+	summary.FetchedAt = time.Now()
+	summary.ExpiresAt = summary.FetchedAt.Add(graphQLSchemaTTL)
+
+	rs.graphQLSchemas.put(key, &cachedGraphQLSchema{summary: summary, schema: schema}, summary.ExpiresAt)
+	return summary, nil
+}
+
+func typeRefString(ref introspectionTypeRef) string {
+	switch ref.Kind {
+	case "NON_NULL":
+		if ref.OfType != nil {
+			return typeRefString(*ref.OfType) + "!"
+		}
+	case "LIST":
+		if ref.OfType != nil {
+			return "[" + typeRefString(*ref.OfType) + "]"
+		}
+	}
+	if ref.Name != nil {
+		return *ref.Name
+	}
+	return ""
+}
+
+func typeRefNames(refs []introspectionTypeRef) []string {
+	names := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		names = append(names, typeRefString(ref))
+	}
+	return names
+}
+
+func summarizeInputValues(values []introspectionInputValue) []model.DTOGraphQLInputValue {
+	summary := make([]model.DTOGraphQLInputValue, 0, len(values))
+	for _, value := range values {
+		summary = append(summary, model.DTOGraphQLInputValue{
+			Name:         value.Name,
+			Description:  derefString(value.Description),
+			Type:         typeRefString(value.Type),
+			DefaultValue: value.DefaultValue,
+		})
+	}
+	return summary
+}
+
+// summarizeSchema flattens the introspection result into the shape used by
+// clients for autocomplete, with type references written as GraphQL types.
+func summarizeSchema(schema *introspectionSchema) *model.DTOGraphQLSchema {
+	summary := &model.DTOGraphQLSchema{}
+	if schema.QueryType != nil {
+		summary.QueryType = schema.QueryType.Name
+	}
+	if schema.MutationType != nil {
+		summary.MutationType = schema.MutationType.Name
+	}
+	if schema.SubscriptionType != nil {
+		summary.SubscriptionType = schema.SubscriptionType.Name
+	}
+
+	for _, t := range schema.Types {
+		typeSummary := model.DTOGraphQLType{
+			Kind:          t.Kind,
+			Name:          t.Name,
+			Description:   derefString(t.Description),
+			InputFields:   summarizeInputValues(t.InputFields),
+			Interfaces:    typeRefNames(t.Interfaces),
+			PossibleTypes: typeRefNames(t.PossibleTypes),
+		}
+		for _, f := range t.Fields {
+			typeSummary.Fields = append(typeSummary.Fields, model.DTOGraphQLField{
+				Name:              f.Name,
+				Description:       derefString(f.Description),
+				Type:              typeRefString(f.Type),
+				Args:              summarizeInputValues(f.Args),
+				IsDeprecated:      f.IsDeprecated,
+				DeprecationReason: derefString(f.DeprecationReason),
+			})
+		}
+		for _, v := range t.EnumValues {
+			typeSummary.EnumValues = append(typeSummary.EnumValues, v.Name)
+		}
+		summary.Types = append(summary.Types, typeSummary)
+	}
+
+	for _, d := range schema.Directives {
+		summary.Directives = append(summary.Directives, model.DTOGraphQLDirective{
+			Name:        d.Name,
+			Description: derefString(d.Description),
+			Locations:   d.Locations,
+			Args:        summarizeInputValues(d.Args),
+		})
+	}
+	return summary
+}
+
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func writeSDLArgs(b *strings.Builder, args []introspectionInputValue) {
+	if len(args) == 0 {
+		return
+	}
+	b.WriteString("(")
+	for i, arg := range args {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(arg.Name + ": " + typeRefString(arg.Type))
+		if arg.DefaultValue != nil {
+			b.WriteString(" = " + *arg.DefaultValue)
+		}
+	}
+	b.WriteString(")")
+}
+
+// introspectionToSDL rebuilds a schema definition from an introspection
+// result so it can be loaded by gqlparser for query validation. Descriptions
+// are left out since validation does not need them.
+func introspectionToSDL(schema *introspectionSchema) string {
+	var b strings.Builder
+
+	b.WriteString("schema {\n")
+	if schema.QueryType != nil {
+		b.WriteString("  query: " + schema.QueryType.Name + "\n")
+	}
+	if schema.MutationType != nil {
+		b.WriteString("  mutation: " + schema.MutationType.Name + "\n")
+	}
+	if schema.SubscriptionType != nil {
+		b.WriteString("  subscription: " + schema.SubscriptionType.Name + "\n")
+	}
+	b.WriteString("}\n\n")
+
+	for _, d := range schema.Directives {
+		if builtinGraphQLNames[d.Name] {
+			continue
+		}
+		b.WriteString("directive @" + d.Name)
+		writeSDLArgs(&b, d.Args)
+		b.WriteString(" on " + strings.Join(d.Locations, " | ") + "\n\n")
+	}
+
+	for _, t := range schema.Types {
+		if strings.HasPrefix(t.Name, "__") || builtinGraphQLNames[t.Name] {
+			continue
+		}
+
+		switch t.Kind {
+		case "SCALAR":
+			b.WriteString("scalar " + t.Name + "\n\n")
+		case "OBJECT", "INTERFACE":
+			keyword := "type "
+			if t.Kind == "INTERFACE" {
+				keyword = "interface "
+			}
+			b.WriteString(keyword + t.Name)
+			if len(t.Interfaces) > 0 {
+				b.WriteString(" implements " + strings.Join(typeRefNames(t.Interfaces), " & "))
+			}
+			b.WriteString(" {\n")
+			for _, f := range t.Fields {
+				b.WriteString("  " + f.Name)
+				writeSDLArgs(&b, f.Args)
+				b.WriteString(": " + typeRefString(f.Type) + "\n")
+			}
+			b.WriteString("}\n\n")
+		case "UNION":
+			b.WriteString("union " + t.Name + " = " + strings.Join(typeRefNames(t.PossibleTypes), " | ") + "\n\n")
+		case "ENUM":
+			b.WriteString("enum " + t.Name + " {\n")
+			for _, v := range t.EnumValues {
+				b.WriteString("  " + v.Name + "\n")
+			}
+			b.WriteString("}\n\n")
+		case "INPUT_OBJECT":
+			b.WriteString("input " + t.Name + " {\n")
+			for _, f := range t.InputFields {
+				b.WriteString("  " + f.Name + ": " + typeRefString(f.Type))
+				if f.DefaultValue != nil {
+					b.WriteString(" = " + *f.DefaultValue)
+				}
+				b.WriteString("\n")
+			}
+			b.WriteString("}\n\n")
+		}
+	}
+
+	return b.String()
+}
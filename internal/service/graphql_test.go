@@ -0,0 +1,119 @@
+package service
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/suar-net/suar-be/internal/config"
+	"github.com/suar-net/suar-be/internal/model"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+func TestCredentialFingerprint(t *testing.T) {
+	bearerA := map[string][]string{"Authorization": {"Bearer a"}}
+	tests := []struct {
+		name string
+		a, b map[string][]string
+		same bool
+	}{
+		{"no headers", nil, map[string][]string{}, true},
+		{"non-credential headers ignored", nil, map[string][]string{"Content-Type": {"application/json"}}, true},
+		{"header case", bearerA, map[string][]string{"authorization": {"Bearer a"}}, true},
+		{"different token", bearerA, map[string][]string{"Authorization": {"Bearer b"}}, false},
+		{"credentials vs none", bearerA, nil, false},
+		{"cookie", nil, map[string][]string{"Cookie": {"sid=1"}}, false},
+		{"api key", nil, map[string][]string{"X-Api-Key": {"k"}}, false},
+	}
+	for _, tt := range tests {
+		if got := credentialFingerprint(tt.a) == credentialFingerprint(tt.b); got != tt.same {
+			t.Errorf("%s: same fingerprint = %v, want %v", tt.name, got, tt.same)
+		}
+	}
+}
+
+func TestGraphQLSchemaCache(t *testing.T) {
+	headers := map[string][]string{"Authorization": {"Bearer a"}}
+	key := newGraphQLCacheKey(1, "dev", "https://api.test/graphql?x=1", headers)
+
+	c := newLRUCache[graphQLCacheKey, *cachedGraphQLSchema](2)
+	c.put(key, &cachedGraphQLSchema{}, time.Now().Add(time.Hour))
+	tests := []struct {
+		name string
+		key  graphQLCacheKey
+		hit  bool
+	}{
+		{"same user and credentials", newGraphQLCacheKey(1, "dev", "https://api.test/graphql", headers), true},
+		{"other user", newGraphQLCacheKey(2, "dev", "https://api.test/graphql", headers), false},
+		{"other credentials", newGraphQLCacheKey(1, "dev", "https://api.test/graphql", nil), false},
+		{"other environment", newGraphQLCacheKey(1, "prod", "https://api.test/graphql", headers), false},
+	}
+	for _, tt := range tests {
+		if _, got := c.get(tt.key); got != tt.hit {
+			t.Errorf("%s: hit = %v, want %v", tt.name, got, tt.hit)
+		}
+	}
+}
+
+func TestValidateGraphQLQuery(t *testing.T) {
+	schema, err := gqlparser.LoadSchema(&ast.Source{Input: "type Query { user(id: ID!): User } type User { name: String }"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs := NewRequestService(nil, nil, config.ResponseConfig{})
+	rs.graphQLSchemas.put(newGraphQLCacheKey(1, "", "https://api.test/graphql", nil), &cachedGraphQLSchema{schema: schema}, time.Now().Add(time.Hour))
+
+	tests := []struct {
+		name    string
+		userID  int
+		query   string
+		wantErr bool
+	}{
+		{"valid", 1, `{ user(id: "1") { name } }`, false},
+		{"unknown field", 1, `{ user(id: "1") { email } }`, true},
+		{"no schema for another user", 2, `{ user(id: "1") { email } }`, false},
+	}
+	for _, tt := range tests {
+		dto := &model.DTORequest{URL: "https://api.test/graphql", GraphQL: &model.DTOGraphQLBody{Query: tt.query}}
+		err := rs.validateGraphQLQuery(tt.userID, dto)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("%s: err = %v, want ErrInvalidInput", tt.name, err)
+		}
+	}
+}
+
+func TestApplyGraphQLBody(t *testing.T) {
+	gql := &model.DTOGraphQLBody{Query: "{ a }", Variables: []byte(`{"x":1}`), OperationName: "Op"}
+
+	post := &model.DTORequest{Method: "POST", URL: "https://api.test/graphql", GraphQL: gql}
+	if err := applyGraphQLBody(post); err != nil {
+		t.Fatal(err)
+	}
+	if string(post.Body) != `{"query":"{ a }","variables":{"x":1},"operationName":"Op"}` || post.Headers["Content-Type"][0] != "application/json" {
+		t.Errorf("POST body %s, headers %v", post.Body, post.Headers)
+	}
+
+	get := &model.DTORequest{Method: "GET", URL: "https://api.test/graphql?k=v", GraphQL: gql}
+	if err := applyGraphQLBody(get); err != nil {
+		t.Fatal(err)
+	}
+	parsed, _ := url.Parse(get.URL)
+	if q := parsed.Query(); q.Get("query") != "{ a }" || q.Get("variables") != `{"x":1}` || q.Get("operationName") != "Op" || q.Get("k") != "v" {
+		t.Errorf("GET URL %s", get.URL)
+	}
+
+	for _, dto := range []*model.DTORequest{
+		{Method: "PUT", GraphQL: gql},
+		{Method: "POST", GraphQL: &model.DTOGraphQLBody{Query: " "}},
+		{Method: "POST", BodyText: "x", GraphQL: gql},
+	} {
+		if err := applyGraphQLBody(dto); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("applyGraphQLBody(%+v) = %v, want ErrInvalidInput", dto, err)
+		}
+	}
+}
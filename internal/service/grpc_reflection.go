@@ -0,0 +1,271 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/bufbuild/protocompile"
+	"github.com/suar-net/suar-be/internal/model"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// The v1 and v1alpha reflection services use identical messages and differ
+// only in their name, so the v1 types are used for both.
+const (
+	reflectionV1Method      = "/grpc.reflection.v1.ServerReflection/ServerReflectionInfo"
+	reflectionV1AlphaMethod = "/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo"
+)
+
+// reflectionClient talks to the server reflection service of a target.
+type reflectionClient struct {
+	stream grpc.ClientStream
+}
+
+func newReflectionClient(ctx context.Context, conn *grpc.ClientConn) (*reflectionClient, error) {
+	desc := &grpc.StreamDesc{ClientStreams: true, ServerStreams: true}
+
+	client := &reflectionClient{}
+	stream, err := conn.NewStream(ctx, desc, reflectionV1Method)
+	if err == nil {
+		client.stream = stream
+		// Servers that only know v1alpha answer the first message with
+		// Unimplemented, so probe before settling on v1.
+		if _, err = client.listServices(); err == nil {
+			return client, nil
+		}
+	}
+	if status.Code(err) != codes.Unimplemented {
+		return nil, fmt.Errorf("%w: server reflection failed: %v", ErrUpstreamFailed, err)
+	}
+
+	stream, err = conn.NewStream(ctx, desc, reflectionV1AlphaMethod)
+	if err != nil {
+		return nil, fmt.Errorf("%w: server reflection failed: %v", ErrUpstreamFailed, err)
+	}
+	client.stream = stream
+	if _, err := client.listServices(); err != nil {
+		if status.Code(err) == codes.Unimplemented {
+			return nil, fmt.Errorf("%w: target does not support server reflection, upload a descriptor set or .proto files instead", ErrInvalidInput)
+		}
+		return nil, fmt.Errorf("%w: server reflection failed: %v", ErrUpstreamFailed, err)
+	}
+	return client, nil
+}
+
+func (c *reflectionClient) roundTrip(req *reflectionpb.ServerReflectionRequest) (*reflectionpb.ServerReflectionResponse, error) {
+	if err := c.stream.SendMsg(req); err != nil {
+		// The real error is only reported by RecvMsg.
+		if err != io.EOF {
+			return nil, err
+		}
+	}
+	resp := &reflectionpb.ServerReflectionResponse{}
+	if err := c.stream.RecvMsg(resp); err != nil {
+		return nil, err
+	}
+	if errResp := resp.GetErrorResponse(); errResp != nil {
+		return nil, status.Error(codes.Code(errResp.GetErrorCode()), errResp.GetErrorMessage())
+	}
+	return resp, nil
+}
+
+func (c *reflectionClient) close() {
+	c.stream.CloseSend()
+}
+
+func (c *reflectionClient) listServices() ([]string, error) {
+	resp, err := c.roundTrip(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, service := range resp.GetListServicesResponse().GetService() {
+		names = append(names, service.GetName())
+	}
+	return names, nil
+}
+
+func (c *reflectionClient) files(req *reflectionpb.ServerReflectionRequest) ([]*descriptorpb.FileDescriptorProto, error) {
+	resp, err := c.roundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []*descriptorpb.FileDescriptorProto
+	for _, raw := range resp.GetFileDescriptorResponse().GetFileDescriptorProto() {
+		file := &descriptorpb.FileDescriptorProto{}
+		if err := proto.Unmarshal(raw, file); err != nil {
+			return nil, fmt.Errorf("invalid file descriptor from reflection: %w", err)
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// resolveFiles downloads the files that define services and every file they
+// depend on. Well-known types missing on the server are taken from the ones
+// compiled into this binary.
+func (c *reflectionClient) resolveFiles(services []string) (*protoregistry.Files, error) {
+	collected := make(map[string]*descriptorpb.FileDescriptorProto)
+	var pending []string
+
+	add := func(files []*descriptorpb.FileDescriptorProto) {
+		for _, file := range files {
+			if _, ok := collected[file.GetName()]; ok {
+				continue
+			}
+			collected[file.GetName()] = file
+			pending = append(pending, file.GetDependency()...)
+		}
+	}
+
+	for _, service := range services {
+		files, err := c.files(&reflectionpb.ServerReflectionRequest{
+			MessageRequest: &reflectionpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: service},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to resolve %s: %v", ErrUpstreamFailed, service, err)
+		}
+		add(files)
+	}
+
+	for len(pending) > 0 {
+		name := pending[0]
+		pending = pending[1:]
+		if _, ok := collected[name]; ok {
+			continue
+		}
+
+		files, err := c.files(&reflectionpb.ServerReflectionRequest{
+			MessageRequest: &reflectionpb.ServerReflectionRequest_FileByFilename{FileByFilename: name},
+		})
+		if err != nil {
+			builtin, findErr := protoregistry.GlobalFiles.FindFileByPath(name)
+			if findErr != nil {
+				return nil, fmt.Errorf("%w: failed to resolve %s: %v", ErrUpstreamFailed, name, err)
+			}
+			files = []*descriptorpb.FileDescriptorProto{protodesc.ToFileDescriptorProto(builtin)}
+		}
+		add(files)
+	}
+
+	set := &descriptorpb.FileDescriptorSet{}
+	for _, file := range collected {
+		set.File = append(set.File, file)
+	}
+	files, err := protodesc.NewFiles(set)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid descriptors from reflection: %v", ErrUpstreamFailed, err)
+	}
+	return files, nil
+}
+
+// filesFromDescriptorSet loads a base64 encoded FileDescriptorSet as produced
+// by `protoc --include_imports --descriptor_set_out`.
+func filesFromDescriptorSet(encoded string) (*protoregistry.Files, error) {
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: descriptor_set must be base64 encoded: %v", ErrInvalidInput, err)
+	}
+
+	set := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(raw, set); err != nil {
+		return nil, fmt.Errorf("%w: invalid descriptor set: %v", ErrInvalidInput, err)
+	}
+	files, err := protodesc.NewFiles(set)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid descriptor set: %v", ErrInvalidInput, err)
+	}
+	return files, nil
+}
+
+// filesFromProtoSources compiles uploaded .proto files. Imports of the
+// well-known types are resolved without uploading them.
+func filesFromProtoSources(ctx context.Context, sources map[string]string) (*protoregistry.Files, error) {
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{
+			Accessor: protocompile.SourceAccessorFromMap(sources),
+		}),
+	}
+	compiled, err := compiler.Compile(ctx, names...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to compile proto files: %v", ErrInvalidInput, err)
+	}
+
+	files := &protoregistry.Files{}
+	var register func(file protoreflect.FileDescriptor) error
+	register = func(file protoreflect.FileDescriptor) error {
+		if _, err := files.FindFileByPath(file.Path()); err == nil {
+			return nil
+		}
+		imports := file.Imports()
+		for i := 0; i < imports.Len(); i++ {
+			if err := register(imports.Get(i).FileDescriptor); err != nil {
+				return err
+			}
+		}
+		return files.RegisterFile(file)
+	}
+	for _, file := range compiled {
+		if err := register(file); err != nil {
+			return nil, fmt.Errorf("%w: failed to register proto files: %v", ErrInvalidInput, err)
+		}
+	}
+	return files, nil
+}
+
+// loadDescriptors picks the descriptor source of a request: an uploaded
+// descriptor set, uploaded .proto files, or server reflection as a fallback.
+// services is only filled in when reflection was used.
+func loadDescriptors(ctx context.Context, conn *grpc.ClientConn, source model.DTOGRPCDescriptorSource) (*protoregistry.Files, []string, error) {
+	switch {
+	case source.DescriptorSet != "":
+		files, err := filesFromDescriptorSet(source.DescriptorSet)
+		return files, nil, err
+	case len(source.ProtoFiles) > 0:
+		files, err := filesFromProtoSources(ctx, source.ProtoFiles)
+		return files, nil, err
+	}
+
+	client, err := newReflectionClient(ctx, conn)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer client.close()
+
+	names, err := client.listServices()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: server reflection failed: %v", ErrUpstreamFailed, err)
+	}
+	var services []string
+	for _, name := range names {
+		if !strings.HasPrefix(name, "grpc.reflection.") {
+			services = append(services, name)
+		}
+	}
+
+	files, err := client.resolveFiles(services)
+	if err != nil {
+		return nil, nil, err
+	}
+	return files, services, nil
+}
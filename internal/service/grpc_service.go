@@ -0,0 +1,420 @@
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/suar-net/suar-be/internal/model"
+	"github.com/suar-net/suar-be/internal/repository"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const (
+	maxGRPCResponseMessages = 1000
+	// maxGRPCResponseBytes bounds the JSON encoded size of the messages kept
+	// from one call. Each message is also limited to 4 MB by the grpc client.
+	maxGRPCResponseBytes = 10 << 20
+)
+
+type grpcService struct {
+	repository repository.IRequestRepository
+	// checkTarget is the SSRF check applied to targets before dialing.
+	checkTarget func(host string) error
+}
+
+func NewGRPCService(r repository.IRequestRepository) IGRPCService {
+	return &grpcService{repository: r, checkTarget: checkEgress}
+}
+
+// grpcResponseBudget decides which response messages of a call are kept. Once
+// a message does not fit, it and every later message are dropped so the kept
+// messages stay a prefix of the stream.
+type grpcResponseBudget struct {
+	messages int
+	bytes    int
+	full     bool
+}
+
+func (b *grpcResponseBudget) allow(size int) bool {
+	if b.full || b.messages >= maxGRPCResponseMessages || b.bytes+size > maxGRPCResponseBytes {
+		b.full = true
+		return false
+	}
+	b.messages++
+	b.bytes += size
+	return true
+}
+
+// dial validates the target against the SSRF policy and creates a client
+// connection. Connections are opened lazily by grpc on first use.
+func (s *grpcService) dial(target string, plaintext bool) (*grpc.ClientConn, error) {
+	host, _, err := net.SplitHostPort(target)
+	if err != nil {
+		return nil, fmt.Errorf("%w: target must be in the form host:port", ErrInvalidInput)
+	}
+	if err := s.checkTarget(host); err != nil {
+		return nil, err
+	}
+
+	creds := credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+	if plaintext {
+		creds = insecure.NewCredentials()
+	}
+	conn, err := grpc.NewClient("dns:///"+target, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid target: %v", ErrInvalidInput, err)
+	}
+	return conn, nil
+}
+
+func grpcTimeout(timeoutMs int) (time.Duration, error) {
+	timeout := defaultRequestTimeout
+	if timeoutMs > 0 {
+		timeout = time.Duration(timeoutMs) * time.Millisecond
+	}
+	if timeout > maxRequestTimeout {
+		return 0, fmt.Errorf("%w: timeout of %v exceeds the maximum allowed limit of %v", ErrInvalidInput, timeout, maxRequestTimeout)
+	}
+	return timeout, nil
+}
+
+// outgoingMetadata converts request metadata to grpc metadata, dropping the
+// same sensitive keys as the HTTP header filter.
+func outgoingMetadata(ctx context.Context, values map[string][]string) context.Context {
+	md := metadata.MD{}
+	for key, list := range values {
+		if blockedHeaders[http.CanonicalHeaderKey(key)] {
+			continue
+		}
+		md.Append(strings.ToLower(key), list...)
+	}
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// ListServices describes every service and method known to the target, with
+// an input template that can be used as a starting point for a message.
+func (s *grpcService) ListServices(ctx context.Context, dto *model.DTOGRPCListRequest) (*model.DTOGRPCServiceList, error) {
+	timeout, err := grpcTimeout(dto.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := s.dial(dto.Target, dto.Plaintext)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(outgoingMetadata(ctx, dto.Metadata), timeout)
+	defer cancel()
+
+	files, names, err := loadDescriptors(ctx, conn, dto.DTOGRPCDescriptorSource)
+	if err != nil {
+		return nil, err
+	}
+
+	var services []protoreflect.ServiceDescriptor
+	if names != nil {
+		for _, name := range names {
+			desc, err := files.FindDescriptorByName(protoreflect.FullName(name))
+			if err != nil {
+				continue
+			}
+			if service, ok := desc.(protoreflect.ServiceDescriptor); ok {
+				services = append(services, service)
+			}
+		}
+	} else {
+		files.RangeFiles(func(file protoreflect.FileDescriptor) bool {
+			for i := 0; i < file.Services().Len(); i++ {
+				services = append(services, file.Services().Get(i))
+			}
+			return true
+		})
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i].FullName() < services[j].FullName() })
+
+	list := &model.DTOGRPCServiceList{Services: []model.DTOGRPCService{}}
+	for _, service := range services {
+		serviceDTO := model.DTOGRPCService{Name: string(service.FullName())}
+		methods := service.Methods()
+		for i := 0; i < methods.Len(); i++ {
+			method := methods.Get(i)
+			template, _ := protojson.MarshalOptions{EmitUnpopulated: true}.Marshal(dynamicpb.NewMessage(method.Input()))
+			serviceDTO.Methods = append(serviceDTO.Methods, model.DTOGRPCMethod{
+				Name:            string(method.Name()),
+				FullName:        "/" + string(service.FullName()) + "/" + string(method.Name()),
+				InputType:       string(method.Input().FullName()),
+				OutputType:      string(method.Output().FullName()),
+				ClientStreaming: method.IsStreamingClient(),
+				ServerStreaming: method.IsStreamingServer(),
+				InputTemplate:   template,
+			})
+		}
+		list.Services = append(list.Services, serviceDTO)
+	}
+	return list, nil
+}
+
+func findGRPCMethod(files *protoregistry.Files, serviceName, methodName string) (protoreflect.MethodDescriptor, error) {
+	desc, err := files.FindDescriptorByName(protoreflect.FullName(serviceName))
+	if err != nil {
+		return nil, fmt.Errorf("%w: service %s not found", ErrInvalidInput, serviceName)
+	}
+	service, ok := desc.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is not a service", ErrInvalidInput, serviceName)
+	}
+	method := service.Methods().ByName(protoreflect.Name(methodName))
+	if method == nil {
+		return nil, fmt.Errorf("%w: method %s not found in service %s", ErrInvalidInput, methodName, serviceName)
+	}
+	return method, nil
+}
+
+// typeResolver looks up message types in the loaded descriptors first and in
+// the types compiled into this binary second, so google.protobuf.Any fields
+// and status details can be transcoded.
+type typeResolver struct {
+	local *dynamicpb.Types
+}
+
+func (r typeResolver) FindMessageByName(name protoreflect.FullName) (protoreflect.MessageType, error) {
+	if mt, err := r.local.FindMessageByName(name); err == nil {
+		return mt, nil
+	}
+	return protoregistry.GlobalTypes.FindMessageByName(name)
+}
+
+func (r typeResolver) FindMessageByURL(url string) (protoreflect.MessageType, error) {
+	if mt, err := r.local.FindMessageByURL(url); err == nil {
+		return mt, nil
+	}
+	return protoregistry.GlobalTypes.FindMessageByURL(url)
+}
+
+func (r typeResolver) FindExtensionByName(name protoreflect.FullName) (protoreflect.ExtensionType, error) {
+	if xt, err := r.local.FindExtensionByName(name); err == nil {
+		return xt, nil
+	}
+	return protoregistry.GlobalTypes.FindExtensionByName(name)
+}
+
+func (r typeResolver) FindExtensionByNumber(message protoreflect.FullName, field protoreflect.FieldNumber) (protoreflect.ExtensionType, error) {
+	if xt, err := r.local.FindExtensionByNumber(message, field); err == nil {
+		return xt, nil
+	}
+	return protoregistry.GlobalTypes.FindExtensionByNumber(message, field)
+}
+
+func metadataToMap(md metadata.MD) map[string][]string {
+	result := make(map[string][]string, len(md))
+	for key, values := range md {
+		result[key] = values
+	}
+	return result
+}
+
+// Invoke calls a gRPC method with JSON messages. Every method kind goes
+// through a client stream: unary and server streaming calls take exactly one
+// message, client and bidirectional streaming calls take any number, which
+// are all sent before responses are read.
+func (s *grpcService) Invoke(ctx context.Context, userID *int, dto *model.DTOGRPCRequest) (*model.DTOGRPCResponse, error) {
+	timeout, err := grpcTimeout(dto.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := s.dial(dto.Target, dto.Plaintext)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(outgoingMetadata(ctx, dto.Metadata), timeout)
+	defer cancel()
+
+	files, _, err := loadDescriptors(ctx, conn, dto.DTOGRPCDescriptorSource)
+	if err != nil {
+		return nil, err
+	}
+	method, err := findGRPCMethod(files, dto.Service, dto.Method)
+	if err != nil {
+		return nil, err
+	}
+
+	resolver := typeResolver{local: dynamicpb.NewTypes(files)}
+	rawMessages := dto.Messages
+	if len(rawMessages) == 0 {
+		rawMessages = []json.RawMessage{json.RawMessage("{}")}
+	}
+	if !method.IsStreamingClient() && len(rawMessages) != 1 {
+		return nil, fmt.Errorf("%w: method %s accepts exactly one message", ErrInvalidInput, method.Name())
+	}
+
+	requests := make([]*dynamicpb.Message, 0, len(rawMessages))
+	for i, raw := range rawMessages {
+		message := dynamicpb.NewMessage(method.Input())
+		if err := (protojson.UnmarshalOptions{Resolver: resolver}).Unmarshal(raw, message); err != nil {
+			return nil, fmt.Errorf("%w: message %d is not a valid %s: %v", ErrInvalidInput, i, method.Input().FullName(), err)
+		}
+		requests = append(requests, message)
+	}
+
+	startTime := time.Now()
+	fullMethod := "/" + string(method.Parent().FullName()) + "/" + string(method.Name())
+	stream, err := conn.NewStream(ctx, &grpc.StreamDesc{
+		StreamName:    string(method.Name()),
+		ClientStreams: method.IsStreamingClient(),
+		ServerStreams: method.IsStreamingServer(),
+	}, fullMethod)
+
+	var rpcErr error
+	responses := []json.RawMessage{}
+	dtoResponse := &model.DTOGRPCResponse{Timestamp: startTime}
+
+	if err != nil {
+		rpcErr = err
+	} else {
+		for _, request := range requests {
+			// io.EOF means the server already ended the call, the status is
+			// reported by RecvMsg below.
+			if err := stream.SendMsg(request); err != nil {
+				break
+			}
+		}
+		stream.CloseSend()
+
+		marshal := protojson.MarshalOptions{Resolver: resolver}
+		var budget grpcResponseBudget
+		for {
+			message := dynamicpb.NewMessage(method.Output())
+			if err := stream.RecvMsg(message); err != nil {
+				if err != io.EOF {
+					rpcErr = err
+				}
+				break
+			}
+			if budget.full {
+				dtoResponse.MessagesDropped++
+				continue
+			}
+			encoded, err := marshal.Marshal(message)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode response message: %w", err)
+			}
+			if !budget.allow(len(encoded)) {
+				dtoResponse.MessagesDropped++
+				continue
+			}
+			responses = append(responses, encoded)
+		}
+
+		if header, err := stream.Header(); err == nil {
+			dtoResponse.Headers = metadataToMap(header)
+		}
+		dtoResponse.Trailers = metadataToMap(stream.Trailer())
+	}
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) && rpcErr != nil {
+		dtoResponse.Error = fmt.Sprintf("%v: deadline of %v exceeded", ErrRequestTimeout, timeout)
+	}
+
+	st := status.Convert(rpcErr)
+	dtoResponse.Duration = time.Since(startTime)
+	dtoResponse.StatusCode = int(st.Code())
+	dtoResponse.Status = st.Code().String()
+	dtoResponse.StatusMessage = st.Message()
+	dtoResponse.Messages = responses
+	for _, detail := range st.Proto().GetDetails() {
+		encoded, err := protojson.MarshalOptions{Resolver: resolver}.Marshal(detail)
+		if err != nil {
+			// Unknown detail types are passed on undecoded.
+			encoded, _ = json.Marshal(map[string]string{
+				"@type": detail.GetTypeUrl(),
+				"value": base64.StdEncoding.EncodeToString(detail.GetValue()),
+			})
+		}
+		dtoResponse.StatusDetails = append(dtoResponse.StatusDetails, encoded)
+	}
+
+	if userID != nil {
+		// History is written even when the caller has gone away.
+		saveCtx, saveCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer saveCancel()
+		if err := s.saveHistory(saveCtx, userID, dto, fullMethod, rawMessages, dtoResponse); err != nil {
+			return dtoResponse, fmt.Errorf("failed to save grpc history: %w", err)
+		}
+	}
+
+	return dtoResponse, nil
+}
+
+func (s *grpcService) saveHistory(ctx context.Context, userID *int, dto *model.DTOGRPCRequest, fullMethod string, requests []json.RawMessage, resp *model.DTOGRPCResponse) error {
+	scheme := "grpcs://"
+	if dto.Plaintext {
+		scheme = "grpc://"
+	}
+
+	requestHeaders, err := json.Marshal(dto.Metadata)
+	if err != nil {
+		return err
+	}
+	requestBody, err := json.Marshal(requests)
+	if err != nil {
+		return err
+	}
+	responseHeaders, err := json.Marshal(resp.Headers)
+	if err != nil {
+		return err
+	}
+	responseBytes, err := json.Marshal(resp.Messages)
+	if err != nil {
+		return err
+	}
+	transcript, err := json.Marshal(model.GRPCTranscript{
+		Status:        resp.Status,
+		StatusMessage: resp.StatusMessage,
+		StatusDetails: resp.StatusDetails,
+		Trailers:      resp.Trailers,
+	})
+	if err != nil {
+		return err
+	}
+
+	requestBodyString := string(requestBody)
+	responseBody := string(responseBytes)
+	responseSize := int64(len(responseBytes))
+	durationMs := int(resp.Duration.Milliseconds())
+
+	return s.repository.Create(ctx, &model.Request{
+		UserID:             userID,
+		RequestType:        model.RequestTypeGRPC,
+		RequestMethod:      http.MethodPost,
+		RequestURL:         scheme + dto.Target + fullMethod,
+		RequestHeaders:     requestHeaders,
+		RequestBody:        &requestBodyString,
+		ResponseStatusCode: &resp.StatusCode,
+		ResponseHeaders:    responseHeaders,
+		ResponseBody:       &responseBody,
+		ResponseSize:       &responseSize,
+		DurationMs:         &durationMs,
+		SessionTranscript:  transcript,
+	})
+}
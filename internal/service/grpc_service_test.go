@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/suar-net/suar-be/internal/model"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+func TestGRPCResponseBudget(t *testing.T) {
+	tests := []struct {
+		name  string
+		sizes []int
+		kept  int
+	}{
+		{"small messages", []int{10, 10, 10}, 3},
+		{"byte budget", []int{maxGRPCResponseBytes - 5, 10, 1}, 1},
+		{"single oversized message", []int{maxGRPCResponseBytes + 1, 1}, 0},
+		{"message budget", make([]int, maxGRPCResponseMessages+5), maxGRPCResponseMessages},
+	}
+	for _, tt := range tests {
+		var budget grpcResponseBudget
+		kept := 0
+		for _, size := range tt.sizes {
+			if budget.allow(size) {
+				kept++
+			}
+		}
+		if kept != tt.kept {
+			t.Errorf("%s: kept %d messages, want %d", tt.name, kept, tt.kept)
+		}
+	}
+}
+
+// startReflectionServer serves the health service with server reflection on
+// a loopback port.
+func startReflectionServer(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	reflection.Register(server)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+	return listener.Addr().String()
+}
+
+func TestGRPCServiceWithReflection(t *testing.T) {
+	target := startReflectionServer(t)
+	s := &grpcService{checkTarget: func(string) error { return nil }}
+	ctx := context.Background()
+
+	list, err := s.ListServices(ctx, &model.DTOGRPCListRequest{Target: target, Plaintext: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, service := range list.Services {
+		names = append(names, service.Name)
+	}
+	if !strings.Contains(strings.Join(names, ","), "grpc.health.v1.Health") {
+		t.Fatalf("services = %v, want grpc.health.v1.Health", names)
+	}
+
+	tests := []struct {
+		name    string
+		method  string
+		timeout int
+		status  string
+		want    []string
+	}{
+		{"unary", "Check", 0, "OK", []string{`"status":"SERVING"`}},
+		// Watch streams until the call ends, the first update is kept.
+		{"server streaming", "Watch", 300, "DeadlineExceeded", []string{`"status":"SERVING"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := s.Invoke(ctx, nil, &model.DTOGRPCRequest{
+				Target: target, Plaintext: true, Service: "grpc.health.v1.Health", Method: tt.method,
+				Messages: []json.RawMessage{json.RawMessage(`{}`)}, Timeout: tt.timeout,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Status != tt.status || len(resp.Messages) != len(tt.want) {
+				t.Fatalf("status %s (%s), %d messages", resp.Status, resp.StatusMessage, len(resp.Messages))
+			}
+			for i, want := range tt.want {
+				if !strings.Contains(string(resp.Messages[i]), want) {
+					t.Errorf("message %d = %s, want it to contain %s", i, resp.Messages[i], want)
+				}
+			}
+		})
+	}
+
+	_, err = s.Invoke(ctx, nil, &model.DTOGRPCRequest{Target: target, Plaintext: true, Service: "grpc.health.v1.Health", Method: "Nope"})
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("unknown method: err = %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestGRPCServiceRejectsPrivateTargets(t *testing.T) {
+	target := startReflectionServer(t)
+	s := NewGRPCService(nil)
+	_, err := s.ListServices(context.Background(), &model.DTOGRPCListRequest{Target: target, Plaintext: true})
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("err = %v, want the loopback target to be refused", err)
+	}
+}
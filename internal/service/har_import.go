@@ -0,0 +1,186 @@
+package service
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/suar-net/suar-be/internal/model"
+)
+
+// Limits of a response example, as validated when examples are set.
+const (
+	maxExampleHeaders  = 100
+	maxExampleBodySize = 1 << 20
+)
+
+// harSkippedRequestHeaders are set by the client for every request and are
+// not kept in saved requests.
+var harSkippedRequestHeaders = map[string]bool{
+	"content-length":    true,
+	"host":              true,
+	"connection":        true,
+	"transfer-encoding": true,
+}
+
+// harSkippedResponseHeaders describe the encoding of the captured body, which
+// HAR stores decoded, so they do not apply to an example.
+var harSkippedResponseHeaders = map[string]bool{
+	"content-length":    true,
+	"content-encoding":  true,
+	"transfer-encoding": true,
+	"connection":        true,
+}
+
+// harToCollection converts the HTTP entries of a HAR file to a collection of
+// saved requests, in the order they were recorded. Requests to more than one
+// host are put in a folder per host. The response of each entry is kept as
+// an example so the collection can be served as a mock.
+func harToCollection(har *model.HAR, warnings *importWarnings) (*model.Collection, error) {
+	if len(har.Log.Entries) == 0 {
+		return nil, fmt.Errorf("%w: the HAR file contains no entries", ErrInvalidInput)
+	}
+	if len(har.Log.Entries) > maxHARImportEntries {
+		return nil, fmt.Errorf("%w: the HAR file contains %d entries, at most %d can be imported", ErrInvalidInput, len(har.Log.Entries), maxHARImportEntries)
+	}
+
+	var hosts []string
+	byHost := make(map[string][]*model.CollectionItem)
+	for i, entry := range har.Log.Entries {
+		item, host, err := harEntryToCollectionItem(entry)
+		if err != nil {
+			warnings.add("entry %d: %v", i, err)
+			continue
+		}
+		if _, ok := byHost[host]; !ok {
+			hosts = append(hosts, host)
+		}
+		byHost[host] = append(byHost[host], item)
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("%w: the HAR file contains no HTTP requests that can be saved", ErrInvalidInput)
+	}
+
+	name := "HAR import"
+	if creator := strings.TrimSpace(har.Log.Creator.Name); creator != "" {
+		name = "HAR import from " + creator
+	}
+	collection := &model.Collection{Name: truncateName(name), Variables: []model.Variable{}}
+	if len(hosts) == 1 {
+		collection.Items = byHost[hosts[0]]
+		return collection, nil
+	}
+	for _, host := range hosts {
+		collection.Items = append(collection.Items, &model.CollectionItem{
+			Type:  model.CollectionItemFolder,
+			Name:  truncateName(host),
+			Items: byHost[host],
+		})
+	}
+	return collection, nil
+}
+
+// harEntryToCollectionItem converts one HAR entry to a saved request and
+// returns the host it was sent to. WebSocket sessions and entries with a
+// scheme or method requests cannot use are rejected.
+func harEntryToCollectionItem(entry model.HAREntry) (*model.CollectionItem, string, error) {
+	parsedURL, err := url.Parse(entry.Request.URL)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid URL: %v", err)
+	}
+	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
+		return nil, "", fmt.Errorf("unsupported URL scheme %q", parsedURL.Scheme)
+	}
+	if entry.ResourceType == "websocket" {
+		return nil, "", fmt.Errorf("websocket sessions cannot be saved as requests")
+	}
+	method := strings.ToUpper(entry.Request.Method)
+	if !allowedMethods[method] {
+		return nil, "", fmt.Errorf("unsupported method %q", entry.Request.Method)
+	}
+
+	request := &model.CollectionRequest{Method: method, URL: parsedURL.String()}
+	for _, header := range entry.Request.Headers {
+		// HTTP/2 pseudo headers such as :authority are not real headers.
+		if strings.HasPrefix(header.Name, ":") || harSkippedRequestHeaders[strings.ToLower(header.Name)] {
+			continue
+		}
+		request.Headers = append(request.Headers, model.KeyValue{Key: header.Name, Value: header.Value})
+	}
+	request.Body = harPostDataToBody(entry.Request.PostData)
+
+	name := entry.Comment
+	if strings.TrimSpace(name) == "" {
+		name = method + " " + parsedURL.RequestURI()
+	}
+	item := &model.CollectionItem{
+		Type:    model.CollectionItemRequest,
+		Name:    truncateName(name),
+		Request: request,
+	}
+	if example, ok := harResponseToExample(entry.Response); ok {
+		item.Examples = []model.ResponseExample{example}
+	}
+	return item, parsedURL.Host, nil
+}
+
+func harPostDataToBody(postData *model.HARPostData) *model.RequestBody {
+	if postData == nil {
+		return nil
+	}
+	if postData.Text != "" {
+		return &model.RequestBody{Mode: model.BodyModeRaw, Raw: postData.Text, ContentType: postData.MimeType}
+	}
+	if len(postData.Params) == 0 {
+		return nil
+	}
+	if strings.HasPrefix(postData.MimeType, "multipart/form-data") {
+		body := &model.RequestBody{Mode: model.BodyModeFormData}
+		for _, param := range postData.Params {
+			body.FormData = append(body.FormData, model.FormField{Key: param.Name, Value: param.Value, Type: "text"})
+		}
+		return body
+	}
+	body := &model.RequestBody{Mode: model.BodyModeURLEncoded}
+	for _, param := range postData.Params {
+		body.URLEncoded = append(body.URLEncoded, model.KeyValue{Key: param.Name, Value: param.Value})
+	}
+	return body
+}
+
+// harResponseToExample keeps a recorded response as an example. Responses
+// that were never received, and binary or oversized bodies, are left out.
+func harResponseToExample(response model.HARResponse) (model.ResponseExample, bool) {
+	if response.Status < 100 || response.Status > 599 {
+		return model.ResponseExample{}, false
+	}
+	example := model.ResponseExample{Name: fmt.Sprintf("%d %s", response.Status, response.StatusText), Status: response.Status}
+	if response.StatusText == "" {
+		example.Name = fmt.Sprintf("%d", response.Status)
+	}
+	for _, header := range response.Headers {
+		if len(example.Headers) == maxExampleHeaders {
+			break
+		}
+		if strings.HasPrefix(header.Name, ":") || harSkippedResponseHeaders[strings.ToLower(header.Name)] {
+			continue
+		}
+		example.Headers = append(example.Headers, model.KeyValue{Key: header.Name, Value: header.Value})
+	}
+	if body, ok := harContentText(response.Content); ok {
+		if len(body) > maxExampleBodySize {
+			return model.ResponseExample{}, false
+		}
+		example.Body = body
+	}
+	example.Name = truncateName(example.Name)
+	return example, true
+}
+
+// truncateName shortens a generated name to the 255 bytes the database keeps.
+func truncateName(name string) string {
+	if len(name) <= 255 {
+		return name
+	}
+	return string(trimIncompleteRune([]byte(name[:255])))
+}
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/suar-net/suar-be/internal/model"
+)
+
+func TestHAREntryToCollectionItem(t *testing.T) {
+	tests := []struct {
+		name     string
+		entry    model.HAREntry
+		wantErr  bool
+		itemName string
+		host     string
+		headers  []model.KeyValue
+		body     *model.RequestBody
+		examples int
+	}{
+		{
+			name: "json post",
+			entry: model.HAREntry{
+				Request: model.HARRequest{Method: "post", URL: "https://api.test/users?page=2", Headers: []model.HARNameValue{
+					{Name: ":authority", Value: "api.test"}, {Name: "Content-Type", Value: "application/json"},
+					{Name: "Content-Length", Value: "7"}, {Name: "Authorization", Value: "Bearer x"},
+				}, PostData: &model.HARPostData{MimeType: "application/json", Text: `{"a":1}`}},
+				Response: model.HARResponse{Status: 201, StatusText: "Created", Content: model.HARContent{MimeType: "application/json", Text: `{"id":1}`}},
+			},
+			itemName: "POST /users?page=2",
+			host:     "api.test",
+			headers:  []model.KeyValue{{Key: "Content-Type", Value: "application/json"}, {Key: "Authorization", Value: "Bearer x"}},
+			body:     &model.RequestBody{Mode: model.BodyModeRaw, Raw: `{"a":1}`, ContentType: "application/json"},
+			examples: 1,
+		},
+		{
+			name: "urlencoded params with comment",
+			entry: model.HAREntry{
+				Comment: "Login",
+				Request: model.HARRequest{Method: "POST", URL: "http://a.test:8080/login", PostData: &model.HARPostData{
+					MimeType: "application/x-www-form-urlencoded", Params: []model.HARNameValue{{Name: "u", Value: "ana"}},
+				}},
+			},
+			itemName: "Login",
+			host:     "a.test:8080",
+			body:     &model.RequestBody{Mode: model.BodyModeURLEncoded, URLEncoded: []model.KeyValue{{Key: "u", Value: "ana"}}},
+		},
+		{
+			name: "multipart params",
+			entry: model.HAREntry{Request: model.HARRequest{Method: "PUT", URL: "https://a.test/f", PostData: &model.HARPostData{
+				MimeType: "multipart/form-data; boundary=x", Params: []model.HARNameValue{{Name: "f", Value: "v"}},
+			}}},
+			itemName: "PUT /f",
+			host:     "a.test",
+			body:     &model.RequestBody{Mode: model.BodyModeFormData, FormData: []model.FormField{{Key: "f", Value: "v", Type: "text"}}},
+		},
+		{name: "websocket", entry: model.HAREntry{ResourceType: "websocket", Request: model.HARRequest{Method: "GET", URL: "https://a.test/ws"}}, wantErr: true},
+		{name: "ws scheme", entry: model.HAREntry{Request: model.HARRequest{Method: "GET", URL: "wss://a.test/ws"}}, wantErr: true},
+		{name: "data url", entry: model.HAREntry{Request: model.HARRequest{Method: "GET", URL: "data:,x"}}, wantErr: true},
+		{name: "unknown method", entry: model.HAREntry{Request: model.HARRequest{Method: "BREW", URL: "https://a.test"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		item, host, err := harEntryToCollectionItem(tt.entry)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if item.Name != tt.itemName || host != tt.host {
+			t.Errorf("%s: name %q host %q, want %q %q", tt.name, item.Name, host, tt.itemName, tt.host)
+		}
+		if !reflect.DeepEqual(item.Request.Headers, tt.headers) {
+			t.Errorf("%s: headers = %+v, want %+v", tt.name, item.Request.Headers, tt.headers)
+		}
+		if !reflect.DeepEqual(item.Request.Body, tt.body) {
+			t.Errorf("%s: body = %+v, want %+v", tt.name, item.Request.Body, tt.body)
+		}
+		if len(item.Examples) != tt.examples {
+			t.Errorf("%s: examples = %+v, want %d", tt.name, item.Examples, tt.examples)
+		}
+	}
+}
+
+func TestHARResponseToExample(t *testing.T) {
+	tests := []struct {
+		name     string
+		response model.HARResponse
+		ok       bool
+		want     model.ResponseExample
+	}{
+		{
+			name: "json",
+			response: model.HARResponse{Status: 200, StatusText: "OK",
+				Headers: []model.HARNameValue{{Name: "Content-Type", Value: "application/json"}, {Name: "Content-Encoding", Value: "gzip"}},
+				Content: model.HARContent{MimeType: "application/json", Text: `{"a":1}`}},
+			ok:   true,
+			want: model.ResponseExample{Name: "200 OK", Status: 200, Headers: []model.KeyValue{{Key: "Content-Type", Value: "application/json"}}, Body: `{"a":1}`},
+		},
+		{
+			name:     "binary body is left out",
+			response: model.HARResponse{Status: 204, Content: model.HARContent{MimeType: "image/png", Text: "iVBORw0KGgo=", Encoding: "base64"}},
+			ok:       true,
+			want:     model.ResponseExample{Name: "204", Status: 204},
+		},
+		{name: "no response", response: model.HARResponse{Status: 0}},
+		{name: "oversized body", response: model.HARResponse{Status: 200, Content: model.HARContent{MimeType: "text/plain", Text: strings.Repeat("a", maxExampleBodySize+1)}}},
+	}
+	for _, tt := range tests {
+		got, ok := harResponseToExample(tt.response)
+		if ok != tt.ok || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: = %+v, %v, want %+v, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func harWithURLs(urls ...string) *model.HAR {
+	har := &model.HAR{Log: model.HARLog{Creator: model.HARCreator{Name: "Firefox"}}}
+	for _, u := range urls {
+		har.Log.Entries = append(har.Log.Entries, model.HAREntry{Request: model.HARRequest{Method: "GET", URL: u}})
+	}
+	return har
+}
+
+func TestHARToCollection(t *testing.T) {
+	var warnings importWarnings
+	collection, err := harToCollection(harWithURLs("https://a.test/1", "https://a.test/2"), &warnings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if collection.Name != "HAR import from Firefox" || len(collection.Items) != 2 || collection.Items[0].Type != model.CollectionItemRequest {
+		t.Errorf("single host collection = %+v", collection)
+	}
+
+	warnings = nil
+	collection, err = harToCollection(harWithURLs("https://a.test/1", "https://b.test/1", "data:,x", "https://a.test/2"), &warnings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var folders []string
+	for _, item := range collection.Items {
+		folders = append(folders, item.Name+":"+string(rune('0'+len(item.Items))))
+	}
+	if !reflect.DeepEqual(folders, []string{"a.test:2", "b.test:1"}) {
+		t.Errorf("folders = %q", folders)
+	}
+	if len(warnings) != 1 || !strings.HasPrefix(warnings[0], "entry 2:") {
+		t.Errorf("warnings = %q", warnings)
+	}
+
+	for name, har := range map[string]*model.HAR{
+		"empty":          harWithURLs(),
+		"nothing usable": harWithURLs("data:,x", "wss://a.test"),
+	} {
+		if _, err := harToCollection(har, &warnings); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("%s: err = %v, want ErrInvalidInput", name, err)
+		}
+	}
+}
+
+func TestImportHARStoresCollection(t *testing.T) {
+	repo := &fakeCollectionRepo{}
+	s := NewImportService(repo, nil, nil)
+
+	data := `{"log":{"version":"1.2","creator":{"name":"Chrome"},"entries":[
+		{"request":{"method":"GET","url":"https://a.test/x","headers":[]},"response":{"status":200,"headers":[],"content":{"mimeType":"text/plain","text":"hi"}}},
+		{"request":{"method":"GET","url":"ws://a.test/ws","headers":[]},"response":{"status":101,"headers":[],"content":{}}}
+	]}}`
+	got, err := s.ImportHAR(context.Background(), 1, []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Collections) != 1 || len(got.Warnings) != 1 || len(repo.created) != 1 {
+		t.Fatalf("response = %+v, created %d", got, len(repo.created))
+	}
+	item := repo.created[0].Items[0]
+	if repo.created[0].UserID != 1 || item.Name != "GET /x" || len(item.Examples) != 1 || item.Examples[0].Body != "hi" {
+		t.Errorf("stored = %+v, item %+v", repo.created[0], item)
+	}
+
+	if _, err := s.ImportHAR(context.Background(), 1, []byte("{")); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("invalid JSON: err = %v, want ErrInvalidInput", err)
+	}
+}
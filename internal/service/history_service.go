@@ -0,0 +1,492 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/suar-net/suar-be/internal/model"
+	"github.com/suar-net/suar-be/internal/repository"
+)
+
+const maxHARImportEntries = 5000
+
+type historyService struct {
+	repository repository.IRequestRepository
+	runs       repository.ICollectionRunRepository
+}
+
+func NewHistoryService(r repository.IRequestRepository, runs repository.ICollectionRunRepository) IHistoryService {
+	return &historyService{repository: r, runs: runs}
+}
+
+func newHAR(entries int) *model.HAR {
+	return &model.HAR{Log: model.HARLog{
+		Version: "1.2",
+		Creator: model.HARCreator{Name: "suar", Version: "1.0"},
+		Entries: make([]model.HAREntry, 0, entries),
+	}}
+}
+
+// ExportHAR returns the selected history entries of a user as a HAR 1.2
+// document. HTTP and WebSocket entries can be exported, gRPC calls have no
+// HAR representation.
+func (s *historyService) ExportHAR(ctx context.Context, userID int, dto *model.DTOHARExportRequest) (*model.HAR, error) {
+	entries, err := s.repository.GetByIDs(ctx, dto.IDs, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load history entries: %w", err)
+	}
+	if len(entries) == 0 {
+		return nil, ErrHistoryNotFound
+	}
+
+	har := newHAR(len(entries))
+	for _, entry := range entries {
+		if entry.RequestType == model.RequestTypeGRPC {
+			return nil, fmt.Errorf("%w: history entry %d is a grpc call, which cannot be exported as HAR", ErrInvalidInput, entry.ID)
+		}
+		harEntry, err := historyToHAREntry(entry)
+		if err != nil {
+			return nil, err
+		}
+		har.Log.Entries = append(har.Log.Entries, harEntry)
+	}
+	return har, nil
+}
+
+// ExportRunHAR returns the requests sent by a collection run as a HAR 1.2
+// document, in the order of the run report. Each entry is commented with the
+// path of its request. Results without a history entry, because the request
+// failed or the run skipped history, are left out.
+func (s *historyService) ExportRunHAR(ctx context.Context, userID int, runID int) (*model.HAR, error) {
+	run, err := s.runs.GetByID(ctx, runID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load run: %w", err)
+	}
+	if run == nil {
+		return nil, ErrRunNotFound
+	}
+
+	var ids []int
+	for _, result := range run.Results {
+		if result.HistoryID != nil {
+			ids = append(ids, *result.HistoryID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("%w: run %d has no recorded requests", ErrHistoryNotFound, runID)
+	}
+	entries, err := s.repository.GetByIDs(ctx, ids, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load history entries: %w", err)
+	}
+	byID := make(map[int]*model.Request, len(entries))
+	for _, entry := range entries {
+		byID[entry.ID] = entry
+	}
+
+	har := newHAR(len(entries))
+	for _, result := range run.Results {
+		if result.HistoryID == nil || byID[*result.HistoryID] == nil {
+			continue
+		}
+		harEntry, err := historyToHAREntry(byID[*result.HistoryID])
+		if err != nil {
+			return nil, err
+		}
+		harEntry.Comment = result.Path
+		if run.Totals.Iterations > 1 {
+			harEntry.Comment = fmt.Sprintf("%s (iteration %d)", result.Path, result.Iteration)
+		}
+		har.Log.Entries = append(har.Log.Entries, harEntry)
+	}
+	if len(har.Log.Entries) == 0 {
+		return nil, fmt.Errorf("%w: the history of run %d has been deleted", ErrHistoryNotFound, runID)
+	}
+	return har, nil
+}
+
+// historyHeaders decodes the JSON headers column into HAR name/value pairs,
+// sorted by name so exports are stable.
+func historyHeaders(raw json.RawMessage) ([]model.HARNameValue, error) {
+	pairs := []model.HARNameValue{}
+	if len(raw) == 0 || string(raw) == "null" {
+		return pairs, nil
+	}
+	var headers map[string][]string
+	if err := json.Unmarshal(raw, &headers); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range headers[name] {
+			pairs = append(pairs, model.HARNameValue{Name: name, Value: value})
+		}
+	}
+	return pairs, nil
+}
+
+func harHeader(headers []model.HARNameValue, name string) string {
+	for _, header := range headers {
+		if strings.EqualFold(header.Name, name) {
+			return header.Value
+		}
+	}
+	return ""
+}
+
+func historyToHAREntry(entry *model.Request) (model.HAREntry, error) {
+	requestHeaders, err := historyHeaders(entry.RequestHeaders)
+	if err != nil {
+		return model.HAREntry{}, fmt.Errorf("invalid request headers in history entry %d: %w", entry.ID, err)
+	}
+	responseHeaders, err := historyHeaders(entry.ResponseHeaders)
+	if err != nil {
+		return model.HAREntry{}, fmt.Errorf("invalid response headers in history entry %d: %w", entry.ID, err)
+	}
+
+	var duration float64
+	if entry.DurationMs != nil {
+		duration = float64(*entry.DurationMs)
+	}
+
+	request := model.HARRequest{
+		Method:      entry.RequestMethod,
+		URL:         entry.RequestURL,
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     []model.HARNameValue{},
+		Headers:     requestHeaders,
+		QueryString: []model.HARNameValue{},
+		HeadersSize: -1,
+		BodySize:    0,
+	}
+	if parsedURL, err := url.Parse(entry.RequestURL); err == nil {
+		for _, pair := range strings.Split(parsedURL.RawQuery, "&") {
+			if pair == "" {
+				continue
+			}
+			name, value, _ := strings.Cut(pair, "=")
+			name, _ = url.QueryUnescape(name)
+			value, _ = url.QueryUnescape(value)
+			request.QueryString = append(request.QueryString, model.HARNameValue{Name: name, Value: value})
+		}
+	}
+	if entry.RequestBody != nil && *entry.RequestBody != "" {
+		request.BodySize = int64(len(*entry.RequestBody))
+		request.PostData = &model.HARPostData{
+			MimeType: harHeader(requestHeaders, "Content-Type"),
+			Text:     *entry.RequestBody,
+		}
+	}
+
+	response := model.HARResponse{
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     []model.HARNameValue{},
+		Headers:     responseHeaders,
+		RedirectURL: harHeader(responseHeaders, "Location"),
+		HeadersSize: -1,
+		BodySize:    -1,
+	}
+	if entry.ResponseStatusCode != nil {
+		response.Status = *entry.ResponseStatusCode
+		response.StatusText = http.StatusText(*entry.ResponseStatusCode)
+	}
+	response.Content.MimeType = harHeader(responseHeaders, "Content-Type")
+	if entry.ResponseSize != nil {
+		response.Content.Size = *entry.ResponseSize
+	}
+	if entry.ResponseBody != nil {
+		response.Content.Text = *entry.ResponseBody
+	}
+
+	timings, err := historyTimings(entry.Timings, duration)
+	if err != nil {
+		return model.HAREntry{}, fmt.Errorf("invalid timings in history entry %d: %w", entry.ID, err)
+	}
+	harEntry := model.HAREntry{
+		StartedDateTime: entry.ExecutedAt,
+		Time:            duration,
+		Request:         request,
+		Response:        response,
+		Timings:         timings,
+	}
+	// Time is the sum of the phases when they are known.
+	if len(entry.Timings) > 0 && string(entry.Timings) != "null" {
+		harEntry.Time = harTotal(timings)
+	}
+
+	if entry.RequestType == model.RequestTypeWebSocket && len(entry.SessionTranscript) > 0 {
+		var transcript model.WebSocketTranscript
+		if err := json.Unmarshal(entry.SessionTranscript, &transcript); err != nil {
+			return model.HAREntry{}, fmt.Errorf("invalid transcript in history entry %d: %w", entry.ID, err)
+		}
+		harEntry.ResourceType = "websocket"
+		harEntry.WebSocketMessages = transcriptToHAR(transcript)
+	}
+	return harEntry, nil
+}
+
+// historyTimings converts the stored phase timings to HAR. Entries recorded
+// before timings were kept, and WebSocket sessions, only have the total
+// duration, which is reported as waiting time.
+func historyTimings(raw json.RawMessage, duration float64) (model.HARTimings, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return model.HARTimings{Blocked: -1, DNS: -1, Connect: -1, Send: 0, Wait: duration, Receive: 0, SSL: -1}, nil
+	}
+	var timings model.RequestTimings
+	if err := json.Unmarshal(raw, &timings); err != nil {
+		return model.HARTimings{}, err
+	}
+	return model.HARTimings{
+		Blocked: timings.Blocked,
+		DNS:     timings.DNS,
+		Connect: timings.Connect,
+		Send:    timings.Send,
+		Wait:    timings.Wait,
+		Receive: timings.Receive,
+		SSL:     timings.TLS,
+	}, nil
+}
+
+// harTotal is the entry time HAR expects, the sum of the phases that apply.
+// SSL is already part of connect.
+func harTotal(timings model.HARTimings) float64 {
+	var total float64
+	for _, phase := range []float64{timings.Blocked, timings.DNS, timings.Connect, timings.Send, timings.Wait, timings.Receive} {
+		if phase > 0 {
+			total += phase
+		}
+	}
+	return total
+}
+
+// transcriptToHAR converts a WebSocket transcript to the message format used
+// by browser devtools, where time is in seconds since the epoch.
+func transcriptToHAR(transcript model.WebSocketTranscript) []model.HARWebSocketMessage {
+	messages := make([]model.HARWebSocketMessage, 0, len(transcript.Messages))
+	for _, message := range transcript.Messages {
+		harMessage := model.HARWebSocketMessage{
+			Type:   "send",
+			Time:   float64(message.Timestamp.UnixNano()) / float64(time.Second),
+			Opcode: 1,
+			Data:   message.Data,
+		}
+		if message.Direction == "received" {
+			harMessage.Type = "receive"
+		}
+		if message.Type == "binary" {
+			harMessage.Opcode = 2
+			harMessage.Data = message.DataBase64
+		}
+		messages = append(messages, harMessage)
+	}
+	return messages
+}
+
+// ImportHAR stores the entries of a HAR file as history of a user. Entries
+// that cannot be represented, such as data: URLs, are skipped. All stored
+// entries are written in one transaction.
+func (s *historyService) ImportHAR(ctx context.Context, userID int, har *model.HAR) (*model.DTOHARImportResponse, error) {
+	if len(har.Log.Entries) == 0 {
+		return nil, fmt.Errorf("%w: the HAR file contains no entries", ErrInvalidInput)
+	}
+	if len(har.Log.Entries) > maxHARImportEntries {
+		return nil, fmt.Errorf("%w: the HAR file contains %d entries, at most %d can be imported", ErrInvalidInput, len(har.Log.Entries), maxHARImportEntries)
+	}
+
+	result := &model.DTOHARImportResponse{}
+	var requests []*model.Request
+	for i, entry := range har.Log.Entries {
+		request, err := harEntryToHistory(entry)
+		if err != nil {
+			result.Skipped++
+			result.Warnings = append(result.Warnings, fmt.Sprintf("entry %d: %v", i, err))
+			continue
+		}
+		request.UserID = &userID
+		requests = append(requests, request)
+	}
+
+	if len(requests) > 0 {
+		if err := s.repository.CreateMany(ctx, requests); err != nil {
+			return nil, fmt.Errorf("failed to store imported history: %w", err)
+		}
+	}
+	result.Imported = len(requests)
+	return result, nil
+}
+
+func harHeadersToJSON(pairs []model.HARNameValue) (json.RawMessage, error) {
+	headers := make(map[string][]string)
+	for _, pair := range pairs {
+		// HTTP/2 pseudo headers such as :authority are not real headers.
+		if strings.HasPrefix(pair.Name, ":") {
+			continue
+		}
+		headers[pair.Name] = append(headers[pair.Name], pair.Value)
+	}
+	return json.Marshal(headers)
+}
+
+func harEntryToHistory(entry model.HAREntry) (*model.Request, error) {
+	parsedURL, err := url.Parse(entry.Request.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %v", err)
+	}
+
+	requestType := model.RequestTypeHTTP
+	switch parsedURL.Scheme {
+	case "http", "https":
+		if entry.ResourceType == "websocket" {
+			requestType = model.RequestTypeWebSocket
+		}
+	case "ws", "wss":
+		requestType = model.RequestTypeWebSocket
+	default:
+		return nil, fmt.Errorf("unsupported URL scheme %q", parsedURL.Scheme)
+	}
+
+	method := strings.ToUpper(entry.Request.Method)
+	if !allowedMethods[method] {
+		return nil, fmt.Errorf("unsupported method %q", entry.Request.Method)
+	}
+
+	requestHeaders, err := harHeadersToJSON(entry.Request.Headers)
+	if err != nil {
+		return nil, err
+	}
+	responseHeaders, err := harHeadersToJSON(entry.Response.Headers)
+	if err != nil {
+		return nil, err
+	}
+
+	request := &model.Request{
+		RequestType:     requestType,
+		ExecutedAt:      entry.StartedDateTime,
+		RequestMethod:   method,
+		RequestURL:      parsedURL.String(),
+		RequestHeaders:  requestHeaders,
+		ResponseHeaders: responseHeaders,
+	}
+
+	if postData := entry.Request.PostData; postData != nil {
+		body := postData.Text
+		if body == "" && len(postData.Params) > 0 {
+			form := make([]string, 0, len(postData.Params))
+			for _, param := range postData.Params {
+				form = append(form, url.QueryEscape(param.Name)+"="+url.QueryEscape(param.Value))
+			}
+			body = strings.Join(form, "&")
+		}
+		if body != "" {
+			request.RequestBody = &body
+		}
+	}
+
+	// A status of 0 means the request never got a response.
+	if entry.Response.Status > 0 {
+		status := entry.Response.Status
+		request.ResponseStatusCode = &status
+	}
+	if body, ok := harContentText(entry.Response.Content); ok {
+		request.ResponseBody = &body
+	}
+	if size := entry.Response.Content.Size; size >= 0 {
+		request.ResponseSize = &size
+	}
+	if entry.Time >= 0 {
+		durationMs := int(entry.Time)
+		request.DurationMs = &durationMs
+	}
+
+	if requestType == model.RequestTypeHTTP && entry.Timings != (model.HARTimings{}) {
+		request.Timings, err = json.Marshal(model.RequestTimings{
+			Blocked: entry.Timings.Blocked,
+			DNS:     entry.Timings.DNS,
+			Connect: entry.Timings.Connect,
+			TLS:     entry.Timings.SSL,
+			Send:    entry.Timings.Send,
+			Wait:    entry.Timings.Wait,
+			Receive: entry.Timings.Receive,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if requestType == model.RequestTypeWebSocket && len(entry.WebSocketMessages) > 0 {
+		transcript := harToTranscript(entry.WebSocketMessages)
+		transcript.OpenedAt = entry.StartedDateTime
+		transcript.ClosedAt = entry.StartedDateTime.Add(time.Duration(entry.Time * float64(time.Millisecond)))
+		request.SessionTranscript, err = json.Marshal(transcript)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return request, nil
+}
+
+// harContentText returns a response body that can be stored as text. Binary
+// content, including base64 encoded text that does not decode to UTF-8, is
+// left out and only its size is kept.
+func harContentText(content model.HARContent) (string, bool) {
+	text := content.Text
+	if content.Encoding == "base64" {
+		decoded, err := base64.StdEncoding.DecodeString(text)
+		if err != nil {
+			return "", false
+		}
+		text = string(decoded)
+	}
+	if text == "" || !utf8.ValidString(text) || strings.ContainsRune(text, 0) {
+		return "", false
+	}
+	if mediaType, _, err := mime.ParseMediaType(content.MimeType); err == nil && !isTextContentType(mediaType) && !json.Valid([]byte(text)) {
+		return "", false
+	}
+	return text, true
+}
+
+func harToTranscript(messages []model.HARWebSocketMessage) model.WebSocketTranscript {
+	transcript := model.WebSocketTranscript{Messages: []model.WebSocketMessage{}}
+	for _, harMessage := range messages {
+		if len(transcript.Messages) >= maxTranscriptMessages {
+			transcript.MessagesDropped++
+			continue
+		}
+		seconds := int64(harMessage.Time)
+		message := model.WebSocketMessage{
+			Direction: "sent",
+			Type:      "text",
+			Timestamp: time.Unix(seconds, int64((harMessage.Time-float64(seconds))*float64(time.Second))),
+		}
+		if harMessage.Type == "receive" {
+			message.Direction = "received"
+		}
+		if harMessage.Opcode == 2 {
+			message.Type = "binary"
+			message.DataBase64 = harMessage.Data
+			if decoded, err := base64.StdEncoding.DecodeString(harMessage.Data); err == nil {
+				message.Size = len(decoded)
+			}
+		} else {
+			message.Data = harMessage.Data
+			message.Size = len(harMessage.Data)
+		}
+		transcript.Messages = append(transcript.Messages, message)
+	}
+	return transcript
+}
@@ -0,0 +1,285 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/suar-net/suar-be/internal/model"
+	"github.com/suar-net/suar-be/internal/repository"
+)
+
+func TestHistoryTimings(t *testing.T) {
+	tests := []struct {
+		name     string
+		raw      string
+		duration float64
+		want     model.HARTimings
+		wantErr  bool
+	}{
+		{"no timings", "", 120, model.HARTimings{Blocked: -1, DNS: -1, Connect: -1, Wait: 120, SSL: -1}, false},
+		{"null timings", "null", 5, model.HARTimings{Blocked: -1, DNS: -1, Connect: -1, Wait: 5, SSL: -1}, false},
+		{"phases", `{"blocked":1,"dns":2,"connect":9,"tls":6,"send":0.5,"wait":40,"receive":3}`, 0,
+			model.HARTimings{Blocked: 1, DNS: 2, Connect: 9, Send: 0.5, Wait: 40, Receive: 3, SSL: 6}, false},
+		{"reused connection", `{"blocked":0,"dns":-1,"connect":-1,"tls":-1,"send":0,"wait":10,"receive":1}`, 0,
+			model.HARTimings{Blocked: 0, DNS: -1, Connect: -1, Send: 0, Wait: 10, Receive: 1, SSL: -1}, false},
+		{"invalid", `[1]`, 0, model.HARTimings{}, true},
+	}
+	for _, tt := range tests {
+		got, err := historyTimings(json.RawMessage(tt.raw), tt.duration)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: timings = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHARTotal(t *testing.T) {
+	tests := []struct {
+		timings model.HARTimings
+		want    float64
+	}{
+		{model.HARTimings{Blocked: -1, DNS: -1, Connect: -1, Wait: 10, SSL: -1}, 10},
+		// SSL is part of connect and not counted twice.
+		{model.HARTimings{Blocked: 1, DNS: 2, Connect: 9, Send: 1, Wait: 40, Receive: 3, SSL: 6}, 56},
+	}
+	for _, tt := range tests {
+		if got := harTotal(tt.timings); got != tt.want {
+			t.Errorf("harTotal(%+v) = %v, want %v", tt.timings, got, tt.want)
+		}
+	}
+}
+
+func historyEntry(id int) *model.Request {
+	status, size, duration := 201, int64(7), 12
+	requestBody, responseBody := `{"a":1}`, `{"id":7}`
+	return &model.Request{
+		ID:                 id,
+		RequestType:        model.RequestTypeHTTP,
+		ExecutedAt:         time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+		RequestMethod:      "POST",
+		RequestURL:         "https://a.test/items?q=a%20b&x",
+		RequestHeaders:     json.RawMessage(`{"Content-Type":["application/json"],"Accept":["*/*"]}`),
+		RequestBody:        &requestBody,
+		ResponseStatusCode: &status,
+		ResponseHeaders:    json.RawMessage(`{"Content-Type":["application/json"],"Location":["/items/7"]}`),
+		ResponseBody:       &responseBody,
+		ResponseSize:       &size,
+		DurationMs:         &duration,
+	}
+}
+
+func TestHistoryToHAREntry(t *testing.T) {
+	entry, err := historyToHAREntry(historyEntry(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Time != 12 || entry.Timings.Wait != 12 || entry.Timings.DNS != -1 {
+		t.Errorf("time = %v, timings = %+v", entry.Time, entry.Timings)
+	}
+	wantQuery := []model.HARNameValue{{Name: "q", Value: "a b"}, {Name: "x", Value: ""}}
+	if !reflect.DeepEqual(entry.Request.QueryString, wantQuery) {
+		t.Errorf("query = %+v, want %+v", entry.Request.QueryString, wantQuery)
+	}
+	if entry.Request.Headers[0].Name != "Accept" {
+		t.Errorf("headers not sorted: %+v", entry.Request.Headers)
+	}
+	if entry.Request.PostData == nil || entry.Request.PostData.MimeType != "application/json" || entry.Request.BodySize != 7 {
+		t.Errorf("post data = %+v, body size %d", entry.Request.PostData, entry.Request.BodySize)
+	}
+	if entry.Response.Status != 201 || entry.Response.StatusText != "Created" || entry.Response.RedirectURL != "/items/7" ||
+		entry.Response.Content.Text != `{"id":7}` || entry.Response.Content.Size != 7 {
+		t.Errorf("response = %+v", entry.Response)
+	}
+
+	timed := historyEntry(2)
+	timed.Timings = json.RawMessage(`{"blocked":1,"dns":2,"connect":9,"tls":6,"send":1,"wait":40,"receive":3}`)
+	entry, err = historyToHAREntry(timed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Time != 56 || entry.Timings.SSL != 6 || entry.Timings.Connect != 9 {
+		t.Errorf("time = %v, timings = %+v", entry.Time, entry.Timings)
+	}
+
+	broken := historyEntry(3)
+	broken.Timings = json.RawMessage(`"x"`)
+	if _, err := historyToHAREntry(broken); err == nil {
+		t.Error("invalid timings were accepted")
+	}
+}
+
+func TestHAREntryToHistory(t *testing.T) {
+	started := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		entry       model.HAREntry
+		wantErr     bool
+		requestType string
+		body        string
+		timings     string
+	}{
+		{
+			name: "http with timings",
+			entry: model.HAREntry{
+				StartedDateTime: started, Time: 56,
+				Request:  model.HARRequest{Method: "post", URL: "https://a.test/x", PostData: &model.HARPostData{Text: "a=1"}},
+				Response: model.HARResponse{Status: 200},
+				Timings:  model.HARTimings{Blocked: 1, DNS: 2, Connect: 9, Send: 1, Wait: 40, Receive: 3, SSL: 6},
+			},
+			requestType: model.RequestTypeHTTP,
+			body:        "a=1",
+			timings:     `{"blocked":1,"dns":2,"connect":9,"tls":6,"send":1,"wait":40,"receive":3}`,
+		},
+		{
+			name: "form params",
+			entry: model.HAREntry{
+				Request: model.HARRequest{Method: "POST", URL: "https://a.test/x", PostData: &model.HARPostData{
+					Params: []model.HARNameValue{{Name: "a b", Value: "1&2"}, {Name: "c", Value: ""}},
+				}},
+			},
+			requestType: model.RequestTypeHTTP,
+			body:        "a+b=1%262&c=",
+		},
+		{
+			name:        "websocket upgrade",
+			entry:       model.HAREntry{ResourceType: "websocket", Request: model.HARRequest{Method: "GET", URL: "wss://a.test/ws"}},
+			requestType: model.RequestTypeWebSocket,
+		},
+		{name: "data url", entry: model.HAREntry{Request: model.HARRequest{Method: "GET", URL: "data:text/plain,hi"}}, wantErr: true},
+		{name: "unknown method", entry: model.HAREntry{Request: model.HARRequest{Method: "BREW", URL: "https://a.test"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := harEntryToHistory(tt.entry)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if got.RequestType != tt.requestType {
+			t.Errorf("%s: type = %q, want %q", tt.name, got.RequestType, tt.requestType)
+		}
+		var body string
+		if got.RequestBody != nil {
+			body = *got.RequestBody
+		}
+		if body != tt.body {
+			t.Errorf("%s: body = %q, want %q", tt.name, body, tt.body)
+		}
+		if string(got.Timings) != tt.timings {
+			t.Errorf("%s: timings = %s, want %s", tt.name, got.Timings, tt.timings)
+		}
+	}
+}
+
+func TestHARContentText(t *testing.T) {
+	tests := []struct {
+		content model.HARContent
+		want    string
+		ok      bool
+	}{
+		{model.HARContent{MimeType: "text/plain", Text: "hi"}, "hi", true},
+		{model.HARContent{MimeType: "application/json", Text: "aGk=", Encoding: "base64"}, "hi", true},
+		{model.HARContent{MimeType: "text/plain", Text: "aGk=", Encoding: "base64"}, "hi", true},
+		{model.HARContent{MimeType: "application/octet-stream", Text: `{"a":1}`}, `{"a":1}`, true},
+		{model.HARContent{MimeType: "image/png", Text: "iVBORw0KGgo=", Encoding: "base64"}, "", false},
+		{model.HARContent{MimeType: "text/plain", Text: "%%%", Encoding: "base64"}, "", false},
+		{model.HARContent{MimeType: "text/plain", Text: ""}, "", false},
+	}
+	for _, tt := range tests {
+		got, ok := harContentText(tt.content)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("harContentText(%+v) = %q, %v, want %q, %v", tt.content, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestTranscriptHARRoundTrip(t *testing.T) {
+	at := time.Date(2024, 5, 1, 10, 0, 0, 500_000_000, time.UTC)
+	transcript := model.WebSocketTranscript{Messages: []model.WebSocketMessage{
+		{Direction: "sent", Type: "text", Data: "ping", Size: 4, Timestamp: at},
+		{Direction: "received", Type: "binary", DataBase64: "AAE=", Size: 2, Timestamp: at.Add(time.Second)},
+	}}
+	got := harToTranscript(transcriptToHAR(transcript))
+	if len(got.Messages) != 2 {
+		t.Fatalf("messages = %+v", got.Messages)
+	}
+	for i, message := range got.Messages {
+		want := transcript.Messages[i]
+		if message.Direction != want.Direction || message.Type != want.Type || message.Data != want.Data ||
+			message.DataBase64 != want.DataBase64 || message.Size != want.Size || !message.Timestamp.Equal(want.Timestamp) {
+			t.Errorf("message %d = %+v, want %+v", i, message, want)
+		}
+	}
+}
+
+type fakeRequestRepo struct {
+	repository.IRequestRepository
+	entries map[int]*model.Request
+}
+
+func (f *fakeRequestRepo) GetByIDs(ctx context.Context, ids []int, userID int) ([]*model.Request, error) {
+	var found []*model.Request
+	for _, id := range ids {
+		if entry, ok := f.entries[id]; ok && userID == 1 {
+			found = append(found, entry)
+		}
+	}
+	return found, nil
+}
+
+type fakeRunRepo struct {
+	repository.ICollectionRunRepository
+	runs map[int]*model.CollectionRun
+}
+
+func (f *fakeRunRepo) GetByID(ctx context.Context, id int, userID int) (*model.CollectionRun, error) {
+	if run, ok := f.runs[id]; ok && run.UserID == userID {
+		return run, nil
+	}
+	return nil, nil
+}
+
+func TestExportRunHAR(t *testing.T) {
+	first, second, deleted := 5, 3, 99
+	requests := &fakeRequestRepo{entries: map[int]*model.Request{5: historyEntry(5), 3: historyEntry(3)}}
+	runs := &fakeRunRepo{runs: map[int]*model.CollectionRun{
+		1: {ID: 1, UserID: 1, Totals: model.RunTotals{Iterations: 2}, Results: []model.RunItemResult{
+			{Iteration: 1, Path: "Users / Create", HistoryID: &first},
+			{Iteration: 1, Path: "Users / Broken", Error: "dial failed"},
+			{Iteration: 2, Path: "Users / Create", HistoryID: &second},
+			{Iteration: 2, Path: "Users / Gone", HistoryID: &deleted},
+		}},
+		2: {ID: 2, UserID: 1, Results: []model.RunItemResult{{Path: "Skipped history"}}},
+	}}
+	s := NewHistoryService(requests, runs)
+	ctx := context.Background()
+
+	har, err := s.ExportRunHAR(ctx, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var comments []string
+	for _, entry := range har.Log.Entries {
+		comments = append(comments, entry.Comment)
+	}
+	want := []string{"Users / Create (iteration 1)", "Users / Create (iteration 2)"}
+	if !reflect.DeepEqual(comments, want) {
+		t.Errorf("entries = %q, want %q", comments, want)
+	}
+
+	if _, err := s.ExportRunHAR(ctx, 2, 1); !errors.Is(err, ErrRunNotFound) {
+		t.Errorf("run of another user: err = %v, want ErrRunNotFound", err)
+	}
+	if _, err := s.ExportRunHAR(ctx, 1, 2); !errors.Is(err, ErrHistoryNotFound) {
+		t.Errorf("run without history: err = %v, want ErrHistoryNotFound", err)
+	}
+}
@@ -0,0 +1,193 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/suar-net/suar-be/internal/model"
+	"github.com/suar-net/suar-be/internal/repository"
+)
+
+// importService converts request definitions from other tools into the
+// request format and collection model of this API.
+type importService struct {
+	collections  repository.ICollectionRepository
+	environments repository.IEnvironmentRepository
+	specs        repository.IAPISpecRepository
+}
+
+func NewImportService(c repository.ICollectionRepository, e repository.IEnvironmentRepository, s repository.IAPISpecRepository) IImportService {
+	return &importService{collections: c, environments: e, specs: s}
+}
+
+// ImportCurl parses a cURL command line, as copied from browser devtools or
+// API documentation, into a request. With a collection_id the request is also
+// saved at the end of that collection.
+func (s *importService) ImportCurl(ctx context.Context, userID *int, dto *model.DTOCurlImportRequest) (*model.DTOCurlImportResponse, error) {
+	imported, err := parseCurlCommand(dto.Command)
+	if err != nil || dto.CollectionID == nil {
+		return imported, err
+	}
+	if userID == nil {
+		return nil, ErrCollectionNotFound
+	}
+
+	item := curlToSavedRequest(&imported.Request, dto.Name, &imported.Warnings)
+	added, err := s.collections.AddItem(ctx, *dto.CollectionID, *userID, item)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save imported request: %w", err)
+	}
+	if !added {
+		return nil, ErrCollectionNotFound
+	}
+	imported.Saved = &model.DTOImportedResource{ID: item.ID, Name: item.Name}
+	return imported, nil
+}
+
+// ImportPostman stores a Postman v2.1 collection or a Postman environment,
+// whichever data contains.
+func (s *importService) ImportPostman(ctx context.Context, userID int, data []byte) (*model.DTOCollectionImportResponse, error) {
+	var probe struct {
+		Info   json.RawMessage `json:"info"`
+		Values json.RawMessage `json:"values"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, fmt.Errorf("%w: invalid JSON: %v", ErrInvalidInput, err)
+	}
+
+	var warnings importWarnings
+	switch {
+	case len(probe.Info) > 0:
+		var postman model.PostmanCollection
+		if err := json.Unmarshal(data, &postman); err != nil {
+			return nil, fmt.Errorf("%w: invalid Postman collection: %v", ErrInvalidInput, err)
+		}
+		collection, err := postmanToCollection(&postman, &warnings)
+		if err != nil {
+			return nil, err
+		}
+		return s.store(ctx, userID, []*model.Collection{collection}, nil, warnings)
+	case len(probe.Values) > 0:
+		var postman model.PostmanEnvironment
+		if err := json.Unmarshal(data, &postman); err != nil {
+			return nil, fmt.Errorf("%w: invalid Postman environment: %v", ErrInvalidInput, err)
+		}
+		environment, err := postmanEnvironmentToNative(&postman)
+		if err != nil {
+			return nil, err
+		}
+		return s.store(ctx, userID, nil, []*model.Environment{environment}, warnings)
+	}
+	return nil, fmt.Errorf("%w: not a Postman collection or environment export", ErrInvalidInput)
+}
+
+// ImportInsomnia stores every workspace of an Insomnia v4 export as a
+// collection together with its environments.
+func (s *importService) ImportInsomnia(ctx context.Context, userID int, data []byte) (*model.DTOCollectionImportResponse, error) {
+	var export insomniaExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, fmt.Errorf("%w: invalid Insomnia export: %v", ErrInvalidInput, err)
+	}
+
+	var warnings importWarnings
+	collections, environments, err := insomniaToNative(&export, &warnings)
+	if err != nil {
+		return nil, err
+	}
+	return s.store(ctx, userID, collections, environments, warnings)
+}
+
+// ImportOpenAPI generates a collection from an OpenAPI 3.0 or 3.1 document,
+// given as JSON or YAML, with an environment for each declared server. The
+// document is stored as a spec and every generated request is linked to its
+// operation, so responses are validated against it.
+func (s *importService) ImportOpenAPI(ctx context.Context, userID int, data []byte) (*model.DTOCollectionImportResponse, error) {
+	doc, document, err := parseOpenAPIDocument(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var warnings importWarnings
+	collection, environments := openAPIToNative(doc, &warnings)
+
+	spec := &model.APISpec{UserID: userID, Name: collection.Name, Version: doc.Info.Version, Document: document}
+	specID, err := s.specs.Create(ctx, spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store spec %q: %w", spec.Name, err)
+	}
+	setContractSpec(collection.Items, specID)
+
+	response, err := s.store(ctx, userID, []*model.Collection{collection}, environments, warnings)
+	if err != nil {
+		return nil, err
+	}
+	response.Specs = []model.DTOImportedResource{{ID: specID, Name: spec.Name}}
+	return response, nil
+}
+
+// ImportHAR saves the HTTP requests recorded in a HAR file, such as a browser
+// export, as a new collection. Recorded responses become examples.
+func (s *importService) ImportHAR(ctx context.Context, userID int, data []byte) (*model.DTOCollectionImportResponse, error) {
+	var har model.HAR
+	if err := json.Unmarshal(data, &har); err != nil {
+		return nil, fmt.Errorf("%w: invalid HAR file: %v", ErrInvalidInput, err)
+	}
+
+	var warnings importWarnings
+	collection, err := harToCollection(&har, &warnings)
+	if err != nil {
+		return nil, err
+	}
+	return s.store(ctx, userID, []*model.Collection{collection}, nil, warnings)
+}
+
+func setContractSpec(items []*model.CollectionItem, specID int) {
+	for _, item := range items {
+		if item.Contract != nil {
+			item.Contract.SpecID = specID
+		}
+		setContractSpec(item.Items, specID)
+	}
+}
+
+func (s *importService) store(ctx context.Context, userID int, collections []*model.Collection, environments []*model.Environment, warnings importWarnings) (*model.DTOCollectionImportResponse, error) {
+	response := &model.DTOCollectionImportResponse{
+		Collections:  []model.DTOImportedResource{},
+		Environments: []model.DTOImportedResource{},
+		Warnings:     warnings,
+	}
+
+	for _, collection := range collections {
+		collection.UserID = userID
+		normalizeCollectionItems(collection.Items)
+		id, err := s.collections.Create(ctx, collection)
+		if err != nil {
+			return nil, fmt.Errorf("failed to store collection %q: %w", collection.Name, err)
+		}
+		response.Collections = append(response.Collections, model.DTOImportedResource{ID: id, Name: collection.Name})
+	}
+	for _, environment := range environments {
+		environment.UserID = userID
+		id, err := s.environments.Create(ctx, environment)
+		if err != nil {
+			return nil, fmt.Errorf("failed to store environment %q: %w", environment.Name, err)
+		}
+		response.Environments = append(response.Environments, model.DTOImportedResource{ID: id, Name: environment.Name})
+	}
+	return response, nil
+}
+
+// normalizeCollectionItems fills in defaults the database requires.
+func normalizeCollectionItems(items []*model.CollectionItem) {
+	for _, item := range items {
+		if strings.TrimSpace(item.Name) == "" {
+			item.Name = "Untitled"
+		}
+		if item.Request != nil && item.Request.Method == "" {
+			item.Request.Method = "GET"
+		}
+		normalizeCollectionItems(item.Items)
+	}
+}
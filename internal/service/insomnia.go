@@ -0,0 +1,295 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/suar-net/suar-be/internal/model"
+)
+
+// insomniaExport is an Insomnia v4 export. Every workspace, folder, request
+// and environment is a resource pointing at its parent through ParentID.
+type insomniaExport struct {
+	Type         string             `json:"_type"`
+	ExportFormat int                `json:"__export_format"`
+	Resources    []insomniaResource `json:"resources"`
+}
+
+type insomniaResource struct {
+	ID             string              `json:"_id"`
+	Type           string              `json:"_type"`
+	ParentID       string              `json:"parentId"`
+	Name           string              `json:"name"`
+	Description    string              `json:"description"`
+	MetaSortKey    float64             `json:"metaSortKey"`
+	Method         string              `json:"method"`
+	URL            string              `json:"url"`
+	Body           insomniaBody        `json:"body"`
+	Headers        []insomniaPair      `json:"headers"`
+	Parameters     []insomniaPair      `json:"parameters"`
+	Authentication map[string]any      `json:"authentication"`
+	Data           map[string]any      `json:"data"`
+	PreRequest     string              `json:"preRequestScript"`
+	AfterResponse  string              `json:"afterResponseScript"`
+	children       []*insomniaResource `json:"-"`
+}
+
+type insomniaBody struct {
+	MimeType string         `json:"mimeType"`
+	Text     string         `json:"text"`
+	Params   []insomniaPair `json:"params"`
+}
+
+type insomniaPair struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Disabled bool   `json:"disabled"`
+	Type     string `json:"type"`
+	FileName string `json:"fileName"`
+}
+
+// insomniaTemplate matches Insomnia's {{ _.name }} variable syntax.
+var insomniaTemplate = regexp.MustCompile(`\{\{\s*_\.([A-Za-z0-9_.\-]+)\s*\}\}`)
+
+// insomniaText converts Insomnia variables to the {{name}} syntax used here.
+func insomniaText(s string) string {
+	return insomniaTemplate.ReplaceAllString(s, "{{$1}}")
+}
+
+// insomniaToNative converts every workspace of an Insomnia export into a
+// collection. The base environment of a workspace becomes the collection
+// variables and each sub environment becomes an environment.
+func insomniaToNative(export *insomniaExport, warnings *importWarnings) ([]*model.Collection, []*model.Environment, error) {
+	if export.Type != "export" || export.ExportFormat != 4 {
+		return nil, nil, fmt.Errorf("%w: not an Insomnia v4 export", ErrInvalidInput)
+	}
+
+	byID := make(map[string]*insomniaResource, len(export.Resources))
+	for i := range export.Resources {
+		byID[export.Resources[i].ID] = &export.Resources[i]
+	}
+	var workspaces []*insomniaResource
+	for i := range export.Resources {
+		resource := &export.Resources[i]
+		if resource.Type == "workspace" {
+			workspaces = append(workspaces, resource)
+			continue
+		}
+		if parent, ok := byID[resource.ParentID]; ok {
+			parent.children = append(parent.children, resource)
+		}
+	}
+	if len(workspaces) == 0 {
+		return nil, nil, fmt.Errorf("%w: the export contains no workspace", ErrInvalidInput)
+	}
+
+	var collections []*model.Collection
+	var environments []*model.Environment
+	for _, workspace := range workspaces {
+		collection := &model.Collection{
+			Name:        workspace.Name,
+			Description: workspace.Description,
+			Variables:   []model.Variable{},
+			Items:       insomniaItemsToNative(workspace.children, workspace.Name, warnings),
+		}
+		for _, child := range workspace.children {
+			if child.Type != "environment" {
+				continue
+			}
+			collection.Variables = append(collection.Variables, insomniaVariables(child.Data)...)
+			for _, sub := range child.children {
+				if sub.Type == "environment" {
+					environments = append(environments, &model.Environment{
+						Name:      workspace.Name + " - " + sub.Name,
+						Variables: insomniaVariables(sub.Data),
+					})
+				}
+			}
+		}
+		collections = append(collections, collection)
+	}
+	return collections, environments, nil
+}
+
+func insomniaItemsToNative(resources []*insomniaResource, path string, warnings *importWarnings) []*model.CollectionItem {
+	sorted := make([]*insomniaResource, len(resources))
+	copy(sorted, resources)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].MetaSortKey < sorted[j].MetaSortKey })
+
+	var items []*model.CollectionItem
+	for _, resource := range sorted {
+		itemPath := path + " / " + resource.Name
+		switch resource.Type {
+		case "request_group":
+			items = append(items, &model.CollectionItem{
+				Type:        model.CollectionItemFolder,
+				Name:        resource.Name,
+				Description: resource.Description,
+				Auth:        insomniaAuthToNative(resource.Authentication, itemPath, warnings),
+				Items:       insomniaItemsToNative(resource.children, itemPath, warnings),
+			})
+		case "request":
+			items = append(items, insomniaRequestToNative(resource, itemPath, warnings))
+		case "grpc_request", "websocket_request":
+			warnings.add("%s: %s resources are not supported and were skipped", itemPath, resource.Type)
+		}
+	}
+	return items
+}
+
+func insomniaRequestToNative(resource *insomniaResource, path string, warnings *importWarnings) *model.CollectionItem {
+	requestURL := insomniaText(resource.URL)
+	var query []string
+	for _, param := range resource.Parameters {
+		if !param.Disabled {
+			query = append(query, insomniaText(param.Name)+"="+insomniaText(param.Value))
+		}
+	}
+	if len(query) > 0 {
+		separator := "?"
+		if strings.Contains(requestURL, "?") {
+			separator = "&"
+		}
+		requestURL += separator + strings.Join(query, "&")
+	}
+
+	request := &model.CollectionRequest{
+		Method:  strings.ToUpper(resource.Method),
+		URL:     requestURL,
+		Headers: insomniaPairs(resource.Headers),
+		Body:    insomniaBodyToNative(resource.Body, path, warnings),
+	}
+
+	var scripts *model.Scripts
+	if strings.TrimSpace(resource.PreRequest) != "" || strings.TrimSpace(resource.AfterResponse) != "" {
+		scripts = &model.Scripts{PreRequest: resource.PreRequest, Test: resource.AfterResponse}
+		warnings.add("%s: scripts were imported as is, Insomnia's insomnia.* API may need to be rewritten", path)
+	}
+
+	return &model.CollectionItem{
+		Type:        model.CollectionItemRequest,
+		Name:        resource.Name,
+		Description: resource.Description,
+		Request:     request,
+		Auth:        insomniaAuthToNative(resource.Authentication, path, warnings),
+		Scripts:     scripts,
+	}
+}
+
+func insomniaPairs(pairs []insomniaPair) []model.KeyValue {
+	var native []model.KeyValue
+	for _, pair := range pairs {
+		native = append(native, model.KeyValue{Key: insomniaText(pair.Name), Value: insomniaText(pair.Value), Disabled: pair.Disabled})
+	}
+	return native
+}
+
+func insomniaBodyToNative(body insomniaBody, path string, warnings *importWarnings) *model.RequestBody {
+	switch body.MimeType {
+	case "":
+		if body.Text == "" {
+			return nil
+		}
+		return &model.RequestBody{Mode: model.BodyModeRaw, Raw: insomniaText(body.Text)}
+	case "application/x-www-form-urlencoded":
+		return &model.RequestBody{Mode: model.BodyModeURLEncoded, URLEncoded: insomniaPairs(body.Params)}
+	case "multipart/form-data":
+		native := &model.RequestBody{Mode: model.BodyModeFormData}
+		for _, param := range body.Params {
+			field := model.FormField{Key: insomniaText(param.Name), Value: insomniaText(param.Value), Type: "text", Disabled: param.Disabled}
+			if param.Type == "file" {
+				field.Type, field.Value = "file", param.FileName
+				warnings.add("%s: the file of form field %q was not imported", path, param.Name)
+			}
+			native.FormData = append(native.FormData, field)
+		}
+		return native
+	case "application/graphql":
+		var envelope struct {
+			Query     string          `json:"query"`
+			Variables json.RawMessage `json:"variables"`
+		}
+		if err := json.Unmarshal([]byte(body.Text), &envelope); err != nil {
+			warnings.add("%s: invalid graphql body was dropped", path)
+			return nil
+		}
+		graphQL := &model.DTOGraphQLBody{Query: insomniaText(envelope.Query)}
+		if len(envelope.Variables) > 0 && string(envelope.Variables) != "null" {
+			graphQL.Variables = json.RawMessage(insomniaText(string(envelope.Variables)))
+		}
+		return &model.RequestBody{Mode: model.BodyModeGraphQL, GraphQL: graphQL}
+	default:
+		if body.Text == "" {
+			warnings.add("%s: body type %s is not supported and was dropped", path, body.MimeType)
+			return nil
+		}
+		return &model.RequestBody{Mode: model.BodyModeRaw, Raw: insomniaText(body.Text), ContentType: body.MimeType}
+	}
+}
+
+func insomniaAuthToNative(auth map[string]any, path string, warnings *importWarnings) *model.RequestAuth {
+	if len(auth) == 0 {
+		return nil
+	}
+	field := func(name string) string {
+		value, _ := auth[name].(string)
+		return insomniaText(value)
+	}
+	if disabled, _ := auth["disabled"].(bool); disabled {
+		return &model.RequestAuth{Type: model.AuthNone}
+	}
+
+	switch field("type") {
+	case "", "inherit":
+		return nil
+	case "none":
+		return &model.RequestAuth{Type: model.AuthNone}
+	case "basic":
+		return &model.RequestAuth{Type: model.AuthBasic, Username: field("username"), Password: field("password")}
+	case "bearer":
+		// A custom prefix replaces "Bearer", which only a plain header can express.
+		if prefix := field("prefix"); prefix != "" && !strings.EqualFold(prefix, "Bearer") {
+			return &model.RequestAuth{Type: model.AuthAPIKey, Key: "Authorization", Value: prefix + " " + field("token"), In: "header"}
+		}
+		return &model.RequestAuth{Type: model.AuthBearer, Token: field("token")}
+	case "apikey":
+		in := "header"
+		if field("addTo") == "queryParams" {
+			in = "query"
+		}
+		return &model.RequestAuth{Type: model.AuthAPIKey, Key: field("key"), Value: field("value"), In: in}
+	default:
+		warnings.add("%s: auth type %q is not supported, no auth is sent", path, field("type"))
+		return &model.RequestAuth{Type: model.AuthNone}
+	}
+}
+
+// insomniaVariables flattens environment data, nested objects become dotted
+// keys so {{ _.api.host }} maps to {{api.host}}.
+func insomniaVariables(data map[string]any) []model.Variable {
+	variables := []model.Variable{}
+	var walk func(prefix string, value any)
+	walk = func(prefix string, value any) {
+		if object, ok := value.(map[string]any); ok {
+			keys := make([]string, 0, len(object))
+			for key := range object {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				name := key
+				if prefix != "" {
+					name = prefix + "." + key
+				}
+				walk(name, object[key])
+			}
+			return
+		}
+		variables = append(variables, model.Variable{Key: prefix, Value: insomniaText(scalarString(value))})
+	}
+	walk("", data)
+	return variables
+}
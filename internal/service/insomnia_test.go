@@ -0,0 +1,183 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/suar-net/suar-be/internal/model"
+)
+
+func TestInsomniaText(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"{{ _.base }}/users", "{{base}}/users"},
+		{"{{_.api.host}}:{{ _.port }}", "{{api.host}}:{{port}}"},
+		{"{{ plain }}", "{{ plain }}"},
+		{"no variables", "no variables"},
+	}
+	for _, tt := range tests {
+		if got := insomniaText(tt.in); got != tt.want {
+			t.Errorf("insomniaText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestInsomniaBodyToNative(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     insomniaBody
+		want     *model.RequestBody
+		warnings int
+	}{
+		{"empty", insomniaBody{}, nil, 0},
+		{"text without type", insomniaBody{Text: "{{ _.x }}"}, &model.RequestBody{Mode: model.BodyModeRaw, Raw: "{{x}}"}, 0},
+		{"json", insomniaBody{MimeType: "application/json", Text: `{"a":"{{ _.a }}"}`},
+			&model.RequestBody{Mode: model.BodyModeRaw, Raw: `{"a":"{{a}}"}`, ContentType: "application/json"}, 0},
+		{"urlencoded", insomniaBody{MimeType: "application/x-www-form-urlencoded", Params: []insomniaPair{{Name: "a", Value: "{{ _.v }}", Disabled: true}}},
+			&model.RequestBody{Mode: model.BodyModeURLEncoded, URLEncoded: []model.KeyValue{{Key: "a", Value: "{{v}}", Disabled: true}}}, 0},
+		{"multipart with file", insomniaBody{MimeType: "multipart/form-data", Params: []insomniaPair{
+			{Name: "n", Value: "v"}, {Name: "f", Type: "file", FileName: "/tmp/a.png"},
+		}}, &model.RequestBody{Mode: model.BodyModeFormData, FormData: []model.FormField{
+			{Key: "n", Value: "v", Type: "text"}, {Key: "f", Value: "/tmp/a.png", Type: "file"},
+		}}, 1},
+		{"graphql", insomniaBody{MimeType: "application/graphql", Text: `{"query":"{ me }","variables":{"id":"{{ _.id }}"}}`},
+			&model.RequestBody{Mode: model.BodyModeGraphQL, GraphQL: &model.DTOGraphQLBody{Query: "{ me }", Variables: json.RawMessage(`{"id":"{{id}}"}`)}}, 0},
+		{"graphql without variables", insomniaBody{MimeType: "application/graphql", Text: `{"query":"{ me }","variables":null}`},
+			&model.RequestBody{Mode: model.BodyModeGraphQL, GraphQL: &model.DTOGraphQLBody{Query: "{ me }"}}, 0},
+		{"broken graphql", insomniaBody{MimeType: "application/graphql", Text: "{"}, nil, 1},
+		{"file body", insomniaBody{MimeType: "application/octet-stream"}, nil, 1},
+	}
+	for _, tt := range tests {
+		var warnings importWarnings
+		got := insomniaBodyToNative(tt.body, "w / r", &warnings)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: body = %+v, want %+v", tt.name, got, tt.want)
+		}
+		if len(warnings) != tt.warnings {
+			t.Errorf("%s: warnings = %q, want %d", tt.name, warnings, tt.warnings)
+		}
+	}
+}
+
+func TestInsomniaAuthToNative(t *testing.T) {
+	tests := []struct {
+		name     string
+		auth     map[string]any
+		want     *model.RequestAuth
+		warnings int
+	}{
+		{"missing", nil, nil, 0},
+		{"inherit", map[string]any{"type": "inherit"}, nil, 0},
+		{"none", map[string]any{"type": "none"}, &model.RequestAuth{Type: model.AuthNone}, 0},
+		{"disabled", map[string]any{"type": "bearer", "token": "t", "disabled": true}, &model.RequestAuth{Type: model.AuthNone}, 0},
+		{"basic", map[string]any{"type": "basic", "username": "{{ _.user }}", "password": "p"},
+			&model.RequestAuth{Type: model.AuthBasic, Username: "{{user}}", Password: "p"}, 0},
+		{"bearer", map[string]any{"type": "bearer", "token": "t", "prefix": "bearer"}, &model.RequestAuth{Type: model.AuthBearer, Token: "t"}, 0},
+		{"custom prefix", map[string]any{"type": "bearer", "token": "t", "prefix": "Token"},
+			&model.RequestAuth{Type: model.AuthAPIKey, Key: "Authorization", Value: "Token t", In: "header"}, 0},
+		{"apikey in query", map[string]any{"type": "apikey", "key": "k", "value": "v", "addTo": "queryParams"},
+			&model.RequestAuth{Type: model.AuthAPIKey, Key: "k", Value: "v", In: "query"}, 0},
+		{"oauth2", map[string]any{"type": "oauth2"}, &model.RequestAuth{Type: model.AuthNone}, 1},
+	}
+	for _, tt := range tests {
+		var warnings importWarnings
+		got := insomniaAuthToNative(tt.auth, "w", &warnings)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: auth = %+v, want %+v", tt.name, got, tt.want)
+		}
+		if len(warnings) != tt.warnings {
+			t.Errorf("%s: warnings = %q, want %d", tt.name, warnings, tt.warnings)
+		}
+	}
+}
+
+func TestInsomniaVariables(t *testing.T) {
+	got := insomniaVariables(map[string]any{
+		"base": "https://{{ _.host }}",
+		"api":  map[string]any{"version": 2.0, "key": "k"},
+		"on":   true,
+	})
+	want := []model.Variable{
+		{Key: "api.key", Value: "k"},
+		{Key: "api.version", Value: "2"},
+		{Key: "base", Value: "https://{{host}}"},
+		{Key: "on", Value: "true"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("insomniaVariables = %+v, want %+v", got, want)
+	}
+	if got := insomniaVariables(nil); len(got) != 0 {
+		t.Errorf("insomniaVariables(nil) = %+v", got)
+	}
+}
+
+func TestInsomniaToNative(t *testing.T) {
+	data := `{"_type": "export", "__export_format": 4, "resources": [
+		{"_id": "wrk", "_type": "workspace", "name": "Shop"},
+		{"_id": "env", "_type": "environment", "parentId": "wrk", "data": {"base": "https://shop.test"}},
+		{"_id": "env_stg", "_type": "environment", "parentId": "env", "name": "Staging", "data": {"base": "https://stg.test"}},
+		{"_id": "fld", "_type": "request_group", "parentId": "wrk", "name": "Users", "metaSortKey": 2},
+		{"_id": "req_b", "_type": "request", "parentId": "fld", "name": "Create", "method": "post", "url": "{{ _.base }}/users",
+		 "metaSortKey": 2, "parameters": [{"name": "a", "value": "1"}, {"name": "b", "value": "2", "disabled": true}],
+		 "headers": [{"name": "X-A", "value": "{{ _.a }}"}], "afterResponseScript": "insomnia.test()"},
+		{"_id": "req_a", "_type": "request", "parentId": "fld", "name": "List", "method": "GET", "url": "{{ _.base }}/users?x=1",
+		 "metaSortKey": 1, "parameters": [{"name": "y", "value": "2"}]},
+		{"_id": "req_root", "_type": "request", "parentId": "wrk", "name": "Health", "method": "GET", "url": "{{ _.base }}/health", "metaSortKey": 1},
+		{"_id": "ws", "_type": "websocket_request", "parentId": "wrk", "name": "Live", "metaSortKey": 3}
+	]}`
+	var export insomniaExport
+	if err := json.Unmarshal([]byte(data), &export); err != nil {
+		t.Fatal(err)
+	}
+	var warnings importWarnings
+	collections, environments, err := insomniaToNative(&export, &warnings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(collections) != 1 || len(environments) != 1 {
+		t.Fatalf("collections = %d, environments = %d", len(collections), len(environments))
+	}
+	collection := collections[0]
+	if !reflect.DeepEqual(collection.Variables, []model.Variable{{Key: "base", Value: "https://shop.test"}}) {
+		t.Errorf("variables = %+v", collection.Variables)
+	}
+	if environments[0].Name != "Shop - Staging" || environments[0].Variables[0].Value != "https://stg.test" {
+		t.Errorf("environment = %+v", environments[0])
+	}
+
+	var names []string
+	for _, item := range collection.Items {
+		names = append(names, item.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"Health", "Users"}) {
+		t.Errorf("top level items = %q", names)
+	}
+	folder := collection.Items[1]
+	list, create := folder.Items[0], folder.Items[1]
+	if list.Name != "List" || list.Request.URL != "{{base}}/users?x=1&y=2" {
+		t.Errorf("list = %+v", list.Request)
+	}
+	if create.Request.Method != "POST" || create.Request.URL != "{{base}}/users?a=1" ||
+		!reflect.DeepEqual(create.Request.Headers, []model.KeyValue{{Key: "X-A", Value: "{{a}}"}}) {
+		t.Errorf("create = %+v", create.Request)
+	}
+	if create.Scripts == nil || create.Scripts.Test != "insomnia.test()" {
+		t.Errorf("create scripts = %+v", create.Scripts)
+	}
+	// The script and the websocket request are reported.
+	if len(warnings) != 2 {
+		t.Errorf("warnings = %q", warnings)
+	}
+
+	for name, export := range map[string]*insomniaExport{
+		"v3 export":    {Type: "export", ExportFormat: 3},
+		"no workspace": {Type: "export", ExportFormat: 4, Resources: []insomniaResource{{ID: "req", Type: "request"}}},
+	} {
+		if _, _, err := insomniaToNative(export, &warnings); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("%s: err = %v, want ErrInvalidInput", name, err)
+		}
+	}
+}
@@ -0,0 +1,566 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/suar-net/suar-be/internal/config"
+	"github.com/suar-net/suar-be/internal/model"
+	"github.com/suar-net/suar-be/internal/repository"
+)
+
+const (
+	// maxLoadTests bounds the load tests running on one replica. Each user
+	// may only run maxLoadTestsPerUser of them and each host is only load
+	// tested by one at a time, across replicas.
+	maxLoadTests        = 5
+	maxLoadTestsPerUser = 1
+	// loadTestHeartbeatInterval is how often a load test in progress tells
+	// the database it is alive. One without a heartbeat for
+	// loadTestStaleAfter belonged to a replica that is gone and no longer
+	// counts against the limits.
+	loadTestHeartbeatInterval = 5 * time.Second
+	loadTestStaleAfter        = time.Minute
+	// defaultLoadTestDuration applies when neither a duration nor a number
+	// of requests is given.
+	defaultLoadTestDuration  = 10 * time.Second
+	loadTestProgressInterval = time.Second
+	// maxLoadTestRead bounds how much of each response body is read, the
+	// rest is dropped with the connection.
+	maxLoadTestRead = 10 << 20
+)
+
+// loadTestBuckets are the upper bounds, in milliseconds, of the latency
+// histogram buckets.
+var loadTestBuckets = []int{5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+// loadTestService fires a request repeatedly to check how a target holds up.
+// Tests only run against the hosts allowed by the configuration, within its
+// limits. The limits per user and per host are kept in the database so they
+// hold across replicas; the tests of this replica are tracked in memory so
+// they can be canceled on shutdown.
+type loadTestService struct {
+	tests     repository.ILoadTestRepository
+	requests  *RequestService
+	config    config.LoadTestConfig
+	heartbeat time.Duration
+
+	mu      sync.Mutex
+	cancels map[int64]context.CancelCauseFunc
+	closed  bool
+}
+
+func NewLoadTestService(tests repository.ILoadTestRepository, requests *RequestService, cfg config.LoadTestConfig) ILoadTestService {
+	return &loadTestService{
+		tests:     tests,
+		requests:  requests,
+		config:    cfg,
+		heartbeat: loadTestHeartbeatInterval,
+		cancels:   map[int64]context.CancelCauseFunc{},
+	}
+}
+
+// RunLoadTest runs a load test and streams its progress through emit until
+// it is done or ctx is canceled. Errors are only returned before the test
+// starts; failed requests are counted in the stats.
+func (s *loadTestService) RunLoadTest(ctx context.Context, userID int, dto *model.DTOLoadTestRequest, emit func(model.DTOLoadTestEvent) error) error {
+	if len(s.config.AllowedHosts) == 0 {
+		return fmt.Errorf("%w: load testing is not enabled on this server", ErrLoadTestNotAllowed)
+	}
+	// The host is checked before the request is built, which resolves it for
+	// the egress policy.
+	if target, err := url.Parse(dto.Request.URL); err == nil && !s.config.AllowsHost(target.Hostname()) {
+		return fmt.Errorf("%w: %s is not in the list of hosts that may be load tested", ErrLoadTestNotAllowed, target.Hostname())
+	}
+	dto.Request.CaptureRaw = false
+	outbound, err := s.requests.CreateOutboundRequest(&dto.Request)
+	if err != nil {
+		return err
+	}
+	host := strings.ToLower(outbound.URL.Hostname())
+	plan, err := s.loadTestPlan(dto, outbound)
+	if err != nil {
+		return err
+	}
+
+	testCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	id, err := s.reserve(ctx, userID, host, cancel)
+	if err != nil {
+		return err
+	}
+	defer s.release(id)
+	stopHeartbeat := s.keepAlive(testCtx, id)
+	defer stopHeartbeat()
+
+	if err := emit(model.DTOLoadTestEvent{Type: model.LoadTestEventStarted, Plan: plan}); err != nil {
+		return nil
+	}
+	test := newLoadTest(plan, outbound, s.requests.httpClient(outbound))
+	test.run(testCtx, emit)
+	return nil
+}
+
+// loadTestPlan applies the defaults and limits to a load test request.
+func (s *loadTestService) loadTestPlan(dto *model.DTOLoadTestRequest, outbound *OutboundRequest) (*model.LoadTestPlan, error) {
+	mode := dto.Mode
+	if mode == "" {
+		mode = model.LoadTestModeConcurrency
+		if dto.Rate > 0 {
+			mode = model.LoadTestModeRate
+		}
+	}
+
+	concurrency := dto.Concurrency
+	switch mode {
+	case model.LoadTestModeRate:
+		if dto.Rate <= 0 {
+			return nil, fmt.Errorf("%w: rate mode needs a rate of at least 1 request per second", ErrInvalidInput)
+		}
+		if dto.Rate > s.config.MaxRate {
+			return nil, fmt.Errorf("%w: a rate of %d exceeds the limit of %d requests per second", ErrInvalidInput, dto.Rate, s.config.MaxRate)
+		}
+		// Slots bound the requests waiting for a response at once.
+		if concurrency <= 0 {
+			concurrency = s.config.MaxConcurrency
+		}
+	case model.LoadTestModeConcurrency:
+		if concurrency <= 0 {
+			concurrency = 1
+		}
+	}
+	if concurrency > s.config.MaxConcurrency {
+		return nil, fmt.Errorf("%w: a concurrency of %d exceeds the limit of %d", ErrInvalidInput, concurrency, s.config.MaxConcurrency)
+	}
+
+	duration := time.Duration(dto.DurationMs) * time.Millisecond
+	switch {
+	case duration > s.config.MaxDuration:
+		return nil, fmt.Errorf("%w: a duration of %v exceeds the limit of %v", ErrInvalidInput, duration, s.config.MaxDuration)
+	case duration == 0 && dto.Requests > 0:
+		duration = s.config.MaxDuration
+	case duration == 0:
+		duration = min(defaultLoadTestDuration, s.config.MaxDuration)
+	}
+	requests := dto.Requests
+	if requests > s.config.MaxRequests {
+		return nil, fmt.Errorf("%w: %d requests exceed the limit of %d a load test can send", ErrInvalidInput, requests, s.config.MaxRequests)
+	}
+	if requests == 0 {
+		requests = s.config.MaxRequests
+	}
+
+	return &model.LoadTestPlan{
+		Method:      outbound.Method,
+		URL:         outbound.URL.String(),
+		Mode:        mode,
+		Rate:        dto.Rate,
+		Concurrency: concurrency,
+		DurationMs:  duration.Milliseconds(),
+		Requests:    requests,
+		TimeoutMs:   outbound.Timeout.Milliseconds(),
+	}, nil
+}
+
+// reserve counts a new load test against the limits, release gives its
+// slots back once it is over.
+func (s *loadTestService) reserve(ctx context.Context, userID int, host string, cancel context.CancelCauseFunc) (int64, error) {
+	if err := s.checkCapacity(); err != nil {
+		return 0, err
+	}
+	test := &model.LoadTest{UserID: userID, Host: host}
+	created, hostBusy, err := s.tests.Create(ctx, test, maxLoadTestsPerUser, time.Now().Add(-loadTestStaleAfter))
+	if err != nil {
+		return 0, fmt.Errorf("failed to reserve load test: %w", err)
+	}
+	if hostBusy {
+		return 0, fmt.Errorf("%w: %s is already being load tested", ErrLoadTestLimitReached, host)
+	}
+	if !created {
+		return 0, ErrLoadTestLimitReached
+	}
+
+	// Another test may have taken the last slot of this replica meanwhile.
+	s.mu.Lock()
+	err = s.checkCapacityLocked()
+	if err == nil {
+		s.cancels[test.ID] = cancel
+	}
+	s.mu.Unlock()
+	if err != nil {
+		s.deleteTest(test.ID)
+		return 0, err
+	}
+	return test.ID, nil
+}
+
+func (s *loadTestService) checkCapacity() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.checkCapacityLocked()
+}
+
+func (s *loadTestService) checkCapacityLocked() error {
+	if s.closed {
+		return errServerShutdown
+	}
+	if len(s.cancels) >= maxLoadTests {
+		return ErrLoadTestLimitReached
+	}
+	return nil
+}
+
+func (s *loadTestService) release(id int64) {
+	s.mu.Lock()
+	delete(s.cancels, id)
+	s.mu.Unlock()
+	s.deleteTest(id)
+}
+
+// deleteTest removes a load test from the database, even when the client
+// that ran it is gone.
+func (s *loadTestService) deleteTest(id int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.tests.Delete(ctx, id); err != nil {
+		log.Printf("failed to delete load test %d: %v", id, err)
+	}
+}
+
+// keepAlive sends the heartbeat of a load test until the returned function
+// is called.
+func (s *loadTestService) keepAlive(ctx context.Context, id int64) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(s.heartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			beatCtx, beatCancel := context.WithTimeout(ctx, 5*time.Second)
+			err := s.tests.Heartbeat(beatCtx, id)
+			beatCancel()
+			if err != nil && ctx.Err() == nil {
+				log.Printf("failed to record heartbeat of load test %d: %v", id, err)
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// Shutdown cancels the load tests in progress, their streams end with the
+// stats so far. No new load tests are accepted afterwards.
+func (s *loadTestService) Shutdown() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for _, cancel := range s.cancels {
+		cancel(errServerShutdown)
+	}
+}
+
+// loadTest is one load test in progress and the stats of its requests.
+type loadTest struct {
+	plan     *model.LoadTestPlan
+	outbound *OutboundRequest
+	client   *http.Client
+	started  time.Time
+
+	mu        sync.Mutex
+	sent      int
+	completed int
+	failed    int
+	dropped   int
+	bytes     int64
+	latencies []float64
+	buckets   []int
+	statuses  map[int]int
+	failures  []*model.LoadTestFailure
+}
+
+// newLoadTest prepares a load test. Its client pools enough connections for
+// the requests in flight and does not follow redirects, which could lead
+// away from the allowed host.
+func newLoadTest(plan *model.LoadTestPlan, outbound *OutboundRequest, base *http.Client) *loadTest {
+	transport := base.Transport.(*http.Transport).Clone()
+	transport.MaxIdleConns = plan.Concurrency
+	transport.MaxIdleConnsPerHost = plan.Concurrency
+
+	return &loadTest{
+		plan:     plan,
+		outbound: outbound,
+		client: &http.Client{
+			Transport: transport,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		buckets:  make([]int, len(loadTestBuckets)+1),
+		statuses: map[int]int{},
+	}
+}
+
+// run sends the requests and emits progress until the test is done. Sending
+// stops once the duration is over or every request is sent; the requests in
+// flight then get to finish within their timeout.
+func (t *loadTest) run(ctx context.Context, emit func(model.DTOLoadTestEvent) error) {
+	defer t.client.CloseIdleConnections()
+	t.started = time.Now()
+	sendCtx, stop := context.WithTimeout(ctx, time.Duration(t.plan.DurationMs)*time.Millisecond)
+	defer stop()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if t.plan.Mode == model.LoadTestModeRate {
+			t.sendAtRate(ctx, sendCtx)
+		} else {
+			t.sendConcurrently(ctx, sendCtx)
+		}
+	}()
+
+	progress := time.NewTicker(loadTestProgressInterval)
+	defer progress.Stop()
+	for {
+		select {
+		case <-done:
+			reason := model.LoadTestReasonCompleted
+			if errors.Is(context.Cause(ctx), errServerShutdown) {
+				reason = model.LoadTestReasonShutdown
+			} else if ctx.Err() != nil {
+				reason = model.LoadTestReasonCanceled
+			}
+			emit(model.DTOLoadTestEvent{Type: model.LoadTestEventDone, Stats: t.snapshot(), Reason: reason})
+			return
+		case <-progress.C:
+			if err := emit(model.DTOLoadTestEvent{Type: model.LoadTestEventProgress, Stats: t.snapshot()}); err != nil {
+				// Nobody is listening anymore.
+				stop()
+				<-done
+				return
+			}
+		}
+	}
+}
+
+// sendAtRate starts a request every 1/rate seconds. When every slot is
+// still waiting for a response the send is dropped rather than delayed,
+// so a slow target does not lower the rate the others are sent at.
+func (t *loadTest) sendAtRate(ctx, sendCtx context.Context) {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	slots := make(chan struct{}, t.plan.Concurrency)
+	ticker := time.NewTicker(time.Second / time.Duration(t.plan.Rate))
+	defer ticker.Stop()
+
+	for t.claim(sendCtx) {
+		select {
+		case slots <- struct{}{}:
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				t.send(ctx)
+				<-slots
+			}()
+		default:
+			t.mu.Lock()
+			t.sent--
+			t.dropped++
+			t.mu.Unlock()
+		}
+
+		select {
+		case <-sendCtx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sendConcurrently keeps the given number of requests in flight, each
+// worker sending its next request as soon as the previous one is done.
+func (t *loadTest) sendConcurrently(ctx, sendCtx context.Context) {
+	var wg sync.WaitGroup
+	for range t.plan.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t.claim(sendCtx) {
+				t.send(ctx)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// claim counts a request about to be sent, it reports false once sending
+// should stop.
+func (t *loadTest) claim(sendCtx context.Context) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if sendCtx.Err() != nil || t.sent >= t.plan.Requests {
+		return false
+	}
+	t.sent++
+	return true
+}
+
+// send sends the request once and records how it went. The latency covers
+// the whole exchange, up to the end of the response body.
+func (t *loadTest) send(ctx context.Context) {
+	reqCtx, cancel := context.WithTimeout(ctx, t.outbound.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	req, err := http.NewRequestWithContext(reqCtx, t.outbound.Method, t.outbound.URL.String(), bytes.NewReader(t.outbound.Body))
+	if err != nil {
+		t.record(0, 0, 0, err)
+		return
+	}
+	req.Header = t.outbound.Headers.Clone()
+	if req.Header.Get("Accept-Encoding") == "" && req.Header.Get("Range") == "" && req.Method != http.MethodHead {
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		t.record(0, 0, 0, err)
+		return
+	}
+	n, err := io.Copy(io.Discard, io.LimitReader(resp.Body, maxLoadTestRead))
+	resp.Body.Close()
+	t.record(resp.StatusCode, n, time.Since(start), err)
+}
+
+func (t *loadTest) record(status int, n int64, latency time.Duration, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.bytes += n
+	if err != nil {
+		t.failed++
+		kind := loadTestErrorKind(err)
+		for _, failure := range t.failures {
+			if failure.Kind == kind {
+				failure.Count++
+				return
+			}
+		}
+		t.failures = append(t.failures, &model.LoadTestFailure{Kind: kind, Count: 1, Message: err.Error()})
+		return
+	}
+
+	t.completed++
+	t.statuses[status]++
+	ms := float64(latency.Microseconds()) / 1000
+	t.latencies = append(t.latencies, ms)
+	bucket := sort.SearchInts(loadTestBuckets, int(math.Ceil(ms)))
+	t.buckets[bucket]++
+}
+
+// loadTestErrorKind groups the errors of requests that got no response.
+func loadTestErrorKind(err error) string {
+	var dnsErr *net.DNSError
+	var certErr *tls.CertificateVerificationError
+	var recordErr tls.RecordHeaderError
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()):
+		return "timeout"
+	case errors.As(err, &dnsErr):
+		return "dns"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "connection_refused"
+	case errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+		return "connection_reset"
+	case errors.As(err, &certErr) || errors.As(err, &recordErr):
+		return "tls"
+	}
+	return "other"
+}
+
+// snapshot sums up the requests so far.
+func (t *loadTest) snapshot() *model.LoadTestStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	elapsed := time.Since(t.started)
+	stats := &model.LoadTestStats{
+		ElapsedMs:     elapsed.Milliseconds(),
+		Sent:          t.sent,
+		Completed:     t.completed,
+		Failed:        t.failed,
+		Dropped:       t.dropped,
+		InFlight:      t.sent - t.completed - t.failed,
+		BytesReceived: t.bytes,
+		Latency:       latencyStats(t.latencies),
+		Statuses:      make(map[int]int, len(t.statuses)),
+		Errors:        make([]model.LoadTestFailure, 0, len(t.failures)),
+	}
+	if seconds := elapsed.Seconds(); seconds > 0 {
+		stats.Throughput = math.Round(float64(t.completed)/seconds*100) / 100
+	}
+	for i, count := range t.buckets {
+		bucket := model.LatencyBucket{Count: count}
+		if i < len(loadTestBuckets) {
+			bucket.UpToMs = &loadTestBuckets[i]
+		}
+		stats.Histogram = append(stats.Histogram, bucket)
+	}
+	for status, count := range t.statuses {
+		stats.Statuses[status] = count
+	}
+	for _, failure := range t.failures {
+		stats.Errors = append(stats.Errors, *failure)
+	}
+	return stats
+}
+
+// latencyStats computes the latency percentiles by nearest rank.
+func latencyStats(latencies []float64) model.LatencyStats {
+	if len(latencies) == 0 {
+		return model.LatencyStats{}
+	}
+	sorted := append([]float64(nil), latencies...)
+	sort.Float64s(sorted)
+	percentile := func(p float64) float64 {
+		return sorted[max(int(math.Ceil(p/100*float64(len(sorted))))-1, 0)]
+	}
+
+	var sum float64
+	for _, latency := range sorted {
+		sum += latency
+	}
+	return model.LatencyStats{
+		Min:  sorted[0],
+		Mean: math.Round(sum/float64(len(sorted))*1000) / 1000,
+		P50:  percentile(50),
+		P90:  percentile(90),
+		P99:  percentile(99),
+		Max:  sorted[len(sorted)-1],
+	}
+}
@@ -0,0 +1,267 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/suar-net/suar-be/internal/config"
+	"github.com/suar-net/suar-be/internal/model"
+)
+
+// fakeLoadTestRepo keeps load tests in memory with the limits the database
+// applies.
+type fakeLoadTestRepo struct {
+	mu     sync.Mutex
+	nextID int64
+	tests  map[int64]*model.LoadTest
+	beats  int
+}
+
+func newFakeLoadTestRepo() *fakeLoadTestRepo {
+	return &fakeLoadTestRepo{tests: map[int64]*model.LoadTest{}}
+}
+
+func (r *fakeLoadTestRepo) Create(ctx context.Context, test *model.LoadTest, userLimit int, staleBefore time.Time) (bool, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	running := 0
+	for id, other := range r.tests {
+		if other.HeartbeatAt.Before(staleBefore) {
+			delete(r.tests, id)
+			continue
+		}
+		if other.Host == test.Host {
+			return false, true, nil
+		}
+		if other.UserID == test.UserID {
+			running++
+		}
+	}
+	if running >= userLimit {
+		return false, false, nil
+	}
+	r.nextID++
+	stored := *test
+	stored.ID, stored.StartedAt, stored.HeartbeatAt = r.nextID, time.Now(), time.Now()
+	r.tests[stored.ID] = &stored
+	*test = stored
+	return true, false, nil
+}
+
+func (r *fakeLoadTestRepo) Heartbeat(ctx context.Context, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.beats++
+	if test, ok := r.tests[id]; ok {
+		test.HeartbeatAt = time.Now()
+	}
+	return nil
+}
+
+func (r *fakeLoadTestRepo) Delete(ctx context.Context, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.tests, id)
+	return nil
+}
+
+func TestLatencyStats(t *testing.T) {
+	hundred := make([]float64, 100)
+	for i := range hundred {
+		hundred[i] = float64(100 - i)
+	}
+	tests := []struct {
+		name      string
+		latencies []float64
+		want      model.LatencyStats
+	}{
+		{"none", nil, model.LatencyStats{}},
+		{"one", []float64{12.5}, model.LatencyStats{Min: 12.5, Mean: 12.5, P50: 12.5, P90: 12.5, P99: 12.5, Max: 12.5}},
+		{"nearest rank", []float64{4, 1, 3, 2}, model.LatencyStats{Min: 1, Mean: 2.5, P50: 2, P90: 4, P99: 4, Max: 4}},
+		{"hundred", hundred, model.LatencyStats{Min: 1, Mean: 50.5, P50: 50, P90: 90, P99: 99, Max: 100}},
+		{"rounded mean", []float64{1, 1, 2}, model.LatencyStats{Min: 1, Mean: 1.333, P50: 1, P90: 2, P99: 2, Max: 2}},
+	}
+	for _, tt := range tests {
+		if got := latencyStats(tt.latencies); got != tt.want {
+			t.Errorf("%s: latencyStats = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+	if hundred[0] != 100 {
+		t.Error("the latencies were sorted in place")
+	}
+}
+
+func TestLoadTestPlan(t *testing.T) {
+	s := &loadTestService{config: config.LoadTestConfig{MaxRate: 50, MaxConcurrency: 20, MaxDuration: time.Minute, MaxRequests: 1000}}
+	outbound := &OutboundRequest{Method: "GET", URL: &url.URL{Scheme: "https", Host: "api.test"}, Timeout: 5 * time.Second}
+	tests := []struct {
+		name    string
+		dto     model.DTOLoadTestRequest
+		want    model.LoadTestPlan
+		wantErr string
+	}{
+		{name: "defaults", want: model.LoadTestPlan{Mode: model.LoadTestModeConcurrency, Concurrency: 1, DurationMs: 10000, Requests: 1000}},
+		{name: "rate", dto: model.DTOLoadTestRequest{Rate: 10},
+			want: model.LoadTestPlan{Mode: model.LoadTestModeRate, Rate: 10, Concurrency: 20, DurationMs: 10000, Requests: 1000}},
+		{name: "requests run up to the longest duration", dto: model.DTOLoadTestRequest{Requests: 50, Concurrency: 5},
+			want: model.LoadTestPlan{Mode: model.LoadTestModeConcurrency, Concurrency: 5, DurationMs: 60000, Requests: 50}},
+		{name: "rate mode without a rate", dto: model.DTOLoadTestRequest{Mode: model.LoadTestModeRate}, wantErr: "needs a rate"},
+		{name: "rate above the limit", dto: model.DTOLoadTestRequest{Rate: 51}, wantErr: "exceeds the limit of 50 requests per second"},
+		{name: "concurrency above the limit", dto: model.DTOLoadTestRequest{Concurrency: 21}, wantErr: "exceeds the limit of 20"},
+		{name: "duration above the limit", dto: model.DTOLoadTestRequest{DurationMs: 61000}, wantErr: "exceeds the limit of 1m0s"},
+		{name: "requests above the limit", dto: model.DTOLoadTestRequest{Requests: 1001}, wantErr: "exceed the limit of 1000"},
+	}
+	for _, tt := range tests {
+		plan, err := s.loadTestPlan(&tt.dto, outbound)
+		if tt.wantErr != "" {
+			if !errors.Is(err, ErrInvalidInput) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: err = %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		tt.want.Method, tt.want.URL, tt.want.TimeoutMs = "GET", "https://api.test", 5000
+		if *plan != tt.want {
+			t.Errorf("%s: plan = %+v, want %+v", tt.name, plan, tt.want)
+		}
+	}
+}
+
+func TestLoadTestLimits(t *testing.T) {
+	tests := newFakeLoadTestRepo()
+	s := NewLoadTestService(tests, nil, config.LoadTestConfig{}).(*loadTestService)
+	reserve := func(userID int, host string) (int64, error) {
+		return s.reserve(context.Background(), userID, host, func(error) {})
+	}
+
+	first, err := reserve(1, "api.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reserve(1, "other.test"); !errors.Is(err, ErrLoadTestLimitReached) {
+		t.Errorf("second test of a user: err = %v", err)
+	}
+	if _, err := reserve(2, "api.test"); !errors.Is(err, ErrLoadTestLimitReached) || !strings.Contains(err.Error(), "api.test is already being load tested") {
+		t.Errorf("host in use: err = %v", err)
+	}
+	s.release(first)
+	if len(tests.tests) != 0 || len(s.cancels) != 0 {
+		t.Errorf("released test kept: %v, %v", tests.tests, s.cancels)
+	}
+
+	// A test left behind by a replica that died stops counting once its
+	// heartbeat is old.
+	stale, err := reserve(1, "api.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	delete(s.cancels, stale)
+	tests.tests[stale].HeartbeatAt = time.Now().Add(-2 * loadTestStaleAfter)
+	if _, err := reserve(2, "api.test"); err != nil {
+		t.Errorf("stale test still counted: %v", err)
+	}
+
+	// This replica runs at most maxLoadTests, whatever the database allows.
+	for userID := 10; len(s.cancels) < maxLoadTests; userID++ {
+		if _, err := reserve(userID, fmt.Sprintf("host%d.test", userID)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stored := len(tests.tests)
+	if _, err := reserve(99, "free.test"); !errors.Is(err, ErrLoadTestLimitReached) || len(tests.tests) != stored {
+		t.Errorf("replica full: err = %v, %d tests stored, want %d", err, len(tests.tests), stored)
+	}
+
+	var canceled []error
+	for id := range s.cancels {
+		s.cancels[id] = func(cause error) { canceled = append(canceled, cause) }
+	}
+	s.Shutdown()
+	if len(canceled) != maxLoadTests || !errors.Is(canceled[0], errServerShutdown) {
+		t.Errorf("canceled = %v", canceled)
+	}
+	if _, err := reserve(100, "late.test"); !errors.Is(err, errServerShutdown) {
+		t.Errorf("after shutdown: err = %v", err)
+	}
+}
+
+func TestLoadTestHeartbeat(t *testing.T) {
+	tests := newFakeLoadTestRepo()
+	s := NewLoadTestService(tests, nil, config.LoadTestConfig{}).(*loadTestService)
+	s.heartbeat = time.Millisecond
+	id, err := s.reserve(context.Background(), 1, "api.test", func(error) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests.tests[id].HeartbeatAt = time.Time{}
+
+	stop := s.keepAlive(context.Background(), id)
+	deadline := time.Now().Add(time.Second)
+	for {
+		tests.mu.Lock()
+		beat := tests.tests[id].HeartbeatAt
+		tests.mu.Unlock()
+		if !beat.IsZero() {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no heartbeat was recorded")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	stop()
+	tests.mu.Lock()
+	beats := tests.beats
+	tests.mu.Unlock()
+	time.Sleep(10 * time.Millisecond)
+	tests.mu.Lock()
+	defer tests.mu.Unlock()
+	if tests.beats != beats {
+		t.Error("heartbeats went on after the test was over")
+	}
+}
+
+func TestRunLoadTestAllowedHosts(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		url     string
+		want    string
+	}{
+		{"disabled", nil, "https://api.test/a", "load testing is not enabled on this server"},
+		{"other host", []string{"api.test"}, "https://evil.test/a", "evil.test is not in the list of hosts"},
+		{"subdomain without wildcard", []string{"api.test"}, "https://v1.api.test/a", "v1.api.test is not in the list of hosts"},
+		{"wildcard does not allow the domain itself", []string{"*.api.test"}, "https://api.test/a", "api.test is not in the list of hosts"},
+		{"suffix is not a subdomain", []string{"*.api.test"}, "https://evilapi.test/a", "evilapi.test is not in the list of hosts"},
+	}
+	for _, tt := range tests {
+		repo := newFakeLoadTestRepo()
+		s := NewLoadTestService(repo, nil, config.LoadTestConfig{AllowedHosts: tt.allowed})
+		dto := &model.DTOLoadTestRequest{Request: model.DTORequest{Method: "GET", URL: tt.url}}
+		err := s.RunLoadTest(context.Background(), 1, dto, func(model.DTOLoadTestEvent) error {
+			t.Errorf("%s: a refused test emitted an event", tt.name)
+			return nil
+		})
+		if !errors.Is(err, ErrLoadTestNotAllowed) || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.want)
+		}
+		if len(repo.tests) != 0 {
+			t.Errorf("%s: a refused test was stored", tt.name)
+		}
+	}
+
+	allowed := config.LoadTestConfig{AllowedHosts: []string{"api.test", "*.staging.test"}}
+	for host, want := range map[string]bool{"api.test": true, "API.test.": true, "v2.staging.test": true, "staging.test": false, "api.test.evil": false} {
+		if got := allowed.AllowsHost(host); got != want {
+			t.Errorf("AllowsHost(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// lruCache keeps values until they expire, evicting the least recently used
+// entry once it holds max entries. It is safe for concurrent use.
+type lruCache[K comparable, V any] struct {
+	mu      sync.Mutex
+	max     int
+	order   *list.List
+	entries map[K]*list.Element
+}
+
+type lruEntry[K comparable, V any] struct {
+	key       K
+	value     V
+	expiresAt time.Time
+}
+
+func newLRUCache[K comparable, V any](max int) *lruCache[K, V] {
+	return &lruCache[K, V]{
+		max:     max,
+		order:   list.New(),
+		entries: make(map[K]*list.Element),
+	}
+}
+
+// get returns the value stored for key, unless it expired.
+func (c *lruCache[K, V]) get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero V
+	element, ok := c.entries[key]
+	if !ok {
+		return zero, false
+	}
+	entry := element.Value.(*lruEntry[K, V])
+	if time.Now().After(entry.expiresAt) {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		return zero, false
+	}
+	c.order.MoveToFront(element)
+	return entry.value, true
+}
+
+// put stores value for key until expiresAt, replacing the previous value.
+func (c *lruCache[K, V]) put(key K, value V, expiresAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &lruEntry[K, V]{key: key, value: value, expiresAt: expiresAt}
+	if element, ok := c.entries[key]; ok {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.max {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry[K, V]).key)
+	}
+}
+
+func (c *lruCache[K, V]) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
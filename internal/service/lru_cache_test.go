@@ -0,0 +1,38 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRUCache(t *testing.T) {
+	later := time.Now().Add(time.Hour)
+	c := newLRUCache[string, int](2)
+	c.put("a", 1, later)
+	c.put("b", 2, later)
+	if value, ok := c.get("a"); !ok || value != 1 {
+		t.Errorf("get(a) = %d, %v", value, ok)
+	}
+
+	// b is now the least recently used entry.
+	c.put("c", 3, later)
+	if _, ok := c.get("b"); ok {
+		t.Error("the least recently used entry was not evicted")
+	}
+	if _, ok := c.get("a"); !ok {
+		t.Error("a recently used entry was evicted")
+	}
+	if c.len() != 2 || len(c.entries) != 2 {
+		t.Errorf("cache holds %d entries, want 2", c.len())
+	}
+
+	c.put("a", 4, later)
+	if value, _ := c.get("a"); value != 4 || c.len() != 2 {
+		t.Errorf("replaced value = %d with %d entries", value, c.len())
+	}
+
+	c.put("a", 5, time.Now().Add(-time.Second))
+	if _, ok := c.get("a"); ok || c.len() != 1 {
+		t.Error("an expired entry was returned or kept")
+	}
+}
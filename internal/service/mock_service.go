@@ -0,0 +1,510 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/suar-net/suar-be/internal/model"
+	"github.com/suar-net/suar-be/internal/repository"
+)
+
+const (
+	maxMocksPerUser = 20
+	maxMockHits     = 200
+	// maxMockHitBody is how much of a request body a hit keeps.
+	maxMockHitBody = 64 << 10
+	// mockCollectionTTL is how long a mock server answers from the copy of
+	// its collection it loaded, so edits show up within a few seconds.
+	mockCollectionTTL        = 5 * time.Second
+	maxCachedMockCollections = 256
+)
+
+// Headers a client sends to pick an example by name or by status, as
+// Postman mock servers accept them.
+const (
+	mockResponseNameHeader = "X-Mock-Response-Name"
+	mockResponseCodeHeader = "X-Mock-Response-Code"
+)
+
+// mockRedactedHeaders are logged without their value, clients often send
+// real credentials to a mock.
+var mockRedactedHeaders = map[string]bool{
+	"Authorization":       true,
+	"Cookie":              true,
+	"Proxy-Authorization": true,
+}
+
+// mockSkippedHeaders are example headers a mock does not send: they describe
+// how the example was transferred, not what it is.
+var mockSkippedHeaders = map[string]bool{
+	"Connection":        true,
+	"Content-Encoding":  true,
+	"Content-Length":    true,
+	"Keep-Alive":        true,
+	"Trailer":           true,
+	"Transfer-Encoding": true,
+	"Upgrade":           true,
+}
+
+type mockService struct {
+	mocks       repository.IMockRepository
+	collections ICollectionService
+	// cache keeps the collection a mock server serves, by mock ID, so
+	// anonymous traffic to a mock does not load the whole collection tree on
+	// every request. The cached collections are shared and must not be
+	// modified.
+	cache *lruCache[string, *model.Collection]
+}
+
+func NewMockService(m repository.IMockRepository, collections ICollectionService) IMockService {
+	return &mockService{
+		mocks:       m,
+		collections: collections,
+		cache:       newLRUCache[string, *model.Collection](maxCachedMockCollections),
+	}
+}
+
+// mockCollection loads the collection a mock server serves, from the cache
+// when it holds a fresh copy of the collection the mock now serves.
+func (s *mockService) mockCollection(ctx context.Context, mock *model.MockServer) (*model.Collection, error) {
+	if collection, ok := s.cache.get(mock.ID); ok && collection.ID == mock.CollectionID {
+		return collection, nil
+	}
+	collection, err := s.collections.GetCollection(ctx, mock.CollectionID, mock.UserID)
+	if err != nil {
+		return nil, err
+	}
+	s.cache.put(mock.ID, collection, time.Now().Add(mockCollectionTTL))
+	return collection, nil
+}
+
+func withMockPath(mock *model.MockServer) *model.MockServer {
+	mock.Path = "/mock/" + mock.ID
+	return mock
+}
+
+func (s *mockService) CreateMock(ctx context.Context, userID int, dto *model.DTOMockServerRequest) (*model.MockServer, error) {
+	count, err := s.mocks.CountByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count mock servers: %w", err)
+	}
+	if count >= maxMocksPerUser {
+		return nil, ErrMockLimitReached
+	}
+	if _, err := s.collections.GetCollection(ctx, dto.CollectionID, userID); err != nil {
+		return nil, err
+	}
+
+	idBytes := make([]byte, 16)
+	if _, err := rand.Read(idBytes); err != nil {
+		return nil, fmt.Errorf("failed to generate mock server id: %w", err)
+	}
+	mock := &model.MockServer{ID: hex.EncodeToString(idBytes), UserID: userID}
+	applyMock(mock, dto)
+	if err := s.mocks.Create(ctx, mock); err != nil {
+		return nil, fmt.Errorf("failed to save mock server: %w", err)
+	}
+	return withMockPath(mock), nil
+}
+
+func applyMock(mock *model.MockServer, dto *model.DTOMockServerRequest) {
+	mock.CollectionID = dto.CollectionID
+	mock.Name = dto.Name
+	mock.DelayMs = dto.DelayMs
+	mock.Enabled = dto.Enabled == nil || *dto.Enabled
+}
+
+func (s *mockService) ListMocks(ctx context.Context, userID int) ([]*model.MockServer, error) {
+	mocks, err := s.mocks.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list mock servers: %w", err)
+	}
+	if mocks == nil {
+		mocks = []*model.MockServer{}
+	}
+	for _, mock := range mocks {
+		withMockPath(mock)
+	}
+	return mocks, nil
+}
+
+func (s *mockService) GetMock(ctx context.Context, id string, userID int) (*model.MockServer, error) {
+	mock, err := s.mocks.GetByID(ctx, id, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mock server: %w", err)
+	}
+	if mock == nil {
+		return nil, ErrMockNotFound
+	}
+	return withMockPath(mock), nil
+}
+
+func (s *mockService) UpdateMock(ctx context.Context, id string, userID int, dto *model.DTOMockServerRequest) (*model.MockServer, error) {
+	mock, err := s.GetMock(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.collections.GetCollection(ctx, dto.CollectionID, userID); err != nil {
+		return nil, err
+	}
+
+	applyMock(mock, dto)
+	found, err := s.mocks.Update(ctx, mock)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update mock server: %w", err)
+	}
+	if !found {
+		return nil, ErrMockNotFound
+	}
+	return mock, nil
+}
+
+func (s *mockService) DeleteMock(ctx context.Context, id string, userID int) error {
+	found, err := s.mocks.Delete(ctx, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete mock server: %w", err)
+	}
+	if !found {
+		return ErrMockNotFound
+	}
+	return nil
+}
+
+func (s *mockService) ListHits(ctx context.Context, id string, userID int, limit int) ([]*model.MockHit, error) {
+	if _, err := s.GetMock(ctx, id, userID); err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > maxMockHits {
+		limit = maxMockHits
+	}
+	hits, err := s.mocks.GetHits(ctx, id, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list mock hits: %w", err)
+	}
+	if hits == nil {
+		hits = []*model.MockHit{}
+	}
+	return hits, nil
+}
+
+// ServeMock answers a request to a mock server with the example of the saved
+// request it matches, after the configured delay, and logs the hit. A
+// request no example matches is answered with 404 and logged as well. The
+// collection may be up to mockCollectionTTL old.
+func (s *mockService) ServeMock(ctx context.Context, id string, req *model.DTOMockRequest) (*model.DTOMockResponse, error) {
+	mock, err := s.mocks.GetPublic(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mock server: %w", err)
+	}
+	if mock == nil || !mock.Enabled {
+		return nil, ErrMockNotFound
+	}
+	collection, err := s.mockCollection(ctx, mock)
+	if err != nil {
+		return nil, err
+	}
+
+	started := time.Now()
+	hit := &model.MockHit{
+		MockID:  mock.ID,
+		Method:  req.Method,
+		Path:    req.Path,
+		Query:   req.RawQuery,
+		Headers: mockHitHeaders(req.Headers),
+		Body:    mockHitBody(req.Body),
+	}
+
+	var response *model.DTOMockResponse
+	item, example, reason := matchMockExample(collection, req)
+	if example == nil {
+		response = mockErrorResponse(http.StatusNotFound, reason)
+	} else {
+		hit.ItemID, hit.Example = &item.ID, example.Name
+		response = mockExampleResponse(example)
+
+		delay := time.Duration(mock.DelayMs) * time.Millisecond
+		if example.DelayMs != nil {
+			delay = time.Duration(*example.DelayMs) * time.Millisecond
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+	}
+
+	response.Headers = publicResponseHeaders(response.Headers)
+	hit.Status = response.Status
+	hit.DurationMs = time.Since(started).Milliseconds()
+	saveCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.mocks.CreateHit(saveCtx, hit); err != nil {
+		log.Printf("failed to log hit of mock server %s: %v", mock.ID, err)
+	}
+	return response, nil
+}
+
+// matchMockExample finds the saved request a mock request is for and picks
+// one of its examples. Among the requests whose method and path match, the
+// one with the most literal path segments wins, so /users/me is preferred
+// over /users/:id. When no example is picked it tells why.
+func matchMockExample(collection *model.Collection, req *model.DTOMockRequest) (*model.CollectionItem, *model.ResponseExample, string) {
+	segments := mockPathSegments(req.Path)
+	var best *model.CollectionItem
+	bestScore := -1
+	var walk func(items []*model.CollectionItem)
+	walk = func(items []*model.CollectionItem) {
+		for _, item := range items {
+			if item.Type == model.CollectionItemFolder {
+				walk(item.Items)
+				continue
+			}
+			if item.Request == nil || len(item.Examples) == 0 {
+				continue
+			}
+			method := item.Request.Method
+			if method == "" {
+				method = http.MethodGet
+			}
+			if !strings.EqualFold(method, req.Method) {
+				continue
+			}
+			template := resolveCollectionVariables(item.Request.URL, collection.Variables)
+			if score, ok := matchMockPath(mockPathSegments(mockURLPath(template)), segments); ok && score > bestScore {
+				best, bestScore = item, score
+			}
+		}
+	}
+	walk(collection.Items)
+	if best == nil {
+		return nil, nil, fmt.Sprintf("no saved example matches %s %s", req.Method, req.Path)
+	}
+
+	headers := http.Header(req.Headers)
+	if name := headers.Get(mockResponseNameHeader); name != "" {
+		for i := range best.Examples {
+			if strings.EqualFold(best.Examples[i].Name, name) {
+				return best, &best.Examples[i], ""
+			}
+		}
+		return best, nil, fmt.Sprintf("%q has no example named %q", best.Name, name)
+	}
+	if code := headers.Get(mockResponseCodeHeader); code != "" {
+		status, _ := strconv.Atoi(code)
+		for i := range best.Examples {
+			if mockExampleStatus(&best.Examples[i]) == status {
+				return best, &best.Examples[i], ""
+			}
+		}
+		return best, nil, fmt.Sprintf("%q has no example with status %s", best.Name, code)
+	}
+
+	// An example whose rules all match beats a default one; among those the
+	// one with the most rules is the most specific.
+	var picked *model.ResponseExample
+	pickedRules := -1
+	for i := range best.Examples {
+		example := &best.Examples[i]
+		rules, ok := matchExampleRules(example.Match, req)
+		if ok && rules > pickedRules {
+			picked, pickedRules = example, rules
+		}
+	}
+	if picked == nil {
+		return best, nil, fmt.Sprintf("no example of %q matches the query and headers of the request", best.Name)
+	}
+	return best, picked, ""
+}
+
+// resolveCollectionVariables replaces the {{name}} placeholders that are
+// collection variables, so a base URL variable that has a path is matched
+// with it. Other placeholders are left to match as path parameters.
+func resolveCollectionVariables(template string, variables []model.Variable) string {
+	for depth := 0; depth < maxVariableDepth && strings.Contains(template, "{{"); depth++ {
+		changed := false
+		template = variablePattern.ReplaceAllStringFunc(template, func(match string) string {
+			if i := findVariable(variables, strings.TrimSpace(match[2:len(match)-2])); i >= 0 && !variables[i].Disabled {
+				changed = true
+				return variables[i].Value
+			}
+			return match
+		})
+		if !changed {
+			break
+		}
+	}
+	return template
+}
+
+// mockURLPath returns the path of a saved request's URL: the scheme and the
+// host, or the variable standing for them, are dropped with the query.
+func mockURLPath(rawURL string) string {
+	rawURL, _, _ = strings.Cut(rawURL, "#")
+	rawURL, _, _ = strings.Cut(rawURL, "?")
+	if _, rest, ok := strings.Cut(rawURL, "://"); ok {
+		rawURL = rest
+	}
+	if strings.HasPrefix(rawURL, "/") {
+		return rawURL
+	}
+	if i := strings.Index(rawURL, "/"); i >= 0 {
+		return rawURL[i:]
+	}
+	return "/"
+}
+
+func mockPathSegments(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+// matchMockPath matches request path segments against those of a saved
+// request, where :name, {name} and {{name}} segments match any value. It
+// returns how many segments matched literally.
+func matchMockPath(template, segments []string) (int, bool) {
+	if len(template) != len(segments) {
+		return 0, false
+	}
+	score := 0
+	for i, part := range template {
+		isParam := strings.HasPrefix(part, ":") ||
+			(strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}"))
+		if isParam {
+			if segments[i] == "" {
+				return 0, false
+			}
+			continue
+		}
+		if part != segments[i] {
+			return 0, false
+		}
+		score++
+	}
+	return score, true
+}
+
+// matchExampleRules reports whether a request has every query parameter and
+// header of an example's rules, and how many rules there are.
+func matchExampleRules(match *model.ExampleMatch, req *model.DTOMockRequest) (int, bool) {
+	if match == nil {
+		return 0, true
+	}
+	rules := 0
+	for _, rule := range match.Query {
+		if rule.Disabled {
+			continue
+		}
+		rules++
+		values, ok := req.Query[rule.Key]
+		if !ok || (rule.Value != "" && !containsString(values, rule.Value)) {
+			return 0, false
+		}
+	}
+	headers := http.Header(req.Headers)
+	for _, rule := range match.Headers {
+		if rule.Disabled {
+			continue
+		}
+		rules++
+		values := headers.Values(rule.Key)
+		if len(values) == 0 || (rule.Value != "" && !containsString(values, rule.Value)) {
+			return 0, false
+		}
+	}
+	return rules, true
+}
+
+// mockExampleStatus is the status of an example, 200 for examples imported
+// without one.
+func mockExampleStatus(example *model.ResponseExample) int {
+	if example.Status == 0 {
+		return http.StatusOK
+	}
+	return example.Status
+}
+
+// mockExampleResponse is the response a mock answers with for an example. An
+// example saved with a status a mock may not answer with is reported as a
+// server error.
+func mockExampleResponse(example *model.ResponseExample) *model.DTOMockResponse {
+	status := mockExampleStatus(example)
+	if !publicResponseStatus(status) {
+		return mockErrorResponse(http.StatusInternalServerError,
+			fmt.Sprintf("example %q has status %d, a mock server answers with 200 to 599", example.Name, status))
+	}
+	response := &model.DTOMockResponse{Status: status, Body: example.Body}
+	hasContentType := false
+	for _, header := range example.Headers {
+		name := http.CanonicalHeaderKey(header.Key)
+		if header.Disabled || mockSkippedHeaders[name] {
+			continue
+		}
+		hasContentType = hasContentType || name == "Content-Type"
+		response.Headers = append(response.Headers, model.KeyValue{Key: name, Value: header.Value})
+	}
+	if !hasContentType && example.Body != "" {
+		contentType := "text/plain; charset=utf-8"
+		if json.Valid([]byte(example.Body)) {
+			contentType = "application/json"
+		}
+		response.Headers = append(response.Headers, model.KeyValue{Key: "Content-Type", Value: contentType})
+	}
+	return response
+}
+
+func mockErrorResponse(status int, message string) *model.DTOMockResponse {
+	body, _ := json.Marshal(map[string]string{"error": message})
+	return &model.DTOMockResponse{
+		Status:  status,
+		Headers: []model.KeyValue{{Key: "Content-Type", Value: "application/json"}},
+		Body:    string(body),
+	}
+}
+
+// mockHitHeaders lists the headers of a request for its hit, sorted by name,
+// with credentials redacted.
+func mockHitHeaders(headers map[string][]string) []model.KeyValue {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var logged []model.KeyValue
+	for _, name := range names {
+		for _, value := range headers[name] {
+			if mockRedactedHeaders[http.CanonicalHeaderKey(name)] {
+				value = "[redacted]"
+			}
+			logged = append(logged, model.KeyValue{Key: name, Value: value})
+		}
+	}
+	return logged
+}
+
+// mockHitBody is the part of a request body a hit keeps. Binary bodies are
+// only described, the database stores text.
+func mockHitBody(body []byte) string {
+	if !utf8.Valid(body) || strings.ContainsRune(string(body), 0) {
+		return fmt.Sprintf("[binary body of %d bytes]", len(body))
+	}
+	if len(body) > maxMockHitBody {
+		// The limit may cut a character in two, its first bytes are dropped.
+		return strings.ToValidUTF8(string(body[:maxMockHitBody]), "")
+	}
+	return string(body)
+}
@@ -0,0 +1,223 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/suar-net/suar-be/internal/model"
+	"github.com/suar-net/suar-be/internal/repository"
+)
+
+func mockTestCollection() *model.Collection {
+	example := func(name string, status int, match *model.ExampleMatch) model.ResponseExample {
+		return model.ResponseExample{Name: name, Status: status, Body: name, Match: match}
+	}
+	request := func(id int, method, url string, examples ...model.ResponseExample) *model.CollectionItem {
+		return &model.CollectionItem{ID: id, Type: model.CollectionItemRequest, Name: url,
+			Request: &model.CollectionRequest{Method: method, URL: url}, Examples: examples}
+	}
+	return &model.Collection{
+		ID:        1,
+		Variables: []model.Variable{{Key: "baseUrl", Value: "https://api.test/v1"}},
+		Items: []*model.CollectionItem{
+			request(1, "GET", "{{baseUrl}}/users/:id", example("user", 200, nil)),
+			request(2, "GET", "{{baseUrl}}/users/me", example("me", 200, nil)),
+			request(3, "", "https://api.test/v1/health?full=1", example("healthy", 0, nil)),
+			request(4, "POST", "{{baseUrl}}/users"),
+			{ID: 5, Type: model.CollectionItemFolder, Items: []*model.CollectionItem{
+				request(6, "POST", "{{host}}/v1/orders",
+					example("created", 201, nil),
+					example("invalid", 422, &model.ExampleMatch{Query: []model.KeyValue{{Key: "invalid"}}}),
+					example("express", 202, &model.ExampleMatch{
+						Query:   []model.KeyValue{{Key: "speed", Value: "express"}},
+						Headers: []model.KeyValue{{Key: "X-Tenant", Value: "acme"}, {Key: "X-Ignored", Disabled: true}},
+					}),
+				),
+				request(7, "GET", "{{baseUrl}}/orders/{id}",
+					example("found", 200, &model.ExampleMatch{Headers: []model.KeyValue{{Key: "Authorization"}}})),
+			}},
+		},
+	}
+}
+
+func TestMatchMockExample(t *testing.T) {
+	collection := mockTestCollection()
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		query   map[string][]string
+		headers map[string][]string
+		item    int
+		example string
+		reason  string
+	}{
+		{name: "path parameter", method: "GET", path: "/v1/users/42", item: 1, example: "user"},
+		{name: "literal segment wins", method: "GET", path: "/v1/users/me", item: 2, example: "me"},
+		{name: "method ignores case", method: "get", path: "/v1/users/me/", item: 2, example: "me"},
+		{name: "default method and query ignored", method: "GET", path: "/v1/health", item: 3, example: "healthy"},
+		{name: "method must match", method: "DELETE", path: "/v1/users/42", reason: "no saved example matches DELETE /v1/users/42"},
+		{name: "request without examples", method: "POST", path: "/v1/users", reason: "no saved example matches POST /v1/users"},
+		{name: "empty parameter", method: "GET", path: "/v1/users/", reason: "no saved example matches GET /v1/users/"},
+		{name: "variable standing for the host", method: "POST", path: "/v1/orders", item: 6, example: "created"},
+		{name: "query rule", method: "POST", path: "/v1/orders", query: map[string][]string{"invalid": {""}}, item: 6, example: "invalid"},
+		{name: "most rules win", method: "POST", path: "/v1/orders",
+			query: map[string][]string{"speed": {"express"}, "invalid": {"1"}}, headers: map[string][]string{"X-Tenant": {"acme"}},
+			item: 6, example: "express"},
+		{name: "rule value must match", method: "POST", path: "/v1/orders",
+			query: map[string][]string{"speed": {"slow"}}, headers: map[string][]string{"X-Tenant": {"acme"}}, item: 6, example: "created"},
+		{name: "no default example", method: "GET", path: "/v1/orders/7", item: 7,
+			reason: `no example of "{{baseUrl}}/orders/{id}" matches the query and headers of the request`},
+		{name: "header rule", method: "GET", path: "/v1/orders/7", headers: map[string][]string{"Authorization": {"Bearer a"}}, item: 7, example: "found"},
+		{name: "by name", method: "POST", path: "/v1/orders", headers: map[string][]string{"X-Mock-Response-Name": {"INVALID"}}, item: 6, example: "invalid"},
+		{name: "unknown name", method: "POST", path: "/v1/orders", headers: map[string][]string{"X-Mock-Response-Name": {"gone"}}, item: 6,
+			reason: `"{{host}}/v1/orders" has no example named "gone"`},
+		{name: "by status", method: "POST", path: "/v1/orders", headers: map[string][]string{"X-Mock-Response-Code": {"202"}}, item: 6, example: "express"},
+		{name: "default status", method: "GET", path: "/v1/health", headers: map[string][]string{"X-Mock-Response-Code": {"200"}}, item: 3, example: "healthy"},
+		{name: "unknown status", method: "POST", path: "/v1/orders", headers: map[string][]string{"X-Mock-Response-Code": {"500"}}, item: 6,
+			reason: `"{{host}}/v1/orders" has no example with status 500`},
+	}
+	for _, tt := range tests {
+		req := &model.DTOMockRequest{Method: tt.method, Path: tt.path, Query: tt.query, Headers: tt.headers}
+		item, example, reason := matchMockExample(collection, req)
+		var itemID int
+		if item != nil {
+			itemID = item.ID
+		}
+		var exampleName string
+		if example != nil {
+			exampleName = example.Name
+		}
+		if itemID != tt.item || exampleName != tt.example || (tt.reason != "" && reason != tt.reason) {
+			t.Errorf("%s: matched item %d, example %q, reason %q", tt.name, itemID, exampleName, reason)
+		}
+	}
+}
+
+func TestMockExampleResponse(t *testing.T) {
+	tests := []struct {
+		name    string
+		example model.ResponseExample
+		status  int
+		headers []model.KeyValue
+	}{
+		{"json body", model.ResponseExample{Body: `{"a": 1}`}, 200,
+			[]model.KeyValue{{Key: "Content-Type", Value: "application/json"}}},
+		{"text body", model.ResponseExample{Status: 404, Body: "missing"}, 404,
+			[]model.KeyValue{{Key: "Content-Type", Value: "text/plain; charset=utf-8"}}},
+		{"no body", model.ResponseExample{Status: 204}, 204, nil},
+		{"saved headers", model.ResponseExample{Status: 200, Body: "<p>", Headers: []model.KeyValue{
+			{Key: "content-type", Value: "text/html"}, {Key: "Content-Length", Value: "3"}, {Key: "X-Off", Disabled: true}}}, 200,
+			[]model.KeyValue{{Key: "Content-Type", Value: "text/html"}}},
+		{"informational status", model.ResponseExample{Name: "upgrade", Status: 101}, 500,
+			[]model.KeyValue{{Key: "Content-Type", Value: "application/json"}}},
+	}
+	for _, tt := range tests {
+		response := mockExampleResponse(&tt.example)
+		if response.Status != tt.status || !reflect.DeepEqual(response.Headers, tt.headers) {
+			t.Errorf("%s: response = %+v", tt.name, response)
+		}
+	}
+}
+
+func TestPublicResponse(t *testing.T) {
+	statuses := map[int]bool{100: false, 101: false, 199: false, 200: true, 302: true, 599: true, 600: false, 0: false}
+	for status, want := range statuses {
+		if got := publicResponseStatus(status); got != want {
+			t.Errorf("publicResponseStatus(%d) = %v, want %v", status, got, want)
+		}
+	}
+
+	headers := publicResponseHeaders([]model.KeyValue{
+		{Key: "content-type", Value: "text/html"},
+		{Key: "set-cookie", Value: "session=planted; Path=/"},
+		{Key: "Content-Security-Policy", Value: "script-src *"},
+		{Key: "X-Content-Type-Options", Value: "none"},
+		{Key: "Transfer-Encoding", Value: "chunked"},
+		{Key: "Location", Value: "/elsewhere"},
+		{Key: "Access-Control-Allow-Origin", Value: "*"},
+		{Key: "X-Off", Value: "1", Disabled: true},
+	})
+	want := []model.KeyValue{
+		{Key: "Content-Type", Value: "text/html"},
+		{Key: "Location", Value: "/elsewhere"},
+		{Key: "Access-Control-Allow-Origin", Value: "*"},
+		{Key: "Content-Security-Policy", Value: "sandbox"},
+		{Key: "X-Content-Type-Options", Value: "nosniff"},
+	}
+	if !reflect.DeepEqual(headers, want) {
+		t.Errorf("headers = %+v", headers)
+	}
+}
+
+// fakeMockRepo serves one mock server and records its hits.
+type fakeMockRepo struct {
+	repository.IMockRepository
+	mock *model.MockServer
+	mu   sync.Mutex
+	hits []*model.MockHit
+}
+
+func (r *fakeMockRepo) GetPublic(ctx context.Context, id string) (*model.MockServer, error) {
+	if id != r.mock.ID {
+		return nil, nil
+	}
+	mock := *r.mock
+	return &mock, nil
+}
+
+func (r *fakeMockRepo) CreateHit(ctx context.Context, hit *model.MockHit) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hits = append(r.hits, hit)
+	return nil
+}
+
+// countingCollectionService counts how often a collection is loaded.
+type countingCollectionService struct {
+	fakeCollectionService
+	mu    sync.Mutex
+	loads int
+}
+
+func (c *countingCollectionService) GetCollection(ctx context.Context, id int, userID int) (*model.Collection, error) {
+	c.mu.Lock()
+	c.loads++
+	c.mu.Unlock()
+	return c.fakeCollectionService.GetCollection(ctx, id, userID)
+}
+
+func TestServeMock(t *testing.T) {
+	mocks := &fakeMockRepo{mock: &model.MockServer{ID: "m1", UserID: 1, CollectionID: 1, Enabled: true}}
+	collections := &countingCollectionService{fakeCollectionService: fakeCollectionService{collection: mockTestCollection()}}
+	s := NewMockService(mocks, collections).(*mockService)
+
+	response, err := s.ServeMock(context.Background(), "m1", &model.DTOMockRequest{Method: "GET", Path: "/v1/users/me"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Status != http.StatusOK || response.Body != "me" || response.Headers[len(response.Headers)-2].Value != "sandbox" {
+		t.Errorf("response = %+v", response)
+	}
+	response, err = s.ServeMock(context.Background(), "m1", &model.DTOMockRequest{Method: "GET", Path: "/v1/missing"})
+	if err != nil || response.Status != http.StatusNotFound || response.Headers[len(response.Headers)-1].Value != "nosniff" {
+		t.Errorf("response = %+v, err = %v", response, err)
+	}
+	if collections.loads != 1 || len(mocks.hits) != 2 || *mocks.hits[0].ItemID != 2 || mocks.hits[1].Status != http.StatusNotFound {
+		t.Errorf("loads = %d, hits = %+v", collections.loads, mocks.hits)
+	}
+
+	// Pointing the mock at another collection skips the cached copy.
+	mocks.mock.CollectionID = 2
+	if _, err := s.ServeMock(context.Background(), "m1", &model.DTOMockRequest{Method: "GET", Path: "/v1/users/me"}); err != ErrCollectionNotFound {
+		t.Errorf("err = %v, want %v", err, ErrCollectionNotFound)
+	}
+
+	mocks.mock.Enabled = false
+	if _, err := s.ServeMock(context.Background(), "m1", &model.DTOMockRequest{Method: "GET", Path: "/v1/users/me"}); err != ErrMockNotFound {
+		t.Errorf("err = %v, want %v", err, ErrMockNotFound)
+	}
+}
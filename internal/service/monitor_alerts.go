@@ -0,0 +1,454 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/suar-net/suar-be/internal/model"
+)
+
+const (
+	maxAlertsPerMonitor = 10
+	maxAlertDeliveries  = 100
+	alertSendTimeout    = 10 * time.Second
+	// alertPollInterval is how often a replica looks for alerts to send.
+	alertPollInterval = 5 * time.Second
+	// alertDeliveryLease keeps a delivery from being sent twice while an
+	// attempt is in flight.
+	alertDeliveryLease  = time.Minute
+	maxConcurrentAlerts = 10
+	maxAlertFailures    = 10
+)
+
+// alertRetryDelays are the waits before each retry of a delivery; it is
+// given up after the last one.
+var alertRetryDelays = []time.Duration{30 * time.Second, 2 * time.Minute, 10 * time.Minute, 30 * time.Minute}
+
+// monitorFailure tells why a request of a check failed, or returns "" when
+// it passed. A status outside 2xx fails the request unless it has an
+// assertion on the status, which then decides.
+func monitorFailure(report *model.RunItemResult) string {
+	name := report.Path
+	if report.Error != "" {
+		return fmt.Sprintf("%s: %s", name, report.Error)
+	}
+	statusAsserted := false
+	for _, assertion := range report.Assertions {
+		statusAsserted = statusAsserted || assertion.Source == "status"
+	}
+	if !statusAsserted && report.StatusCode != 0 && (report.StatusCode < 200 || report.StatusCode > 299) {
+		report.Passed = false
+		return fmt.Sprintf("%s: unexpected status %d", name, report.StatusCode)
+	}
+	for _, assertion := range report.Assertions {
+		if !assertion.Passed {
+			message := assertion.Message
+			if assertion.Name != "" {
+				message = assertion.Name + ": " + message
+			}
+			return fmt.Sprintf("%s: assertion failed: %s", name, message)
+		}
+	}
+	for _, test := range report.Tests {
+		if !test.Passed && !test.Skipped {
+			return fmt.Sprintf("%s: test %q failed", name, test.Name)
+		}
+	}
+	if report.Contract != nil && !report.Contract.Valid {
+		return fmt.Sprintf("%s: response does not match the contract", name)
+	}
+	if !report.Passed {
+		return fmt.Sprintf("%s: failed", name)
+	}
+	return ""
+}
+
+// updateAlertState moves the alert state of a monitor on with the result of
+// a check. It returns the event to notify, if any, with the key that keeps
+// it from being notified twice: a monitor fails and recovers once per
+// failing period, identified by when it started failing.
+func updateAlertState(monitor *model.Monitor, result *model.MonitorResult) (*model.AlertEvent, string) {
+	if result.Status != model.MonitorStatusUp {
+		monitor.ConsecutiveFailures++
+		if monitor.FailingSince == nil {
+			failingSince := result.StartedAt
+			monitor.FailingSince = &failingSince
+		}
+		if monitor.AlertState == model.AlertStateFailing || monitor.ConsecutiveFailures < max(monitor.AlertAfter, 1) {
+			return nil, ""
+		}
+		monitor.AlertState = model.AlertStateFailing
+		event := alertEvent(model.AlertEventFailing, monitor, result)
+		return event, alertDedupKey(event)
+	}
+
+	var event *model.AlertEvent
+	if monitor.AlertState == model.AlertStateFailing {
+		event = alertEvent(model.AlertEventRecovered, monitor, result)
+	}
+	monitor.AlertState = model.AlertStateOK
+	monitor.ConsecutiveFailures = 0
+	monitor.FailingSince = nil
+	if event == nil {
+		return nil, ""
+	}
+	return event, alertDedupKey(event)
+}
+
+func alertDedupKey(event *model.AlertEvent) string {
+	since := event.CheckedAt
+	if event.FailingSince != nil {
+		since = *event.FailingSince
+	}
+	return event.Event + ":" + strconv.FormatInt(since.UnixMilli(), 10)
+}
+
+func alertEvent(name string, monitor *model.Monitor, result *model.MonitorResult) *model.AlertEvent {
+	failures := result.Failures
+	if len(failures) > maxAlertFailures {
+		failures = failures[:maxAlertFailures]
+	}
+	if name == model.AlertEventRecovered {
+		failures = nil
+	}
+	return &model.AlertEvent{
+		Event:        name,
+		Monitor:      model.AlertEventMonitor{ID: monitor.ID, Name: monitor.Name, Schedule: monitor.Schedule},
+		Status:       result.Status,
+		Failures:     failures,
+		FailingSince: monitor.FailingSince,
+		ResultID:     result.ID,
+		CheckedAt:    result.StartedAt,
+		Requests:     result.Requests,
+		Failed:       result.Failed,
+		LatencyMs:    result.LatencyMs,
+	}
+}
+
+// checkAlertURL applies the egress policy to a webhook URL.
+func checkAlertURL(rawURL string) error {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") {
+		return fmt.Errorf("%w: alert URL must be an http or https URL", ErrInvalidInput)
+	}
+	return checkEgress(parsedURL.Hostname())
+}
+
+func (s *monitorService) ListAlerts(ctx context.Context, monitorID int, userID int) ([]*model.MonitorAlert, error) {
+	if _, err := s.GetMonitor(ctx, monitorID, userID); err != nil {
+		return nil, err
+	}
+	alerts, err := s.alerts.GetByMonitorID(ctx, monitorID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list alerts: %w", err)
+	}
+	if alerts == nil {
+		alerts = []*model.MonitorAlert{}
+	}
+	return alerts, nil
+}
+
+func (s *monitorService) CreateAlert(ctx context.Context, monitorID int, userID int, dto *model.DTOMonitorAlertRequest) (*model.MonitorAlert, error) {
+	if _, err := s.GetMonitor(ctx, monitorID, userID); err != nil {
+		return nil, err
+	}
+	alerts, err := s.alerts.GetByMonitorID(ctx, monitorID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list alerts: %w", err)
+	}
+	if len(alerts) >= maxAlertsPerMonitor {
+		return nil, ErrAlertLimitReached
+	}
+	if err := s.checkAlertURL(dto.URL); err != nil {
+		return nil, err
+	}
+
+	alert := &model.MonitorAlert{MonitorID: monitorID}
+	applyAlert(alert, dto)
+	if _, err := s.alerts.Create(ctx, alert); err != nil {
+		return nil, fmt.Errorf("failed to save alert: %w", err)
+	}
+	return alert, nil
+}
+
+// UpdateAlert replaces the settings of an alert. The secret is kept when
+// none is given.
+func (s *monitorService) UpdateAlert(ctx context.Context, id int, monitorID int, userID int, dto *model.DTOMonitorAlertRequest) (*model.MonitorAlert, error) {
+	alert, err := s.getAlert(ctx, id, monitorID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkAlertURL(dto.URL); err != nil {
+		return nil, err
+	}
+
+	applyAlert(alert, dto)
+	found, err := s.alerts.Update(ctx, alert)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update alert: %w", err)
+	}
+	if !found {
+		return nil, ErrAlertNotFound
+	}
+	return alert, nil
+}
+
+func applyAlert(alert *model.MonitorAlert, dto *model.DTOMonitorAlertRequest) {
+	alert.Name = dto.Name
+	alert.Kind = dto.Kind
+	alert.URL = dto.URL
+	alert.Enabled = dto.Enabled == nil || *dto.Enabled
+	if dto.Secret != nil {
+		alert.Secret = *dto.Secret
+	}
+}
+
+func (s *monitorService) DeleteAlert(ctx context.Context, id int, monitorID int, userID int) error {
+	if _, err := s.GetMonitor(ctx, monitorID, userID); err != nil {
+		return err
+	}
+	found, err := s.alerts.Delete(ctx, id, monitorID)
+	if err != nil {
+		return fmt.Errorf("failed to delete alert: %w", err)
+	}
+	if !found {
+		return ErrAlertNotFound
+	}
+	return nil
+}
+
+func (s *monitorService) getAlert(ctx context.Context, id int, monitorID int, userID int) (*model.MonitorAlert, error) {
+	if _, err := s.GetMonitor(ctx, monitorID, userID); err != nil {
+		return nil, err
+	}
+	alert, err := s.alerts.GetByID(ctx, id, monitorID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get alert: %w", err)
+	}
+	if alert == nil {
+		return nil, ErrAlertNotFound
+	}
+	return alert, nil
+}
+
+// TestAlert sends a test notification to an alert right away, whether it is
+// enabled or not, and reports how the webhook answered.
+func (s *monitorService) TestAlert(ctx context.Context, id int, monitorID int, userID int) (*model.DTOAlertTestResult, error) {
+	alert, err := s.getAlert(ctx, id, monitorID, userID)
+	if err != nil {
+		return nil, err
+	}
+	monitor, err := s.GetMonitor(ctx, monitorID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	event := &model.AlertEvent{
+		Event:     model.AlertEventTest,
+		Monitor:   model.AlertEventMonitor{ID: monitor.ID, Name: monitor.Name, Schedule: monitor.Schedule},
+		Status:    monitor.LastStatus,
+		CheckedAt: time.Now(),
+	}
+	statusCode, err := s.sendAlert(ctx, alert, event, "test")
+	result := &model.DTOAlertTestResult{Delivered: err == nil, StatusCode: statusCode}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result, nil
+}
+
+func (s *monitorService) ListDeliveries(ctx context.Context, monitorID int, userID int, limit int) ([]*model.AlertDelivery, error) {
+	if _, err := s.GetMonitor(ctx, monitorID, userID); err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > maxAlertDeliveries {
+		limit = maxAlertDeliveries
+	}
+	deliveries, err := s.alerts.GetDeliveries(ctx, monitorID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list alert deliveries: %w", err)
+	}
+	if deliveries == nil {
+		deliveries = []*model.AlertDelivery{}
+	}
+	return deliveries, nil
+}
+
+// deliver polls for queued alert deliveries and sends them. Deliveries are
+// claimed in the database like monitors are, so each is sent by one replica.
+func (s *monitorService) deliver(ctx context.Context) {
+	ticker := time.NewTicker(alertPollInterval)
+	defer ticker.Stop()
+	for {
+		claimCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		deliveries, err := s.alerts.ClaimDeliveries(claimCtx, maxConcurrentAlerts, alertDeliveryLease)
+		cancel()
+		if err != nil && ctx.Err() == nil {
+			log.Printf("failed to claim alert deliveries: %v", err)
+		}
+
+		var sends sync.WaitGroup
+		for _, delivery := range deliveries {
+			sends.Add(1)
+			go func() {
+				defer sends.Done()
+				s.deliverAlert(ctx, delivery)
+			}()
+		}
+		sends.Wait()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// deliverAlert sends a claimed delivery and records the outcome. A failed
+// attempt is retried after the next of alertRetryDelays; one cut short by
+// shutdown is retried once its lease runs out.
+func (s *monitorService) deliverAlert(ctx context.Context, delivery *model.AlertDelivery) {
+	var err error
+	if delivery.Alert.Enabled {
+		_, err = s.sendAlert(ctx, delivery.Alert, &delivery.Payload, strconv.FormatInt(delivery.ID, 10))
+		if err != nil && ctx.Err() != nil {
+			return
+		}
+	} else {
+		err = errors.New("alert is disabled")
+	}
+
+	saveCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err == nil {
+		if err := s.alerts.MarkSent(saveCtx, delivery.ID); err != nil {
+			log.Printf("failed to record alert delivery %d: %v", delivery.ID, err)
+		}
+		return
+	}
+
+	var retryAt *time.Time
+	if delivery.Alert.Enabled && delivery.Attempts <= len(alertRetryDelays) {
+		next := time.Now().Add(alertRetryDelays[delivery.Attempts-1])
+		retryAt = &next
+	}
+	if err := s.alerts.MarkFailed(saveCtx, delivery.ID, err.Error(), retryAt); err != nil {
+		log.Printf("failed to record alert delivery %d: %v", delivery.ID, err)
+	}
+}
+
+// sendAlert posts an event to an alert in the payload its kind expects. Any
+// status outside 2xx is a failure; redirects are not followed.
+func (s *monitorService) sendAlert(ctx context.Context, alert *model.MonitorAlert, event *model.AlertEvent, deliveryID string) (int, error) {
+	if err := s.checkAlertURL(alert.URL); err != nil {
+		return 0, err
+	}
+	body, err := json.Marshal(alertPayload(alert.Kind, event))
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode alert: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, alert.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create alert request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "suar-monitor")
+	if alert.Kind == model.AlertKindGeneric {
+		req.Header.Set("X-Suar-Event", event.Event)
+		req.Header.Set("X-Suar-Delivery", deliveryID)
+		if alert.Secret != "" {
+			req.Header.Set("X-Suar-Signature", alertSignature(alert.Secret, body))
+		}
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send alert: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook answered with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// alertSignature signs the body of a generic webhook with the secret of its
+// alert, so the receiver can tell the request came from us.
+func alertSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// alertPayload builds the body sent to an alert: the event itself for
+// generic webhooks, a message for Slack and Teams.
+func alertPayload(kind string, event *model.AlertEvent) any {
+	title, lines := alertMessage(event)
+	switch kind {
+	case model.AlertKindSlack:
+		icon := ":red_circle:"
+		if event.Event != model.AlertEventFailing {
+			icon = ":large_green_circle:"
+		}
+		text := icon + " *" + title + "*"
+		for _, line := range lines {
+			text += "\n• " + line
+		}
+		return map[string]any{"text": text}
+	case model.AlertKindTeams:
+		color := "D70000"
+		if event.Event != model.AlertEventFailing {
+			color = "2EB886"
+		}
+		return map[string]any{
+			"@type":      "MessageCard",
+			"@context":   "https://schema.org/extensions",
+			"themeColor": color,
+			"summary":    title,
+			"title":      title,
+			"text":       strings.Join(lines, "\n\n"),
+		}
+	default:
+		return event
+	}
+}
+
+// alertMessage writes an event as a title and lines of text for chat
+// webhooks.
+func alertMessage(event *model.AlertEvent) (string, []string) {
+	name := event.Monitor.Name
+	var title string
+	var lines []string
+	switch event.Event {
+	case model.AlertEventFailing:
+		title = fmt.Sprintf("Monitor %q is failing", name)
+		lines = append(lines, event.Failures...)
+		lines = append(lines, fmt.Sprintf("%d of %d requests failed in %d ms", event.Failed, event.Requests, event.LatencyMs))
+	case model.AlertEventRecovered:
+		title = fmt.Sprintf("Monitor %q recovered", name)
+		if event.FailingSince != nil {
+			lines = append(lines, fmt.Sprintf("Failing since %s (%s)", event.FailingSince.UTC().Format(time.RFC3339),
+				event.CheckedAt.Sub(*event.FailingSince).Round(time.Second)))
+		}
+	default:
+		title = fmt.Sprintf("Test notification for monitor %q", name)
+		lines = append(lines, "This webhook will be notified when the monitor fails and recovers.")
+	}
+	return title, lines
+}
@@ -0,0 +1,347 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/suar-net/suar-be/internal/model"
+	"github.com/suar-net/suar-be/internal/repository"
+)
+
+// fakeAlertRepo records how deliveries were marked.
+type fakeAlertRepo struct {
+	repository.IMonitorAlertRepository
+	mu      sync.Mutex
+	sent    []int64
+	failed  []string
+	retryAt []*time.Time
+}
+
+func (r *fakeAlertRepo) MarkSent(ctx context.Context, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sent = append(r.sent, id)
+	return nil
+}
+
+func (r *fakeAlertRepo) MarkFailed(ctx context.Context, id int64, message string, retryAt *time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failed = append(r.failed, message)
+	r.retryAt = append(r.retryAt, retryAt)
+	return nil
+}
+
+// webhookRequest is what a test webhook received.
+type webhookRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// newTestWebhook starts a webhook that answers with status and records the
+// requests it gets, and a monitor service that sends alerts to it.
+func newTestWebhook(t *testing.T, status int) (*monitorService, *fakeAlertRepo, *httptest.Server, chan webhookRequest) {
+	t.Helper()
+	received := make(chan webhookRequest, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- webhookRequest{header: r.Header.Clone(), body: body}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+
+	alerts := &fakeAlertRepo{}
+	s := &monitorService{
+		alerts:        alerts,
+		client:        server.Client(),
+		checkAlertURL: func(string) error { return nil },
+	}
+	return s, alerts, server, received
+}
+
+func TestSendAlert(t *testing.T) {
+	failingSince := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	failing := &model.AlertEvent{
+		Event:        model.AlertEventFailing,
+		Monitor:      model.AlertEventMonitor{ID: 4, Name: "Shop"},
+		Status:       model.MonitorStatusDown,
+		Failures:     []string{"Users / Broken: unexpected status 503"},
+		FailingSince: &failingSince,
+		CheckedAt:    failingSince,
+		Requests:     3,
+		Failed:       1,
+		LatencyMs:    50,
+	}
+	s, _, server, received := newTestWebhook(t, http.StatusNoContent)
+
+	tests := []struct {
+		name    string
+		alert   model.MonitorAlert
+		headers map[string]string
+		check   func(body map[string]any) bool
+	}{
+		{"generic", model.MonitorAlert{Kind: model.AlertKindGeneric},
+			map[string]string{"X-Suar-Event": model.AlertEventFailing, "X-Suar-Delivery": "7", "X-Suar-Signature": ""},
+			func(body map[string]any) bool {
+				return body["event"] == model.AlertEventFailing && body["failed"] == float64(1) &&
+					body["monitor"].(map[string]any)["name"] == "Shop"
+			}},
+		{"generic with secret", model.MonitorAlert{Kind: model.AlertKindGeneric, Secret: "s3cret"},
+			map[string]string{"X-Suar-Event": model.AlertEventFailing},
+			func(body map[string]any) bool { return body["event"] == model.AlertEventFailing }},
+		{"slack", model.MonitorAlert{Kind: model.AlertKindSlack},
+			map[string]string{"X-Suar-Event": "", "X-Suar-Signature": ""},
+			func(body map[string]any) bool {
+				return body["text"] == ":red_circle: *Monitor \"Shop\" is failing*\n• Users / Broken: unexpected status 503\n• 1 of 3 requests failed in 50 ms"
+			}},
+		{"teams", model.MonitorAlert{Kind: model.AlertKindTeams, Secret: "s3cret"},
+			map[string]string{"X-Suar-Event": "", "X-Suar-Signature": ""},
+			func(body map[string]any) bool {
+				return body["@type"] == "MessageCard" && body["themeColor"] == "D70000" &&
+					body["title"] == `Monitor "Shop" is failing` &&
+					body["text"] == "Users / Broken: unexpected status 503\n\n1 of 3 requests failed in 50 ms"
+			}},
+	}
+	for _, tt := range tests {
+		tt.alert.URL = server.URL
+		status, err := s.sendAlert(context.Background(), &tt.alert, failing, "7")
+		if err != nil || status != http.StatusNoContent {
+			t.Fatalf("%s: status = %d, err = %v", tt.name, status, err)
+		}
+		request := <-received
+		if request.header.Get("Content-Type") != "application/json" || request.header.Get("User-Agent") != "suar-monitor" {
+			t.Errorf("%s: headers = %v", tt.name, request.header)
+		}
+		for name, want := range tt.headers {
+			if got := request.header.Get(name); got != want {
+				t.Errorf("%s: %s = %q, want %q", tt.name, name, got, want)
+			}
+		}
+		if tt.alert.Kind == model.AlertKindGeneric && tt.alert.Secret != "" {
+			mac := hmac.New(sha256.New, []byte(tt.alert.Secret))
+			mac.Write(request.body)
+			if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); request.header.Get("X-Suar-Signature") != want {
+				t.Errorf("%s: signature = %q, want %q", tt.name, request.header.Get("X-Suar-Signature"), want)
+			}
+		}
+		var body map[string]any
+		if err := json.Unmarshal(request.body, &body); err != nil || !tt.check(body) {
+			t.Errorf("%s: body = %s", tt.name, request.body)
+		}
+	}
+}
+
+func TestSendAlertFailures(t *testing.T) {
+	event := &model.AlertEvent{Event: model.AlertEventTest}
+
+	s, _, server, received := newTestWebhook(t, http.StatusBadGateway)
+	status, err := s.sendAlert(context.Background(), &model.MonitorAlert{Kind: model.AlertKindGeneric, URL: server.URL}, event, "test")
+	if status != http.StatusBadGateway || err == nil || err.Error() != "webhook answered with status 502" {
+		t.Errorf("status = %d, err = %v", status, err)
+	}
+	<-received
+
+	// The egress policy is applied before anything is sent.
+	s.checkAlertURL = checkAlertURL
+	status, err = s.sendAlert(context.Background(), &model.MonitorAlert{URL: "ftp://" + server.Listener.Addr().String()}, event, "test")
+	if status != 0 || !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("status = %d, err = %v", status, err)
+	}
+	select {
+	case <-received:
+		t.Error("a refused URL was sent to")
+	default:
+	}
+}
+
+func TestDeliverAlert(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		enabled   bool
+		attempts  int
+		sent      bool
+		message   string
+		wantRetry time.Duration
+	}{
+		{name: "sent", status: http.StatusOK, enabled: true, attempts: 1, sent: true},
+		{name: "first failure", status: http.StatusInternalServerError, enabled: true, attempts: 1,
+			message: "webhook answered with status 500", wantRetry: alertRetryDelays[0]},
+		{name: "second failure", status: http.StatusInternalServerError, enabled: true, attempts: 2,
+			message: "webhook answered with status 500", wantRetry: alertRetryDelays[1]},
+		{name: "last retry", status: http.StatusInternalServerError, enabled: true, attempts: len(alertRetryDelays),
+			message: "webhook answered with status 500", wantRetry: alertRetryDelays[len(alertRetryDelays)-1]},
+		{name: "given up", status: http.StatusInternalServerError, enabled: true, attempts: len(alertRetryDelays) + 1,
+			message: "webhook answered with status 500"},
+		{name: "disabled", status: http.StatusOK, attempts: 1, message: "alert is disabled"},
+	}
+	for _, tt := range tests {
+		s, alerts, server, _ := newTestWebhook(t, tt.status)
+		delivery := &model.AlertDelivery{
+			ID:       9,
+			Attempts: tt.attempts,
+			Payload:  model.AlertEvent{Event: model.AlertEventFailing},
+			Alert:    &model.MonitorAlert{Kind: model.AlertKindGeneric, URL: server.URL, Enabled: tt.enabled},
+		}
+		before := time.Now()
+		s.deliverAlert(context.Background(), delivery)
+		after := time.Now()
+
+		if tt.sent {
+			if !reflect.DeepEqual(alerts.sent, []int64{9}) || alerts.failed != nil {
+				t.Errorf("%s: sent = %v, failed = %q", tt.name, alerts.sent, alerts.failed)
+			}
+			continue
+		}
+		if alerts.sent != nil || !reflect.DeepEqual(alerts.failed, []string{tt.message}) {
+			t.Errorf("%s: sent = %v, failed = %q", tt.name, alerts.sent, alerts.failed)
+			continue
+		}
+		retryAt := alerts.retryAt[0]
+		switch {
+		case tt.wantRetry == 0 && retryAt != nil:
+			t.Errorf("%s: retried at %v", tt.name, retryAt)
+		case tt.wantRetry != 0 && (retryAt == nil || retryAt.Before(before.Add(tt.wantRetry)) || retryAt.After(after.Add(tt.wantRetry))):
+			t.Errorf("%s: retry at %v, want %v after now", tt.name, retryAt, tt.wantRetry)
+		}
+	}
+}
+
+func TestUpdateAlertState(t *testing.T) {
+	start := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	check := func(minute int, status string) *model.MonitorResult {
+		return &model.MonitorResult{Status: status, StartedAt: start.Add(time.Duration(minute) * time.Minute)}
+	}
+	since := func(minute int) string {
+		return ":" + strconv.FormatInt(start.Add(time.Duration(minute)*time.Minute).UnixMilli(), 10)
+	}
+
+	tests := []struct {
+		name       string
+		alertAfter int
+		checks     []*model.MonitorResult
+		// want is the dedup key of the event notified after each check, or ""
+		// when none was.
+		want []string
+	}{
+		{"alerts on the first failure", 0,
+			[]*model.MonitorResult{check(0, model.MonitorStatusDown), check(1, model.MonitorStatusDown)},
+			[]string{model.AlertEventFailing + since(0), ""}},
+		{"waits for the threshold", 3,
+			[]*model.MonitorResult{check(0, model.MonitorStatusDown), check(1, model.MonitorStatusError), check(2, model.MonitorStatusDown), check(3, model.MonitorStatusDown)},
+			[]string{"", "", model.AlertEventFailing + since(0), ""}},
+		{"recovery before the threshold is quiet", 2,
+			[]*model.MonitorResult{check(0, model.MonitorStatusDown), check(1, model.MonitorStatusUp), check(2, model.MonitorStatusDown)},
+			[]string{"", "", ""}},
+		{"recovers once per failing period", 1,
+			[]*model.MonitorResult{check(0, model.MonitorStatusUp), check(1, model.MonitorStatusDown), check(2, model.MonitorStatusUp), check(3, model.MonitorStatusUp)},
+			[]string{"", model.AlertEventFailing + since(1), model.AlertEventRecovered + since(1), ""}},
+		{"a new failing period has a new key", 1,
+			[]*model.MonitorResult{check(0, model.MonitorStatusDown), check(1, model.MonitorStatusUp), check(2, model.MonitorStatusDown)},
+			[]string{model.AlertEventFailing + since(0), model.AlertEventRecovered + since(0), model.AlertEventFailing + since(2)}},
+	}
+	for _, tt := range tests {
+		monitor := &model.Monitor{Name: "Shop", AlertAfter: tt.alertAfter, AlertState: model.AlertStateOK}
+		var got []string
+		for _, result := range tt.checks {
+			event, key := updateAlertState(monitor, result)
+			if (event == nil) != (key == "") || (event != nil && key != alertDedupKey(event)) {
+				t.Errorf("%s: event = %+v, key = %q", tt.name, event, key)
+			}
+			got = append(got, key)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: keys = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAlertDedupKey(t *testing.T) {
+	checkedAt := time.UnixMilli(2000)
+	failingSince := time.UnixMilli(1000)
+	tests := []struct {
+		event model.AlertEvent
+		want  string
+	}{
+		{model.AlertEvent{Event: model.AlertEventFailing, CheckedAt: checkedAt, FailingSince: &failingSince}, "monitor.failing:1000"},
+		{model.AlertEvent{Event: model.AlertEventRecovered, CheckedAt: checkedAt, FailingSince: &failingSince}, "monitor.recovered:1000"},
+		{model.AlertEvent{Event: model.AlertEventFailing, CheckedAt: checkedAt}, "monitor.failing:2000"},
+	}
+	for _, tt := range tests {
+		if got := alertDedupKey(&tt.event); got != tt.want {
+			t.Errorf("alertDedupKey(%+v) = %q, want %q", tt.event, got, tt.want)
+		}
+	}
+}
+
+func TestAlertMessage(t *testing.T) {
+	failingSince := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	monitor := model.AlertEventMonitor{Name: "Shop"}
+	tests := []struct {
+		event model.AlertEvent
+		title string
+		lines []string
+	}{
+		{model.AlertEvent{Event: model.AlertEventFailing, Monitor: monitor, Failures: []string{"a: failed"}, Requests: 2, Failed: 1, LatencyMs: 30},
+			`Monitor "Shop" is failing`, []string{"a: failed", "1 of 2 requests failed in 30 ms"}},
+		{model.AlertEvent{Event: model.AlertEventRecovered, Monitor: monitor, FailingSince: &failingSince, CheckedAt: failingSince.Add(90 * time.Second)},
+			`Monitor "Shop" recovered`, []string{"Failing since 2026-03-01T10:00:00Z (1m30s)"}},
+		{model.AlertEvent{Event: model.AlertEventTest, Monitor: monitor},
+			`Test notification for monitor "Shop"`, []string{"This webhook will be notified when the monitor fails and recovers."}},
+	}
+	for _, tt := range tests {
+		title, lines := alertMessage(&tt.event)
+		if title != tt.title || !reflect.DeepEqual(lines, tt.lines) {
+			t.Errorf("alertMessage(%s) = %q, %q", tt.event.Event, title, lines)
+		}
+	}
+
+	// Slack and Teams messages are green once a monitor recovers.
+	recovered := &model.AlertEvent{Event: model.AlertEventRecovered, Monitor: monitor}
+	if text := alertPayload(model.AlertKindSlack, recovered).(map[string]any)["text"]; text != `:large_green_circle: *Monitor "Shop" recovered*` {
+		t.Errorf("slack text = %q", text)
+	}
+	if color := alertPayload(model.AlertKindTeams, recovered).(map[string]any)["themeColor"]; color != "2EB886" {
+		t.Errorf("teams color = %q", color)
+	}
+	if payload := alertPayload(model.AlertKindGeneric, recovered); payload != recovered {
+		t.Errorf("generic payload = %+v", payload)
+	}
+}
+
+func TestMonitorFailure(t *testing.T) {
+	tests := []struct {
+		name   string
+		report model.RunItemResult
+		want   string
+	}{
+		{"passed", model.RunItemResult{Path: "a", Passed: true, StatusCode: 200}, ""},
+		{"error", model.RunItemResult{Path: "a", Error: "connection refused"}, "a: connection refused"},
+		{"bad status", model.RunItemResult{Path: "a", Passed: true, StatusCode: 500}, "a: unexpected status 500"},
+		{"asserted status", model.RunItemResult{Path: "a", Passed: true, StatusCode: 404,
+			Assertions: []model.AssertionResult{{Assertion: model.Assertion{Source: "status"}, Passed: true}}}, ""},
+		{"failed assertion", model.RunItemResult{Path: "a", StatusCode: 200,
+			Assertions: []model.AssertionResult{{Assertion: model.Assertion{Name: "fast", Source: "response_time"}, Message: "too slow"}}},
+			"a: assertion failed: fast: too slow"},
+		{"failed test", model.RunItemResult{Path: "a", StatusCode: 200, Tests: []model.DTOScriptTest{{Name: "ok", Passed: false}}},
+			`a: test "ok" failed`},
+		{"skipped test", model.RunItemResult{Path: "a", Passed: true, StatusCode: 200, Tests: []model.DTOScriptTest{{Name: "ok", Skipped: true}}}, ""},
+	}
+	for _, tt := range tests {
+		if got := monitorFailure(&tt.report); got != tt.want {
+			t.Errorf("%s: monitorFailure = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
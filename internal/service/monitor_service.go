@@ -0,0 +1,484 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	_ "time/tzdata" // monitor time zones must not depend on the host
+
+	"github.com/robfig/cron/v3"
+	"github.com/suar-net/suar-be/internal/model"
+	"github.com/suar-net/suar-be/internal/repository"
+)
+
+const (
+	maxMonitorsPerUser = 50
+	minMonitorInterval = time.Minute
+	// monitorCheckTimeout bounds a whole check, the lease of a monitor lasts
+	// a little longer so a check is never run twice at the same time.
+	monitorCheckTimeout = 5 * time.Minute
+	monitorLease        = monitorCheckTimeout + time.Minute
+	// monitorPollInterval is how often a replica looks for due monitors, it
+	// is the most a check can start late.
+	monitorPollInterval   = 10 * time.Second
+	maxConcurrentMonitors = 10
+	maxMonitorResults     = 200
+	maxMonitorFailures    = 20
+)
+
+// monitorStatsWindows maps the windows stats can be asked for to their
+// length and to the date_trunc unit of their series.
+var monitorStatsWindows = map[string]struct {
+	length time.Duration
+	bucket string
+}{
+	"1h":  {time.Hour, "minute"},
+	"24h": {24 * time.Hour, "hour"},
+	"7d":  {7 * 24 * time.Hour, "hour"},
+	"30d": {30 * 24 * time.Hour, "day"},
+}
+
+// monitorService manages monitors and runs their checks. Every replica runs
+// a scheduler; a monitor that is due is leased by exactly one of them in the
+// database, so replicas share the checks without coordinating otherwise.
+type monitorService struct {
+	monitors     repository.IMonitorRepository
+	alerts       repository.IMonitorAlertRepository
+	collections  ICollectionService
+	environments repository.IEnvironmentRepository
+	requests     IRequestService
+	client       *http.Client
+	// checkAlertURL applies the egress policy to webhook URLs.
+	checkAlertURL func(rawURL string) error
+
+	mu      sync.Mutex
+	cancel  context.CancelFunc
+	stopped chan struct{}
+}
+
+func NewMonitorService(m repository.IMonitorRepository, a repository.IMonitorAlertRepository, collections ICollectionService, e repository.IEnvironmentRepository, requests IRequestService) IMonitorService {
+	return &monitorService{
+		monitors:      m,
+		alerts:        a,
+		collections:   collections,
+		environments:  e,
+		requests:      requests,
+		checkAlertURL: checkAlertURL,
+		client: &http.Client{
+			Timeout: alertSendTimeout,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// parseSchedule parses a cron expression and checks it does not fire more
+// often than once a minute.
+func parseSchedule(schedule, timezone string) (cron.Schedule, *time.Location, error) {
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	location, err := time.LoadLocation(timezone)
+	if err != nil || timezone == "Local" {
+		return nil, nil, fmt.Errorf("%w: unknown time zone %q", ErrInvalidInput, timezone)
+	}
+	if strings.HasPrefix(strings.TrimSpace(schedule), "TZ=") || strings.HasPrefix(strings.TrimSpace(schedule), "CRON_TZ=") {
+		return nil, nil, fmt.Errorf("%w: set the time zone with the timezone field", ErrInvalidInput)
+	}
+	parsed, err := cron.ParseStandard(schedule)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: invalid schedule: %v", ErrInvalidInput, err)
+	}
+
+	first := parsed.Next(time.Now().In(location))
+	if first.IsZero() {
+		return nil, nil, fmt.Errorf("%w: schedule never fires", ErrInvalidInput)
+	}
+	if second := parsed.Next(first); second.Sub(first) < minMonitorInterval {
+		return nil, nil, fmt.Errorf("%w: schedule fires more often than once every %v", ErrInvalidInput, minMonitorInterval)
+	}
+	return parsed, location, nil
+}
+
+func nextMonitorRun(schedule cron.Schedule, location *time.Location, after time.Time) time.Time {
+	return schedule.Next(after.In(location))
+}
+
+// applyMonitor checks the settings of a monitor against what the user owns
+// and copies them onto monitor.
+func (s *monitorService) applyMonitor(ctx context.Context, monitor *model.Monitor, dto *model.DTOMonitorRequest) error {
+	schedule, location, err := parseSchedule(dto.Schedule, dto.Timezone)
+	if err != nil {
+		return err
+	}
+
+	collection, err := s.collections.GetCollection(ctx, dto.CollectionID, monitor.UserID)
+	if err != nil {
+		return err
+	}
+	if dto.ItemID != nil && findItemPath(collection.Items, *dto.ItemID) == nil {
+		return ErrItemNotFound
+	}
+	if dto.EnvironmentID != nil {
+		environment, err := s.environments.GetByID(ctx, *dto.EnvironmentID, monitor.UserID)
+		if err != nil {
+			return fmt.Errorf("failed to load environment: %w", err)
+		}
+		if environment == nil {
+			return ErrEnvironmentNotFound
+		}
+	}
+
+	monitor.CollectionID = dto.CollectionID
+	monitor.ItemID = dto.ItemID
+	monitor.EnvironmentID = dto.EnvironmentID
+	monitor.Name = dto.Name
+	monitor.Schedule = strings.TrimSpace(dto.Schedule)
+	monitor.Timezone = location.String()
+	monitor.Timeout = dto.Timeout
+	monitor.Enabled = dto.Enabled == nil || *dto.Enabled
+	monitor.NextRunAt = nextMonitorRun(schedule, location, time.Now())
+	monitor.LatencyThresholdMs = dto.LatencyThresholdMs
+	monitor.AlertAfter = max(dto.AlertAfter, 1)
+	return nil
+}
+
+func (s *monitorService) CreateMonitor(ctx context.Context, userID int, dto *model.DTOMonitorRequest) (*model.Monitor, error) {
+	count, err := s.monitors.CountByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count monitors: %w", err)
+	}
+	if count >= maxMonitorsPerUser {
+		return nil, ErrMonitorLimitReached
+	}
+
+	monitor := &model.Monitor{UserID: userID}
+	if err := s.applyMonitor(ctx, monitor, dto); err != nil {
+		return nil, err
+	}
+	if _, err := s.monitors.Create(ctx, monitor); err != nil {
+		return nil, fmt.Errorf("failed to save monitor: %w", err)
+	}
+	return monitor, nil
+}
+
+func (s *monitorService) ListMonitors(ctx context.Context, userID int) ([]*model.Monitor, error) {
+	monitors, err := s.monitors.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list monitors: %w", err)
+	}
+	if monitors == nil {
+		monitors = []*model.Monitor{}
+	}
+	return monitors, nil
+}
+
+func (s *monitorService) GetMonitor(ctx context.Context, id int, userID int) (*model.Monitor, error) {
+	monitor, err := s.monitors.GetByID(ctx, id, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get monitor: %w", err)
+	}
+	if monitor == nil {
+		return nil, ErrMonitorNotFound
+	}
+	return monitor, nil
+}
+
+// UpdateMonitor replaces the settings of a monitor. The next check is
+// scheduled from the new schedule.
+func (s *monitorService) UpdateMonitor(ctx context.Context, id int, userID int, dto *model.DTOMonitorRequest) (*model.Monitor, error) {
+	monitor, err := s.GetMonitor(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.applyMonitor(ctx, monitor, dto); err != nil {
+		return nil, err
+	}
+	found, err := s.monitors.Update(ctx, monitor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update monitor: %w", err)
+	}
+	if !found {
+		return nil, ErrMonitorNotFound
+	}
+	return monitor, nil
+}
+
+func (s *monitorService) DeleteMonitor(ctx context.Context, id int, userID int) error {
+	found, err := s.monitors.Delete(ctx, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete monitor: %w", err)
+	}
+	if !found {
+		return ErrMonitorNotFound
+	}
+	return nil
+}
+
+// TriggerMonitor makes a monitor due, a replica picks it up on its next
+// poll. It is not checked twice when a check is already running.
+func (s *monitorService) TriggerMonitor(ctx context.Context, id int, userID int) error {
+	found, err := s.monitors.Trigger(ctx, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to trigger monitor: %w", err)
+	}
+	if !found {
+		return ErrMonitorNotFound
+	}
+	return nil
+}
+
+func (s *monitorService) ListResults(ctx context.Context, id int, userID int, limit int) ([]*model.MonitorResult, error) {
+	if _, err := s.GetMonitor(ctx, id, userID); err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > maxMonitorResults {
+		limit = maxMonitorResults
+	}
+	results, err := s.monitors.GetResults(ctx, id, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list monitor results: %w", err)
+	}
+	if results == nil {
+		results = []*model.MonitorResult{}
+	}
+	return results, nil
+}
+
+// GetStats reports uptime, latency percentiles and assertion failures of a
+// monitor over one of the windows in monitorStatsWindows.
+func (s *monitorService) GetStats(ctx context.Context, id int, userID int, window string) (*model.DTOMonitorStats, error) {
+	if window == "" {
+		window = "24h"
+	}
+	span, ok := monitorStatsWindows[window]
+	if !ok {
+		return nil, fmt.Errorf("%w: window must be one of 1h, 24h, 7d or 30d", ErrInvalidInput)
+	}
+	if _, err := s.GetMonitor(ctx, id, userID); err != nil {
+		return nil, err
+	}
+
+	stats, err := s.monitors.GetStats(ctx, id, time.Now().Add(-span.length), span.bucket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get monitor stats: %w", err)
+	}
+	stats.Window = window
+	if stats.Checks > 0 {
+		uptime := 100 * float64(stats.Up) / float64(stats.Checks)
+		stats.Uptime = &uptime
+	}
+	for i := range stats.Series {
+		stats.Series[i].Uptime = 100 * float64(stats.Series[i].Up) / float64(stats.Series[i].Checks)
+	}
+	return stats, nil
+}
+
+// Start starts the scheduler and the sending of alerts. They run until
+// Shutdown.
+func (s *monitorService) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	s.cancel, s.stopped = cancel, stopped
+
+	var loops sync.WaitGroup
+	loops.Add(2)
+	go func() {
+		defer loops.Done()
+		s.schedule(ctx)
+	}()
+	go func() {
+		defer loops.Done()
+		s.deliver(ctx)
+	}()
+	go func() {
+		loops.Wait()
+		close(stopped)
+	}()
+}
+
+// Shutdown stops the scheduler and cancels the checks in progress. Their
+// monitors are released so another replica checks them right away; alerts
+// being sent are retried later.
+func (s *monitorService) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	cancel, stopped := s.cancel, s.stopped
+	s.mu.Unlock()
+	if cancel == nil {
+		return nil
+	}
+
+	cancel()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// schedule polls for due monitors and checks them, at most
+// maxConcurrentMonitors at a time.
+func (s *monitorService) schedule(ctx context.Context) {
+	var checks sync.WaitGroup
+	defer checks.Wait()
+
+	slots := make(chan struct{}, maxConcurrentMonitors)
+	ticker := time.NewTicker(monitorPollInterval)
+	defer ticker.Stop()
+	for {
+		if free := cap(slots) - len(slots); free > 0 {
+			claimCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			monitors, err := s.monitors.ClaimDue(claimCtx, free, monitorLease)
+			cancel()
+			if err != nil && ctx.Err() == nil {
+				log.Printf("failed to claim due monitors: %v", err)
+			}
+			for _, monitor := range monitors {
+				slots <- struct{}{}
+				checks.Add(1)
+				go func() {
+					defer checks.Done()
+					defer func() { <-slots }()
+					s.runCheck(ctx, monitor)
+				}()
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runCheck checks a leased monitor and stores the result. A check cut short
+// by shutdown is not recorded.
+func (s *monitorService) runCheck(ctx context.Context, monitor *model.Monitor) {
+	checkCtx, cancel := context.WithTimeout(ctx, monitorCheckTimeout)
+	defer cancel()
+
+	result := &model.MonitorResult{MonitorID: monitor.ID, StartedAt: time.Now()}
+	err := s.check(checkCtx, monitor, result)
+	result.FinishedAt = time.Now()
+
+	saveCtx, saveCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer saveCancel()
+	if ctx.Err() != nil {
+		if err := s.monitors.Release(saveCtx, monitor.ID); err != nil {
+			log.Printf("failed to release monitor %d: %v", monitor.ID, err)
+		}
+		return
+	}
+	if err != nil {
+		result.Status = model.MonitorStatusError
+		result.Error = err.Error()
+		if errors.Is(checkCtx.Err(), context.DeadlineExceeded) {
+			result.Error = fmt.Sprintf("check exceeded the time limit of %v", monitorCheckTimeout)
+		}
+	}
+
+	if result.Error != "" {
+		result.Failures = append(result.Failures, result.Error)
+	}
+
+	// The schedule was valid when it was saved; should it no longer parse,
+	// the monitor is retried after the lease instead of in a tight loop.
+	monitor.NextRunAt = time.Now().Add(monitorLease)
+	if schedule, location, err := parseSchedule(monitor.Schedule, monitor.Timezone); err == nil {
+		monitor.NextRunAt = nextMonitorRun(schedule, location, time.Now())
+	}
+	event, dedupKey := updateAlertState(monitor, result)
+	if err := s.monitors.Complete(saveCtx, monitor, result, event, dedupKey); err != nil {
+		log.Printf("failed to save result of monitor %d: %v", monitor.ID, err)
+	}
+}
+
+// check sends the requests of a monitor in order and fills in result. The
+// environment is used as saved: values scripts or extractions set only last
+// for the check, so a monitor cannot disturb the user's work.
+func (s *monitorService) check(ctx context.Context, monitor *model.Monitor, result *model.MonitorResult) error {
+	collection, err := s.collections.GetCollection(ctx, monitor.CollectionID, monitor.UserID)
+	if err != nil {
+		return err
+	}
+	paths := collectRunPaths(nil, collection.Items)
+	if monitor.ItemID != nil {
+		path := findItemPath(collection.Items, *monitor.ItemID)
+		if path == nil {
+			return ErrItemNotFound
+		}
+		paths = [][]*model.CollectionItem{path}
+		if item := path[len(path)-1]; item.Type == model.CollectionItemFolder {
+			paths = collectRunPaths(path, item.Items)
+		}
+	}
+	if len(paths) == 0 {
+		return errors.New("there are no saved requests to check")
+	}
+	if len(paths) > maxRunRequests {
+		return fmt.Errorf("a check can send at most %d requests", maxRunRequests)
+	}
+
+	var environment *model.Environment
+	if monitor.EnvironmentID != nil {
+		environment, err = s.environments.GetByID(ctx, *monitor.EnvironmentID, monitor.UserID)
+		if err != nil {
+			return fmt.Errorf("failed to load environment: %w", err)
+		}
+		if environment == nil {
+			return ErrEnvironmentNotFound
+		}
+	}
+
+	runner := &collectionRunner{
+		requests:    s.requests,
+		userID:      monitor.UserID,
+		collection:  collection,
+		environment: environment,
+		variables:   newRunVariables(collection, environment),
+		timeout:     monitor.Timeout,
+		skipHistory: true,
+	}
+	info := scriptInfo{Iteration: 0, IterationCount: 1}
+	for _, path := range paths {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		started := time.Now()
+		report := runItemReport(path, runner.runItem(ctx, path, info), started)
+		if failure := monitorFailure(&report); failure != "" && len(result.Failures) < maxMonitorFailures {
+			result.Failures = append(result.Failures, failure)
+		}
+		result.Results = append(result.Results, report)
+	}
+
+	totals := runTotals(1, len(paths), result.Results)
+	result.Requests = totals.Completed
+	result.Failed = totals.Failed
+	result.Assertions = totals.Assertions
+	result.AssertionsFailed = totals.AssertionsFailed
+	result.LatencyMs = totals.DurationMs
+	result.Status = model.MonitorStatusUp
+	if totals.Failed > 0 {
+		result.Status = model.MonitorStatusDown
+	}
+	if monitor.LatencyThresholdMs > 0 && result.LatencyMs > int64(monitor.LatencyThresholdMs) {
+		result.Status = model.MonitorStatusDown
+		result.Failures = append(result.Failures, fmt.Sprintf("latency of %d ms is above the threshold of %d ms",
+			result.LatencyMs, monitor.LatencyThresholdMs))
+	}
+	return nil
+}
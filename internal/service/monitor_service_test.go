@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/suar-net/suar-be/internal/model"
+)
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		schedule, timezone string
+		wantErr            string
+	}{
+		{"*/5 * * * *", "", ""},
+		{"* * * * *", "UTC", ""},
+		{"0 9 * * MON-FRI", "Asia/Jakarta", ""},
+		{"@hourly", "Europe/Berlin", ""},
+		{"@every 90s", "", ""},
+		{"@every 30s", "", "fires more often than once every 1m0s"},
+		{"* * * * * *", "", "invalid schedule"},
+		{"61 * * * *", "", "invalid schedule"},
+		{"0 0 30 2 *", "", "schedule never fires"},
+		{"TZ=Asia/Tokyo 0 9 * * *", "", "set the time zone with the timezone field"},
+		{" CRON_TZ=UTC 0 9 * * *", "", "set the time zone with the timezone field"},
+		{"@daily", "Mars/Olympus", "unknown time zone"},
+		{"@daily", "Local", "unknown time zone"},
+	}
+	for _, tt := range tests {
+		schedule, location, err := parseSchedule(tt.schedule, tt.timezone)
+		if tt.wantErr != "" {
+			if !errors.Is(err, ErrInvalidInput) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parseSchedule(%q, %q): err = %v, want %q", tt.schedule, tt.timezone, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || schedule == nil || location == nil {
+			t.Errorf("parseSchedule(%q, %q): err = %v", tt.schedule, tt.timezone, err)
+		}
+	}
+	if _, location, _ := parseSchedule("@daily", ""); location.String() != "UTC" {
+		t.Errorf("default time zone = %s, want UTC", location)
+	}
+}
+
+func TestNextMonitorRun(t *testing.T) {
+	tests := []struct {
+		schedule, timezone string
+		after, want        time.Time
+	}{
+		// 10:00 in Jakarta, the next 09:00 there is tomorrow.
+		{"0 9 * * *", "Asia/Jakarta", time.Date(2026, 10, 18, 3, 0, 0, 0, time.UTC), time.Date(2026, 10, 19, 2, 0, 0, 0, time.UTC)},
+		// New York leaves daylight saving time overnight, 09:00 moves an
+		// hour in UTC.
+		{"0 9 * * *", "America/New_York", time.Date(2026, 10, 31, 14, 0, 0, 0, time.UTC), time.Date(2026, 11, 1, 14, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", "UTC", time.Date(2026, 1, 1, 10, 7, 30, 0, time.UTC), time.Date(2026, 1, 1, 10, 15, 0, 0, time.UTC)},
+		{"0 0 * * MON", "UTC", time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC), time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		schedule, location, err := parseSchedule(tt.schedule, tt.timezone)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := nextMonitorRun(schedule, location, tt.after); !got.Equal(tt.want) {
+			t.Errorf("nextMonitorRun(%q in %s, %v) = %v, want %v", tt.schedule, tt.timezone, tt.after, got.UTC(), tt.want)
+		}
+	}
+}
+
+func TestMonitorCheck(t *testing.T) {
+	s := &monitorService{
+		collections: &fakeCollectionService{collection: runTestCollection()},
+		requests: &fakeRequestService{respond: func(ctx context.Context, dto *model.DTORequest) (*model.DTOResponse, error) {
+			if dto.URL == "https://shop.test/broken" {
+				return &model.DTOResponse{StatusCode: 503, Duration: 30 * time.Millisecond}, nil
+			}
+			if !dto.SkipHistory {
+				t.Errorf("monitor request to %s is saved to history", dto.URL)
+			}
+			return &model.DTOResponse{StatusCode: 200, Duration: 10 * time.Millisecond}, nil
+		}},
+	}
+	folderID, itemID, missingID := 2, 3, 99
+	tests := []struct {
+		name     string
+		monitor  model.Monitor
+		status   string
+		paths    []string
+		failures []string
+		latency  int64
+		wantErr  error
+	}{
+		{name: "collection", monitor: model.Monitor{CollectionID: 1, UserID: 1}, status: model.MonitorStatusDown,
+			paths:    []string{"Health", "Users / List", "Users / Broken"},
+			failures: []string{"Users / Broken: unexpected status 503"}, latency: 50},
+		{name: "one request", monitor: model.Monitor{CollectionID: 1, UserID: 1, ItemID: &itemID}, status: model.MonitorStatusUp,
+			paths: []string{"Users / List"}, latency: 10},
+		{name: "folder", monitor: model.Monitor{CollectionID: 1, UserID: 1, ItemID: &folderID}, status: model.MonitorStatusDown,
+			paths:    []string{"Users / List", "Users / Broken"},
+			failures: []string{"Users / Broken: unexpected status 503"}, latency: 40},
+		{name: "too slow", monitor: model.Monitor{CollectionID: 1, UserID: 1, ItemID: &itemID, LatencyThresholdMs: 5}, status: model.MonitorStatusDown,
+			paths: []string{"Users / List"}, failures: []string{"latency of 10 ms is above the threshold of 5 ms"}, latency: 10},
+		{name: "deleted item", monitor: model.Monitor{CollectionID: 1, UserID: 1, ItemID: &missingID}, wantErr: ErrItemNotFound},
+		{name: "deleted collection", monitor: model.Monitor{CollectionID: 2, UserID: 1}, wantErr: ErrCollectionNotFound},
+	}
+	for _, tt := range tests {
+		result := &model.MonitorResult{}
+		err := s.check(context.Background(), &tt.monitor, result)
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("%s: err = %v, want %v", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		var paths []string
+		for _, report := range result.Results {
+			paths = append(paths, report.Path)
+		}
+		if result.Status != tt.status || !reflect.DeepEqual(paths, tt.paths) || !reflect.DeepEqual(result.Failures, tt.failures) ||
+			result.LatencyMs != tt.latency || result.Requests != len(tt.paths) {
+			t.Errorf("%s: result = %+v", tt.name, result)
+		}
+	}
+}
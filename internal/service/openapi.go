@@ -0,0 +1,746 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/suar-net/suar-be/internal/model"
+	"gopkg.in/yaml.v3"
+)
+
+// openAPIMaxSchemaDepth bounds example generation for deeply nested schemas.
+const openAPIMaxSchemaDepth = 8
+
+// openAPIMaxExampleNodes bounds the number of values in one generated
+// example, a schema referencing another one from many places would otherwise
+// grow exponentially with the depth.
+const openAPIMaxExampleNodes = 5000
+
+// openAPIPathParam matches {name} templates in OpenAPI paths and server URLs.
+var openAPIPathParam = regexp.MustCompile(`\{([^{}/]+)\}`)
+
+// parseOpenAPIDocument decodes an OpenAPI 3.x document given as JSON or YAML.
+// It also returns the document as JSON.
+func parseOpenAPIDocument(data []byte) (*model.OpenAPIDocument, []byte, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, nil, fmt.Errorf("%w: the document is empty", ErrInvalidInput)
+	}
+
+	if data[0] != '{' {
+		// YAML is decoded generically and re-encoded as JSON so the document
+		// types only need JSON tags.
+		var generic any
+		if err := yaml.Unmarshal(data, &generic); err != nil {
+			return nil, nil, fmt.Errorf("%w: invalid YAML: %v", ErrInvalidInput, err)
+		}
+		converted, err := json.Marshal(yamlToJSONValue(generic))
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: unsupported YAML content: %v", ErrInvalidInput, err)
+		}
+		data = converted
+	}
+
+	var doc model.OpenAPIDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, nil, fmt.Errorf("%w: invalid OpenAPI document: %v", ErrInvalidInput, err)
+	}
+	if doc.Swagger != "" {
+		return nil, nil, fmt.Errorf("%w: Swagger %s documents are not supported, convert the document to OpenAPI 3 first", ErrInvalidInput, doc.Swagger)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		return nil, nil, fmt.Errorf("%w: not an OpenAPI 3 document", ErrInvalidInput)
+	}
+	return &doc, data, nil
+}
+
+// yamlToJSONValue converts maps with non string keys, such as unquoted
+// response codes, into maps JSON can encode.
+func yamlToJSONValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, element := range v {
+			v[key] = yamlToJSONValue(element)
+		}
+		return v
+	case map[any]any:
+		converted := make(map[string]any, len(v))
+		for key, element := range v {
+			converted[fmt.Sprint(key)] = yamlToJSONValue(element)
+		}
+		return converted
+	case []any:
+		for i, element := range v {
+			v[i] = yamlToJSONValue(element)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// openAPIConverter generates a collection from an OpenAPI document. Variables
+// referenced by the generated requests are collected as collection variables.
+type openAPIConverter struct {
+	doc          *model.OpenAPIDocument
+	warnings     *importWarnings
+	variables    []model.Variable
+	variableKeys map[string]bool
+
+	// refExamples memoizes the example of each referenced schema, expanding
+	// holds the references being generated, nodes is what is left of the
+	// node budget of the current example.
+	refExamples map[string]refExample
+	expanding   map[string]bool
+	nodes       int
+}
+
+// refExample is the generated example of a referenced schema and the number
+// of values it contains.
+type refExample struct {
+	value any
+	nodes int
+}
+
+// openAPIToNative converts an OpenAPI document into a collection with one
+// request per operation, grouped in a folder per tag, and an environment per
+// server holding the server URL as the baseUrl variable. Each request gets a
+// contract naming its operation, the caller fills in the spec ID.
+func openAPIToNative(doc *model.OpenAPIDocument, warnings *importWarnings) (*model.Collection, []*model.Environment) {
+	c := &openAPIConverter{
+		doc:          doc,
+		warnings:     warnings,
+		variableKeys: make(map[string]bool),
+		refExamples:  make(map[string]refExample),
+		expanding:    make(map[string]bool),
+	}
+
+	name := strings.TrimSpace(doc.Info.Title)
+	if name == "" {
+		name = "OpenAPI import"
+	}
+	collection := &model.Collection{
+		Name:        name,
+		Description: doc.Info.Description,
+	}
+
+	var environments []*model.Environment
+	if len(doc.Servers) == 0 {
+		c.addVariable(model.Variable{Key: "baseUrl"})
+		warnings.add("the document declares no servers, set the baseUrl variable before sending requests")
+	}
+	for i, server := range doc.Servers {
+		variables := openAPIServerVariables(server)
+		if !strings.Contains(server.URL, "://") {
+			warnings.add("server URL %q is relative, set baseUrl to an absolute URL", server.URL)
+		}
+		if i == 0 {
+			for _, variable := range variables {
+				c.addVariable(variable)
+			}
+		}
+		label := server.Description
+		if label == "" {
+			label = server.URL
+		}
+		environments = append(environments, &model.Environment{
+			Name:      name + " - " + label,
+			Variables: variables,
+		})
+	}
+
+	collection.Auth = c.securityToAuth(doc.Security, name)
+	collection.Items = c.operationsToItems(collection.Auth)
+	collection.Variables = c.variables
+	if collection.Variables == nil {
+		collection.Variables = []model.Variable{}
+	}
+	return collection, environments
+}
+
+// openAPIServerVariables returns baseUrl and the defaults of the server
+// variables, which the URL references as {{name}}.
+func openAPIServerVariables(server model.OpenAPIServer) []model.Variable {
+	variables := []model.Variable{{
+		Key:   "baseUrl",
+		Value: strings.TrimSuffix(openAPIPathParam.ReplaceAllString(server.URL, "{{$1}}"), "/"),
+	}}
+	for _, name := range sortedKeys(server.Variables) {
+		variables = append(variables, model.Variable{Key: name, Value: server.Variables[name].Default})
+	}
+	return variables
+}
+
+// addVariable adds a collection variable unless one with the same key exists.
+func (c *openAPIConverter) addVariable(variable model.Variable) {
+	if c.variableKeys[variable.Key] {
+		return
+	}
+	c.variableKeys[variable.Key] = true
+	c.variables = append(c.variables, variable)
+}
+
+func (c *openAPIConverter) operationsToItems(collectionAuth *model.RequestAuth) []*model.CollectionItem {
+	folders := make(map[string]*model.CollectionItem)
+	var folderOrder []string
+	folder := func(tag string) *model.CollectionItem {
+		if item, ok := folders[tag]; ok {
+			return item
+		}
+		item := &model.CollectionItem{Type: model.CollectionItemFolder, Name: tag}
+		folders[tag] = item
+		folderOrder = append(folderOrder, tag)
+		return item
+	}
+	// Declared tags keep their order, others follow in order of appearance.
+	for _, tag := range c.doc.Tags {
+		folder(tag.Name).Description = tag.Description
+	}
+
+	var untagged []*model.CollectionItem
+	for _, path := range sortedKeys(c.doc.Paths) {
+		pathItem := c.doc.Paths[path]
+		if pathItem == nil {
+			continue
+		}
+		if pathItem.Ref != "" {
+			c.warnings.add("%s: referenced path items are not supported and were skipped", path)
+			continue
+		}
+		methods, operations := pathItem.Operations()
+		for i, operation := range operations {
+			item := c.operationToItem(path, methods[i], pathItem, operation, collectionAuth)
+			if len(operation.Tags) == 0 {
+				untagged = append(untagged, item)
+				continue
+			}
+			parent := folder(operation.Tags[0])
+			parent.Items = append(parent.Items, item)
+		}
+	}
+
+	var items []*model.CollectionItem
+	for _, tag := range folderOrder {
+		if len(folders[tag].Items) > 0 {
+			items = append(items, folders[tag])
+		}
+	}
+	return append(items, untagged...)
+}
+
+func (c *openAPIConverter) operationToItem(path, method string, pathItem *model.OpenAPIPathItem, operation *model.OpenAPIOperation, collectionAuth *model.RequestAuth) *model.CollectionItem {
+	name := operation.Summary
+	if name == "" {
+		name = operation.OperationID
+	}
+	if name == "" {
+		name = method + " " + path
+	}
+	itemPath := method + " " + path
+	if operation.Deprecated {
+		name += " (deprecated)"
+	}
+
+	request := &model.CollectionRequest{
+		Method: method,
+		URL:    "{{baseUrl}}" + openAPIPathParam.ReplaceAllString(path, "{{$1}}"),
+	}
+
+	var query []string
+	for _, parameter := range c.mergeParameters(pathItem.Parameters, operation.Parameters, itemPath) {
+		value, explicit := c.parameterExample(parameter)
+		switch parameter.In {
+		case "path":
+			variable := model.Variable{Key: parameter.Name}
+			if explicit {
+				variable.Value = openAPIParameterString(value)
+			}
+			c.addVariable(variable)
+		case "query":
+			if !parameter.Required && !explicit {
+				continue
+			}
+			query = append(query, openAPIQueryPairs(parameter.Name, value, explicit)...)
+		case "header":
+			// Accept, Content-Type and Authorization are described by other
+			// parts of the document and ignored as parameters.
+			switch strings.ToLower(parameter.Name) {
+			case "accept", "content-type", "authorization":
+				continue
+			}
+			header := model.KeyValue{Key: parameter.Name, Value: "{{" + parameter.Name + "}}", Disabled: !parameter.Required}
+			if explicit {
+				header.Value = openAPIParameterString(value)
+			}
+			request.Headers = append(request.Headers, header)
+		case "cookie":
+			c.warnings.add("%s: cookie parameter %q is not supported and was skipped", itemPath, parameter.Name)
+		}
+	}
+	if len(query) > 0 {
+		request.URL += "?" + strings.Join(query, "&")
+	}
+
+	if operation.RequestBody != nil {
+		request.Body = c.requestBodyToNative(operation.RequestBody, itemPath)
+	}
+
+	item := &model.CollectionItem{
+		Type:        model.CollectionItemRequest,
+		Name:        name,
+		Description: operation.Description,
+		Request:     request,
+		Contract:    &model.Contract{Operation: method + " " + path},
+	}
+	if operation.Security != nil {
+		auth := c.securityToAuth(*operation.Security, itemPath)
+		switch {
+		case auth == nil && collectionAuth != nil:
+			item.Auth = &model.RequestAuth{Type: model.AuthNone}
+		case auth != nil && (collectionAuth == nil || *auth != *collectionAuth):
+			item.Auth = auth
+		}
+	}
+	return item
+}
+
+// mergeParameters resolves references and lets operation parameters override
+// path item parameters with the same name and location.
+func (c *openAPIConverter) mergeParameters(pathParameters, operationParameters []*model.OpenAPIParameter, path string) []*model.OpenAPIParameter {
+	var merged []*model.OpenAPIParameter
+	index := make(map[string]int)
+	for _, list := range [][]*model.OpenAPIParameter{pathParameters, operationParameters} {
+		for _, parameter := range list {
+			parameter = c.resolveParameter(parameter, path)
+			if parameter == nil {
+				continue
+			}
+			key := parameter.In + ":" + parameter.Name
+			if i, ok := index[key]; ok {
+				merged[i] = parameter
+				continue
+			}
+			index[key] = len(merged)
+			merged = append(merged, parameter)
+		}
+	}
+	return merged
+}
+
+// parameterExample returns the example value of a parameter and whether the
+// document gives one, as opposed to a value generated from its type.
+func (c *openAPIConverter) parameterExample(parameter *model.OpenAPIParameter) (any, bool) {
+	if parameter.Example != nil {
+		return parameter.Example, true
+	}
+	if value, ok := c.firstExample(parameter.Examples); ok {
+		return value, true
+	}
+	schema := c.resolveSchema(parameter.Schema)
+	if schema == nil {
+		return nil, false
+	}
+	for _, value := range []any{schema.Example, schema.Default, schema.Const} {
+		if value != nil {
+			return value, true
+		}
+	}
+	if len(schema.Examples) > 0 {
+		return schema.Examples[0], true
+	}
+	if len(schema.Enum) > 0 {
+		return schema.Enum[0], true
+	}
+	return nil, false
+}
+
+func openAPIParameterString(value any) string {
+	if list, ok := value.([]any); ok {
+		parts := make([]string, len(list))
+		for i, element := range list {
+			parts[i] = scalarString(element)
+		}
+		return strings.Join(parts, ",")
+	}
+	return scalarString(value)
+}
+
+// openAPIQueryPairs encodes a query parameter, repeating the name for array
+// values as the default form style does. Without an example the value is a
+// {{name}} variable.
+func openAPIQueryPairs(name string, value any, explicit bool) []string {
+	key := url.QueryEscape(name)
+	if !explicit {
+		return []string{key + "={{" + name + "}}"}
+	}
+	if list, ok := value.([]any); ok {
+		pairs := make([]string, len(list))
+		for i, element := range list {
+			pairs[i] = key + "=" + url.QueryEscape(scalarString(element))
+		}
+		return pairs
+	}
+	return []string{key + "=" + url.QueryEscape(scalarString(value))}
+}
+
+func (c *openAPIConverter) requestBodyToNative(body *model.OpenAPIRequestBody, path string) *model.RequestBody {
+	body = c.resolveRequestBody(body, path)
+	if body == nil || len(body.Content) == 0 {
+		return nil
+	}
+
+	mediaType := openAPIPreferredMediaType(body.Content)
+	media := body.Content[mediaType]
+	if media == nil {
+		return nil
+	}
+	schema := c.resolveSchema(media.Schema)
+	value := media.Example
+	if value == nil {
+		value, _ = c.firstExample(media.Examples)
+	}
+	if value == nil {
+		value = c.example(media.Schema)
+	}
+
+	switch {
+	case mediaType == "application/x-www-form-urlencoded":
+		native := &model.RequestBody{Mode: model.BodyModeURLEncoded}
+		object, _ := value.(map[string]any)
+		for _, key := range sortedKeys(object) {
+			native.URLEncoded = append(native.URLEncoded, model.KeyValue{Key: key, Value: openAPIParameterString(object[key])})
+		}
+		return native
+	case mediaType == "multipart/form-data":
+		native := &model.RequestBody{Mode: model.BodyModeFormData}
+		object, _ := value.(map[string]any)
+		for _, key := range sortedKeys(object) {
+			field := model.FormField{Key: key, Value: openAPIParameterString(object[key]), Type: "text"}
+			if schema != nil {
+				if property := c.resolveSchema(schema.Properties[key]); property != nil && (property.Format == "binary" || property.Format == "base64") {
+					field.Type, field.Value = "file", ""
+				}
+			}
+			native.FormData = append(native.FormData, field)
+		}
+		return native
+	case openAPIIsJSON(mediaType):
+		raw := ""
+		if value != nil {
+			data, _ := json.MarshalIndent(value, "", "  ")
+			raw = string(data)
+		}
+		return &model.RequestBody{Mode: model.BodyModeRaw, Raw: raw, ContentType: mediaType}
+	default:
+		raw, ok := value.(string)
+		if !ok && value != nil {
+			c.warnings.add("%s: no example body could be generated for %s", path, mediaType)
+		}
+		return &model.RequestBody{Mode: model.BodyModeRaw, Raw: raw, ContentType: mediaType}
+	}
+}
+
+// openAPIPreferredMediaType picks JSON over forms over any other media type.
+func openAPIPreferredMediaType(content map[string]*model.OpenAPIMediaType) string {
+	mediaTypes := sortedKeys(content)
+	for _, preferred := range []func(string) bool{
+		func(m string) bool { return m == "application/json" },
+		openAPIIsJSON,
+		func(m string) bool { return m == "application/x-www-form-urlencoded" },
+		func(m string) bool { return m == "multipart/form-data" },
+	} {
+		for _, mediaType := range mediaTypes {
+			if preferred(mediaType) {
+				return mediaType
+			}
+		}
+	}
+	return mediaTypes[0]
+}
+
+func openAPIIsJSON(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// firstExample returns the value of the first named example, by name.
+func (c *openAPIConverter) firstExample(examples map[string]*model.OpenAPIExample) (any, bool) {
+	for _, name := range sortedKeys(examples) {
+		example := examples[name]
+		for depth := 0; example != nil && example.Ref != "" && depth < openAPIMaxSchemaDepth; depth++ {
+			example = c.doc.Components.Examples[openAPIRefName(example.Ref, "examples")]
+		}
+		if example != nil && example.Value != nil {
+			return example.Value, true
+		}
+	}
+	return nil, false
+}
+
+// example builds an example value from a schema with a fresh node budget.
+func (c *openAPIConverter) example(schema *model.OpenAPISchema) any {
+	c.nodes = openAPIMaxExampleNodes
+	return c.schemaExample(schema, 0)
+}
+
+// schemaExample builds an example value from a schema. Explicit examples and
+// defaults win over values generated from the type. Referenced schemas are
+// generated once and reused, a reference to a schema that is being generated
+// is left out so recursive schemas terminate. Once the node budget is spent
+// the remaining values are left out.
+func (c *openAPIConverter) schemaExample(schema *model.OpenAPISchema, depth int) any {
+	if schema == nil || depth > openAPIMaxSchemaDepth || c.nodes <= 0 {
+		return nil
+	}
+	if schema.Ref != "" {
+		return c.refSchemaExample(schema)
+	}
+	c.nodes--
+
+	for _, value := range []any{schema.Example, schema.Const, schema.Default} {
+		if value != nil {
+			return value
+		}
+	}
+	if len(schema.Examples) > 0 {
+		return schema.Examples[0]
+	}
+	if len(schema.Enum) > 0 {
+		return schema.Enum[0]
+	}
+	if len(schema.AllOf) > 0 {
+		merged := make(map[string]any)
+		var last any
+		for _, part := range schema.AllOf {
+			last = c.schemaExample(part, depth+1)
+			if object, ok := last.(map[string]any); ok {
+				for key, value := range object {
+					merged[key] = value
+				}
+			}
+		}
+		if len(merged) == 0 && last != nil {
+			return last
+		}
+		return merged
+	}
+	for _, alternatives := range [][]*model.OpenAPISchema{schema.OneOf, schema.AnyOf} {
+		if len(alternatives) > 0 {
+			return c.schemaExample(alternatives[0], depth+1)
+		}
+	}
+
+	schemaType := schema.Type.Primary()
+	if schemaType == "" {
+		switch {
+		case schema.Properties != nil:
+			schemaType = "object"
+		case schema.Items != nil:
+			schemaType = "array"
+		}
+	}
+	switch schemaType {
+	case "object":
+		object := make(map[string]any)
+		for name, property := range schema.Properties {
+			if resolved := c.resolveSchema(property); resolved != nil && resolved.ReadOnly {
+				continue
+			}
+			// Recursive and untyped properties have no example and are left out.
+			if value := c.schemaExample(property, depth+1); value != nil {
+				object[name] = value
+			}
+		}
+		return object
+	case "array":
+		item := c.schemaExample(schema.Items, depth+1)
+		if item == nil {
+			return []any{}
+		}
+		return []any{item}
+	case "string":
+		return openAPIStringExample(schema.Format)
+	case "integer", "number":
+		return 0
+	case "boolean":
+		return true
+	default:
+		return nil
+	}
+}
+
+// refSchemaExample returns the memoized example of a referenced schema, and
+// charges its size to the node budget. The depth starts over at the
+// referenced schema so the memoized value does not depend on where the
+// reference was first met.
+func (c *openAPIConverter) refSchemaExample(schema *model.OpenAPISchema) any {
+	if c.expanding[schema.Ref] {
+		return nil
+	}
+	if memo, ok := c.refExamples[schema.Ref]; ok {
+		if memo.nodes > c.nodes {
+			c.nodes = 0
+			return nil
+		}
+		c.nodes -= memo.nodes
+		return memo.value
+	}
+
+	c.expanding[schema.Ref] = true
+	before := c.nodes
+	value := c.schemaExample(c.resolveSchema(schema), 0)
+	delete(c.expanding, schema.Ref)
+	// A value cut short by the budget is not kept, another example with a
+	// fresh budget generates it again.
+	if c.nodes > 0 {
+		c.refExamples[schema.Ref] = refExample{value: value, nodes: before - c.nodes}
+	}
+	return value
+}
+
+func openAPIStringExample(format string) string {
+	switch format {
+	case "date-time":
+		return "2024-01-01T00:00:00Z"
+	case "date":
+		return "2024-01-01"
+	case "time":
+		return "00:00:00"
+	case "email":
+		return "user@example.com"
+	case "uuid":
+		return "00000000-0000-0000-0000-000000000000"
+	case "uri", "url":
+		return "https://example.com"
+	case "hostname":
+		return "example.com"
+	case "ipv4":
+		return "192.0.2.1"
+	case "ipv6":
+		return "2001:db8::1"
+	case "binary", "byte", "base64", "password":
+		return ""
+	default:
+		return "string"
+	}
+}
+
+// securityToAuth maps the first usable security requirement to an auth
+// helper. Secrets are left as variables that are added to the collection.
+func (c *openAPIConverter) securityToAuth(requirements []model.OpenAPISecurityRequirement, path string) *model.RequestAuth {
+	for _, requirement := range requirements {
+		// An empty requirement makes authentication optional.
+		if len(requirement) == 0 {
+			return nil
+		}
+		names := sortedKeys(requirement)
+		if len(names) > 1 {
+			c.warnings.add("%s: only the %s security scheme of a combined requirement is applied", path, names[0])
+		}
+		if auth := c.securitySchemeToAuth(names[0], path); auth != nil {
+			return auth
+		}
+	}
+	return nil
+}
+
+func (c *openAPIConverter) securitySchemeToAuth(name, path string) *model.RequestAuth {
+	scheme := c.doc.Components.SecuritySchemes[name]
+	for depth := 0; scheme != nil && scheme.Ref != "" && depth < openAPIMaxSchemaDepth; depth++ {
+		scheme = c.doc.Components.SecuritySchemes[openAPIRefName(scheme.Ref, "securitySchemes")]
+	}
+	if scheme == nil {
+		c.warnings.add("%s: security scheme %q is not defined", path, name)
+		return nil
+	}
+
+	switch scheme.Type {
+	case "http":
+		switch strings.ToLower(scheme.Scheme) {
+		case "basic":
+			c.addVariable(model.Variable{Key: "username"})
+			c.addVariable(model.Variable{Key: "password", Secret: true})
+			return &model.RequestAuth{Type: model.AuthBasic, Username: "{{username}}", Password: "{{password}}"}
+		case "bearer":
+			c.addVariable(model.Variable{Key: name, Secret: true})
+			return &model.RequestAuth{Type: model.AuthBearer, Token: "{{" + name + "}}"}
+		}
+		c.warnings.add("%s: HTTP auth scheme %q is not supported", path, scheme.Scheme)
+		return nil
+	case "apiKey":
+		if scheme.In != "header" && scheme.In != "query" {
+			c.warnings.add("%s: API keys sent in a %s are not supported", path, scheme.In)
+			return nil
+		}
+		c.addVariable(model.Variable{Key: name, Secret: true})
+		return &model.RequestAuth{Type: model.AuthAPIKey, Key: scheme.Name, Value: "{{" + name + "}}", In: scheme.In}
+	case "oauth2", "openIdConnect":
+		c.addVariable(model.Variable{Key: name, Secret: true})
+		c.warnings.add("%s: %s flows are not run, put an access token in the %s variable", path, scheme.Type, name)
+		return &model.RequestAuth{Type: model.AuthBearer, Token: "{{" + name + "}}"}
+	default:
+		c.warnings.add("%s: security scheme type %q is not supported", path, scheme.Type)
+		return nil
+	}
+}
+
+// openAPIRefName returns the component name of a local reference such as
+// #/components/schemas/Pet, or "" for any other reference.
+func openAPIRefName(ref, kind string) string {
+	name, ok := strings.CutPrefix(ref, "#/components/"+kind+"/")
+	if !ok || strings.Contains(name, "/") {
+		return ""
+	}
+	return strings.NewReplacer("~1", "/", "~0", "~").Replace(name)
+}
+
+func (c *openAPIConverter) resolveSchema(schema *model.OpenAPISchema) *model.OpenAPISchema {
+	for depth := 0; schema != nil && schema.Ref != "" && depth < openAPIMaxSchemaDepth; depth++ {
+		schema = c.doc.Components.Schemas[openAPIRefName(schema.Ref, "schemas")]
+	}
+	if schema != nil && schema.Ref != "" {
+		return nil
+	}
+	return schema
+}
+
+func (c *openAPIConverter) resolveParameter(parameter *model.OpenAPIParameter, path string) *model.OpenAPIParameter {
+	for depth := 0; parameter != nil && parameter.Ref != "" && depth < openAPIMaxSchemaDepth; depth++ {
+		ref := parameter.Ref
+		parameter = c.doc.Components.Parameters[openAPIRefName(ref, "parameters")]
+		if parameter == nil {
+			c.warnings.add("%s: parameter reference %s could not be resolved", path, ref)
+		}
+	}
+	if parameter != nil && parameter.Ref != "" {
+		return nil
+	}
+	return parameter
+}
+
+func (c *openAPIConverter) resolveRequestBody(body *model.OpenAPIRequestBody, path string) *model.OpenAPIRequestBody {
+	for depth := 0; body != nil && body.Ref != "" && depth < openAPIMaxSchemaDepth; depth++ {
+		ref := body.Ref
+		body = c.doc.Components.RequestBodies[openAPIRefName(ref, "requestBodies")]
+		if body == nil {
+			c.warnings.add("%s: request body reference %s could not be resolved", path, ref)
+		}
+	}
+	if body != nil && body.Ref != "" {
+		return nil
+	}
+	return body
+}
@@ -0,0 +1,312 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/suar-net/suar-be/internal/model"
+)
+
+func TestParseOpenAPIDocument(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{"json", `{"openapi": "3.0.3", "info": {"title": "A"}, "paths": {}}`, false},
+		{"yaml with numeric keys", "openapi: 3.1.0\ninfo:\n  title: A\npaths:\n  /a:\n    get:\n      responses:\n        200:\n          description: ok\n", false},
+		{"empty", "  ", true},
+		{"swagger", `{"swagger": "2.0"}`, true},
+		{"not openapi", `{"info": {}}`, true},
+		{"invalid yaml", "a: [", true},
+	}
+	for _, tt := range tests {
+		doc, data, err := parseOpenAPIDocument([]byte(tt.data))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidInput) {
+				t.Errorf("%s: err = %v, want ErrInvalidInput", tt.name, err)
+			}
+			continue
+		}
+		if doc.Info.Title != "A" || !json.Valid(data) {
+			t.Errorf("%s: doc = %+v, data %s", tt.name, doc, data)
+		}
+	}
+}
+
+func TestOpenAPIRefName(t *testing.T) {
+	tests := []struct {
+		ref, kind, want string
+	}{
+		{"#/components/schemas/Pet", "schemas", "Pet"},
+		{"#/components/schemas/a~1b~0c", "schemas", "a/b~c"},
+		{"#/components/parameters/Pet", "schemas", ""},
+		{"#/components/schemas/Pet/properties/id", "schemas", ""},
+		{"other.yaml#/components/schemas/Pet", "schemas", ""},
+		{"file:///etc/passwd", "schemas", ""},
+	}
+	for _, tt := range tests {
+		if got := openAPIRefName(tt.ref, tt.kind); got != tt.want {
+			t.Errorf("openAPIRefName(%q, %q) = %q, want %q", tt.ref, tt.kind, got, tt.want)
+		}
+	}
+}
+
+// converterFor returns a converter for a document with the given schemas.
+func converterFor(t *testing.T, schemas string) *openAPIConverter {
+	t.Helper()
+	doc, _, err := parseOpenAPIDocument([]byte(`{"openapi": "3.1.0", "info": {"title": "T"}, "components": {"schemas": ` + schemas + `}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var warnings importWarnings
+	return &openAPIConverter{doc: doc, warnings: &warnings, variableKeys: map[string]bool{},
+		refExamples: map[string]refExample{}, expanding: map[string]bool{}}
+}
+
+func TestSchemaExample(t *testing.T) {
+	schemas := `{
+		"Pet": {"type": "object", "properties": {
+			"id": {"type": "integer", "readOnly": true},
+			"name": {"type": "string", "example": "Rex"},
+			"tags": {"type": "array", "items": {"$ref": "#/components/schemas/Tag"}},
+			"owner": {"$ref": "#/components/schemas/Owner"}
+		}},
+		"Tag": {"type": "string", "enum": ["a", "b"]},
+		"Owner": {"allOf": [{"type": "object", "properties": {"email": {"type": "string", "format": "email"}}},
+			{"type": "object", "properties": {"since": {"type": "string", "format": "date"}}}]},
+		"Node": {"type": "object", "properties": {"value": {"type": "number"}, "next": {"$ref": "#/components/schemas/Node"}}},
+		"Loop": {"$ref": "#/components/schemas/Loop"}
+	}`
+	tests := []struct {
+		name   string
+		schema string
+		want   string
+	}{
+		{"string", `{"type": "string"}`, `"string"`},
+		{"nullable type list", `{"type": ["null", "integer"]}`, `0`},
+		{"format", `{"type": "string", "format": "uuid"}`, `"00000000-0000-0000-0000-000000000000"`},
+		{"example wins", `{"type": "integer", "example": 7, "default": 3}`, `7`},
+		{"const", `{"const": "fixed"}`, `"fixed"`},
+		{"examples list", `{"type": "string", "examples": ["first", "second"]}`, `"first"`},
+		{"oneOf takes the first", `{"oneOf": [{"type": "boolean"}, {"type": "string"}]}`, `true`},
+		{"untyped properties", `{"properties": {"a": {"type": "string"}}}`, `{"a":"string"}`},
+		{"array of untyped", `{"type": "array", "items": {}}`, `[]`},
+		{"refs and read only", `{"$ref": "#/components/schemas/Pet"}`,
+			`{"name":"Rex","owner":{"email":"user@example.com","since":"2024-01-01"},"tags":["a"]}`},
+		{"recursive", `{"$ref": "#/components/schemas/Node"}`, `{"value":0}`},
+		{"self reference", `{"$ref": "#/components/schemas/Loop"}`, `null`},
+		{"unknown ref", `{"$ref": "#/components/schemas/Missing"}`, `null`},
+		{"external ref", `{"$ref": "https://evil.test/schema.json"}`, `null`},
+	}
+	for _, tt := range tests {
+		c := converterFor(t, schemas)
+		var schema model.OpenAPISchema
+		if err := json.Unmarshal([]byte(tt.schema), &schema); err != nil {
+			t.Fatal(err)
+		}
+		got, _ := json.Marshal(c.example(&schema))
+		if string(got) != tt.want {
+			t.Errorf("%s: example = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+// countExampleNodes counts the values of a generated example the way the
+// node budget does.
+func countExampleNodes(value any) int {
+	switch v := value.(type) {
+	case map[string]any:
+		n := 1
+		for _, element := range v {
+			n += countExampleNodes(element)
+		}
+		return n
+	case []any:
+		n := 1
+		for _, element := range v {
+			n += countExampleNodes(element)
+		}
+		return n
+	case nil:
+		return 0
+	default:
+		return 1
+	}
+}
+
+func TestSchemaExampleSharedReferences(t *testing.T) {
+	// Every level references the next one from forty properties, a naive
+	// expansion visits 40^10 schemas.
+	var b strings.Builder
+	b.WriteString("{")
+	for level := 0; level < 10; level++ {
+		fmt.Fprintf(&b, `"L%d": {"type": "object", "properties": {`, level)
+		for p := 0; p < 40; p++ {
+			if p > 0 {
+				b.WriteString(",")
+			}
+			if level == 9 {
+				fmt.Fprintf(&b, `"p%d": {"type": "string"}`, p)
+			} else {
+				fmt.Fprintf(&b, `"p%d": {"$ref": "#/components/schemas/L%d"}`, p, level+1)
+			}
+		}
+		b.WriteString("}},")
+	}
+	b.WriteString(`"Small": {"type": "object", "properties": {"a": {"$ref": "#/components/schemas/L8"}}}}`)
+	c := converterFor(t, b.String())
+
+	start := time.Now()
+	value := c.example(&model.OpenAPISchema{Ref: "#/components/schemas/L0"})
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("generating the example took %v", elapsed)
+	}
+	if n := countExampleNodes(value); n == 0 || n > openAPIMaxExampleNodes {
+		t.Errorf("example has %d values, want between 1 and %d", n, openAPIMaxExampleNodes)
+	}
+
+	// The deepest levels fit in the budget and are reused by later examples.
+	if _, ok := c.refExamples["#/components/schemas/L8"]; !ok {
+		t.Error("the example of L8 was not memoized")
+	}
+	small, _ := c.example(&model.OpenAPISchema{Ref: "#/components/schemas/Small"}).(map[string]any)
+	if n := countExampleNodes(small); n != 1+1+40*41 {
+		t.Errorf("example with a fresh budget has %d values, want %d", n, 1+1+40*41)
+	}
+}
+
+func TestOpenAPIQueryPairs(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    any
+		explicit bool
+		want     []string
+	}{
+		{"page", nil, false, []string{"page={{page}}"}},
+		{"q", "a b&c", true, []string{"q=a+b%26c"}},
+		{"id", []any{1.0, 2.0}, true, []string{"id=1", "id=2"}},
+		{"on", true, true, []string{"on=true"}},
+	}
+	for _, tt := range tests {
+		if got := openAPIQueryPairs(tt.name, tt.value, tt.explicit); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("openAPIQueryPairs(%q, %v) = %q, want %q", tt.name, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestOpenAPIPreferredMediaType(t *testing.T) {
+	media := func(types ...string) map[string]*model.OpenAPIMediaType {
+		content := map[string]*model.OpenAPIMediaType{}
+		for _, mediaType := range types {
+			content[mediaType] = &model.OpenAPIMediaType{}
+		}
+		return content
+	}
+	tests := []struct {
+		content map[string]*model.OpenAPIMediaType
+		want    string
+	}{
+		{media("text/plain", "application/json", "application/xml"), "application/json"},
+		{media("application/xml", "application/vnd.api+json"), "application/vnd.api+json"},
+		{media("multipart/form-data", "application/x-www-form-urlencoded"), "application/x-www-form-urlencoded"},
+		{media("text/plain", "application/xml"), "application/xml"},
+	}
+	for _, tt := range tests {
+		if got := openAPIPreferredMediaType(tt.content); got != tt.want {
+			t.Errorf("openAPIPreferredMediaType(%v) = %q, want %q", sortedKeys(tt.content), got, tt.want)
+		}
+	}
+}
+
+func TestOpenAPIToNative(t *testing.T) {
+	doc, _, err := parseOpenAPIDocument([]byte(`
+openapi: 3.0.3
+info: {title: Pets, version: "1"}
+servers:
+  - url: https://{region}.pets.test/v1/
+    description: Production
+    variables: {region: {default: eu}}
+security: [{token: []}]
+tags: [{name: pets, description: Pet operations}]
+paths:
+  /pets/{petId}:
+    parameters:
+      - {name: petId, in: path, required: true, schema: {type: integer, example: 5}}
+    get:
+      tags: [pets]
+      summary: Get a pet
+      parameters:
+        - {name: fields, in: query, schema: {type: string}}
+        - {name: X-Trace, in: header, schema: {type: string}}
+        - {name: session, in: cookie, schema: {type: string}}
+    put:
+      tags: [pets]
+      operationId: updatePet
+      security: []
+      requestBody:
+        content:
+          application/json:
+            schema: {$ref: "#/components/schemas/Pet"}
+  /health:
+    get: {deprecated: true}
+components:
+  securitySchemes:
+    token: {type: http, scheme: bearer}
+  schemas:
+    Pet: {type: object, properties: {name: {type: string}}}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var warnings importWarnings
+	collection, environments := openAPIToNative(doc, &warnings)
+
+	if len(environments) != 1 || environments[0].Name != "Pets - Production" ||
+		!reflect.DeepEqual(environments[0].Variables, []model.Variable{{Key: "baseUrl", Value: "https://{{region}}.pets.test/v1"}, {Key: "region", Value: "eu"}}) {
+		t.Errorf("environments = %+v", environments)
+	}
+	if collection.Auth == nil || collection.Auth.Type != model.AuthBearer || collection.Auth.Token != "{{token}}" {
+		t.Errorf("collection auth = %+v", collection.Auth)
+	}
+	if len(collection.Items) != 2 || collection.Items[0].Name != "pets" || collection.Items[0].Description != "Pet operations" {
+		t.Fatalf("items = %+v", collection.Items)
+	}
+
+	get, put := collection.Items[0].Items[0], collection.Items[0].Items[1]
+	if get.Name != "Get a pet" || get.Request.URL != "{{baseUrl}}/pets/{{petId}}" || get.Contract.Operation != "GET /pets/{petId}" {
+		t.Errorf("get = %+v, request %+v", get, get.Request)
+	}
+	if !reflect.DeepEqual(get.Request.Headers, []model.KeyValue{{Key: "X-Trace", Value: "{{X-Trace}}", Disabled: true}}) {
+		t.Errorf("get headers = %+v", get.Request.Headers)
+	}
+	if put.Name != "updatePet" || put.Auth == nil || put.Auth.Type != model.AuthNone {
+		t.Errorf("put = %+v", put)
+	}
+	if put.Request.Body == nil || put.Request.Body.Raw != "{\n  \"name\": \"string\"\n}" || put.Request.Body.ContentType != "application/json" {
+		t.Errorf("put body = %+v", put.Request.Body)
+	}
+	if health := collection.Items[1]; health.Name != "GET /health (deprecated)" {
+		t.Errorf("untagged item = %+v", health)
+	}
+
+	variables := map[string]string{}
+	for _, variable := range collection.Variables {
+		variables[variable.Key] = variable.Value
+	}
+	if variables["petId"] != "5" || variables["region"] != "eu" {
+		t.Errorf("variables = %+v", collection.Variables)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "cookie") {
+		t.Errorf("warnings = %q", warnings)
+	}
+}
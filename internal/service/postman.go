@@ -0,0 +1,446 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/suar-net/suar-be/internal/model"
+)
+
+// importWarnings collects what could not be mapped during an import.
+type importWarnings []string
+
+func (w *importWarnings) add(format string, args ...any) {
+	*w = append(*w, fmt.Sprintf(format, args...))
+}
+
+func scalarString(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
+
+// postmanToCollection converts a Postman v2.1 collection to the native model.
+func postmanToCollection(postman *model.PostmanCollection, warnings *importWarnings) (*model.Collection, error) {
+	if postman.Info.Schema != "" && !strings.Contains(postman.Info.Schema, "v2.1") && !strings.Contains(postman.Info.Schema, "v2.0") {
+		return nil, fmt.Errorf("%w: unsupported Postman collection schema %s, export the collection as v2.1", ErrInvalidInput, postman.Info.Schema)
+	}
+	if strings.TrimSpace(postman.Info.Name) == "" {
+		return nil, fmt.Errorf("%w: the collection has no name", ErrInvalidInput)
+	}
+
+	collection := &model.Collection{
+		Name:        postman.Info.Name,
+		Description: string(postman.Info.Description),
+		Variables:   []model.Variable{},
+		Auth:        postmanAuthToNative(postman.Auth, postman.Info.Name, warnings),
+		Scripts:     postmanEventsToScripts(postman.Event),
+	}
+	for _, variable := range postman.Variable {
+		collection.Variables = append(collection.Variables, model.Variable{
+			Key:      variable.Key,
+			Value:    scalarString(variable.Value),
+			Secret:   variable.Type == "secret",
+			Disabled: variable.Disabled,
+		})
+	}
+	collection.Items = postmanItemsToNative(postman.Item, postman.Info.Name, warnings)
+	return collection, nil
+}
+
+func postmanItemsToNative(items []model.PostmanItem, path string, warnings *importWarnings) []*model.CollectionItem {
+	var native []*model.CollectionItem
+	for _, item := range items {
+		itemPath := path + " / " + item.Name
+		if item.Request == nil {
+			native = append(native, &model.CollectionItem{
+				Type:        model.CollectionItemFolder,
+				Name:        item.Name,
+				Description: string(item.Description),
+				Auth:        postmanAuthToNative(item.Auth, itemPath, warnings),
+				Scripts:     postmanEventsToScripts(item.Event),
+				Items:       postmanItemsToNative(item.Item, itemPath, warnings),
+			})
+			continue
+		}
+
+		request := item.Request
+		description := string(item.Description)
+		if description == "" {
+			description = string(request.Description)
+		}
+		native = append(native, &model.CollectionItem{
+			Type:        model.CollectionItemRequest,
+			Name:        item.Name,
+			Description: description,
+			Request: &model.CollectionRequest{
+				Method:  strings.ToUpper(request.Method),
+				URL:     postmanURLString(request.URL),
+				Headers: postmanKeyValues(request.Header),
+				Body:    postmanBodyToNative(request.Body, itemPath, warnings),
+			},
+			Auth:     postmanAuthToNative(request.Auth, itemPath, warnings),
+			Scripts:  postmanEventsToScripts(item.Event),
+			Examples: postmanResponsesToExamples(item.Response),
+		})
+	}
+	return native
+}
+
+func postmanResponsesToExamples(responses []model.PostmanResponse) []model.ResponseExample {
+	var examples []model.ResponseExample
+	for i, response := range responses {
+		name := response.Name
+		if name == "" {
+			name = fmt.Sprintf("Example %d", i+1)
+		}
+		examples = append(examples, model.ResponseExample{
+			Name:    name,
+			Status:  response.Code,
+			Headers: postmanKeyValues(response.Header),
+			Body:    response.Body,
+		})
+	}
+	return examples
+}
+
+// postmanURLString returns the raw URL, or rebuilds it from its parts for
+// collections written by tools that leave raw out.
+func postmanURLString(u model.PostmanURL) string {
+	if u.Raw != "" {
+		return u.Raw
+	}
+	var b strings.Builder
+	if u.Protocol != "" {
+		b.WriteString(u.Protocol + "://")
+	}
+	b.WriteString(strings.Join(u.Host, "."))
+	if u.Port != "" {
+		b.WriteString(":" + u.Port)
+	}
+	if len(u.Path) > 0 {
+		b.WriteString("/" + strings.Join(u.Path, "/"))
+	}
+	var query []string
+	for _, param := range u.Query {
+		if !param.Disabled {
+			query = append(query, param.Key+"="+param.Value)
+		}
+	}
+	if len(query) > 0 {
+		b.WriteString("?" + strings.Join(query, "&"))
+	}
+	return b.String()
+}
+
+func postmanKeyValues(pairs []model.PostmanKeyValue) []model.KeyValue {
+	var native []model.KeyValue
+	for _, pair := range pairs {
+		native = append(native, model.KeyValue{Key: pair.Key, Value: pair.Value, Disabled: pair.Disabled})
+	}
+	return native
+}
+
+var postmanRawLanguages = map[string]string{
+	"json":       "application/json",
+	"xml":        "application/xml",
+	"html":       "text/html",
+	"text":       "text/plain",
+	"javascript": "application/javascript",
+}
+
+func postmanBodyToNative(body *model.PostmanBody, path string, warnings *importWarnings) *model.RequestBody {
+	if body == nil || body.Disabled {
+		return nil
+	}
+	switch body.Mode {
+	case "", "none":
+		return nil
+	case "raw":
+		native := &model.RequestBody{Mode: model.BodyModeRaw, Raw: body.Raw}
+		if body.Options != nil {
+			native.ContentType = postmanRawLanguages[body.Options.Raw.Language]
+		}
+		return native
+	case "urlencoded":
+		return &model.RequestBody{Mode: model.BodyModeURLEncoded, URLEncoded: postmanKeyValues(body.URLEncoded)}
+	case "formdata":
+		native := &model.RequestBody{Mode: model.BodyModeFormData}
+		for _, field := range body.FormData {
+			formField := model.FormField{Key: field.Key, Value: field.Value, Type: "text", ContentType: field.ContentType, Disabled: field.Disabled}
+			if field.Type == "file" {
+				formField.Type = "file"
+				formField.Value = scalarString(field.Src)
+				warnings.add("%s: the file of form field %q was not imported", path, field.Key)
+			}
+			native.FormData = append(native.FormData, formField)
+		}
+		return native
+	case "graphql":
+		if body.GraphQL == nil {
+			return nil
+		}
+		graphQL := &model.DTOGraphQLBody{Query: body.GraphQL.Query}
+		if strings.TrimSpace(body.GraphQL.Variables) != "" {
+			if json.Valid([]byte(body.GraphQL.Variables)) {
+				graphQL.Variables = json.RawMessage(body.GraphQL.Variables)
+			} else {
+				warnings.add("%s: graphql variables are not valid JSON and were dropped", path)
+			}
+		}
+		return &model.RequestBody{Mode: model.BodyModeGraphQL, GraphQL: graphQL}
+	default:
+		warnings.add("%s: body mode %q is not supported and was dropped", path, body.Mode)
+		return nil
+	}
+}
+
+func postmanAuthToNative(auth *model.PostmanAuth, path string, warnings *importWarnings) *model.RequestAuth {
+	if auth == nil {
+		return nil
+	}
+	switch auth.Type {
+	case "", "inherit":
+		return nil
+	case "noauth":
+		return &model.RequestAuth{Type: model.AuthNone}
+	case "basic":
+		return &model.RequestAuth{Type: model.AuthBasic, Username: auth.Param("username"), Password: auth.Param("password")}
+	case "bearer":
+		return &model.RequestAuth{Type: model.AuthBearer, Token: auth.Param("token")}
+	case "apikey":
+		in := "header"
+		if auth.Param("in") == "query" {
+			in = "query"
+		}
+		return &model.RequestAuth{Type: model.AuthAPIKey, Key: auth.Param("key"), Value: auth.Param("value"), In: in}
+	default:
+		warnings.add("%s: auth type %q is not supported, no auth is sent", path, auth.Type)
+		return &model.RequestAuth{Type: model.AuthNone}
+	}
+}
+
+func postmanEventsToScripts(events []model.PostmanEvent) *model.Scripts {
+	scripts := &model.Scripts{}
+	for _, event := range events {
+		source := strings.Join(event.Script.Exec, "\n")
+		switch event.Listen {
+		case "prerequest":
+			scripts.PreRequest = source
+		case "test":
+			scripts.Test = source
+		}
+	}
+	if strings.TrimSpace(scripts.PreRequest) == "" && strings.TrimSpace(scripts.Test) == "" {
+		return nil
+	}
+	return scripts
+}
+
+// postmanEnvironmentToNative converts an environment exported from Postman.
+func postmanEnvironmentToNative(postman *model.PostmanEnvironment) (*model.Environment, error) {
+	if strings.TrimSpace(postman.Name) == "" {
+		return nil, fmt.Errorf("%w: the environment has no name", ErrInvalidInput)
+	}
+	environment := &model.Environment{Name: postman.Name, Variables: []model.Variable{}}
+	for _, value := range postman.Values {
+		environment.Variables = append(environment.Variables, model.Variable{
+			Key:      value.Key,
+			Value:    scalarString(value.Value),
+			Secret:   value.Type == "secret",
+			Disabled: value.Enabled != nil && !*value.Enabled,
+		})
+	}
+	return environment, nil
+}
+
+// collectionToPostman converts a native collection to Postman v2.1.
+func collectionToPostman(collection *model.Collection) *model.PostmanCollection {
+	postman := &model.PostmanCollection{
+		Info: model.PostmanInfo{
+			Name:        collection.Name,
+			Description: model.PostmanDescription(collection.Description),
+			Schema:      model.PostmanSchemaV21,
+		},
+		Item:  collectionItemsToPostman(collection.Items),
+		Auth:  nativeAuthToPostman(collection.Auth),
+		Event: scriptsToPostmanEvents(collection.Scripts),
+	}
+	if postman.Item == nil {
+		postman.Item = []model.PostmanItem{}
+	}
+	for _, variable := range collection.Variables {
+		postmanVariable := model.PostmanVariable{Key: variable.Key, Value: variable.Value, Type: "string", Disabled: variable.Disabled}
+		if variable.Secret {
+			postmanVariable.Type = "secret"
+		}
+		postman.Variable = append(postman.Variable, postmanVariable)
+	}
+	return postman
+}
+
+func collectionItemsToPostman(items []*model.CollectionItem) []model.PostmanItem {
+	var postman []model.PostmanItem
+	for _, item := range items {
+		postmanItem := model.PostmanItem{
+			Name:        item.Name,
+			Description: model.PostmanDescription(item.Description),
+			Event:       scriptsToPostmanEvents(item.Scripts),
+		}
+		if item.Type == model.CollectionItemFolder {
+			postmanItem.Auth = nativeAuthToPostman(item.Auth)
+			postmanItem.Item = collectionItemsToPostman(item.Items)
+			if postmanItem.Item == nil {
+				postmanItem.Item = []model.PostmanItem{}
+			}
+		} else if item.Request != nil {
+			postmanItem.Request = &model.PostmanRequest{
+				Method: item.Request.Method,
+				Header: nativeKeyValuesToPostman(item.Request.Headers),
+				URL:    postmanURLFromString(item.Request.URL),
+				Body:   nativeBodyToPostman(item.Request.Body),
+				Auth:   nativeAuthToPostman(item.Auth),
+			}
+			for _, example := range item.Examples {
+				status := mockExampleStatus(&example)
+				postmanItem.Response = append(postmanItem.Response, model.PostmanResponse{
+					Name:            example.Name,
+					OriginalRequest: postmanItem.Request,
+					Status:          http.StatusText(status),
+					Code:            status,
+					Header:          nativeKeyValuesToPostman(example.Headers),
+					Body:            example.Body,
+				})
+			}
+		}
+		postman = append(postman, postmanItem)
+	}
+	return postman
+}
+
+// postmanURLFromString splits a URL that may contain {{variables}}, and so
+// cannot go through url.Parse, into the parts Postman shows in its editor.
+func postmanURLFromString(raw string) model.PostmanURL {
+	u := model.PostmanURL{Raw: raw}
+	rest := raw
+	if protocol, after, ok := strings.Cut(rest, "://"); ok {
+		u.Protocol = protocol
+		rest = after
+	}
+	rest, query, _ := strings.Cut(rest, "?")
+	hostPort, path, hasPath := strings.Cut(rest, "/")
+	if host, port, ok := strings.Cut(hostPort, ":"); ok && !strings.Contains(port, "}") {
+		hostPort, u.Port = host, port
+	}
+	if strings.HasPrefix(hostPort, "{{") {
+		u.Host = []string{hostPort}
+	} else {
+		u.Host = strings.Split(hostPort, ".")
+	}
+	if hasPath {
+		u.Path = strings.Split(path, "/")
+	}
+	if query != "" {
+		for _, pair := range strings.Split(query, "&") {
+			key, value, _ := strings.Cut(pair, "=")
+			u.Query = append(u.Query, model.PostmanKeyValue{Key: key, Value: value})
+		}
+	}
+	return u
+}
+
+func nativeKeyValuesToPostman(pairs []model.KeyValue) []model.PostmanKeyValue {
+	postman := []model.PostmanKeyValue{}
+	for _, pair := range pairs {
+		postman = append(postman, model.PostmanKeyValue{Key: pair.Key, Value: pair.Value, Disabled: pair.Disabled})
+	}
+	return postman
+}
+
+func nativeBodyToPostman(body *model.RequestBody) *model.PostmanBody {
+	if body == nil {
+		return nil
+	}
+	switch body.Mode {
+	case model.BodyModeRaw:
+		postman := &model.PostmanBody{Mode: "raw", Raw: body.Raw}
+		for language, contentType := range postmanRawLanguages {
+			if contentType == body.ContentType {
+				postman.Options = &model.PostmanBodyOptions{}
+				postman.Options.Raw.Language = language
+			}
+		}
+		return postman
+	case model.BodyModeURLEncoded:
+		return &model.PostmanBody{Mode: "urlencoded", URLEncoded: nativeKeyValuesToPostman(body.URLEncoded)}
+	case model.BodyModeFormData:
+		postman := &model.PostmanBody{Mode: "formdata", FormData: []model.PostmanKeyValue{}}
+		for _, field := range body.FormData {
+			formField := model.PostmanKeyValue{Key: field.Key, Value: field.Value, Type: "text", ContentType: field.ContentType, Disabled: field.Disabled}
+			if field.Type == "file" {
+				formField.Type, formField.Value, formField.Src = "file", "", field.Value
+			}
+			postman.FormData = append(postman.FormData, formField)
+		}
+		return postman
+	case model.BodyModeGraphQL:
+		if body.GraphQL == nil {
+			return nil
+		}
+		return &model.PostmanBody{Mode: "graphql", GraphQL: &model.PostmanGraphQL{
+			Query:     body.GraphQL.Query,
+			Variables: string(body.GraphQL.Variables),
+		}}
+	}
+	return nil
+}
+
+func nativeAuthToPostman(auth *model.RequestAuth) *model.PostmanAuth {
+	if auth == nil {
+		return nil
+	}
+	param := func(key, value string) model.PostmanAuthParam {
+		return model.PostmanAuthParam{Key: key, Value: value, Type: "string"}
+	}
+	switch auth.Type {
+	case model.AuthBasic:
+		return &model.PostmanAuth{Type: "basic", Params: map[string][]model.PostmanAuthParam{
+			"basic": {param("username", auth.Username), param("password", auth.Password)},
+		}}
+	case model.AuthBearer:
+		return &model.PostmanAuth{Type: "bearer", Params: map[string][]model.PostmanAuthParam{
+			"bearer": {param("token", auth.Token)},
+		}}
+	case model.AuthAPIKey:
+		return &model.PostmanAuth{Type: "apikey", Params: map[string][]model.PostmanAuthParam{
+			"apikey": {param("key", auth.Key), param("value", auth.Value), param("in", auth.In)},
+		}}
+	}
+	return &model.PostmanAuth{Type: "noauth"}
+}
+
+func scriptsToPostmanEvents(scripts *model.Scripts) []model.PostmanEvent {
+	if scripts == nil {
+		return nil
+	}
+	var events []model.PostmanEvent
+	if scripts.PreRequest != "" {
+		events = append(events, model.PostmanEvent{Listen: "prerequest", Script: model.PostmanScript{
+			Type: "text/javascript", Exec: strings.Split(scripts.PreRequest, "\n"),
+		}})
+	}
+	if scripts.Test != "" {
+		events = append(events, model.PostmanEvent{Listen: "test", Script: model.PostmanScript{
+			Type: "text/javascript", Exec: strings.Split(scripts.Test, "\n"),
+		}})
+	}
+	return events
+}
@@ -0,0 +1,257 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/suar-net/suar-be/internal/model"
+)
+
+func TestPostmanURLString(t *testing.T) {
+	tests := []struct {
+		url  model.PostmanURL
+		want string
+	}{
+		{model.PostmanURL{Raw: "{{base}}/users?x=1"}, "{{base}}/users?x=1"},
+		{model.PostmanURL{Protocol: "https", Host: []string{"api", "test"}, Port: "8443", Path: []string{"v1", "users"},
+			Query: []model.PostmanKeyValue{{Key: "a", Value: "1"}, {Key: "b", Value: "2", Disabled: true}}}, "https://api.test:8443/v1/users?a=1"},
+		{model.PostmanURL{Host: []string{"{{host}}"}}, "{{host}}"},
+	}
+	for _, tt := range tests {
+		if got := postmanURLString(tt.url); got != tt.want {
+			t.Errorf("postmanURLString(%+v) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestPostmanURLFromString(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want model.PostmanURL
+	}{
+		{"https://api.test:8443/v1/users?a=1&b", model.PostmanURL{
+			Raw: "https://api.test:8443/v1/users?a=1&b", Protocol: "https", Host: []string{"api", "test"}, Port: "8443",
+			Path: []string{"v1", "users"}, Query: []model.PostmanKeyValue{{Key: "a", Value: "1"}, {Key: "b"}},
+		}},
+		{"{{base.url}}/items", model.PostmanURL{Raw: "{{base.url}}/items", Host: []string{"{{base.url}}"}, Path: []string{"items"}}},
+		{"http://{{host}}:{{port}}", model.PostmanURL{Raw: "http://{{host}}:{{port}}", Protocol: "http", Host: []string{"{{host}}:{{port}}"}}},
+	}
+	for _, tt := range tests {
+		got := postmanURLFromString(tt.raw)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("postmanURLFromString(%q) = %+v, want %+v", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestPostmanBodyToNative(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     *model.PostmanBody
+		want     *model.RequestBody
+		warnings int
+	}{
+		{"nil", nil, nil, 0},
+		{"disabled", &model.PostmanBody{Mode: "raw", Raw: "x", Disabled: true}, nil, 0},
+		{"none", &model.PostmanBody{Mode: "none"}, nil, 0},
+		{"raw json", &model.PostmanBody{Mode: "raw", Raw: `{"a":1}`, Options: &model.PostmanBodyOptions{Raw: struct {
+			Language string `json:"language,omitempty"`
+		}{Language: "json"}}}, &model.RequestBody{Mode: model.BodyModeRaw, Raw: `{"a":1}`, ContentType: "application/json"}, 0},
+		{"urlencoded", &model.PostmanBody{Mode: "urlencoded", URLEncoded: []model.PostmanKeyValue{{Key: "a", Value: "1", Disabled: true}}},
+			&model.RequestBody{Mode: model.BodyModeURLEncoded, URLEncoded: []model.KeyValue{{Key: "a", Value: "1", Disabled: true}}}, 0},
+		{"formdata with file", &model.PostmanBody{Mode: "formdata", FormData: []model.PostmanKeyValue{
+			{Key: "name", Value: "ana", Type: "text"}, {Key: "avatar", Type: "file", Src: "/tmp/a.png"},
+		}}, &model.RequestBody{Mode: model.BodyModeFormData, FormData: []model.FormField{
+			{Key: "name", Value: "ana", Type: "text"}, {Key: "avatar", Value: "/tmp/a.png", Type: "file"},
+		}}, 1},
+		{"graphql", &model.PostmanBody{Mode: "graphql", GraphQL: &model.PostmanGraphQL{Query: "{ me }", Variables: `{"a":1}`}},
+			&model.RequestBody{Mode: model.BodyModeGraphQL, GraphQL: &model.DTOGraphQLBody{Query: "{ me }", Variables: json.RawMessage(`{"a":1}`)}}, 0},
+		{"graphql with broken variables", &model.PostmanBody{Mode: "graphql", GraphQL: &model.PostmanGraphQL{Query: "{ me }", Variables: "{"}},
+			&model.RequestBody{Mode: model.BodyModeGraphQL, GraphQL: &model.DTOGraphQLBody{Query: "{ me }"}}, 1},
+		{"file mode", &model.PostmanBody{Mode: "file"}, nil, 1},
+	}
+	for _, tt := range tests {
+		var warnings importWarnings
+		got := postmanBodyToNative(tt.body, "c / r", &warnings)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: body = %+v, want %+v", tt.name, got, tt.want)
+		}
+		if len(warnings) != tt.warnings {
+			t.Errorf("%s: warnings = %q, want %d", tt.name, warnings, tt.warnings)
+		}
+	}
+}
+
+func TestPostmanAuthToNative(t *testing.T) {
+	params := func(kind string, pairs ...string) map[string][]model.PostmanAuthParam {
+		var list []model.PostmanAuthParam
+		for i := 0; i < len(pairs); i += 2 {
+			list = append(list, model.PostmanAuthParam{Key: pairs[i], Value: pairs[i+1]})
+		}
+		return map[string][]model.PostmanAuthParam{kind: list}
+	}
+	tests := []struct {
+		name     string
+		auth     *model.PostmanAuth
+		want     *model.RequestAuth
+		warnings int
+	}{
+		{"missing", nil, nil, 0},
+		{"inherit", &model.PostmanAuth{Type: "inherit"}, nil, 0},
+		{"noauth", &model.PostmanAuth{Type: "noauth"}, &model.RequestAuth{Type: model.AuthNone}, 0},
+		{"basic", &model.PostmanAuth{Type: "basic", Params: params("basic", "username", "ana", "password", "s")},
+			&model.RequestAuth{Type: model.AuthBasic, Username: "ana", Password: "s"}, 0},
+		{"bearer", &model.PostmanAuth{Type: "bearer", Params: params("bearer", "token", "{{token}}")},
+			&model.RequestAuth{Type: model.AuthBearer, Token: "{{token}}"}, 0},
+		{"apikey in query", &model.PostmanAuth{Type: "apikey", Params: params("apikey", "key", "k", "value", "v", "in", "query")},
+			&model.RequestAuth{Type: model.AuthAPIKey, Key: "k", Value: "v", In: "query"}, 0},
+		{"apikey defaults to header", &model.PostmanAuth{Type: "apikey", Params: params("apikey", "key", "k")},
+			&model.RequestAuth{Type: model.AuthAPIKey, Key: "k", In: "header"}, 0},
+		{"oauth2", &model.PostmanAuth{Type: "oauth2"}, &model.RequestAuth{Type: model.AuthNone}, 1},
+	}
+	for _, tt := range tests {
+		var warnings importWarnings
+		got := postmanAuthToNative(tt.auth, "c", &warnings)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: auth = %+v, want %+v", tt.name, got, tt.want)
+		}
+		if len(warnings) != tt.warnings {
+			t.Errorf("%s: warnings = %q, want %d", tt.name, warnings, tt.warnings)
+		}
+	}
+}
+
+func TestPostmanEventsToScripts(t *testing.T) {
+	tests := []struct {
+		events []model.PostmanEvent
+		want   *model.Scripts
+	}{
+		{nil, nil},
+		{[]model.PostmanEvent{{Listen: "test", Script: model.PostmanScript{Exec: []string{"  ", ""}}}}, nil},
+		{[]model.PostmanEvent{
+			{Listen: "prerequest", Script: model.PostmanScript{Exec: []string{"a()", "b()"}}},
+			{Listen: "test", Script: model.PostmanScript{Exec: []string{"c()"}}},
+		}, &model.Scripts{PreRequest: "a()\nb()", Test: "c()"}},
+	}
+	for _, tt := range tests {
+		if got := postmanEventsToScripts(tt.events); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("postmanEventsToScripts(%+v) = %+v, want %+v", tt.events, got, tt.want)
+		}
+	}
+}
+
+func TestPostmanToCollection(t *testing.T) {
+	data := `{
+		"info": {"name": "Shop", "description": {"content": "docs"}, "schema": "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"},
+		"variable": [{"key": "base", "value": "https://shop.test"}, {"key": "n", "value": 3}, {"key": "s", "value": "x", "type": "secret"}],
+		"item": [
+			{"name": "Users", "item": [
+				{"name": "List", "request": "{{base}}/users"},
+				{"name": "Create", "request": {"method": "post", "url": {"raw": "{{base}}/users"}, "header": [{"key": "X-A", "value": "1"}],
+					"body": {"mode": "raw", "raw": "{}"}},
+				 "response": [{"code": 201, "body": "{\"id\":1}"}]}
+			]}
+		]
+	}`
+	var postman model.PostmanCollection
+	if err := json.Unmarshal([]byte(data), &postman); err != nil {
+		t.Fatal(err)
+	}
+	var warnings importWarnings
+	collection, err := postmanToCollection(&postman, &warnings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if collection.Name != "Shop" || collection.Description != "docs" || len(warnings) != 0 {
+		t.Errorf("collection = %+v, warnings %q", collection, warnings)
+	}
+	wantVariables := []model.Variable{{Key: "base", Value: "https://shop.test"}, {Key: "n", Value: "3"}, {Key: "s", Value: "x", Secret: true}}
+	if !reflect.DeepEqual(collection.Variables, wantVariables) {
+		t.Errorf("variables = %+v, want %+v", collection.Variables, wantVariables)
+	}
+	folder := collection.Items[0]
+	if folder.Type != model.CollectionItemFolder || len(folder.Items) != 2 {
+		t.Fatalf("folder = %+v", folder)
+	}
+	list, create := folder.Items[0], folder.Items[1]
+	if list.Request.Method != "GET" || list.Request.URL != "{{base}}/users" {
+		t.Errorf("list = %+v", list.Request)
+	}
+	if create.Request.Method != "POST" || len(create.Request.Headers) != 1 || create.Request.Body.Raw != "{}" {
+		t.Errorf("create = %+v", create.Request)
+	}
+	if len(create.Examples) != 1 || create.Examples[0].Name != "Example 1" || create.Examples[0].Status != 201 {
+		t.Errorf("examples = %+v", create.Examples)
+	}
+
+	for name, info := range map[string]model.PostmanInfo{
+		"v1 schema": {Name: "Old", Schema: "https://schema.getpostman.com/json/collection/v1.0.0/collection.json"},
+		"no name":   {Name: " "},
+	} {
+		if _, err := postmanToCollection(&model.PostmanCollection{Info: info}, &warnings); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("%s: err = %v, want ErrInvalidInput", name, err)
+		}
+	}
+}
+
+func TestPostmanRoundTrip(t *testing.T) {
+	collection := &model.Collection{
+		Name:      "Shop",
+		Variables: []model.Variable{{Key: "base", Value: "https://shop.test"}, {Key: "s", Value: "x", Secret: true}},
+		Auth:      &model.RequestAuth{Type: model.AuthBearer, Token: "{{token}}"},
+		Scripts:   &model.Scripts{PreRequest: "a()\nb()"},
+		Items: []*model.CollectionItem{{
+			Type: model.CollectionItemFolder, Name: "Users",
+			Items: []*model.CollectionItem{{
+				Type: model.CollectionItemRequest, Name: "Create",
+				Request: &model.CollectionRequest{
+					Method:  "POST",
+					URL:     "{{base}}/users?x=1",
+					Headers: []model.KeyValue{{Key: "X-A", Value: "1", Disabled: true}},
+					Body:    &model.RequestBody{Mode: model.BodyModeRaw, Raw: `{"a":1}`, ContentType: "application/json"},
+				},
+				Auth:     &model.RequestAuth{Type: model.AuthAPIKey, Key: "k", Value: "v", In: "query"},
+				Examples: []model.ResponseExample{{Name: "Created", Status: 201, Body: `{"id":1}`}},
+			}},
+		}},
+	}
+
+	// Go through JSON so the custom unmarshalers run as they do on import.
+	data, err := json.Marshal(collectionToPostman(collection))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var postman model.PostmanCollection
+	if err := json.Unmarshal(data, &postman); err != nil {
+		t.Fatal(err)
+	}
+	var warnings importWarnings
+	got, err := postmanToCollection(&postman, &warnings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, collection) {
+		gotJSON, _ := json.Marshal(got)
+		wantJSON, _ := json.Marshal(collection)
+		t.Errorf("round trip changed the collection:\n got %s\nwant %s", gotJSON, wantJSON)
+	}
+}
+
+func TestPostmanEnvironmentToNative(t *testing.T) {
+	disabled := false
+	got, err := postmanEnvironmentToNative(&model.PostmanEnvironment{Name: "Staging", Values: []model.PostmanEnvironmentValue{
+		{Key: "host", Value: "s.test"}, {Key: "token", Value: "t", Type: "secret"}, {Key: "old", Value: true, Enabled: &disabled},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []model.Variable{{Key: "host", Value: "s.test"}, {Key: "token", Value: "t", Secret: true}, {Key: "old", Value: "true", Disabled: true}}
+	if got.Name != "Staging" || !reflect.DeepEqual(got.Variables, want) {
+		t.Errorf("environment = %+v", got)
+	}
+	if _, err := postmanEnvironmentToNative(&model.PostmanEnvironment{}); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("unnamed environment: err = %v, want ErrInvalidInput", err)
+	}
+}
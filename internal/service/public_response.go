@@ -0,0 +1,56 @@
+package service
+
+import (
+	"net/http"
+
+	"github.com/suar-net/suar-be/internal/model"
+)
+
+// unsafeResponseHeaders are headers a user may not make a public endpoint,
+// a mock server or a request bin, answer with: they act on the browser of
+// whoever opens the URL, on the site's origin rather than the user's.
+var unsafeResponseHeaders = map[string]bool{
+	"Access-Control-Allow-Credentials":    true,
+	"Alt-Svc":                             true,
+	"Clear-Site-Data":                     true,
+	"Content-Security-Policy":             true,
+	"Content-Security-Policy-Report-Only": true,
+	"Cross-Origin-Embedder-Policy":        true,
+	"Cross-Origin-Opener-Policy":          true,
+	"Cross-Origin-Resource-Policy":        true,
+	"Origin-Agent-Cluster":                true,
+	"Permissions-Policy":                  true,
+	"Refresh":                             true,
+	"Service-Worker-Allowed":              true,
+	"Set-Cookie":                          true,
+	"Set-Cookie2":                         true,
+	"Strict-Transport-Security":           true,
+	"X-Content-Type-Options":              true,
+	"X-Frame-Options":                     true,
+}
+
+// publicResponseStatus reports whether a public endpoint may answer with a
+// status. Informational statuses would leave the client waiting for the
+// real response, or switch the connection to another protocol.
+func publicResponseStatus(status int) bool {
+	return status >= 200 && status <= 599
+}
+
+// publicResponseHeaders returns the headers a public endpoint answers with:
+// those set by the user, without the unsafe and transfer ones, and headers
+// that keep the body from running as a page of the site, whatever its
+// content type says.
+func publicResponseHeaders(headers []model.KeyValue) []model.KeyValue {
+	public := make([]model.KeyValue, 0, len(headers)+2)
+	for _, header := range headers {
+		name := http.CanonicalHeaderKey(header.Key)
+		if header.Disabled || mockSkippedHeaders[name] || unsafeResponseHeaders[name] {
+			continue
+		}
+		public = append(public, model.KeyValue{Key: name, Value: header.Value})
+	}
+	return append(public,
+		model.KeyValue{Key: "Content-Security-Policy", Value: "sandbox"},
+		model.KeyValue{Key: "X-Content-Type-Options", Value: "nosniff"},
+	)
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/suar-net/suar-be/internal/model"
+)
+
+// maxRawCaptureSize bounds how much of each direction is kept. The response
+// body is still read in full, only the copy stops growing.
+const maxRawCaptureSize = 1024 * 1024
+
+// rawCapture records the bytes written to and read from a connection after
+// TLS, which for HTTP/1.1 is the exact request and response text.
+type rawCapture struct {
+	mu       sync.Mutex
+	sent     bytes.Buffer
+	received bytes.Buffer
+	sentN    int64
+	recvN    int64
+}
+
+func appendLimited(buf *bytes.Buffer, p []byte) {
+	if room := maxRawCaptureSize - buf.Len(); room > 0 {
+		if len(p) > room {
+			p = p[:room]
+		}
+		buf.Write(p)
+	}
+}
+
+// captureConn copies everything passing through the wrapped connection into
+// a rawCapture.
+type captureConn struct {
+	net.Conn
+	capture *rawCapture
+}
+
+func (c *captureConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		c.capture.mu.Lock()
+		appendLimited(&c.capture.received, p[:n])
+		c.capture.recvN += int64(n)
+		c.capture.mu.Unlock()
+	}
+	return n, err
+}
+
+func (c *captureConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	if n > 0 {
+		c.capture.mu.Lock()
+		appendLimited(&c.capture.sent, p[:n])
+		c.capture.sentN += int64(n)
+		c.capture.mu.Unlock()
+	}
+	return n, err
+}
+
+// newCaptureClient returns a single use HTTP/1.1 client whose only connection
+// is recorded into capture. HTTP/2 frames are binary and multiplexed, so raw
+// capture is limited to HTTP/1.1. TLS is done here instead of by the transport
+// so the recording sees plain text.
+func newCaptureClient(capture *rawCapture) *http.Client {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+
+	var protocols http.Protocols
+	protocols.SetHTTP1(true)
+
+	transport := &http.Transport{
+		Protocols:             &protocols,
+		DisableCompression:    true,
+		DisableKeepAlives:     true,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := dialer.DialContext(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			return &captureConn{Conn: conn, capture: capture}, nil
+		},
+		DialTLSContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			host, _, err := net.SplitHostPort(addr)
+			if err != nil {
+				return nil, err
+			}
+			conn, err := dialer.DialContext(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			tlsConn := tls.Client(conn, &tls.Config{
+				ServerName: host,
+				NextProtos: []string{"http/1.1"},
+			})
+			handshakeCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+			defer cancel()
+			if err := tlsConn.HandshakeContext(handshakeCtx); err != nil {
+				conn.Close()
+				return nil, err
+			}
+			return &captureConn{Conn: tlsConn, capture: capture}, nil
+		},
+	}
+
+	return &http.Client{
+		Transport: transport,
+	}
+}
+
+// exchange converts the recording into its DTO. Text is returned as is and
+// anything that is not valid UTF-8, such as a compressed body, as base64.
+func (c *rawCapture) exchange() *model.DTORawExchange {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	raw := &model.DTORawExchange{
+		RequestSize:       c.sentN,
+		ResponseSize:      c.recvN,
+		RequestTruncated:  c.sentN > int64(c.sent.Len()),
+		ResponseTruncated: c.recvN > int64(c.received.Len()),
+	}
+	if data := c.sent.Bytes(); utf8.Valid(data) {
+		raw.Request = string(data)
+	} else {
+		raw.RequestBase64 = base64.StdEncoding.EncodeToString(data)
+	}
+	if data := c.received.Bytes(); utf8.Valid(data) {
+		raw.Response = string(data)
+	} else {
+		raw.ResponseBase64 = base64.StdEncoding.EncodeToString(data)
+	}
+	return raw
+}
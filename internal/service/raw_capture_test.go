@@ -0,0 +1,83 @@
+package service
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAppendLimited(t *testing.T) {
+	tests := []struct {
+		name   string
+		start  int
+		write  int
+		length int
+	}{
+		{"fits", 0, 10, 10},
+		{"cut at the limit", maxRawCaptureSize - 4, 10, maxRawCaptureSize},
+		{"already full", maxRawCaptureSize, 10, maxRawCaptureSize},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		buf.Write(make([]byte, tt.start))
+		appendLimited(&buf, make([]byte, tt.write))
+		if buf.Len() != tt.length {
+			t.Errorf("%s: length %d, want %d", tt.name, buf.Len(), tt.length)
+		}
+	}
+}
+
+func TestRawCapture(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Test", "yes")
+		switch r.URL.Query().Get("reply") {
+		case "binary":
+			w.Write([]byte{0xff, 0xfe})
+		case "large":
+			w.Write(bytes.Repeat([]byte("a"), maxRawCaptureSize))
+		default:
+			io.WriteString(w, "hello")
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name      string
+		reply     string
+		base64    bool
+		truncated bool
+	}{
+		{"text", "text", false, false},
+		{"binary", "binary", true, false},
+		{"large", "large", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			capture := &rawCapture{}
+			req, _ := http.NewRequest(http.MethodPost, server.URL+"/path", strings.NewReader("ping"))
+			q := req.URL.Query()
+			q.Set("reply", tt.reply)
+			req.URL.RawQuery = q.Encode()
+			resp, err := newCaptureClient(capture).Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+
+			raw := capture.exchange()
+			if !strings.HasPrefix(raw.Request, "POST /path?reply=") || !strings.HasSuffix(raw.Request, "\r\n\r\nping") {
+				t.Errorf("request = %q", raw.Request)
+			}
+			if tt.base64 != (raw.ResponseBase64 != "") || (!tt.base64 && !strings.Contains(raw.Response, "X-Test: yes\r\n")) {
+				t.Errorf("response = %.80q, base64 %q", raw.Response, raw.ResponseBase64)
+			}
+			if raw.ResponseTruncated != tt.truncated || (tt.truncated && raw.ResponseSize <= maxRawCaptureSize) {
+				t.Errorf("truncated %v size %d", raw.ResponseTruncated, raw.ResponseSize)
+			}
+		})
+	}
+}
@@ -0,0 +1,376 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/suar-net/suar-be/internal/model"
+	"github.com/suar-net/suar-be/internal/repository"
+)
+
+const (
+	maxBinsPerUser = 20
+	maxBinRequests = 500
+	// binPollInterval is how often a watched bin is checked for requests
+	// another replica recorded; requests recorded by this one are pushed
+	// right away.
+	binPollInterval = 2 * time.Second
+	// binPingInterval keeps idle streams from being closed by proxies.
+	binPingInterval = 30 * time.Second
+	binStreamBatch  = 100
+)
+
+// binHub wakes the streams watching a bin when this replica records a
+// request for it, and ends them all when the server shuts down.
+type binHub struct {
+	mu       sync.Mutex
+	watchers map[string]map[chan struct{}]struct{}
+	closed   chan struct{}
+}
+
+func newBinHub() *binHub {
+	return &binHub{watchers: map[string]map[chan struct{}]struct{}{}, closed: make(chan struct{})}
+}
+
+// watch returns the channel woken for the bin, or an error once the hub is
+// shut down.
+func (h *binHub) watch(binID string) (chan struct{}, error) {
+	wake := make(chan struct{}, 1)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	select {
+	case <-h.closed:
+		return nil, errServerShutdown
+	default:
+	}
+	if h.watchers[binID] == nil {
+		h.watchers[binID] = map[chan struct{}]struct{}{}
+	}
+	h.watchers[binID][wake] = struct{}{}
+	return wake, nil
+}
+
+func (h *binHub) unwatch(binID string, wake chan struct{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.watchers[binID], wake)
+	if len(h.watchers[binID]) == 0 {
+		delete(h.watchers, binID)
+	}
+}
+
+// shutdown ends every stream watching a bin and refuses new ones.
+func (h *binHub) shutdown() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	select {
+	case <-h.closed:
+	default:
+		close(h.closed)
+	}
+}
+
+func (h *binHub) notify(binID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for wake := range h.watchers[binID] {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+type requestBinService struct {
+	bins repository.IRequestBinRepository
+	hub  *binHub
+}
+
+func NewRequestBinService(b repository.IRequestBinRepository) IRequestBinService {
+	return &requestBinService{
+		bins: b,
+		hub:  newBinHub(),
+	}
+}
+
+func withBinPath(bin *model.RequestBin) *model.RequestBin {
+	bin.Path = "/bin/" + bin.ID
+	return bin
+}
+
+func applyBin(bin *model.RequestBin, dto *model.DTORequestBinRequest) {
+	bin.Name = dto.Name
+	bin.Response = model.BinResponse{Status: http.StatusOK}
+	if dto.Response != nil {
+		bin.Response = *dto.Response
+	}
+}
+
+func (s *requestBinService) CreateBin(ctx context.Context, userID int, dto *model.DTORequestBinRequest) (*model.RequestBin, error) {
+	count, err := s.bins.CountByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count request bins: %w", err)
+	}
+	if count >= maxBinsPerUser {
+		return nil, ErrBinLimitReached
+	}
+
+	idBytes := make([]byte, 16)
+	if _, err := rand.Read(idBytes); err != nil {
+		return nil, fmt.Errorf("failed to generate request bin id: %w", err)
+	}
+	bin := &model.RequestBin{ID: hex.EncodeToString(idBytes), UserID: userID}
+	applyBin(bin, dto)
+	if err := s.bins.Create(ctx, bin); err != nil {
+		return nil, fmt.Errorf("failed to save request bin: %w", err)
+	}
+	return withBinPath(bin), nil
+}
+
+func (s *requestBinService) ListBins(ctx context.Context, userID int) ([]*model.RequestBin, error) {
+	bins, err := s.bins.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list request bins: %w", err)
+	}
+	if bins == nil {
+		bins = []*model.RequestBin{}
+	}
+	for _, bin := range bins {
+		withBinPath(bin)
+	}
+	return bins, nil
+}
+
+func (s *requestBinService) GetBin(ctx context.Context, id string, userID int) (*model.RequestBin, error) {
+	bin, err := s.bins.GetByID(ctx, id, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get request bin: %w", err)
+	}
+	if bin == nil {
+		return nil, ErrBinNotFound
+	}
+	return withBinPath(bin), nil
+}
+
+func (s *requestBinService) UpdateBin(ctx context.Context, id string, userID int, dto *model.DTORequestBinRequest) (*model.RequestBin, error) {
+	bin, err := s.GetBin(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	applyBin(bin, dto)
+	found, err := s.bins.Update(ctx, bin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update request bin: %w", err)
+	}
+	if !found {
+		return nil, ErrBinNotFound
+	}
+	return bin, nil
+}
+
+func (s *requestBinService) DeleteBin(ctx context.Context, id string, userID int) error {
+	found, err := s.bins.Delete(ctx, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete request bin: %w", err)
+	}
+	if !found {
+		return ErrBinNotFound
+	}
+	return nil
+}
+
+func (s *requestBinService) ListRequests(ctx context.Context, id string, userID int, limit int) ([]*model.CapturedRequest, error) {
+	if _, err := s.GetBin(ctx, id, userID); err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > maxBinRequests {
+		limit = maxBinRequests
+	}
+	captures, err := s.bins.GetCaptures(ctx, id, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list captured requests: %w", err)
+	}
+	if captures == nil {
+		captures = []*model.CapturedRequest{}
+	}
+	return captures, nil
+}
+
+func (s *requestBinService) ClearRequests(ctx context.Context, id string, userID int) error {
+	if _, err := s.GetBin(ctx, id, userID); err != nil {
+		return err
+	}
+	if err := s.bins.ClearCaptures(ctx, id); err != nil {
+		return fmt.Errorf("failed to clear captured requests: %w", err)
+	}
+	return nil
+}
+
+// Capture records a request sent to a bin and returns the response to answer
+// it with. Headers are kept as received, including credentials, as checking
+// what a webhook sends is the point of a bin. The response only has the
+// headers a public endpoint may send, and bins saved with a status it may
+// not answer with answer a server error, as mock servers do.
+func (s *requestBinService) Capture(ctx context.Context, id string, req *model.DTOBinCapture) (*model.BinResponse, error) {
+	bin, err := s.bins.GetPublic(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get request bin: %w", err)
+	}
+	if bin == nil {
+		return nil, ErrBinNotFound
+	}
+
+	capture := &model.CapturedRequest{
+		BinID:     bin.ID,
+		Method:    req.Method,
+		Path:      req.Path,
+		Query:     req.RawQuery,
+		Headers:   capturedHeaders(req.Headers),
+		Size:      req.Size,
+		Truncated: req.Truncated,
+		SourceIP:  req.SourceIP,
+	}
+	if len(req.Body) > 0 {
+		capture.Body, capture.BodyEncoding = capturedBody(req.Body, req.Truncated)
+	}
+
+	// The request is recorded even when its sender gives up waiting.
+	saveCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.bins.CreateCapture(saveCtx, capture); err != nil {
+		return nil, fmt.Errorf("failed to record request: %w", err)
+	}
+	s.hub.notify(bin.ID)
+
+	response := bin.Response
+	if !publicResponseStatus(response.Status) {
+		body, _ := json.Marshal(map[string]string{
+			"error": fmt.Sprintf("request bin has status %d, a request bin answers with 200 to 599", response.Status),
+		})
+		response = model.BinResponse{
+			Status:  http.StatusInternalServerError,
+			Headers: []model.KeyValue{{Key: "Content-Type", Value: "application/json"}},
+			Body:    string(body),
+		}
+	}
+	response.Headers = publicResponseHeaders(response.Headers)
+	return &response, nil
+}
+
+// capturedBody keeps a body as text when it is UTF-8 without NUL bytes and
+// as base64 otherwise. A body cut by the size limit may end in the middle of
+// a character, which is dropped rather than making the body binary.
+func capturedBody(body []byte, truncated bool) (string, string) {
+	text := body
+	if truncated {
+		for i := 1; i < utf8.UTFMax && i <= len(text); i++ {
+			if utf8.RuneStart(text[len(text)-i]) {
+				if !utf8.FullRune(text[len(text)-i:]) {
+					text = text[:len(text)-i]
+				}
+				break
+			}
+		}
+	}
+	if utf8.Valid(text) && !bytes.ContainsRune(text, 0) {
+		return string(text), model.BodyEncodingText
+	}
+	return base64.StdEncoding.EncodeToString(body), model.BodyEncodingBase64
+}
+
+// capturedHeaders lists headers sorted by name, keeping their values in
+// the order they were received.
+func capturedHeaders(headers map[string][]string) []model.KeyValue {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var captured []model.KeyValue
+	for _, name := range names {
+		for _, value := range headers[name] {
+			captured = append(captured, model.KeyValue{Key: name, Value: value})
+		}
+	}
+	return captured
+}
+
+// WatchBin streams the requests a bin records through emit until ctx is
+// done or the server shuts down: those recorded after afterID, or from now
+// on when afterID is 0.
+func (s *requestBinService) WatchBin(ctx context.Context, id string, userID int, afterID int64, emit func(model.DTOBinEvent) error) error {
+	bin, err := s.GetBin(ctx, id, userID)
+	if err != nil {
+		return err
+	}
+	lastID := afterID
+	if lastID <= 0 {
+		if lastID, err = s.bins.LatestCaptureID(ctx, id); err != nil {
+			return fmt.Errorf("failed to get captured requests: %w", err)
+		}
+	}
+
+	wake, err := s.hub.watch(id)
+	if err != nil {
+		return err
+	}
+	defer s.hub.unwatch(id, wake)
+	if err := emit(model.DTOBinEvent{Type: model.BinEventReady, Bin: bin}); err != nil {
+		return nil
+	}
+
+	poll := time.NewTicker(binPollInterval)
+	defer poll.Stop()
+	lastEvent := time.Now()
+	for {
+		captures, err := s.bins.GetCapturesAfter(ctx, id, lastID, binStreamBatch)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to get captured requests: %w", err)
+		}
+		for _, capture := range captures {
+			if err := emit(model.DTOBinEvent{Type: model.BinEventRequest, Request: capture}); err != nil {
+				return nil
+			}
+			lastID, lastEvent = capture.ID, time.Now()
+		}
+		if len(captures) == binStreamBatch {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.hub.closed:
+			return nil
+		case <-wake:
+		case <-poll.C:
+			if time.Since(lastEvent) >= binPingInterval {
+				if err := emit(model.DTOBinEvent{Type: model.BinEventPing}); err != nil {
+					return nil
+				}
+				lastEvent = time.Now()
+			}
+		}
+	}
+}
+
+// Shutdown ends the streams watching bins so their connections close before
+// the server is shut down.
+func (s *requestBinService) Shutdown() {
+	s.hub.shutdown()
+}
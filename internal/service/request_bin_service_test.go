@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/suar-net/suar-be/internal/model"
+	"github.com/suar-net/suar-be/internal/repository"
+)
+
+func TestCapturedBody(t *testing.T) {
+	base64Of := func(body string) string { return base64.StdEncoding.EncodeToString([]byte(body)) }
+	tests := []struct {
+		name      string
+		body      string
+		truncated bool
+		want      string
+		encoding  string
+	}{
+		{"text", `{"a": "é"}`, false, `{"a": "é"}`, model.BodyEncodingText},
+		{"NUL byte", "a\x00b", false, base64Of("a\x00b"), model.BodyEncodingBase64},
+		{"invalid UTF-8", "a\xffb", false, base64Of("a\xffb"), model.BodyEncodingBase64},
+		{"cut character of a whole body", "h\xc3", false, base64Of("h\xc3"), model.BodyEncodingBase64},
+		{"cut two byte character", "h\xc3", true, "h", model.BodyEncodingText},
+		{"cut four byte character", "a\xf0\x9f\x98", true, "a", model.BodyEncodingText},
+		{"truncated on a whole character", "hé", true, "hé", model.BodyEncodingText},
+		{"invalid before the cut", "\xffabc", true, base64Of("\xffabc"), model.BodyEncodingBase64},
+		{"only continuation bytes", "\x80\x80", true, base64Of("\x80\x80"), model.BodyEncodingBase64},
+	}
+	for _, tt := range tests {
+		body, encoding := capturedBody([]byte(tt.body), tt.truncated)
+		if body != tt.want || encoding != tt.encoding {
+			t.Errorf("%s: capturedBody = %q, %q, want %q, %q", tt.name, body, encoding, tt.want, tt.encoding)
+		}
+	}
+}
+
+func TestCapturedHeaders(t *testing.T) {
+	headers := capturedHeaders(map[string][]string{
+		"X-Trace":       {"b", "a"},
+		"Authorization": {"Bearer secret"},
+	})
+	want := []model.KeyValue{
+		{Key: "Authorization", Value: "Bearer secret"},
+		{Key: "X-Trace", Value: "b"},
+		{Key: "X-Trace", Value: "a"},
+	}
+	if !reflect.DeepEqual(headers, want) {
+		t.Errorf("headers = %+v", headers)
+	}
+}
+
+// fakeBinRepo serves one bin and records the requests captured for it.
+type fakeBinRepo struct {
+	repository.IRequestBinRepository
+	bin      *model.RequestBin
+	captures []*model.CapturedRequest
+}
+
+func (r *fakeBinRepo) GetPublic(ctx context.Context, id string) (*model.RequestBin, error) {
+	if id != r.bin.ID {
+		return nil, nil
+	}
+	return r.bin, nil
+}
+
+func (r *fakeBinRepo) CreateCapture(ctx context.Context, capture *model.CapturedRequest) error {
+	r.captures = append(r.captures, capture)
+	return nil
+}
+
+func TestCapture(t *testing.T) {
+	tests := []struct {
+		name     string
+		response model.BinResponse
+		want     model.BinResponse
+	}{
+		{"default", model.BinResponse{Status: http.StatusOK},
+			model.BinResponse{Status: http.StatusOK, Headers: []model.KeyValue{
+				{Key: "Content-Security-Policy", Value: "sandbox"},
+				{Key: "X-Content-Type-Options", Value: "nosniff"},
+			}}},
+		{"page planting a cookie", model.BinResponse{
+			Status: http.StatusCreated,
+			Headers: []model.KeyValue{
+				{Key: "Content-Type", Value: "text/html"},
+				{Key: "Set-Cookie", Value: "session=planted; Path=/"},
+				{Key: "x-request-id", Value: "1"},
+			},
+			Body: "<script>alert(document.cookie)</script>",
+		}, model.BinResponse{
+			Status: http.StatusCreated,
+			Headers: []model.KeyValue{
+				{Key: "Content-Type", Value: "text/html"},
+				{Key: "X-Request-Id", Value: "1"},
+				{Key: "Content-Security-Policy", Value: "sandbox"},
+				{Key: "X-Content-Type-Options", Value: "nosniff"},
+			},
+			Body: "<script>alert(document.cookie)</script>",
+		}},
+		{"switching protocols", model.BinResponse{Status: http.StatusSwitchingProtocols, Headers: []model.KeyValue{{Key: "Upgrade", Value: "websocket"}}},
+			model.BinResponse{Status: http.StatusInternalServerError, Headers: []model.KeyValue{
+				{Key: "Content-Type", Value: "application/json"},
+				{Key: "Content-Security-Policy", Value: "sandbox"},
+				{Key: "X-Content-Type-Options", Value: "nosniff"},
+			}, Body: `{"error":"request bin has status 101, a request bin answers with 200 to 599"}`}},
+	}
+	for _, tt := range tests {
+		bins := &fakeBinRepo{bin: &model.RequestBin{ID: "b1", Response: tt.response}}
+		s := NewRequestBinService(bins)
+		response, err := s.Capture(context.Background(), "b1", &model.DTOBinCapture{
+			Method:  "POST",
+			Path:    "/hook",
+			Headers: map[string][]string{"Cookie": {"a=1"}},
+			Body:    []byte("payload"),
+			Size:    7,
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(*response, tt.want) {
+			t.Errorf("%s: response = %+v", tt.name, response)
+		}
+		if !reflect.DeepEqual(bins.bin.Response, tt.response) {
+			t.Errorf("%s: the saved response was changed to %+v", tt.name, bins.bin.Response)
+		}
+		capture := bins.captures[0]
+		if capture.Body != "payload" || capture.BodyEncoding != model.BodyEncodingText || capture.Headers[0].Value != "a=1" {
+			t.Errorf("%s: capture = %+v", tt.name, capture)
+		}
+	}
+
+	s := NewRequestBinService(&fakeBinRepo{bin: &model.RequestBin{ID: "b1"}})
+	if _, err := s.Capture(context.Background(), "b2", &model.DTOBinCapture{}); err != ErrBinNotFound {
+		t.Errorf("err = %v, want %v", err, ErrBinNotFound)
+	}
+}
+
+func TestBinHubShutdown(t *testing.T) {
+	hub := newBinHub()
+	if _, err := hub.watch("b1"); err != nil {
+		t.Fatal(err)
+	}
+	hub.shutdown()
+	hub.shutdown()
+	select {
+	case <-hub.closed:
+	default:
+		t.Error("watching streams were not ended")
+	}
+	if _, err := hub.watch("b1"); !errors.Is(err, errServerShutdown) {
+		t.Errorf("watch after shutdown: err = %v", err)
+	}
+}
@@ -3,15 +3,21 @@ package service
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
+	"os"
 	"strings"
 	"time"
+	"unicode/utf8"
 
+	"github.com/suar-net/suar-be/internal/config"
 	"github.com/suar-net/suar-be/internal/model"
 	"github.com/suar-net/suar-be/internal/repository"
 )
@@ -52,37 +58,91 @@ func isPrivateIP(ip net.IP) bool {
 	return false
 }
 
+// checkEgress applies the SSRF policy shared by every kind of outbound
+// connection: the host must resolve and none of its addresses may be private.
+func checkEgress(hostname string) error {
+	ips, err := net.LookupIP(hostname)
+	if err != nil {
+		return fmt.Errorf("%w: could not resolve hostname: %v", ErrInvalidInput, err)
+	}
+	for _, ip := range ips {
+		if isPrivateIP(ip) {
+			return fmt.Errorf("%w: requests to private IP addresses are not allowed", ErrInvalidInput)
+		}
+	}
+	return nil
+}
+
 type OutboundRequest struct {
-	Method  string
-	URL     *url.URL
-	Headers http.Header
-	Body    []byte
-	Timeout time.Duration
+	Method       string
+	URL          *url.URL
+	Headers      http.Header
+	Body         []byte
+	Timeout      time.Duration
+	ResponseMode string
+	SpoolLimit   int64
+	HTTPVersion  string
+	CaptureRaw   bool
 }
 
 type RequestService struct {
-	repository repository.IRequestRepository
-	httpClient *http.Client
+	repository     repository.IRequestRepository
+	specs          repository.IAPISpecRepository
+	httpClients    map[string]*http.Client
+	responseConfig config.ResponseConfig
+	responseStore  *ResponseStore
+	streams        *streamRegistry
+	graphQLSchemas *lruCache[graphQLCacheKey, *cachedGraphQLSchema]
 }
 
-func NewRequestService(r repository.IRequestRepository) *RequestService {
+// newHTTPClient creates a client whose transport only speaks the given
+// protocols. Each HTTP version gets its own transport so pooled connections
+// negotiated for one version are never reused for another.
+func newHTTPClient(protocols *http.Protocols) *http.Client {
 	transport := &http.Transport{
+		Protocols:             protocols,
+		ForceAttemptHTTP2:     protocols.HTTP2(),
+		DisableCompression:    true,
 		MaxIdleConns:          100,
 		IdleConnTimeout:       90 * time.Second,
 		TLSHandshakeTimeout:   10 * time.Second,
 		ExpectContinueTimeout: 1 * time.Second,
 	}
 
-	httpClient := &http.Client{
+	return &http.Client{
 		Transport: transport,
 	}
+}
+
+func NewRequestService(r repository.IRequestRepository, s repository.IAPISpecRepository, responseConfig config.ResponseConfig) *RequestService {
+	var auto, http1, http2, h2c http.Protocols
+	auto.SetHTTP1(true)
+	auto.SetHTTP2(true)
+	http1.SetHTTP1(true)
+	http2.SetHTTP2(true)
+	h2c.SetUnencryptedHTTP2(true)
 
 	return &RequestService{
 		repository: r,
-		httpClient: httpClient,
+		specs:      s,
+		httpClients: map[string]*http.Client{
+			model.HTTPVersionAuto: newHTTPClient(&auto),
+			model.HTTPVersion1:    newHTTPClient(&http1),
+			model.HTTPVersion2:    newHTTPClient(&http2),
+			model.HTTPVersionH2C:  newHTTPClient(&h2c),
+		},
+		responseConfig: responseConfig,
+		responseStore:  NewResponseStore(responseConfig.SpoolDir, responseConfig.SpoolTTL, responseConfig.SpoolQuota),
+		streams:        newStreamRegistry(),
+		graphQLSchemas: newLRUCache[graphQLCacheKey, *cachedGraphQLSchema](maxGraphQLSchemas),
 	}
 }
 
+// httpClient returns the client for the HTTP version of a request.
+func (rs RequestService) httpClient(outboundRequest *OutboundRequest) *http.Client {
+	return rs.httpClients[outboundRequest.HTTPVersion]
+}
+
 func (rs RequestService) CreateOutboundRequest(dto *model.DTORequest) (*OutboundRequest, error) {
 	// HTTP Method Validation
 	dto.Method = strings.ToUpper(dto.Method)
@@ -90,6 +150,13 @@ func (rs RequestService) CreateOutboundRequest(dto *model.DTORequest) (*Outbound
 		return nil, fmt.Errorf("%w: invalid or unsupported HTTP method: %s", ErrInvalidInput, dto.Method)
 	}
 
+	// GraphQL body mode is turned into a plain request before the usual checks
+	if dto.GraphQL != nil {
+		if err := applyGraphQLBody(dto); err != nil {
+			return nil, err
+		}
+	}
+
 	// URL Validation
 	if dto.URL == "" {
 		return nil, fmt.Errorf("%w: URL cannot be empty", ErrInvalidInput)
@@ -102,15 +169,8 @@ func (rs RequestService) CreateOutboundRequest(dto *model.DTORequest) (*Outbound
 		return nil, fmt.Errorf("%w: invalid URL scheme: %s. Only 'http' and 'https' are allowed", ErrInvalidInput, parsedURL.Scheme)
 	}
 
-	// SSRF Protection: Disallow requests to private/local IP addresses
-	ips, err := net.LookupIP(parsedURL.Hostname())
-	if err != nil {
-		return nil, fmt.Errorf("%w: could not resolve hostname: %v", ErrInvalidInput, err)
-	}
-	for _, ip := range ips {
-		if isPrivateIP(ip) {
-			return nil, fmt.Errorf("%w: requests to private IP addresses are not allowed", ErrInvalidInput)
-		}
+	if err := checkEgress(parsedURL.Hostname()); err != nil {
+		return nil, err
 	}
 
 	// Timeout Validation
@@ -131,19 +191,73 @@ func (rs RequestService) CreateOutboundRequest(dto *model.DTORequest) (*Outbound
 		}
 	}
 
+	// HTTP version validation, HTTP/2 over TLS needs https and h2c needs http
+	httpVersion := dto.HTTPVersion
+	if httpVersion == "" {
+		httpVersion = model.HTTPVersionAuto
+	}
+	if httpVersion == model.HTTPVersion2 && parsedURL.Scheme != "https" {
+		return nil, fmt.Errorf("%w: http_version 'http2' requires an https URL, use 'h2c' for plain http", ErrInvalidInput)
+	}
+	if httpVersion == model.HTTPVersionH2C && parsedURL.Scheme != "http" {
+		return nil, fmt.Errorf("%w: http_version 'h2c' requires an http URL, use 'http2' for https", ErrInvalidInput)
+	}
+
+	if dto.CaptureRaw && (httpVersion == model.HTTPVersion2 || httpVersion == model.HTTPVersionH2C) {
+		return nil, fmt.Errorf("%w: capture_raw is only supported over HTTP/1.1", ErrInvalidInput)
+	}
+
+	body, err := requestBody(dto)
+	if err != nil {
+		return nil, err
+	}
+
+	responseMode := dto.ResponseMode
+	if responseMode == "" {
+		responseMode = model.ResponseModeInline
+	}
+
 	// Create the outbound request with validated data
 	request := &OutboundRequest{
-		Method:  dto.Method,
-		URL:     parsedURL,
-		Headers: headers,
-		Body:    dto.Body,
-		Timeout: timeout,
+		Method:       dto.Method,
+		URL:          parsedURL,
+		Headers:      headers,
+		Body:         body,
+		Timeout:      timeout,
+		ResponseMode: responseMode,
+		HTTPVersion:  httpVersion,
+		CaptureRaw:   dto.CaptureRaw,
 	}
 
 	return request, nil
 }
 
-func (rs RequestService) HttpResponseToDTOResponse(resp *http.Response, duration time.Duration, timestamp time.Time) (*model.DTOResponse, error) {
+// requestBody returns the bytes to send from whichever body field is set.
+func requestBody(dto *model.DTORequest) ([]byte, error) {
+	set := 0
+	for _, present := range []bool{len(dto.Body) > 0, dto.BodyText != "", dto.BodyBase64 != ""} {
+		if present {
+			set++
+		}
+	}
+	if set > 1 {
+		return nil, fmt.Errorf("%w: only one of body, body_text and body_base64 may be set", ErrInvalidInput)
+	}
+
+	switch {
+	case dto.BodyText != "":
+		return []byte(dto.BodyText), nil
+	case dto.BodyBase64 != "":
+		body, err := base64.StdEncoding.DecodeString(dto.BodyBase64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: body_base64 is not valid base64: %v", ErrInvalidInput, err)
+		}
+		return body, nil
+	}
+	return dto.Body, nil
+}
+
+func (rs RequestService) HttpResponseToDTOResponse(resp *http.Response, outboundRequest *OutboundRequest, duration time.Duration, timestamp time.Time) (*model.DTOResponse, error) {
 	defer resp.Body.Close()
 
 	headers := make(map[string][]string)
@@ -151,38 +265,234 @@ func (rs RequestService) HttpResponseToDTOResponse(resp *http.Response, duration
 		headers[key] = values
 	}
 
-	limitedReader := &io.LimitedReader{R: resp.Body, N: maxResponseBodySize}
-	bodyBytes, err := io.ReadAll(limitedReader)
-
 	dtoResponse := &model.DTOResponse{
-		StatusCode: resp.StatusCode,
-		Duration:   duration,
-		Timestamp:  timestamp,
-		Headers:    headers,
+		StatusCode:      resp.StatusCode,
+		Proto:           resp.Proto,
+		Duration:        duration,
+		Timestamp:       timestamp,
+		Headers:         headers,
+		ContentEncoding: resp.Header.Get("Content-Encoding"),
+	}
+	if resp.TLS != nil {
+		dtoResponse.ALPN = resp.TLS.NegotiatedProtocol
+	}
+	contentType := resp.Header.Get("Content-Type")
+
+	// Compression is disabled on the transport, so the body is read exactly as
+	// it was sent and decoded here regardless of who set Accept-Encoding.
+	wireReader := &countingReader{r: resp.Body}
+	headerReader := &replayReader{r: wireReader}
+	bodyReader, closeDecoder, err := newContentDecoder(headerReader, parseContentEncoding(dtoResponse.ContentEncoding))
+	if err != nil {
+		// Fall back to the encoded bytes so the client can still inspect them,
+		// including those the decoder read before giving up.
+		dtoResponse.Error = fmt.Sprintf("%v, body returned as received", err)
+		bodyReader, closeDecoder = headerReader.replay(), func() {}
+	} else {
+		headerReader.stop()
 	}
+	defer closeDecoder()
+
+	limitedReader := &io.LimitedReader{R: bodyReader, N: maxResponseBodySize}
+	bodyBytes, err := io.ReadAll(limitedReader)
+	dtoResponse.WireSize = wireReader.n
 
 	if err != nil {
 		dtoResponse.Error = fmt.Sprintf("failed to read response body: %v", err)
 		dtoResponse.Size = 0
 		dtoResponse.Body = nil
-	} else {
-		dtoResponse.Size = int64(len(bodyBytes))
-		dtoResponse.Body = bodyBytes
-		if limitedReader.N <= 0 {
+		return dtoResponse, nil
+	}
+
+	dtoResponse.Size = int64(len(bodyBytes))
+	if limitedReader.N <= 0 {
+		if outboundRequest.ResponseMode == model.ResponseModeSpool && outboundRequest.SpoolLimit > maxResponseBodySize {
+			bodyBytes = rs.spoolResponseBody(dtoResponse, bodyBytes, bodyReader, outboundRequest.SpoolLimit, contentType)
+			dtoResponse.WireSize = wireReader.n
+		} else {
 			dtoResponse.Error = "response body truncated due to size limit"
 		}
 	}
 
+	decodedBody, charsetName, err := decodeCharset(bodyBytes, contentType)
+	if err != nil && dtoResponse.Error == "" {
+		dtoResponse.Error = err.Error()
+	}
+	dtoResponse.Body = decodedBody
+	dtoResponse.Charset = charsetName
+	renderBody(dtoResponse, contentType)
+
 	return dtoResponse, nil
 }
 
-func (rs RequestService) ProcessRequest(ctx context.Context, dto *model.DTORequest) (*model.DTOResponse, error) {
+// spoolResponseBody writes a body that exceeded the inline limit to the
+// response store and fills in the download fields. head is what has already
+// been read, rest is the remainder of the decoded body. It returns the preview
+// that should be rendered inline instead of the full body.
+func (rs RequestService) spoolResponseBody(dtoResponse *model.DTOResponse, head []byte, rest io.Reader, limit int64, contentType string) []byte {
+	file, err := rs.responseStore.Create()
+	if err != nil {
+		dtoResponse.Error = fmt.Sprintf("response body truncated, failed to spool it: %v", err)
+		return head
+	}
+
+	if _, err := file.Write(head); err != nil {
+		rs.responseStore.Discard(file)
+		dtoResponse.Error = fmt.Sprintf("response body truncated, failed to spool it: %v", err)
+		return head
+	}
+
+	remaining := &io.LimitedReader{R: rest, N: limit - int64(len(head))}
+	if _, err := io.Copy(file, remaining); err != nil {
+		rs.responseStore.Discard(file)
+		if errors.Is(err, errSpoolFull) {
+			dtoResponse.Error = fmt.Sprintf("response body truncated: %v", err)
+		} else {
+			dtoResponse.Error = fmt.Sprintf("failed to read response body: %v", err)
+		}
+		return head
+	}
+
+	entry, err := rs.responseStore.Commit(file, contentType)
+	if err != nil {
+		dtoResponse.Error = fmt.Sprintf("response body truncated, failed to spool it: %v", err)
+		return head
+	}
+
+	if remaining.N <= 0 {
+		dtoResponse.Error = fmt.Sprintf("response body truncated at the spool limit of %d bytes", limit)
+	}
+
+	dtoResponse.Size = entry.Size
+	dtoResponse.Truncated = true
+	dtoResponse.DownloadToken = entry.Token
+	dtoResponse.DownloadURL = "/api/v1/responses/" + entry.Token
+	dtoResponse.DownloadExpires = &entry.ExpiresAt
+
+	preview := head
+	if int64(len(preview)) > rs.responseConfig.PreviewSize {
+		preview = preview[:rs.responseConfig.PreviewSize]
+	}
+	return trimIncompleteRune(preview)
+}
+
+// OpenSpooledResponse returns a spooled body by its download token. The caller
+// must close the returned file.
+func (rs RequestService) OpenSpooledResponse(token string) (*SpooledResponse, *os.File, error) {
+	return rs.responseStore.Open(token)
+}
+
+func (rs RequestService) ProcessRequest(ctx context.Context, userID *int, dto *model.DTORequest) (*model.DTOResponse, error) {
 	outboundRequest, err := rs.CreateOutboundRequest(dto)
 	if err != nil {
 		return nil, err
 	}
 
-	return rs.ExecuteRequest(ctx, outboundRequest)
+	// Schemas are cached per user, anonymous requests are not validated.
+	if dto.GraphQL != nil && userID != nil {
+		if err := rs.validateGraphQLQuery(*userID, dto); err != nil {
+			return nil, err
+		}
+	}
+
+	if outboundRequest.ResponseMode == model.ResponseModeSpool {
+		outboundRequest.SpoolLimit = rs.responseConfig.SpoolLimit(userID != nil)
+		if outboundRequest.SpoolLimit <= 0 {
+			return nil, fmt.Errorf("%w: spooling large responses is not available for your account tier", ErrInvalidInput)
+		}
+	}
+
+	// The contract is checked before sending so a broken link fails fast.
+	var contract *contractValidator
+	if dto.Contract != nil {
+		contract, err = newContractValidator(ctx, rs.specs, userID, dto.Contract)
+		if err != nil {
+			return nil, err
+		}
+		if err := contract.matchRequest(outboundRequest.Method, outboundRequest.URL); err != nil {
+			return nil, err
+		}
+	}
+	assertions, err := compileAssertions(dto.Assertions)
+	if err != nil {
+		return nil, err
+	}
+
+	dtoResponse, err := rs.ExecuteRequest(ctx, outboundRequest)
+	if err != nil {
+		return nil, err
+	}
+	if dtoResponse.Error == "" {
+		if contract != nil {
+			dtoResponse.Contract = contract.validate(dtoResponse)
+		}
+		if len(assertions) > 0 {
+			dtoResponse.Assertions = evaluateAssertions(assertions, dtoResponse)
+		}
+	}
+
+	if userID != nil && !dto.SkipHistory {
+		// History is written even when the caller has gone away.
+		saveCtx, saveCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer saveCancel()
+		if err := rs.saveHistory(saveCtx, userID, outboundRequest, dtoResponse); err != nil {
+			return dtoResponse, fmt.Errorf("failed to save request history: %w", err)
+		}
+	}
+	return dtoResponse, nil
+}
+
+func (rs RequestService) saveHistory(ctx context.Context, userID *int, req *OutboundRequest, resp *model.DTOResponse) error {
+	requestHeaders, err := json.Marshal(req.Headers)
+	if err != nil {
+		return err
+	}
+	responseHeaders, err := json.Marshal(resp.Headers)
+	if err != nil {
+		return err
+	}
+	durationMs := int(resp.Duration.Milliseconds())
+	entry := &model.Request{
+		UserID:          userID,
+		RequestType:     model.RequestTypeHTTP,
+		ExecutedAt:      resp.Timestamp,
+		RequestMethod:   req.Method,
+		RequestURL:      req.URL.String(),
+		RequestHeaders:  requestHeaders,
+		RequestBody:     historyText(req.Body),
+		ResponseHeaders: responseHeaders,
+		ResponseBody:    historyText(resp.Body),
+		ResponseSize:    &resp.Size,
+		DurationMs:      &durationMs,
+	}
+	if resp.StatusCode > 0 {
+		entry.ResponseStatusCode = &resp.StatusCode
+	}
+	if len(resp.Assertions) > 0 {
+		if entry.AssertionResults, err = json.Marshal(resp.Assertions); err != nil {
+			return err
+		}
+	}
+	if resp.Timings != nil {
+		if entry.Timings, err = json.Marshal(resp.Timings); err != nil {
+			return err
+		}
+	}
+	if err := rs.repository.Create(ctx, entry); err != nil {
+		return err
+	}
+	resp.HistoryID = &entry.ID
+	return nil
+}
+
+// historyText returns a body that can be stored as text, binary bodies are
+// left out and only their size is kept.
+func historyText(body []byte) *string {
+	if len(body) == 0 || !utf8.Valid(body) || bytes.IndexByte(body, 0) >= 0 {
+		return nil
+	}
+	text := string(body)
+	return &text
 }
 
 func (rs RequestService) ExecuteRequest(ctx context.Context, outboundRequest *OutboundRequest) (*model.DTOResponse, error) {
@@ -209,9 +519,26 @@ func (rs RequestService) ExecuteRequest(ctx context.Context, outboundRequest *Ou
 			Timestamp: startTime,
 		}, nil
 	}
-	httpRequest.Header = outboundRequest.Headers
+	httpRequest.Header = outboundRequest.Headers.Clone()
+	trace := newRequestTrace(startTime)
+	httpRequest = httpRequest.WithContext(httptrace.WithClientTrace(httpRequest.Context(), trace.clientTrace()))
+
+	// Mirror the transport's default of asking for gzip, which it no longer
+	// does on its own since compression is handled by HttpResponseToDTOResponse.
+	if httpRequest.Header.Get("Accept-Encoding") == "" && httpRequest.Header.Get("Range") == "" &&
+		httpRequest.Method != http.MethodHead {
+		httpRequest.Header.Set("Accept-Encoding", "gzip")
+	}
 
-	httpResponse, err := rs.httpClient.Do(httpRequest)
+	client := rs.httpClient(outboundRequest)
+	var capture *rawCapture
+	if outboundRequest.CaptureRaw {
+		capture = &rawCapture{}
+		client = newCaptureClient(capture)
+		defer client.CloseIdleConnections()
+	}
+
+	httpResponse, err := client.Do(httpRequest)
 	duration := time.Since(startTime)
 	if err != nil {
 		if errors.Is(err, context.DeadlineExceeded) {
@@ -220,7 +547,16 @@ func (rs RequestService) ExecuteRequest(ctx context.Context, outboundRequest *Ou
 		return nil, fmt.Errorf("failed to execute request to target server: %w", err)
 	}
 
-	return rs.HttpResponseToDTOResponse(httpResponse, duration, startTime)
+	dtoResponse, err := rs.HttpResponseToDTOResponse(httpResponse, outboundRequest, duration, startTime)
+	if err != nil {
+		return nil, err
+	}
+	dtoResponse.Timings = trace.timings(time.Now())
+	// The capture is only complete once the body has been read.
+	if capture != nil {
+		dtoResponse.Raw = capture.exchange()
+	}
+	return dtoResponse, nil
 }
 
 func (rs RequestService) GetHistory() {
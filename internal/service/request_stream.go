@@ -0,0 +1,341 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/suar-net/suar-be/internal/model"
+)
+
+const (
+	maxStreamDuration  = 30 * time.Minute
+	maxStreamEventSize = 1024 * 1024
+	streamChunkSize    = 32 * 1024
+	// maxStreamsPerUser bounds the streams a user keeps open at once, and
+	// maxAnonymousStreams those of all anonymous callers together, on this
+	// replica.
+	maxStreamsPerUser   = 5
+	maxAnonymousStreams = 20
+)
+
+// registeredStream is a running stream and the user who started it, nil for
+// an anonymous caller.
+type registeredStream struct {
+	userID *int
+	cancel context.CancelCauseFunc
+}
+
+// streamRegistry tracks running streams so they can be cancelled from a
+// separate request, or all at once when the server shuts down. Stream IDs
+// are random: they are the cancel credential of anonymous streams, the
+// streams of a user can only be cancelled by that user.
+type streamRegistry struct {
+	mu      sync.Mutex
+	streams map[string]registeredStream
+	closed  bool
+}
+
+func newStreamRegistry() *streamRegistry {
+	return &streamRegistry{streams: make(map[string]registeredStream)}
+}
+
+func (r *streamRegistry) add(userID *int, cancel context.CancelCauseFunc) (string, error) {
+	idBytes := make([]byte, 16)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", fmt.Errorf("failed to generate stream id: %w", err)
+	}
+	id := hex.EncodeToString(idBytes)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return "", errServerShutdown
+	}
+	running := 0
+	for _, stream := range r.streams {
+		if sameStreamOwner(stream.userID, userID) {
+			running++
+		}
+	}
+	if userID == nil && running >= maxAnonymousStreams {
+		return "", fmt.Errorf("%w: anonymous callers already have %d streams open, sign in to open more", ErrStreamLimitReached, running)
+	}
+	if userID != nil && running >= maxStreamsPerUser {
+		return "", fmt.Errorf("%w: at most %d streams may be open at once", ErrStreamLimitReached, maxStreamsPerUser)
+	}
+	r.streams[id] = registeredStream{userID: userID, cancel: cancel}
+	return id, nil
+}
+
+func (r *streamRegistry) remove(id string) {
+	r.mu.Lock()
+	delete(r.streams, id)
+	r.mu.Unlock()
+}
+
+// cancel stops the stream with the id when userID may cancel it.
+func (r *streamRegistry) cancel(id string, userID *int) bool {
+	r.mu.Lock()
+	stream, ok := r.streams[id]
+	r.mu.Unlock()
+	if !ok || (stream.userID != nil && !sameStreamOwner(stream.userID, userID)) {
+		return false
+	}
+	stream.cancel(errStreamCancelled)
+	return true
+}
+
+// shutdown cancels every running stream and refuses new ones.
+func (r *streamRegistry) shutdown() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	for _, stream := range r.streams {
+		stream.cancel(errServerShutdown)
+	}
+}
+
+func sameStreamOwner(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+var errStreamCancelled = errors.New("stream cancelled")
+
+// StreamRequest executes a request and relays the response body through emit
+// as it arrives instead of buffering it. Upstream text/event-stream bodies are
+// split into one message event per server-sent event, anything else is sent
+// as raw chunks. Validation errors are returned before anything is emitted;
+// once streaming has started every outcome is reported as a done event.
+func (rs RequestService) StreamRequest(ctx context.Context, userID *int, dto *model.DTORequest, emit func(model.DTOStreamEvent) error) error {
+	outboundRequest, err := rs.CreateOutboundRequest(dto)
+	if err != nil {
+		return err
+	}
+
+	streamCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	streamCtx, cancelTimeout := context.WithTimeout(streamCtx, maxStreamDuration)
+	defer cancelTimeout()
+
+	streamID, err := rs.streams.add(userID, cancel)
+	if err != nil {
+		return err
+	}
+	defer rs.streams.remove(streamID)
+
+	startTime := time.Now()
+	if err := emit(model.DTOStreamEvent{Type: model.StreamEventStream, Timestamp: startTime, StreamID: streamID}); err != nil {
+		return nil
+	}
+
+	var bodyReader io.Reader
+	if len(outboundRequest.Body) > 0 {
+		bodyReader = bytes.NewReader(outboundRequest.Body)
+	}
+	httpRequest, err := http.NewRequestWithContext(streamCtx, outboundRequest.Method, outboundRequest.URL.String(), bodyReader)
+	if err != nil {
+		emit(streamDoneEvent(startTime, 0, "error", fmt.Sprintf("failed to create http request: %v", err)))
+		return nil
+	}
+	httpRequest.Header = outboundRequest.Headers.Clone()
+
+	// The request timeout only bounds the wait for response headers, the body
+	// itself may keep streaming up to maxStreamDuration.
+	headerTimer := time.AfterFunc(outboundRequest.Timeout, func() { cancel(ErrRequestTimeout) })
+	httpResponse, err := rs.httpClient(outboundRequest).Do(httpRequest)
+	headerTimer.Stop()
+	if err != nil {
+		emit(streamDoneEvent(startTime, 0, streamEndReason(streamCtx), fmt.Sprintf("failed to execute request to target server: %v", err)))
+		return nil
+	}
+	defer httpResponse.Body.Close()
+
+	if err := emit(model.DTOStreamEvent{
+		Type:       model.StreamEventResponse,
+		Timestamp:  time.Now(),
+		Elapsed:    time.Since(startTime),
+		StatusCode: httpResponse.StatusCode,
+		Proto:      httpResponse.Proto,
+		Headers:    httpResponse.Header,
+	}); err != nil {
+		return nil
+	}
+
+	wireReader := &countingReader{r: httpResponse.Body}
+	reader, closeDecoder, err := newContentDecoder(wireReader, parseContentEncoding(httpResponse.Header.Get("Content-Encoding")))
+	if err != nil {
+		emit(streamDoneEvent(startTime, 0, "error", err.Error()))
+		return nil
+	}
+	defer closeDecoder()
+
+	mediaType, _, _ := mime.ParseMediaType(httpResponse.Header.Get("Content-Type"))
+	if mediaType == "text/event-stream" {
+		err = relayServerSentEvents(reader, startTime, emit)
+	} else {
+		err = relayChunks(reader, startTime, emit)
+	}
+
+	reason, errMsg := "eof", ""
+	if err != nil {
+		reason, errMsg = streamEndReason(streamCtx), err.Error()
+	}
+	emit(streamDoneEvent(startTime, wireReader.n, reason, errMsg))
+	return nil
+}
+
+// CancelStream stops a running stream started by StreamRequest.
+func (rs RequestService) CancelStream(userID *int, streamID string) error {
+	if !rs.streams.cancel(streamID, userID) {
+		return ErrStreamNotFound
+	}
+	return nil
+}
+
+// StopStreams cancels every running stream so the connections relaying them
+// close before the server is shut down.
+func (rs RequestService) StopStreams() {
+	rs.streams.shutdown()
+}
+
+// streamEndReason explains why a stream context ended, or "error" when the
+// stream failed on its own.
+func streamEndReason(ctx context.Context) string {
+	switch cause := context.Cause(ctx); {
+	case errors.Is(cause, errStreamCancelled):
+		return "cancelled"
+	case errors.Is(cause, errServerShutdown):
+		return "shutdown"
+	case errors.Is(cause, ErrRequestTimeout), errors.Is(cause, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(cause, context.Canceled):
+		return "client_closed"
+	}
+	return "error"
+}
+
+func streamDoneEvent(startTime time.Time, size int64, reason, errMsg string) model.DTOStreamEvent {
+	return model.DTOStreamEvent{
+		Type:      model.StreamEventDone,
+		Timestamp: time.Now(),
+		Elapsed:   time.Since(startTime),
+		Size:      size,
+		Reason:    reason,
+		Error:     errMsg,
+	}
+}
+
+// relayChunks emits the body in the pieces it arrives in. Text is sent as is,
+// anything that is not valid UTF-8 is sent as base64.
+func relayChunks(reader io.Reader, startTime time.Time, emit func(model.DTOStreamEvent) error) error {
+	buf := make([]byte, streamChunkSize)
+	for {
+		n, err := reader.Read(buf)
+		if n > 0 {
+			event := model.DTOStreamEvent{
+				Type:      model.StreamEventChunk,
+				Timestamp: time.Now(),
+				Elapsed:   time.Since(startTime),
+				Size:      int64(n),
+			}
+			if utf8.Valid(buf[:n]) {
+				event.Data = string(buf[:n])
+			} else {
+				event.DataBase64 = base64.StdEncoding.EncodeToString(buf[:n])
+			}
+			if emitErr := emit(event); emitErr != nil {
+				return emitErr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// relayServerSentEvents parses an upstream text/event-stream body following
+// the WHATWG event stream format and emits one message per dispatched event.
+func relayServerSentEvents(reader io.Reader, startTime time.Time, emit func(model.DTOStreamEvent) error) error {
+	// The buffer bounds a line, so an upstream that never sends a newline
+	// cannot make it grow without limit.
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStreamEventSize+2)
+
+	var eventType, lastID string
+	var data strings.Builder
+	hasData := false
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if line == "" {
+			if hasData {
+				if emitErr := emit(model.DTOStreamEvent{
+					Type:      model.StreamEventMessage,
+					Timestamp: time.Now(),
+					Elapsed:   time.Since(startTime),
+					Event:     eventType,
+					ID:        lastID,
+					Data:      data.String(),
+					Size:      int64(data.Len()),
+				}); emitErr != nil {
+					return emitErr
+				}
+			}
+			eventType, hasData = "", false
+			data.Reset()
+			continue
+		}
+
+		if !strings.HasPrefix(line, ":") {
+			field, value, _ := strings.Cut(line, ":")
+			value = strings.TrimPrefix(value, " ")
+			switch field {
+			case "event":
+				eventType = value
+			case "data":
+				if hasData {
+					data.WriteByte('\n')
+				}
+				data.WriteString(value)
+				hasData = true
+				if data.Len() > maxStreamEventSize {
+					return fmt.Errorf("server-sent event exceeds %d bytes", maxStreamEventSize)
+				}
+			case "id":
+				if !strings.ContainsRune(value, 0) {
+					lastID = value
+				}
+			}
+		}
+	}
+
+	// An event that was not terminated by a blank line before the stream
+	// ended is discarded, as the format requires.
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return fmt.Errorf("server-sent event exceeds %d bytes", maxStreamEventSize)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/suar-net/suar-be/internal/model"
+)
+
+// endlessReader returns the same byte forever, like an upstream that never
+// ends its line.
+type endlessReader byte
+
+func (r endlessReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = byte(r)
+	}
+	return len(p), nil
+}
+
+func TestRelayServerSentEvents(t *testing.T) {
+	type message struct{ Event, ID, Data string }
+	tests := []struct {
+		name   string
+		stream string
+		want   []message
+	}{
+		{"single", "data: hello\n\n", []message{{Data: "hello"}}},
+		{"multi line data", "data: a\ndata: b\n\n", []message{{Data: "a\nb"}}},
+		{"event and id", "event: tick\nid: 7\ndata: x\n\n", []message{{"tick", "7", "x"}}},
+		{"id carries over", "id: 1\ndata: a\n\ndata: b\n\n", []message{{ID: "1", Data: "a"}, {ID: "1", Data: "b"}}},
+		{"crlf", "data: a\r\n\r\n", []message{{Data: "a"}}},
+		{"comments and no data", ": ping\n\nevent: x\n\n", nil},
+		{"no space after colon", "data:a\n\n", []message{{Data: "a"}}},
+		{"unterminated event dropped", "data: a\n\ndata: b\n", []message{{Data: "a"}}},
+		{"id with nul ignored", "id: a\x00b\ndata: x\n\n", []message{{Data: "x"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []message
+			err := relayServerSentEvents(strings.NewReader(tt.stream), time.Now(), func(e model.DTOStreamEvent) error {
+				got = append(got, message{e.Event, e.ID, e.Data})
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRelayServerSentEventsBoundsLines(t *testing.T) {
+	emit := func(model.DTOStreamEvent) error { return nil }
+	err := relayServerSentEvents(endlessReader('a'), time.Now(), emit)
+	if err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Fatalf("endless line: err = %v, want a size error", err)
+	}
+
+	long := strings.Repeat("data: "+strings.Repeat("a", 1000)+"\n", maxStreamEventSize/1000+1) + "\n"
+	err = relayServerSentEvents(strings.NewReader(long), time.Now(), emit)
+	if err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Fatalf("large event: err = %v, want a size error", err)
+	}
+}
+
+func TestRelayChunks(t *testing.T) {
+	var events []model.DTOStreamEvent
+	err := relayChunks(io.MultiReader(strings.NewReader("text"), strings.NewReader("\xff\x00")), time.Now(), func(e model.DTOStreamEvent) error {
+		events = append(events, e)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Data != "text" || events[1].DataBase64 != "/wA=" || events[1].Size != 2 {
+		t.Fatalf("unexpected events %+v", events)
+	}
+
+	stop := errors.New("client gone")
+	if err := relayChunks(strings.NewReader("x"), time.Now(), func(model.DTOStreamEvent) error { return stop }); !errors.Is(err, stop) {
+		t.Errorf("emit error = %v, want it returned", err)
+	}
+}
+
+func TestStreamRegistry(t *testing.T) {
+	r := newStreamRegistry()
+	var canceled []error
+	record := func(cause error) { canceled = append(canceled, cause) }
+	alice, bob := 1, 2
+
+	owned, err := r.add(&alice, record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	anonymous, err := r.add(nil, record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.cancel(owned, nil) || r.cancel(owned, &bob) {
+		t.Error("a stream of a user was cancelled by someone else")
+	}
+	if !r.cancel(owned, &alice) || !r.cancel(anonymous, &bob) {
+		t.Error("cancel refused")
+	}
+	if len(canceled) != 2 || !errors.Is(canceled[0], errStreamCancelled) {
+		t.Errorf("canceled = %v", canceled)
+	}
+	r.remove(owned)
+	r.remove(anonymous)
+
+	for range maxStreamsPerUser {
+		if _, err := r.add(&alice, record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := r.add(&alice, record); !errors.Is(err, ErrStreamLimitReached) {
+		t.Errorf("user above the limit: err = %v", err)
+	}
+	if _, err := r.add(&bob, record); err != nil {
+		t.Errorf("other user: %v", err)
+	}
+	for range maxAnonymousStreams {
+		if _, err := r.add(nil, record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := r.add(nil, record); !errors.Is(err, ErrStreamLimitReached) {
+		t.Errorf("anonymous above the limit: err = %v", err)
+	}
+
+	canceled = nil
+	r.shutdown()
+	if want := maxStreamsPerUser + 1 + maxAnonymousStreams; len(canceled) != want || !errors.Is(canceled[0], errServerShutdown) {
+		t.Errorf("shutdown canceled %d streams (%v), want %d", len(canceled), canceled, want)
+	}
+	if _, err := r.add(&bob, record); !errors.Is(err, errServerShutdown) {
+		t.Errorf("after shutdown: err = %v", err)
+	}
+}
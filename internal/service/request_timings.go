@@ -0,0 +1,110 @@
+package service
+
+import (
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/suar-net/suar-be/internal/model"
+)
+
+// requestTrace records when each phase of a request starts and ends. The
+// hooks may run on transport goroutines, so every field is guarded by mu.
+type requestTrace struct {
+	mu sync.Mutex
+
+	start                            time.Time
+	dnsStart, dnsDone                time.Time
+	connectStart, connectDone        time.Time
+	tlsStart, tlsDone                time.Time
+	gotConn, wroteRequest, firstByte time.Time
+}
+
+func newRequestTrace(start time.Time) *requestTrace {
+	return &requestTrace{start: start}
+}
+
+func (t *requestTrace) set(field *time.Time) {
+	t.mu.Lock()
+	*field = time.Now()
+	t.mu.Unlock()
+}
+
+// clientTrace returns the hooks that fill t.
+func (t *requestTrace) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GetConn: func(string) {
+			// A retried request starts over on a new connection.
+			t.mu.Lock()
+			t.dnsStart, t.dnsDone = time.Time{}, time.Time{}
+			t.connectStart, t.connectDone = time.Time{}, time.Time{}
+			t.tlsStart, t.tlsDone = time.Time{}, time.Time{}
+			t.mu.Unlock()
+		},
+		DNSStart: func(httptrace.DNSStartInfo) { t.set(&t.dnsStart) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { t.set(&t.dnsDone) },
+		ConnectStart: func(string, string) {
+			// Dialers racing IPv4 and IPv6 call this more than once, the
+			// first attempt is when connecting started.
+			t.mu.Lock()
+			if t.connectStart.IsZero() {
+				t.connectStart = time.Now()
+			}
+			t.mu.Unlock()
+		},
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				t.set(&t.connectDone)
+			}
+		},
+		TLSHandshakeStart:    func() { t.set(&t.tlsStart) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { t.set(&t.tlsDone) },
+		GotConn:              func(httptrace.GotConnInfo) { t.set(&t.gotConn) },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { t.set(&t.wroteRequest) },
+		GotFirstResponseByte: func() { t.set(&t.firstByte) },
+	}
+}
+
+// timings splits the time from start to end into HAR phases. end is when the
+// response body has been read.
+func (t *requestTrace) timings(end time.Time) *model.RequestTimings {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.gotConn.IsZero() || t.wroteRequest.IsZero() || t.firstByte.IsZero() {
+		return nil
+	}
+	timings := &model.RequestTimings{
+		DNS:     phaseMs(t.dnsStart, t.dnsDone),
+		Connect: phaseMs(t.connectStart, t.connectDone),
+		TLS:     phaseMs(t.tlsStart, t.tlsDone),
+		Send:    ms(t.wroteRequest.Sub(t.gotConn)),
+		Wait:    ms(t.firstByte.Sub(t.wroteRequest)),
+		Receive: ms(end.Sub(t.firstByte)),
+	}
+	// Connect covers the TLS handshake, as in HAR.
+	if timings.Connect >= 0 && timings.TLS >= 0 {
+		timings.Connect += timings.TLS
+	}
+	blocked := t.gotConn.Sub(t.start)
+	for _, phase := range []float64{timings.DNS, timings.Connect} {
+		if phase > 0 {
+			blocked -= time.Duration(phase * float64(time.Millisecond))
+		}
+	}
+	timings.Blocked = max(ms(blocked), 0)
+	return timings
+}
+
+// phaseMs is the length of a phase in milliseconds, or -1 if it did not run.
+func phaseMs(start, end time.Time) float64 {
+	if start.IsZero() || end.IsZero() {
+		return -1
+	}
+	return ms(end.Sub(start))
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"testing"
+	"time"
+)
+
+func TestRequestTraceTimings(t *testing.T) {
+	base := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	at := func(ms int) time.Time { return base.Add(time.Duration(ms) * time.Millisecond) }
+
+	tests := []struct {
+		name  string
+		trace *requestTrace
+		end   time.Time
+		want  *[7]float64 // blocked, dns, connect, tls, send, wait, receive
+	}{
+		{
+			name: "new tls connection",
+			trace: &requestTrace{
+				start: at(0), dnsStart: at(1), dnsDone: at(3), connectStart: at(3), connectDone: at(8),
+				tlsStart: at(8), tlsDone: at(14), gotConn: at(15), wroteRequest: at(16), firstByte: at(56),
+			},
+			end:  at(60),
+			want: &[7]float64{2, 2, 11, 6, 1, 40, 4},
+		},
+		{
+			name:  "reused connection",
+			trace: &requestTrace{start: at(0), gotConn: at(1), wroteRequest: at(2), firstByte: at(12)},
+			end:   at(12),
+			want:  &[7]float64{1, -1, -1, -1, 1, 10, 0},
+		},
+		{
+			name:  "plain http connection",
+			trace: &requestTrace{start: at(0), connectStart: at(0), connectDone: at(4), gotConn: at(4), wroteRequest: at(5), firstByte: at(9)},
+			end:   at(10),
+			want:  &[7]float64{0, -1, 4, -1, 1, 4, 1},
+		},
+		{
+			name:  "no response",
+			trace: &requestTrace{start: at(0), gotConn: at(1), wroteRequest: at(2)},
+			end:   at(3),
+		},
+	}
+	for _, tt := range tests {
+		got := tt.trace.timings(tt.end)
+		if tt.want == nil {
+			if got != nil {
+				t.Errorf("%s: timings = %+v, want nil", tt.name, got)
+			}
+			continue
+		}
+		if got == nil {
+			t.Errorf("%s: timings = nil", tt.name)
+			continue
+		}
+		phases := [7]float64{got.Blocked, got.DNS, got.Connect, got.TLS, got.Send, got.Wait, got.Receive}
+		if phases != *tt.want {
+			t.Errorf("%s: timings = %v, want %v", tt.name, phases, *tt.want)
+		}
+	}
+}
+
+func TestRequestTraceAgainstServer(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	client := server.Client()
+
+	send := func() (*requestTrace, time.Time) {
+		trace := newRequestTrace(time.Now())
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace.clientTrace()))
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		return trace, time.Now()
+	}
+
+	trace, end := send()
+	first := trace.timings(end)
+	if first == nil {
+		t.Fatal("no timings for the first request")
+	}
+	if first.Connect < 0 || first.TLS < 0 || first.Connect < first.TLS {
+		t.Errorf("first request: connect %v, tls %v", first.Connect, first.TLS)
+	}
+	if first.Wait < 20 {
+		t.Errorf("first request: wait = %v, want at least the 20ms the server slept", first.Wait)
+	}
+
+	trace, end = send()
+	second := trace.timings(end)
+	if second == nil || second.Connect != -1 || second.TLS != -1 || second.DNS != -1 {
+		t.Errorf("reused connection: timings = %+v", second)
+	}
+}
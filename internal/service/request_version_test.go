@@ -0,0 +1,85 @@
+package service
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/suar-net/suar-be/internal/config"
+	"github.com/suar-net/suar-be/internal/model"
+)
+
+func TestCreateOutboundRequestHTTPVersion(t *testing.T) {
+	rs := NewRequestService(nil, nil, config.ResponseConfig{})
+	tests := []struct {
+		url     string
+		version string
+		want    string
+		wantErr bool
+	}{
+		{"https://93.184.216.34/", "", model.HTTPVersionAuto, false},
+		{"https://93.184.216.34/", model.HTTPVersion1, model.HTTPVersion1, false},
+		{"https://93.184.216.34/", model.HTTPVersion2, model.HTTPVersion2, false},
+		{"http://93.184.216.34/", model.HTTPVersion2, "", true},
+		{"http://93.184.216.34/", model.HTTPVersionH2C, model.HTTPVersionH2C, false},
+		{"https://93.184.216.34/", model.HTTPVersionH2C, "", true},
+	}
+	for _, tt := range tests {
+		outbound, err := rs.CreateOutboundRequest(&model.DTORequest{Method: "GET", URL: tt.url, HTTPVersion: tt.version})
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidInput) {
+				t.Errorf("%s %q: err = %v, want ErrInvalidInput", tt.url, tt.version, err)
+			}
+			continue
+		}
+		if err != nil || outbound.HTTPVersion != tt.want {
+			t.Errorf("%s %q: version %v, err %v, want %s", tt.url, tt.version, outbound, err, tt.want)
+		}
+	}
+}
+
+func TestHTTPClientsNegotiateTheirVersion(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	})
+	tlsServer := httptest.NewUnstartedServer(handler)
+	tlsServer.EnableHTTP2 = true
+	tlsServer.StartTLS()
+	defer tlsServer.Close()
+
+	h2cServer := httptest.NewUnstartedServer(handler)
+	h2cServer.Config.Protocols = new(http.Protocols)
+	h2cServer.Config.Protocols.SetHTTP1(true)
+	h2cServer.Config.Protocols.SetUnencryptedHTTP2(true)
+	h2cServer.Start()
+	defer h2cServer.Close()
+
+	rs := NewRequestService(nil, nil, config.ResponseConfig{})
+	for _, client := range rs.httpClients {
+		client.Transport.(*http.Transport).TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	tests := []struct {
+		version string
+		url     string
+		want    string
+	}{
+		{model.HTTPVersionAuto, tlsServer.URL, "HTTP/2.0"},
+		{model.HTTPVersion1, tlsServer.URL, "HTTP/1.1"},
+		{model.HTTPVersion2, tlsServer.URL, "HTTP/2.0"},
+		{model.HTTPVersionAuto, h2cServer.URL, "HTTP/1.1"},
+		{model.HTTPVersionH2C, h2cServer.URL, "HTTP/2.0"},
+	}
+	for _, tt := range tests {
+		resp, err := rs.httpClient(&OutboundRequest{HTTPVersion: tt.version}).Get(tt.url)
+		if err != nil {
+			t.Errorf("%s %s: %v", tt.version, tt.url, err)
+			continue
+		}
+		resp.Body.Close()
+		if resp.Proto != tt.want {
+			t.Errorf("%s %s: negotiated %s, want %s", tt.version, tt.url, resp.Proto, tt.want)
+		}
+	}
+}
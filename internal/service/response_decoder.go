@@ -0,0 +1,216 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+)
+
+// countingReader counts the bytes read from the underlying reader, used to
+// report the size of the body as it was received on the wire.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// replayReader keeps the bytes read through it until stop is called, so the
+// bytes a decoder consumed while checking its header can be given back when
+// it rejects the stream.
+type replayReader struct {
+	r        io.Reader
+	recorded bytes.Buffer
+	stopped  bool
+}
+
+func (r *replayReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if !r.stopped {
+		r.recorded.Write(p[:n])
+	}
+	return n, err
+}
+
+// stop ends recording and drops what was recorded.
+func (r *replayReader) stop() {
+	r.stopped = true
+	r.recorded = bytes.Buffer{}
+}
+
+// replay ends recording and returns a reader for the whole stream, starting
+// with the bytes recorded so far.
+func (r *replayReader) replay() io.Reader {
+	r.stopped = true
+	return io.MultiReader(bytes.NewReader(r.recorded.Bytes()), r.r)
+}
+
+// parseContentEncoding returns the codings listed in a Content-Encoding header
+// in the order they were applied, skipping "identity".
+func parseContentEncoding(header string) []string {
+	var codings []string
+	for _, coding := range strings.Split(header, ",") {
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" || coding == "identity" {
+			continue
+		}
+		codings = append(codings, coding)
+	}
+	return codings
+}
+
+// newContentDecoder wraps body with a decoder for every coding, undoing them
+// in reverse order. The returned close function releases decoder resources.
+func newContentDecoder(body io.Reader, codings []string) (io.Reader, func(), error) {
+	var closers []func()
+	closeAll := func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			closers[i]()
+		}
+	}
+
+	reader := body
+	for i := len(codings) - 1; i >= 0; i-- {
+		switch codings[i] {
+		case "gzip", "x-gzip":
+			gz, err := gzip.NewReader(reader)
+			if err != nil {
+				closeAll()
+				return nil, nil, fmt.Errorf("invalid gzip stream: %w", err)
+			}
+			closers = append(closers, func() { gz.Close() })
+			reader = gz
+		case "deflate":
+			// Most servers send zlib-wrapped data as the spec requires, but some
+			// send a raw deflate stream, so peek at the header to tell them apart.
+			buffered := bufio.NewReader(reader)
+			header, _ := buffered.Peek(2)
+			if len(header) == 2 && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+				zr, err := zlib.NewReader(buffered)
+				if err != nil {
+					closeAll()
+					return nil, nil, fmt.Errorf("invalid deflate stream: %w", err)
+				}
+				closers = append(closers, func() { zr.Close() })
+				reader = zr
+			} else {
+				fr := flate.NewReader(buffered)
+				closers = append(closers, func() { fr.Close() })
+				reader = fr
+			}
+		case "br":
+			reader = brotli.NewReader(reader)
+		case "zstd":
+			zr, err := zstd.NewReader(reader, zstd.WithDecoderConcurrency(1))
+			if err != nil {
+				closeAll()
+				return nil, nil, fmt.Errorf("invalid zstd stream: %w", err)
+			}
+			closers = append(closers, zr.Close)
+			reader = zr
+		default:
+			closeAll()
+			return nil, nil, fmt.Errorf("unsupported content encoding: %s", codings[i])
+		}
+	}
+
+	return reader, closeAll, nil
+}
+
+// isTextContentType reports whether a media type carries text that should be
+// converted to UTF-8 before it is returned to the client.
+func isTextContentType(mediaType string) bool {
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "+json"),
+		strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+	switch mediaType {
+	case "application/json",
+		"application/xml",
+		"application/javascript",
+		"application/ecmascript",
+		"application/x-www-form-urlencoded",
+		"application/graphql":
+		return true
+	}
+	return false
+}
+
+// decodeCharset converts a text body to UTF-8. The charset is taken from the
+// Content-Type header when present, otherwise it is sniffed from the body.
+// It returns the converted body and the name of the source charset, or the
+// body unchanged and an empty name when the body is not text.
+func decodeCharset(body []byte, contentType string) ([]byte, string, error) {
+	if len(body) == 0 {
+		return body, "", nil
+	}
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil && contentType != "" {
+		mediaType = strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	}
+	if mediaType == "" {
+		mediaType, _, _ = mime.ParseMediaType(http.DetectContentType(body))
+	}
+	if !isTextContentType(mediaType) {
+		return body, "", nil
+	}
+
+	var enc encoding.Encoding
+	name := strings.ToLower(params["charset"])
+	if name != "" {
+		enc, name = charset.Lookup(name)
+		if enc == nil {
+			return body, "", fmt.Errorf("unsupported charset: %s", params["charset"])
+		}
+	} else {
+		if utf8.Valid(body) {
+			return body, "utf-8", nil
+		}
+		enc, name, _ = charset.DetermineEncoding(body, contentType)
+	}
+
+	if name == "utf-8" || enc == encoding.Nop {
+		return body, name, nil
+	}
+
+	decoded, err := enc.NewDecoder().Bytes(body)
+	if err != nil {
+		return body, "", fmt.Errorf("failed to decode %s body: %w", name, err)
+	}
+	return bytes.TrimPrefix(decoded, []byte("\xef\xbb\xbf")), name, nil
+}
+
+// trimIncompleteRune drops a UTF-8 sequence cut in half at the end of a
+// truncated body, so a text preview stays valid UTF-8.
+func trimIncompleteRune(body []byte) []byte {
+	for i := 1; i < utf8.UTFMax && i <= len(body); i++ {
+		if !utf8.RuneStart(body[len(body)-i]) {
+			continue
+		}
+		if !utf8.FullRune(body[len(body)-i:]) {
+			return body[:len(body)-i]
+		}
+		break
+	}
+	return body
+}
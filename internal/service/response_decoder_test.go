@@ -0,0 +1,176 @@
+package service
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/suar-net/suar-be/internal/config"
+)
+
+func compress(t *testing.T, coding string, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch coding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "zlib":
+		w = zlib.NewWriter(&buf)
+	case "flate":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	case "zstd":
+		var err error
+		if w, err = zstd.NewWriter(&buf); err != nil {
+			t.Fatal(err)
+		}
+	default:
+		t.Fatalf("unknown coding %s", coding)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestParseContentEncoding(t *testing.T) {
+	tests := []struct {
+		header string
+		want   []string
+	}{
+		{"", nil},
+		{"identity", nil},
+		{"gzip", []string{"gzip"}},
+		{"Deflate, GZIP", []string{"deflate", "gzip"}},
+		{" br ,identity, zstd", []string{"br", "zstd"}},
+	}
+	for _, tt := range tests {
+		if got := parseContentEncoding(tt.header); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseContentEncoding(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestNewContentDecoder(t *testing.T) {
+	plain := []byte(strings.Repeat("hello decoder ", 200))
+	tests := []struct {
+		name    string
+		body    []byte
+		codings []string
+	}{
+		{"gzip", compress(t, "gzip", plain), []string{"gzip"}},
+		{"x-gzip", compress(t, "gzip", plain), []string{"x-gzip"}},
+		{"deflate zlib", compress(t, "zlib", plain), []string{"deflate"}},
+		{"deflate raw", compress(t, "flate", plain), []string{"deflate"}},
+		{"br", compress(t, "br", plain), []string{"br"}},
+		{"zstd", compress(t, "zstd", plain), []string{"zstd"}},
+		{"stacked", compress(t, "br", compress(t, "gzip", plain)), []string{"gzip", "br"}},
+		{"none", plain, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader, closeDecoder, err := newContentDecoder(bytes.NewReader(tt.body), tt.codings)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer closeDecoder()
+			got, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, plain) {
+				t.Errorf("decoded %d bytes, want %d", len(got), len(plain))
+			}
+		})
+	}
+
+	for _, codings := range [][]string{{"gzip"}, {"compress"}} {
+		if _, _, err := newContentDecoder(strings.NewReader("not compressed"), codings); err == nil {
+			t.Errorf("newContentDecoder(%v) on plain text succeeded, want an error", codings)
+		}
+	}
+}
+
+func TestHttpResponseToDTOResponseKeepsUndecodableBody(t *testing.T) {
+	body := "this body claims to be gzip but is not"
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Encoding": {"gzip"}, "Content-Type": {"text/plain"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+	rs := NewRequestService(nil, nil, config.ResponseConfig{})
+	dto, err := rs.HttpResponseToDTOResponse(resp, &OutboundRequest{}, time.Millisecond, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(dto.Body) != body {
+		t.Errorf("body = %q, want %q", dto.Body, body)
+	}
+	if dto.WireSize != int64(len(body)) {
+		t.Errorf("wire size = %d, want %d", dto.WireSize, len(body))
+	}
+	if !strings.Contains(dto.Error, "body returned as received") {
+		t.Errorf("error = %q, want the fallback to be reported", dto.Error)
+	}
+}
+
+func TestDecodeCharset(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        []byte
+		contentType string
+		want        string
+		charset     string
+		wantErr     bool
+	}{
+		{"utf-8 json", []byte(`{"a":"é"}`), "application/json", `{"a":"é"}`, "utf-8", false},
+		{"latin1 header", []byte("caf\xe9"), "text/plain; charset=ISO-8859-1", "café", "windows-1252", false},
+		{"shift_jis", []byte("\x93\xfa\x96\x7b"), "text/plain; charset=shift_jis", "日本", "shift_jis", false},
+		{"binary untouched", []byte{0x89, 'P', 'N', 'G'}, "image/png", "\x89PNG", "", false},
+		{"unknown charset", []byte("abc"), "text/plain; charset=nope", "abc", "", true},
+		{"empty", nil, "text/plain", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, name, err := decodeCharset(tt.body, tt.contentType)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want || name != tt.charset {
+				t.Errorf("got %q (%s), want %q (%s)", got, name, tt.want, tt.charset)
+			}
+		})
+	}
+}
+
+func TestTrimIncompleteRune(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"abc", "abc"},
+		{"ab\xc3", "ab"},
+		{"ab\xe6\x97", "ab"},
+		{"abé", "abé"},
+		{"日本", "日本"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := string(trimIncompleteRune([]byte(tt.in))); got != tt.want {
+			t.Errorf("trimIncompleteRune(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"mime"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/suar-net/suar-be/internal/model"
+)
+
+// detectMimeType prefers the Content-Type sent by the target server and only
+// falls back to sniffing the body when the header is missing or generic.
+func detectMimeType(body []byte, contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err == nil && mediaType != "application/octet-stream" && mediaType != "binary/octet-stream" {
+		return mediaType
+	}
+	if len(body) == 0 {
+		return mediaType
+	}
+	detected, _, _ := mime.ParseMediaType(mimetype.Detect(body).String())
+	return detected
+}
+
+// classifyContent maps a media type to the content kind used by clients to
+// pick a renderer.
+func classifyContent(mediaType string, body []byte) model.ContentKind {
+	switch {
+	case mediaType == "application/json", strings.HasSuffix(mediaType, "+json"):
+		return model.ContentKindJSON
+	case mediaType == "application/xml", mediaType == "text/xml", strings.HasSuffix(mediaType, "+xml"):
+		return model.ContentKindXML
+	case mediaType == "text/html", mediaType == "application/xhtml+xml":
+		return model.ContentKindHTML
+	case strings.HasPrefix(mediaType, "image/"):
+		return model.ContentKindImage
+	case mediaType == "application/pdf":
+		return model.ContentKindPDF
+	case isTextContentType(mediaType):
+		return model.ContentKindText
+	}
+
+	// Servers often label text as something generic, so check what the bytes
+	// actually are before giving up on rendering them as text.
+	detected := mimetype.Detect(body)
+	for m := detected; m != nil; m = m.Parent() {
+		if m.Is("application/json") {
+			return model.ContentKindJSON
+		}
+		if m.Is("text/plain") && utf8.Valid(body) {
+			return model.ContentKindText
+		}
+	}
+	return model.ContentKindBinary
+}
+
+// prettyJSON returns an indented copy of body, or nil when it is not JSON.
+func prettyJSON(body []byte) []byte {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, body, "", "  "); err != nil {
+		return nil
+	}
+	return buf.Bytes()
+}
+
+// prettyXML re-encodes body with indentation, or returns nil when it is not
+// well-formed XML.
+func prettyXML(body []byte) []byte {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	decoder.Strict = false
+	// The body has already been converted to UTF-8 by decodeCharset, so the
+	// encoding named in the XML declaration no longer applies.
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+
+	var buf bytes.Buffer
+	encoder := xml.NewEncoder(&buf)
+	encoder.Indent("", "  ")
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil
+		}
+		// Whitespace between elements is replaced by the encoder's indentation.
+		if data, ok := token.(xml.CharData); ok && len(bytes.TrimSpace(data)) == 0 {
+			continue
+		}
+		if err := encoder.EncodeToken(xml.CopyToken(token)); err != nil {
+			return nil
+		}
+	}
+	if err := encoder.Flush(); err != nil {
+		return nil
+	}
+	return buf.Bytes()
+}
+
+// renderBody fills the rendering metadata of a response so clients do not
+// have to sniff the body themselves. Textual bodies are returned as text,
+// everything else as base64.
+func renderBody(dtoResponse *model.DTOResponse, contentType string) {
+	body := dtoResponse.Body
+	dtoResponse.MimeType = detectMimeType(body, contentType)
+	dtoResponse.ContentKind = classifyContent(dtoResponse.MimeType, body)
+
+	switch dtoResponse.ContentKind {
+	case model.ContentKindJSON, model.ContentKindXML, model.ContentKindHTML, model.ContentKindText:
+		if utf8.Valid(body) {
+			dtoResponse.BodyText = string(body)
+		} else {
+			dtoResponse.BodyBase64 = base64.StdEncoding.EncodeToString(body)
+		}
+	default:
+		if len(body) > 0 {
+			dtoResponse.BodyBase64 = base64.StdEncoding.EncodeToString(body)
+		}
+	}
+
+	switch dtoResponse.ContentKind {
+	case model.ContentKindJSON:
+		dtoResponse.BodyPretty = string(prettyJSON(body))
+	case model.ContentKindXML:
+		dtoResponse.BodyPretty = string(prettyXML(body))
+	}
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/suar-net/suar-be/internal/model"
+)
+
+func TestDetectMimeType(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	tests := []struct {
+		name        string
+		body        []byte
+		contentType string
+		want        string
+	}{
+		{"header wins", []byte("{}"), "text/plain; charset=utf-8", "text/plain"},
+		{"generic header sniffed", png, "application/octet-stream", "image/png"},
+		{"missing header sniffed", png, "", "image/png"},
+		{"empty body", nil, "application/octet-stream", "application/octet-stream"},
+	}
+	for _, tt := range tests {
+		if got := detectMimeType(tt.body, tt.contentType); got != tt.want {
+			t.Errorf("%s: detectMimeType = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestClassifyContent(t *testing.T) {
+	tests := []struct {
+		mediaType string
+		body      string
+		want      model.ContentKind
+	}{
+		{"application/json", `{}`, model.ContentKindJSON},
+		{"application/problem+json", `{}`, model.ContentKindJSON},
+		{"text/xml", `<a/>`, model.ContentKindXML},
+		{"application/atom+xml", `<feed/>`, model.ContentKindXML},
+		{"text/html", `<p>`, model.ContentKindHTML},
+		{"image/svg+xml", `<svg/>`, model.ContentKindXML},
+		{"image/png", "\x89PNG", model.ContentKindImage},
+		{"application/pdf", "%PDF", model.ContentKindPDF},
+		{"text/csv", "a,b", model.ContentKindText},
+		{"application/octet-stream", `{"a":1}`, model.ContentKindJSON},
+		{"application/octet-stream", "plain words", model.ContentKindText},
+		{"application/octet-stream", "\x00\x01\x02\xff", model.ContentKindBinary},
+	}
+	for _, tt := range tests {
+		if got := classifyContent(tt.mediaType, []byte(tt.body)); got != tt.want {
+			t.Errorf("classifyContent(%q, %q) = %q, want %q", tt.mediaType, tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestPrettyJSONAndXML(t *testing.T) {
+	if got := string(prettyJSON([]byte(`{"a":[1,2]}`))); got != "{\n  \"a\": [\n    1,\n    2\n  ]\n}" {
+		t.Errorf("prettyJSON = %q", got)
+	}
+	if got := prettyJSON([]byte(`{"a":`)); got != nil {
+		t.Errorf("prettyJSON of invalid JSON = %q, want nil", got)
+	}
+	if got := string(prettyXML([]byte(`<a> <b>x</b></a>`))); got != "<a>\n  <b>x</b>\n</a>" {
+		t.Errorf("prettyXML = %q", got)
+	}
+	if got := prettyXML([]byte(`<a`)); got != nil {
+		t.Errorf("prettyXML of invalid XML = %q, want nil", got)
+	}
+}
+
+func TestRenderBody(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		contentType string
+		text        string
+		pretty      string
+		base64      string
+	}{
+		{"json", `{"a":1}`, "application/json", `{"a":1}`, "{\n  \"a\": 1\n}", ""},
+		{"text", "hi", "text/plain", "hi", "", ""},
+		{"invalid utf-8 text", "\xff", "text/plain", "", "", "/w=="},
+		{"binary", "\x00\x01", "image/png", "", "", "AAE="},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &model.DTOResponse{Body: []byte(tt.body)}
+			renderBody(resp, tt.contentType)
+			if resp.BodyText != tt.text || resp.BodyPretty != tt.pretty || resp.BodyBase64 != tt.base64 {
+				t.Errorf("got text %q pretty %q base64 %q", resp.BodyText, resp.BodyPretty, resp.BodyBase64)
+			}
+		})
+	}
+}
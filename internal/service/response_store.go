@@ -0,0 +1,267 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// spoolSweepInterval is how often expired spool files are removed and the
+	// disk usage of the spool directory is recounted.
+	spoolSweepInterval = time.Minute
+	spoolTokenLength   = 48
+)
+
+// errSpoolFull is returned by SpoolFile writes once the store's quota is used
+// up.
+var errSpoolFull = errors.New("response spool storage is full")
+
+// SpooledResponse describes a response body that was too large to return
+// inline and was written to disk instead.
+type SpooledResponse struct {
+	Token       string    `json:"-"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	path        string
+}
+
+// ResponseStore keeps large response bodies on local disk for a limited time.
+// The download token is the only credential needed to read a body back, in the
+// same way a pre-signed URL works, so it is generated from crypto/rand.
+//
+// The index lives next to the bodies: a body is stored under the SHA-256 of
+// its token with its description in a .json file beside it, so replicas
+// sharing the directory and a restarted process can serve what was spooled
+// before. The bodies on disk may take at most quota bytes together.
+type ResponseStore struct {
+	dir   string
+	ttl   time.Duration
+	quota int64
+
+	mu   sync.Mutex
+	used int64
+}
+
+// NewResponseStore opens the store and starts removing expired bodies in the
+// background for the lifetime of the process.
+func NewResponseStore(dir string, ttl time.Duration, quota int64) *ResponseStore {
+	s := &ResponseStore{
+		dir:   dir,
+		ttl:   ttl,
+		quota: quota,
+	}
+	s.sweep()
+	go func() {
+		ticker := time.NewTicker(spoolSweepInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.sweep()
+		}
+	}()
+	return s
+}
+
+// SpoolFile is a body being written to the store. Writes fail with
+// errSpoolFull once the store's quota would be exceeded.
+type SpoolFile struct {
+	file  *os.File
+	store *ResponseStore
+	size  int64
+}
+
+func (f *SpoolFile) Write(p []byte) (int, error) {
+	if !f.store.reserve(int64(len(p))) {
+		return 0, errSpoolFull
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	if n < len(p) {
+		f.store.release(int64(len(p) - n))
+	}
+	return n, err
+}
+
+// Create opens a new spool file. The caller writes the body into it and then
+// calls Commit, or Discard when writing fails.
+func (s *ResponseStore) Create() (*SpoolFile, error) {
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+	file, err := os.CreateTemp(s.dir, "partial-*")
+	if err != nil {
+		return nil, err
+	}
+	return &SpoolFile{file: file, store: s}, nil
+}
+
+// Commit registers a fully written spool file and returns its download entry.
+func (s *ResponseStore) Commit(f *SpoolFile, contentType string) (*SpooledResponse, error) {
+	if err := f.file.Close(); err != nil {
+		s.remove(f)
+		return nil, fmt.Errorf("failed to close spool file: %w", err)
+	}
+
+	tokenBytes := make([]byte, spoolTokenLength/2)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		s.remove(f)
+		return nil, fmt.Errorf("failed to generate download token: %w", err)
+	}
+
+	now := time.Now()
+	entry := &SpooledResponse{
+		Token:       hex.EncodeToString(tokenBytes),
+		ContentType: contentType,
+		Size:        f.size,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(s.ttl),
+		path:        s.bodyPath(hex.EncodeToString(tokenBytes)),
+	}
+
+	// The description is written first, a body without one is removed by
+	// the sweep once it is older than the TTL.
+	meta, err := json.Marshal(entry)
+	if err == nil {
+		err = os.WriteFile(entry.path+".json", meta, 0o600)
+	}
+	if err == nil {
+		err = os.Rename(f.file.Name(), entry.path)
+	}
+	if err != nil {
+		os.Remove(entry.path + ".json")
+		s.remove(f)
+		return nil, fmt.Errorf("failed to store spool file: %w", err)
+	}
+	return entry, nil
+}
+
+// Discard closes and deletes a spool file that will not be committed.
+func (s *ResponseStore) Discard(f *SpoolFile) {
+	f.file.Close()
+	s.remove(f)
+}
+
+func (s *ResponseStore) remove(f *SpoolFile) {
+	os.Remove(f.file.Name())
+	s.release(f.size)
+}
+
+// Open returns the spooled body for token. The caller must close the file.
+func (s *ResponseStore) Open(token string) (*SpooledResponse, *os.File, error) {
+	if len(token) != spoolTokenLength {
+		return nil, nil, ErrResponseNotFound
+	}
+	if _, err := hex.DecodeString(token); err != nil {
+		return nil, nil, ErrResponseNotFound
+	}
+
+	path := s.bodyPath(token)
+	entry, err := readSpoolMeta(path + ".json")
+	if err != nil || time.Now().After(entry.ExpiresAt) {
+		return nil, nil, ErrResponseNotFound
+	}
+	entry.Token, entry.path = token, path
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, ErrResponseNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open spooled response: %w", err)
+	}
+	return entry, file, nil
+}
+
+// bodyPath is where the body of token is stored. Only a hash of the token is
+// used, so listing the directory does not reveal download tokens.
+func (s *ResponseStore) bodyPath(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return filepath.Join(s.dir, "response-"+hex.EncodeToString(sum[:]))
+}
+
+func readSpoolMeta(path string) (*SpooledResponse, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entry SpooledResponse
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (s *ResponseStore) reserve(n int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.used+n > s.quota {
+		return false
+	}
+	s.used += n
+	return true
+}
+
+func (s *ResponseStore) release(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.used = max(s.used-n, 0)
+}
+
+// sweep removes expired bodies, and bodies or partial files left behind for
+// longer than the TTL, then recounts the disk usage. Readers that already
+// opened a file keep working until they close it.
+func (s *ResponseStore) sweep() {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	var used int64
+	for _, dirEntry := range entries {
+		name := dirEntry.Name()
+		path := filepath.Join(s.dir, name)
+		info, err := dirEntry.Info()
+		if err != nil {
+			continue
+		}
+		stale := now.Sub(info.ModTime()) > s.ttl
+
+		switch {
+		case strings.HasPrefix(name, "response-") && strings.HasSuffix(name, ".json"):
+			// Descriptions whose body is gone, the body itself is handled
+			// below.
+			if _, err := os.Stat(strings.TrimSuffix(path, ".json")); err != nil && stale {
+				os.Remove(path)
+			}
+		case strings.HasPrefix(name, "response-"):
+			entry, err := readSpoolMeta(path + ".json")
+			if (err != nil && stale) || (err == nil && now.After(entry.ExpiresAt)) {
+				os.Remove(path)
+				os.Remove(path + ".json")
+				continue
+			}
+			used += info.Size()
+		case strings.HasPrefix(name, "partial-"):
+			if stale {
+				os.Remove(path)
+				continue
+			}
+			used += info.Size()
+		}
+	}
+
+	s.mu.Lock()
+	s.used = used
+	s.mu.Unlock()
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func spool(t *testing.T, s *ResponseStore, body string) (*SpooledResponse, error) {
+	t.Helper()
+	file, err := s.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.Copy(file, strings.NewReader(body)); err != nil {
+		s.Discard(file)
+		return nil, err
+	}
+	return s.Commit(file, "text/plain")
+}
+
+func readSpooled(t *testing.T, s *ResponseStore, token string) (string, error) {
+	t.Helper()
+	_, file, err := s.Open(token)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data), nil
+}
+
+func TestResponseStoreRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	s := NewResponseStore(dir, time.Hour, 1<<20)
+	entry, err := spool(t, s, "spooled body")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Size != int64(len("spooled body")) || len(entry.Token) != spoolTokenLength {
+		t.Fatalf("unexpected entry %+v", entry)
+	}
+	if got, err := readSpooled(t, s, entry.Token); err != nil || got != "spooled body" {
+		t.Fatalf("Open = %q, %v", got, err)
+	}
+
+	// A restarted process, or another replica, reads the index from disk.
+	other := NewResponseStore(dir, time.Hour, 1<<20)
+	if got, err := readSpooled(t, other, entry.Token); err != nil || got != "spooled body" {
+		t.Fatalf("Open from another store = %q, %v", got, err)
+	}
+
+	// The token itself does not appear in the directory.
+	names, _ := filepath.Glob(filepath.Join(dir, "*"))
+	for _, name := range names {
+		if strings.Contains(name, entry.Token) {
+			t.Errorf("file %s reveals the token", name)
+		}
+	}
+}
+
+func TestResponseStoreRejectsUnknownTokens(t *testing.T) {
+	s := NewResponseStore(t.TempDir(), time.Hour, 1<<20)
+	for _, token := range []string{"", "abc", strings.Repeat("0", spoolTokenLength), "../../etc/passwd" + strings.Repeat("a", 32)} {
+		if _, _, err := s.Open(token); !errors.Is(err, ErrResponseNotFound) {
+			t.Errorf("Open(%q) = %v, want ErrResponseNotFound", token, err)
+		}
+	}
+}
+
+func TestResponseStoreQuota(t *testing.T) {
+	s := NewResponseStore(t.TempDir(), time.Hour, 10)
+	if _, err := spool(t, s, "123456"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := spool(t, s, "123456"); !errors.Is(err, errSpoolFull) {
+		t.Fatalf("second spool = %v, want errSpoolFull", err)
+	}
+	// A discarded file gives its bytes back.
+	if _, err := spool(t, s, "1234"); err != nil {
+		t.Fatalf("spool within the quota = %v", err)
+	}
+}
+
+func TestResponseStoreSweep(t *testing.T) {
+	dir := t.TempDir()
+	s := NewResponseStore(dir, time.Hour, 1<<20)
+	entry, err := spool(t, s, "expiring")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept, err := spool(t, s, "kept")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Expire the first entry and leave an old partial file and an old body
+	// without a description behind.
+	meta, _ := readSpoolMeta(entry.path + ".json")
+	meta.ExpiresAt = time.Now().Add(-time.Minute)
+	data, _ := json.Marshal(meta)
+	os.WriteFile(entry.path+".json", data, 0o600)
+	old := time.Now().Add(-2 * time.Hour)
+	partial := filepath.Join(dir, "partial-123")
+	orphan := filepath.Join(dir, "response-orphan")
+	for _, path := range []string{partial, orphan} {
+		os.WriteFile(path, []byte("left behind"), 0o600)
+		os.Chtimes(path, old, old)
+	}
+
+	if _, err := readSpooled(t, s, entry.Token); !errors.Is(err, ErrResponseNotFound) {
+		t.Errorf("expired entry opened: %v", err)
+	}
+	s.sweep()
+	for _, path := range []string{entry.path, entry.path + ".json", partial, orphan} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s was not removed", filepath.Base(path))
+		}
+	}
+	if got, err := readSpooled(t, s, kept.Token); err != nil || got != "kept" {
+		t.Errorf("kept entry = %q, %v", got, err)
+	}
+	if s.used != int64(len("kept")) {
+		t.Errorf("used = %d after sweep, want %d", s.used, len("kept"))
+	}
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Data formats of a data driven run.
+const (
+	runDataCSV  = "csv"
+	runDataJSON = "json"
+)
+
+const (
+	maxRunIterations = 1000
+	maxRunDataSize   = 5 << 20
+)
+
+// parseRunData turns the data of a run into one map of variables per
+// iteration. Data is either a JSON array of objects or a JSON string holding
+// the text of a CSV or JSON file; the format of a file is guessed from its
+// first character unless it is given.
+func parseRunData(data json.RawMessage, format string) ([]map[string]string, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || string(data) == "null" {
+		return nil, nil
+	}
+	if len(data) > maxRunDataSize {
+		return nil, fmt.Errorf("%w: data is larger than %d MB", ErrInvalidInput, maxRunDataSize>>20)
+	}
+
+	text := data
+	if data[0] == '"' {
+		var file string
+		if err := json.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("%w: invalid data: %v", ErrInvalidInput, err)
+		}
+		text = bytes.TrimSpace(bytes.TrimPrefix([]byte(file), []byte("\ufeff")))
+	} else if format == runDataCSV {
+		return nil, fmt.Errorf("%w: CSV data must be sent as a string", ErrInvalidInput)
+	} else {
+		format = runDataJSON
+	}
+	if format == "" {
+		format = runDataCSV
+		if len(text) > 0 && text[0] == '[' {
+			format = runDataJSON
+		}
+	}
+
+	var rows []map[string]string
+	var err error
+	if format == runDataJSON {
+		rows, err = parseRunDataJSON(text)
+	} else {
+		rows, err = parseRunDataCSV(text)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid %s data: %v", ErrInvalidInput, strings.ToUpper(format), err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%w: data has no rows", ErrInvalidInput)
+	}
+	if len(rows) > maxRunIterations {
+		return nil, fmt.Errorf("%w: data has more than %d rows", ErrInvalidInput, maxRunIterations)
+	}
+	return rows, nil
+}
+
+// parseRunDataJSON reads an array of objects. Values that are not strings
+// are bound as JSON, like extracted values are, except null which binds an
+// empty value.
+func parseRunDataJSON(text []byte) ([]map[string]string, error) {
+	var objects []map[string]json.RawMessage
+	if err := json.Unmarshal(text, &objects); err != nil {
+		return nil, errors.New("expected an array of objects")
+	}
+	rows := make([]map[string]string, 0, len(objects))
+	for _, object := range objects {
+		row := make(map[string]string, len(object))
+		for key, raw := range object {
+			var value string
+			if err := json.Unmarshal(raw, &value); err != nil {
+				value = string(raw)
+			}
+			row[key] = value
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// parseRunDataCSV reads a CSV file whose first row names the variables.
+// Columns without a name are ignored.
+func parseRunDataCSV(text []byte) ([]map[string]string, error) {
+	reader := csv.NewReader(bytes.NewReader(text))
+	header, err := reader.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, errors.New("missing header row")
+		}
+		return nil, err
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+	}
+
+	var rows []map[string]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		row := make(map[string]string, len(header))
+		for i, value := range record {
+			if header[i] != "" {
+				row[header[i]] = value
+			}
+		}
+		rows = append(rows, row)
+		if len(rows) > maxRunIterations {
+			break
+		}
+	}
+	return rows, nil
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/suar-net/suar-be/internal/model"
+)
+
+func TestParseRunData(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		format  string
+		want    []map[string]string
+		wantErr string
+	}{
+		{"empty", ``, "", nil, ""},
+		{"null", `null`, "json", nil, ""},
+		{"json array", `[{"id": 1, "name": "a", "tags": ["x"], "none": null}, {"id": "2"}]`, "",
+			[]map[string]string{{"id": "1", "name": "a", "tags": `["x"]`, "none": ""}, {"id": "2"}}, ""},
+		{"json file", `"[{\"id\": 1}]"`, "", []map[string]string{{"id": "1"}}, ""},
+		{"csv file", `"\ufeffid, name\n1,a\n2,\"b, c\"\n"`, "",
+			[]map[string]string{{"id": "1", "name": "a"}, {"id": "2", "name": "b, c"}}, ""},
+		{"csv file given", `"id\n1"`, "csv", []map[string]string{{"id": "1"}}, ""},
+		{"unnamed column", `"id,\n1,x"`, "csv", []map[string]string{{"id": "1"}}, ""},
+		{"csv as array", `[{"id": 1}]`, "csv", nil, "CSV data must be sent as a string"},
+		{"json file given as csv", `"[{\"id\": 1}]"`, "csv", nil, "invalid CSV data"},
+		{"not objects", `[1, 2]`, "", nil, "expected an array of objects"},
+		{"object", `{"id": 1}`, "", nil, "expected an array of objects"},
+		{"broken string", `"id`, "", nil, "invalid data"},
+		{"header only", `"id,name"`, "", nil, "data has no rows"},
+		{"empty file", `"  "`, "csv", nil, "missing header row"},
+		{"empty array", `[]`, "", nil, "data has no rows"},
+		{"ragged csv", `"a,b\n1"`, "", nil, "wrong number of fields"},
+	}
+	for _, tt := range tests {
+		got, err := parseRunData(json.RawMessage(tt.data), tt.format)
+		if tt.wantErr != "" {
+			if !errors.Is(err, ErrInvalidInput) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: err = %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: parseRunData = %v, %v, want %v", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseRunDataLimits(t *testing.T) {
+	var csv strings.Builder
+	csv.WriteString("id\n")
+	for i := 0; i <= maxRunIterations; i++ {
+		fmt.Fprintf(&csv, "%d\n", i)
+	}
+	data, _ := json.Marshal(csv.String())
+	if _, err := parseRunData(data, ""); err == nil || !strings.Contains(err.Error(), "more than 1000 rows") {
+		t.Errorf("too many rows: err = %v", err)
+	}
+
+	large, _ := json.Marshal(strings.Repeat("x", maxRunDataSize))
+	if _, err := parseRunData(large, ""); err == nil || !strings.Contains(err.Error(), "larger than 5 MB") {
+		t.Errorf("too much data: err = %v", err)
+	}
+}
+
+func TestRunServiceBindsDataPerIteration(t *testing.T) {
+	var mu sync.Mutex
+	var urls []string
+	runs := newMemoryRunRepo()
+	s := newTestRunService(runs, func(ctx context.Context, dto *model.DTORequest) (*model.DTOResponse, error) {
+		mu.Lock()
+		urls = append(urls, dto.URL)
+		mu.Unlock()
+		if strings.HasSuffix(dto.URL, "/broken?user=2") {
+			return &model.DTOResponse{Error: "timeout"}, nil
+		}
+		return &model.DTOResponse{StatusCode: 200}, nil
+	})
+	s.collections = &fakeCollectionService{collection: &model.Collection{ID: 1, Name: "Shop", Items: []*model.CollectionItem{
+		{ID: 1, Type: model.CollectionItemRequest, Name: "Get", Request: &model.CollectionRequest{Method: "GET", URL: "https://shop.test/{{path}}?user={{id}}"}},
+	}}}
+
+	started, err := s.StartRun(context.Background(), 1, 1, &model.DTOCollectionRunRequest{
+		Data: json.RawMessage(`"id,path\n1,users\n2,broken\n"`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if started.Totals.Iterations != 2 || started.Totals.Requests != 2 {
+		t.Errorf("totals = %+v", started.Totals)
+	}
+	run := runs.waitForRun(t, started.ID)
+
+	if want := []string{"https://shop.test/users?user=1", "https://shop.test/broken?user=2"}; !reflect.DeepEqual(urls, want) {
+		t.Errorf("urls = %q, want %q", urls, want)
+	}
+	want := []model.RunIteration{
+		{Iteration: 0, Data: map[string]string{"id": "1", "path": "users"}, Completed: 1, Passed: 1},
+		{Iteration: 1, Data: map[string]string{"id": "2", "path": "broken"}, Completed: 1, Failed: 1},
+	}
+	if !reflect.DeepEqual(run.Iterations, want) || run.Results[1].Iteration != 1 {
+		t.Errorf("iterations = %+v, want %+v", run.Iterations, want)
+	}
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
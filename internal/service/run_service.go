@@ -0,0 +1,603 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/suar-net/suar-be/internal/model"
+	"github.com/suar-net/suar-be/internal/repository"
+)
+
+const (
+	maxRunRequests    = 1000
+	maxRunSends       = 10000
+	maxRunsPerUser    = 3
+	maxRunConcurrency = 10
+	// maxRunDuration bounds a whole run, the timeout of each request still
+	// applies on its own.
+	maxRunDuration = 30 * time.Minute
+	// runProgressInterval bounds how often the report of a run in progress
+	// is saved.
+	runProgressInterval = time.Second
+	// runHeartbeatInterval is how often a run in progress tells the
+	// database it is alive and checks for a cancel made through another
+	// replica. A run without a heartbeat for runStaleAfter belonged to a
+	// replica that is gone; it is marked failed and no longer counts
+	// against the limit of its user.
+	runHeartbeatInterval = 5 * time.Second
+	runStaleAfter        = time.Minute
+)
+
+var (
+	errRunCanceled    = errors.New("run was canceled")
+	errServerShutdown = errors.New("server shut down before the run finished")
+	errRunAbandoned   = errors.New("the server running it stopped before the run finished")
+)
+
+// runService runs collections in the background. The runs of this replica
+// are tracked in memory so they can be canceled and waited for on
+// shutdown. What replicas share goes through the database: the limit of
+// runs in progress per user, cancel requests, which each run polls with
+// its heartbeat, and the runs left behind by replicas that died.
+type runService struct {
+	runs         repository.ICollectionRunRepository
+	collections  ICollectionService
+	repository   repository.ICollectionRepository
+	environments repository.IEnvironmentRepository
+	requests     IRequestService
+	heartbeat    time.Duration
+
+	mu          sync.Mutex
+	cancels     map[int]context.CancelCauseFunc
+	closed      bool
+	stopReaping context.CancelFunc
+	wg          sync.WaitGroup
+}
+
+func NewRunService(runs repository.ICollectionRunRepository, collections ICollectionService, r repository.ICollectionRepository, e repository.IEnvironmentRepository, requests IRequestService) IRunService {
+	return &runService{
+		runs:         runs,
+		collections:  collections,
+		repository:   r,
+		environments: e,
+		requests:     requests,
+		heartbeat:    runHeartbeatInterval,
+		cancels:      map[int]context.CancelCauseFunc{},
+	}
+}
+
+// Start marks the runs abandoned by replicas that stopped as failed, right
+// away and then periodically, until Shutdown.
+func (s *runService) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed || s.stopReaping != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.stopReaping = cancel
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(runStaleAfter / 2)
+		defer ticker.Stop()
+		for {
+			s.failStaleRuns(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (s *runService) failStaleRuns(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	failed, err := s.runs.FailStale(ctx, time.Now().Add(-runStaleAfter), errRunAbandoned.Error())
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("failed to fail abandoned runs: %v", err)
+		}
+		return
+	}
+	if failed > 0 {
+		log.Printf("marked %d abandoned runs as failed", failed)
+	}
+}
+
+// StartRun stores a new run and starts it in the background. The returned
+// run is in the running state; its progress is read with GetRun.
+func (s *runService) StartRun(ctx context.Context, collectionID, userID int, dto *model.DTOCollectionRunRequest) (*model.CollectionRun, error) {
+	collection, err := s.collections.GetCollection(ctx, collectionID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	items, name := collection.Items, collection.Name
+	var prefix []*model.CollectionItem
+	if dto.FolderID != nil {
+		prefix = findItemPath(collection.Items, *dto.FolderID)
+		if prefix == nil || prefix[len(prefix)-1].Type != model.CollectionItemFolder {
+			return nil, ErrFolderNotFound
+		}
+		folder := prefix[len(prefix)-1]
+		items, name = folder.Items, collection.Name+" / "+folder.Name
+	}
+	paths := collectRunPaths(prefix, items)
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("%w: there are no saved requests to run", ErrInvalidInput)
+	}
+	if len(paths) > maxRunRequests {
+		return nil, fmt.Errorf("%w: a run can send at most %d requests", ErrInvalidInput, maxRunRequests)
+	}
+	data, err := parseRunData(dto.Data, dto.DataFormat)
+	if err != nil {
+		return nil, err
+	}
+	iterations := max(len(data), 1)
+	if iterations*len(paths) > maxRunSends {
+		return nil, fmt.Errorf("%w: %d iterations of %d requests exceed the limit of %d requests a run can send",
+			ErrInvalidInput, iterations, len(paths), maxRunSends)
+	}
+
+	var environment *model.Environment
+	if dto.EnvironmentID != nil {
+		environment, err = s.environments.GetByID(ctx, *dto.EnvironmentID, userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load environment: %w", err)
+		}
+		if environment == nil {
+			return nil, ErrEnvironmentNotFound
+		}
+	}
+
+	concurrency := dto.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	if concurrency > maxRunConcurrency {
+		concurrency = maxRunConcurrency
+	}
+
+	if err := s.reserve(); err != nil {
+		return nil, err
+	}
+
+	run := &model.CollectionRun{
+		UserID:        userID,
+		CollectionID:  &collection.ID,
+		FolderID:      dto.FolderID,
+		EnvironmentID: dto.EnvironmentID,
+		Name:          name,
+		Status:        model.RunStatusRunning,
+		Concurrency:   concurrency,
+		Totals:        model.RunTotals{Iterations: iterations, Requests: iterations * len(paths)},
+	}
+	created, err := s.runs.Create(ctx, run, maxRunsPerUser, time.Now().Add(-runStaleAfter))
+	if err != nil {
+		s.release(run)
+		return nil, fmt.Errorf("failed to save run: %w", err)
+	}
+	if !created {
+		s.release(run)
+		return nil, ErrRunLimitReached
+	}
+
+	runner := &collectionRunner{
+		requests:    s.requests,
+		userID:      userID,
+		collection:  collection,
+		environment: environment,
+		variables:   newRunVariables(collection, environment),
+		timeout:     dto.Timeout,
+	}
+
+	// The run outlives the request that started it.
+	runCtx, cancel := context.WithCancelCause(context.Background())
+	runCtx, cancelTimeout := context.WithTimeout(runCtx, maxRunDuration)
+	s.mu.Lock()
+	s.cancels[run.ID] = cancel
+	if s.closed {
+		cancel(errServerShutdown)
+	}
+	s.mu.Unlock()
+
+	started := *run
+	go func() {
+		defer s.release(run)
+		defer cancelTimeout()
+		defer cancel(nil)
+		s.execute(runCtx, cancel, run, runner, paths, data, dto.StopOnFailure)
+	}()
+	return &started, nil
+}
+
+// reserve counts a new run so Shutdown waits for it, release forgets it
+// once the run is over. The limit of runs per user is checked by Create.
+func (s *runService) reserve() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errServerShutdown
+	}
+	s.wg.Add(1)
+	return nil
+}
+
+func (s *runService) release(run *model.CollectionRun) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.cancels, run.ID)
+	s.wg.Done()
+}
+
+// collectRunPaths lists the saved requests below items in the order they
+// appear, each with the folders leading to it.
+func collectRunPaths(prefix []*model.CollectionItem, items []*model.CollectionItem) [][]*model.CollectionItem {
+	var paths [][]*model.CollectionItem
+	for _, item := range items {
+		path := append(append([]*model.CollectionItem(nil), prefix...), item)
+		switch item.Type {
+		case model.CollectionItemRequest:
+			paths = append(paths, path)
+		case model.CollectionItemFolder:
+			paths = append(paths, collectRunPaths(path, item.Items)...)
+		}
+	}
+	return paths
+}
+
+type runOutcome struct {
+	index  int
+	result model.RunItemResult
+}
+
+// execute sends the requests of a run once per row of data and saves the
+// report as requests complete, at most every runProgressInterval.
+// Iterations run one after the other, so one sees what the previous one
+// left in the environment. Results are kept in the order of the
+// collection, whatever order they finish in.
+func (s *runService) execute(ctx context.Context, cancel context.CancelCauseFunc, run *model.CollectionRun, runner *collectionRunner, paths [][]*model.CollectionItem, data []map[string]string, stopOnFailure bool) {
+	stopHeartbeat := s.keepAlive(ctx, cancel, run.ID)
+	defer stopHeartbeat()
+
+	iterations := max(len(data), 1)
+	results := make([]*model.RunItemResult, iterations*len(paths))
+	var stopped atomic.Bool
+	var saved time.Time
+	for iteration := 0; iteration < iterations && ctx.Err() == nil && !stopped.Load(); iteration++ {
+		var row map[string]string
+		if data != nil {
+			row = data[iteration]
+		}
+		runner.variables.startIteration(row)
+		info := scriptInfo{Iteration: iteration, IterationCount: iterations}
+
+		for outcome := range s.runIteration(ctx, runner, paths, info, run.Concurrency, stopOnFailure, &stopped) {
+			results[iteration*len(paths)+outcome.index] = &outcome.result
+			if time.Since(saved) < runProgressInterval {
+				continue
+			}
+			saved = time.Now()
+			summarizeRun(run, data, len(paths), results)
+			if cancelRequested, err := s.save(run); err != nil {
+				log.Printf("failed to save progress of run %d: %v", run.ID, err)
+			} else if cancelRequested {
+				cancel(errRunCanceled)
+			}
+		}
+	}
+	summarizeRun(run, data, len(paths), results)
+
+	now := time.Now()
+	run.FinishedAt = &now
+	switch cause := context.Cause(ctx); {
+	case errors.Is(cause, errRunCanceled):
+		run.Status = model.RunStatusCanceled
+	case errors.Is(cause, errServerShutdown):
+		run.Status, run.Error = model.RunStatusCanceled, cause.Error()
+	case errors.Is(cause, context.DeadlineExceeded):
+		run.Status, run.Error = model.RunStatusFailed, fmt.Sprintf("run exceeded the time limit of %v", maxRunDuration)
+	case run.Totals.Failed > 0 || run.Totals.Completed < run.Totals.Requests:
+		run.Status = model.RunStatusFailed
+	default:
+		run.Status = model.RunStatusPassed
+	}
+	if err := runner.saveVariables(s.repository, s.environments); err != nil {
+		run.Error = strings.TrimPrefix(run.Error+"; "+err.Error(), "; ")
+	}
+	if _, err := s.save(run); err != nil {
+		log.Printf("failed to save run %d: %v", run.ID, err)
+	}
+}
+
+// keepAlive sends the heartbeat of a run until the returned function is
+// called, and cancels the run when the user asked for it through any
+// replica.
+func (s *runService) keepAlive(ctx context.Context, cancel context.CancelCauseFunc, id int) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(s.heartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			beatCtx, beatCancel := context.WithTimeout(ctx, 5*time.Second)
+			cancelRequested, err := s.runs.Heartbeat(beatCtx, id)
+			beatCancel()
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("failed to record heartbeat of run %d: %v", id, err)
+				}
+			} else if cancelRequested {
+				cancel(errRunCanceled)
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// runIteration sends the requests of one iteration with a pool of workers
+// and delivers their reports until all of them are done.
+func (s *runService) runIteration(ctx context.Context, runner *collectionRunner, paths [][]*model.CollectionItem, info scriptInfo, concurrency int, stopOnFailure bool, stopped *atomic.Bool) <-chan runOutcome {
+	jobs := make(chan int)
+	outcomes := make(chan runOutcome)
+
+	go func() {
+		defer close(jobs)
+		for i := range paths {
+			if stopped.Load() {
+				return
+			}
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var workers sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for i := range jobs {
+				if ctx.Err() != nil || stopped.Load() {
+					continue
+				}
+				started := time.Now()
+				result := runner.runItem(ctx, paths[i], info)
+				if ctx.Err() != nil {
+					// Aborted by the cancel, not a failure of the request.
+					continue
+				}
+				report := runItemReport(paths[i], result, started)
+				report.Iteration = info.Iteration
+				if stopOnFailure && !report.Passed {
+					stopped.Store(true)
+				}
+				outcomes <- runOutcome{index: i, result: report}
+			}
+		}()
+	}
+	go func() {
+		workers.Wait()
+		close(outcomes)
+	}()
+	return outcomes
+}
+
+// summarizeRun fills in the report of a run from the results so far.
+func summarizeRun(run *model.CollectionRun, data []map[string]string, requests int, results []*model.RunItemResult) {
+	run.Results = orderedResults(results)
+	run.Totals = runTotals(max(len(data), 1), requests, run.Results)
+	run.Iterations = runIterations(data, run.Results)
+}
+
+// save writes a run with its own context, so a canceled run can still be
+// stored.
+func (s *runService) save(run *model.CollectionRun) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return s.runs.Update(ctx, run)
+}
+
+func orderedResults(results []*model.RunItemResult) []model.RunItemResult {
+	ordered := make([]model.RunItemResult, 0, len(results))
+	for _, result := range results {
+		if result != nil {
+			ordered = append(ordered, *result)
+		}
+	}
+	return ordered
+}
+
+// runItemReport keeps what a run report needs from the result of a request.
+// A request passes when it got a response and none of its assertions,
+// tests or contract checks failed.
+func runItemReport(path []*model.CollectionItem, result *model.DTOItemRunResult, started time.Time) model.RunItemResult {
+	names := make([]string, 0, len(path))
+	for _, item := range path {
+		names = append(names, item.Name)
+	}
+	report := model.RunItemResult{
+		ItemID:    result.ItemID,
+		Name:      result.Name,
+		Path:      strings.Join(names, " / "),
+		Warnings:  result.Warnings,
+		Error:     result.Error,
+		StartedAt: started,
+	}
+	if result.Request != nil {
+		report.Method, report.URL = result.Request.Method, result.Request.URL
+	}
+	if response := result.Response; response != nil {
+		report.StatusCode = response.StatusCode
+		report.DurationMs = response.Duration.Milliseconds()
+		report.Size = response.Size
+		report.Assertions = response.Assertions
+		report.Contract = response.Contract
+		report.HistoryID = response.HistoryID
+		if report.Error == "" {
+			report.Error = response.Error
+		}
+	}
+	if result.Test != nil {
+		report.Tests = result.Test.Tests
+		if result.Test.Error != "" && report.Error == "" {
+			report.Error = "test script failed: " + result.Test.Error
+		}
+	}
+
+	report.Passed = report.Error == "" && result.Response != nil
+	for _, assertion := range report.Assertions {
+		report.Passed = report.Passed && assertion.Passed
+	}
+	for _, test := range report.Tests {
+		report.Passed = report.Passed && (test.Passed || test.Skipped)
+	}
+	if report.Contract != nil {
+		report.Passed = report.Passed && report.Contract.Valid
+	}
+	return report
+}
+
+func runTotals(iterations, requests int, results []model.RunItemResult) model.RunTotals {
+	totals := model.RunTotals{Iterations: iterations, Requests: iterations * requests, Completed: len(results)}
+	for _, result := range results {
+		if result.Passed {
+			totals.Passed++
+		} else {
+			totals.Failed++
+		}
+		totals.DurationMs += result.DurationMs
+		for _, assertion := range result.Assertions {
+			totals.Assertions++
+			if !assertion.Passed {
+				totals.AssertionsFailed++
+			}
+		}
+		for _, test := range result.Tests {
+			if test.Skipped {
+				continue
+			}
+			totals.Tests++
+			if !test.Passed {
+				totals.TestsFailed++
+			}
+		}
+	}
+	return totals
+}
+
+// runIterations summarizes the results of each iteration that started.
+func runIterations(data []map[string]string, results []model.RunItemResult) []model.RunIteration {
+	var iterations []model.RunIteration
+	for _, result := range results {
+		for len(iterations) <= result.Iteration {
+			iteration := model.RunIteration{Iteration: len(iterations)}
+			if data != nil {
+				iteration.Data = data[iteration.Iteration]
+			}
+			iterations = append(iterations, iteration)
+		}
+		iteration := &iterations[result.Iteration]
+		iteration.Completed++
+		if result.Passed {
+			iteration.Passed++
+		} else {
+			iteration.Failed++
+		}
+	}
+	return iterations
+}
+
+func (s *runService) ListRuns(ctx context.Context, userID int, collectionID *int) ([]*model.CollectionRun, error) {
+	runs, err := s.runs.GetByUserID(ctx, userID, collectionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list runs: %w", err)
+	}
+	if runs == nil {
+		runs = []*model.CollectionRun{}
+	}
+	return runs, nil
+}
+
+func (s *runService) GetRun(ctx context.Context, id int, userID int) (*model.CollectionRun, error) {
+	run, err := s.runs.GetByID(ctx, id, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get run: %w", err)
+	}
+	if run == nil {
+		return nil, ErrRunNotFound
+	}
+	if run.Results == nil {
+		run.Results = []model.RunItemResult{}
+	}
+	return run, nil
+}
+
+// CancelRun stops a run. Requests in flight are aborted, the results so far
+// are kept. Canceling a finished run has no effect.
+func (s *runService) CancelRun(ctx context.Context, id int, userID int) error {
+	found, err := s.runs.RequestCancel(ctx, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to cancel run: %w", err)
+	}
+	if !found {
+		return ErrRunNotFound
+	}
+
+	s.mu.Lock()
+	cancel := s.cancels[id]
+	s.mu.Unlock()
+	if cancel != nil {
+		cancel(errRunCanceled)
+	}
+	return nil
+}
+
+// Shutdown cancels the runs in progress and waits until their reports are
+// saved or ctx is done. No new runs are accepted afterwards.
+func (s *runService) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	if s.stopReaping != nil {
+		s.stopReaping()
+	}
+	for _, cancel := range s.cancels {
+		cancel(errServerShutdown)
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,442 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/suar-net/suar-be/internal/model"
+	"github.com/suar-net/suar-be/internal/repository"
+)
+
+// memoryRunRepo keeps runs the way collection_runs does, shared by every
+// runService built on it as replicas share the database.
+type memoryRunRepo struct {
+	repository.ICollectionRunRepository
+
+	mu         sync.Mutex
+	runs       map[int]*model.CollectionRun
+	canceled   map[int]bool
+	heartbeats map[int]int
+	staleCalls []time.Time
+}
+
+func newMemoryRunRepo() *memoryRunRepo {
+	return &memoryRunRepo{runs: map[int]*model.CollectionRun{}, canceled: map[int]bool{}, heartbeats: map[int]int{}}
+}
+
+func (f *memoryRunRepo) Create(ctx context.Context, run *model.CollectionRun, limit int, staleBefore time.Time) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	running := 0
+	for _, existing := range f.runs {
+		if existing.UserID == run.UserID && existing.Status == model.RunStatusRunning {
+			running++
+		}
+	}
+	if running >= limit {
+		return false, nil
+	}
+	run.ID, run.StartedAt = len(f.runs)+1, time.Now()
+	stored := *run
+	f.runs[run.ID] = &stored
+	return true, nil
+}
+
+func (f *memoryRunRepo) Update(ctx context.Context, run *model.CollectionRun) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	stored := *run
+	f.runs[run.ID] = &stored
+	return f.canceled[run.ID], nil
+}
+
+func (f *memoryRunRepo) Heartbeat(ctx context.Context, id int) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.heartbeats[id]++
+	return f.canceled[id], nil
+}
+
+func (f *memoryRunRepo) FailStale(ctx context.Context, staleBefore time.Time, message string) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.staleCalls = append(f.staleCalls, staleBefore)
+	return 0, nil
+}
+
+func (f *memoryRunRepo) RequestCancel(ctx context.Context, id int, userID int) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if run, ok := f.runs[id]; ok && run.UserID == userID {
+		f.canceled[id] = true
+		return true, nil
+	}
+	return false, nil
+}
+
+func (f *memoryRunRepo) GetByID(ctx context.Context, id int, userID int) (*model.CollectionRun, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if run, ok := f.runs[id]; ok && run.UserID == userID {
+		copied := *run
+		return &copied, nil
+	}
+	return nil, nil
+}
+
+// waitForRun polls a run until it is no longer running.
+func (f *memoryRunRepo) waitForRun(t *testing.T, id int) *model.CollectionRun {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		run, _ := f.GetByID(context.Background(), id, 1)
+		if run != nil && run.Status != model.RunStatusRunning {
+			return run
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("run %d did not finish", id)
+	return nil
+}
+
+type fakeCollectionService struct {
+	ICollectionService
+	collection *model.Collection
+}
+
+func (f *fakeCollectionService) GetCollection(ctx context.Context, id int, userID int) (*model.Collection, error) {
+	if id != f.collection.ID || userID != 1 {
+		return nil, ErrCollectionNotFound
+	}
+	return f.collection, nil
+}
+
+// fakeRequestService answers every request with respond.
+type fakeRequestService struct {
+	IRequestService
+	respond func(ctx context.Context, dto *model.DTORequest) (*model.DTOResponse, error)
+}
+
+func (f *fakeRequestService) ProcessRequest(ctx context.Context, userID *int, dto *model.DTORequest) (*model.DTOResponse, error) {
+	return f.respond(ctx, dto)
+}
+
+// blockingRequests answers no request until its context is done.
+func blockingRequests(ctx context.Context, dto *model.DTORequest) (*model.DTOResponse, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func runTestCollection() *model.Collection {
+	request := func(id int, name, url string) *model.CollectionItem {
+		return &model.CollectionItem{ID: id, Type: model.CollectionItemRequest, Name: name,
+			Request: &model.CollectionRequest{Method: "GET", URL: url}}
+	}
+	return &model.Collection{ID: 1, UserID: 1, Name: "Shop", Items: []*model.CollectionItem{
+		request(1, "Health", "https://shop.test/health"),
+		{ID: 2, Type: model.CollectionItemFolder, Name: "Users", Items: []*model.CollectionItem{
+			request(3, "List", "https://shop.test/users"),
+			request(4, "Broken", "https://shop.test/broken"),
+		}},
+	}}
+}
+
+func newTestRunService(runs *memoryRunRepo, respond func(context.Context, *model.DTORequest) (*model.DTOResponse, error)) *runService {
+	s := NewRunService(runs, &fakeCollectionService{collection: runTestCollection()}, nil, nil, &fakeRequestService{respond: respond}).(*runService)
+	s.heartbeat = 10 * time.Millisecond
+	return s
+}
+
+func TestRunServiceRunsCollection(t *testing.T) {
+	runs := newMemoryRunRepo()
+	s := newTestRunService(runs, func(ctx context.Context, dto *model.DTORequest) (*model.DTOResponse, error) {
+		if dto.URL == "https://shop.test/broken" {
+			return nil, errors.New("connection refused")
+		}
+		return &model.DTOResponse{StatusCode: 200, Duration: 5 * time.Millisecond, Size: 2}, nil
+	})
+
+	started, err := s.StartRun(context.Background(), 1, 1, &model.DTOCollectionRunRequest{Concurrency: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if started.Status != model.RunStatusRunning || started.Totals.Requests != 3 || started.Name != "Shop" {
+		t.Errorf("started = %+v", started)
+	}
+	run := runs.waitForRun(t, started.ID)
+
+	var paths []string
+	for _, result := range run.Results {
+		paths = append(paths, result.Path)
+	}
+	if !reflect.DeepEqual(paths, []string{"Health", "Users / List", "Users / Broken"}) {
+		t.Errorf("results in order %q", paths)
+	}
+	if run.Status != model.RunStatusFailed || run.Totals.Passed != 2 || run.Totals.Failed != 1 || run.Totals.DurationMs != 10 {
+		t.Errorf("run = %+v", run)
+	}
+	if broken := run.Results[2]; broken.Passed || broken.Error != "connection refused" {
+		t.Errorf("broken = %+v", broken)
+	}
+	if run.FinishedAt == nil {
+		t.Error("the run has no finish time")
+	}
+
+	folderID := 2
+	started, err = s.StartRun(context.Background(), 1, 1, &model.DTOCollectionRunRequest{FolderID: &folderID, StopOnFailure: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	run = runs.waitForRun(t, started.ID)
+	if run.Name != "Shop / Users" || len(run.Results) != 2 || run.Status != model.RunStatusFailed {
+		t.Errorf("folder run = %+v", run)
+	}
+
+	for name, dto := range map[string]*model.DTOCollectionRunRequest{
+		"request as folder": {FolderID: new(int)},
+		"invalid data":      {Data: []byte("{"), DataFormat: "json"},
+	} {
+		if _, err := s.StartRun(context.Background(), 1, 1, dto); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+	if _, err := s.StartRun(context.Background(), 1, 2, &model.DTOCollectionRunRequest{}); !errors.Is(err, ErrCollectionNotFound) {
+		t.Errorf("collection of another user: err = %v", err)
+	}
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunServiceLimitIsShared(t *testing.T) {
+	runs := newMemoryRunRepo()
+	// Two replicas sharing the database.
+	first := newTestRunService(runs, blockingRequests)
+	second := newTestRunService(runs, blockingRequests)
+
+	for i := 0; i < maxRunsPerUser; i++ {
+		replica := first
+		if i%2 == 1 {
+			replica = second
+		}
+		if _, err := replica.StartRun(context.Background(), 1, 1, &model.DTOCollectionRunRequest{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, replica := range []*runService{first, second} {
+		if _, err := replica.StartRun(context.Background(), 1, 1, &model.DTOCollectionRunRequest{}); !errors.Is(err, ErrRunLimitReached) {
+			t.Errorf("err = %v, want ErrRunLimitReached", err)
+		}
+	}
+
+	for _, replica := range []*runService{first, second} {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := replica.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+		cancel()
+	}
+	for id := 1; id <= maxRunsPerUser; id++ {
+		run, _ := runs.GetByID(context.Background(), id, 1)
+		if run.Status != model.RunStatusCanceled || run.Error != errServerShutdown.Error() {
+			t.Errorf("run %d = %+v", id, run)
+		}
+	}
+	if _, err := first.StartRun(context.Background(), 1, 1, &model.DTOCollectionRunRequest{}); !errors.Is(err, errServerShutdown) {
+		t.Errorf("run after shutdown: err = %v", err)
+	}
+}
+
+func TestRunServiceCancelFromAnotherReplica(t *testing.T) {
+	runs := newMemoryRunRepo()
+	running := newTestRunService(runs, blockingRequests)
+	other := newTestRunService(runs, blockingRequests)
+
+	started, err := running.StartRun(context.Background(), 1, 1, &model.DTOCollectionRunRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// No request completes, so the run only learns about the cancel from
+	// its heartbeat.
+	if err := other.CancelRun(context.Background(), started.ID, 1); err != nil {
+		t.Fatal(err)
+	}
+	run := runs.waitForRun(t, started.ID)
+	if run.Status != model.RunStatusCanceled || run.Error != "" || len(run.Results) != 0 {
+		t.Errorf("run = %+v", run)
+	}
+	runs.mu.Lock()
+	heartbeats := runs.heartbeats[started.ID]
+	runs.mu.Unlock()
+	if heartbeats == 0 {
+		t.Error("the run sent no heartbeat")
+	}
+
+	if err := other.CancelRun(context.Background(), started.ID, 2); !errors.Is(err, ErrRunNotFound) {
+		t.Errorf("cancel of another user's run: err = %v", err)
+	}
+}
+
+func TestRunServiceFailsStaleRuns(t *testing.T) {
+	runs := newMemoryRunRepo()
+	s := newTestRunService(runs, blockingRequests)
+	s.Start()
+	s.Start()
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	runs.mu.Lock()
+	defer runs.mu.Unlock()
+	if len(runs.staleCalls) != 1 {
+		t.Fatalf("FailStale called %d times, want once on start", len(runs.staleCalls))
+	}
+	if age := time.Since(runs.staleCalls[0]); age < runStaleAfter || age > runStaleAfter+time.Second {
+		t.Errorf("stale cutoff is %v old, want %v", age, runStaleAfter)
+	}
+}
+
+func TestCollectRunPaths(t *testing.T) {
+	collection := runTestCollection()
+	var names []string
+	for _, path := range collectRunPaths(nil, collection.Items) {
+		var parts []string
+		for _, item := range path {
+			parts = append(parts, item.Name)
+		}
+		names = append(names, strings.Join(parts, "/"))
+	}
+	if want := []string{"Health", "Users/List", "Users/Broken"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("paths = %q, want %q", names, want)
+	}
+	if path := findItemPath(collection.Items, 4); len(path) != 2 || path[0].ID != 2 || path[1].ID != 4 {
+		t.Errorf("findItemPath = %+v", path)
+	}
+	if path := findItemPath(collection.Items, 99); path != nil {
+		t.Errorf("findItemPath of a missing item = %+v", path)
+	}
+}
+
+func TestRunItemReport(t *testing.T) {
+	path := []*model.CollectionItem{{Name: "Users"}, {ID: 3, Name: "List"}}
+	response := &model.DTOResponse{StatusCode: 200, Duration: 20 * time.Millisecond, Size: 7}
+	tests := []struct {
+		name   string
+		result *model.DTOItemRunResult
+		passed bool
+		err    string
+	}{
+		{"passed", &model.DTOItemRunResult{Response: response}, true, ""},
+		{"no response", &model.DTOItemRunResult{Error: "pre-request script failed: x"}, false, "pre-request script failed: x"},
+		{"response error", &model.DTOItemRunResult{Response: &model.DTOResponse{Error: "timeout"}}, false, "timeout"},
+		{"failed assertion", &model.DTOItemRunResult{Response: &model.DTOResponse{Assertions: []model.AssertionResult{{Passed: true}, {Passed: false}}}}, false, ""},
+		{"failed test", &model.DTOItemRunResult{Response: response, Test: &model.DTOScriptResult{Tests: []model.DTOScriptTest{{Passed: false}}}}, false, ""},
+		{"skipped test", &model.DTOItemRunResult{Response: response, Test: &model.DTOScriptResult{Tests: []model.DTOScriptTest{{Skipped: true}}}}, true, ""},
+		{"test script error", &model.DTOItemRunResult{Response: response, Test: &model.DTOScriptResult{Error: "boom"}}, false, "test script failed: boom"},
+		{"invalid contract", &model.DTOItemRunResult{Response: &model.DTOResponse{Contract: &model.DTOContractResult{Valid: false}}}, false, ""},
+	}
+	for _, tt := range tests {
+		tt.result.ItemID, tt.result.Name = 3, "List"
+		report := runItemReport(path, tt.result, time.Time{})
+		if report.Passed != tt.passed || report.Error != tt.err || report.Path != "Users / List" {
+			t.Errorf("%s: report = %+v, want passed %v and error %q", tt.name, report, tt.passed, tt.err)
+		}
+	}
+}
+
+func TestRunTotalsAndIterations(t *testing.T) {
+	results := []model.RunItemResult{
+		{Iteration: 0, Passed: true, DurationMs: 10, Assertions: []model.AssertionResult{{Passed: true}}},
+		{Iteration: 0, Passed: false, DurationMs: 5, Tests: []model.DTOScriptTest{{Passed: false}, {Skipped: true}}},
+		{Iteration: 1, Passed: false, Assertions: []model.AssertionResult{{Passed: false}}},
+	}
+	totals := runTotals(2, 2, results)
+	want := model.RunTotals{Iterations: 2, Requests: 4, Completed: 3, Passed: 1, Failed: 2,
+		Assertions: 2, AssertionsFailed: 1, Tests: 1, TestsFailed: 1, DurationMs: 15}
+	if totals != want {
+		t.Errorf("totals = %+v, want %+v", totals, want)
+	}
+
+	data := []map[string]string{{"id": "1"}, {"id": "2"}}
+	iterations := runIterations(data, results)
+	wantIterations := []model.RunIteration{
+		{Iteration: 0, Data: data[0], Completed: 2, Passed: 1, Failed: 1},
+		{Iteration: 1, Data: data[1], Completed: 1, Failed: 1},
+	}
+	if !reflect.DeepEqual(iterations, wantIterations) {
+		t.Errorf("iterations = %+v, want %+v", iterations, wantIterations)
+	}
+}
+
+// storedEnvironmentVariables and storedCollectionVariables keep the
+// variables of an environment and a collection, as edited by the user while
+// a run goes on.
+type storedEnvironmentVariables struct {
+	repository.IEnvironmentRepository
+	variables []model.Variable
+}
+
+func (s *storedEnvironmentVariables) MergeVariables(ctx context.Context, id int, userID int, merge func([]model.Variable) []model.Variable) (bool, error) {
+	s.variables = merge(s.variables)
+	return true, nil
+}
+
+type storedCollectionVariables struct {
+	repository.ICollectionRepository
+	variables []model.Variable
+}
+
+func (s *storedCollectionVariables) MergeVariables(ctx context.Context, id int, userID int, merge func([]model.Variable) []model.Variable) (bool, error) {
+	s.variables = merge(s.variables)
+	return true, nil
+}
+
+func TestSaveVariablesKeepsOtherEdits(t *testing.T) {
+	environment := &model.Environment{ID: 1, Variables: []model.Variable{
+		{Key: "token", Value: "old", Secret: true},
+		{Key: "host", Value: "api.test"},
+		{Key: "stale", Value: "x"},
+	}}
+	collection := &model.Collection{ID: 1, Variables: []model.Variable{{Key: "page", Value: "1"}}}
+	runner := &collectionRunner{collection: collection, environment: environment, variables: newRunVariables(collection, environment)}
+	runner.variables.set(scopeEnvironment, "token", "new")
+	runner.variables.set(scopeEnvironment, "session", "s1")
+	runner.variables.unset(scopeEnvironment, "stale")
+
+	// Edited by the user during the run.
+	environments := &storedEnvironmentVariables{variables: []model.Variable{
+		{Key: "token", Value: "edited", Secret: true},
+		{Key: "host", Value: "staging.test"},
+		{Key: "stale", Value: "x"},
+		{Key: "added", Value: "y"},
+	}}
+	collections := &storedCollectionVariables{variables: []model.Variable{{Key: "page", Value: "2"}}}
+	if err := runner.saveVariables(collections, environments); err != nil {
+		t.Fatal(err)
+	}
+	want := []model.Variable{
+		{Key: "token", Value: "new", Secret: true},
+		{Key: "host", Value: "staging.test"},
+		{Key: "added", Value: "y"},
+		{Key: "session", Value: "s1"},
+	}
+	if !reflect.DeepEqual(environments.variables, want) {
+		t.Errorf("environment variables = %+v, want %+v", environments.variables, want)
+	}
+	if collections.variables[0].Value != "2" {
+		t.Errorf("unchanged collection variables were written back: %+v", collections.variables)
+	}
+
+	runner.variables.clear(scopeCollection)
+	runner.variables.set(scopeCollection, "page", "3")
+	if err := runner.saveVariables(collections, environments); err != nil {
+		t.Fatal(err)
+	}
+	if want := []model.Variable{{Key: "page", Value: "3"}}; !reflect.DeepEqual(collections.variables, want) {
+		t.Errorf("cleared collection variables = %+v, want %+v", collections.variables, want)
+	}
+}
@@ -0,0 +1,1057 @@
+// The pm.* API of the script sandbox, a subset of Postman's. It is evaluated
+// once per phase and returns a function that installs the API into the global
+// object. host is implemented in Go (script_sandbox.go): it owns the
+// variables, collects test results and console output. The request is kept
+// as a plain object here and read back by Go after the phase.
+(function (global, host, input) {
+	'use strict';
+
+	// The input arrives as JSON text, so every value is a plain JavaScript
+	// value rather than a wrapped Go one.
+	input = JSON.parse(input);
+	var request = input.request;
+	request.headers = request.headers || [];
+	var response = input.response;
+	var editable = input.info.eventName === 'prerequest';
+
+	// Allocation limits. The memory watch in Go only interrupts the runtime
+	// between instructions, so a builtin that allocates a huge string or
+	// array in one call, such as 'x'.repeat(8e9), would exhaust the memory
+	// of the server before it is stopped. Those builtins refuse sizes above
+	// the limits set by Go.
+	var limits = input.limits;
+
+	function checkSize(size, limit, what) {
+		if (size > limit) {
+			throw new RangeError(what + ' exceeds the sandbox limit of ' + limit);
+		}
+	}
+
+	function guard(object, name, check) {
+		var original = object[name];
+		Object.defineProperty(object, name, {
+			value: function () {
+				check.apply(this, arguments);
+				return original.apply(this, arguments);
+			},
+			writable: true,
+			configurable: true
+		});
+	}
+
+	function checkPad(targetLength) {
+		checkSize(Number(targetLength), limits.stringLength, 'string length');
+	}
+
+	function checkArrayLength() {
+		checkSize(Number(Object(this).length), limits.arrayLength, 'array length');
+	}
+
+	guard(String.prototype, 'repeat', function (count) {
+		checkSize(String(this).length * Math.floor(Number(count)), limits.stringLength, 'string length');
+	});
+	guard(String.prototype, 'padStart', checkPad);
+	guard(String.prototype, 'padEnd', checkPad);
+	['fill', 'sort', 'reverse', 'copyWithin'].forEach(function (name) {
+		guard(Array.prototype, name, checkArrayLength);
+	});
+	guard(Array, 'from', function (items) {
+		if (items !== null && items !== undefined) {
+			checkSize(Number(Object(items).length) || 0, limits.arrayLength, 'array length');
+		}
+	});
+
+	// join converts the elements itself so the length of the result is
+	// known before it is built.
+	var join = Array.prototype.join;
+	Object.defineProperty(Array.prototype, 'join', {
+		value: function (separator) {
+			checkArrayLength.call(this);
+			var self = Object(this);
+			var text = separator === undefined ? ',' : String(separator);
+			var parts = [];
+			var size = 0;
+			for (var i = 0; i < self.length; i++) {
+				var element = self[i];
+				parts.push(element === undefined || element === null ? '' : String(element));
+				size += parts[i].length + (i > 0 ? text.length : 0);
+				checkSize(size, limits.stringLength, 'string length');
+			}
+			return join.call(parts, text);
+		},
+		writable: true,
+		configurable: true
+	});
+
+	// new Array(n) only sets the length, but constructors that allocate
+	// their storage up front are capped. The replacement shares the
+	// prototype of the original, so instanceof and the static methods keep
+	// working. (goja's Proxy does not support instanceof.)
+	function limitConstructor(name, size) {
+		var original = global[name];
+		if (typeof original !== 'function') {
+			return;
+		}
+		var isArray = name === 'Array';
+		var limited = function () {
+			checkSize(size(arguments[0], arguments.length), isArray ? limits.arrayLength : limits.bufferSize,
+				isArray ? 'array length' : 'buffer size');
+			var args = Array.prototype.slice.call(arguments);
+			if (new.target === undefined) {
+				return Reflect.apply(original, undefined, args);
+			}
+			return Reflect.construct(original, args, new.target);
+		};
+		Object.setPrototypeOf(limited, original);
+		Object.defineProperty(limited, 'name', { value: name });
+		Object.defineProperty(limited, 'prototype', { value: original.prototype });
+		Object.defineProperty(original.prototype, 'constructor', { value: limited, writable: true, configurable: true });
+		Object.defineProperty(global, name, { value: limited, writable: true, configurable: true });
+	}
+
+	limitConstructor('Array', function (first, count) {
+		return count === 1 && typeof first === 'number' ? first : 0;
+	});
+	['ArrayBuffer', 'SharedArrayBuffer', 'Int8Array', 'Uint8Array', 'Uint8ClampedArray', 'Int16Array',
+		'Uint16Array', 'Int32Array', 'Uint32Array', 'Float32Array', 'Float64Array'].forEach(function (name) {
+		var bytes = global[name] && global[name].BYTES_PER_ELEMENT || 1;
+		limitConstructor(name, function (first) {
+			if (typeof first === 'number') {
+				return first * bytes;
+			}
+			// Views of an existing buffer allocate nothing new.
+			if (first !== null && typeof first === 'object' && !(first instanceof ArrayBuffer)) {
+				return (Number(first.length) || 0) * bytes;
+			}
+			return 0;
+		});
+	});
+
+	function format(value) {
+		if (typeof value === 'string') {
+			return value;
+		}
+		if (value === undefined) {
+			return 'undefined';
+		}
+		if (typeof value === 'function') {
+			return '[Function]';
+		}
+		if (value instanceof Error) {
+			return value.name + ': ' + value.message;
+		}
+		try {
+			var text = JSON.stringify(value);
+			return text === undefined ? String(value) : text;
+		} catch (e) {
+			return String(value);
+		}
+	}
+
+	function inspect(value) {
+		if (typeof value === 'string') {
+			return JSON.stringify(value);
+		}
+		return format(value);
+	}
+
+	function readOnly(what) {
+		throw new TypeError(what + ' can only be changed in a pre-request script');
+	}
+
+	// Variable scopes.
+
+	function VariableScope(scope, writable) {
+		this._scope = scope;
+		this._writable = writable;
+	}
+	VariableScope.prototype.get = function (key) {
+		return host.get(this._scope, String(key));
+	};
+	VariableScope.prototype.has = function (key) {
+		return host.has(this._scope, String(key));
+	};
+	VariableScope.prototype.set = function (key, value) {
+		if (!this._writable) {
+			throw new TypeError('iteration data is read only');
+		}
+		host.set(this._scope, String(key), format(value));
+	};
+	VariableScope.prototype.unset = function (key) {
+		if (!this._writable) {
+			throw new TypeError('iteration data is read only');
+		}
+		host.unset(this._scope, String(key));
+	};
+	VariableScope.prototype.clear = function () {
+		if (!this._writable) {
+			throw new TypeError('iteration data is read only');
+		}
+		host.clear(this._scope);
+	};
+	VariableScope.prototype.toObject = function () {
+		return host.toObject(this._scope);
+	};
+	VariableScope.prototype.replaceIn = function (template) {
+		return host.replaceIn(String(template));
+	};
+
+	// Key/value lists, used for headers, query parameters and form fields.
+
+	function toPair(entry) {
+		if (typeof entry === 'string') {
+			var colon = entry.indexOf(':');
+			if (colon < 0) {
+				return { key: entry.trim(), value: '' };
+			}
+			return { key: entry.slice(0, colon).trim(), value: entry.slice(colon + 1).trim() };
+		}
+		if (!entry || entry.key === undefined) {
+			throw new TypeError('expected a {key, value} object');
+		}
+		return {
+			key: String(entry.key),
+			value: entry.value === undefined || entry.value === null ? '' : format(entry.value),
+			disabled: !!entry.disabled
+		};
+	}
+
+	// PropertyList reads its entries through list() so it also works on
+	// lists that are rebuilt on every change, such as the query of the URL.
+	function PropertyList(list, options) {
+		this._list = list;
+		this._options = options || {};
+	}
+	PropertyList.prototype._matches = function (entry, key) {
+		if (typeof key === 'function') {
+			return key(entry);
+		}
+		if (this._options.ignoreCase) {
+			return entry.key.toLowerCase() === String(key).toLowerCase();
+		}
+		return entry.key === String(key);
+	};
+	PropertyList.prototype._change = function (update) {
+		if (!this._options.writable) {
+			readOnly(this._options.name || 'the request');
+		}
+		var entries = this._list();
+		update(entries);
+		if (this._options.onChange) {
+			this._options.onChange(entries);
+		}
+	};
+	PropertyList.prototype.get = function (key) {
+		var entries = this._list();
+		for (var i = 0; i < entries.length; i++) {
+			if (!entries[i].disabled && this._matches(entries[i], key)) {
+				return entries[i].value;
+			}
+		}
+		return undefined;
+	};
+	PropertyList.prototype.has = function (key, value) {
+		var entries = this._list();
+		for (var i = 0; i < entries.length; i++) {
+			if (!entries[i].disabled && this._matches(entries[i], key) &&
+				(value === undefined || entries[i].value === String(value))) {
+				return true;
+			}
+		}
+		return false;
+	};
+	PropertyList.prototype.one = function (key) {
+		var entries = this._list();
+		for (var i = 0; i < entries.length; i++) {
+			if (this._matches(entries[i], key)) {
+				return { key: entries[i].key, value: entries[i].value, disabled: !!entries[i].disabled };
+			}
+		}
+		return undefined;
+	};
+	PropertyList.prototype.all = function () {
+		return this._list().map(function (entry) {
+			return { key: entry.key, value: entry.value, disabled: !!entry.disabled };
+		});
+	};
+	PropertyList.prototype.count = function () {
+		return this._list().length;
+	};
+	PropertyList.prototype.each = function (fn) {
+		this.all().forEach(fn);
+	};
+	PropertyList.prototype.map = function (fn) {
+		return this.all().map(fn);
+	};
+	PropertyList.prototype.filter = function (fn) {
+		return this.all().filter(fn);
+	};
+	PropertyList.prototype.toObject = function () {
+		var object = {};
+		this._list().forEach(function (entry) {
+			if (!entry.disabled && !Object.prototype.hasOwnProperty.call(object, entry.key)) {
+				object[entry.key] = entry.value;
+			}
+		});
+		return object;
+	};
+	PropertyList.prototype.add = function (entry) {
+		var pair = toPair(entry);
+		this._change(function (entries) {
+			entries.push(pair);
+		});
+	};
+	PropertyList.prototype.upsert = function (entry) {
+		var pair = toPair(entry);
+		var self = this;
+		this._change(function (entries) {
+			for (var i = 0; i < entries.length; i++) {
+				if (self._matches(entries[i], pair.key)) {
+					entries[i] = pair;
+					return;
+				}
+			}
+			entries.push(pair);
+		});
+	};
+	PropertyList.prototype.remove = function (key) {
+		var self = this;
+		this._change(function (entries) {
+			for (var i = entries.length - 1; i >= 0; i--) {
+				if (self._matches(entries[i], key)) {
+					entries.splice(i, 1);
+				}
+			}
+		});
+	};
+	PropertyList.prototype.clear = function () {
+		this._change(function (entries) {
+			entries.length = 0;
+		});
+	};
+
+	// The URL is kept as written, so it may contain {{variables}} that do not
+	// survive a real URL parser.
+
+	function splitURL(url) {
+		var hash = '';
+		var hashAt = url.indexOf('#');
+		if (hashAt >= 0) {
+			hash = url.slice(hashAt);
+			url = url.slice(0, hashAt);
+		}
+		var query = [];
+		var queryAt = url.indexOf('?');
+		if (queryAt >= 0) {
+			url.slice(queryAt + 1).split('&').forEach(function (part) {
+				if (part === '') {
+					return;
+				}
+				var equals = part.indexOf('=');
+				if (equals < 0) {
+					query.push({ key: part, value: '' });
+				} else {
+					query.push({ key: part.slice(0, equals), value: part.slice(equals + 1) });
+				}
+			});
+			url = url.slice(0, queryAt);
+		}
+		return { base: url, query: query, hash: hash };
+	}
+
+	function joinURL(parts) {
+		var query = parts.query.filter(function (entry) {
+			return !entry.disabled;
+		}).map(function (entry) {
+			return entry.value === '' ? entry.key : entry.key + '=' + entry.value;
+		}).join('&');
+		return parts.base + (query ? '?' + query : '') + parts.hash;
+	}
+
+	function Url() {
+		this.query = new PropertyList(function () {
+			return splitURL(request.url).query;
+		}, {
+			name: 'the URL',
+			writable: editable,
+			onChange: function (entries) {
+				var parts = splitURL(request.url);
+				parts.query = entries;
+				request.url = joinURL(parts);
+			}
+		});
+	}
+	Url.prototype.toString = function () {
+		return request.url;
+	};
+	Url.prototype.update = function (url) {
+		if (!editable) {
+			readOnly('the URL');
+		}
+		request.url = String(url);
+	};
+	Url.prototype.getHost = function () {
+		var base = splitURL(request.url).base;
+		var schemeAt = base.indexOf('://');
+		if (schemeAt >= 0) {
+			base = base.slice(schemeAt + 3);
+		}
+		var slash = base.indexOf('/');
+		return slash < 0 ? base : base.slice(0, slash);
+	};
+	Url.prototype.getPath = function () {
+		var base = splitURL(request.url).base;
+		var schemeAt = base.indexOf('://');
+		if (schemeAt >= 0) {
+			base = base.slice(schemeAt + 3);
+		}
+		var slash = base.indexOf('/');
+		return slash < 0 ? '/' : base.slice(slash);
+	};
+	Url.prototype.getQueryString = function () {
+		var url = joinURL(splitURL(request.url));
+		var queryAt = url.indexOf('?');
+		return queryAt < 0 ? '' : url.slice(queryAt + 1).split('#')[0];
+	};
+	Url.prototype.toJSON = function () {
+		return request.url;
+	};
+
+	function ensureBody(mode) {
+		if (!request.body || request.body.mode !== mode) {
+			request.body = { mode: mode };
+		}
+		return request.body;
+	}
+
+	var body = {
+		get mode() {
+			return request.body ? request.body.mode : undefined;
+		},
+		get raw() {
+			return request.body && request.body.mode === 'raw' ? request.body.raw || '' : undefined;
+		},
+		set raw(value) {
+			if (!editable) {
+				readOnly('the body');
+			}
+			ensureBody('raw').raw = format(value);
+		},
+		urlencoded: new PropertyList(function () {
+			return request.body && request.body.mode === 'urlencoded' ? request.body.urlencoded || (request.body.urlencoded = []) : [];
+		}, {
+			name: 'the body',
+			writable: editable,
+			onChange: function (entries) {
+				ensureBody('urlencoded').urlencoded = entries;
+			}
+		}),
+		update: function (value) {
+			if (!editable) {
+				readOnly('the body');
+			}
+			if (typeof value === 'string') {
+				ensureBody('raw').raw = value;
+			} else if (value && value.mode === 'raw') {
+				ensureBody('raw').raw = format(value.raw);
+			} else if (value && value.mode === 'urlencoded') {
+				ensureBody('urlencoded').urlencoded = (value.urlencoded || []).map(toPair);
+			} else if (value && value.mode === 'graphql' && value.graphql) {
+				var graphql = ensureBody('graphql');
+				graphql.graphql = { query: String(value.graphql.query || '') };
+				if (value.graphql.variables !== undefined) {
+					graphql.graphql.variables = typeof value.graphql.variables === 'string' ?
+						JSON.parse(value.graphql.variables || 'null') : value.graphql.variables;
+				}
+			} else {
+				ensureBody('raw').raw = format(value);
+			}
+		},
+		isEmpty: function () {
+			if (!request.body) {
+				return true;
+			}
+			switch (request.body.mode) {
+				case 'raw':
+					return !request.body.raw;
+				case 'urlencoded':
+					return !(request.body.urlencoded || []).length;
+				case 'formdata':
+					return !(request.body.formdata || []).length;
+				default:
+					return !request.body.graphql;
+			}
+		},
+		toString: function () {
+			return request.body && request.body.mode === 'raw' ? request.body.raw || '' : '';
+		}
+	};
+
+	var pmRequest = {
+		get method() {
+			return request.method;
+		},
+		set method(value) {
+			if (!editable) {
+				readOnly('the method');
+			}
+			request.method = String(value).toUpperCase();
+		},
+		get url() {
+			return url;
+		},
+		set url(value) {
+			url.update(value);
+		},
+		headers: new PropertyList(function () {
+			return request.headers;
+		}, { name: 'headers', ignoreCase: true, writable: editable }),
+		body: body,
+		addHeader: function (header) {
+			this.headers.add(header);
+		},
+		removeHeader: function (key) {
+			this.headers.remove(key);
+		},
+		upsertHeader: function (header) {
+			this.headers.upsert(header);
+		},
+		toJSON: function () {
+			return request;
+		}
+	};
+	var url = new Url();
+
+	// Assertions, a subset of chai's expect API.
+
+	function AssertionError(message) {
+		this.name = 'AssertionError';
+		this.message = message;
+	}
+	AssertionError.prototype = Object.create(Error.prototype);
+	AssertionError.prototype.constructor = AssertionError;
+
+	function typeOf(value) {
+		if (value === null) {
+			return 'null';
+		}
+		if (Array.isArray(value)) {
+			return 'array';
+		}
+		if (value instanceof RegExp) {
+			return 'regexp';
+		}
+		if (value instanceof Date) {
+			return 'date';
+		}
+		return typeof value;
+	}
+
+	function deepEqual(a, b) {
+		if (a === b) {
+			return true;
+		}
+		if (typeof a === 'number' && typeof b === 'number') {
+			return a !== a && b !== b;
+		}
+		if (typeOf(a) !== typeOf(b) || typeof a !== 'object' || a === null) {
+			return false;
+		}
+		if (Array.isArray(a)) {
+			if (a.length !== b.length) {
+				return false;
+			}
+			for (var i = 0; i < a.length; i++) {
+				if (!deepEqual(a[i], b[i])) {
+					return false;
+				}
+			}
+			return true;
+		}
+		var keysA = Object.keys(a);
+		var keysB = Object.keys(b);
+		if (keysA.length !== keysB.length) {
+			return false;
+		}
+		for (var j = 0; j < keysA.length; j++) {
+			if (!Object.prototype.hasOwnProperty.call(b, keysA[j]) || !deepEqual(a[keysA[j]], b[keysA[j]])) {
+				return false;
+			}
+		}
+		return true;
+	}
+
+	function isResponse(value) {
+		return value === pmResponse && value !== undefined;
+	}
+
+	function Assertion(subject, message) {
+		this._subject = subject;
+		this._message = message;
+		this._negate = false;
+		this._deep = false;
+		this._any = false;
+	}
+	Assertion.prototype._assert = function (passed, phrase) {
+		if (this._negate) {
+			passed = !passed;
+		}
+		if (!passed) {
+			var subject = isResponse(this._subject) ? 'response' : inspect(this._subject);
+			var text = 'expected ' + subject + ' to ' + (this._negate ? 'not ' : '') + phrase;
+			throw new AssertionError(this._message ? this._message + ': ' + text : text);
+		}
+		return this;
+	};
+	['to', 'be', 'been', 'is', 'that', 'which', 'and', 'has', 'have', 'with', 'at', 'of', 'same', 'but', 'does', 'still', 'also', 'all']
+		.forEach(function (name) {
+			Object.defineProperty(Assertion.prototype, name, {
+				get: function () {
+					return this;
+				}
+			});
+		});
+	Object.defineProperty(Assertion.prototype, 'not', {
+		get: function () {
+			this._negate = !this._negate;
+			return this;
+		}
+	});
+	Object.defineProperty(Assertion.prototype, 'deep', {
+		get: function () {
+			this._deep = true;
+			return this;
+		}
+	});
+	Object.defineProperty(Assertion.prototype, 'any', {
+		get: function () {
+			this._any = true;
+			return this;
+		}
+	});
+
+	function defineCheck(name, check) {
+		Object.defineProperty(Assertion.prototype, name, {
+			get: function () {
+				var outcome = check(this._subject);
+				return this._assert(outcome[0], outcome[1]);
+			}
+		});
+	}
+	defineCheck('ok', function (subject) {
+		if (isResponse(subject)) {
+			return [subject.code === 200, 'have status 200 but got ' + subject.code];
+		}
+		return [!!subject, 'be truthy'];
+	});
+	defineCheck('true', function (subject) {
+		return [subject === true, 'be true'];
+	});
+	defineCheck('false', function (subject) {
+		return [subject === false, 'be false'];
+	});
+	defineCheck('null', function (subject) {
+		return [subject === null, 'be null'];
+	});
+	defineCheck('undefined', function (subject) {
+		return [subject === undefined, 'be undefined'];
+	});
+	defineCheck('NaN', function (subject) {
+		return [typeof subject === 'number' && subject !== subject, 'be NaN'];
+	});
+	defineCheck('exist', function (subject) {
+		return [subject !== null && subject !== undefined, 'exist'];
+	});
+	defineCheck('empty', function (subject) {
+		if (typeof subject === 'string' || Array.isArray(subject)) {
+			return [subject.length === 0, 'be empty'];
+		}
+		if (subject && typeof subject === 'object') {
+			return [Object.keys(subject).length === 0, 'be empty'];
+		}
+		return [false, 'be empty'];
+	});
+
+	// Response checks, pm.response.to.be.success and friends.
+	var statusClasses = {
+		success: [200, 299],
+		info: [100, 199],
+		redirection: [300, 399],
+		clientError: [400, 499],
+		serverError: [500, 599],
+		error: [400, 599]
+	};
+	Object.keys(statusClasses).forEach(function (name) {
+		var range = statusClasses[name];
+		defineCheck(name, function (subject) {
+			var code = subject ? subject.code : undefined;
+			return [code >= range[0] && code <= range[1], 'have a status between ' + range[0] + ' and ' + range[1] + ' but got ' + code];
+		});
+	});
+	var statusCodes = {
+		accepted: 202,
+		withoutContent: 204,
+		badRequest: 400,
+		unauthorized: 401,
+		unauthorised: 401,
+		forbidden: 403,
+		notFound: 404,
+		rateLimited: 429
+	};
+	Object.keys(statusCodes).forEach(function (name) {
+		var code = statusCodes[name];
+		defineCheck(name, function (subject) {
+			return [subject && subject.code === code, 'have status ' + code + ' but got ' + (subject ? subject.code : undefined)];
+		});
+	});
+	defineCheck('json', function (subject) {
+		try {
+			JSON.parse(isResponse(subject) ? subject.text() : subject);
+			return [true, 'be JSON'];
+		} catch (e) {
+			return [false, 'be JSON'];
+		}
+	});
+
+	var methods = {
+		equal: function (expected) {
+			var passed = this._deep ? deepEqual(this._subject, expected) : this._subject === expected;
+			return this._assert(passed, (this._deep ? 'deeply ' : '') + 'equal ' + inspect(expected));
+		},
+		eql: function (expected) {
+			return this._assert(deepEqual(this._subject, expected), 'deeply equal ' + inspect(expected));
+		},
+		above: function (n) {
+			return this._assert(this._subject > n, 'be above ' + n);
+		},
+		below: function (n) {
+			return this._assert(this._subject < n, 'be below ' + n);
+		},
+		least: function (n) {
+			return this._assert(this._subject >= n, 'be at least ' + n);
+		},
+		most: function (n) {
+			return this._assert(this._subject <= n, 'be at most ' + n);
+		},
+		within: function (low, high) {
+			return this._assert(this._subject >= low && this._subject <= high, 'be within ' + low + '..' + high);
+		},
+		a: function (type) {
+			type = String(type).toLowerCase();
+			return this._assert(typeOf(this._subject) === type, 'be a ' + type);
+		},
+		include: function (expected) {
+			var subject = this._subject;
+			var passed = false;
+			var deep = this._deep;
+			if (typeof subject === 'string') {
+				passed = subject.indexOf(String(expected)) >= 0;
+			} else if (Array.isArray(subject)) {
+				passed = subject.some(function (element) {
+					return deep ? deepEqual(element, expected) : element === expected;
+				});
+			} else if (subject && typeof subject === 'object' && expected && typeof expected === 'object') {
+				passed = Object.keys(expected).every(function (key) {
+					return deep ? deepEqual(subject[key], expected[key]) : subject[key] === expected[key];
+				});
+			}
+			return this._assert(passed, 'include ' + inspect(expected));
+		},
+		property: function (name, value) {
+			var subject = this._subject;
+			var has = subject !== null && subject !== undefined && Object(subject)[name] !== undefined;
+			if (arguments.length < 2) {
+				this._assert(has, 'have property ' + inspect(name));
+			} else {
+				var actual = has ? subject[name] : undefined;
+				var same = this._deep ? deepEqual(actual, value) : actual === value;
+				this._assert(has && same, 'have property ' + inspect(name) + ' of ' + inspect(value) + ', but got ' + inspect(actual));
+			}
+			if (!this._negate && has) {
+				this._subject = subject[name];
+			}
+			return this;
+		},
+		lengthOf: function (n) {
+			var length = this._subject === null || this._subject === undefined ? undefined : this._subject.length;
+			return this._assert(length === n, 'have a length of ' + n + ' but got ' + length);
+		},
+		match: function (pattern) {
+			return this._assert(pattern instanceof RegExp && pattern.test(String(this._subject)), 'match ' + String(pattern));
+		},
+		oneOf: function (list) {
+			var subject = this._subject;
+			var deep = this._deep;
+			return this._assert(Array.isArray(list) && list.some(function (element) {
+				return deep ? deepEqual(element, subject) : element === subject;
+			}), 'be one of ' + inspect(list));
+		},
+		keys: function () {
+			var expected = Array.isArray(arguments[0]) ? arguments[0] : Array.prototype.slice.call(arguments);
+			if (!Array.isArray(arguments[0]) && arguments[0] && typeof arguments[0] === 'object') {
+				expected = Object.keys(arguments[0]);
+			}
+			var actual = this._subject && typeof this._subject === 'object' ? Object.keys(this._subject) : [];
+			var passed;
+			if (this._any) {
+				passed = expected.some(function (key) {
+					return actual.indexOf(key) >= 0;
+				});
+			} else {
+				passed = expected.length === actual.length && expected.every(function (key) {
+					return actual.indexOf(key) >= 0;
+				});
+			}
+			return this._assert(passed, 'have ' + (this._any ? 'any of ' : '') + 'keys ' + inspect(expected));
+		},
+		instanceOf: function (constructor) {
+			return this._assert(this._subject instanceof constructor, 'be an instance of ' + (constructor && constructor.name));
+		},
+		status: function (expected) {
+			var subject = this._subject || {};
+			if (typeof expected === 'string') {
+				return this._assert(subject.status === expected, 'have status ' + inspect(expected) + ' but got ' + inspect(subject.status));
+			}
+			return this._assert(subject.code === expected, 'have status ' + expected + ' but got ' + subject.code);
+		},
+		header: function (name, value) {
+			var headers = this._subject && this._subject.headers;
+			if (!headers || typeof headers.has !== 'function') {
+				return this._assert(false, 'have headers');
+			}
+			if (arguments.length < 2) {
+				return this._assert(headers.has(name), 'have header ' + inspect(name));
+			}
+			return this._assert(headers.get(name) === String(value),
+				'have header ' + inspect(name) + ' with value ' + inspect(String(value)) + ' but got ' + inspect(headers.get(name)));
+		},
+		body: function (expected) {
+			var text = isResponse(this._subject) ? this._subject.text() : String(this._subject);
+			if (arguments.length === 0) {
+				return this._assert(text.length > 0, 'have a body');
+			}
+			if (typeof expected === 'string') {
+				return this._assert(text === expected, 'have body ' + inspect(expected));
+			}
+			if (expected instanceof RegExp) {
+				return this._assert(expected.test(text), 'have a body matching ' + String(expected));
+			}
+			var parsed;
+			try {
+				parsed = JSON.parse(text);
+			} catch (e) {
+				return this._assert(false, 'have a JSON body');
+			}
+			return this._assert(deepEqual(parsed, expected), 'have body ' + inspect(expected));
+		},
+		jsonBody: function (path, value) {
+			var data;
+			try {
+				data = isResponse(this._subject) ? this._subject.json() : this._subject;
+			} catch (e) {
+				return this._assert(false, 'have a JSON body');
+			}
+			if (arguments.length === 0) {
+				return this._assert(true, 'have a JSON body');
+			}
+			var found = true;
+			String(path).split('.').forEach(function (key) {
+				if (found && data !== null && typeof data === 'object' && Object.prototype.hasOwnProperty.call(data, key)) {
+					data = data[key];
+				} else {
+					found = false;
+				}
+			});
+			if (arguments.length < 2) {
+				return this._assert(found, 'have JSON body property ' + inspect(path));
+			}
+			return this._assert(found && deepEqual(data, value),
+				'have JSON body property ' + inspect(path) + ' of ' + inspect(value) + ' but got ' + inspect(found ? data : undefined));
+		},
+		jsonSchema: function (schema) {
+			var data;
+			try {
+				data = isResponse(this._subject) ? this._subject.json() : this._subject;
+			} catch (e) {
+				return this._assert(false, 'have a JSON body');
+			}
+			var errors = host.jsonSchema(JSON.stringify(schema), JSON.stringify(data === undefined ? null : data));
+			return this._assert(errors.length === 0, 'match the JSON schema' + (errors.length ? ': ' + errors.join('; ') : ''));
+		}
+	};
+	var aliases = {
+		equal: ['equals', 'eq'],
+		eql: ['eqls'],
+		above: ['gt', 'greaterThan'],
+		below: ['lt', 'lessThan'],
+		least: ['gte'],
+		most: ['lte'],
+		a: ['an'],
+		include: ['includes', 'contain', 'contains'],
+		lengthOf: ['length'],
+		match: ['matches'],
+		keys: ['key'],
+		instanceOf: ['instanceof']
+	};
+	Object.keys(methods).forEach(function (name) {
+		Assertion.prototype[name] = methods[name];
+		(aliases[name] || []).forEach(function (alias) {
+			Assertion.prototype[alias] = methods[name];
+		});
+	});
+
+	function expect(subject, message) {
+		return new Assertion(subject, message);
+	}
+	expect.fail = function (message) {
+		throw new AssertionError(message === undefined ? 'expect.fail()' : String(message));
+	};
+
+	// The response, only present in test scripts.
+
+	var pmResponse;
+	if (response) {
+		var responseHeaders = [];
+		Object.keys(response.headers || {}).sort().forEach(function (key) {
+			response.headers[key].forEach(function (value) {
+				responseHeaders.push({ key: key, value: value });
+			});
+		});
+		var parsedJSON;
+		pmResponse = {
+			code: response.code,
+			status: response.status,
+			responseTime: response.responseTime,
+			responseSize: response.responseSize,
+			headers: new PropertyList(function () {
+				return responseHeaders;
+			}, { name: 'response headers', ignoreCase: true }),
+			text: function () {
+				return response.body;
+			},
+			json: function () {
+				if (parsedJSON === undefined) {
+					try {
+						parsedJSON = { value: JSON.parse(response.body) };
+					} catch (e) {
+						throw new SyntaxError('the response body is not valid JSON: ' + e.message);
+					}
+				}
+				return parsedJSON.value;
+			},
+			reason: function () {
+				return response.status;
+			}
+		};
+		Object.defineProperty(pmResponse, 'to', {
+			get: function () {
+				return new Assertion(pmResponse).to;
+			}
+		});
+	}
+
+	// pm and the globals it comes with.
+
+	var tests = {};
+
+	function test(name, fn) {
+		name = String(name);
+		if (typeof fn !== 'function') {
+			host.test(name, false, 'pm.test needs a function', false);
+			return pm;
+		}
+		try {
+			fn(function () {});
+			host.test(name, true, '', false);
+		} catch (e) {
+			host.test(name, false, e && e.message !== undefined ? String(e.message) : format(e), false);
+		}
+		return pm;
+	}
+	test.skip = function (name) {
+		host.test(String(name), true, '', true);
+		return pm;
+	};
+
+	var pm = {
+		info: input.info,
+		request: pmRequest,
+		response: pmResponse,
+		variables: new VariableScope('local', true),
+		iterationData: new VariableScope('data', false),
+		environment: new VariableScope('environment', true),
+		collectionVariables: new VariableScope('collection', true),
+		globals: new VariableScope('globals', true),
+		test: test,
+		expect: expect,
+		sendRequest: function () {
+			throw new Error('pm.sendRequest is not available, scripts have no network access');
+		},
+		visualizer: {
+			set: function () {}
+		}
+	};
+	pm.environment.name = input.environmentName;
+
+	function log(level) {
+		return function () {
+			host.log(level, Array.prototype.map.call(arguments, format).join(' '));
+		};
+	}
+
+	global.pm = pm;
+	global.console = {
+		log: log('log'),
+		info: log('info'),
+		warn: log('warn'),
+		error: log('error'),
+		debug: log('debug')
+	};
+
+	// The legacy API that older Postman collections still use.
+	global.tests = tests;
+	global.postman = {
+		getEnvironmentVariable: function (key) {
+			return pm.environment.get(key);
+		},
+		setEnvironmentVariable: function (key, value) {
+			pm.environment.set(key, value);
+		},
+		clearEnvironmentVariable: function (key) {
+			pm.environment.unset(key);
+		},
+		getGlobalVariable: function (key) {
+			return pm.globals.get(key);
+		},
+		setGlobalVariable: function (key, value) {
+			pm.globals.set(key, value);
+		},
+		clearGlobalVariable: function (key) {
+			pm.globals.unset(key);
+		},
+		getResponseHeader: function (key) {
+			return pmResponse ? pmResponse.headers.get(key) : undefined;
+		}
+	};
+	global.environment = pm.environment.toObject();
+	global.globals = pm.globals.toObject();
+	global.request = {
+		method: request.method,
+		url: request.url,
+		headers: pmRequest.headers.toObject()
+	};
+	if (response) {
+		global.responseBody = response.body;
+		global.responseCode = { code: response.code, name: response.status };
+		global.responseTime = response.responseTime;
+		global.responseHeaders = pmResponse.headers.toObject();
+	}
+
+	// finish is called by Go after the last script of the phase.
+	return function finish() {
+		Object.keys(tests).forEach(function (name) {
+			host.test(name, !!tests[name], tests[name] ? '' : 'test returned false', false);
+		});
+		return JSON.stringify(request);
+	};
+})
@@ -0,0 +1,350 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/metrics"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/suar-net/suar-be/internal/model"
+)
+
+// Scripts can neither block nor do I/O, so the wall clock time a script
+// takes is the CPU time it uses.
+const (
+	scriptTimeLimit = 2 * time.Second
+	// scriptMemoryLimit is how much the heap of the whole process may grow
+	// while a script runs, see watch.
+	scriptMemoryLimit  = 256 << 20
+	maxScriptSize      = 256 << 10
+	maxScriptCallStack = 1024
+	maxConsoleEntries  = 200
+	maxConsoleLength   = 4096
+	maxScriptTests     = 500
+
+	// Sizes the allocating builtins of script_pm.js refuse to exceed. A
+	// string of maxScriptStringLength UTF-16 characters and an array of
+	// maxScriptArrayLength values each take about 16 MB.
+	maxScriptStringLength = 8 << 20
+	maxScriptArrayLength  = 1 << 20
+	maxScriptBufferSize   = 16 << 20
+)
+
+// Script phases, named like Postman's events.
+const (
+	scriptPhasePreRequest = "prerequest"
+	scriptPhaseTest       = "test"
+)
+
+//go:embed script_pm.js
+var scriptPrelude string
+
+var scriptPreludeProgram = goja.MustCompile("script_pm.js", scriptPrelude, true)
+
+// scriptInfo is exposed to scripts as pm.info.
+type scriptInfo struct {
+	EventName      string `json:"eventName"`
+	RequestName    string `json:"requestName"`
+	RequestID      string `json:"requestId"`
+	Iteration      int    `json:"iteration"`
+	IterationCount int    `json:"iterationCount"`
+}
+
+// scriptResponse is the response as test scripts see it.
+type scriptResponse struct {
+	Code         int                 `json:"code"`
+	Status       string              `json:"status"`
+	Headers      map[string][]string `json:"headers"`
+	Body         string              `json:"body"`
+	ResponseTime int64               `json:"responseTime"`
+	ResponseSize int64               `json:"responseSize"`
+}
+
+func newScriptResponse(resp *model.DTOResponse) *scriptResponse {
+	headers := resp.Headers
+	if headers == nil {
+		headers = map[string][]string{}
+	}
+	return &scriptResponse{
+		Code:         resp.StatusCode,
+		Status:       http.StatusText(resp.StatusCode),
+		Headers:      headers,
+		Body:         string(resp.Body),
+		ResponseTime: resp.Duration.Milliseconds(),
+		ResponseSize: resp.Size,
+	}
+}
+
+// scriptSandbox runs the scripts of one phase in a fresh JavaScript runtime.
+// The runtime has no module loader, timers, network or filesystem access,
+// only the pm.* API installed by script_pm.js, whose host side is
+// implemented here.
+type scriptSandbox struct {
+	vm        *goja.Runtime
+	variables *runVariables
+	result    *model.DTOScriptResult
+	finish    goja.Callable
+	failed    bool
+}
+
+// newScriptSandbox prepares a runtime for a phase. The request is the saved
+// request as written, pre-request scripts may change it.
+func newScriptSandbox(variables *runVariables, info scriptInfo, environmentName string, request *model.CollectionRequest, response *scriptResponse) (*scriptSandbox, error) {
+	vm := goja.New()
+	vm.SetMaxCallStackSize(maxScriptCallStack)
+	s := &scriptSandbox{
+		vm:        vm,
+		variables: variables,
+		result:    &model.DTOScriptResult{Tests: []model.DTOScriptTest{}},
+	}
+
+	input, err := json.Marshal(map[string]any{
+		"info":            info,
+		"request":         request,
+		"response":        response,
+		"environmentName": environmentName,
+		"limits": map[string]int{
+			"stringLength": maxScriptStringLength,
+			"arrayLength":  maxScriptArrayLength,
+			"bufferSize":   maxScriptBufferSize,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	prelude, err := vm.RunProgram(scriptPreludeProgram)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the script API: %w", err)
+	}
+	install, ok := goja.AssertFunction(prelude)
+	if !ok {
+		return nil, errors.New("failed to load the script API")
+	}
+	finish, err := install(goja.Undefined(), vm.GlobalObject(), vm.ToValue(s.host()), vm.ToValue(string(input)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the script API: %w", err)
+	}
+	if s.finish, ok = goja.AssertFunction(finish); !ok {
+		return nil, errors.New("failed to load the script API")
+	}
+	return s, nil
+}
+
+// host is the Go side of the pm.* API.
+func (s *scriptSandbox) host() map[string]any {
+	return map[string]any{
+		"get": func(scope, key string) goja.Value {
+			if value, ok := s.variables.get(scope, key); ok {
+				return s.vm.ToValue(value)
+			}
+			return goja.Undefined()
+		},
+		"has": func(scope, key string) bool {
+			_, ok := s.variables.get(scope, key)
+			return ok
+		},
+		"set": func(scope, key, value string) {
+			s.variables.set(scope, key, value)
+		},
+		"unset": func(scope, key string) {
+			s.variables.unset(scope, key)
+		},
+		"clear": func(scope string) {
+			s.variables.clear(scope)
+		},
+		"toObject": func(scope string) map[string]any {
+			values := s.variables.all(scope)
+			object := make(map[string]any, len(values))
+			for key, value := range values {
+				object[key] = value
+			}
+			return object
+		},
+		"replaceIn": func(template string) string {
+			resolved, _ := s.variables.resolve(template)
+			return resolved
+		},
+		"log": func(level, message string) {
+			if len(s.result.Console) >= maxConsoleEntries {
+				s.result.ConsoleDropped++
+				return
+			}
+			if len(message) > maxConsoleLength {
+				message = string(trimIncompleteRune([]byte(message[:maxConsoleLength]))) + "..."
+			}
+			s.result.Console = append(s.result.Console, model.DTOConsoleEntry{Level: level, Message: message})
+		},
+		"test": func(name string, passed bool, message string, skipped bool) {
+			if len(s.result.Tests) >= maxScriptTests {
+				return
+			}
+			s.result.Tests = append(s.result.Tests, model.DTOScriptTest{Name: name, Passed: passed, Skipped: skipped, Error: message})
+		},
+		"jsonSchema": func(schema, data string) []string {
+			return validateScriptSchema(schema, data)
+		},
+	}
+}
+
+// run executes one script. An error stops the phase, the scripts after it
+// are not run.
+func (s *scriptSandbox) run(ctx context.Context, name, source string) bool {
+	if s.failed {
+		return false
+	}
+	if len(source) > maxScriptSize {
+		s.fail(fmt.Sprintf("%s: script is larger than %d bytes", name, maxScriptSize))
+		return false
+	}
+
+	stop := s.watch(ctx)
+	_, err := s.vm.RunScript(name, source)
+	stop()
+	if err != nil {
+		s.fail(scriptErrorMessage(err))
+		return false
+	}
+	return true
+}
+
+func (s *scriptSandbox) fail(message string) {
+	s.failed = true
+	s.result.Error = message
+}
+
+// watch interrupts the runtime when the phase runs out of time or the heap
+// grows by more than scriptMemoryLimit. Neither Go nor goja account memory
+// per runtime, so this is a safety net for the whole process rather than a
+// limit of one script: the heap of the process is sampled, and what other
+// scripts and requests allocate meanwhile counts too. The limit is set well
+// above what scripts running side by side use, so ordinary scripts are not
+// stopped for each other's allocations; when a runaway script trips it,
+// scripts running at the same moment may be stopped with it. The budget of
+// a single script comes from script_pm.js, which caps the builtins that
+// allocate a lot in a single call, as an interrupt only takes effect
+// between instructions. What is left, such as growing a string in a loop,
+// grows a step per instruction and is stopped here.
+func (s *scriptSandbox) watch(ctx context.Context) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		sample := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+		metrics.Read(sample)
+		baseline := sample[0].Value.Uint64()
+
+		deadline := time.NewTimer(scriptTimeLimit)
+		defer deadline.Stop()
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				s.vm.Interrupt(fmt.Errorf("script was canceled: %w", ctx.Err()))
+				return
+			case <-deadline.C:
+				s.vm.Interrupt(fmt.Errorf("script exceeded the time limit of %v", scriptTimeLimit))
+				return
+			case <-ticker.C:
+				metrics.Read(sample)
+				if used := sample[0].Value.Uint64(); used > baseline && used-baseline > scriptMemoryLimit {
+					s.vm.Interrupt(fmt.Errorf("script exceeded the memory limit of %d MB", scriptMemoryLimit>>20))
+					return
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+		s.vm.ClearInterrupt()
+	}
+}
+
+// close runs the end of phase bookkeeping and returns the request as the
+// scripts left it.
+func (s *scriptSandbox) close(ctx context.Context) (*model.CollectionRequest, *model.DTOScriptResult) {
+	stop := s.watch(ctx)
+	value, err := s.finish(goja.Undefined())
+	stop()
+	if err != nil {
+		if !s.failed {
+			s.fail(scriptErrorMessage(err))
+		}
+		return nil, s.result
+	}
+
+	var request model.CollectionRequest
+	if err := json.Unmarshal([]byte(value.String()), &request); err != nil {
+		if !s.failed {
+			s.fail(fmt.Sprintf("scripts left an invalid request: %v", err))
+		}
+		return nil, s.result
+	}
+	return &request, s.result
+}
+
+func scriptErrorMessage(err error) string {
+	var interrupted *goja.InterruptedError
+	if errors.As(err, &interrupted) {
+		if cause, ok := interrupted.Value().(error); ok {
+			return cause.Error()
+		}
+		return interrupted.String()
+	}
+	var stackOverflow *goja.StackOverflowError
+	if errors.As(err, &stackOverflow) {
+		return fmt.Sprintf("script exceeded the maximum call stack size of %d", maxScriptCallStack)
+	}
+	var exception *goja.Exception
+	if errors.As(err, &exception) {
+		// Compile errors carry their type twice.
+		return strings.Replace(exception.Error(), "SyntaxError: SyntaxError: ", "SyntaxError: ", 1)
+	}
+	return err.Error()
+}
+
+// validateScriptSchema backs pm.response.to.have.jsonSchema.
+func validateScriptSchema(schemaJSON, dataJSON string) []string {
+	schemaDoc, err := jsonschema.UnmarshalJSON(bytes.NewReader([]byte(schemaJSON)))
+	if err != nil {
+		return []string{fmt.Sprintf("invalid schema: %v", err)}
+	}
+	data, err := jsonschema.UnmarshalJSON(bytes.NewReader([]byte(dataJSON)))
+	if err != nil {
+		return []string{fmt.Sprintf("invalid data: %v", err)}
+	}
+
+	compiler := newSchemaCompiler()
+	compiler.DefaultDraft(jsonschema.Draft7)
+	if err := compiler.AddResource("mem://script/schema.json", schemaDoc); err != nil {
+		return []string{fmt.Sprintf("invalid schema: %v", err)}
+	}
+	schema, err := compiler.Compile("mem://script/schema.json")
+	if err != nil {
+		return []string{fmt.Sprintf("invalid schema: %v", err)}
+	}
+
+	var result model.DTOContractResult
+	addSchemaViolations(&result, "", schema.Validate(data))
+	messages := []string{}
+	for _, violation := range result.Violations {
+		pointer := violation.Pointer
+		if pointer == "" {
+			pointer = "/"
+		}
+		messages = append(messages, pointer+": "+violation.Message)
+	}
+	return messages
+}
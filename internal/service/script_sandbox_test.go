@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/suar-net/suar-be/internal/model"
+)
+
+// runTestScript runs a script in a fresh pre-request sandbox and returns the
+// result of the phase.
+func runTestScript(t *testing.T, ctx context.Context, source string) *model.DTOScriptResult {
+	t.Helper()
+	variables := newRunVariables(&model.Collection{}, nil)
+	request := &model.CollectionRequest{Method: "GET", URL: "https://api.test/a"}
+	sandbox, err := newScriptSandbox(variables, scriptInfo{EventName: scriptPhasePreRequest, RequestName: "a"}, "", request, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sandbox.run(ctx, "script", source)
+	_, result := sandbox.close(ctx)
+	return result
+}
+
+func TestScriptSandboxLimits(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		source string
+		want   string
+	}{
+		{"repeat", context.Background(), `'x'.repeat(8e9)`, "string length exceeds the sandbox limit"},
+		{"repeat of a long string", context.Background(), `'x'.repeat(1e6).repeat(1e3)`, "string length exceeds the sandbox limit"},
+		{"padEnd", context.Background(), `''.padEnd(1e9, 'ab')`, "string length exceeds the sandbox limit"},
+		{"join of a long array", context.Background(), `new Array(1e9).join('x')`, "array length exceeds the sandbox limit"},
+		{"join of long strings", context.Background(), `var s = 'x'.repeat(1e6); [s, s, s, s, s, s, s, s, s].join('')`, "string length exceeds the sandbox limit"},
+		{"array constructor", context.Background(), `Array(1e8)`, "array length exceeds the sandbox limit"},
+		{"constructor property", context.Background(), `[].constructor(1e8)`, "array length exceeds the sandbox limit"},
+		{"fill after growing", context.Background(), `var a = []; a.length = 1e9; a.fill(0)`, "array length exceeds the sandbox limit"},
+		{"Array.from", context.Background(), `Array.from({length: 1e9})`, "array length exceeds the sandbox limit"},
+		{"typed array", context.Background(), `new Float64Array(1e8)`, "buffer size exceeds the sandbox limit"},
+		{"array buffer", context.Background(), `new ArrayBuffer(4e9)`, "buffer size exceeds the sandbox limit"},
+		{"doubling a string", context.Background(), `var s = 'x'.repeat(1 << 20); var keep = []; while (true) { keep.push(s + keep.length); }`, "memory limit of 256 MB"},
+		{"recursion", context.Background(), `function f() { return f() + 1; } f()`, "maximum call stack size of 1024"},
+		{"canceled", canceled, `while (true) {}`, "script was canceled"},
+		{"endless loop", context.Background(), `while (true) {}`, "time limit of 2s"},
+	}
+	for _, tt := range tests {
+		result := runTestScript(t, tt.ctx, tt.source)
+		if !strings.Contains(result.Error, tt.want) {
+			t.Errorf("%s: error = %q, want %q", tt.name, result.Error, tt.want)
+		}
+	}
+}
+
+func TestScriptSandboxAllowsOrdinaryAllocations(t *testing.T) {
+	source := `
+		var a = new Array(3).fill(1);
+		var b = Array.from('abc');
+		var c = [3, 1, 2].sort().reverse();
+		var s = 'ab'.repeat(3).padStart(8, '-') + [1, null, 'x'].join('|');
+		var bytes = new Uint8Array([1, 2, 3]);
+		pm.test('values', function () {
+			pm.expect(a).to.eql([1, 1, 1]);
+			pm.expect(b).to.eql(['a', 'b', 'c']);
+			pm.expect(c).to.eql([3, 2, 1]);
+			pm.expect(s).to.equal('--ababab1||x');
+			pm.expect(bytes.length).to.equal(3);
+			pm.expect(a instanceof Array && Array.isArray(a) && bytes instanceof Uint8Array).to.be.true;
+			pm.expect([].join()).to.equal('');
+			pm.expect(String([1, [2, 3]])).to.equal('1,2,3');
+		});
+	`
+	result := runTestScript(t, context.Background(), source)
+	if result.Error != "" || len(result.Tests) != 1 || !result.Tests[0].Passed {
+		t.Errorf("result = %+v", result)
+	}
+}
+
+// The memory limit is measured on the heap of the whole process, scripts
+// allocating side by side within their own budget must not trip it for each
+// other.
+func TestScriptSandboxConcurrentAllocations(t *testing.T) {
+	source := `
+		var keep = [];
+		for (var i = 0; i < 3; i++) {
+			keep.push('x'.repeat(4 << 20) + i);
+		}
+		var until = Date.now() + 300;
+		while (Date.now() < until) {}
+		pm.test('kept', function () { pm.expect(keep.length).to.equal(3); });
+	`
+	const scripts = 6
+	results := make(chan *model.DTOScriptResult, scripts)
+	for range scripts {
+		go func() {
+			results <- runTestScript(t, context.Background(), source)
+		}()
+	}
+	for range scripts {
+		if result := <-results; result.Error != "" || len(result.Tests) != 1 || !result.Tests[0].Passed {
+			t.Errorf("result = %+v", result)
+		}
+	}
+}
+
+func TestValidateScriptSchema(t *testing.T) {
+	fileURL := secretSchemaFile(t)
+	tests := []struct {
+		name, schema, data string
+		want               []string
+	}{
+		{"valid", `{"type": "object", "required": ["id"]}`, `{"id": 1}`, []string{}},
+		{"violation", `{"type": "object", "properties": {"id": {"type": "string"}}}`, `{"id": 1}`, []string{"/id: "}},
+		{"root violation", `{"type": "array"}`, `{}`, []string{"/: "}},
+		{"invalid schema", `{`, `{}`, []string{"invalid schema"}},
+		{"invalid data", `{}`, `{`, []string{"invalid data"}},
+		{"external reference", `{"$ref": "` + fileURL + `"}`, `"public"`, []string{errExternalSchemaRef.Error()}},
+	}
+	for _, tt := range tests {
+		got := validateScriptSchema(tt.schema, tt.data)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: messages = %q, want %q", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if !strings.Contains(got[i], tt.want[i]) {
+				t.Errorf("%s: messages = %q, want %q", tt.name, got, tt.want)
+			}
+		}
+	}
+}
@@ -2,32 +2,177 @@ package service
 
 import (
 	"context"
+	"errors"
+	"os"
 
+	"github.com/gorilla/websocket"
 	"github.com/suar-net/suar-be/internal/config"
 	"github.com/suar-net/suar-be/internal/model"
 	"github.com/suar-net/suar-be/internal/repository"
 )
 
 type IRequestService interface {
-	ProcessRequest(ctx context.Context, dto *model.DTORequest) (*model.DTOResponse, error)
+	ProcessRequest(ctx context.Context, userID *int, dto *model.DTORequest) (*model.DTOResponse, error)
+	OpenSpooledResponse(token string) (*SpooledResponse, *os.File, error)
+	StreamRequest(ctx context.Context, userID *int, dto *model.DTORequest, emit func(model.DTOStreamEvent) error) error
+	CancelStream(userID *int, streamID string) error
+	StopStreams()
+	IntrospectGraphQL(ctx context.Context, userID int, dto *model.DTOGraphQLIntrospectionRequest) (*model.DTOGraphQLSchema, error)
 	GetHistory()
 }
 
+type IWebSocketService interface {
+	Dial(ctx context.Context, dto *model.DTOWebSocketRequest) (*WebSocketSession, error)
+	Relay(ctx context.Context, userID *int, session *WebSocketSession, client *websocket.Conn) error
+}
+
+type IGRPCService interface {
+	ListServices(ctx context.Context, dto *model.DTOGRPCListRequest) (*model.DTOGRPCServiceList, error)
+	Invoke(ctx context.Context, userID *int, dto *model.DTOGRPCRequest) (*model.DTOGRPCResponse, error)
+}
+
+type IImportService interface {
+	ImportCurl(ctx context.Context, userID *int, dto *model.DTOCurlImportRequest) (*model.DTOCurlImportResponse, error)
+	ImportPostman(ctx context.Context, userID int, data []byte) (*model.DTOCollectionImportResponse, error)
+	ImportInsomnia(ctx context.Context, userID int, data []byte) (*model.DTOCollectionImportResponse, error)
+	ImportOpenAPI(ctx context.Context, userID int, data []byte) (*model.DTOCollectionImportResponse, error)
+	ImportHAR(ctx context.Context, userID int, data []byte) (*model.DTOCollectionImportResponse, error)
+}
+
+type ICollectionService interface {
+	ListCollections(ctx context.Context, userID int) ([]*model.Collection, error)
+	GetCollection(ctx context.Context, id int, userID int) (*model.Collection, error)
+	DeleteCollection(ctx context.Context, id int, userID int) error
+	ExportPostman(ctx context.Context, id int, userID int) (*model.PostmanCollection, error)
+	SetItemContract(ctx context.Context, collectionID, itemID, userID int, contract *model.Contract) error
+	SetItemExtractions(ctx context.Context, collectionID, itemID, userID int, extractions []model.Extraction) error
+	SetItemExamples(ctx context.Context, collectionID, itemID, userID int, examples []model.ResponseExample) error
+	RunItem(ctx context.Context, collectionID, itemID, userID int, dto *model.DTOItemRunRequest) (*model.DTOItemRunResult, error)
+}
+
+type IRunService interface {
+	StartRun(ctx context.Context, collectionID, userID int, dto *model.DTOCollectionRunRequest) (*model.CollectionRun, error)
+	ListRuns(ctx context.Context, userID int, collectionID *int) ([]*model.CollectionRun, error)
+	GetRun(ctx context.Context, id int, userID int) (*model.CollectionRun, error)
+	CancelRun(ctx context.Context, id int, userID int) error
+	Start()
+	Shutdown(ctx context.Context) error
+}
+
+type IMonitorService interface {
+	CreateMonitor(ctx context.Context, userID int, dto *model.DTOMonitorRequest) (*model.Monitor, error)
+	ListMonitors(ctx context.Context, userID int) ([]*model.Monitor, error)
+	GetMonitor(ctx context.Context, id int, userID int) (*model.Monitor, error)
+	UpdateMonitor(ctx context.Context, id int, userID int, dto *model.DTOMonitorRequest) (*model.Monitor, error)
+	DeleteMonitor(ctx context.Context, id int, userID int) error
+	TriggerMonitor(ctx context.Context, id int, userID int) error
+	ListResults(ctx context.Context, id int, userID int, limit int) ([]*model.MonitorResult, error)
+	GetStats(ctx context.Context, id int, userID int, window string) (*model.DTOMonitorStats, error)
+	ListAlerts(ctx context.Context, monitorID int, userID int) ([]*model.MonitorAlert, error)
+	CreateAlert(ctx context.Context, monitorID int, userID int, dto *model.DTOMonitorAlertRequest) (*model.MonitorAlert, error)
+	UpdateAlert(ctx context.Context, id int, monitorID int, userID int, dto *model.DTOMonitorAlertRequest) (*model.MonitorAlert, error)
+	DeleteAlert(ctx context.Context, id int, monitorID int, userID int) error
+	TestAlert(ctx context.Context, id int, monitorID int, userID int) (*model.DTOAlertTestResult, error)
+	ListDeliveries(ctx context.Context, monitorID int, userID int, limit int) ([]*model.AlertDelivery, error)
+	Start()
+	Shutdown(ctx context.Context) error
+}
+
+type IMockService interface {
+	CreateMock(ctx context.Context, userID int, dto *model.DTOMockServerRequest) (*model.MockServer, error)
+	ListMocks(ctx context.Context, userID int) ([]*model.MockServer, error)
+	GetMock(ctx context.Context, id string, userID int) (*model.MockServer, error)
+	UpdateMock(ctx context.Context, id string, userID int, dto *model.DTOMockServerRequest) (*model.MockServer, error)
+	DeleteMock(ctx context.Context, id string, userID int) error
+	ListHits(ctx context.Context, id string, userID int, limit int) ([]*model.MockHit, error)
+	ServeMock(ctx context.Context, id string, req *model.DTOMockRequest) (*model.DTOMockResponse, error)
+}
+
+type IRequestBinService interface {
+	CreateBin(ctx context.Context, userID int, dto *model.DTORequestBinRequest) (*model.RequestBin, error)
+	ListBins(ctx context.Context, userID int) ([]*model.RequestBin, error)
+	GetBin(ctx context.Context, id string, userID int) (*model.RequestBin, error)
+	UpdateBin(ctx context.Context, id string, userID int, dto *model.DTORequestBinRequest) (*model.RequestBin, error)
+	DeleteBin(ctx context.Context, id string, userID int) error
+	ListRequests(ctx context.Context, id string, userID int, limit int) ([]*model.CapturedRequest, error)
+	ClearRequests(ctx context.Context, id string, userID int) error
+	Capture(ctx context.Context, id string, req *model.DTOBinCapture) (*model.BinResponse, error)
+	WatchBin(ctx context.Context, id string, userID int, afterID int64, emit func(model.DTOBinEvent) error) error
+	Shutdown()
+}
+
+type ILoadTestService interface {
+	RunLoadTest(ctx context.Context, userID int, dto *model.DTOLoadTestRequest, emit func(model.DTOLoadTestEvent) error) error
+	Shutdown()
+}
+
+type ISpecService interface {
+	ListSpecs(ctx context.Context, userID int) ([]*model.APISpec, error)
+	GetSpec(ctx context.Context, id int, userID int) (*model.APISpec, error)
+	DeleteSpec(ctx context.Context, id int, userID int) error
+}
+
+type IEnvironmentService interface {
+	ListEnvironments(ctx context.Context, userID int) ([]*model.Environment, error)
+	GetEnvironment(ctx context.Context, id int, userID int) (*model.Environment, error)
+	DeleteEnvironment(ctx context.Context, id int, userID int) error
+}
+
+type ISnippetService interface {
+	Generate(ctx context.Context, userID *int, dto *model.DTOSnippetRequest) (*model.DTOSnippetResponse, error)
+}
+
+type IHistoryService interface {
+	ExportHAR(ctx context.Context, userID int, dto *model.DTOHARExportRequest) (*model.HAR, error)
+	ImportHAR(ctx context.Context, userID int, har *model.HAR) (*model.DTOHARImportResponse, error)
+	ExportRunHAR(ctx context.Context, userID int, runID int) (*model.HAR, error)
+}
+
 type IAuthService interface {
 	Register(ctx context.Context, userReg *model.DTOUserRegisterRequest) (*model.User, error)
 	Login(ctx context.Context, userLog *model.DTOLoginRequest) (*model.DTOLoginResponse, error)
 	ValidateToken(ctx context.Context, tokenString string) (*model.Claims, error)
+	IssueTicket(ctx context.Context, userID int) (*model.DTOTicketResponse, error)
+	RedeemTicket(ctx context.Context, ticket string) (*model.Claims, error)
 }
 
 type Service struct {
-	requestService IRequestService
-	authService    IAuthService
+	requestService     IRequestService
+	webSocketService   IWebSocketService
+	grpcService        IGRPCService
+	importService      IImportService
+	snippetService     ISnippetService
+	historyService     IHistoryService
+	collectionService  ICollectionService
+	runService         IRunService
+	monitorService     IMonitorService
+	mockService        IMockService
+	binService         IRequestBinService
+	loadTestService    ILoadTestService
+	environmentService IEnvironmentService
+	specService        ISpecService
+	authService        IAuthService
 }
 
-func NewService(r repository.Repository, jwt config.JWTConfig) *Service {
+func NewService(r repository.Repository, cfg config.Config) *Service {
+	requestService := NewRequestService(r.RequestRepo(), r.APISpecRepo(), cfg.Response)
+	collectionService := NewCollectionService(r.CollectionRepo(), r.EnvironmentRepo(), r.APISpecRepo(), requestService)
 	return &Service{
-		requestService: NewRequestService(r.RequestRepo()),
-		authService:    NewAuthService(r.UserRepo(), jwt),
+		requestService:     requestService,
+		webSocketService:   NewWebSocketService(r.RequestRepo()),
+		grpcService:        NewGRPCService(r.RequestRepo()),
+		importService:      NewImportService(r.CollectionRepo(), r.EnvironmentRepo(), r.APISpecRepo()),
+		snippetService:     NewSnippetService(r.RequestRepo(), collectionService, r.EnvironmentRepo()),
+		historyService:     NewHistoryService(r.RequestRepo(), r.RunRepo()),
+		collectionService:  collectionService,
+		runService:         NewRunService(r.RunRepo(), collectionService, r.CollectionRepo(), r.EnvironmentRepo(), requestService),
+		monitorService:     NewMonitorService(r.MonitorRepo(), r.AlertRepo(), collectionService, r.EnvironmentRepo(), requestService),
+		mockService:        NewMockService(r.MockRepo(), collectionService),
+		binService:         NewRequestBinService(r.BinRepo()),
+		loadTestService:    NewLoadTestService(r.LoadTestRepo(), requestService, cfg.LoadTest),
+		environmentService: NewEnvironmentService(r.EnvironmentRepo()),
+		specService:        NewSpecService(r.APISpecRepo()),
+		authService:        NewAuthService(r.UserRepo(), cfg.JWT),
 	}
 }
 
@@ -35,6 +180,79 @@ func (s *Service) RequestService() IRequestService {
 	return s.requestService
 }
 
+func (s *Service) WebSocketService() IWebSocketService {
+	return s.webSocketService
+}
+
+func (s *Service) GRPCService() IGRPCService {
+	return s.grpcService
+}
+
+func (s *Service) ImportService() IImportService {
+	return s.importService
+}
+
+func (s *Service) SnippetService() ISnippetService {
+	return s.snippetService
+}
+
+func (s *Service) HistoryService() IHistoryService {
+	return s.historyService
+}
+
+func (s *Service) CollectionService() ICollectionService {
+	return s.collectionService
+}
+
+func (s *Service) RunService() IRunService {
+	return s.runService
+}
+
+func (s *Service) MonitorService() IMonitorService {
+	return s.monitorService
+}
+
+func (s *Service) MockService() IMockService {
+	return s.mockService
+}
+
+func (s *Service) RequestBinService() IRequestBinService {
+	return s.binService
+}
+
+func (s *Service) LoadTestService() ILoadTestService {
+	return s.loadTestService
+}
+
+// Start starts the work services do in the background, such as scheduled
+// monitor checks.
+func (s *Service) Start() {
+	s.runService.Start()
+	s.monitorService.Start()
+}
+
+// StopStreams cancels the work streamed to clients, such as request streams,
+// bin streams and load tests, so their connections close before the server
+// is shut down.
+func (s *Service) StopStreams() {
+	s.requestService.StopStreams()
+	s.binService.Shutdown()
+	s.loadTestService.Shutdown()
+}
+
+// Shutdown stops the work services run in the background.
+func (s *Service) Shutdown(ctx context.Context) error {
+	return errors.Join(s.runService.Shutdown(ctx), s.monitorService.Shutdown(ctx))
+}
+
+func (s *Service) EnvironmentService() IEnvironmentService {
+	return s.environmentService
+}
+
+func (s *Service) SpecService() ISpecService {
+	return s.specService
+}
+
 func (s *Service) AuthService() IAuthService {
 	return s.authService
 }
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/suar-net/suar-be/internal/model"
+	"github.com/suar-net/suar-be/internal/repository"
+)
+
+type snippetService struct {
+	repository   repository.IRequestRepository
+	collections  ICollectionService
+	environments repository.IEnvironmentRepository
+}
+
+func NewSnippetService(r repository.IRequestRepository, collections ICollectionService, environments repository.IEnvironmentRepository) ISnippetService {
+	return &snippetService{repository: r, collections: collections, environments: environments}
+}
+
+// Generate returns client code equivalent to a request, in every requested
+// language or in all supported languages when none are given.
+func (s *snippetService) Generate(ctx context.Context, userID *int, dto *model.DTOSnippetRequest) (*model.DTOSnippetResponse, error) {
+	sources := 0
+	for _, set := range []bool{dto.Request != nil, dto.HistoryID != nil, dto.ItemID != nil} {
+		if set {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return nil, fmt.Errorf("%w: exactly one of request, history_id and item_id must be set", ErrInvalidInput)
+	}
+	if dto.EnvironmentID != nil && dto.ItemID == nil {
+		return nil, fmt.Errorf("%w: environment_id can only be used with item_id", ErrInvalidInput)
+	}
+
+	var definition model.DTORequest
+	redactSecrets := dto.RedactSecrets
+	switch {
+	case dto.Request != nil:
+		definition = *dto.Request
+	case userID == nil:
+		return nil, fmt.Errorf("%w: history_id and item_id require authentication", ErrInvalidInput)
+	case dto.HistoryID != nil:
+		entry, err := s.repository.GetByID(ctx, *dto.HistoryID, *userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load history entry: %w", err)
+		}
+		if entry == nil {
+			return nil, ErrHistoryNotFound
+		}
+		definition, err = historyToDTORequest(entry)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		item, err := s.itemRequest(ctx, *userID, dto)
+		if err != nil {
+			return nil, err
+		}
+		// Secrets of a saved request are known from its variables.
+		definition, redactSecrets = *item, false
+	}
+
+	req, err := newSnippetRequest(definition, redactSecrets)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &model.DTOSnippetResponse{Snippets: []model.DTOSnippet{}}
+	for _, generator := range snippetGenerators {
+		if len(dto.Languages) > 0 && !slices.Contains(dto.Languages, generator.language) {
+			continue
+		}
+		response.Snippets = append(response.Snippets, model.DTOSnippet{
+			Language: generator.language,
+			Code:     generator.generate(req),
+		})
+	}
+	return response, nil
+}
+
+// itemRequest builds the request a saved request of a collection sends,
+// with its variables resolved except the secret ones.
+func (s *snippetService) itemRequest(ctx context.Context, userID int, dto *model.DTOSnippetRequest) (*model.DTORequest, error) {
+	collection, err := s.collections.GetCollection(ctx, *dto.CollectionID, userID)
+	if err != nil {
+		return nil, err
+	}
+	path := findItemPath(collection.Items, *dto.ItemID)
+	if path == nil || path[len(path)-1].Type != model.CollectionItemRequest || path[len(path)-1].Request == nil {
+		return nil, ErrItemNotFound
+	}
+
+	var environment *model.Environment
+	if dto.EnvironmentID != nil {
+		environment, err = s.environments.GetByID(ctx, *dto.EnvironmentID, userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load environment: %w", err)
+		}
+		if environment == nil {
+			return nil, ErrEnvironmentNotFound
+		}
+	}
+
+	runner := &collectionRunner{collection: collection, environment: environment, variables: newRunVariables(collection, environment)}
+	runner.variables.placeholdSecrets = true
+	request, _ := buildRunRequest(path[len(path)-1].Request, runner.auth(path), runner.variables)
+	return request, nil
+}
+
+// historyToDTORequest rebuilds the request definition of an HTTP history entry.
+func historyToDTORequest(entry *model.Request) (model.DTORequest, error) {
+	if entry.RequestType != "" && entry.RequestType != model.RequestTypeHTTP {
+		return model.DTORequest{}, fmt.Errorf("%w: code snippets are only available for http requests, not %s", ErrInvalidInput, entry.RequestType)
+	}
+
+	dto := model.DTORequest{
+		Method: entry.RequestMethod,
+		URL:    entry.RequestURL,
+	}
+	if len(entry.RequestHeaders) > 0 && string(entry.RequestHeaders) != "null" {
+		if err := json.Unmarshal(entry.RequestHeaders, &dto.Headers); err != nil {
+			return model.DTORequest{}, fmt.Errorf("invalid request headers in history entry %d: %w", entry.ID, err)
+		}
+	}
+	if entry.RequestBody != nil && *entry.RequestBody != "" {
+		if json.Valid([]byte(*entry.RequestBody)) {
+			dto.Body = json.RawMessage(*entry.RequestBody)
+		} else {
+			dto.BodyText = *entry.RequestBody
+		}
+	}
+	return dto, nil
+}
@@ -0,0 +1,383 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/suar-net/suar-be/internal/model"
+)
+
+// snippetBodyFile is the file name binary bodies are read from in generated
+// code, since they cannot be written as a string literal.
+const snippetBodyFile = "body.bin"
+
+type snippetHeader struct {
+	name  string
+	value string
+}
+
+// snippetRequest is a request reduced to what every generator needs.
+// Headers keep one entry per value, sorted by name.
+type snippetRequest struct {
+	method  string
+	url     string
+	headers []snippetHeader
+	body    string
+	binary  bool
+}
+
+// joinedHeaders returns headers with repeated names combined into one
+// comma separated value, for clients that take headers as a map.
+func (r snippetRequest) joinedHeaders() []snippetHeader {
+	var joined []snippetHeader
+	for _, header := range r.headers {
+		if n := len(joined); n > 0 && strings.EqualFold(joined[n-1].name, header.name) {
+			joined[n-1].value += ", " + header.value
+			continue
+		}
+		joined = append(joined, header)
+	}
+	return joined
+}
+
+var snippetGenerators = []struct {
+	language string
+	generate func(snippetRequest) string
+}{
+	{model.SnippetCurl, curlSnippet},
+	{model.SnippetGo, goSnippet},
+	{model.SnippetPython, pythonSnippet},
+	{model.SnippetFetch, fetchSnippet},
+	{model.SnippetAxios, axiosSnippet},
+	{model.SnippetHTTPie, httpieSnippet},
+	{model.SnippetPowerShell, powerShellSnippet},
+}
+
+// newSnippetRequest prepares a request definition for code generation. The
+// request is not validated against the SSRF policy since it is not sent.
+func newSnippetRequest(dto model.DTORequest, redactSecrets bool) (snippetRequest, error) {
+	headers := make(map[string][]string, len(dto.Headers))
+	for key, values := range dto.Headers {
+		headers[key] = values
+	}
+	dto.Headers = headers
+	dto.Method = strings.ToUpper(dto.Method)
+	if dto.Method == "" {
+		dto.Method = http.MethodGet
+	}
+
+	if dto.GraphQL != nil {
+		if err := applyGraphQLBody(&dto); err != nil {
+			return snippetRequest{}, err
+		}
+	}
+	body, err := requestBody(&dto)
+	if err != nil {
+		return snippetRequest{}, err
+	}
+	if dto.URL == "" {
+		return snippetRequest{}, fmt.Errorf("%w: URL cannot be empty", ErrInvalidInput)
+	}
+	parsedURL, err := url.Parse(dto.URL)
+	if err != nil {
+		return snippetRequest{}, fmt.Errorf("%w: failed to parse URL: %v", ErrInvalidInput, err)
+	}
+
+	req := snippetRequest{
+		method: dto.Method,
+		url:    parsedURL.String(),
+		body:   string(body),
+		binary: !utf8.Valid(body),
+	}
+	if req.binary {
+		req.body = ""
+	}
+
+	names := make([]string, 0, len(dto.Headers))
+	for name := range dto.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range dto.Headers[name] {
+			if redactSecrets && isSecretName(name) {
+				value = redactHeaderValue(name, value)
+			}
+			req.headers = append(req.headers, snippetHeader{name: name, value: value})
+		}
+	}
+
+	if redactSecrets && parsedURL.RawQuery != "" {
+		req.url = redactQuery(parsedURL)
+	}
+	return req, nil
+}
+
+var secretNameParts = []string{"auth", "token", "secret", "password", "passwd", "api-key", "apikey", "api_key", "cookie", "session", "signature", "credential"}
+
+func isSecretName(name string) bool {
+	lower := strings.ToLower(name)
+	for _, part := range secretNameParts {
+		if strings.Contains(lower, part) {
+			return true
+		}
+	}
+	return lower == "key" || lower == "sig"
+}
+
+func placeholderName(name string) string {
+	return "{{" + strings.ToUpper(strings.NewReplacer("-", "_", ".", "_", " ", "_").Replace(name)) + "}}"
+}
+
+// redactHeaderValue keeps the scheme of an Authorization style value, so
+// "Bearer abc" becomes "Bearer {{AUTHORIZATION}}".
+func redactHeaderValue(name, value string) string {
+	if scheme, _, ok := strings.Cut(value, " "); ok {
+		switch strings.ToLower(scheme) {
+		case "bearer", "basic", "token", "digest", "apikey":
+			return scheme + " " + placeholderName(name)
+		}
+	}
+	return placeholderName(name)
+}
+
+// redactQuery replaces secret query parameter values while keeping the order
+// and encoding of every other parameter.
+func redactQuery(parsedURL *url.URL) string {
+	pairs := strings.Split(parsedURL.RawQuery, "&")
+	for i, pair := range pairs {
+		key, _, ok := strings.Cut(pair, "=")
+		name, err := url.QueryUnescape(key)
+		if ok && err == nil && isSecretName(name) {
+			pairs[i] = key + "=" + placeholderName(name)
+		}
+	}
+	redacted := *parsedURL
+	redacted.RawQuery = ""
+	return redacted.String() + "?" + strings.Join(pairs, "&")
+}
+
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./:=@,+") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// jsString quotes s as a JavaScript string literal.
+func jsString(s string) string {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// goString quotes s as a Go string literal, preferring a raw string for
+// readable multi-line bodies.
+func goString(s string) string {
+	if strings.Contains(s, "\n") && !strings.ContainsAny(s, "`\r") {
+		return "`" + s + "`"
+	}
+	return strconv.Quote(s)
+}
+
+// pyString quotes s as a Python string literal. Go's escapes are a subset of
+// the ones Python understands.
+func pyString(s string) string {
+	return strconv.Quote(s)
+}
+
+func powerShellString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func curlSnippet(req snippetRequest) string {
+	var b strings.Builder
+	b.WriteString("curl")
+	if req.method != http.MethodGet || req.body != "" || req.binary {
+		if req.method == http.MethodHead {
+			b.WriteString(" --head")
+		} else {
+			b.WriteString(" --request " + req.method)
+		}
+	}
+	b.WriteString(" \\\n  --url " + shellQuote(req.url))
+	for _, header := range req.headers {
+		b.WriteString(" \\\n  --header " + shellQuote(header.name+": "+header.value))
+	}
+	switch {
+	case req.binary:
+		b.WriteString(" \\\n  --data-binary @" + snippetBodyFile)
+	case req.body != "":
+		b.WriteString(" \\\n  --data-raw " + shellQuote(req.body))
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+func goSnippet(req snippetRequest) string {
+	var b strings.Builder
+	b.WriteString("package main\n\nimport (\n\t\"fmt\"\n\t\"io\"\n\t\"net/http\"\n")
+	if req.binary {
+		b.WriteString("\t\"os\"\n")
+	} else if req.body != "" {
+		b.WriteString("\t\"strings\"\n")
+	}
+	b.WriteString(")\n\nfunc main() {\n")
+
+	bodyArg := "nil"
+	switch {
+	case req.binary:
+		fmt.Fprintf(&b, "\tbody, err := os.Open(%q)\n\tif err != nil {\n\t\tpanic(err)\n\t}\n\tdefer body.Close()\n\n", snippetBodyFile)
+		bodyArg = "body"
+	case req.body != "":
+		fmt.Fprintf(&b, "\tbody := strings.NewReader(%s)\n\n", goString(req.body))
+		bodyArg = "body"
+	}
+
+	fmt.Fprintf(&b, "\treq, err := http.NewRequest(%q, %q, %s)\n\tif err != nil {\n\t\tpanic(err)\n\t}\n", req.method, req.url, bodyArg)
+	for _, header := range req.headers {
+		fmt.Fprintf(&b, "\treq.Header.Add(%q, %q)\n", header.name, header.value)
+	}
+	b.WriteString("\n\tresp, err := http.DefaultClient.Do(req)\n\tif err != nil {\n\t\tpanic(err)\n\t}\n\tdefer resp.Body.Close()\n\n")
+	b.WriteString("\trespBody, err := io.ReadAll(resp.Body)\n\tif err != nil {\n\t\tpanic(err)\n\t}\n\n")
+	b.WriteString("\tfmt.Println(resp.Status)\n\tfmt.Println(string(respBody))\n}\n")
+	return b.String()
+}
+
+func pythonSnippet(req snippetRequest) string {
+	var b strings.Builder
+	b.WriteString("import requests\n\n")
+	fmt.Fprintf(&b, "url = %s\n", pyString(req.url))
+
+	args := ""
+	if headers := req.joinedHeaders(); len(headers) > 0 {
+		b.WriteString("headers = {\n")
+		for _, header := range headers {
+			fmt.Fprintf(&b, "    %s: %s,\n", pyString(header.name), pyString(header.value))
+		}
+		b.WriteString("}\n")
+		args += ", headers=headers"
+	}
+	switch {
+	case req.binary:
+		fmt.Fprintf(&b, "\nwith open(%s, \"rb\") as f:\n    data = f.read()\n", pyString(snippetBodyFile))
+		args += ", data=data"
+	case req.body != "":
+		fmt.Fprintf(&b, "data = %s\n", pyString(req.body))
+		args += ", data=data"
+	}
+
+	fmt.Fprintf(&b, "\nresponse = requests.request(%s, url%s)\n\n", pyString(req.method), args)
+	b.WriteString("print(response.status_code)\nprint(response.text)\n")
+	return b.String()
+}
+
+// jsRequestOptions writes the headers and body properties shared by the
+// fetch and axios snippets.
+func jsRequestOptions(b *strings.Builder, req snippetRequest, bodyKey string) {
+	if headers := req.joinedHeaders(); len(headers) > 0 {
+		b.WriteString("  headers: {\n")
+		for _, header := range headers {
+			fmt.Fprintf(b, "    %s: %s,\n", jsString(header.name), jsString(header.value))
+		}
+		b.WriteString("  },\n")
+	}
+	switch {
+	case req.binary:
+		fmt.Fprintf(b, "  %s: fs.readFileSync(%s),\n", bodyKey, jsString(snippetBodyFile))
+	case req.body != "":
+		fmt.Fprintf(b, "  %s: %s,\n", bodyKey, jsString(req.body))
+	}
+}
+
+func fetchSnippet(req snippetRequest) string {
+	var b strings.Builder
+	if req.binary {
+		b.WriteString("const fs = require(\"fs\");\n\n")
+	}
+	fmt.Fprintf(&b, "const response = await fetch(%s, {\n", jsString(req.url))
+	fmt.Fprintf(&b, "  method: %s,\n", jsString(req.method))
+	jsRequestOptions(&b, req, "body")
+	b.WriteString("});\n\nconsole.log(response.status);\nconsole.log(await response.text());\n")
+	return b.String()
+}
+
+func axiosSnippet(req snippetRequest) string {
+	var b strings.Builder
+	b.WriteString("const axios = require(\"axios\");\n")
+	if req.binary {
+		b.WriteString("const fs = require(\"fs\");\n")
+	}
+	b.WriteString("\naxios.request({\n")
+	fmt.Fprintf(&b, "  method: %s,\n", jsString(strings.ToLower(req.method)))
+	fmt.Fprintf(&b, "  url: %s,\n", jsString(req.url))
+	jsRequestOptions(&b, req, "data")
+	b.WriteString("  // Return every status instead of throwing on 4xx and 5xx.\n  validateStatus: () => true,\n")
+	b.WriteString("}).then((response) => {\n  console.log(response.status);\n  console.log(response.data);\n}).catch((error) => {\n  console.error(error);\n});\n")
+	return b.String()
+}
+
+func httpieSnippet(req snippetRequest) string {
+	var b strings.Builder
+	b.WriteString("http")
+	if req.body != "" {
+		b.WriteString(" --raw " + shellQuote(req.body))
+	}
+	b.WriteString(" " + req.method + " " + shellQuote(req.url))
+	for _, header := range req.headers {
+		b.WriteString(" \\\n  " + shellQuote(header.name+":"+header.value))
+	}
+	if req.binary {
+		b.WriteString(" \\\n  < " + snippetBodyFile)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+func powerShellSnippet(req snippetRequest) string {
+	var b strings.Builder
+	args := ""
+
+	// Content-Type has its own parameter, Invoke-WebRequest rejects it in
+	// the headers table on older versions.
+	var contentType string
+	var headers []snippetHeader
+	for _, header := range req.joinedHeaders() {
+		if strings.EqualFold(header.name, "Content-Type") {
+			contentType = header.value
+			continue
+		}
+		headers = append(headers, header)
+	}
+	if len(headers) > 0 {
+		b.WriteString("$headers = @{\n")
+		for _, header := range headers {
+			fmt.Fprintf(&b, "    %s = %s\n", powerShellString(header.name), powerShellString(header.value))
+		}
+		b.WriteString("}\n")
+		args += " -Headers $headers"
+	}
+	if contentType != "" {
+		args += " -ContentType " + powerShellString(contentType)
+	}
+	switch {
+	case req.binary:
+		args += " -InFile " + powerShellString(snippetBodyFile)
+	case req.body != "":
+		fmt.Fprintf(&b, "$body = %s\n", powerShellString(req.body))
+		args += " -Body $body"
+	}
+
+	fmt.Fprintf(&b, "$response = Invoke-WebRequest -Uri %s -Method %s%s\n", powerShellString(req.url), powerShellString(req.method), args)
+	b.WriteString("$response.StatusCode\n$response.Content\n")
+	return b.String()
+}
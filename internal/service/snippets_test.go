@@ -0,0 +1,289 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/suar-net/suar-be/internal/model"
+	"github.com/suar-net/suar-be/internal/repository"
+)
+
+func TestNewSnippetRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		dto     model.DTORequest
+		redact  bool
+		want    snippetRequest
+		wantErr bool
+	}{
+		{
+			name: "defaults and sorted headers",
+			dto:  model.DTORequest{URL: "https://a.test", Headers: map[string][]string{"X-B": {"1", "2"}, "Accept": {"*/*"}}},
+			want: snippetRequest{method: "GET", url: "https://a.test", headers: []snippetHeader{{"Accept", "*/*"}, {"X-B", "1"}, {"X-B", "2"}}},
+		},
+		{
+			name:   "secrets redacted",
+			dto:    model.DTORequest{Method: "post", URL: "https://a.test/?api_key=abc&q=1", Headers: map[string][]string{"Authorization": {"Bearer abc"}, "X-Session": {"s"}}},
+			redact: true,
+			want: snippetRequest{method: "POST", url: "https://a.test/?api_key={{API_KEY}}&q=1",
+				headers: []snippetHeader{{"Authorization", "Bearer {{AUTHORIZATION}}"}, {"X-Session", "{{X_SESSION}}"}}},
+		},
+		{
+			name: "secrets kept without redaction",
+			dto:  model.DTORequest{URL: "https://a.test/?token=abc", Headers: map[string][]string{"Authorization": {"Bearer abc"}}},
+			want: snippetRequest{method: "GET", url: "https://a.test/?token=abc", headers: []snippetHeader{{"Authorization", "Bearer abc"}}},
+		},
+		{
+			name: "binary body",
+			dto:  model.DTORequest{Method: "PUT", URL: "https://a.test", BodyBase64: "//4="},
+			want: snippetRequest{method: "PUT", url: "https://a.test", binary: true},
+		},
+		{
+			name: "graphql",
+			dto:  model.DTORequest{Method: "POST", URL: "https://a.test", GraphQL: &model.DTOGraphQLBody{Query: "{ a }"}},
+			want: snippetRequest{method: "POST", url: "https://a.test", body: `{"query":"{ a }"}`, headers: []snippetHeader{{"Content-Type", "application/json"}}},
+		},
+		{name: "missing URL", dto: model.DTORequest{Method: "GET"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newSnippetRequest(tt.dto, tt.redact)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSnippetQuoting(t *testing.T) {
+	tests := []struct {
+		name  string
+		quote func(string) string
+		in    string
+		want  string
+	}{
+		{"shell plain", shellQuote, "https://a.test/x", "https://a.test/x"},
+		{"shell empty", shellQuote, "", "''"},
+		{"shell quote", shellQuote, "it's", `'it'\''s'`},
+		{"js", jsString, "<a href=\"x\">\n", `"<a href=\"x\">\n"`},
+		{"go raw", goString, "a\nb", "`a\nb`"},
+		{"go backtick", goString, "a`\nb", `"a` + "`" + `\nb"`},
+		{"powershell", powerShellString, "it's", "'it''s'"},
+	}
+	for _, tt := range tests {
+		if got := tt.quote(tt.in); got != tt.want {
+			t.Errorf("%s: quote(%q) = %s, want %s", tt.name, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestJoinedHeaders(t *testing.T) {
+	req := snippetRequest{headers: []snippetHeader{{"Accept", "a"}, {"X-B", "1"}, {"x-b", "2"}}}
+	want := []snippetHeader{{"Accept", "a"}, {"X-B", "1, 2"}}
+	if got := req.joinedHeaders(); !reflect.DeepEqual(got, want) {
+		t.Errorf("joinedHeaders = %v, want %v", got, want)
+	}
+}
+
+func TestSnippetGenerators(t *testing.T) {
+	req := snippetRequest{
+		method:  "POST",
+		url:     "https://a.test/items?x=1",
+		headers: []snippetHeader{{"Content-Type", "application/json"}, {"X-Id", "it's"}},
+		body:    "{\"name\": \"a\"}",
+	}
+	binary := snippetRequest{method: "PUT", url: "https://a.test/upload", binary: true}
+
+	tests := []struct {
+		language string
+		want     []string
+		binary   []string
+	}{
+		{model.SnippetCurl, []string{"--request POST", "--url 'https://a.test/items?x=1'", `--header 'X-Id: it'\''s'`, `--data-raw '{"name": "a"}'`}, []string{"--data-binary @body.bin"}},
+		{model.SnippetGo, []string{`http.NewRequest("POST", "https://a.test/items?x=1", body)`, `req.Header.Add("X-Id", "it's")`}, []string{`os.Open("body.bin")`}},
+		{model.SnippetPython, []string{`"X-Id": "it's"`, `requests.request("POST", url, headers=headers, data=data)`}, []string{`open("body.bin", "rb")`}},
+		{model.SnippetFetch, []string{`await fetch("https://a.test/items?x=1"`, `body: "{\"name\": \"a\"}"`}, []string{`fs.readFileSync("body.bin")`}},
+		{model.SnippetAxios, []string{`method: "post"`, `data: "{\"name\": \"a\"}"`}, []string{`data: fs.readFileSync("body.bin")`}},
+		{model.SnippetHTTPie, []string{`http --raw '{"name": "a"}' POST`, `'X-Id:it'\''s'`}, []string{"< body.bin"}},
+		{model.SnippetPowerShell, []string{"-ContentType 'application/json'", `'X-Id' = 'it''s'`, "-Body $body"}, []string{"-InFile 'body.bin'"}},
+	}
+	generators := map[string]func(snippetRequest) string{}
+	for _, generator := range snippetGenerators {
+		generators[generator.language] = generator.generate
+	}
+	for _, tt := range tests {
+		generate := generators[tt.language]
+		for _, c := range []struct {
+			req  snippetRequest
+			want []string
+		}{{req, tt.want}, {binary, tt.binary}} {
+			code := generate(c.req)
+			for _, want := range c.want {
+				if !strings.Contains(code, want) {
+					t.Errorf("%s snippet does not contain %s:\n%s", tt.language, want, code)
+				}
+			}
+			if tt.language == model.SnippetGo {
+				if _, err := parser.ParseFile(token.NewFileSet(), "main.go", code, 0); err != nil {
+					t.Errorf("go snippet does not parse: %v\n%s", err, code)
+				}
+			}
+		}
+	}
+}
+
+// TestCurlSnippetRoundTrip imports the generated cURL command again, which
+// must give back the original request.
+func TestCurlSnippetRoundTrip(t *testing.T) {
+	dto := model.DTORequest{
+		Method:   "PATCH",
+		URL:      "https://a.test/items/1?q=a%20b",
+		Headers:  map[string][]string{"Content-Type": {"application/json"}, "X-Note": {"it's \"quoted\""}},
+		BodyText: "{\n  \"a\": \"<&>\"\n}",
+	}
+	req, err := newSnippetRequest(dto, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported, err := parseCurlCommand(curlSnippet(req))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := imported.Request
+	if got.Method != dto.Method || got.URL != dto.URL || got.BodyText != dto.BodyText || !reflect.DeepEqual(got.Headers, dto.Headers) {
+		t.Errorf("round trip = %+v, want %+v", got, dto)
+	}
+}
+
+func TestHistoryToDTORequest(t *testing.T) {
+	jsonBody, textBody := `{"a":1}`, "a=1"
+	tests := []struct {
+		name    string
+		entry   model.Request
+		want    model.DTORequest
+		wantErr bool
+	}{
+		{"json body", model.Request{RequestMethod: "POST", RequestURL: "https://a.test", RequestHeaders: []byte(`{"A":["1"]}`), RequestBody: &jsonBody},
+			model.DTORequest{Method: "POST", URL: "https://a.test", Headers: map[string][]string{"A": {"1"}}, Body: []byte(jsonBody)}, false},
+		{"text body", model.Request{RequestMethod: "POST", RequestURL: "https://a.test", RequestBody: &textBody},
+			model.DTORequest{Method: "POST", URL: "https://a.test", BodyText: textBody}, false},
+		{"websocket", model.Request{RequestType: model.RequestTypeWebSocket}, model.DTORequest{}, true},
+	}
+	for _, tt := range tests {
+		got, err := historyToDTORequest(&tt.entry)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGenerateSnippets(t *testing.T) {
+	s := NewSnippetService(nil, nil, nil)
+	request := &model.DTORequest{Method: "GET", URL: "https://a.test"}
+
+	got, err := s.Generate(context.Background(), nil, &model.DTOSnippetRequest{Request: request, Languages: []string{"python", "curl"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Snippets) != 2 || got.Snippets[0].Language != model.SnippetCurl || got.Snippets[1].Language != model.SnippetPython {
+		t.Errorf("snippets = %+v, want curl and python in generator order", got.Snippets)
+	}
+	all, _ := s.Generate(context.Background(), nil, &model.DTOSnippetRequest{Request: request})
+	if len(all.Snippets) != len(snippetGenerators) {
+		t.Errorf("got %d snippets, want every language", len(all.Snippets))
+	}
+
+	historyID := 1
+	for _, dto := range []*model.DTOSnippetRequest{{}, {Request: request, HistoryID: &historyID}, {HistoryID: &historyID}} {
+		if _, err := s.Generate(context.Background(), nil, dto); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("Generate(%+v) = %v, want ErrInvalidInput", dto, err)
+		}
+	}
+}
+
+// fakeEnvironmentRepo serves one environment of user 1.
+type fakeEnvironmentRepo struct {
+	repository.IEnvironmentRepository
+	environment *model.Environment
+}
+
+func (r *fakeEnvironmentRepo) GetByID(ctx context.Context, id int, userID int) (*model.Environment, error) {
+	if id != r.environment.ID || userID != 1 {
+		return nil, nil
+	}
+	return r.environment, nil
+}
+
+func TestGenerateItemSnippet(t *testing.T) {
+	collection := &model.Collection{
+		ID:        1,
+		Variables: []model.Variable{{Key: "token", Value: "s3cr3t", Secret: true}, {Key: "version", Value: "v1"}},
+		Auth:      &model.RequestAuth{Type: model.AuthBearer, Token: "{{token}}"},
+		Items: []*model.CollectionItem{{ID: 2, Type: model.CollectionItemRequest, Name: "orders", Request: &model.CollectionRequest{
+			Method:  "POST",
+			URL:     "https://{{host}}/{{version}}/orders",
+			Headers: []model.KeyValue{{Key: "X-Signature", Value: "{{signature}}"}, {Key: "Authorization-Hint", Value: "plain"}},
+			Body:    &model.RequestBody{Mode: model.BodyModeURLEncoded, URLEncoded: []model.KeyValue{{Key: "pin", Value: "{{pin}} x"}}},
+		}}},
+	}
+	environment := &model.Environment{ID: 3, Variables: []model.Variable{
+		{Key: "host", Value: "api.test"},
+		{Key: "signature", Value: "abc", Secret: true},
+		{Key: "pin", Value: "1234", Secret: true},
+	}}
+	s := NewSnippetService(nil, &fakeCollectionService{collection: collection}, &fakeEnvironmentRepo{environment: environment})
+	userID, collectionID, itemID, environmentID := 1, 1, 2, 3
+
+	got, err := s.Generate(context.Background(), &userID, &model.DTOSnippetRequest{
+		CollectionID: &collectionID, ItemID: &itemID, EnvironmentID: &environmentID,
+		Languages: []string{model.SnippetCurl}, RedactSecrets: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := got.Snippets[0].Code
+	for _, want := range []string{"https://api.test/v1/orders", "Bearer {{token}}", "X-Signature: {{signature}}", "Authorization-Hint: plain", "pin={{pin}}+x"} {
+		if !strings.Contains(code, want) {
+			t.Errorf("snippet lacks %q:\n%s", want, code)
+		}
+	}
+	for _, secret := range []string{"s3cr3t", "abc", "1234"} {
+		if strings.Contains(code, secret) {
+			t.Errorf("snippet holds the secret %q:\n%s", secret, code)
+		}
+	}
+
+	missing := 9
+	for _, dto := range []*model.DTOSnippetRequest{
+		{CollectionID: &collectionID, ItemID: &missing},
+		{CollectionID: &missing, ItemID: &itemID},
+		{CollectionID: &collectionID, ItemID: &itemID, EnvironmentID: &missing},
+	} {
+		if _, err := s.Generate(context.Background(), &userID, dto); err == nil || errors.Is(err, ErrInvalidInput) {
+			t.Errorf("Generate(%+v) = %v, want a not found error", dto, err)
+		}
+	}
+	for _, dto := range []*model.DTOSnippetRequest{
+		{CollectionID: &collectionID, ItemID: &itemID, Request: &model.DTORequest{URL: "https://a.test"}},
+		{Request: &model.DTORequest{URL: "https://a.test"}, EnvironmentID: &environmentID},
+	} {
+		if _, err := s.Generate(context.Background(), &userID, dto); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("Generate(%+v) = %v, want ErrInvalidInput", dto, err)
+		}
+	}
+	if _, err := s.Generate(context.Background(), nil, &model.DTOSnippetRequest{CollectionID: &collectionID, ItemID: &itemID}); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("anonymous item snippet: err = %v", err)
+	}
+}
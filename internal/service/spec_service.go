@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/suar-net/suar-be/internal/model"
+	"github.com/suar-net/suar-be/internal/repository"
+)
+
+// specService manages stored OpenAPI documents. Specs are created by the
+// OpenAPI import and referenced by request contracts.
+type specService struct {
+	repository repository.IAPISpecRepository
+}
+
+func NewSpecService(r repository.IAPISpecRepository) ISpecService {
+	return &specService{repository: r}
+}
+
+// ListSpecs returns the specs of a user without their documents.
+func (s *specService) ListSpecs(ctx context.Context, userID int) ([]*model.APISpec, error) {
+	specs, err := s.repository.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list specs: %w", err)
+	}
+	if specs == nil {
+		specs = []*model.APISpec{}
+	}
+	return specs, nil
+}
+
+func (s *specService) GetSpec(ctx context.Context, id int, userID int) (*model.APISpec, error) {
+	spec, err := s.repository.GetByID(ctx, id, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load spec: %w", err)
+	}
+	if spec == nil {
+		return nil, ErrSpecNotFound
+	}
+	return spec, nil
+}
+
+// DeleteSpec removes a spec. Contracts that still reference it fail with
+// ErrSpecNotFound when used.
+func (s *specService) DeleteSpec(ctx context.Context, id int, userID int) error {
+	deleted, err := s.repository.Delete(ctx, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete spec: %w", err)
+	}
+	if !deleted {
+		return ErrSpecNotFound
+	}
+	return nil
+}
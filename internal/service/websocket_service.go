@@ -0,0 +1,267 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/websocket"
+	"github.com/suar-net/suar-be/internal/model"
+	"github.com/suar-net/suar-be/internal/repository"
+)
+
+const (
+	maxWebSocketSessionDuration = 30 * time.Minute
+	maxWebSocketMessageSize     = 1024 * 1024
+	maxTranscriptMessages       = 1000
+	maxTranscriptBytes          = 4 * 1024 * 1024
+	webSocketHandshakeTimeout   = 10 * time.Second
+)
+
+// WebSocketSession is a connection to a target WebSocket server that is
+// waiting to be paired with the client connection.
+type WebSocketSession struct {
+	Target          *websocket.Conn
+	URL             *url.URL
+	RequestHeaders  http.Header
+	ResponseHeaders http.Header
+	Subprotocol     string
+	OpenedAt        time.Time
+}
+
+type webSocketService struct {
+	repository repository.IRequestRepository
+	dialer     *websocket.Dialer
+}
+
+func NewWebSocketService(r repository.IRequestRepository) IWebSocketService {
+	return &webSocketService{
+		repository: r,
+		dialer: &websocket.Dialer{
+			Proxy:            http.ProxyFromEnvironment,
+			HandshakeTimeout: webSocketHandshakeTimeout,
+		},
+	}
+}
+
+// Dial validates the target against the same SSRF policy as HTTP requests
+// and opens the connection to it.
+func (s *webSocketService) Dial(ctx context.Context, dto *model.DTOWebSocketRequest) (*WebSocketSession, error) {
+	if dto.URL == "" {
+		return nil, fmt.Errorf("%w: URL cannot be empty", ErrInvalidInput)
+	}
+	parsedURL, err := url.Parse(dto.URL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to parse URL: %v", ErrInvalidInput, err)
+	}
+	if parsedURL.Scheme != "ws" && parsedURL.Scheme != "wss" {
+		return nil, fmt.Errorf("%w: invalid URL scheme: %s. Only 'ws' and 'wss' are allowed", ErrInvalidInput, parsedURL.Scheme)
+	}
+	if err := checkEgress(parsedURL.Hostname()); err != nil {
+		return nil, err
+	}
+
+	headers := make(http.Header)
+	for key, values := range dto.Headers {
+		if !blockedHeaders[http.CanonicalHeaderKey(key)] {
+			headers[key] = values
+		}
+	}
+
+	dialer := *s.dialer
+	dialer.Subprotocols = dto.Subprotocols
+
+	target, resp, err := dialer.DialContext(ctx, parsedURL.String(), headers)
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("%w: websocket handshake failed with status %d", ErrUpstreamFailed, resp.StatusCode)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrUpstreamFailed, err)
+	}
+	target.SetReadLimit(maxWebSocketMessageSize)
+
+	return &WebSocketSession{
+		Target:          target,
+		URL:             parsedURL,
+		RequestHeaders:  headers,
+		ResponseHeaders: resp.Header,
+		Subprotocol:     target.Subprotocol(),
+		OpenedAt:        time.Now(),
+	}, nil
+}
+
+// transcriptRecorder collects relayed messages from both relay goroutines.
+// Messages past maxTranscriptMessages, or whose payload would take the
+// transcript past maxTranscriptBytes, are only counted as dropped.
+type transcriptRecorder struct {
+	mu              sync.Mutex
+	transcript      model.WebSocketTranscript
+	transcriptBytes int
+	receivedBytes   int64
+}
+
+func (t *transcriptRecorder) record(direction string, messageType int, data []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if direction == "received" {
+		t.receivedBytes += int64(len(data))
+	}
+	if len(t.transcript.Messages) >= maxTranscriptMessages || t.transcriptBytes+len(data) > maxTranscriptBytes {
+		t.transcript.MessagesDropped++
+		return
+	}
+	t.transcriptBytes += len(data)
+
+	message := model.WebSocketMessage{
+		Direction: direction,
+		Type:      "text",
+		Size:      len(data),
+		Timestamp: time.Now(),
+	}
+	if messageType == websocket.BinaryMessage || !utf8.Valid(data) {
+		message.Type = "binary"
+		message.DataBase64 = base64.StdEncoding.EncodeToString(data)
+	} else {
+		message.Data = string(data)
+	}
+	t.transcript.Messages = append(t.transcript.Messages, message)
+}
+
+func (t *transcriptRecorder) close(closedBy string, code int, reason string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Only the first side to close the session is recorded.
+	if t.transcript.ClosedBy != "" {
+		return
+	}
+	t.transcript.ClosedBy = closedBy
+	t.transcript.CloseCode = code
+	t.transcript.CloseReason = reason
+}
+
+// Relay copies messages between the client and the target until either side
+// closes, then stores the session transcript as a websocket history entry for
+// authenticated users. It closes both connections before returning.
+func (s *webSocketService) Relay(ctx context.Context, userID *int, session *WebSocketSession, client *websocket.Conn) error {
+	client.SetReadLimit(maxWebSocketMessageSize)
+
+	recorder := &transcriptRecorder{}
+	recorder.transcript.Subprotocol = session.Subprotocol
+	recorder.transcript.OpenedAt = session.OpenedAt
+
+	sessionCtx, cancel := context.WithTimeout(ctx, maxWebSocketSessionDuration)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		defer cancel()
+		pipeWebSocket(client, session.Target, "sent", "client", recorder)
+	}()
+	go func() {
+		defer wg.Done()
+		defer cancel()
+		pipeWebSocket(session.Target, client, "received", "target", recorder)
+	}()
+
+	<-sessionCtx.Done()
+	if errors.Is(sessionCtx.Err(), context.DeadlineExceeded) {
+		recorder.close("server", websocket.CloseGoingAway, "session duration limit reached")
+	} else {
+		recorder.close("server", websocket.CloseGoingAway, "session ended")
+	}
+
+	// Give both sides a chance to see a close frame before the sockets go away.
+	deadline := time.Now().Add(time.Second)
+	closeMessage := websocket.FormatCloseMessage(websocket.CloseGoingAway, "")
+	client.WriteControl(websocket.CloseMessage, closeMessage, deadline)
+	session.Target.WriteControl(websocket.CloseMessage, closeMessage, deadline)
+	client.Close()
+	session.Target.Close()
+	wg.Wait()
+
+	recorder.transcript.ClosedAt = time.Now()
+	if userID == nil {
+		return nil
+	}
+
+	// The request context is already done once the client disconnects, so the
+	// history entry is written with a fresh one.
+	saveCtx, saveCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer saveCancel()
+	return s.saveHistory(saveCtx, userID, session, recorder)
+}
+
+// pipeWebSocket forwards messages from src to dst until src closes or a write
+// fails. A close frame from src is passed on to dst with the same code.
+func pipeWebSocket(src, dst *websocket.Conn, direction, side string, recorder *transcriptRecorder) {
+	for {
+		messageType, data, err := src.ReadMessage()
+		if err != nil {
+			var closeErr *websocket.CloseError
+			if errors.As(err, &closeErr) {
+				recorder.close(side, closeErr.Code, closeErr.Text)
+				code := closeErr.Code
+				// 1006 and 1015 describe a broken connection and may not be
+				// sent in a close frame, FormatCloseMessage turns 1005 into
+				// an empty payload.
+				if code == websocket.CloseAbnormalClosure || code == websocket.CloseTLSHandshake {
+					code = websocket.CloseNoStatusReceived
+				}
+				dst.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(code, closeErr.Text),
+					time.Now().Add(time.Second))
+			} else {
+				recorder.close(side, websocket.CloseAbnormalClosure, err.Error())
+			}
+			return
+		}
+
+		recorder.record(direction, messageType, data)
+		if err := dst.WriteMessage(messageType, data); err != nil {
+			return
+		}
+	}
+}
+
+func (s *webSocketService) saveHistory(ctx context.Context, userID *int, session *WebSocketSession, recorder *transcriptRecorder) error {
+	transcript, err := json.Marshal(recorder.transcript)
+	if err != nil {
+		return fmt.Errorf("failed to encode websocket transcript: %w", err)
+	}
+	requestHeaders, err := json.Marshal(session.RequestHeaders)
+	if err != nil {
+		return fmt.Errorf("failed to encode request headers: %w", err)
+	}
+	responseHeaders, err := json.Marshal(session.ResponseHeaders)
+	if err != nil {
+		return fmt.Errorf("failed to encode response headers: %w", err)
+	}
+
+	statusCode := http.StatusSwitchingProtocols
+	durationMs := int(recorder.transcript.ClosedAt.Sub(session.OpenedAt).Milliseconds())
+	receivedBytes := recorder.receivedBytes
+
+	return s.repository.Create(ctx, &model.Request{
+		UserID:             userID,
+		RequestType:        model.RequestTypeWebSocket,
+		RequestMethod:      http.MethodGet,
+		RequestURL:         session.URL.String(),
+		RequestHeaders:     requestHeaders,
+		ResponseStatusCode: &statusCode,
+		ResponseHeaders:    responseHeaders,
+		ResponseSize:       &receivedBytes,
+		DurationMs:         &durationMs,
+		SessionTranscript:  transcript,
+	})
+}
@@ -0,0 +1,32 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestTranscriptRecorderLimits(t *testing.T) {
+	recorder := &transcriptRecorder{}
+	large := make([]byte, maxWebSocketMessageSize)
+	for range maxTranscriptBytes / len(large) {
+		recorder.record("received", websocket.BinaryMessage, large)
+	}
+	recorder.record("received", websocket.BinaryMessage, large)
+	recorder.record("sent", websocket.TextMessage, []byte("ping"))
+	transcript := recorder.transcript
+	if len(transcript.Messages) != maxTranscriptBytes/len(large) || transcript.MessagesDropped != 2 {
+		t.Errorf("kept %d messages, dropped %d", len(transcript.Messages), transcript.MessagesDropped)
+	}
+	if recorder.receivedBytes != int64(len(large))*int64(maxTranscriptBytes/len(large)+1) {
+		t.Errorf("receivedBytes = %d, dropped messages must still be counted", recorder.receivedBytes)
+	}
+
+	recorder = &transcriptRecorder{}
+	for range maxTranscriptMessages + 2 {
+		recorder.record("sent", websocket.TextMessage, []byte("ping"))
+	}
+	if len(recorder.transcript.Messages) != maxTranscriptMessages || recorder.transcript.MessagesDropped != 2 {
+		t.Errorf("kept %d messages, dropped %d", len(recorder.transcript.Messages), recorder.transcript.MessagesDropped)
+	}
+}